@@ -76,6 +76,29 @@ const SIPHeaderNames_SUBSCRIPTION_STATE = "Subscription-State"   //48
 const SIPHeaderNames_EVENT = "Event"                             //44
 const SIPHeaderNames_ALLOW_EVENTS = "Allow-Events"               //45
 const SIPHeaderNames_REFER_TO = "Refer-To"                       //46
+const SIPHeaderNames_SESSION_EXPIRES = "Session-Expires"         //47
+const SIPHeaderNames_MIN_SE = "Min-SE"                           //48
+const SIPHeaderNames_REFER_SUB = "Refer-Sub"                     //49
+const SIPHeaderNames_REPLACES = "Replaces"                       //50
+const SIPHeaderNames_JOIN = "Join"                               //51
+const SIPHeaderNames_SIP_ETAG = "SIP-ETag"                       //52
+const SIPHeaderNames_SIP_IF_MATCH = "SIP-If-Match"               //53
+const SIPHeaderNames_PATH = "Path"                               //54
+const SIPHeaderNames_SERVICE_ROUTE = "Service-Route"             //55
+
+const SIPHeaderNames_P_ASSERTED_IDENTITY = "P-Asserted-Identity"   //56
+const SIPHeaderNames_P_PREFERRED_IDENTITY = "P-Preferred-Identity" //57
+const SIPHeaderNames_PRIVACY = "Privacy"                           //58
+
+const SIPHeaderNames_HISTORY_INFO = "History-Info" //59
+const SIPHeaderNames_DIVERSION = "Diversion"       //60
+const SIPHeaderNames_IDENTITY = "Identity"         //61
+const SIPHeaderNames_FLOW_TIMER = "Flow-Timer"     //62
+
+const SIPHeaderNames_ACCEPT_CONTACT = "Accept-Contact"           //63
+const SIPHeaderNames_REJECT_CONTACT = "Reject-Contact"           //64
+const SIPHeaderNames_REQUEST_DISPOSITION = "Request-Disposition" //65
+
 const SIPHeaderNames_K = "K"
 const SIPHeaderNames_C = "C"
 const SIPHeaderNames_E = "E"
@@ -87,6 +110,7 @@ const SIPHeaderNames_S = "S"
 const SIPHeaderNames_T = "T"
 const SIPHeaderNames_V = "V"
 const SIPHeaderNames_R = "R"
+const SIPHeaderNames_X = "X"
 
 const SIPMethodNames_INVITE = "INVITE"
 const SIPMethodNames_ACK = "ACK"
@@ -96,6 +120,7 @@ const SIPMethodNames_NOTIFY = "NOTIFY"
 const SIPMethodNames_OPTIONS = "OPTIONS"
 const SIPMethodNames_REGISTER = "REGISTER"
 const SIPMethodNames_MESSAGE = "MESSAGE"
+const SIPMethodNames_PUBLISH = "PUBLISH"
 
 const SIPTransportNames_UDP = "udp"
 const SIPTransportNames_TCP = "tcp"
@@ -6,6 +6,7 @@ type RawPacket struct {
 	receivetime   *RTPTime
 	senderaddress Address
 	isrtp         bool
+	muxed         bool
 }
 
 /** Creates an instance which stores data from \c data with length \c datalen.
@@ -27,6 +28,28 @@ func NewRawPacket(data []byte,
 	return this
 }
 
+/** Creates an instance for data received on a port which carries both RTP and
+ *  RTCP (RFC 5761). Whether the data is RTP or RTCP is decided with IsRTCPMuxed.
+ */
+func NewMuxedRawPacket(data []byte,
+	address Address,
+	recvtime *RTPTime) *RawPacket {
+	this := NewRawPacket(data, address, recvtime, !IsRTCPMuxed(data))
+	this.muxed = true
+	return this
+}
+
+/** Applies the demultiplexing rule of RFC 5761 section 4: a packet whose second
+ *  byte is in the range 192-223 (the RTCP packet types, or an RTP marker bit with
+ *  payload types 64-95) is RTCP, anything else is RTP.
+ */
+func IsRTCPMuxed(data []byte) bool {
+	if len(data) < 2 {
+		return false
+	}
+	return data[1] >= RTP_RTCPTYPE_MUXMIN && data[1] <= RTP_RTCPTYPE_MUXMAX
+}
+
 /** Returns the pointer to the data which is contained in this packet. */
 func (this *RawPacket) GetData() []byte {
 	return this.packetdata
@@ -47,7 +70,9 @@ func (this *RawPacket) GetSenderAddress() Address {
 	return this.senderaddress
 }
 
-/** Returns \c true if this data is RTP data, \c false if it is RTCP data. */
+/** Returns \c true if this data is RTP data, \c false if it is RTCP data.
+ *  For packets created with NewMuxedRawPacket this follows RFC 5761.
+ */
 func (this *RawPacket) IsRTP() bool {
 	return this.isrtp
 }
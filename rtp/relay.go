@@ -0,0 +1,483 @@
+package rtp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const RELAY_DEFAULTTIMEOUT = 60 * time.Second
+const RELAY_RECEIVEBUFFER = 65536
+
+/** This interface is implemented by the users of a Relay to learn about the streams it closes. */
+type RelayListener interface {
+	/** Called once \c stream is closed because no packet was received on it for the timeout of the
+	 *  relay, since its creation, its last packet or the end of its hold.
+	 */
+	StreamTimedOut(stream *RelayStream)
+}
+
+/** An in-process RTP/RTCP relay, e.g. to let media traverse NATs.
+ *  Each RelayStream relays one media stream between two parties through two endpoints, one
+ *  facing each party, with an RTP and an RTCP port of their own. The packets received on the
+ *  endpoint of one party are sent from the endpoint of the other party to the address of that
+ *  party. The address of a party is first the one announced in its session description, and is
+ *  then latched onto the source of the first packet it sends (symmetric RTP, RFC 4961), so that
+ *  a party behind a NAT is reached through the binding its own packets opened. Packets from
+ *  other sources are dropped.
+ */
+type Relay struct {
+	mutex sync.Mutex
+
+	bindIP           net.IP
+	portmin, portmax uint16
+	nextport         uint16
+	timeout          time.Duration
+	listener         RelayListener
+	streams          map[*RelayStream]bool
+}
+
+/** Creates a relay binding its sockets to \c bindIP, or to all addresses if \c bindIP is nil,
+ *  and allocating its port pairs in the range \c portmin to \c portmax.
+ */
+func NewRelay(bindIP net.IP, portmin, portmax uint16) *Relay {
+	this := &Relay{}
+	this.bindIP = bindIP
+	this.portmin = portmin + portmin%2
+	this.portmax = portmax
+	this.nextport = this.portmin
+	this.timeout = RELAY_DEFAULTTIMEOUT
+	this.streams = make(map[*RelayStream]bool)
+	return this
+}
+
+/** Sets the inactivity timeout of the streams created afterwards; 0 disables it. */
+func (this *Relay) SetTimeout(timeout time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.timeout = timeout
+}
+
+/** Returns the inactivity timeout of the streams (default is 60 seconds). */
+func (this *Relay) GetTimeout() time.Duration {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.timeout
+}
+
+/** Sets the listener informed of the streams timing out. */
+func (this *Relay) SetRelayListener(listener RelayListener) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.listener = listener
+}
+
+/** Creates a stream and allocates the port pairs of its two endpoints. Its inactivity timer
+ *  starts at once, and is suspended while an endpoint is on hold.
+ */
+func (this *Relay) CreateStream() (*RelayStream, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	stream := &RelayStream{relay: this, timeout: this.timeout}
+	for i := range stream.endpoints {
+		endpoint, err := this.allocateEndpoint()
+		if err != nil {
+			for _, e := range stream.endpoints[:i] {
+				e.close()
+			}
+			return nil, err
+		}
+		endpoint.stream = stream
+		stream.endpoints[i] = endpoint
+	}
+	stream.endpoints[0].peer = stream.endpoints[1]
+	stream.endpoints[1].peer = stream.endpoints[0]
+	// a stream on which no packet is ever received times out as well
+	stream.mutex.Lock()
+	stream.lastactivity = time.Now()
+	if stream.timeout > 0 {
+		stream.timer = time.AfterFunc(stream.timeout, stream.checkTimeout)
+	}
+	stream.mutex.Unlock()
+	for _, endpoint := range stream.endpoints {
+		go endpoint.receive(endpoint.rtpconn, true)
+		go endpoint.receive(endpoint.rtcpconn, false)
+	}
+	this.streams[stream] = true
+	return stream, nil
+}
+
+/** Returns the streams of the relay not closed yet. */
+func (this *Relay) GetStreams() []*RelayStream {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	streams := make([]*RelayStream, 0, len(this.streams))
+	for stream := range this.streams {
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+/** Closes all the streams of the relay. */
+func (this *Relay) Close() {
+	for _, stream := range this.GetStreams() {
+		stream.Close()
+	}
+}
+
+/** Binds an even RTP port and the next port for RTCP, starting after the last pair allocated. */
+func (this *Relay) allocateEndpoint() (*RelayEndpoint, error) {
+	if this.portmax <= this.portmin {
+		return nil, errors.New("ERR_RTP_RELAY_BADPORTRANGE")
+	}
+	count := int(this.portmax-this.portmin)/2 + 1
+	for i := 0; i < count; i++ {
+		port := this.nextport
+		if int(port)+1 > int(this.portmax) || port < this.portmin {
+			port = this.portmin
+		}
+		this.nextport = port + 2
+
+		rtpconn, err := net.ListenUDP("udp", &net.UDPAddr{IP: this.bindIP, Port: int(port)})
+		if err != nil {
+			continue
+		}
+		rtcpconn, err := net.ListenUDP("udp", &net.UDPAddr{IP: this.bindIP, Port: int(port + 1)})
+		if err != nil {
+			rtpconn.Close()
+			continue
+		}
+		return &RelayEndpoint{rtpconn: rtpconn, rtcpconn: rtcpconn, port: port}, nil
+	}
+	return nil, errors.New("ERR_RTP_RELAY_NOPORTAVAILABLE")
+}
+
+/** A media stream relayed between two parties, through the endpoints 0 and 1. */
+type RelayStream struct {
+	mutex sync.Mutex
+
+	relay        *Relay
+	endpoints    [2]*RelayEndpoint
+	timeout      time.Duration
+	timer        *time.Timer
+	lastactivity time.Time
+	closed       bool
+}
+
+/** Returns the endpoint 0 or 1 of the stream. */
+func (this *RelayStream) GetEndpoint(i int) *RelayEndpoint {
+	return this.endpoints[i]
+}
+
+/** Returns \c true once the stream is closed. */
+func (this *RelayStream) IsClosed() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.closed
+}
+
+/** Closes the sockets of the stream, which stops relaying. */
+func (this *RelayStream) Close() {
+	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+		return
+	}
+	this.closed = true
+	if this.timer != nil {
+		this.timer.Stop()
+	}
+	this.mutex.Unlock()
+
+	for _, endpoint := range this.endpoints {
+		endpoint.close()
+	}
+	this.relay.mutex.Lock()
+	delete(this.relay.streams, this)
+	this.relay.mutex.Unlock()
+}
+
+/** Relays a packet received by \c endpoint on its RTP socket if \c rtpsocket is set, or else on
+ *  its RTCP socket.
+ */
+func (this *RelayStream) forward(endpoint *RelayEndpoint, packet *RawPacket, rtpsocket bool) {
+	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+		return
+	}
+	if !endpoint.latch(packet.GetSenderAddress().(*IPAddress), rtpsocket) {
+		endpoint.counters.dropped++
+		this.mutex.Unlock()
+		return
+	}
+	this.lastactivity = time.Now()
+
+	peer := endpoint.peer
+	conn, destination := peer.rtpconn, peer.remotertp
+	if !packet.IsRTP() && !peer.rtcpmux {
+		conn, destination = peer.rtcpconn, peer.remotertcp
+	}
+	if destination == nil {
+		// the address of the other party is not known yet
+		endpoint.counters.dropped++
+		this.mutex.Unlock()
+		return
+	}
+	endpoint.counters.count(packet)
+	this.mutex.Unlock()
+
+	conn.WriteToUDP(packet.GetData(), &net.UDPAddr{IP: destination.GetIP(), Port: int(destination.GetPort())})
+}
+
+/** Closes the stream if it was inactive for its timeout, or else checks again when it could be.
+ *  A stream on hold is left alone until its hold ends.
+ */
+func (this *RelayStream) checkTimeout() {
+	this.mutex.Lock()
+	if this.closed || this.isOnHold() {
+		this.mutex.Unlock()
+		return
+	}
+	if idle := time.Since(this.lastactivity); idle < this.timeout {
+		this.timer.Reset(this.timeout - idle)
+		this.mutex.Unlock()
+		return
+	}
+	this.mutex.Unlock()
+
+	this.Close()
+	this.relay.mutex.Lock()
+	listener := this.relay.listener
+	this.relay.mutex.Unlock()
+	if listener != nil {
+		listener.StreamTimedOut(this)
+	}
+}
+
+/** Returns \c true if an endpoint of the stream is on hold; the stream mutex must be held. */
+func (this *RelayStream) isOnHold() bool {
+	return this.endpoints[0].onhold || this.endpoints[1].onhold
+}
+
+/** The RTP and RTCP ports of a stream facing one party. */
+type RelayEndpoint struct {
+	stream *RelayStream
+	peer   *RelayEndpoint
+
+	rtpconn, rtcpconn       *net.UDPConn
+	port                    uint16
+	announcedrtp            *IPAddress
+	announcedrtcp           *IPAddress
+	remotertp, remotertcp   *IPAddress
+	rtcpmux                 bool
+	rtplatched, rtcplatched bool
+	onhold                  bool
+	counters                RelayCounters
+}
+
+/** Returns the local address of the RTP socket; its IP is the one the relay is bound to. */
+func (this *RelayEndpoint) GetLocalAddress() *IPAddress {
+	return NewIPAddress(this.stream.relay.bindIP, this.port)
+}
+
+/** Returns the local port of the RTP socket. */
+func (this *RelayEndpoint) GetRTPPort() uint16 {
+	return this.port
+}
+
+/** Returns the local port of the RTCP socket. */
+func (this *RelayEndpoint) GetRTCPPort() uint16 {
+	return this.port + 1
+}
+
+/** Sets the RTP and RTCP addresses announced by the party of the endpoint in its session
+ *  description. A nil \c rtcp tells that the party multiplexes RTCP on its RTP port (RFC 5761),
+ *  and a nil \c rtp that its address is unknown. New addresses cancel the latching, which
+ *  happens again with the next packet of the party; the same addresses announced again leave
+ *  it in place.
+ */
+func (this *RelayEndpoint) SetRemoteAddress(rtp, rtcp *IPAddress) {
+	this.stream.mutex.Lock()
+	defer this.stream.mutex.Unlock()
+	if sameAddress(rtp, this.announcedrtp) && sameAddress(rtcp, this.announcedrtcp) &&
+		this.rtcpmux == (rtcp == nil) {
+		return
+	}
+	this.announcedrtp, this.announcedrtcp = rtp, rtcp
+	this.remotertp, this.remotertcp = rtp, rtcp
+	this.rtcpmux = rtcp == nil
+	this.rtplatched, this.rtcplatched = false, false
+}
+
+/** Sets whether the party of the endpoint put the stream on hold, so that no packet is expected
+ *  on it. The inactivity timer of the stream is suspended while either endpoint is on hold, and
+ *  starts again once neither is.
+ */
+func (this *RelayEndpoint) SetOnHold(onhold bool) {
+	stream := this.stream
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	washeld := stream.isOnHold()
+	this.onhold = onhold
+	if stream.closed || stream.timer == nil || stream.isOnHold() == washeld {
+		return
+	}
+	if washeld {
+		stream.lastactivity = time.Now()
+		stream.timer.Reset(stream.timeout)
+	} else {
+		stream.timer.Stop()
+	}
+}
+
+/** Returns \c true if the party of the endpoint put the stream on hold. */
+func (this *RelayEndpoint) IsOnHold() bool {
+	this.stream.mutex.Lock()
+	defer this.stream.mutex.Unlock()
+	return this.onhold
+}
+
+/** Returns the address the RTP packets of the other party are sent to, or nil if unknown. */
+func (this *RelayEndpoint) GetRemoteAddress() *IPAddress {
+	this.stream.mutex.Lock()
+	defer this.stream.mutex.Unlock()
+	return this.remotertp
+}
+
+/** Returns the address the RTCP packets of the other party are sent to, or nil if unknown or
+ *  if they are sent to the RTP address.
+ */
+func (this *RelayEndpoint) GetRemoteRTCPAddress() *IPAddress {
+	this.stream.mutex.Lock()
+	defer this.stream.mutex.Unlock()
+	if this.rtcpmux {
+		return nil
+	}
+	return this.remotertcp
+}
+
+/** Returns \c true once the RTP address of the party was latched onto the source of its packets. */
+func (this *RelayEndpoint) IsLatched() bool {
+	this.stream.mutex.Lock()
+	defer this.stream.mutex.Unlock()
+	return this.rtplatched
+}
+
+/** Returns the counters of the packets received from the party. */
+func (this *RelayEndpoint) GetCounters() RelayCounters {
+	this.stream.mutex.Lock()
+	defer this.stream.mutex.Unlock()
+	return this.counters
+}
+
+/** Latches the remote address of the socket onto \c source, the sender of a packet received on
+ *  it, if not done yet. Returns \c false if the packet comes from another source than the one
+ *  latched.
+ */
+func (this *RelayEndpoint) latch(source *IPAddress, rtpsocket bool) bool {
+	if rtpsocket {
+		if this.rtplatched {
+			return this.remotertp.IsSameAddress(source)
+		}
+		this.remotertp, this.rtplatched = source, true
+		return true
+	}
+	if this.rtcplatched {
+		return this.remotertcp.IsSameAddress(source)
+	}
+	this.remotertcp, this.rtcplatched = source, true
+	return true
+}
+
+/** Reads the packets of a socket until it is closed. The packets received on the RTP socket
+ *  may be multiplexed RTCP packets.
+ */
+func (this *RelayEndpoint) receive(conn *net.UDPConn, rtpsocket bool) {
+	buffer := make([]byte, RELAY_RECEIVEBUFFER)
+	for {
+		n, source, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		data := make([]byte, n)
+		copy(data, buffer[:n])
+		ip := source.IP
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		address := NewIPAddress(ip, uint16(source.Port))
+		var packet *RawPacket
+		if rtpsocket {
+			packet = NewMuxedRawPacket(data, address, CurrentRTPTime())
+		} else {
+			packet = NewRawPacket(data, address, CurrentRTPTime(), false)
+		}
+		this.stream.forward(this, packet, rtpsocket)
+	}
+}
+
+func (this *RelayEndpoint) close() {
+	this.rtpconn.Close()
+	this.rtcpconn.Close()
+}
+
+func sameAddress(a, b *IPAddress) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.IsSameAddress(b)
+}
+
+/** The counters of the packets received from one party of a stream. */
+type RelayCounters struct {
+	rtppackets, rtpbytes   uint64
+	rtcppackets, rtcpbytes uint64
+	dropped                uint64
+	lastreceivetime        *RTPTime
+}
+
+func (this *RelayCounters) count(packet *RawPacket) {
+	if packet.IsRTP() {
+		this.rtppackets++
+		this.rtpbytes += uint64(packet.GetDataLength())
+	} else {
+		this.rtcppackets++
+		this.rtcpbytes += uint64(packet.GetDataLength())
+	}
+	this.lastreceivetime = packet.GetReceiveTime()
+}
+
+/** Returns the number of RTP packets relayed. */
+func (this RelayCounters) GetRTPPackets() uint64 {
+	return this.rtppackets
+}
+
+/** Returns the number of bytes of the RTP packets relayed. */
+func (this RelayCounters) GetRTPBytes() uint64 {
+	return this.rtpbytes
+}
+
+/** Returns the number of RTCP packets relayed. */
+func (this RelayCounters) GetRTCPPackets() uint64 {
+	return this.rtcppackets
+}
+
+/** Returns the number of bytes of the RTCP packets relayed. */
+func (this RelayCounters) GetRTCPBytes() uint64 {
+	return this.rtcpbytes
+}
+
+/** Returns the number of packets dropped, coming from another source than the one latched or
+ *  received before the address of the other party was known.
+ */
+func (this RelayCounters) GetDroppedPackets() uint64 {
+	return this.dropped
+}
+
+/** Returns the time the last packet was received from the party, or nil if none was. */
+func (this RelayCounters) GetLastReceiveTime() *RTPTime {
+	return this.lastreceivetime
+}
@@ -0,0 +1,171 @@
+package rtp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+type relayTimeouts chan *RelayStream
+
+func (this relayTimeouts) StreamTimedOut(stream *RelayStream) {
+	this <- stream
+}
+
+func listenParty(t *testing.T) (*net.UDPConn, *IPAddress) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, NewIPAddress(net.IPv4(127, 0, 0, 1).To4(), uint16(conn.LocalAddr().(*net.UDPAddr).Port))
+}
+
+func sendTo(t *testing.T, conn *net.UDPConn, data []byte, port uint16) {
+	if _, err := conn.WriteToUDP(data, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: int(port)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receiveFrom(t *testing.T, conn *net.UDPConn, port uint16) []byte {
+	buffer := make([]byte, 1500)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, source, err := conn.ReadFromUDP(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Port != int(port) {
+		t.Errorf("received from port %d instead of %d", source.Port, port)
+	}
+	return buffer[:n]
+}
+
+func TestRelay(t *testing.T) {
+	relay := NewRelay(net.IPv4(127, 0, 0, 1), 41000, 41100)
+	defer relay.Close()
+	timeouts := make(relayTimeouts, 1)
+	relay.SetRelayListener(timeouts)
+	relay.SetTimeout(200 * time.Millisecond)
+
+	stream, err := relay.CreateStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := stream.GetEndpoint(0), stream.GetEndpoint(1)
+	if a.GetRTPPort()%2 != 0 || a.GetRTCPPort() != a.GetRTPPort()+1 || a.GetRTPPort() == b.GetRTPPort() {
+		t.Fatal("bad port pairs", a.GetRTPPort(), b.GetRTPPort())
+	}
+
+	partyA, _ := listenParty(t)
+	defer partyA.Close()
+	partyB, addressB := listenParty(t)
+	defer partyB.Close()
+	stranger, _ := listenParty(t)
+	defer stranger.Close()
+
+	// A announces a private address and latches, B multiplexes RTCP
+	a.SetRemoteAddress(NewIPAddress(net.IPv4(10, 0, 0, 1).To4(), 4000), NewIPAddress(net.IPv4(10, 0, 0, 1).To4(), 4001))
+	b.SetRemoteAddress(addressB, nil)
+
+	rtp := NewPacket(0, []byte{1, 2, 3, 4}, 1, 160, 0x1234, false, 0, nil, false, 0, 0, nil).GetPacket()
+	sendTo(t, partyA, rtp, a.GetRTPPort())
+	if data := receiveFrom(t, partyB, b.GetRTPPort()); string(data) != string(rtp) {
+		t.Error("RTP of A not relayed to B")
+	}
+	if !a.IsLatched() || a.GetRemoteAddress().GetPort() != uint16(partyA.LocalAddr().(*net.UDPAddr).Port) {
+		t.Error("A not latched", a.GetRemoteAddress())
+	}
+
+	sendTo(t, partyB, rtp, b.GetRTPPort())
+	receiveFrom(t, partyA, a.GetRTPPort())
+
+	rr := []byte{0x80, RTP_RTCPTYPE_RR, 0x00, 0x01, 0x00, 0x00, 0x12, 0x34}
+	sendTo(t, partyA, rr, a.GetRTCPPort())
+	if data := receiveFrom(t, partyB, b.GetRTPPort()); string(data) != string(rr) {
+		t.Error("RTCP of A not multiplexed to B")
+	}
+
+	sendTo(t, stranger, rtp, a.GetRTPPort())
+	sendTo(t, partyA, rtp, a.GetRTPPort())
+	receiveFrom(t, partyB, b.GetRTPPort())
+
+	counters := a.GetCounters()
+	if counters.GetRTPPackets() != 2 || counters.GetRTPBytes() != uint64(2*len(rtp)) ||
+		counters.GetRTCPPackets() != 1 || counters.GetRTCPBytes() != uint64(len(rr)) ||
+		counters.GetDroppedPackets() != 1 || counters.GetLastReceiveTime() == nil {
+		t.Error("bad counters of A", counters)
+	}
+	if counters := b.GetCounters(); counters.GetRTPPackets() != 1 || counters.GetDroppedPackets() != 0 {
+		t.Error("bad counters of B", counters)
+	}
+
+	select {
+	case timedOut := <-timeouts:
+		if timedOut != stream || !stream.IsClosed() || len(relay.GetStreams()) != 0 {
+			t.Error("bad timeout")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("stream not timed out")
+	}
+}
+
+func TestRelayPortRange(t *testing.T) {
+	relay := NewRelay(net.IPv4(127, 0, 0, 1), 41201, 41206)
+	defer relay.Close()
+	stream, err := relay.CreateStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream.GetEndpoint(0).GetRTPPort() != 41202 {
+		t.Error("RTP port not even", stream.GetEndpoint(0).GetRTPPort())
+	}
+	if _, err := relay.CreateStream(); err == nil {
+		t.Error("port range exhausted but stream created")
+	}
+	stream.Close()
+	if _, err := relay.CreateStream(); err != nil {
+		t.Error("ports of a closed stream not reused", err)
+	}
+}
+
+func TestRelayIdleStream(t *testing.T) {
+	relay := NewRelay(net.IPv4(127, 0, 0, 1), 41300, 41310)
+	defer relay.Close()
+	timeouts := make(relayTimeouts, 1)
+	relay.SetRelayListener(timeouts)
+	relay.SetTimeout(100 * time.Millisecond)
+
+	// a stream never used times out from its creation
+	stream, err := relay.CreateStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case timedOut := <-timeouts:
+		if timedOut != stream || !stream.IsClosed() {
+			t.Error("bad timeout")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("idle stream not timed out")
+	}
+
+	// a stream on hold does not time out until its hold ends
+	stream, err = relay.CreateStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.GetEndpoint(1).SetOnHold(true)
+	select {
+	case <-timeouts:
+		t.Error("stream on hold timed out")
+	case <-time.After(300 * time.Millisecond):
+	}
+	stream.GetEndpoint(1).SetOnHold(false)
+	select {
+	case timedOut := <-timeouts:
+		if timedOut != stream || !stream.IsClosed() {
+			t.Error("bad timeout")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("stream not timed out after its hold")
+	}
+}
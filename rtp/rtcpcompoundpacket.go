@@ -0,0 +1,134 @@
+package rtp
+
+import (
+	"errors"
+	"fmt"
+)
+
+/** The methods shared by all RTCP packet types. */
+type RTCPPacketer interface {
+	IsKnownFormat() bool
+	GetPacketType() RTCPPacketType
+	GetPacketData() []byte
+	GetPacketLength() int
+	Dump()
+}
+
+/** Represents an RTCP compound packet. */
+type RTCPCompoundPacket struct {
+	compoundpacket []byte
+	packets        []RTCPPacketer
+	reducedsize    bool
+}
+
+/** Creates an RTCP compound packet based on the data in \c rawpack.
+ *  When \c reducedsize is \c true, reduced-size RTCP packets as described in RFC 5506
+ *  are accepted as well: the packet need not start with a sender or receiver report
+ *  and may consist of a single RTCP packet. This should only be allowed if the use
+ *  of reduced-size RTCP was negotiated ("a=rtcp-rsize").
+ */
+func NewRTCPCompoundPacket(rawpack *RawPacket, reducedsize bool) (*RTCPCompoundPacket, error) {
+	if rawpack.IsRTP() {
+		return nil, errors.New("ERR_RTP_RTCPCOMPOUND_INVALIDPACKET")
+	}
+	return NewRTCPCompoundPacketFromData(rawpack.GetData(), reducedsize)
+}
+
+/** Creates an RTCP compound packet from the RTCP data in \c data. See NewRTCPCompoundPacket. */
+func NewRTCPCompoundPacketFromData(data []byte, reducedsize bool) (*RTCPCompoundPacket, error) {
+	this := &RTCPCompoundPacket{}
+	this.compoundpacket = make([]byte, len(data))
+	copy(this.compoundpacket, data)
+	if err := this.parseData(reducedsize); err != nil {
+		return nil, err
+	}
+	return this, nil
+}
+
+func (this *RTCPCompoundPacket) parseData(reducedsize bool) error {
+	data := this.compoundpacket
+	first := true
+
+	for len(data) > 0 {
+		if len(data) < SIZEOF_RTCPHEADER {
+			return errors.New("ERR_RTP_RTCPCOMPOUND_INVALIDPACKET")
+		}
+		if ((data[0] >> RTCP_HEADER_V_POS) & RTCP_HEADER_V_MSK) != RTP_VERSION {
+			return errors.New("ERR_RTP_RTCPCOMPOUND_INVALIDPACKET")
+		}
+
+		packettype := data[1]
+		if first && packettype != RTP_RTCPTYPE_SR && packettype != RTP_RTCPTYPE_RR {
+			if !reducedsize {
+				return errors.New("ERR_RTP_RTCPCOMPOUND_INVALIDPACKET")
+			}
+			this.reducedsize = true
+		}
+
+		length := (int(data[2])<<8 | int(data[3]) + 1) * 4
+		if length > len(data) {
+			return errors.New("ERR_RTP_RTCPCOMPOUND_INVALIDPACKET")
+		}
+		// only the last packet of a compound packet may contain padding
+		if ((data[0]>>RTCP_HEADER_P_POS)&RTCP_HEADER_P_MSK) != 0 && length != len(data) {
+			return errors.New("ERR_RTP_RTCPCOMPOUND_INVALIDPACKET")
+		}
+
+		var packet RTCPPacketer
+		switch packettype {
+		case RTP_RTCPTYPE_SR:
+			packet = NewRTCPPacket(SR, data, length)
+		case RTP_RTCPTYPE_RR:
+			packet = NewRTCPPacket(RR, data, length)
+		case RTP_RTCPTYPE_SDES:
+			packet = NewRTCPPacket(SDES, data, length)
+		case RTP_RTCPTYPE_BYE:
+			packet = NewRTCPBYEPacket(data, length)
+		case RTP_RTCPTYPE_APP:
+			packet = NewRTCPAPPPacket(data, length)
+		case RTP_RTCPTYPE_XR:
+			packet = NewRTCPXRPacket(data, length)
+		default:
+			packet = NewRTCPPacket(Unknown, data, length)
+		}
+		this.packets = append(this.packets, packet)
+
+		data = data[length:]
+		first = false
+	}
+	if len(this.packets) == 1 {
+		this.reducedsize = true
+	}
+	return nil
+}
+
+/** Returns the data of the entire RTCP compound packet. */
+func (this *RTCPCompoundPacket) GetCompoundPacketData() []byte {
+	return this.compoundpacket
+}
+
+/** Returns the size of the entire RTCP compound packet. */
+func (this *RTCPCompoundPacket) GetCompoundPacketLength() int {
+	return len(this.compoundpacket)
+}
+
+/** Returns the individual RTCP packets, in order. */
+func (this *RTCPCompoundPacket) GetPackets() []RTCPPacketer {
+	return this.packets
+}
+
+/** Returns \c true if the packet is a reduced-size RTCP packet (RFC 5506): it
+ *  does not start with a report or consists of a single RTCP packet.
+ */
+func (this *RTCPCompoundPacket) IsReducedSize() bool {
+	return this.reducedsize
+}
+
+func (this *RTCPCompoundPacket) Dump() {
+	fmt.Printf("----------------------------------------------------------------\n")
+	for _, p := range this.packets {
+		p.Dump()
+		fmt.Printf("\n")
+	}
+	fmt.Printf("----------------------------------------------------------------\n")
+}
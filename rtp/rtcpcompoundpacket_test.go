@@ -0,0 +1,68 @@
+package rtp
+
+import "testing"
+
+func TestRTCPMuxDemux(t *testing.T) {
+	rtp := NewPacket(96, []byte{1, 2, 3}, 1, 160, 0x1234, true, 0, nil, false, 0, 0, nil)
+	xr := NewRTCPXRPacketFromBlocks(0x1234)
+
+	if IsRTCPMuxed(rtp.GetPacket()) || !NewMuxedRawPacket(rtp.GetPacket(), nil, nil).IsRTP() {
+		t.Error("RTP packet classified as RTCP")
+	}
+	if !IsRTCPMuxed(xr.GetPacketData()) || NewMuxedRawPacket(xr.GetPacketData(), nil, nil).IsRTP() {
+		t.Error("RTCP packet classified as RTP")
+	}
+
+	// payload type 72 with the marker bit looks like a sender report
+	conflicting := append([]byte{}, rtp.GetPacket()...)
+	conflicting[1] = 0x80 | 72
+	if NewRTPPacketFromRawPacket(NewRawPacket(conflicting, nil, CurrentRTPTime(), true)) != nil {
+		t.Error("RTCP packet type accepted as RTP")
+	}
+
+	// payload type 80 with the marker bit is only RTCP on a multiplexed port
+	conflicting[1] = 0x80 | 80
+	if NewRTPPacketFromRawPacket(NewRawPacket(conflicting, nil, CurrentRTPTime(), true)) == nil {
+		t.Error("RTP packet rejected on an RTP port")
+	}
+	if NewRTPPacketFromRawPacket(NewMuxedRawPacket(conflicting, nil, CurrentRTPTime())) != nil {
+		t.Error("RTCP packet type accepted as RTP on a multiplexed port")
+	}
+}
+
+func TestRTCPCompoundPacket(t *testing.T) {
+	rr := []byte{0x80, RTP_RTCPTYPE_RR, 0x00, 0x01, 0x00, 0x00, 0x12, 0x34}
+	bye := []byte{0x81, RTP_RTCPTYPE_BYE, 0x00, 0x01, 0x00, 0x00, 0x12, 0x34}
+	xr := NewRTCPXRPacketFromBlocks(0x1234, NewRTCPXRVoIPMetricsBlock(0x5678)).GetPacketData()
+
+	compound, err := NewRTCPCompoundPacketFromData(append(append([]byte{}, rr...), bye...), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets := compound.GetPackets()
+	if len(packets) != 2 || packets[0].GetPacketType() != RR || packets[1].GetPacketType() != BYE ||
+		!packets[1].IsKnownFormat() || compound.IsReducedSize() {
+		t.Errorf("bad compound packet %v", packets)
+	}
+
+	// a lone XR packet is reduced-size RTCP
+	if _, err = NewRTCPCompoundPacketFromData(xr, false); err == nil {
+		t.Error("reduced-size packet accepted without negotiation")
+	}
+	compound, err = NewRTCPCompoundPacketFromData(xr, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compound.IsReducedSize() || compound.GetPackets()[0].GetPacketType() != XR {
+		t.Error("reduced-size packet not recognised")
+	}
+
+	// padding is only allowed in the last packet
+	padded := []byte{0xA0, RTP_RTCPTYPE_RR, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04}
+	if _, err = NewRTCPCompoundPacketFromData(append(append([]byte{}, padded...), bye...), true); err == nil {
+		t.Error("padding in the first packet accepted")
+	}
+	if _, err = NewRTCPCompoundPacketFromData(rr[:6], true); err == nil {
+		t.Error("truncated packet accepted")
+	}
+}
@@ -12,6 +12,7 @@ const (
 	BYE                           /**< An RTCP bye packet. */
 	APP                           /**< An RTCP packet containing application specific data. */
 	Unknown                       /**< The type of RTCP packet was not recognized. */
+	XR                            /**< An RTCP extended report packet (RFC 3611). */
 )
 
 /** Base class for specific types of RTCP packets. */
@@ -64,6 +65,8 @@ func (this *RTCPPacket) Dump() {
 		fmt.Printf("RTCP APP Packet         ")
 	case BYE:
 		fmt.Printf("RTCP Bye Packet         ")
+	case XR:
+		fmt.Printf("RTCP Extended Report    ")
 	case Unknown:
 		fmt.Printf("Unknown RTCP Packet     ")
 	default:
@@ -0,0 +1,659 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/** Report block types of RTCP extended reports (RFC 3611 section 4). */
+const RTCP_XR_LOSSRLE = 1
+const RTCP_XR_STATSUMMARY = 6
+const RTCP_XR_VOIPMETRICS = 7
+
+const SIZEOF_RTCPXRBLOCKHEADER = 4
+
+/** The value used by the VoIP metrics block for metrics that are not available. */
+const RTCP_XR_UNAVAILABLE = 127
+
+/** A report block of an RTCP XR packet. */
+type RTCPXRBlock interface {
+	/** Returns the block type (BT). */
+	GetBlockType() uint8
+	/** Encodes the block including its four byte block header. */
+	Encode() []byte
+}
+
+func newRTCPXRBlockHeader(blocktype, typespecific uint8, contentlen int) []byte {
+	b := make([]byte, SIZEOF_RTCPXRBLOCKHEADER+contentlen)
+	b[0] = blocktype
+	b[1] = typespecific
+	binary.BigEndian.PutUint16(b[2:], uint16(contentlen/4))
+	return b
+}
+
+/** A report block whose type is not interpreted. */
+type RTCPXRRawBlock struct {
+	blocktype    uint8
+	typespecific uint8
+	data         []byte
+}
+
+/** Creates a block of type \c blocktype; the length of \c data must be a multiple of four. */
+func NewRTCPXRRawBlock(blocktype, typespecific uint8, data []byte) *RTCPXRRawBlock {
+	return &RTCPXRRawBlock{blocktype: blocktype, typespecific: typespecific, data: data}
+}
+
+func (this *RTCPXRRawBlock) GetBlockType() uint8 {
+	return this.blocktype
+}
+
+/** Returns the type-specific byte of the block header. */
+func (this *RTCPXRRawBlock) GetTypeSpecific() uint8 {
+	return this.typespecific
+}
+
+/** Returns the block contents following the block header. */
+func (this *RTCPXRRawBlock) GetData() []byte {
+	return this.data
+}
+
+func (this *RTCPXRRawBlock) Encode() []byte {
+	b := newRTCPXRBlockHeader(this.blocktype, this.typespecific, len(this.data))
+	copy(b[SIZEOF_RTCPXRBLOCKHEADER:], this.data)
+	return b
+}
+
+/** Describes a Loss RLE report block (RFC 3611 section 4.1).
+ *  The block reports for each sequence number from begin_seq up to (but not
+ *  including) end_seq whether the packet was received.
+ */
+type RTCPXRLossRLEBlock struct {
+	thinning uint8
+	ssrc     uint32
+	beginseq uint16
+	endseq   uint16
+	chunks   []uint16
+}
+
+/** Creates a Loss RLE block for source \c ssrc. The \c n-th entry of \c received
+ *  tells whether the packet with sequence number \c beginseq+n arrived.
+ */
+func NewRTCPXRLossRLEBlock(ssrc uint32, beginseq uint16, received []bool) *RTCPXRLossRLEBlock {
+	this := &RTCPXRLossRLEBlock{ssrc: ssrc, beginseq: beginseq, endseq: beginseq + uint16(len(received))}
+
+	for i := 0; i < len(received); {
+		run := 1
+		for i+run < len(received) && received[i+run] == received[i] && run < 0x3FFF {
+			run++
+		}
+		if run >= 15 {
+			chunk := uint16(run)
+			if received[i] {
+				chunk |= 0x4000
+			}
+			this.chunks = append(this.chunks, chunk)
+			i += run
+			continue
+		}
+		chunk := uint16(0x8000)
+		for bit := 14; bit >= 0 && i < len(received); bit-- {
+			if received[i] {
+				chunk |= 1 << uint(bit)
+			}
+			i++
+		}
+		this.chunks = append(this.chunks, chunk)
+	}
+	return this
+}
+
+func (this *RTCPXRLossRLEBlock) GetBlockType() uint8 {
+	return RTCP_XR_LOSSRLE
+}
+
+/** Returns the thinning value T: only every 2^T-th sequence number is reported. */
+func (this *RTCPXRLossRLEBlock) GetThinning() uint8 {
+	return this.thinning
+}
+
+/** Returns the SSRC of the source this block reports on. */
+func (this *RTCPXRLossRLEBlock) GetSSRC() uint32 {
+	return this.ssrc
+}
+
+/** Returns the first sequence number covered by this block. */
+func (this *RTCPXRLossRLEBlock) GetBeginSeq() uint16 {
+	return this.beginseq
+}
+
+/** Returns the last sequence number covered by this block plus one. */
+func (this *RTCPXRLossRLEBlock) GetEndSeq() uint16 {
+	return this.endseq
+}
+
+/** Decodes the chunks: the \c n-th entry tells whether the \c n-th reported
+ *  sequence number was received.
+ */
+func (this *RTCPXRLossRLEBlock) GetReceived() []bool {
+	count := int(uint16(this.endseq-this.beginseq)) >> this.thinning
+	received := make([]bool, 0, count)
+	for _, chunk := range this.chunks {
+		if chunk == 0 {
+			continue
+		}
+		if chunk&0x8000 == 0 {
+			for n := int(chunk & 0x3FFF); n > 0; n-- {
+				received = append(received, chunk&0x4000 != 0)
+			}
+		} else {
+			for bit := 14; bit >= 0; bit-- {
+				received = append(received, chunk&(1<<uint(bit)) != 0)
+			}
+		}
+	}
+	if len(received) > count {
+		received = received[:count]
+	}
+	return received
+}
+
+func (this *RTCPXRLossRLEBlock) Encode() []byte {
+	chunks := len(this.chunks)
+	if chunks%2 != 0 {
+		chunks++ // terminating null chunk
+	}
+	b := newRTCPXRBlockHeader(RTCP_XR_LOSSRLE, this.thinning&0x0F, 8+2*chunks)
+	binary.BigEndian.PutUint32(b[4:], this.ssrc)
+	binary.BigEndian.PutUint16(b[8:], this.beginseq)
+	binary.BigEndian.PutUint16(b[10:], this.endseq)
+	for i, chunk := range this.chunks {
+		binary.BigEndian.PutUint16(b[12+2*i:], chunk)
+	}
+	return b
+}
+
+/** Describes a Statistics Summary report block (RFC 3611 section 4.6).
+ *  Each group of metrics is only meaningful if its flag is set.
+ */
+type RTCPXRStatisticsSummaryBlock struct {
+	ssrc     uint32
+	beginseq uint16
+	endseq   uint16
+
+	hasloss   bool
+	hasdup    bool
+	hasjitter bool
+	toh       uint8
+
+	lostpackets uint32
+	duppackets  uint32
+	minjitter   uint32
+	maxjitter   uint32
+	meanjitter  uint32
+	devjitter   uint32
+	minttl      uint8
+	maxttl      uint8
+	meanttl     uint8
+	devttl      uint8
+}
+
+/** Kinds of TTL information carried in a Statistics Summary block. */
+const RTCP_XR_TOH_NONE = 0
+const RTCP_XR_TOH_IPV4TTL = 1
+const RTCP_XR_TOH_IPV6HOPLIMIT = 2
+
+/** Creates an empty Statistics Summary block covering the sequence numbers
+ *  \c beginseq up to (but not including) \c endseq.
+ */
+func NewRTCPXRStatisticsSummaryBlock(ssrc uint32, beginseq, endseq uint16) *RTCPXRStatisticsSummaryBlock {
+	return &RTCPXRStatisticsSummaryBlock{ssrc: ssrc, beginseq: beginseq, endseq: endseq}
+}
+
+func (this *RTCPXRStatisticsSummaryBlock) GetBlockType() uint8 {
+	return RTCP_XR_STATSUMMARY
+}
+
+/** Returns the SSRC of the source this block reports on. */
+func (this *RTCPXRStatisticsSummaryBlock) GetSSRC() uint32 {
+	return this.ssrc
+}
+
+/** Returns the first sequence number covered by this block. */
+func (this *RTCPXRStatisticsSummaryBlock) GetBeginSeq() uint16 {
+	return this.beginseq
+}
+
+/** Returns the last sequence number covered by this block plus one. */
+func (this *RTCPXRStatisticsSummaryBlock) GetEndSeq() uint16 {
+	return this.endseq
+}
+
+/** Returns \c true if the block reports the number of lost packets. */
+func (this *RTCPXRStatisticsSummaryBlock) HasLostPackets() bool {
+	return this.hasloss
+}
+
+/** Returns the number of lost packets in the sequence number interval. */
+func (this *RTCPXRStatisticsSummaryBlock) GetLostPackets() uint32 {
+	return this.lostpackets
+}
+
+/** Sets the number of lost packets. */
+func (this *RTCPXRStatisticsSummaryBlock) SetLostPackets(lost uint32) {
+	this.hasloss = true
+	this.lostpackets = lost
+}
+
+/** Returns \c true if the block reports the number of duplicate packets. */
+func (this *RTCPXRStatisticsSummaryBlock) HasDuplicatePackets() bool {
+	return this.hasdup
+}
+
+/** Returns the number of duplicate packets in the sequence number interval. */
+func (this *RTCPXRStatisticsSummaryBlock) GetDuplicatePackets() uint32 {
+	return this.duppackets
+}
+
+/** Sets the number of duplicate packets. */
+func (this *RTCPXRStatisticsSummaryBlock) SetDuplicatePackets(dup uint32) {
+	this.hasdup = true
+	this.duppackets = dup
+}
+
+/** Returns \c true if the block reports jitter. */
+func (this *RTCPXRStatisticsSummaryBlock) HasJitter() bool {
+	return this.hasjitter
+}
+
+/** Returns the minimum, maximum, mean and standard deviation of the relative
+ *  transit time between packets, in timestamp units.
+ */
+func (this *RTCPXRStatisticsSummaryBlock) GetJitter() (min, max, mean, dev uint32) {
+	return this.minjitter, this.maxjitter, this.meanjitter, this.devjitter
+}
+
+/** Sets the jitter statistics, in timestamp units. */
+func (this *RTCPXRStatisticsSummaryBlock) SetJitter(min, max, mean, dev uint32) {
+	this.hasjitter = true
+	this.minjitter, this.maxjitter, this.meanjitter, this.devjitter = min, max, mean, dev
+}
+
+/** Returns which kind of TTL statistics the block carries (one of RTCP_XR_TOH_...). */
+func (this *RTCPXRStatisticsSummaryBlock) GetTTLType() uint8 {
+	return this.toh
+}
+
+/** Returns the minimum, maximum, mean and standard deviation of the TTL or hop limit. */
+func (this *RTCPXRStatisticsSummaryBlock) GetTTL() (min, max, mean, dev uint8) {
+	return this.minttl, this.maxttl, this.meanttl, this.devttl
+}
+
+/** Sets the TTL or hop limit statistics; \c toh is one of RTCP_XR_TOH_... */
+func (this *RTCPXRStatisticsSummaryBlock) SetTTL(toh uint8, min, max, mean, dev uint8) {
+	this.toh = toh & 0x03
+	this.minttl, this.maxttl, this.meanttl, this.devttl = min, max, mean, dev
+}
+
+func (this *RTCPXRStatisticsSummaryBlock) Encode() []byte {
+	var flags uint8
+	if this.hasloss {
+		flags |= 0x80
+	}
+	if this.hasdup {
+		flags |= 0x40
+	}
+	if this.hasjitter {
+		flags |= 0x20
+	}
+	flags |= (this.toh & 0x03) << 3
+
+	b := newRTCPXRBlockHeader(RTCP_XR_STATSUMMARY, flags, 36)
+	binary.BigEndian.PutUint32(b[4:], this.ssrc)
+	binary.BigEndian.PutUint16(b[8:], this.beginseq)
+	binary.BigEndian.PutUint16(b[10:], this.endseq)
+	binary.BigEndian.PutUint32(b[12:], this.lostpackets)
+	binary.BigEndian.PutUint32(b[16:], this.duppackets)
+	binary.BigEndian.PutUint32(b[20:], this.minjitter)
+	binary.BigEndian.PutUint32(b[24:], this.maxjitter)
+	binary.BigEndian.PutUint32(b[28:], this.meanjitter)
+	binary.BigEndian.PutUint32(b[32:], this.devjitter)
+	b[36] = this.minttl
+	b[37] = this.maxttl
+	b[38] = this.meanttl
+	b[39] = this.devttl
+	return b
+}
+
+/** Describes a VoIP Metrics report block (RFC 3611 section 4.7).
+ *  Rates and densities are fractions scaled by 256, durations and delays are
+ *  in milliseconds, levels in dB(m) and the MOS values are multiplied by ten.
+ */
+type RTCPXRVoIPMetricsBlock struct {
+	ssrc          uint32
+	lossrate      uint8
+	discardrate   uint8
+	burstdensity  uint8
+	gapdensity    uint8
+	burstduration uint16
+	gapduration   uint16
+	roundtrip     uint16
+	endsystem     uint16
+	signallevel   uint8
+	noiselevel    uint8
+	rerl          uint8
+	gmin          uint8
+	rfactor       uint8
+	extrfactor    uint8
+	moslq         uint8
+	moscq         uint8
+	rxconfig      uint8
+	jbnominal     uint16
+	jbmaximum     uint16
+	jbabsmax      uint16
+}
+
+/** Creates a VoIP Metrics block for source \c ssrc with all quality metrics unavailable. */
+func NewRTCPXRVoIPMetricsBlock(ssrc uint32) *RTCPXRVoIPMetricsBlock {
+	return &RTCPXRVoIPMetricsBlock{
+		ssrc:        ssrc,
+		signallevel: RTCP_XR_UNAVAILABLE,
+		noiselevel:  RTCP_XR_UNAVAILABLE,
+		rerl:        RTCP_XR_UNAVAILABLE,
+		rfactor:     RTCP_XR_UNAVAILABLE,
+		extrfactor:  RTCP_XR_UNAVAILABLE,
+		moslq:       RTCP_XR_UNAVAILABLE,
+		moscq:       RTCP_XR_UNAVAILABLE,
+	}
+}
+
+func (this *RTCPXRVoIPMetricsBlock) GetBlockType() uint8 {
+	return RTCP_XR_VOIPMETRICS
+}
+
+/** Returns the SSRC of the source this block reports on. */
+func (this *RTCPXRVoIPMetricsBlock) GetSSRC() uint32 {
+	return this.ssrc
+}
+
+/** Returns the fraction of packets lost, scaled by 256. */
+func (this *RTCPXRVoIPMetricsBlock) GetLossRate() uint8 {
+	return this.lossrate
+}
+
+/** Returns the fraction of packets discarded because of late or early arrival, scaled by 256. */
+func (this *RTCPXRVoIPMetricsBlock) GetDiscardRate() uint8 {
+	return this.discardrate
+}
+
+/** Returns the fraction of packets lost or discarded within bursts, scaled by 256. */
+func (this *RTCPXRVoIPMetricsBlock) GetBurstDensity() uint8 {
+	return this.burstdensity
+}
+
+/** Returns the fraction of packets lost or discarded within gaps, scaled by 256. */
+func (this *RTCPXRVoIPMetricsBlock) GetGapDensity() uint8 {
+	return this.gapdensity
+}
+
+/** Returns the mean duration of bursts in milliseconds. */
+func (this *RTCPXRVoIPMetricsBlock) GetBurstDuration() uint16 {
+	return this.burstduration
+}
+
+/** Returns the mean duration of gaps in milliseconds. */
+func (this *RTCPXRVoIPMetricsBlock) GetGapDuration() uint16 {
+	return this.gapduration
+}
+
+/** Returns the most recent round trip delay in milliseconds. */
+func (this *RTCPXRVoIPMetricsBlock) GetRoundTripDelay() uint16 {
+	return this.roundtrip
+}
+
+/** Returns the end system delay in milliseconds. */
+func (this *RTCPXRVoIPMetricsBlock) GetEndSystemDelay() uint16 {
+	return this.endsystem
+}
+
+/** Returns the signal level, noise level and residual echo return loss in dB(m);
+ *  RTCP_XR_UNAVAILABLE is returned for values that are not known.
+ */
+func (this *RTCPXRVoIPMetricsBlock) GetLevels() (signal, noise int8, rerl uint8) {
+	return int8(this.signallevel), int8(this.noiselevel), this.rerl
+}
+
+/** Sets the signal level, noise level and residual echo return loss. */
+func (this *RTCPXRVoIPMetricsBlock) SetLevels(signal, noise int8, rerl uint8) {
+	this.signallevel, this.noiselevel, this.rerl = uint8(signal), uint8(noise), rerl
+}
+
+/** Returns the gap threshold: the number of received packets that ends a burst. */
+func (this *RTCPXRVoIPMetricsBlock) GetGmin() uint8 {
+	return this.gmin
+}
+
+/** Returns the R factor (0..100) or RTCP_XR_UNAVAILABLE. */
+func (this *RTCPXRVoIPMetricsBlock) GetRFactor() uint8 {
+	return this.rfactor
+}
+
+/** Returns the external R factor (0..100) or RTCP_XR_UNAVAILABLE. */
+func (this *RTCPXRVoIPMetricsBlock) GetExtRFactor() uint8 {
+	return this.extrfactor
+}
+
+/** Returns the listening quality MOS times ten (10..50) or RTCP_XR_UNAVAILABLE. */
+func (this *RTCPXRVoIPMetricsBlock) GetMOSLQ() uint8 {
+	return this.moslq
+}
+
+/** Returns the conversational quality MOS times ten (10..50) or RTCP_XR_UNAVAILABLE. */
+func (this *RTCPXRVoIPMetricsBlock) GetMOSCQ() uint8 {
+	return this.moscq
+}
+
+/** Returns the receiver configuration byte (PLC, jitter buffer adaptive and rate). */
+func (this *RTCPXRVoIPMetricsBlock) GetRXConfig() uint8 {
+	return this.rxconfig
+}
+
+/** Returns the nominal, maximum and absolute maximum jitter buffer delay in milliseconds. */
+func (this *RTCPXRVoIPMetricsBlock) GetJitterBuffer() (nominal, maximum, absmax uint16) {
+	return this.jbnominal, this.jbmaximum, this.jbabsmax
+}
+
+/** Sets the receiver configuration byte and the jitter buffer delays in milliseconds. */
+func (this *RTCPXRVoIPMetricsBlock) SetJitterBuffer(rxconfig uint8, nominal, maximum, absmax uint16) {
+	this.rxconfig = rxconfig
+	this.jbnominal, this.jbmaximum, this.jbabsmax = nominal, maximum, absmax
+}
+
+func (this *RTCPXRVoIPMetricsBlock) Encode() []byte {
+	b := newRTCPXRBlockHeader(RTCP_XR_VOIPMETRICS, 0, 32)
+	binary.BigEndian.PutUint32(b[4:], this.ssrc)
+	b[8] = this.lossrate
+	b[9] = this.discardrate
+	b[10] = this.burstdensity
+	b[11] = this.gapdensity
+	binary.BigEndian.PutUint16(b[12:], this.burstduration)
+	binary.BigEndian.PutUint16(b[14:], this.gapduration)
+	binary.BigEndian.PutUint16(b[16:], this.roundtrip)
+	binary.BigEndian.PutUint16(b[18:], this.endsystem)
+	b[20] = this.signallevel
+	b[21] = this.noiselevel
+	b[22] = this.rerl
+	b[23] = this.gmin
+	b[24] = this.rfactor
+	b[25] = this.extrfactor
+	b[26] = this.moslq
+	b[27] = this.moscq
+	b[28] = this.rxconfig
+	binary.BigEndian.PutUint16(b[30:], this.jbnominal)
+	binary.BigEndian.PutUint16(b[32:], this.jbmaximum)
+	binary.BigEndian.PutUint16(b[34:], this.jbabsmax)
+	return b
+}
+
+/** Interprets a single report block, \c b includes the block header. */
+func parseRTCPXRBlock(b []byte) RTCPXRBlock {
+	blocktype := b[0]
+	content := b[SIZEOF_RTCPXRBLOCKHEADER:]
+
+	switch {
+	case blocktype == RTCP_XR_LOSSRLE && len(content) >= 8:
+		this := &RTCPXRLossRLEBlock{
+			thinning: b[1] & 0x0F,
+			ssrc:     binary.BigEndian.Uint32(content[0:]),
+			beginseq: binary.BigEndian.Uint16(content[4:]),
+			endseq:   binary.BigEndian.Uint16(content[6:]),
+		}
+		for i := 8; i+2 <= len(content); i += 2 {
+			this.chunks = append(this.chunks, binary.BigEndian.Uint16(content[i:]))
+		}
+		return this
+	case blocktype == RTCP_XR_STATSUMMARY && len(content) >= 36:
+		this := &RTCPXRStatisticsSummaryBlock{
+			ssrc:        binary.BigEndian.Uint32(content[0:]),
+			beginseq:    binary.BigEndian.Uint16(content[4:]),
+			endseq:      binary.BigEndian.Uint16(content[6:]),
+			hasloss:     b[1]&0x80 != 0,
+			hasdup:      b[1]&0x40 != 0,
+			hasjitter:   b[1]&0x20 != 0,
+			toh:         (b[1] >> 3) & 0x03,
+			lostpackets: binary.BigEndian.Uint32(content[8:]),
+			duppackets:  binary.BigEndian.Uint32(content[12:]),
+			minjitter:   binary.BigEndian.Uint32(content[16:]),
+			maxjitter:   binary.BigEndian.Uint32(content[20:]),
+			meanjitter:  binary.BigEndian.Uint32(content[24:]),
+			devjitter:   binary.BigEndian.Uint32(content[28:]),
+			minttl:      content[32],
+			maxttl:      content[33],
+			meanttl:     content[34],
+			devttl:      content[35],
+		}
+		return this
+	case blocktype == RTCP_XR_VOIPMETRICS && len(content) >= 32:
+		this := &RTCPXRVoIPMetricsBlock{
+			ssrc:          binary.BigEndian.Uint32(content[0:]),
+			lossrate:      content[4],
+			discardrate:   content[5],
+			burstdensity:  content[6],
+			gapdensity:    content[7],
+			burstduration: binary.BigEndian.Uint16(content[8:]),
+			gapduration:   binary.BigEndian.Uint16(content[10:]),
+			roundtrip:     binary.BigEndian.Uint16(content[12:]),
+			endsystem:     binary.BigEndian.Uint16(content[14:]),
+			signallevel:   content[16],
+			noiselevel:    content[17],
+			rerl:          content[18],
+			gmin:          content[19],
+			rfactor:       content[20],
+			extrfactor:    content[21],
+			moslq:         content[22],
+			moscq:         content[23],
+			rxconfig:      content[24],
+			jbnominal:     binary.BigEndian.Uint16(content[26:]),
+			jbmaximum:     binary.BigEndian.Uint16(content[28:]),
+			jbabsmax:      binary.BigEndian.Uint16(content[30:]),
+		}
+		return this
+	}
+	return &RTCPXRRawBlock{blocktype: blocktype, typespecific: b[1], data: content}
+}
+
+/** Describes an RTCP XR packet (RFC 3611). */
+type RTCPXRPacket struct {
+	RTCPPacket
+	blocks []RTCPXRBlock
+}
+
+/** Creates an instance based on the data in \c data with length \c datalen.
+ *  The report blocks are interpreted right away; blocks of an unknown type
+ *  are returned as RTCPXRRawBlock.
+ */
+func NewRTCPXRPacket(data []byte, datalen int) *RTCPXRPacket {
+	this := &RTCPXRPacket{}
+	this.data = make([]byte, datalen)
+	this.datalen = datalen
+	copy(this.data[:], data[0:datalen])
+	this.packettype = XR
+	this.knownformat = false
+
+	xrlen := datalen
+	if ((this.data[0] >> RTCP_HEADER_P_POS) & RTCP_HEADER_P_MSK) != 0 {
+		padcount := this.data[datalen-1]
+		if (padcount & 0x03) != 0 { // not a multiple of four! (see rfc 3550 p 37)
+			return this
+		}
+		if int(padcount) >= xrlen {
+			return this
+		}
+		xrlen -= int(padcount)
+	}
+	if xrlen < SIZEOF_RTCPHEADER+4 {
+		return this
+	}
+
+	for offset := SIZEOF_RTCPHEADER + 4; offset < xrlen; {
+		if offset+SIZEOF_RTCPXRBLOCKHEADER > xrlen {
+			return this
+		}
+		blocklen := SIZEOF_RTCPXRBLOCKHEADER + 4*int(binary.BigEndian.Uint16(this.data[offset+2:]))
+		if offset+blocklen > xrlen {
+			return this
+		}
+		this.blocks = append(this.blocks, parseRTCPXRBlock(this.data[offset:offset+blocklen]))
+		offset += blocklen
+	}
+	this.knownformat = true
+
+	return this
+}
+
+/** Builds an XR packet sent by \c ssrc carrying the given report blocks. */
+func NewRTCPXRPacketFromBlocks(ssrc uint32, blocks ...RTCPXRBlock) *RTCPXRPacket {
+	data := make([]byte, SIZEOF_RTCPHEADER+4)
+	for _, block := range blocks {
+		data = append(data, block.Encode()...)
+	}
+	data[0] = 2 << RTCP_HEADER_V_POS
+	data[1] = RTP_RTCPTYPE_XR
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)/4-1))
+	binary.BigEndian.PutUint32(data[4:], ssrc)
+	return NewRTCPXRPacket(data, len(data))
+}
+
+/** Returns the SSRC of the source which sent this packet. */
+func (this *RTCPXRPacket) GetSSRC() uint32 {
+	if !this.knownformat {
+		return 0
+	}
+	return binary.BigEndian.Uint32(this.data[SIZEOF_RTCPHEADER:])
+}
+
+/** Returns the report blocks contained in this packet. */
+func (this *RTCPXRPacket) GetBlocks() []RTCPXRBlock {
+	return this.blocks
+}
+
+func (this *RTCPXRPacket) Dump() {
+	this.RTCPPacket.Dump()
+	if !this.IsKnownFormat() {
+		fmt.Printf("    Unknown format!")
+		return
+	}
+	fmt.Printf("    SSRC:   %d\n", this.GetSSRC())
+	for _, block := range this.blocks {
+		switch b := block.(type) {
+		case *RTCPXRLossRLEBlock:
+			fmt.Printf("    Loss RLE: SSRC %d seq %d-%d\n", b.GetSSRC(), b.GetBeginSeq(), b.GetEndSeq())
+		case *RTCPXRStatisticsSummaryBlock:
+			fmt.Printf("    Statistics Summary: SSRC %d lost %d dup %d\n", b.GetSSRC(), b.GetLostPackets(), b.GetDuplicatePackets())
+		case *RTCPXRVoIPMetricsBlock:
+			fmt.Printf("    VoIP Metrics: SSRC %d loss %d/256 R %d MOS-LQ %d MOS-CQ %d\n",
+				b.GetSSRC(), b.GetLossRate(), b.GetRFactor(), b.GetMOSLQ(), b.GetMOSCQ())
+		default:
+			fmt.Printf("    Block type %d\n", block.GetBlockType())
+		}
+	}
+}
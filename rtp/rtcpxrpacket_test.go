@@ -0,0 +1,167 @@
+package rtp
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestRTCPXRLossRLE(t *testing.T) {
+	var received []bool
+	for i := 0; i < 40; i++ {
+		received = append(received, true) // run-length chunk
+	}
+	received = append(received, false, true, false, false, true) // bit vector chunk
+	for i := 0; i < 20; i++ {
+		received = append(received, false)
+	}
+
+	block := NewRTCPXRLossRLEBlock(0x11223344, 65530, received)
+	packet := NewRTCPXRPacketFromBlocks(0xCAFEBABE, block)
+	if !packet.IsKnownFormat() || packet.GetSSRC() != 0xCAFEBABE || len(packet.GetBlocks()) != 1 {
+		t.Fatalf("bad packet %X", packet.GetPacketData())
+	}
+	parsed, ok := packet.GetBlocks()[0].(*RTCPXRLossRLEBlock)
+	if !ok {
+		t.Fatalf("got %T", packet.GetBlocks()[0])
+	}
+	if parsed.GetSSRC() != 0x11223344 || parsed.GetBeginSeq() != 65530 || parsed.GetEndSeq() != uint16(65530+len(received)) {
+		t.Errorf("bad header %d %d %d", parsed.GetSSRC(), parsed.GetBeginSeq(), parsed.GetEndSeq())
+	}
+	got := parsed.GetReceived()
+	if len(got) != len(received) {
+		t.Fatalf("got %d entries, want %d", len(got), len(received))
+	}
+	for i := range got {
+		if got[i] != received[i] {
+			t.Errorf("entry %d: got %v", i, got[i])
+		}
+	}
+}
+
+// A VoIP Metrics block laid out field by field as in RFC 3611 section 4.7.
+func TestRTCPXRVoIPMetricsEncoding(t *testing.T) {
+	data := []byte{
+		0x80, 207, 0x00, 0x0A, 0x01, 0x02, 0x03, 0x04,
+		0x07, 0x00, 0x00, 0x08, 0xAA, 0xBB, 0xCC, 0xDD,
+		0x10, 0x02, 0x80, 0x05, 0x00, 0x3C, 0x01, 0xF4,
+		0x00, 0x64, 0x00, 0x28, 0xEC, 0xB5, 0x7F, 0x10,
+		0x5A, 0x7F, 0x29, 0x27, 0x25, 0x00, 0x00, 0x28,
+		0x00, 0x50, 0x00, 0xA0,
+	}
+	packet := NewRTCPXRPacket(data, len(data))
+	if !packet.IsKnownFormat() {
+		t.Fatal("unknown format")
+	}
+	block, ok := packet.GetBlocks()[0].(*RTCPXRVoIPMetricsBlock)
+	if !ok {
+		t.Fatalf("got %T", packet.GetBlocks()[0])
+	}
+	signal, noise, rerl := block.GetLevels()
+	nominal, maximum, absmax := block.GetJitterBuffer()
+	if block.GetSSRC() != 0xAABBCCDD || block.GetLossRate() != 0x10 || block.GetDiscardRate() != 2 ||
+		block.GetBurstDensity() != 0x80 || block.GetGapDensity() != 5 || block.GetBurstDuration() != 60 ||
+		block.GetGapDuration() != 500 || block.GetRoundTripDelay() != 100 || block.GetEndSystemDelay() != 40 ||
+		signal != -20 || noise != -75 || rerl != RTCP_XR_UNAVAILABLE || block.GetGmin() != 16 ||
+		block.GetRFactor() != 90 || block.GetExtRFactor() != RTCP_XR_UNAVAILABLE ||
+		block.GetMOSLQ() != 41 || block.GetMOSCQ() != 39 || block.GetRXConfig() != 0x25 ||
+		nominal != 40 || maximum != 80 || absmax != 160 {
+		t.Errorf("bad block %+v", block)
+	}
+
+	if again := NewRTCPXRPacketFromBlocks(0x01020304, block); !bytes.Equal(again.GetPacketData(), data) {
+		t.Errorf("got %X, want %X", again.GetPacketData(), data)
+	}
+}
+
+func TestRTCPXRStatisticsSummary(t *testing.T) {
+	block := NewRTCPXRStatisticsSummaryBlock(7, 100, 200)
+	block.SetLostPackets(3)
+	block.SetJitter(1, 9, 4, 2)
+	block.SetTTL(RTCP_XR_TOH_IPV4TTL, 60, 64, 62, 1)
+	unknown := NewRTCPXRRawBlock(42, 0x55, []byte{1, 2, 3, 4})
+
+	packet := NewRTCPXRPacketFromBlocks(1, block, unknown)
+	blocks := packet.GetBlocks()
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks", len(blocks))
+	}
+	parsed := blocks[0].(*RTCPXRStatisticsSummaryBlock)
+	min, max, mean, dev := parsed.GetJitter()
+	tmin, tmax, _, _ := parsed.GetTTL()
+	if !parsed.HasLostPackets() || parsed.GetLostPackets() != 3 || parsed.HasDuplicatePackets() ||
+		!parsed.HasJitter() || min != 1 || max != 9 || mean != 4 || dev != 2 ||
+		parsed.GetTTLType() != RTCP_XR_TOH_IPV4TTL || tmin != 60 || tmax != 64 {
+		t.Errorf("bad block %+v", parsed)
+	}
+	raw := blocks[1].(*RTCPXRRawBlock)
+	if raw.GetBlockType() != 42 || raw.GetTypeSpecific() != 0x55 || !bytes.Equal(raw.GetData(), []byte{1, 2, 3, 4}) {
+		t.Errorf("bad raw block %+v", raw)
+	}
+
+	// a block length running past the end of the packet
+	data := packet.GetPacketData()
+	data[SIZEOF_RTCPHEADER+4+3]++
+	if NewRTCPXRPacket(data, len(data)).IsKnownFormat() {
+		t.Error("truncated block accepted")
+	}
+}
+
+func TestVoIPMetrics(t *testing.T) {
+	metrics := NewVoIPMetrics(1234, 8000)
+	arrival := &RTPTime{sec: 1000}
+	seq := uint16(65500)
+	ts := uint32(0)
+	for i := 0; i < 1000; i++ {
+		// an isolated loss every 100 packets and one burst of four lost packets
+		lost := i%100 == 50 || (i >= 500 && i < 504)
+		if !lost {
+			metrics.process(seq, ts, arrival.Clone(), i == 700)
+		}
+		seq++
+		ts += 160
+		arrival.Add(NewRTPTimeFromFloat64(0.020))
+	}
+	metrics.process(seq-3, ts-3*160, nil, false) // duplicate
+
+	if metrics.GetExpectedPackets() != 1000 || metrics.GetLostPackets() != 14 ||
+		metrics.GetDiscardedPackets() != 1 || metrics.GetDuplicatePackets() != 1 {
+		t.Errorf("expected %d lost %d discarded %d duplicates %d", metrics.GetExpectedPackets(),
+			metrics.GetLostPackets(), metrics.GetDiscardedPackets(), metrics.GetDuplicatePackets())
+	}
+	if math.Abs(metrics.GetLossRate()-0.014) > 1e-9 {
+		t.Errorf("loss rate %f", metrics.GetLossRate())
+	}
+	// RFC 3611 appendix A counts the first loss as the end of a burst too:
+	// the four packets of the burst and the first isolated loss span two bursts
+	if metrics.GetBurstDuration() != 50 || metrics.GetBurstDensity() != 1 {
+		t.Errorf("burst %d ms density %f", metrics.GetBurstDuration(), metrics.GetBurstDensity())
+	}
+	if d := metrics.GetGapDensity(); d < 0.009 || d > 0.012 {
+		t.Errorf("gap density %f", d)
+	}
+	if metrics.GetJitter() > 1 {
+		t.Errorf("jitter %f", metrics.GetJitter())
+	}
+
+	lq := metrics.GetMOSLQ()
+	metrics.SetRoundTripDelay(600)
+	cq := metrics.GetMOSCQ()
+	if lq < 3.8 || lq > 4.4 || cq >= lq {
+		t.Errorf("MOS-LQ %f MOS-CQ %f", lq, cq)
+	}
+
+	block := metrics.GetVoIPMetricsBlock()
+	if block.GetLossRate() != 3 || block.GetRoundTripDelay() != 600 || block.GetGmin() != 16 ||
+		block.GetMOSLQ() != uint8(lq*10+0.5) || block.GetRFactor() > 93 {
+		t.Errorf("bad block %+v", block)
+	}
+	if RFactorToMOS(93.2) < 4.4 || RFactorToMOS(50) > 2.6 || RFactorToMOS(0) != 1 {
+		t.Error("bad R to MOS conversion")
+	}
+
+	rtt := ComputeRoundTripDelay(NewNTPTime(0x12345678, 0x80000000), 0x56780000, 0x00004000)
+	if rtt != 250 {
+		t.Errorf("round trip %d ms", rtt)
+	}
+}
@@ -20,19 +20,26 @@ const RTP_RTCPTYPE_RR = 201
 const RTP_RTCPTYPE_SDES = 202
 const RTP_RTCPTYPE_BYE = 203
 const RTP_RTCPTYPE_APP = 204
+const RTP_RTCPTYPE_XR = 207
+
+/** The range of the second packet byte reserved for RTCP when RTP and RTCP
+ *  share a port (RFC 5761 section 4).
+ */
+const RTP_RTCPTYPE_MUXMIN = 192
+const RTP_RTCPTYPE_MUXMAX = 223
 
 const RTP_HEADER_V_MSK = 0x3
-const RTP_HEADER_V_POS = 0
+const RTP_HEADER_V_POS = 6
 const RTP_HEADER_P_MSK = 0x1
-const RTP_HEADER_P_POS = 2
+const RTP_HEADER_P_POS = 5
 const RTP_HEADER_X_MSK = 0x1
-const RTP_HEADER_X_POS = 3
+const RTP_HEADER_X_POS = 4
 const RTP_HEADER_CC_MSK = 0xF
-const RTP_HEADER_CC_POS = 4
+const RTP_HEADER_CC_POS = 0
 const RTP_HEADER_M_MSK = 0x1
-const RTP_HEADER_M_POS = 8
+const RTP_HEADER_M_POS = 7
 const RTP_HEADER_PT_MSK = 0x7F
-const RTP_HEADER_PT_POS = 9
+const RTP_HEADER_PT_POS = 0
 
 const RTCP_HEADER_C_MSK = 0x1F
 const RTCP_HEADER_C_POS = 0
@@ -130,9 +137,9 @@ func (this *RTPHeader) Encode() []byte {
 
 	for i := uint8(0); i < this.csrccount; i++ {
 		packetbytes[SIZEOF_RTPHEADER+i*4+0] = byte((this.csrc[i] >> 24) & 0xFF)
-		packetbytes[SIZEOF_RTPHEADER+i*4+0] = byte((this.csrc[i] >> 16) & 0xFF)
-		packetbytes[SIZEOF_RTPHEADER+i*4+0] = byte((this.csrc[i] >> 8) & 0xFF)
-		packetbytes[SIZEOF_RTPHEADER+i*4+0] = byte((this.csrc[i] >> 0) & 0xFF)
+		packetbytes[SIZEOF_RTPHEADER+i*4+1] = byte((this.csrc[i] >> 16) & 0xFF)
+		packetbytes[SIZEOF_RTPHEADER+i*4+2] = byte((this.csrc[i] >> 8) & 0xFF)
+		packetbytes[SIZEOF_RTPHEADER+i*4+3] = byte((this.csrc[i] >> 0) & 0xFF)
 	}
 
 	return packetbytes
@@ -170,6 +177,7 @@ func (this *RTPExtension) Parse(packetbytes []byte) error {
 		return errors.New("ERR_RTP_PACKET_INVALIDPACKET")
 	}
 
+	this.data = make([]uint32, this.length)
 	for i := uint16(0); i < this.length; i++ {
 		this.data[i] = uint32(packetbytes[SIZEOF_RTPEXTENSION+i*4+0])<<24 |
 			uint32(packetbytes[SIZEOF_RTPEXTENSION+i*4+1])<<16 |
@@ -0,0 +1,301 @@
+package rtp
+
+import (
+	"errors"
+	"sort"
+)
+
+/** The "defined by profile" values of RFC 8285 header extensions. The low four
+ *  bits of the two-byte value are application bits.
+ */
+const RTP_EXTENSION_ONEBYTE = 0xBEDE
+const RTP_EXTENSION_TWOBYTE = 0x1000
+const RTP_EXTENSION_TWOBYTE_MSK = 0xFFF0
+
+/** Limits of the one-byte form: IDs 1-14 carrying 1-16 bytes. */
+const RTP_EXTENSION_ONEBYTE_MAXID = 14
+const RTP_EXTENSION_ONEBYTE_MAXLEN = 16
+
+/** URIs of the header extensions with helpers in this package. */
+const RTP_EXTENSION_AUDIOLEVEL_URI = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+const RTP_EXTENSION_ABSSENDTIME_URI = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+const RTP_EXTENSION_TRANSPORTCC_URI = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+
+/** The elements of an RTP header extension in the one-byte or two-byte
+ *  format of RFC 8285, keyed by their local identifier.
+ */
+type RTPHeaderExtensions struct {
+	twobyte  bool
+	appbits  uint8
+	elements map[uint8][]byte
+}
+
+/** Creates an empty set of header extension elements. The one-byte format is
+ *  used when encoding unless an element requires the two-byte format.
+ */
+func NewRTPHeaderExtensions() *RTPHeaderExtensions {
+	return &RTPHeaderExtensions{elements: make(map[uint8][]byte)}
+}
+
+/** Returns \c true if \c profile is the "defined by profile" value of one of
+ *  the RFC 8285 formats.
+ */
+func IsRTPHeaderExtensionProfile(profile uint16) bool {
+	return profile == RTP_EXTENSION_ONEBYTE || profile&RTP_EXTENSION_TWOBYTE_MSK == RTP_EXTENSION_TWOBYTE
+}
+
+/** Parses the header extension \c data which carried the "defined by profile"
+ *  value \c profile.
+ */
+func ParseRTPHeaderExtensions(profile uint16, data []byte) (*RTPHeaderExtensions, error) {
+	this := NewRTPHeaderExtensions()
+	switch {
+	case profile == RTP_EXTENSION_ONEBYTE:
+		for i := 0; i < len(data); {
+			if data[i] == 0 { // padding
+				i++
+				continue
+			}
+			id := data[i] >> 4
+			if id == 15 { // reserved, stop processing
+				break
+			}
+			length := int(data[i]&0x0F) + 1
+			if i+1+length > len(data) {
+				return nil, errors.New("ERR_RTP_HEADEREXTENSION_INVALID")
+			}
+			this.elements[id] = data[i+1 : i+1+length]
+			i += 1 + length
+		}
+	case profile&RTP_EXTENSION_TWOBYTE_MSK == RTP_EXTENSION_TWOBYTE:
+		this.twobyte = true
+		this.appbits = uint8(profile & 0x0F)
+		for i := 0; i < len(data); {
+			if data[i] == 0 { // padding
+				i++
+				continue
+			}
+			if i+2 > len(data) {
+				return nil, errors.New("ERR_RTP_HEADEREXTENSION_INVALID")
+			}
+			id := data[i]
+			length := int(data[i+1])
+			if i+2+length > len(data) {
+				return nil, errors.New("ERR_RTP_HEADEREXTENSION_INVALID")
+			}
+			this.elements[id] = data[i+2 : i+2+length]
+			i += 2 + length
+		}
+	default:
+		return nil, errors.New("ERR_RTP_HEADEREXTENSION_UNKNOWNPROFILE")
+	}
+	return this, nil
+}
+
+/** Returns the data of the element with identifier \c id, or nil if there is none. */
+func (this *RTPHeaderExtensions) Get(id uint8) []byte {
+	return this.elements[id]
+}
+
+/** Returns \c true if an element with identifier \c id is present. */
+func (this *RTPHeaderExtensions) Has(id uint8) bool {
+	_, ok := this.elements[id]
+	return ok
+}
+
+/** Sets the element with identifier \c id (1-255) to \c data. Elements that do
+ *  not fit the one-byte format switch the encoding to the two-byte format.
+ */
+func (this *RTPHeaderExtensions) Set(id uint8, data []byte) error {
+	if id == 0 {
+		return errors.New("ERR_RTP_HEADEREXTENSION_BADID")
+	}
+	if len(data) > 255 {
+		return errors.New("ERR_RTP_HEADEREXTENSION_TOOLONG")
+	}
+	this.elements[id] = data
+	return nil
+}
+
+/** Removes the element with identifier \c id. */
+func (this *RTPHeaderExtensions) Remove(id uint8) {
+	delete(this.elements, id)
+}
+
+/** Returns the identifiers of all elements in ascending order. */
+func (this *RTPHeaderExtensions) GetIDs() []uint8 {
+	ids := make([]uint8, 0, len(this.elements))
+	for id := range this.elements {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+/** Forces the two-byte format, e.g. when "a=extmap-allow-mixed" was negotiated. */
+func (this *RTPHeaderExtensions) SetTwoByte(twobyte bool) {
+	this.twobyte = twobyte
+}
+
+/** Returns \c true if the elements are encoded in the two-byte format. */
+func (this *RTPHeaderExtensions) IsTwoByte() bool {
+	if this.twobyte {
+		return true
+	}
+	for id, data := range this.elements {
+		if id > RTP_EXTENSION_ONEBYTE_MAXID || len(data) == 0 || len(data) > RTP_EXTENSION_ONEBYTE_MAXLEN {
+			return true
+		}
+	}
+	return false
+}
+
+/** Sets the four application bits of the two-byte format. */
+func (this *RTPHeaderExtensions) SetAppBits(appbits uint8) {
+	this.appbits = appbits & 0x0F
+}
+
+/** Returns the four application bits of the two-byte format. */
+func (this *RTPHeaderExtensions) GetAppBits() uint8 {
+	return this.appbits
+}
+
+/** Encodes the elements as the "defined by profile" value, the length in 32-bit
+ *  words and the data of an RFC 3550 header extension, as taken by NewPacket.
+ */
+func (this *RTPHeaderExtensions) Encode() (profile uint16, length uint16, data []uint32) {
+	var b []byte
+	twobyte := this.IsTwoByte()
+	for _, id := range this.GetIDs() {
+		element := this.elements[id]
+		if twobyte {
+			b = append(b, id, byte(len(element)))
+		} else {
+			b = append(b, id<<4|byte(len(element)-1))
+		}
+		b = append(b, element...)
+	}
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+
+	profile = RTP_EXTENSION_ONEBYTE
+	if twobyte {
+		profile = RTP_EXTENSION_TWOBYTE | uint16(this.appbits)
+	}
+	data = make([]uint32, len(b)/4)
+	for i := range data {
+		data[i] = bytesToUint32(b[4*i:])
+	}
+	return profile, uint16(len(data)), data
+}
+
+/** Parses the RFC 8285 header extension elements of this packet. A packet
+ *  without a header extension yields an empty set.
+ */
+func (this *RTPPacket) GetHeaderExtensions() (*RTPHeaderExtensions, error) {
+	if this.extension == nil {
+		return NewRTPHeaderExtensions(), nil
+	}
+	data := make([]byte, 0, 4*len(this.extension.data))
+	for _, word := range this.extension.data {
+		data = append(data, uint32ToBytes(word)...)
+	}
+	return ParseRTPHeaderExtensions(this.extension.id, data)
+}
+
+/** Returns the data of header extension element \c id, or nil if the packet
+ *  does not carry it.
+ */
+func (this *RTPPacket) GetHeaderExtension(id uint8) []byte {
+	extensions, err := this.GetHeaderExtensions()
+	if err != nil {
+		return nil
+	}
+	return extensions.Get(id)
+}
+
+/** Rebuilds the packet with the header extension elements \c extensions,
+ *  replacing any header extension present. An empty set removes it.
+ */
+func (this *RTPPacket) SetHeaderExtensions(extensions *RTPHeaderExtensions) error {
+	var profile, length uint16
+	var data []uint32
+	gotextension := len(extensions.elements) != 0
+	if gotextension {
+		profile, length, data = extensions.Encode()
+	}
+	return this.BuildPacket(this.header.payloadtype,
+		this.payload,
+		this.header.sequencenumber,
+		this.header.timestamp,
+		this.header.ssrc,
+		this.header.marker != 0,
+		this.header.csrccount,
+		this.header.csrc,
+		gotextension,
+		profile,
+		length,
+		data)
+}
+
+/** Sets header extension element \c id of this packet, keeping the other elements. */
+func (this *RTPPacket) SetHeaderExtension(id uint8, data []byte) error {
+	extensions, err := this.GetHeaderExtensions()
+	if err != nil {
+		return err
+	}
+	if err = extensions.Set(id, data); err != nil {
+		return err
+	}
+	return this.SetHeaderExtensions(extensions)
+}
+
+/** Encodes the client-to-mixer audio level of RFC 6464: the voice activity
+ *  flag and the level in -dBov (0-127).
+ */
+func EncodeAudioLevel(voice bool, level uint8) []byte {
+	b := level & 0x7F
+	if voice {
+		b |= 0x80
+	}
+	return []byte{b}
+}
+
+/** Decodes the audio level element of RFC 6464. */
+func DecodeAudioLevel(data []byte) (voice bool, level uint8, err error) {
+	if len(data) < 1 {
+		return false, 0, errors.New("ERR_RTP_HEADEREXTENSION_INVALID")
+	}
+	return data[0]&0x80 != 0, data[0] & 0x7F, nil
+}
+
+/** Encodes the abs-send-time element: the 6.18 fixed point seconds taken from
+ *  the NTP timestamp \c t.
+ */
+func EncodeAbsSendTime(t *NTPTime) []byte {
+	v := (t.GetMSW()&0x3F)<<18 | t.GetLSW()>>14
+	return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+/** Decodes the abs-send-time element into seconds modulo 64. */
+func DecodeAbsSendTime(data []byte) (float64, error) {
+	if len(data) < 3 {
+		return 0, errors.New("ERR_RTP_HEADEREXTENSION_INVALID")
+	}
+	v := uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+	return float64(v) / float64(1<<18), nil
+}
+
+/** Encodes the transport-wide sequence number element. */
+func EncodeTransportSequenceNumber(seqnr uint16) []byte {
+	return []byte{byte(seqnr >> 8), byte(seqnr)}
+}
+
+/** Decodes the transport-wide sequence number element. */
+func DecodeTransportSequenceNumber(data []byte) (uint16, error) {
+	if len(data) < 2 {
+		return 0, errors.New("ERR_RTP_HEADEREXTENSION_INVALID")
+	}
+	return uint16(data[0])<<8 | uint16(data[1]), nil
+}
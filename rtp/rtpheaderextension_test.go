@@ -0,0 +1,82 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRTPHeaderExtensionsOneByte(t *testing.T) {
+	// RFC 8285 section 4.2 example: elements with IDs 1 and 2, padding in between
+	data := []byte{0x10, 0xFF, 0x00, 0x00, 0x21, 0xAA, 0xBB, 0x00}
+	extensions, err := ParseRTPHeaderExtensions(RTP_EXTENSION_ONEBYTE, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(extensions.Get(1), []byte{0xFF}) || !bytes.Equal(extensions.Get(2), []byte{0xAA, 0xBB}) ||
+		extensions.IsTwoByte() {
+		t.Errorf("bad elements %v", extensions.elements)
+	}
+
+	packet := NewPacket(96, []byte{1, 2, 3}, 7, 160, 0x1234, true, 0, nil, false, 0, 0, nil)
+	if err = packet.SetHeaderExtension(1, EncodeAudioLevel(true, 30)); err != nil {
+		t.Fatal(err)
+	}
+	if err = packet.SetHeaderExtension(3, EncodeTransportSequenceNumber(0xABCD)); err != nil {
+		t.Fatal(err)
+	}
+	if err = packet.SetHeaderExtension(2, EncodeAbsSendTime(NewNTPTime(0x83AA7E80, 0x80000000))); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := NewRTPPacketFromRawPacket(NewRawPacket(packet.GetPacket(), nil, CurrentRTPTime(), true))
+	if parsed == nil || !parsed.HasExtension() || parsed.GetExtensionID() != RTP_EXTENSION_ONEBYTE ||
+		!bytes.Equal(parsed.GetPayload(), []byte{1, 2, 3}) || !parsed.HasMarker() || parsed.GetSequenceNumber() != 7 {
+		t.Fatalf("bad packet %X", packet.GetPacket())
+	}
+	voice, level, err := DecodeAudioLevel(parsed.GetHeaderExtension(1))
+	if err != nil || !voice || level != 30 {
+		t.Errorf("audio level %v %d %v", voice, level, err)
+	}
+	seqnr, err := DecodeTransportSequenceNumber(parsed.GetHeaderExtension(3))
+	if err != nil || seqnr != 0xABCD {
+		t.Errorf("transport sequence number %X %v", seqnr, err)
+	}
+	abstime, err := DecodeAbsSendTime(parsed.GetHeaderExtension(2))
+	if err != nil || abstime != float64(0x83AA7E80&0x3F)+0.5 {
+		t.Errorf("abs-send-time %f %v", abstime, err)
+	}
+}
+
+func TestRTPHeaderExtensionsTwoByte(t *testing.T) {
+	extensions := NewRTPHeaderExtensions()
+	extensions.Set(1, []byte{})
+	extensions.Set(20, bytes.Repeat([]byte{0x55}, 17))
+	extensions.SetAppBits(3)
+
+	profile, length, data := extensions.Encode()
+	if profile != RTP_EXTENSION_TWOBYTE|3 || length != 6 || len(data) != 6 {
+		t.Fatalf("profile %X length %d", profile, length)
+	}
+	packet := NewPacket(0, nil, 1, 0, 1, false, 0, nil, true, profile, length, data)
+	parsed, err := packet.GetHeaderExtensions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.IsTwoByte() || parsed.GetAppBits() != 3 || !parsed.Has(1) || len(parsed.Get(1)) != 0 ||
+		len(parsed.Get(20)) != 17 {
+		t.Errorf("bad elements %v", parsed.elements)
+	}
+
+	parsed.Remove(20)
+	parsed.Remove(1)
+	if err = packet.SetHeaderExtensions(parsed); err != nil || packet.HasExtension() {
+		t.Errorf("extension not removed: %v", err)
+	}
+
+	if _, err = ParseRTPHeaderExtensions(RTP_EXTENSION_ONEBYTE, []byte{0x13, 0x00}); err == nil {
+		t.Error("truncated element accepted")
+	}
+	if _, err = ParseRTPHeaderExtensions(0x1234, nil); err == nil {
+		t.Error("unknown profile accepted")
+	}
+}
@@ -47,9 +47,14 @@ func (this *RTPPacket) ParseRawPacket(rawpack *RawPacket) error {
 	}
 
 	// We'll check if this is possibly a RTCP packet. For this to be possible
-	// the marker bit and payload type combined should be either an SR or RR
-	// identifier
-	if this.header.marker != 0 {
+	// the marker bit and payload type combined should be in the range of the
+	// RTCP packet types (see RFC 5761 section 4) on a multiplexed port, and
+	// either an SR or RR identifier otherwise
+	if rawpack.muxed {
+		if IsRTCPMuxed(this.packet) {
+			return errors.New("ERR_RTP_PACKET_INVALIDPACKET")
+		}
+	} else if this.header.marker != 0 {
 		if this.header.payloadtype == (RTP_RTCPTYPE_SR & 127) { // don't check high bit (this was the marker!!)
 			return errors.New("ERR_RTP_PACKET_INVALIDPACKET")
 		}
@@ -84,6 +89,7 @@ func (this *RTPPacket) ParseRawPacket(rawpack *RawPacket) error {
 	if payloadlength < 0 {
 		return errors.New("ERR_RTP_PACKET_INVALIDPACKET")
 	}
+	this.payload = this.packet[payloadoffset : payloadoffset+payloadlength]
 
 	return nil
 }
@@ -0,0 +1,262 @@
+package rtp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+)
+
+const SRTP_REPLAYWINDOWSIZE = 64
+const SRTP_MAXLIFETIME = uint64(1) << 48
+const SRTCP_MAXLIFETIME = uint64(1) << 31
+const SRTCP_E_FLAG = 0x80000000
+const SRTCP_INDEX_MSK = 0x7FFFFFFF
+const SIZEOF_SRTCPINDEX = 4
+
+/** Key derivation labels, see RFC 3711 section 4.3.2 and RFC 6188. */
+const SRTP_LABEL_RTP_ENCRYPTION = 0x00
+const SRTP_LABEL_RTP_AUTHENTICATION = 0x01
+const SRTP_LABEL_RTP_SALT = 0x02
+const SRTP_LABEL_RTCP_ENCRYPTION = 0x03
+const SRTP_LABEL_RTCP_AUTHENTICATION = 0x04
+const SRTP_LABEL_RTCP_SALT = 0x05
+
+/** Identifies the crypto suite which protects an SRTP session. */
+type SRTPProfile uint8
+
+const (
+	SRTP_AES128_CM_HMAC_SHA1_80 SRTPProfile = iota /**< AES counter mode with an 80 bit HMAC-SHA1 tag (RFC 3711). */
+	SRTP_AES128_CM_HMAC_SHA1_32                    /**< AES counter mode with a 32 bit HMAC-SHA1 tag on SRTP, 80 bit on SRTCP (RFC 3711). */
+	SRTP_AEAD_AES_128_GCM                          /**< AES-GCM with a 128 bit key and 16 octet tag (RFC 7714). */
+	SRTP_AEAD_AES_256_GCM                          /**< AES-GCM with a 256 bit key and 16 octet tag (RFC 7714). */
+)
+
+/** Returns the length in bytes of the master key used by this profile. */
+func (this SRTPProfile) KeyLength() int {
+	if this == SRTP_AEAD_AES_256_GCM {
+		return 32
+	}
+	return 16
+}
+
+/** Returns the length in bytes of the master salt used by this profile. */
+func (this SRTPProfile) SaltLength() int {
+	if this.IsAEAD() {
+		return 12
+	}
+	return 14
+}
+
+/** Returns \c true if this profile uses an AEAD cipher instead of a separate authentication tag. */
+func (this SRTPProfile) IsAEAD() bool {
+	return this == SRTP_AEAD_AES_128_GCM || this == SRTP_AEAD_AES_256_GCM
+}
+
+/** Returns the length of the authentication tag appended to SRTP packets. */
+func (this SRTPProfile) RTPAuthTagLength() int {
+	switch this {
+	case SRTP_AES128_CM_HMAC_SHA1_32:
+		return 4
+	case SRTP_AEAD_AES_128_GCM, SRTP_AEAD_AES_256_GCM:
+		return 16
+	}
+	return 10
+}
+
+/** Returns the length of the authentication tag appended to SRTCP packets. */
+func (this SRTPProfile) RTCPAuthTagLength() int {
+	if this.IsAEAD() {
+		return 16
+	}
+	return 10
+}
+
+/** Returns the length of the session authentication key, zero for AEAD profiles. */
+func (this SRTPProfile) authKeyLength() int {
+	if this.IsAEAD() {
+		return 0
+	}
+	return 20
+}
+
+/** Returns the crypto suite name used by the SDP crypto attribute (RFC 4568, RFC 7714). */
+func (this SRTPProfile) String() string {
+	switch this {
+	case SRTP_AES128_CM_HMAC_SHA1_80:
+		return "AES_CM_128_HMAC_SHA1_80"
+	case SRTP_AES128_CM_HMAC_SHA1_32:
+		return "AES_CM_128_HMAC_SHA1_32"
+	case SRTP_AEAD_AES_128_GCM:
+		return "AEAD_AES_128_GCM"
+	case SRTP_AEAD_AES_256_GCM:
+		return "AEAD_AES_256_GCM"
+	}
+	return "UNKNOWN"
+}
+
+/** Looks up the profile for an SDP crypto suite name. */
+func GetSRTPProfile(suite string) (SRTPProfile, error) {
+	for p := SRTP_AES128_CM_HMAC_SHA1_80; p <= SRTP_AEAD_AES_256_GCM; p++ {
+		if p.String() == suite {
+			return p, nil
+		}
+	}
+	return 0, errors.New("ERR_SRTP_UNKNOWNPROFILE")
+}
+
+/** Derives \c n bytes of session key material for \c label using the AES-CM PRF of
+ *  RFC 3711 section 4.3.3. The value \c r is the packet index divided by the key
+ *  derivation rate (zero when the rate is zero). The master salt is aligned at the
+ *  start of the block, which is how 96 bit AEAD salts are treated in practice.
+ */
+func srtpDeriveKey(masterkey, mastersalt []byte, label uint8, r uint64, n int) ([]byte, error) {
+	block, err := aes.NewCipher(masterkey)
+	if err != nil {
+		return nil, errors.New("ERR_SRTP_BADKEYLENGTH")
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, mastersalt)
+	iv[7] ^= label
+	for i := 0; i < 6; i++ {
+		iv[13-i] ^= byte(r >> (8 * uint(i)))
+	}
+
+	out := make([]byte, n)
+	cipher.NewCTR(block, iv).XORKeyStream(out, out)
+	return out, nil
+}
+
+/** Applies the AES counter mode transform of RFC 3711 section 4.1.1 to \c data in place.
+ *  The same function encrypts and decrypts; \c index is the 48 bit SRTP packet index or
+ *  the 31 bit SRTCP index.
+ */
+func srtpCounterMode(block cipher.Block, salt []byte, ssrc uint32, index uint64, data []byte) {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, salt)
+	iv[4] ^= byte(ssrc >> 24)
+	iv[5] ^= byte(ssrc >> 16)
+	iv[6] ^= byte(ssrc >> 8)
+	iv[7] ^= byte(ssrc)
+	for i := 0; i < 6; i++ {
+		iv[13-i] ^= byte(index >> (8 * uint(i)))
+	}
+	cipher.NewCTR(block, iv).XORKeyStream(data, data)
+}
+
+/** Builds the 12 octet AES-GCM IV for an SRTP packet (RFC 7714 section 8.1). */
+func srtpGCMIV(salt []byte, ssrc uint32, roc uint32, seqnr uint16) []byte {
+	iv := make([]byte, 12)
+	iv[2] = byte(ssrc >> 24)
+	iv[3] = byte(ssrc >> 16)
+	iv[4] = byte(ssrc >> 8)
+	iv[5] = byte(ssrc)
+	iv[6] = byte(roc >> 24)
+	iv[7] = byte(roc >> 16)
+	iv[8] = byte(roc >> 8)
+	iv[9] = byte(roc)
+	iv[10] = byte(seqnr >> 8)
+	iv[11] = byte(seqnr)
+	for i := range iv {
+		iv[i] ^= salt[i]
+	}
+	return iv
+}
+
+/** Builds the 12 octet AES-GCM IV for an SRTCP packet (RFC 7714 section 9.1). */
+func srtcpGCMIV(salt []byte, ssrc uint32, index uint32) []byte {
+	iv := make([]byte, 12)
+	iv[2] = byte(ssrc >> 24)
+	iv[3] = byte(ssrc >> 16)
+	iv[4] = byte(ssrc >> 8)
+	iv[5] = byte(ssrc)
+	iv[8] = byte(index >> 24)
+	iv[9] = byte(index >> 16)
+	iv[10] = byte(index >> 8)
+	iv[11] = byte(index)
+	for i := range iv {
+		iv[i] ^= salt[i]
+	}
+	return iv
+}
+
+/** A sliding replay window over packet indices (RFC 3711 section 3.3.2). */
+type srtpReplayWindow struct {
+	started bool
+	top     uint64
+	bitmap  uint64
+}
+
+/** Returns \c false if \c index was already received or is too old to be checked. */
+func (this *srtpReplayWindow) Check(index uint64) bool {
+	if !this.started || index > this.top {
+		return true
+	}
+	delta := this.top - index
+	if delta >= SRTP_REPLAYWINDOWSIZE {
+		return false
+	}
+	return (this.bitmap & (uint64(1) << delta)) == 0
+}
+
+/** Marks \c index as received; must only be called after the packet was authenticated. */
+func (this *srtpReplayWindow) Update(index uint64) {
+	if !this.started {
+		this.started = true
+		this.top = index
+		this.bitmap = 1
+		return
+	}
+	if index > this.top {
+		delta := index - this.top
+		if delta >= SRTP_REPLAYWINDOWSIZE {
+			this.bitmap = 1
+		} else {
+			this.bitmap = (this.bitmap << delta) | 1
+		}
+		this.top = index
+	} else {
+		this.bitmap |= uint64(1) << (this.top - index)
+	}
+}
+
+/** Cryptographic state of a single SSRC in one direction. */
+type srtpStream struct {
+	started bool
+	roc     uint32
+	lastseq uint16
+	replay  srtpReplayWindow
+
+	srtcpindex uint32
+	rtcpreplay srtpReplayWindow
+	rtpkeys    *srtpSessionKeys
+	rtcpkeys   *srtpSessionKeys
+}
+
+/** Estimates the rollover counter of \c seqnr as described in RFC 3711 Appendix A and
+ *  returns it together with the resulting 48 bit packet index.
+ */
+func (this *srtpStream) EstimateIndex(seqnr uint16) (uint32, uint64) {
+	v := this.roc
+	if this.started {
+		if this.lastseq < 0x8000 {
+			if int(seqnr)-int(this.lastseq) > 0x8000 && v > 0 {
+				v--
+			}
+		} else {
+			if int(this.lastseq)-0x8000 > int(seqnr) {
+				v++
+			}
+		}
+	}
+	return v, uint64(v)<<16 | uint64(seqnr)
+}
+
+/** Records that the packet with rollover counter \c roc and \c seqnr was processed. */
+func (this *srtpStream) Update(roc uint32, seqnr uint16) {
+	if !this.started || roc > this.roc || (roc == this.roc && seqnr > this.lastseq) {
+		this.roc = roc
+		this.lastseq = seqnr
+	}
+	this.started = true
+}
@@ -0,0 +1,169 @@
+package rtp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// RFC 3711 Appendix B.2: AES-CM keystream.
+func TestSRTPCounterModeKeystream(t *testing.T) {
+	block, _ := aes.NewCipher(unhex(t, "2B7E151628AED2A6ABF7158809CF4F3C"))
+	salt := unhex(t, "F0F1F2F3F4F5F6F7F8F9FAFBFCFD")
+
+	keystream := make([]byte, 0xFF02*16)
+	srtpCounterMode(block, salt, 0, 0, keystream)
+
+	var tv = []struct {
+		block  int
+		stream string
+	}{
+		{0x0000, "E03EAD0935C95E80E166B16DD92B4EB4"},
+		{0x0001, "D23513162B02D0F72A43A2FE4A5F97AB"},
+		{0x0002, "41E95B3BB0A2E8DD477901E4FCA894C0"},
+		{0xFEFF, "EC8CDF7398607CB0F2D21675EA9EA1E4"},
+		{0xFF00, "362B7C3C6773516318A077D7FC5073AE"},
+		{0xFF01, "6A2CC3787889374FBEB4C81B17BA6C44"},
+	}
+	for _, v := range tv {
+		if got := keystream[v.block*16 : v.block*16+16]; !bytes.Equal(got, unhex(t, v.stream)) {
+			t.Errorf("block %04X: got %X, want %s", v.block, got, v.stream)
+		}
+	}
+}
+
+// RFC 3711 Appendix B.3: key derivation.
+func TestSRTPKeyDerivation(t *testing.T) {
+	masterkey := unhex(t, "E1F97A0D3E018BE0D64FA32C06DE4139")
+	mastersalt := unhex(t, "0EC675AD498AFEEBB6960B3AABE6")
+
+	var tv = []struct {
+		label uint8
+		n     int
+		key   string
+	}{
+		{SRTP_LABEL_RTP_ENCRYPTION, 16, "C61E7A93744F39EE10734AFE3FF7A087"},
+		{SRTP_LABEL_RTP_SALT, 14, "30CBBC08863D8C85D49DB34A9AE1"},
+		{SRTP_LABEL_RTP_AUTHENTICATION, 20, "CEBE321F6FF7716B6FD4AB49AF256A156D38BAA4"},
+	}
+	for _, v := range tv {
+		key, err := srtpDeriveKey(masterkey, mastersalt, v.label, 0, v.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(key, unhex(t, v.key)) {
+			t.Errorf("label %d: got %X, want %s", v.label, key, v.key)
+		}
+	}
+}
+
+// Known answer from the libsrtp test driver, keyed with the Appendix B.3 master key.
+func TestSRTPProtectKnownAnswer(t *testing.T) {
+	ctx, err := NewSRTPContext(SRTP_AES128_CM_HMAC_SHA1_80,
+		unhex(t, "E1F97A0D3E018BE0D64FA32C06DE4139"), unhex(t, "0EC675AD498AFEEBB6960B3AABE6"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := NewPacket(0x0F, bytes.Repeat([]byte{0xAB}, 16), 0x1234, 0xDECAFBAD, 0xCAFEBABE, false, 0, nil, false, 0, 0, nil)
+	srtp, err := ctx.ProtectRTP(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := unhex(t, "800F1234DECAFBADCAFEBABE4E55DC4CE79978D88CA4D215949D2402B78D6ACC99EA179B8DBB")
+	if !bytes.Equal(srtp, want) {
+		t.Errorf("got %X, want %X", srtp, want)
+	}
+}
+
+func newSRTPContextPair(t *testing.T, profile SRTPProfile) (*SRTPContext, *SRTPContext) {
+	key := bytes.Repeat([]byte{0x11}, profile.KeyLength())
+	salt := bytes.Repeat([]byte{0x22}, profile.SaltLength())
+	sender, err := NewSRTPContext(profile, key, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := NewSRTPContext(profile, key, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sender, receiver
+}
+
+func TestSRTPProtectUnprotect(t *testing.T) {
+	payload := []byte("the quick brown fox jumps over the lazy dog")
+	for p := SRTP_AES128_CM_HMAC_SHA1_80; p <= SRTP_AEAD_AES_256_GCM; p++ {
+		sender, receiver := newSRTPContextPair(t, p)
+		sender.SetMKI([]byte{0, 1})
+		receiver.SetMKI([]byte{0, 1})
+
+		for i := 0; i < 3; i++ {
+			packet := NewPacket(0, payload, uint16(0xFFFE+i), 160*uint32(i), 0xCAFEBABE, false, 1, []uint32{0x01020304}, false, 0, 0, nil)
+			srtp, err := sender.ProtectRTP(packet)
+			if err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+			if bytes.Contains(srtp, payload) {
+				t.Errorf("%s: payload not encrypted", p)
+			}
+			got, err := receiver.UnprotectRTP(NewRawPacket(srtp, nil, CurrentRTPTime(), true))
+			if err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+			if !bytes.Equal(got.GetPayload(), payload) || got.GetCSRC(0) != 0x01020304 {
+				t.Errorf("%s: payload mismatch %q", p, got.GetPayload())
+			}
+			if _, err = receiver.UnprotectRTP(NewRawPacket(srtp, nil, CurrentRTPTime(), true)); err == nil {
+				t.Errorf("%s: replayed packet accepted", p)
+			}
+			srtp[len(srtp)-1] ^= 0x01
+			if _, err = receiver.UnprotectRTP(NewRawPacket(srtp, nil, CurrentRTPTime(), true)); err == nil {
+				t.Errorf("%s: tampered packet accepted", p)
+			}
+		}
+		if sender.GetROC(0xCAFEBABE) != 1 {
+			t.Errorf("%s: ROC not incremented on rollover", p)
+		}
+
+		rtcp := []byte{0x81, 0xCB, 0x00, 0x01, 0xCA, 0xFE, 0xBA, 0xBE}
+		for i := 0; i < 2; i++ {
+			srtcp, err := sender.ProtectRTCP(rtcp)
+			if err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+			got, err := receiver.UnprotectRTCP(NewRawPacket(srtcp, nil, CurrentRTPTime(), false))
+			if err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+			if !bytes.Equal(got, rtcp) {
+				t.Errorf("%s: RTCP mismatch %X", p, got)
+			}
+			if _, err = receiver.UnprotectRTCP(NewRawPacket(srtcp, nil, CurrentRTPTime(), false)); err == nil {
+				t.Errorf("%s: replayed RTCP accepted", p)
+			}
+		}
+	}
+}
+
+func TestSRTPReplayWindow(t *testing.T) {
+	var w srtpReplayWindow
+	for _, index := range []uint64{100, 102, 101, 90} {
+		if !w.Check(index) {
+			t.Errorf("index %d rejected", index)
+		}
+		w.Update(index)
+	}
+	for _, index := range []uint64{100, 101, 102, 90, 30} {
+		if w.Check(index) {
+			t.Errorf("index %d accepted", index)
+		}
+	}
+}
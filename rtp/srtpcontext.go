@@ -0,0 +1,489 @@
+package rtp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"errors"
+	"hash"
+	"sync"
+)
+
+/** Session keys derived from the master key for either SRTP or SRTCP. */
+type srtpSessionKeys struct {
+	r     uint64
+	block cipher.Block
+	aead  cipher.AEAD
+	salt  []byte
+	auth  hash.Hash
+}
+
+/** Implements SRTP and SRTCP (RFC 3711, RFC 7714) for one master key.
+ *  The context keeps the rollover counter, SRTCP index and replay window of each SSRC
+ *  it has seen. Outgoing and incoming streams are tracked separately so one context
+ *  can be used for both directions of a session as long as the SSRCs differ, although
+ *  the usual setup is one context per direction.
+ */
+type SRTPContext struct {
+	mutex sync.Mutex
+
+	profile    SRTPProfile
+	masterkey  []byte
+	mastersalt []byte
+	mki        []byte
+	kdr        uint64
+	lifetime   uint64
+
+	srtpcount  uint64
+	srtcpcount uint64
+
+	rtpkeys  *srtpSessionKeys
+	rtcpkeys *srtpSessionKeys
+
+	outstreams map[uint32]*srtpStream
+	instreams  map[uint32]*srtpStream
+}
+
+/** Creates a context for \c profile from the master key and master salt. The lengths of
+ *  both must match SRTPProfile.KeyLength() and SRTPProfile.SaltLength().
+ */
+func NewSRTPContext(profile SRTPProfile, masterkey, mastersalt []byte) (*SRTPContext, error) {
+	if profile > SRTP_AEAD_AES_256_GCM {
+		return nil, errors.New("ERR_SRTP_UNKNOWNPROFILE")
+	}
+	if len(masterkey) != profile.KeyLength() {
+		return nil, errors.New("ERR_SRTP_BADKEYLENGTH")
+	}
+	if len(mastersalt) != profile.SaltLength() {
+		return nil, errors.New("ERR_SRTP_BADSALTLENGTH")
+	}
+
+	this := &SRTPContext{}
+	this.profile = profile
+	this.masterkey = make([]byte, len(masterkey))
+	copy(this.masterkey, masterkey)
+	this.mastersalt = make([]byte, len(mastersalt))
+	copy(this.mastersalt, mastersalt)
+	this.lifetime = SRTP_MAXLIFETIME
+	this.outstreams = make(map[uint32]*srtpStream)
+	this.instreams = make(map[uint32]*srtpStream)
+
+	var err error
+	if this.rtpkeys, err = this.deriveKeys(false, 0); err != nil {
+		return nil, err
+	}
+	if this.rtcpkeys, err = this.deriveKeys(true, 0); err != nil {
+		return nil, err
+	}
+	return this, nil
+}
+
+/** Returns the crypto suite of this context. */
+func (this *SRTPContext) GetProfile() SRTPProfile {
+	return this.profile
+}
+
+/** Sets the master key identifier which is carried in every packet. An empty value disables the MKI. */
+func (this *SRTPContext) SetMKI(mki []byte) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.mki = make([]byte, len(mki))
+	copy(this.mki, mki)
+}
+
+/** Returns the master key identifier, or nil if none is used. */
+func (this *SRTPContext) GetMKI() []byte {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.mki
+}
+
+/** Sets the key derivation rate. It must be zero or a power of two between 1 and 2^24. */
+func (this *SRTPContext) SetKeyDerivationRate(kdr uint64) error {
+	if kdr != 0 && (kdr&(kdr-1) != 0 || kdr > 1<<24) {
+		return errors.New("ERR_SRTP_BADKEYDERIVATIONRATE")
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.kdr = kdr
+	return nil
+}
+
+/** Returns the key derivation rate; zero means the session keys are derived once. */
+func (this *SRTPContext) GetKeyDerivationRate() uint64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.kdr
+}
+
+/** Limits the number of SRTP packets protected or unprotected with the master key.
+ *  Once the limit is reached all further packets are refused and the context must be
+ *  rekeyed. The limit can not exceed 2^48.
+ */
+func (this *SRTPContext) SetMasterKeyLifetime(lifetime uint64) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if lifetime == 0 || lifetime > SRTP_MAXLIFETIME {
+		lifetime = SRTP_MAXLIFETIME
+	}
+	this.lifetime = lifetime
+}
+
+/** Returns the master key lifetime in packets. */
+func (this *SRTPContext) GetMasterKeyLifetime() uint64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.lifetime
+}
+
+/** Returns the rollover counter of the outgoing stream \c ssrc. */
+func (this *SRTPContext) GetROC(ssrc uint32) uint32 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if stream, ok := this.outstreams[ssrc]; ok {
+		return stream.roc
+	}
+	return 0
+}
+
+/** Sets the rollover counter of the outgoing stream \c ssrc, e.g. when joining a session late. */
+func (this *SRTPContext) SetROC(ssrc uint32, roc uint32) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.getStream(this.outstreams, ssrc).roc = roc
+}
+
+func (this *SRTPContext) getStream(streams map[uint32]*srtpStream, ssrc uint32) *srtpStream {
+	stream, ok := streams[ssrc]
+	if !ok {
+		stream = &srtpStream{}
+		streams[ssrc] = stream
+	}
+	return stream
+}
+
+func (this *SRTPContext) deriveKeys(rtcp bool, r uint64) (*srtpSessionKeys, error) {
+	var enclabel, authlabel, saltlabel uint8 = SRTP_LABEL_RTP_ENCRYPTION, SRTP_LABEL_RTP_AUTHENTICATION, SRTP_LABEL_RTP_SALT
+	if rtcp {
+		enclabel, authlabel, saltlabel = SRTP_LABEL_RTCP_ENCRYPTION, SRTP_LABEL_RTCP_AUTHENTICATION, SRTP_LABEL_RTCP_SALT
+	}
+
+	keys := &srtpSessionKeys{r: r}
+	enckey, err := srtpDeriveKey(this.masterkey, this.mastersalt, enclabel, r, this.profile.KeyLength())
+	if err != nil {
+		return nil, err
+	}
+	if keys.salt, err = srtpDeriveKey(this.masterkey, this.mastersalt, saltlabel, r, this.profile.SaltLength()); err != nil {
+		return nil, err
+	}
+	if keys.block, err = aes.NewCipher(enckey); err != nil {
+		return nil, errors.New("ERR_SRTP_BADKEYLENGTH")
+	}
+	if this.profile.IsAEAD() {
+		if keys.aead, err = cipher.NewGCM(keys.block); err != nil {
+			return nil, err
+		}
+	} else {
+		authkey, err := srtpDeriveKey(this.masterkey, this.mastersalt, authlabel, r, this.profile.authKeyLength())
+		if err != nil {
+			return nil, err
+		}
+		keys.auth = hmac.New(sha1.New, authkey)
+	}
+	return keys, nil
+}
+
+/** Returns the session keys to use for \c index, deriving fresh ones when a key
+ *  derivation rate is set and the index crossed into a new period.
+ */
+func (this *SRTPContext) getKeys(stream *srtpStream, rtcp bool, index uint64) (*srtpSessionKeys, error) {
+	if this.kdr == 0 {
+		if rtcp {
+			return this.rtcpkeys, nil
+		}
+		return this.rtpkeys, nil
+	}
+
+	r := index / this.kdr
+	current := &stream.rtpkeys
+	if rtcp {
+		current = &stream.rtcpkeys
+	}
+	if *current == nil || (*current).r != r {
+		keys, err := this.deriveKeys(rtcp, r)
+		if err != nil {
+			return nil, err
+		}
+		*current = keys
+	}
+	return *current, nil
+}
+
+func (this *SRTPContext) authenticate(keys *srtpSessionKeys, data []byte, roc []byte, taglen int) []byte {
+	keys.auth.Reset()
+	keys.auth.Write(data)
+	if roc != nil {
+		keys.auth.Write(roc)
+	}
+	return keys.auth.Sum(nil)[:taglen]
+}
+
+/** Returns the length of the RTP header in \c data including CSRCs and header extension. */
+func rtpHeaderLength(data []byte) (int, error) {
+	header := NewRTPHeader()
+	if err := header.Parse(data); err != nil {
+		return 0, err
+	}
+	if header.version != RTP_VERSION {
+		return 0, errors.New("ERR_RTP_PACKET_INVALIDPACKET")
+	}
+	hdrlen := SIZEOF_RTPHEADER + 4*int(header.csrccount)
+	if header.extension != 0 {
+		if len(data) < hdrlen+SIZEOF_RTPEXTENSION {
+			return 0, errors.New("ERR_RTP_PACKET_INVALIDPACKET")
+		}
+		hdrlen += SIZEOF_RTPEXTENSION + 4*(int(data[hdrlen+2])<<8|int(data[hdrlen+3]))
+		if len(data) < hdrlen {
+			return 0, errors.New("ERR_RTP_PACKET_INVALIDPACKET")
+		}
+	}
+	return hdrlen, nil
+}
+
+func uint32ToBytes(v uint32) []byte {
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+func bytesToUint32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+/** Encrypts and authenticates \c packet and returns the resulting SRTP packet. */
+func (this *SRTPContext) ProtectRTP(packet *RTPPacket) ([]byte, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	data := packet.GetPacket()
+	hdrlen, err := rtpHeaderLength(data)
+	if err != nil {
+		return nil, err
+	}
+	if this.srtpcount >= this.lifetime {
+		return nil, errors.New("ERR_SRTP_KEYEXPIRED")
+	}
+
+	stream := this.getStream(this.outstreams, packet.GetSSRC())
+	roc, index := stream.EstimateIndex(packet.GetSequenceNumber())
+	keys, err := this.getKeys(stream, false, index)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, hdrlen, len(data)+len(this.mki)+this.profile.RTPAuthTagLength())
+	copy(out, data[:hdrlen])
+	if this.profile.IsAEAD() {
+		iv := srtpGCMIV(keys.salt, packet.GetSSRC(), roc, packet.GetSequenceNumber())
+		out = keys.aead.Seal(out, iv, data[hdrlen:], data[:hdrlen])
+		out = append(out, this.mki...)
+	} else {
+		out = append(out, data[hdrlen:]...)
+		srtpCounterMode(keys.block, keys.salt, packet.GetSSRC(), index, out[hdrlen:])
+		authlen := len(out)
+		out = append(out, this.mki...)
+		out = append(out, this.authenticate(keys, out[:authlen], uint32ToBytes(roc), this.profile.RTPAuthTagLength())...)
+	}
+
+	stream.Update(roc, packet.GetSequenceNumber())
+	this.srtpcount++
+	return out, nil
+}
+
+/** Verifies and decrypts the SRTP packet in \c rawpack. Replayed packets and packets
+ *  failing authentication are rejected.
+ */
+func (this *SRTPContext) UnprotectRTP(rawpack *RawPacket) (*RTPPacket, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	data := rawpack.GetData()
+	hdrlen, err := rtpHeaderLength(data)
+	if err != nil {
+		return nil, err
+	}
+	trailer := len(this.mki)
+	if !this.profile.IsAEAD() {
+		trailer += this.profile.RTPAuthTagLength()
+	} else if len(data) < hdrlen+trailer+this.profile.RTPAuthTagLength() {
+		return nil, errors.New("ERR_SRTP_PACKETTOOSHORT")
+	}
+	if len(data) < hdrlen+trailer {
+		return nil, errors.New("ERR_SRTP_PACKETTOOSHORT")
+	}
+	if this.srtpcount >= this.lifetime {
+		return nil, errors.New("ERR_SRTP_KEYEXPIRED")
+	}
+
+	ssrc := bytesToUint32(data[8:12])
+	seqnr := uint16(data[2])<<8 | uint16(data[3])
+
+	stream := this.getStream(this.instreams, ssrc)
+	roc, index := stream.EstimateIndex(seqnr)
+	if !stream.replay.Check(index) {
+		return nil, errors.New("ERR_SRTP_REPLAYED")
+	}
+	keys, err := this.getKeys(stream, false, index)
+	if err != nil {
+		return nil, err
+	}
+
+	end := len(data) - trailer
+	if !bytes.Equal(data[end:end+len(this.mki)], this.mki) {
+		return nil, errors.New("ERR_SRTP_BADMKI")
+	}
+
+	plain := make([]byte, hdrlen, end)
+	copy(plain, data[:hdrlen])
+	if this.profile.IsAEAD() {
+		iv := srtpGCMIV(keys.salt, ssrc, roc, seqnr)
+		if plain, err = keys.aead.Open(plain, iv, data[hdrlen:end], data[:hdrlen]); err != nil {
+			return nil, errors.New("ERR_SRTP_AUTHFAILED")
+		}
+	} else {
+		tag := data[end+len(this.mki):]
+		if !hmac.Equal(tag, this.authenticate(keys, data[:end], uint32ToBytes(roc), len(tag))) {
+			return nil, errors.New("ERR_SRTP_AUTHFAILED")
+		}
+		plain = append(plain, data[hdrlen:end]...)
+		srtpCounterMode(keys.block, keys.salt, ssrc, index, plain[hdrlen:])
+	}
+
+	stream.replay.Update(index)
+	stream.Update(roc, seqnr)
+	this.srtpcount++
+
+	recvtime := rawpack.GetReceiveTime()
+	if recvtime == nil {
+		recvtime = CurrentRTPTime()
+	}
+	packet := NewRTPPacketFromRawPacket(NewRawPacket(plain, rawpack.GetSenderAddress(), recvtime, true))
+	if packet == nil {
+		return nil, errors.New("ERR_RTP_PACKET_INVALIDPACKET")
+	}
+	return packet, nil
+}
+
+/** Encrypts and authenticates the (compound) RTCP packet in \c data and returns the SRTCP packet. */
+func (this *SRTPContext) ProtectRTCP(data []byte) ([]byte, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if len(data) < SIZEOF_RTCPHEADER+4 {
+		return nil, errors.New("ERR_RTP_PACKET_INVALIDPACKET")
+	}
+	if this.srtcpcount >= SRTCP_MAXLIFETIME || this.srtcpcount >= this.lifetime {
+		return nil, errors.New("ERR_SRTP_KEYEXPIRED")
+	}
+
+	ssrc := bytesToUint32(data[4:8])
+	stream := this.getStream(this.outstreams, ssrc)
+	index := stream.srtcpindex
+	stream.srtcpindex = (stream.srtcpindex + 1) & SRTCP_INDEX_MSK
+	keys, err := this.getKeys(stream, true, uint64(index))
+	if err != nil {
+		return nil, err
+	}
+	eindex := uint32ToBytes(SRTCP_E_FLAG | index)
+
+	out := make([]byte, 8, len(data)+SIZEOF_SRTCPINDEX+len(this.mki)+this.profile.RTCPAuthTagLength())
+	copy(out, data[:8])
+	if this.profile.IsAEAD() {
+		aad := append(append([]byte{}, data[:8]...), eindex...)
+		out = keys.aead.Seal(out, srtcpGCMIV(keys.salt, ssrc, index), data[8:], aad)
+		out = append(out, eindex...)
+		out = append(out, this.mki...)
+	} else {
+		out = append(out, data[8:]...)
+		srtpCounterMode(keys.block, keys.salt, ssrc, uint64(index), out[8:])
+		out = append(out, eindex...)
+		authlen := len(out)
+		out = append(out, this.mki...)
+		out = append(out, this.authenticate(keys, out[:authlen], nil, this.profile.RTCPAuthTagLength())...)
+	}
+
+	this.srtcpcount++
+	return out, nil
+}
+
+/** Verifies and decrypts the SRTCP packet in \c rawpack and returns the plain compound RTCP packet. */
+func (this *SRTPContext) UnprotectRTCP(rawpack *RawPacket) ([]byte, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	data := rawpack.GetData()
+	taglen := this.profile.RTCPAuthTagLength()
+	trailer := SIZEOF_SRTCPINDEX + len(this.mki)
+	if !this.profile.IsAEAD() {
+		trailer += taglen
+	}
+	if len(data) < 8+trailer {
+		return nil, errors.New("ERR_SRTP_PACKETTOOSHORT")
+	}
+	if this.srtcpcount >= SRTCP_MAXLIFETIME || this.srtcpcount >= this.lifetime {
+		return nil, errors.New("ERR_SRTP_KEYEXPIRED")
+	}
+
+	end := len(data) - trailer
+	eindex := data[end : end+SIZEOF_SRTCPINDEX]
+	encrypted := (bytesToUint32(eindex) & SRTCP_E_FLAG) != 0
+	index := bytesToUint32(eindex) & SRTCP_INDEX_MSK
+	mki := data[end+SIZEOF_SRTCPINDEX : end+SIZEOF_SRTCPINDEX+len(this.mki)]
+	if !bytes.Equal(mki, this.mki) {
+		return nil, errors.New("ERR_SRTP_BADMKI")
+	}
+
+	ssrc := bytesToUint32(data[4:8])
+	stream := this.getStream(this.instreams, ssrc)
+	if !stream.rtcpreplay.Check(uint64(index)) {
+		return nil, errors.New("ERR_SRTP_REPLAYED")
+	}
+	keys, err := this.getKeys(stream, true, uint64(index))
+	if err != nil {
+		return nil, err
+	}
+
+	plain := make([]byte, 8, end)
+	copy(plain, data[:8])
+	if this.profile.IsAEAD() {
+		iv := srtcpGCMIV(keys.salt, ssrc, index)
+		if encrypted {
+			aad := append(append([]byte{}, data[:8]...), eindex...)
+			if plain, err = keys.aead.Open(plain, iv, data[8:end], aad); err != nil {
+				return nil, errors.New("ERR_SRTP_AUTHFAILED")
+			}
+		} else {
+			if end-taglen < 8 {
+				return nil, errors.New("ERR_SRTP_PACKETTOOSHORT")
+			}
+			aad := append(append([]byte{}, data[:end-taglen]...), eindex...)
+			if _, err = keys.aead.Open(nil, iv, data[end-taglen:end], aad); err != nil {
+				return nil, errors.New("ERR_SRTP_AUTHFAILED")
+			}
+			plain = append(plain, data[8:end-taglen]...)
+		}
+	} else {
+		tag := data[end+SIZEOF_SRTCPINDEX+len(this.mki):]
+		if !hmac.Equal(tag, this.authenticate(keys, data[:end+SIZEOF_SRTCPINDEX], nil, len(tag))) {
+			return nil, errors.New("ERR_SRTP_AUTHFAILED")
+		}
+		plain = append(plain, data[8:end]...)
+		if encrypted {
+			srtpCounterMode(keys.block, keys.salt, ssrc, uint64(index), plain[8:])
+		}
+	}
+
+	stream.rtcpreplay.Update(uint64(index))
+	this.srtcpcount++
+	return plain, nil
+}
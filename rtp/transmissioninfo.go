@@ -58,3 +58,8 @@ func (this *TransmissionInfo) GetRTPSocket() int {
 func (this *TransmissionInfo) GetRTCPSocket() int {
 	return this.rtcpsocket
 }
+
+/** Returns \c true if RTP and RTCP are sent and received on the same socket (RFC 5761). */
+func (this *TransmissionInfo) IsRTCPMultiplexed() bool {
+	return this.rtpsocket == this.rtcpsocket
+}
@@ -27,6 +27,7 @@ type TransmissionParams struct {
 	multicastTTL             uint8
 	rtpsendbuf, rtprecvbuf   int
 	rtcpsendbuf, rtcprecvbuf int
+	rtcpmux                  bool
 }
 
 func NewTransmissionParams() *TransmissionParams {
@@ -64,6 +65,13 @@ func (this *TransmissionParams) SetPortbase(pbase uint16) {
 	this.portbase = pbase
 }
 
+/** Sets whether RTP and RTCP share the port \c portbase (RFC 5761). In this
+ *  single port mode received packets are demultiplexed with NewMuxedRawPacket.
+ */
+func (this *TransmissionParams) SetRTCPMultiplexing(mux bool) {
+	this.rtcpmux = mux
+}
+
 /** Sets the multicast TTL to be used to \c mcastTTL. */
 func (this *TransmissionParams) SetMulticastTTL(mcastTTL uint8) {
 	this.multicastTTL = mcastTTL
@@ -117,6 +125,21 @@ func (this *TransmissionParams) GetPortbase() uint16 {
 	return this.portbase
 }
 
+/** Returns \c true if RTP and RTCP share a single port. */
+func (this *TransmissionParams) IsRTCPMultiplexed() bool {
+	return this.rtcpmux
+}
+
+/** Returns the RTCP port: the port after the RTP portbase, or the portbase
+ *  itself when RTCP is multiplexed.
+ */
+func (this *TransmissionParams) GetRTCPPort() uint16 {
+	if this.rtcpmux {
+		return this.portbase
+	}
+	return this.portbase + 1
+}
+
 /** Returns the multicast TTL which will be used (default is 1). */
 func (this *TransmissionParams) GetMulticastTTL() uint8 {
 	return this.multicastTTL
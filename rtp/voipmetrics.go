@@ -0,0 +1,571 @@
+package rtp
+
+import (
+	"math"
+	"sync"
+)
+
+/** The default gap threshold of the burst metrics (RFC 3611 section 4.7.2). */
+const RTP_XR_GMIN = 16
+
+/** The default packetization interval in milliseconds. */
+const RTP_XR_PACKETDURATION = 20
+
+/** The E-model equipment impairment factor and packet loss robustness of
+ *  G.711 with packet loss concealment (ITU-T G.113 Appendix I).
+ */
+const RTP_XR_G711_IE = 0.0
+const RTP_XR_G711_BPL = 25.1
+
+/** Computes the VoIP quality metrics of a single RTP source: loss, discard,
+ *  burst/gap density and duration as defined by RFC 3611 section 4.7, the
+ *  interarrival jitter of RFC 3550 and an E-model (ITU-T G.107) estimate of
+ *  the R factor and MOS. The metrics can be sent to the peer in an RTCP XR
+ *  VoIP Metrics block. The methods may be called from different goroutines.
+ */
+type VoIPMetrics struct {
+	mutex sync.Mutex
+
+	ssrc      uint32
+	clockrate float64
+	gmin      uint32
+	duration  int
+	ie        float64
+	bpl       float64
+
+	started    bool
+	baseseq    uint32
+	maxseq     uint32
+	history    uint64 // bit n set if packet maxseq-n was received
+	received   uint32
+	duplicates uint32
+	discarded  uint32
+
+	// Burst state of RFC 3611 section 4.7.2.
+	pkt  uint32
+	lost uint32
+	c11  uint32
+	c13  uint32
+	c14  uint32
+	c22  uint32
+	c23  uint32
+	c33  uint32
+
+	// Transitions between received and lost packets, for the E-model BurstR.
+	nfound  uint32
+	nlost   uint32
+	tolost  uint32
+	tofound uint32
+	waslost bool
+
+	hastransit bool
+	transit    float64
+	jitter     float64
+	jitterstat runningStat
+
+	roundtrip uint16
+	endsystem uint16
+}
+
+/** Minimum, maximum, mean and standard deviation of a series of values. */
+type runningStat struct {
+	count uint32
+	min   float64
+	max   float64
+	sum   float64
+	sumsq float64
+}
+
+func (this *runningStat) add(v float64) {
+	if this.count == 0 || v < this.min {
+		this.min = v
+	}
+	if this.count == 0 || v > this.max {
+		this.max = v
+	}
+	this.count++
+	this.sum += v
+	this.sumsq += v * v
+}
+
+func (this *runningStat) mean() float64 {
+	if this.count == 0 {
+		return 0
+	}
+	return this.sum / float64(this.count)
+}
+
+func (this *runningStat) dev() float64 {
+	if this.count == 0 {
+		return 0
+	}
+	mean := this.mean()
+	return math.Sqrt(math.Max(0, this.sumsq/float64(this.count)-mean*mean))
+}
+
+/** Creates the metrics for source \c ssrc whose timestamps run at \c clockrate Hz.
+ *  G.711 with packet loss concealment and 20 ms packets are assumed until
+ *  SetCodec and SetPacketDuration are called.
+ */
+func NewVoIPMetrics(ssrc uint32, clockrate int) *VoIPMetrics {
+	return &VoIPMetrics{
+		ssrc:      ssrc,
+		clockrate: float64(clockrate),
+		gmin:      RTP_XR_GMIN,
+		duration:  RTP_XR_PACKETDURATION,
+		ie:        RTP_XR_G711_IE,
+		bpl:       RTP_XR_G711_BPL,
+	}
+}
+
+/** Sets the E-model equipment impairment factor \c ie and packet loss
+ *  robustness factor \c bpl of the codec in use.
+ */
+func (this *VoIPMetrics) SetCodec(ie, bpl float64) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.ie = ie
+	this.bpl = bpl
+}
+
+/** Sets the audio duration of one packet in milliseconds. */
+func (this *VoIPMetrics) SetPacketDuration(ms int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.duration = ms
+}
+
+/** Sets the gap threshold, the number of consecutive received packets that ends a burst. */
+func (this *VoIPMetrics) SetGmin(gmin uint8) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.gmin = uint32(gmin)
+}
+
+/** Sets the round trip delay in milliseconds, e.g. as computed by ComputeRoundTripDelay. */
+func (this *VoIPMetrics) SetRoundTripDelay(ms uint16) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.roundtrip = ms
+}
+
+/** Sets the delay added by this end system (jitter buffer, codec) in milliseconds. */
+func (this *VoIPMetrics) SetEndSystemDelay(ms uint16) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.endsystem = ms
+}
+
+/** Computes the round trip delay in milliseconds from the LSR and DLSR fields of
+ *  a reception report that arrived at \c arrival (RFC 3550 section 6.4.1).
+ *  Zero is returned if no sender report was received by the peer yet.
+ */
+func ComputeRoundTripDelay(arrival *NTPTime, lsr, dlsr uint32) uint16 {
+	if lsr == 0 {
+		return 0
+	}
+	a := arrival.GetMSW()<<16 | arrival.GetLSW()>>16
+	rtt := a - lsr - dlsr
+	if int32(rtt) < 0 {
+		return 0
+	}
+	ms := uint64(rtt) * 1000 / 65536
+	if ms > 0xFFFF {
+		return 0xFFFF
+	}
+	return uint16(ms)
+}
+
+/** Accounts for a received packet. \c discarded tells whether the jitter
+ *  buffer dropped the packet because it arrived too late or too early.
+ */
+func (this *VoIPMetrics) ProcessPacket(packet *RTPPacket, discarded bool) {
+	this.process(packet.GetSequenceNumber(), packet.GetTimestamp(), packet.GetReceiveTime(), discarded)
+}
+
+func (this *VoIPMetrics) process(seqnr uint16, timestamp uint32, arrival *RTPTime, discarded bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if !this.started {
+		this.started = true
+		this.baseseq = uint32(seqnr)
+		this.maxseq = uint32(seqnr)
+		this.history = 1
+		this.received = 1
+		this.update(true, discarded)
+		this.updateJitter(timestamp, arrival)
+		return
+	}
+
+	// extend the sequence number to the one closest to the highest seen
+	extseq := this.maxseq&0xFFFF0000 | uint32(seqnr)
+	if delta := int32(extseq - this.maxseq); delta > 0x8000 {
+		extseq -= 0x10000
+	} else if delta < -0x8000 {
+		extseq += 0x10000
+	}
+
+	if int32(extseq-this.maxseq) > 0 {
+		gap := extseq - this.maxseq
+		if gap < 64 {
+			this.history <<= gap
+		} else {
+			this.history = 0
+		}
+		this.history |= 1
+		for i := uint32(1); i < gap; i++ {
+			this.update(false, false)
+		}
+		this.maxseq = extseq
+		this.received++
+		this.update(true, discarded)
+	} else {
+		back := this.maxseq - extseq
+		if back < 64 && this.history&(uint64(1)<<back) != 0 {
+			this.duplicates++
+			return
+		}
+		if back < 64 {
+			this.history |= uint64(1) << back
+		}
+		// A late packet: it was accounted as lost by the burst metrics already.
+		if int32(extseq-this.baseseq) < 0 {
+			this.baseseq = extseq
+		}
+		this.received++
+		if discarded {
+			this.discarded++
+		}
+	}
+	this.updateJitter(timestamp, arrival)
+}
+
+/** Runs the burst/gap state machine of RFC 3611 section 4.7.2 for the next sequence number. */
+func (this *VoIPMetrics) update(received, discarded bool) {
+	if discarded {
+		this.discarded++
+	}
+	if received && !discarded {
+		this.pkt++
+		this.nfound++
+		if this.waslost {
+			this.tofound++
+		}
+		this.waslost = false
+		return
+	}
+
+	this.nlost++
+	if !this.waslost && this.nfound > 0 {
+		this.tolost++
+	}
+	this.waslost = true
+
+	if this.pkt >= this.gmin {
+		if this.lost == 1 {
+			this.c14++
+		} else {
+			this.c13++
+		}
+		this.lost = 1
+		this.c11 += this.pkt
+	} else {
+		this.lost++
+		if this.pkt == 0 {
+			this.c33++
+		} else {
+			this.c23++
+			this.c22 += this.pkt - 1
+		}
+	}
+	this.pkt = 0
+}
+
+func (this *VoIPMetrics) updateJitter(timestamp uint32, arrival *RTPTime) {
+	if arrival == nil || this.clockrate == 0 {
+		return
+	}
+	t := (float64(arrival.sec) + float64(arrival.microsec)/1000000.0) * this.clockrate
+	transit := t - float64(timestamp)
+	if this.hastransit {
+		d := math.Abs(transit - this.transit)
+		this.jitter += (d - this.jitter) / 16
+		this.jitterstat.add(d)
+	}
+	this.transit = transit
+	this.hastransit = true
+}
+
+func (this *VoIPMetrics) expected() uint32 {
+	if !this.started {
+		return 0
+	}
+	return this.maxseq - this.baseseq + 1
+}
+
+func (this *VoIPMetrics) lostPackets() uint32 {
+	if expected := this.expected(); expected > this.received {
+		return expected - this.received
+	}
+	return 0
+}
+
+/** Returns the number of packets expected from the first to the highest sequence number. */
+func (this *VoIPMetrics) GetExpectedPackets() uint32 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.expected()
+}
+
+/** Returns the number of packets that never arrived. */
+func (this *VoIPMetrics) GetLostPackets() uint32 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.lostPackets()
+}
+
+/** Returns the number of duplicate packets received. */
+func (this *VoIPMetrics) GetDuplicatePackets() uint32 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.duplicates
+}
+
+/** Returns the number of packets discarded by the jitter buffer. */
+func (this *VoIPMetrics) GetDiscardedPackets() uint32 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.discarded
+}
+
+func (this *VoIPMetrics) fraction(n uint32) float64 {
+	if expected := this.expected(); expected != 0 {
+		return float64(n) / float64(expected)
+	}
+	return 0
+}
+
+/** Returns the fraction of expected packets that were lost. */
+func (this *VoIPMetrics) GetLossRate() float64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.fraction(this.lostPackets())
+}
+
+/** Returns the fraction of expected packets that were discarded. */
+func (this *VoIPMetrics) GetDiscardRate() float64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.fraction(this.discarded)
+}
+
+/** The burst and gap metrics of RFC 3611 section 4.7.2: the densities are
+ *  fractions, the durations are in milliseconds.
+ */
+func (this *VoIPMetrics) burstMetrics() (burstdensity, gapdensity float64, burstduration, gapduration int) {
+	c31 := this.c13
+	c32 := this.c23
+	ctotal := this.c11 + this.c14 + this.c13 + this.c22 + this.c23 + c31 + c32 + this.c33
+
+	var p32, p23 float64
+	if c31+c32+this.c33 != 0 {
+		p32 = float64(c32) / float64(c31+c32+this.c33)
+	}
+	if this.c22+this.c23 < 1 {
+		p23 = 1
+	} else {
+		p23 = 1 - float64(this.c22)/float64(this.c22+this.c23)
+	}
+	if this.c23+this.c33 != 0 && p23+p32 != 0 {
+		burstdensity = p23 / (p23 + p32)
+	}
+	if this.c11+this.c14 != 0 {
+		gapdensity = float64(this.c14) / float64(this.c11+this.c14)
+	}
+
+	m := float64(this.duration)
+	if this.c13 == 0 {
+		// no burst has ended yet: everything so far is a single gap
+		gapduration = int(float64(this.c11+this.c14+this.pkt) * m)
+		return
+	}
+	gap := float64(this.c11+this.c14+this.c13) * m / float64(this.c13)
+	gapduration = int(gap)
+	burstduration = int(float64(ctotal)*m/float64(this.c13) - gap)
+	if burstduration < 0 {
+		burstduration = 0
+	}
+	return
+}
+
+/** Returns the fraction of packets lost or discarded within bursts. */
+func (this *VoIPMetrics) GetBurstDensity() float64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	density, _, _, _ := this.burstMetrics()
+	return density
+}
+
+/** Returns the fraction of packets lost or discarded within gaps. */
+func (this *VoIPMetrics) GetGapDensity() float64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	_, density, _, _ := this.burstMetrics()
+	return density
+}
+
+/** Returns the mean burst duration in milliseconds. */
+func (this *VoIPMetrics) GetBurstDuration() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	_, _, duration, _ := this.burstMetrics()
+	return duration
+}
+
+/** Returns the mean gap duration in milliseconds. */
+func (this *VoIPMetrics) GetGapDuration() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	_, _, _, duration := this.burstMetrics()
+	return duration
+}
+
+/** Returns the round trip delay in milliseconds. */
+func (this *VoIPMetrics) GetRoundTripDelay() uint16 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.roundtrip
+}
+
+/** Returns the interarrival jitter estimate of RFC 3550 in timestamp units. */
+func (this *VoIPMetrics) GetJitter() float64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.jitter
+}
+
+/** Computes the E-model rating. The listening quality variant leaves out the
+ *  delay impairment, the conversational one includes it.
+ */
+func (this *VoIPMetrics) rating(conversational bool) float64 {
+	ppl := 100 * this.fraction(this.lostPackets()+this.discarded)
+	if ppl > 100 {
+		ppl = 100
+	}
+
+	// BurstR is 1 for random loss and grows with burstiness (ITU-T G.107 section 3.7)
+	burstr := 1.0
+	if this.nfound != 0 && this.nlost != 0 {
+		p := float64(this.tolost) / float64(this.nfound)
+		q := float64(this.tofound) / float64(this.nlost)
+		if p+q != 0 {
+			burstr = math.Max(1, 1/(p+q))
+		}
+	}
+	ieeff := this.ie
+	if ppl != 0 {
+		ieeff += (95 - this.ie) * ppl / (ppl/burstr + this.bpl)
+	}
+
+	r := 93.2 - ieeff
+	if conversational {
+		d := float64(this.roundtrip)/2 + float64(this.endsystem)
+		id := 0.024 * d
+		if d > 177.3 {
+			id += 0.11 * (d - 177.3)
+		}
+		r -= id
+	}
+	return math.Max(0, math.Min(100, r))
+}
+
+/** Converts an E-model R factor to a MOS (ITU-T G.107 Annex B). */
+func RFactorToMOS(r float64) float64 {
+	if r <= 0 {
+		return 1
+	}
+	if r >= 100 {
+		return 4.5
+	}
+	return 1 + 0.035*r + r*(r-60)*(100-r)*7e-6
+}
+
+/** Returns the R factor including the delay impairment. */
+func (this *VoIPMetrics) GetRFactor() float64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.rating(true)
+}
+
+/** Returns the listening quality MOS, which only accounts for loss and codec. */
+func (this *VoIPMetrics) GetMOSLQ() float64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return RFactorToMOS(this.rating(false))
+}
+
+/** Returns the conversational quality MOS, which also accounts for delay. */
+func (this *VoIPMetrics) GetMOSCQ() float64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return RFactorToMOS(this.rating(true))
+}
+
+func scale256(f float64) uint8 {
+	return uint8(math.Min(255, f*256))
+}
+
+func clampuint16(v int) uint16 {
+	if v > 0xFFFF {
+		return 0xFFFF
+	}
+	return uint16(v)
+}
+
+/** Returns a VoIP Metrics block describing the current state. The levels and
+ *  jitter buffer fields are left for the caller to fill in.
+ */
+func (this *VoIPMetrics) GetVoIPMetricsBlock() *RTCPXRVoIPMetricsBlock {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	block := NewRTCPXRVoIPMetricsBlock(this.ssrc)
+	if !this.started {
+		return block
+	}
+	burstdensity, gapdensity, burstduration, gapduration := this.burstMetrics()
+	block.lossrate = scale256(this.fraction(this.lostPackets()))
+	block.discardrate = scale256(this.fraction(this.discarded))
+	block.burstdensity = scale256(burstdensity)
+	block.gapdensity = scale256(gapdensity)
+	block.burstduration = clampuint16(burstduration)
+	block.gapduration = clampuint16(gapduration)
+	block.roundtrip = this.roundtrip
+	block.endsystem = this.endsystem
+	block.gmin = uint8(this.gmin)
+	block.rfactor = uint8(this.rating(true) + 0.5)
+	block.moslq = uint8(RFactorToMOS(this.rating(false))*10 + 0.5)
+	block.moscq = uint8(RFactorToMOS(this.rating(true))*10 + 0.5)
+	return block
+}
+
+/** Returns a Statistics Summary block covering all packets seen so far. The
+ *  jitter values are in timestamp units.
+ */
+func (this *VoIPMetrics) GetStatisticsSummaryBlock() *RTCPXRStatisticsSummaryBlock {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	block := NewRTCPXRStatisticsSummaryBlock(this.ssrc, uint16(this.baseseq), uint16(this.maxseq+1))
+	if !this.started {
+		return block
+	}
+	block.SetLostPackets(this.lostPackets())
+	block.SetDuplicatePackets(this.duplicates)
+	if this.jitterstat.count != 0 {
+		s := &this.jitterstat
+		block.SetJitter(uint32(s.min), uint32(s.max), uint32(s.mean()), uint32(s.dev()))
+	}
+	return block
+}
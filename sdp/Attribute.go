@@ -0,0 +1,68 @@
+package sdp
+
+import (
+	"errors"
+	"strings"
+)
+
+/**
+ * An attribute field ("a=") of a session or media description.
+ * Property attributes have no value (e.g. "a=recvonly"), value attributes
+ * carry everything after the first colon (e.g. "a=rtpmap:0 PCMU/8000").
+ */
+type Attribute struct {
+	name  string
+	value string
+}
+
+/** Creates a new attribute. An empty value makes it a property attribute.
+ */
+func NewAttribute(name, value string) *Attribute {
+	return &Attribute{name: name, value: value}
+}
+
+/** Parses the text following "a=".
+ */
+func ParseAttribute(s string) (*Attribute, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("SdpParseException: empty attribute")
+	}
+	if i := strings.Index(s, ":"); i != -1 {
+		return &Attribute{name: s[:i], value: s[i+1:]}, nil
+	}
+	return &Attribute{name: s}, nil
+}
+
+/** Returns the name of this attribute.
+ */
+func (this *Attribute) GetName() string {
+	return this.name
+}
+
+/** Returns the value of this attribute or an empty string for property attributes.
+ */
+func (this *Attribute) GetValue() string {
+	return this.value
+}
+
+/** Sets the value of this attribute.
+ */
+func (this *Attribute) SetValue(value string) {
+	this.value = value
+}
+
+/** Returns true if this attribute has a value.
+ */
+func (this *Attribute) HasValue() bool {
+	return this.value != ""
+}
+
+/** Encodes the attribute without the leading "a=".
+ */
+func (this *Attribute) String() string {
+	if this.value == "" {
+		return this.name
+	}
+	return this.name + ":" + this.value
+}
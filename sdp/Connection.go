@@ -0,0 +1,71 @@
+package sdp
+
+import (
+	"errors"
+	"strings"
+)
+
+/**
+ * The connection data field ("c=") of a session or media description.
+ */
+type Connection struct {
+	netType  string
+	addrType string
+	address  string
+}
+
+/** Creates a connection field for an IN network address. The address type is
+ * derived from the address.
+ */
+func NewConnection(address string) *Connection {
+	addrType := "IP4"
+	if strings.Contains(address, ":") {
+		addrType = "IP6"
+	}
+	return &Connection{netType: "IN", addrType: addrType, address: address}
+}
+
+/** Parses the text following "c=".
+ */
+func ParseConnection(s string) (*Connection, error) {
+	parts := strings.Fields(s)
+	if len(parts) != 3 {
+		return nil, errors.New("SdpParseException: bad connection field " + s)
+	}
+	return &Connection{netType: parts[0], addrType: parts[1], address: parts[2]}, nil
+}
+
+/** Returns the network type, normally "IN".
+ */
+func (this *Connection) GetNetworkType() string {
+	return this.netType
+}
+
+/** Returns the address type, "IP4" or "IP6".
+ */
+func (this *Connection) GetAddressType() string {
+	return this.addrType
+}
+
+/** Returns the connection address.
+ */
+func (this *Connection) GetAddress() string {
+	return this.address
+}
+
+/** Sets the connection address and updates the address type to match.
+ */
+func (this *Connection) SetAddress(address string) {
+	this.address = address
+	if strings.Contains(address, ":") {
+		this.addrType = "IP6"
+	} else {
+		this.addrType = "IP4"
+	}
+}
+
+/** Encodes the connection field without the leading "c=".
+ */
+func (this *Connection) String() string {
+	return this.netType + " " + this.addrType + " " + this.address
+}
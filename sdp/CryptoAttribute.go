@@ -0,0 +1,245 @@
+package sdp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const CRYPTO_ATTRIBUTE = "crypto"
+
+/**
+ * One key parameter of a crypto attribute (RFC 4568 section 9.2):
+ *
+ *   inline:<base64 key||salt>[|<lifetime>][|<MKI>:<MKI length>]
+ *
+ * A lifetime of zero means none was given. The MKI length is zero when no
+ * MKI is used.
+ */
+type KeyParam struct {
+	key       []byte
+	salt      []byte
+	lifetime  uint64
+	mki       uint64
+	mkiLength int
+}
+
+/** Creates an inline key parameter without lifetime or MKI.
+ */
+func NewKeyParam(key, salt []byte) *KeyParam {
+	return &KeyParam{key: key, salt: salt}
+}
+
+/** Parses a key parameter. keyLength is the master key length of the crypto
+ * suite, used to split the inline key||salt value.
+ */
+func ParseKeyParam(s string, keyLength int) (*KeyParam, error) {
+	if !strings.HasPrefix(s, "inline:") {
+		return nil, errors.New("SdpParseException: unsupported key method " + s)
+	}
+	parts := strings.Split(s[len("inline:"):], "|")
+	keysalt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		if keysalt, err = base64.RawStdEncoding.DecodeString(parts[0]); err != nil {
+			return nil, errors.New("SdpParseException: bad key " + parts[0])
+		}
+	}
+	if len(keysalt) <= keyLength {
+		return nil, errors.New("SdpParseException: key too short")
+	}
+
+	this := &KeyParam{key: keysalt[:keyLength], salt: keysalt[keyLength:]}
+	for _, part := range parts[1:] {
+		if i := strings.Index(part, ":"); i != -1 {
+			if this.mki, err = strconv.ParseUint(part[:i], 10, 64); err != nil {
+				return nil, errors.New("SdpParseException: bad MKI " + part)
+			}
+			if this.mkiLength, err = strconv.Atoi(part[i+1:]); err != nil || this.mkiLength < 1 || this.mkiLength > 128 {
+				return nil, errors.New("SdpParseException: bad MKI length " + part)
+			}
+		} else if strings.HasPrefix(part, "2^") {
+			exp, err := strconv.Atoi(part[2:])
+			if err != nil || exp < 0 || exp > 63 {
+				return nil, errors.New("SdpParseException: bad lifetime " + part)
+			}
+			this.lifetime = uint64(1) << uint(exp)
+		} else {
+			if this.lifetime, err = strconv.ParseUint(part, 10, 64); err != nil {
+				return nil, errors.New("SdpParseException: bad lifetime " + part)
+			}
+		}
+	}
+	return this, nil
+}
+
+/** Returns the master key.
+ */
+func (this *KeyParam) GetKey() []byte {
+	return this.key
+}
+
+/** Returns the master salt.
+ */
+func (this *KeyParam) GetSalt() []byte {
+	return this.salt
+}
+
+/** Returns the master key lifetime in packets, zero if unspecified.
+ */
+func (this *KeyParam) GetLifetime() uint64 {
+	return this.lifetime
+}
+
+/** Sets the master key lifetime in packets, zero for none.
+ */
+func (this *KeyParam) SetLifetime(lifetime uint64) {
+	this.lifetime = lifetime
+}
+
+/** Returns the MKI value and its length in bytes (zero if no MKI is used).
+ */
+func (this *KeyParam) GetMKI() (uint64, int) {
+	return this.mki, this.mkiLength
+}
+
+/** Sets the MKI value and its length in bytes.
+ */
+func (this *KeyParam) SetMKI(mki uint64, length int) {
+	this.mki = mki
+	this.mkiLength = length
+}
+
+/** Returns the MKI as it is carried in SRTP packets, or nil if none is used.
+ */
+func (this *KeyParam) GetMKIBytes() []byte {
+	if this.mkiLength == 0 {
+		return nil
+	}
+	b := make([]byte, this.mkiLength)
+	v := this.mki
+	for i := this.mkiLength - 1; i >= 0 && v != 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b
+}
+
+/** Encodes the key parameter.
+ */
+func (this *KeyParam) String() string {
+	s := "inline:" + base64.StdEncoding.EncodeToString(append(append([]byte{}, this.key...), this.salt...))
+	if this.lifetime != 0 {
+		if this.lifetime&(this.lifetime-1) == 0 {
+			exp := 0
+			for l := this.lifetime; l > 1; l >>= 1 {
+				exp++
+			}
+			s += "|2^" + strconv.Itoa(exp)
+		} else {
+			s += "|" + strconv.FormatUint(this.lifetime, 10)
+		}
+	}
+	if this.mkiLength != 0 {
+		s += "|" + strconv.FormatUint(this.mki, 10) + ":" + strconv.Itoa(this.mkiLength)
+	}
+	return s
+}
+
+/**
+ * The SDP Security Descriptions crypto attribute (RFC 4568):
+ *
+ *   a=crypto:<tag> <crypto-suite> <key-params> [<session-params>]
+ */
+type CryptoAttribute struct {
+	tag           int
+	suite         string
+	keyParams     []*KeyParam
+	sessionParams []string
+}
+
+/** Creates a crypto attribute with a single key parameter.
+ */
+func NewCryptoAttribute(tag int, suite string, keyParam *KeyParam) *CryptoAttribute {
+	return &CryptoAttribute{tag: tag, suite: suite, keyParams: []*KeyParam{keyParam}}
+}
+
+/** Parses the value of a crypto attribute. keyLength is the master key length
+ * of the suite; a key length of zero parses everything but the key parameters.
+ */
+func ParseCryptoAttribute(value string, keyLength int) (*CryptoAttribute, error) {
+	parts := strings.Fields(value)
+	if len(parts) < 3 {
+		return nil, errors.New("SdpParseException: bad crypto attribute " + value)
+	}
+	tag, err := strconv.Atoi(parts[0])
+	if err != nil || tag < 0 || tag > 999999999 {
+		return nil, errors.New("SdpParseException: bad crypto tag " + parts[0])
+	}
+	this := &CryptoAttribute{tag: tag, suite: parts[1], sessionParams: parts[3:]}
+	if keyLength == 0 {
+		return this, nil
+	}
+	for _, kp := range strings.Split(parts[2], ";") {
+		keyParam, err := ParseKeyParam(kp, keyLength)
+		if err != nil {
+			return nil, err
+		}
+		this.keyParams = append(this.keyParams, keyParam)
+	}
+	return this, nil
+}
+
+/** Returns the tag which pairs offered and answered crypto attributes.
+ */
+func (this *CryptoAttribute) GetTag() int {
+	return this.tag
+}
+
+/** Returns the crypto suite name, e.g. "AES_CM_128_HMAC_SHA1_80".
+ */
+func (this *CryptoAttribute) GetSuite() string {
+	return this.suite
+}
+
+/** Returns the key parameters.
+ */
+func (this *CryptoAttribute) GetKeyParams() []*KeyParam {
+	return this.keyParams
+}
+
+/** Returns the session parameters, e.g. "KDR=20".
+ */
+func (this *CryptoAttribute) GetSessionParams() []string {
+	return this.sessionParams
+}
+
+/** Adds a session parameter.
+ */
+func (this *CryptoAttribute) AddSessionParam(param string) {
+	this.sessionParams = append(this.sessionParams, param)
+}
+
+/** Returns the value of the crypto attribute.
+ */
+func (this *CryptoAttribute) String() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(strconv.Itoa(this.tag) + " " + this.suite + " ")
+	for i, kp := range this.keyParams {
+		if i > 0 {
+			buffer.WriteString(";")
+		}
+		buffer.WriteString(kp.String())
+	}
+	for _, sp := range this.sessionParams {
+		buffer.WriteString(" " + sp)
+	}
+	return buffer.String()
+}
+
+/** Returns this crypto attribute as an SDP attribute.
+ */
+func (this *CryptoAttribute) GetAttribute() *Attribute {
+	return NewAttribute(CRYPTO_ATTRIBUTE, this.String())
+}
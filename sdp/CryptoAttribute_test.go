@@ -0,0 +1,88 @@
+package sdp
+
+import (
+	"bytes"
+	"gosips/rtp"
+	"testing"
+)
+
+func TestCryptoAttribute(t *testing.T) {
+	var tvi = []string{
+		"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4",
+		"2 AES_CM_128_HMAC_SHA1_32 inline:NzB4d1BINUAvLEw6UzF3WSJ+PSdFcGdUJShpX1Zj|2^20|1:32 KDR=1",
+		"1 AES_CM_128_HMAC_SHA1_80 inline:d0RmdmcmVCspeEc3QGZiNWpVLFJhQX1cfHAwJSoj|1048576",
+		"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR;inline:NzB4d1BINUAvLEw6UzF3WSJ+PSdFcGdUJShpX1Zj",
+	}
+	var tvo = []string{
+		"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4",
+		"2 AES_CM_128_HMAC_SHA1_32 inline:NzB4d1BINUAvLEw6UzF3WSJ+PSdFcGdUJShpX1Zj|2^20|1:32 KDR=1",
+		"1 AES_CM_128_HMAC_SHA1_80 inline:d0RmdmcmVCspeEc3QGZiNWpVLFJhQX1cfHAwJSoj|2^20",
+		"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR;inline:NzB4d1BINUAvLEw6UzF3WSJ+PSdFcGdUJShpX1Zj",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		crypto, err := ParseCryptoAttribute(tvi[i], 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if crypto.String() != tvo[i] {
+			t.Log("golden = " + tvo[i])
+			t.Log("failed = " + crypto.String())
+			t.Fail()
+		}
+	}
+
+	kp := NewKeyParam([]byte{1, 2}, []byte{3})
+	kp.SetMKI(258, 3)
+	if !bytes.Equal(kp.GetMKIBytes(), []byte{0, 1, 2}) {
+		t.Errorf("MKI bytes %v", kp.GetMKIBytes())
+	}
+}
+
+func TestSDESOfferAnswer(t *testing.T) {
+	offerer := NewSDESNegotiator()
+	answerer := NewSDESNegotiator(rtp.SRTP_AES128_CM_HMAC_SHA1_80)
+
+	offer := NewMediaDescription("audio", 4000, "RTP/SAVP", "0")
+	offered, err := offerer.CreateOffer(offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer := NewMediaDescription("audio", 5000, "RTP/SAVP", "0")
+	as, err := answerer.CreateAnswer(offer, answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os, err := offerer.ProcessAnswer(offered, answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if as.GetProfile() != rtp.SRTP_AES128_CM_HMAC_SHA1_80 || os.GetProfile() != as.GetProfile() {
+		t.Errorf("negotiated %s and %s", as.GetProfile(), os.GetProfile())
+	}
+
+	packet := rtp.NewPacket(0, []byte("hello"), 1, 160, 1234, false, 0, nil, false, 0, 0, nil)
+	srtp, err := os.GetOutboundContext().ProtectRTP(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := as.GetInboundContext().UnprotectRTP(rtp.NewRawPacket(srtp, nil, rtp.CurrentRTPTime(), true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.GetPayload()) != "hello" {
+		t.Errorf("payload %q", got.GetPayload())
+	}
+
+	insecure := NewMediaDescription("audio", 4000, "RTP/AVP", "0")
+	insecure.AddAttribute(offered[0].GetAttribute())
+	if _, err = answerer.CreateAnswer(insecure, NewMediaDescription("audio", 5000, "RTP/AVP", "0")); err == nil {
+		t.Error("crypto on RTP/AVP accepted")
+	}
+	answerer.SetAllowInsecureProto(true)
+	insecure.RemoveAttribute(CRYPTO_ATTRIBUTE)
+	insecure.AddAttribute(offered[2].GetAttribute())
+	if _, err = answerer.CreateAnswer(insecure, NewMediaDescription("audio", 5000, "RTP/AVP", "0")); err != nil {
+		t.Error(err)
+	}
+}
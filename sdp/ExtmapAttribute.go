@@ -0,0 +1,243 @@
+package sdp
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const EXTMAP_ATTRIBUTE = "extmap"
+const EXTMAP_ALLOW_MIXED_ATTRIBUTE = "extmap-allow-mixed"
+
+/**
+ * The extmap attribute of RFC 8285 section 8, which maps a local identifier to
+ * an RTP header extension:
+ *
+ *   a=extmap:<value>["/"<direction>] <URI> <extensionattributes>
+ */
+type ExtmapAttribute struct {
+	id         int
+	direction  string
+	uri        string
+	attributes string
+}
+
+/** Creates an extmap attribute without direction.
+ */
+func NewExtmapAttribute(id int, uri string) *ExtmapAttribute {
+	return &ExtmapAttribute{id: id, uri: uri}
+}
+
+/** Parses the value of an extmap attribute.
+ */
+func ParseExtmapAttribute(value string) (*ExtmapAttribute, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), " ", 3)
+	if len(parts) < 2 {
+		return nil, errors.New("SdpParseException: bad extmap attribute " + value)
+	}
+	this := &ExtmapAttribute{uri: parts[1]}
+	id := parts[0]
+	if i := strings.Index(id, "/"); i != -1 {
+		this.direction = id[i+1:]
+		id = id[:i]
+		switch this.direction {
+		case "sendrecv", "sendonly", "recvonly", "inactive":
+		default:
+			return nil, errors.New("SdpParseException: bad extmap direction " + this.direction)
+		}
+	}
+	var err error
+	if this.id, err = strconv.Atoi(id); err != nil || this.id < 1 || this.id > 255 {
+		return nil, errors.New("SdpParseException: bad extmap identifier " + id)
+	}
+	if len(parts) == 3 {
+		this.attributes = parts[2]
+	}
+	return this, nil
+}
+
+/** Returns the local identifier used in RTP packets.
+ */
+func (this *ExtmapAttribute) GetID() int {
+	return this.id
+}
+
+/** Returns the direction, or "" if none is given (meaning sendrecv).
+ */
+func (this *ExtmapAttribute) GetDirection() string {
+	return this.direction
+}
+
+/** Sets the direction; "" removes it.
+ */
+func (this *ExtmapAttribute) SetDirection(direction string) {
+	this.direction = direction
+}
+
+/** Returns the URI naming the header extension.
+ */
+func (this *ExtmapAttribute) GetURI() string {
+	return this.uri
+}
+
+/** Returns the extension attributes, or "".
+ */
+func (this *ExtmapAttribute) GetExtensionAttributes() string {
+	return this.attributes
+}
+
+/** Returns the value of the extmap attribute.
+ */
+func (this *ExtmapAttribute) String() string {
+	s := strconv.Itoa(this.id)
+	if this.direction != "" {
+		s += "/" + this.direction
+	}
+	s += " " + this.uri
+	if this.attributes != "" {
+		s += " " + this.attributes
+	}
+	return s
+}
+
+/** Returns this extmap attribute as an SDP attribute.
+ */
+func (this *ExtmapAttribute) GetAttribute() *Attribute {
+	return NewAttribute(EXTMAP_ATTRIBUTE, this.String())
+}
+
+/** Returns the extmap attributes of a media description, followed by those of
+ * the session level if sd is not nil. Unparsable attributes are skipped.
+ */
+func GetExtmapAttributes(sd *SessionDescription, md *MediaDescription) []*ExtmapAttribute {
+	attributes := md.GetAttributes(EXTMAP_ATTRIBUTE)
+	if sd != nil {
+		attributes = append(attributes, sd.GetAttributes(EXTMAP_ATTRIBUTE)...)
+	}
+	var extmaps []*ExtmapAttribute
+	for _, a := range attributes {
+		if extmap, err := ParseExtmapAttribute(a.GetValue()); err == nil {
+			extmaps = append(extmaps, extmap)
+		}
+	}
+	return extmaps
+}
+
+/** Returns the extmap for the given URI or nil.
+ */
+func FindExtmap(extmaps []*ExtmapAttribute, uri string) *ExtmapAttribute {
+	for _, extmap := range extmaps {
+		if extmap.uri == uri {
+			return extmap
+		}
+	}
+	return nil
+}
+
+/**
+ * Negotiates RTP header extensions as described in RFC 8285 section 6. The
+ * offerer chooses the identifiers; the answerer accepts a subset of the offered
+ * extensions with the same identifiers.
+ */
+type ExtmapNegotiator struct {
+	uris       []string
+	allowMixed bool
+}
+
+/** Creates a negotiator for the header extensions with the given URIs, in the
+ * order they are offered.
+ */
+func NewExtmapNegotiator(uris ...string) *ExtmapNegotiator {
+	return &ExtmapNegotiator{uris: uris}
+}
+
+/** Sets whether one-byte and two-byte header extensions may be mixed in a
+ * stream ("a=extmap-allow-mixed"), which allows identifiers above 14.
+ */
+func (this *ExtmapNegotiator) SetAllowMixed(allow bool) {
+	this.allowMixed = allow
+}
+
+func (this *ExtmapNegotiator) supports(uri string) bool {
+	for _, u := range this.uris {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
+
+/** Adds an extmap attribute for every supported extension to the media
+ * description of an offer and returns them. Identifiers are assigned from 1
+ * and stay within the one-byte range unless mixing is allowed.
+ */
+func (this *ExtmapNegotiator) CreateOffer(md *MediaDescription) ([]*ExtmapAttribute, error) {
+	md.RemoveAttribute(EXTMAP_ATTRIBUTE)
+	if this.allowMixed {
+		md.SetAttribute(NewAttribute(EXTMAP_ALLOW_MIXED_ATTRIBUTE, ""))
+	}
+	var offered []*ExtmapAttribute
+	id := 1
+	for _, uri := range this.uris {
+		if id > 14 && !this.allowMixed || id > 255 {
+			return nil, errors.New("SdpException: too many header extensions")
+		}
+		extmap := NewExtmapAttribute(id, uri)
+		md.AddAttribute(extmap.GetAttribute())
+		offered = append(offered, extmap)
+		id++
+	}
+	return offered, nil
+}
+
+/** Returns the direction that answers an offered direction.
+ */
+func answerDirection(direction string) string {
+	switch direction {
+	case "sendonly":
+		return "recvonly"
+	case "recvonly":
+		return "sendonly"
+	}
+	return direction
+}
+
+/** Accepts the supported extensions of an offered media description: they are
+ * added to the answer with the offered identifiers and returned. mixed tells
+ * whether both sides allow mixing one-byte and two-byte extensions.
+ */
+func (this *ExtmapNegotiator) CreateAnswer(offer, answer *MediaDescription) (accepted []*ExtmapAttribute, mixed bool) {
+	mixed = this.allowMixed && offer.HasAttribute(EXTMAP_ALLOW_MIXED_ATTRIBUTE)
+	answer.RemoveAttribute(EXTMAP_ATTRIBUTE)
+	answer.RemoveAttribute(EXTMAP_ALLOW_MIXED_ATTRIBUTE)
+	if mixed {
+		answer.AddAttribute(NewAttribute(EXTMAP_ALLOW_MIXED_ATTRIBUTE, ""))
+	}
+	for _, extmap := range GetExtmapAttributes(nil, offer) {
+		if !this.supports(extmap.uri) || extmap.id > 14 && !mixed {
+			continue
+		}
+		local := &ExtmapAttribute{id: extmap.id, direction: answerDirection(extmap.direction), uri: extmap.uri}
+		answer.AddAttribute(local.GetAttribute())
+		accepted = append(accepted, local)
+	}
+	return accepted, mixed
+}
+
+/** Returns the extensions in use once the answer to our offer arrived: those
+ * we offered that the answer accepted with the same identifier.
+ */
+func (this *ExtmapNegotiator) ProcessAnswer(offered []*ExtmapAttribute, answer *MediaDescription) (accepted []*ExtmapAttribute, mixed bool, err error) {
+	mixed = this.allowMixed && answer.HasAttribute(EXTMAP_ALLOW_MIXED_ATTRIBUTE)
+	for _, extmap := range GetExtmapAttributes(nil, answer) {
+		local := FindExtmap(offered, extmap.uri)
+		if local == nil {
+			return nil, false, errors.New("SdpException: answer added header extension " + extmap.uri)
+		}
+		if local.id != extmap.id {
+			return nil, false, errors.New("SdpException: answer changed the identifier of " + extmap.uri)
+		}
+		accepted = append(accepted, extmap)
+	}
+	return accepted, mixed, nil
+}
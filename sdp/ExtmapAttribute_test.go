@@ -0,0 +1,59 @@
+package sdp
+
+import (
+	"gosips/rtp"
+	"testing"
+)
+
+func TestExtmapAttribute(t *testing.T) {
+	var tvi = []string{
+		"1 urn:ietf:params:rtp-hdrext:ssrc-audio-level",
+		"2/sendonly urn:ietf:params:rtp-hdrext:toffset",
+		"3 urn:ietf:params:rtp-hdrext:ssrc-audio-level vad=on",
+	}
+	for i := 0; i < len(tvi); i++ {
+		extmap, err := ParseExtmapAttribute(tvi[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if extmap.String() != tvi[i] {
+			t.Log("golden = " + tvi[i])
+			t.Log("failed = " + extmap.String())
+			t.Fail()
+		}
+	}
+	// 15 is only reserved in the one-byte form
+	if extmap, err := ParseExtmapAttribute("15 urn:x"); err != nil || extmap.GetID() != 15 {
+		t.Error("two-byte identifier 15 rejected")
+	}
+	for _, bad := range []string{"0 urn:x", "256 urn:x", "1/sideways urn:x", "1"} {
+		if _, err := ParseExtmapAttribute(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestExtmapOfferAnswer(t *testing.T) {
+	offerer := NewExtmapNegotiator(rtp.RTP_EXTENSION_AUDIOLEVEL_URI, rtp.RTP_EXTENSION_ABSSENDTIME_URI, rtp.RTP_EXTENSION_TRANSPORTCC_URI)
+	answerer := NewExtmapNegotiator(rtp.RTP_EXTENSION_TRANSPORTCC_URI, rtp.RTP_EXTENSION_AUDIOLEVEL_URI)
+
+	offer := NewMediaDescription("audio", 4000, "RTP/AVP", "0")
+	offered, err := offerer.CreateOffer(offer)
+	if err != nil || len(offered) != 3 {
+		t.Fatal(err)
+	}
+	answer := NewMediaDescription("audio", 5000, "RTP/AVP", "0")
+	accepted, mixed := answerer.CreateAnswer(offer, answer)
+	if len(accepted) != 2 || mixed || FindExtmap(accepted, rtp.RTP_EXTENSION_TRANSPORTCC_URI).GetID() != 3 {
+		t.Errorf("answer %q", answer.String())
+	}
+	used, _, err := offerer.ProcessAnswer(offered, answer)
+	if err != nil || len(used) != 2 || FindExtmap(used, rtp.RTP_EXTENSION_ABSSENDTIME_URI) != nil {
+		t.Errorf("offerer uses %v: %v", used, err)
+	}
+
+	answer.SetAttribute(NewExtmapAttribute(4, rtp.RTP_EXTENSION_AUDIOLEVEL_URI).GetAttribute())
+	if _, _, err = offerer.ProcessAnswer(offered, answer); err == nil {
+		t.Error("changed identifier accepted")
+	}
+}
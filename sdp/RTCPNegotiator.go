@@ -0,0 +1,58 @@
+package sdp
+
+const RTCP_ATTRIBUTE = "rtcp"
+const RTCP_MUX_ATTRIBUTE = "rtcp-mux"
+const RTCP_RSIZE_ATTRIBUTE = "rtcp-rsize"
+
+/**
+ * Negotiates RTP/RTCP multiplexing (RFC 5761 section 5.1.1) and reduced-size
+ * RTCP (RFC 5506 section 5) for a media stream. Each option is only used if
+ * it was offered and the answerer agreed to it.
+ */
+type RTCPNegotiator struct {
+	mux         bool
+	reducedSize bool
+}
+
+/** Creates a negotiator willing to use the given options.
+ */
+func NewRTCPNegotiator(mux, reducedSize bool) *RTCPNegotiator {
+	return &RTCPNegotiator{mux: mux, reducedSize: reducedSize}
+}
+
+/** Adds the attributes for the options we are willing to use to the media
+ * description of an offer.
+ */
+func (this *RTCPNegotiator) CreateOffer(md *MediaDescription) {
+	if this.mux {
+		md.SetAttribute(NewAttribute(RTCP_MUX_ATTRIBUTE, ""))
+	}
+	if this.reducedSize {
+		md.SetAttribute(NewAttribute(RTCP_RSIZE_ATTRIBUTE, ""))
+	}
+}
+
+/** Answers an offered media description: the options that were offered and
+ * that we are willing to use are added to the answer and returned.
+ */
+func (this *RTCPNegotiator) CreateAnswer(offer, answer *MediaDescription) (mux, reducedSize bool) {
+	mux = this.mux && offer.HasAttribute(RTCP_MUX_ATTRIBUTE)
+	reducedSize = this.reducedSize && offer.HasAttribute(RTCP_RSIZE_ATTRIBUTE)
+	answer.RemoveAttribute(RTCP_MUX_ATTRIBUTE)
+	answer.RemoveAttribute(RTCP_RSIZE_ATTRIBUTE)
+	if mux {
+		answer.AddAttribute(NewAttribute(RTCP_MUX_ATTRIBUTE, ""))
+	}
+	if reducedSize {
+		answer.AddAttribute(NewAttribute(RTCP_RSIZE_ATTRIBUTE, ""))
+	}
+	return mux, reducedSize
+}
+
+/** Returns the options in effect once the answer to our offer arrived.
+ */
+func (this *RTCPNegotiator) ProcessAnswer(answer *MediaDescription) (mux, reducedSize bool) {
+	mux = this.mux && answer.HasAttribute(RTCP_MUX_ATTRIBUTE)
+	reducedSize = this.reducedSize && answer.HasAttribute(RTCP_RSIZE_ATTRIBUTE)
+	return mux, reducedSize
+}
@@ -0,0 +1,29 @@
+package sdp
+
+import "testing"
+
+func TestRTCPNegotiation(t *testing.T) {
+	offerer := NewRTCPNegotiator(true, true)
+	offer := NewMediaDescription("audio", 4000, "RTP/AVP", "0")
+	offerer.CreateOffer(offer)
+	if offer.String() != "m=audio 4000 RTP/AVP 0\r\na=rtcp-mux\r\na=rtcp-rsize\r\n" {
+		t.Errorf("offer %q", offer.String())
+	}
+
+	// an answerer that supports multiplexing only
+	answer := NewMediaDescription("audio", 5000, "RTP/AVP", "0")
+	mux, rsize := NewRTCPNegotiator(true, false).CreateAnswer(offer, answer)
+	if !mux || rsize || !answer.HasAttribute(RTCP_MUX_ATTRIBUTE) || answer.HasAttribute(RTCP_RSIZE_ATTRIBUTE) {
+		t.Errorf("answer %q", answer.String())
+	}
+	if mux, rsize = offerer.ProcessAnswer(answer); !mux || rsize {
+		t.Errorf("offerer got mux %v rsize %v", mux, rsize)
+	}
+
+	// nothing is used that was not offered
+	plain := NewMediaDescription("audio", 4000, "RTP/AVP", "0")
+	answer = NewMediaDescription("audio", 5000, "RTP/AVP", "0")
+	if mux, rsize = NewRTCPNegotiator(true, true).CreateAnswer(plain, answer); mux || rsize || len(answer.GetAttributes("")) != 0 {
+		t.Errorf("answer %q", answer.String())
+	}
+}
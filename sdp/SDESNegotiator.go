@@ -0,0 +1,290 @@
+package sdp
+
+import (
+	"crypto/rand"
+	"errors"
+	"gosips/rtp"
+	"strconv"
+	"strings"
+)
+
+/**
+ * The result of negotiating SDP Security Descriptions for one media stream:
+ * the chosen crypto suite and the SRTP contexts keyed with the local and the
+ * remote master key.
+ */
+type SDESSession struct {
+	profile  rtp.SRTPProfile
+	local    *CryptoAttribute
+	remote   *CryptoAttribute
+	outbound *rtp.SRTPContext
+	inbound  *rtp.SRTPContext
+}
+
+/** Returns the negotiated crypto suite.
+ */
+func (this *SDESSession) GetProfile() rtp.SRTPProfile {
+	return this.profile
+}
+
+/** Returns the crypto attribute carrying our own key.
+ */
+func (this *SDESSession) GetLocalCrypto() *CryptoAttribute {
+	return this.local
+}
+
+/** Returns the crypto attribute carrying the peer's key.
+ */
+func (this *SDESSession) GetRemoteCrypto() *CryptoAttribute {
+	return this.remote
+}
+
+/** Returns the context that protects the packets we send.
+ */
+func (this *SDESSession) GetOutboundContext() *rtp.SRTPContext {
+	return this.outbound
+}
+
+/** Returns the context that unprotects the packets we receive.
+ */
+func (this *SDESSession) GetInboundContext() *rtp.SRTPContext {
+	return this.inbound
+}
+
+/**
+ * Implements the SDES offer/answer procedures of RFC 4568 section 7.
+ * The negotiator offers and accepts the crypto suites it was created with,
+ * in that order of preference.
+ */
+type SDESNegotiator struct {
+	suites             []rtp.SRTPProfile
+	allowInsecureProto bool
+	lifetime           uint64
+	mkiLength          int
+}
+
+/** Creates a negotiator for the given suites. Without arguments all suites
+ * supported by the rtp package are used, strongest first.
+ */
+func NewSDESNegotiator(suites ...rtp.SRTPProfile) *SDESNegotiator {
+	if len(suites) == 0 {
+		suites = []rtp.SRTPProfile{
+			rtp.SRTP_AEAD_AES_256_GCM,
+			rtp.SRTP_AEAD_AES_128_GCM,
+			rtp.SRTP_AES128_CM_HMAC_SHA1_80,
+			rtp.SRTP_AES128_CM_HMAC_SHA1_32,
+		}
+	}
+	return &SDESNegotiator{suites: suites}
+}
+
+/** Sets whether crypto attributes are accepted on a non-secure transport
+ * profile such as RTP/AVP. This is off by default.
+ */
+func (this *SDESNegotiator) SetAllowInsecureProto(allow bool) {
+	this.allowInsecureProto = allow
+}
+
+/** Sets the lifetime advertised with our keys, zero for none.
+ */
+func (this *SDESNegotiator) SetLifetime(lifetime uint64) {
+	this.lifetime = lifetime
+}
+
+/** Sets the MKI length in bytes of our keys, zero for no MKI.
+ */
+func (this *SDESNegotiator) SetMKILength(length int) {
+	this.mkiLength = length
+}
+
+func (this *SDESNegotiator) supports(profile rtp.SRTPProfile) bool {
+	for _, p := range this.suites {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *SDESNegotiator) newKeyParam(profile rtp.SRTPProfile) (*KeyParam, error) {
+	keysalt := make([]byte, profile.KeyLength()+profile.SaltLength())
+	if _, err := rand.Read(keysalt); err != nil {
+		return nil, err
+	}
+	kp := NewKeyParam(keysalt[:profile.KeyLength()], keysalt[profile.KeyLength():])
+	kp.SetLifetime(this.lifetime)
+	if this.mkiLength != 0 {
+		kp.SetMKI(1, this.mkiLength)
+	}
+	return kp, nil
+}
+
+/** Adds one crypto attribute per supported suite to the media description of
+ * an offer and returns them; they must be passed back to ProcessAnswer.
+ */
+func (this *SDESNegotiator) CreateOffer(md *MediaDescription) ([]*CryptoAttribute, error) {
+	if !md.IsSecureProto() && !this.allowInsecureProto {
+		return nil, errors.New("SdpException: crypto not allowed with transport " + md.GetProto())
+	}
+	var offered []*CryptoAttribute
+	for i, profile := range this.suites {
+		kp, err := this.newKeyParam(profile)
+		if err != nil {
+			return nil, err
+		}
+		crypto := NewCryptoAttribute(i+1, profile.String(), kp)
+		md.AddAttribute(crypto.GetAttribute())
+		offered = append(offered, crypto)
+	}
+	return offered, nil
+}
+
+/** Checks the session parameters of a crypto attribute and returns the key
+ * derivation rate they ask for. Parameters we can not honour make the whole
+ * attribute unusable.
+ */
+func checkSessionParams(crypto *CryptoAttribute) (uint64, error) {
+	var kdr uint64
+	for _, sp := range crypto.GetSessionParams() {
+		if strings.HasPrefix(sp, "KDR=") {
+			n, err := strconv.Atoi(sp[len("KDR="):])
+			if err != nil || n < 0 || n > 24 {
+				return 0, errors.New("SdpException: bad KDR " + sp)
+			}
+			if n > 0 {
+				kdr = uint64(1) << uint(n)
+			}
+		} else {
+			return 0, errors.New("SdpException: unsupported session parameter " + sp)
+		}
+	}
+	return kdr, nil
+}
+
+/** Creates an SRTP context for the first key of a crypto attribute.
+ */
+func newSRTPContext(profile rtp.SRTPProfile, crypto *CryptoAttribute) (*rtp.SRTPContext, error) {
+	kdr, err := checkSessionParams(crypto)
+	if err != nil {
+		return nil, err
+	}
+	if len(crypto.GetKeyParams()) == 0 {
+		return nil, errors.New("SdpException: no key in crypto attribute")
+	}
+	kp := crypto.GetKeyParams()[0]
+	ctx, err := rtp.NewSRTPContext(profile, kp.GetKey(), kp.GetSalt())
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.SetKeyDerivationRate(kdr); err != nil {
+		return nil, err
+	}
+	ctx.SetMasterKeyLifetime(kp.GetLifetime())
+	ctx.SetMKI(kp.GetMKIBytes())
+	return ctx, nil
+}
+
+/** Parses a crypto attribute of a suite we support, returning nil for
+ * suites we do not know.
+ */
+func (this *SDESNegotiator) parseCrypto(a *Attribute) (rtp.SRTPProfile, *CryptoAttribute, error) {
+	header, err := ParseCryptoAttribute(a.GetValue(), 0)
+	if err != nil {
+		return 0, nil, err
+	}
+	profile, err := rtp.GetSRTPProfile(header.GetSuite())
+	if err != nil || !this.supports(profile) {
+		return 0, nil, nil
+	}
+	crypto, err := ParseCryptoAttribute(a.GetValue(), profile.KeyLength())
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, kp := range crypto.GetKeyParams() {
+		if len(kp.GetSalt()) != profile.SaltLength() {
+			return 0, nil, errors.New("SdpException: bad key length for " + crypto.GetSuite())
+		}
+	}
+	return profile, crypto, nil
+}
+
+/** Answers the crypto attributes of an offered media description. The first
+ * offered attribute with a supported suite and usable parameters is accepted
+ * and a crypto attribute with the same tag and a fresh key is added to the
+ * answer. A nil session and error means the offer uses plain RTP.
+ * An error means the stream must be rejected (e.g. with 488).
+ */
+func (this *SDESNegotiator) CreateAnswer(offer, answer *MediaDescription) (*SDESSession, error) {
+	attributes := offer.GetAttributes(CRYPTO_ATTRIBUTE)
+	if len(attributes) == 0 {
+		if offer.IsSecureProto() {
+			return nil, errors.New("SdpException: no crypto attribute for " + offer.GetProto())
+		}
+		return nil, nil
+	}
+	if !offer.IsSecureProto() && !this.allowInsecureProto {
+		return nil, errors.New("SdpException: crypto not allowed with transport " + offer.GetProto())
+	}
+
+	for _, a := range attributes {
+		profile, remote, err := this.parseCrypto(a)
+		if err != nil || remote == nil {
+			continue
+		}
+		inbound, err := newSRTPContext(profile, remote)
+		if err != nil {
+			continue
+		}
+		kp, err := this.newKeyParam(profile)
+		if err != nil {
+			return nil, err
+		}
+		local := NewCryptoAttribute(remote.GetTag(), remote.GetSuite(), kp)
+		for _, sp := range remote.GetSessionParams() {
+			local.AddSessionParam(sp)
+		}
+		outbound, err := newSRTPContext(profile, local)
+		if err != nil {
+			return nil, err
+		}
+		answer.RemoveAttribute(CRYPTO_ATTRIBUTE)
+		answer.AddAttribute(local.GetAttribute())
+		return &SDESSession{profile: profile, local: local, remote: remote, outbound: outbound, inbound: inbound}, nil
+	}
+	return nil, errors.New("SdpException: no acceptable crypto attribute")
+}
+
+/** Completes the negotiation on the offerer's side. offered are the attributes
+ * returned by CreateOffer for the same media stream.
+ */
+func (this *SDESNegotiator) ProcessAnswer(offered []*CryptoAttribute, answer *MediaDescription) (*SDESSession, error) {
+	attributes := answer.GetAttributes(CRYPTO_ATTRIBUTE)
+	if len(attributes) != 1 {
+		return nil, errors.New("SdpException: answer must carry exactly one crypto attribute")
+	}
+	profile, remote, err := this.parseCrypto(attributes[0])
+	if err != nil {
+		return nil, err
+	}
+	if remote == nil {
+		return nil, errors.New("SdpException: answer uses an unsupported crypto suite")
+	}
+	for _, local := range offered {
+		if local.GetTag() != remote.GetTag() {
+			continue
+		}
+		if local.GetSuite() != remote.GetSuite() {
+			return nil, errors.New("SdpException: answer changed the crypto suite of tag " + strconv.Itoa(local.GetTag()))
+		}
+		outbound, err := newSRTPContext(profile, local)
+		if err != nil {
+			return nil, err
+		}
+		inbound, err := newSRTPContext(profile, remote)
+		if err != nil {
+			return nil, err
+		}
+		return &SDESSession{profile: profile, local: local, remote: remote, outbound: outbound, inbound: inbound}, nil
+	}
+	return nil, errors.New("SdpException: answer tag " + strconv.Itoa(remote.GetTag()) + " was not offered")
+}
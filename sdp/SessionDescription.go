@@ -0,0 +1,355 @@
+package sdp
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+/**
+ * A single "<type>=<value>" line of a description. Lines that have no
+ * typed accessor are kept as they are so that a description survives a
+ * parse/encode round trip unchanged.
+ */
+type Field struct {
+	Type  byte
+	Value string
+}
+
+/** The ordered list of fields of a session or media description.
+ */
+type fieldList struct {
+	fields []*Field
+}
+
+/** Returns all the fields, in order.
+ */
+func (this *fieldList) GetFields() []*Field {
+	return this.fields
+}
+
+/** Returns the value of the first field of the given type or "" if there is none.
+ */
+func (this *fieldList) GetField(t byte) string {
+	for _, f := range this.fields {
+		if f.Type == t {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+/** Returns the first attribute with the given name or nil.
+ */
+func (this *fieldList) GetAttribute(name string) *Attribute {
+	for _, f := range this.fields {
+		if f.Type == 'a' {
+			if a, err := ParseAttribute(f.Value); err == nil && a.GetName() == name {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+/** Returns all attributes with the given name, or all attributes if name is "".
+ */
+func (this *fieldList) GetAttributes(name string) []*Attribute {
+	var attributes []*Attribute
+	for _, f := range this.fields {
+		if f.Type == 'a' {
+			if a, err := ParseAttribute(f.Value); err == nil && (name == "" || a.GetName() == name) {
+				attributes = append(attributes, a)
+			}
+		}
+	}
+	return attributes
+}
+
+/** Returns true if an attribute with the given name is present.
+ */
+func (this *fieldList) HasAttribute(name string) bool {
+	return this.GetAttribute(name) != nil
+}
+
+/** Appends an attribute.
+ */
+func (this *fieldList) AddAttribute(attribute *Attribute) {
+	this.fields = append(this.fields, &Field{Type: 'a', Value: attribute.String()})
+}
+
+/** Replaces all attributes with the same name by the given one.
+ */
+func (this *fieldList) SetAttribute(attribute *Attribute) {
+	this.RemoveAttribute(attribute.GetName())
+	this.AddAttribute(attribute)
+}
+
+/** Removes all attributes with the given name.
+ */
+func (this *fieldList) RemoveAttribute(name string) {
+	fields := this.fields[:0]
+	for _, f := range this.fields {
+		if f.Type == 'a' {
+			if a, err := ParseAttribute(f.Value); err == nil && a.GetName() == name {
+				continue
+			}
+		}
+		fields = append(fields, f)
+	}
+	this.fields = fields
+}
+
+/** Returns the connection field or nil.
+ */
+func (this *fieldList) GetConnection() *Connection {
+	if v := this.GetField('c'); v != "" {
+		if c, err := ParseConnection(v); err == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+/** Sets the connection field. A new field is inserted before the first field
+ * of any of the types in before, which keeps the order mandated by RFC 4566.
+ */
+func (this *fieldList) setConnection(connection *Connection, before string) {
+	for _, f := range this.fields {
+		if f.Type == 'c' {
+			f.Value = connection.String()
+			return
+		}
+	}
+	field := &Field{Type: 'c', Value: connection.String()}
+	for i, f := range this.fields {
+		if strings.IndexByte(before, f.Type) != -1 {
+			this.fields = append(this.fields[:i], append([]*Field{field}, this.fields[i:]...)...)
+			return
+		}
+	}
+	this.fields = append(this.fields, field)
+}
+
+func (this *fieldList) encode(buffer *bytes.Buffer) {
+	for _, f := range this.fields {
+		buffer.WriteByte(f.Type)
+		buffer.WriteByte('=')
+		buffer.WriteString(f.Value)
+		buffer.WriteString("\r\n")
+	}
+}
+
+/**
+ * A media description: the "m=" line and the fields that follow it up to the
+ * next "m=" line.
+ */
+type MediaDescription struct {
+	fieldList
+
+	media     string
+	port      int
+	portCount int
+	proto     string
+	formats   []string
+}
+
+/** Creates a media description, e.g. NewMediaDescription("audio", 49170, "RTP/AVP", "0", "8").
+ */
+func NewMediaDescription(media string, port int, proto string, formats ...string) *MediaDescription {
+	return &MediaDescription{media: media, port: port, proto: proto, formats: formats}
+}
+
+/** Parses the text following "m=".
+ */
+func ParseMediaDescription(s string) (*MediaDescription, error) {
+	parts := strings.Fields(s)
+	if len(parts) < 3 {
+		return nil, errors.New("SdpParseException: bad media field " + s)
+	}
+	this := &MediaDescription{media: parts[0], proto: parts[2], formats: parts[3:]}
+	port := parts[1]
+	if i := strings.Index(port, "/"); i != -1 {
+		var err error
+		if this.portCount, err = strconv.Atoi(port[i+1:]); err != nil {
+			return nil, errors.New("SdpParseException: bad port count " + port)
+		}
+		port = port[:i]
+	}
+	var err error
+	if this.port, err = strconv.Atoi(port); err != nil || this.port < 0 || this.port > 65535 {
+		return nil, errors.New("SdpParseException: bad port " + port)
+	}
+	return this, nil
+}
+
+/** Returns the media type, e.g. "audio".
+ */
+func (this *MediaDescription) GetMedia() string {
+	return this.media
+}
+
+/** Returns the transport port.
+ */
+func (this *MediaDescription) GetPort() int {
+	return this.port
+}
+
+/** Sets the transport port. A port of zero rejects the stream.
+ */
+func (this *MediaDescription) SetPort(port int) {
+	this.port = port
+}
+
+/** Returns the transport protocol, e.g. "RTP/AVP" or "RTP/SAVP".
+ */
+func (this *MediaDescription) GetProto() string {
+	return this.proto
+}
+
+/** Sets the transport protocol.
+ */
+func (this *MediaDescription) SetProto(proto string) {
+	this.proto = proto
+}
+
+/** Returns true if the transport protocol is a secure RTP profile
+ * (RTP/SAVP, RTP/SAVPF or their UDP/TLS variants).
+ */
+func (this *MediaDescription) IsSecureProto() bool {
+	return strings.Contains(strings.ToUpper(this.proto), "SAVP")
+}
+
+/** Returns the media formats (payload types for RTP).
+ */
+func (this *MediaDescription) GetFormats() []string {
+	return this.formats
+}
+
+/** Sets the media formats.
+ */
+func (this *MediaDescription) SetFormats(formats []string) {
+	this.formats = formats
+}
+
+/** Sets the media level connection field.
+ */
+func (this *MediaDescription) SetConnection(connection *Connection) {
+	this.setConnection(connection, "bka")
+}
+
+/** Encodes the "m=" line value.
+ */
+func (this *MediaDescription) GetMediaField() string {
+	port := strconv.Itoa(this.port)
+	if this.portCount > 0 {
+		port += "/" + strconv.Itoa(this.portCount)
+	}
+	s := this.media + " " + port + " " + this.proto
+	if len(this.formats) > 0 {
+		s += " " + strings.Join(this.formats, " ")
+	}
+	return s
+}
+
+func (this *MediaDescription) encode(buffer *bytes.Buffer) {
+	buffer.WriteString("m=" + this.GetMediaField() + "\r\n")
+	this.fieldList.encode(buffer)
+}
+
+/** Encodes the media description with CRLF line endings.
+ */
+func (this *MediaDescription) String() string {
+	var buffer bytes.Buffer
+	this.encode(&buffer)
+	return buffer.String()
+}
+
+/**
+ * A session description as defined by RFC 4566: the session level fields
+ * followed by any number of media descriptions.
+ */
+type SessionDescription struct {
+	fieldList
+
+	media []*MediaDescription
+}
+
+/** Creates an empty session description.
+ */
+func NewSessionDescription() *SessionDescription {
+	return &SessionDescription{}
+}
+
+/** Parses a complete session description. Both CRLF and LF line endings are
+ * accepted.
+ */
+func ParseSessionDescription(s string) (*SessionDescription, error) {
+	this := &SessionDescription{}
+	var current *MediaDescription
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(line) < 2 || line[1] != '=' {
+			return nil, errors.New("SdpParseException: bad line " + line)
+		}
+		if line[0] == 'm' {
+			md, err := ParseMediaDescription(line[2:])
+			if err != nil {
+				return nil, err
+			}
+			this.media = append(this.media, md)
+			current = md
+		} else if current != nil {
+			current.fields = append(current.fields, &Field{Type: line[0], Value: line[2:]})
+		} else {
+			this.fields = append(this.fields, &Field{Type: line[0], Value: line[2:]})
+		}
+	}
+	if this.GetField('v') == "" {
+		return nil, errors.New("SdpParseException: missing version field")
+	}
+	return this, nil
+}
+
+/** Returns the media descriptions of this session.
+ */
+func (this *SessionDescription) GetMediaDescriptions() []*MediaDescription {
+	return this.media
+}
+
+/** Appends a media description.
+ */
+func (this *SessionDescription) AddMediaDescription(md *MediaDescription) {
+	this.media = append(this.media, md)
+}
+
+/** Sets the session level connection field.
+ */
+func (this *SessionDescription) SetConnection(connection *Connection) {
+	this.setConnection(connection, "btrzka")
+}
+
+/** Returns the connection that applies to the given media description, which
+ * is its own connection field if present and the session level one otherwise.
+ */
+func (this *SessionDescription) GetMediaConnection(md *MediaDescription) *Connection {
+	if c := md.GetConnection(); c != nil {
+		return c
+	}
+	return this.GetConnection()
+}
+
+/** Encodes the session description with CRLF line endings.
+ */
+func (this *SessionDescription) String() string {
+	var buffer bytes.Buffer
+	this.fieldList.encode(&buffer)
+	for _, md := range this.media {
+		md.encode(&buffer)
+	}
+	return buffer.String()
+}
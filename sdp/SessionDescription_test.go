@@ -0,0 +1,75 @@
+package sdp
+
+import (
+	"testing"
+)
+
+func TestSessionDescription(t *testing.T) {
+	var tvi = []string{
+		"v=0\r\n" +
+			"o=jdoe 2890844526 2890842807 IN IP4 10.47.16.5\r\n" +
+			"s=SDP Seminar\r\n" +
+			"c=IN IP4 224.2.17.12/127\r\n" +
+			"t=2873397496 2873404696\r\n" +
+			"a=recvonly\r\n" +
+			"m=audio 49170 RTP/AVP 0\r\n" +
+			"m=video 51372/2 RTP/AVP 99\r\n" +
+			"a=rtpmap:99 h263-1998/90000\r\n",
+		"v=0\n" +
+			"o=- 0 0 IN IP6 ::1\n" +
+			"s=-\n" +
+			"t=0 0\n" +
+			"m=audio 5004 RTP/SAVP 8\n",
+	}
+	var tvo = []string{
+		"v=0\r\n" +
+			"o=jdoe 2890844526 2890842807 IN IP4 10.47.16.5\r\n" +
+			"s=SDP Seminar\r\n" +
+			"c=IN IP4 224.2.17.12/127\r\n" +
+			"t=2873397496 2873404696\r\n" +
+			"a=recvonly\r\n" +
+			"m=audio 49170 RTP/AVP 0\r\n" +
+			"m=video 51372/2 RTP/AVP 99\r\n" +
+			"a=rtpmap:99 h263-1998/90000\r\n",
+		"v=0\r\n" +
+			"o=- 0 0 IN IP6 ::1\r\n" +
+			"s=-\r\n" +
+			"t=0 0\r\n" +
+			"m=audio 5004 RTP/SAVP 8\r\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		sd, err := ParseSessionDescription(tvi[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sd.String() != tvo[i] {
+			t.Log("golden = " + tvo[i])
+			t.Log("failed = " + sd.String())
+			t.Fail()
+		}
+	}
+}
+
+func TestSessionDescriptionEdit(t *testing.T) {
+	sd, err := ParseSessionDescription("v=0\r\no=- 0 0 IN IP4 10.0.0.1\r\ns=-\r\nt=0 0\r\nm=audio 4000 RTP/AVP 0\r\na=sendrecv\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd.SetConnection(NewConnection("192.0.2.1"))
+	md := sd.GetMediaDescriptions()[0]
+	md.SetPort(5000)
+	md.SetConnection(NewConnection("2001:db8::1"))
+	md.SetAttribute(NewAttribute("rtpmap", "0 PCMU/8000"))
+
+	golden := "v=0\r\no=- 0 0 IN IP4 10.0.0.1\r\ns=-\r\nc=IN IP4 192.0.2.1\r\nt=0 0\r\n" +
+		"m=audio 5000 RTP/AVP 0\r\nc=IN IP6 2001:db8::1\r\na=sendrecv\r\na=rtpmap:0 PCMU/8000\r\n"
+	if sd.String() != golden {
+		t.Log("golden = " + golden)
+		t.Log("failed = " + sd.String())
+		t.Fail()
+	}
+	if sd.GetMediaConnection(md).GetAddress() != "2001:db8::1" {
+		t.Fail()
+	}
+}
@@ -0,0 +1,80 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Gruu.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strings"
+)
+
+/** Returns the public GRUU (RFC 5627) the registrar assigned to the User
+ * Agent instance instanceId in a 2xx response to a REGISTER, or nil if it
+ * assigned none. The public GRUU is stable across registrations and reveals
+ * the address-of-record.
+ */
+func GetPublicGruu(response message.Response, instanceId string) address.URI {
+	return getGruu(response, instanceId, header.ParameterNames_PUB_GRUU)
+}
+
+/** Returns the temporary GRUU (RFC 5627) the registrar assigned to the User
+ * Agent instance instanceId in a 2xx response to a REGISTER, or nil if it
+ * assigned none. Each registration returns a new temporary GRUU, which does
+ * not reveal the address-of-record; the previous ones stay valid as long as
+ * the instance stays registered with the same Call-ID.
+ */
+func GetTemporaryGruu(response message.Response, instanceId string) address.URI {
+	return getGruu(response, instanceId, header.ParameterNames_TEMP_GRUU)
+}
+
+func getGruu(response message.Response, instanceId, parameterName string) address.URI {
+	for e := response.GetHeaders(core.SIPHeaderNames_CONTACT).Front(); e != nil; e = e.Next() {
+		contact, ok := e.Value.(*header.Contact)
+		if !ok || !strings.EqualFold(contact.GetInstanceId(), instanceId) {
+			continue
+		}
+		gruu := strings.Trim(contact.GetParameter(parameterName), "\"")
+		if gruu == "" {
+			continue
+		}
+		if uri, err := parser.NewURLParser(gruu).UriReference(); err == nil {
+			return uri
+		}
+	}
+	return nil
+}
+
+/** Sets the address of the Contact of msg, a dialog-forming request or its
+ * response, to a GRUU of the User Agent instance so that the requests of the
+ * dialog reach this instance. The parameters of the Contact are kept; a
+ * Contact is added if msg has none.
+ */
+func SetGruuContact(msg message.Message, gruu address.URI) (SipException error) {
+	if sipURI, ok := gruu.(*address.SipURIImpl); !ok || !sipURI.HasGrParam() {
+		return errors.New("SipException: not a GRUU")
+	}
+	// the URI is copied through the parser as cloning drops its parameters
+	gruuAddress, err := parser.NewAddressParser("<" + gruu.String() + ">").Address()
+	if err != nil {
+		return err
+	}
+	if contact, ok := msg.GetHeader(core.SIPHeaderNames_CONTACT).(*header.Contact); ok {
+		contact.SetAddress(gruuAddress)
+		return nil
+	}
+	contact := header.NewContact()
+	contact.SetAddress(gruuAddress)
+	contactList := header.NewContactList()
+	contactList.PushBack(contact)
+	msg.SetHeader(contactList)
+	return nil
+}
@@ -0,0 +1,91 @@
+package sip
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strings"
+	"testing"
+)
+
+// gruuResponse returns the 200 (OK) to register assigning GRUUs to the
+// instance.
+func gruuResponse(t *testing.T, register *message.SIPRequest) *message.SIPResponse {
+	response := register.CreateResponse(message.OK).String()
+	return parseResponse(t, strings.Replace(response, "Content-Length:",
+		"Contact: <sip:bob@192.0.2.9>;expires=3600, <sip:bob@192.0.2.2;transport=tcp;ob>;+sip.instance=\"<"+instanceId+">\";expires=3600;"+
+			"pub-gruu=\"sip:bob@example.com;gr="+instanceId+"\";temp-gruu=\"sip:tgruu.7hs==@example.com;gr\"\r\n"+
+			"Content-Length:", 1))
+}
+
+func TestGetGruu(t *testing.T) {
+	response := gruuResponse(t, outboundRegister(t))
+	if gruu := GetPublicGruu(response, instanceId); gruu == nil || gruu.String() != "sip:bob@example.com;gr="+instanceId {
+		t.Error("bad public GRUU", gruu)
+	}
+	if gruu := GetTemporaryGruu(response, strings.ToUpper(instanceId)); gruu == nil || gruu.String() != "sip:tgruu.7hs==@example.com;gr" {
+		t.Error("bad temporary GRUU", gruu)
+	}
+	if gruu := GetPublicGruu(response, "urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6"); gruu != nil {
+		t.Error("GRUU of another instance", gruu)
+	}
+}
+
+func TestSetGruuContact(t *testing.T) {
+	gruu, _ := parser.NewURLParser("sip:bob@example.com;gr=" + instanceId).UriReference()
+	invite := parseRequest(t, "INVITE sip:alice@a.example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/TCP 192.0.2.2;branch=z9hG4bKinvite\r\n"+
+		"From: <sip:bob@example.com>;tag=bob-tag\r\n"+
+		"To: <sip:alice@a.example.com>\r\n"+
+		"Call-ID: gruu@192.0.2.2\r\n"+
+		"CSeq: 1 INVITE\r\n"+
+		"Contact: <sip:bob@192.0.2.2;transport=tcp>;+sip.instance=\"<"+instanceId+">\"\r\n"+
+		"Max-Forwards: 70\r\n"+
+		"Content-Length: 0\r\n\r\n")
+	notGruu, _ := parser.NewURLParser("sip:bob@192.0.2.2").UriReference()
+	if err := SetGruuContact(invite, notGruu); err == nil {
+		t.Error("URI without gr parameter set as GRUU")
+	}
+
+	// the address of the Contact is replaced and its parameters kept
+	if err := SetGruuContact(invite, gruu); err != nil {
+		t.Fatal(err)
+	}
+	contact := reparse(t, invite).GetHeader(core.SIPHeaderNames_CONTACT).(*header.Contact)
+	if contact.GetAddress().GetURI().String() != gruu.String() || contact.GetInstanceId() != instanceId {
+		t.Error("bad Contact", contact)
+	}
+
+	// a Contact is added to a response without one
+	response := invite.CreateResponse(message.OK)
+	response.RemoveHeader(core.SIPHeaderNames_CONTACT)
+	SetGruuContact(response, gruu)
+	if contact, ok := reparse(t, response).GetHeader(core.SIPHeaderNames_CONTACT).(*header.Contact); !ok ||
+		contact.GetAddress().GetURI().String() != gruu.String() {
+		t.Error("Contact not added", response)
+	}
+}
+
+func TestOutboundClientGruu(t *testing.T) {
+	provider := &fakeProvider{}
+	client := NewOutboundClient(provider, instanceId)
+	if err := client.Register(outboundRegister(t), &fakeFlow{transport: TCP, proxy: "edge1.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Unregister()
+	register := reparse(t, provider.lastSent(t)).(*message.SIPRequest)
+	if !IsOptionSupported(register, OPTION_GRUU) {
+		t.Error("REGISTER without Supported: gruu", register)
+	}
+	if client.GetPublicGruu() != nil || client.GetTemporaryGruu() != nil {
+		t.Error("GRUUs before the registration")
+	}
+	client.ProcessResponse(gruuResponse(t, register))
+	if gruu := client.GetPublicGruu(); gruu == nil || gruu.String() != "sip:bob@example.com;gr="+instanceId {
+		t.Error("bad public GRUU", gruu)
+	}
+	if gruu := client.GetTemporaryGruu(); gruu == nil || gruu.String() != "sip:tgruu.7hs==@example.com;gr" {
+		t.Error("bad temporary GRUU", gruu)
+	}
+}
@@ -0,0 +1,228 @@
+package sip
+
+import (
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// The fakes below implement just enough of the transaction layer for the
+// User Agent helpers of this package: every message sent is recorded so
+// that the tests can parse it again as the peer would receive it.
+
+func parseMessage(t *testing.T, s string) message.Message {
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(s)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, s)
+	}
+	return msg
+}
+
+func parseRequest(t *testing.T, s string) *message.SIPRequest {
+	return parseMessage(t, s).(*message.SIPRequest)
+}
+
+func parseResponse(t *testing.T, s string) *message.SIPResponse {
+	return parseMessage(t, s).(*message.SIPResponse)
+}
+
+// reparse returns msg as received by the peer.
+func reparse(t *testing.T, msg message.Message) message.Message {
+	return parseMessage(t, msg.String())
+}
+
+type fakeClientTransaction struct {
+	ClientTransaction
+
+	provider *fakeProvider
+	request  message.Request
+	dialog   Dialog
+}
+
+func (this *fakeClientTransaction) GetRequest() message.Request {
+	return this.request
+}
+
+func (this *fakeClientTransaction) GetDialog() Dialog {
+	return this.dialog
+}
+
+func (this *fakeClientTransaction) GetBranchId() string {
+	if request, ok := this.request.(*message.SIPRequest); ok && request.GetTopmostVia() != nil {
+		return request.GetTopmostVia().GetBranch()
+	}
+	return ""
+}
+
+func (this *fakeClientTransaction) SendRequest() error {
+	this.provider.mutex.Lock()
+	defer this.provider.mutex.Unlock()
+	this.provider.sent = append(this.provider.sent, this.request)
+	return nil
+}
+
+type fakeServerTransaction struct {
+	ServerTransaction
+
+	mutex     sync.Mutex
+	request   message.Request
+	dialog    Dialog
+	responses []message.Response
+}
+
+func newFakeServerTransaction(request message.Request, dialog Dialog) *fakeServerTransaction {
+	return &fakeServerTransaction{request: request, dialog: dialog}
+}
+
+func (this *fakeServerTransaction) GetRequest() message.Request {
+	return this.request
+}
+
+func (this *fakeServerTransaction) GetDialog() Dialog {
+	return this.dialog
+}
+
+func (this *fakeServerTransaction) SendResponse(response message.Response) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.responses = append(this.responses, response)
+	return nil
+}
+
+func (this *fakeServerTransaction) getResponses() []message.Response {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return append([]message.Response(nil), this.responses...)
+}
+
+type fakeProvider struct {
+	SipProvider
+
+	mutex  sync.Mutex
+	dialog Dialog
+	// the requests sent in client transactions, in dialog or not
+	sent []message.Request
+}
+
+func (this *fakeProvider) GetNewClientTransaction(request message.Request) (ClientTransaction, error) {
+	return &fakeClientTransaction{provider: this, request: request, dialog: this.dialog}, nil
+}
+
+func (this *fakeProvider) GetNewCallId() header.CallIdHeader {
+	callId, _ := header.NewCallID("new-call-id@a.example.com")
+	return callId
+}
+
+func (this *fakeProvider) getSent() []message.Request {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return append([]message.Request(nil), this.sent...)
+}
+
+func (this *fakeProvider) lastSent(t *testing.T) *message.SIPRequest {
+	sent := this.getSent()
+	if len(sent) == 0 {
+		t.Fatal("no request sent")
+	}
+	return reparse(t, sent[len(sent)-1]).(*message.SIPRequest)
+}
+
+// fakeDialog is a dialog between alice@a.example.com, the local party, and
+// bob@b.example.com; its requests go to the remote target and are sent
+// through the client transactions of its provider.
+type fakeDialog struct {
+	Dialog
+
+	t        *testing.T
+	provider *fakeProvider
+	mutex    sync.Mutex
+	server   bool
+	state    *DialogState
+	localSeq int
+	target   string
+}
+
+func newFakeDialog(t *testing.T) *fakeDialog {
+	this := &fakeDialog{t: t, state: DIALOGSTATE_CONFIRMED, localSeq: 10, target: "sip:bob@192.0.2.2"}
+	this.provider = &fakeProvider{dialog: this}
+	return this
+}
+
+func (this *fakeDialog) GetCallId() header.CallIdHeader {
+	callId, _ := header.NewCallID("dialog@a.example.com")
+	return callId
+}
+
+func (this *fakeDialog) GetLocalTag() string {
+	return "alice-tag"
+}
+
+func (this *fakeDialog) GetRemoteTag() string {
+	return "bob-tag"
+}
+
+func (this *fakeDialog) GetDialogId() string {
+	return "dialog@a.example.com:alice-tag:bob-tag"
+}
+
+func (this *fakeDialog) GetState() *DialogState {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.state
+}
+
+func (this *fakeDialog) IsServer() bool {
+	return this.server
+}
+
+func (this *fakeDialog) GetRemoteTarget() address.Address {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	target, _ := parser.NewAddressParser("<" + this.target + ">").Address()
+	return target
+}
+
+func (this *fakeDialog) GetLocalSequenceNumber() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.localSeq
+}
+
+func (this *fakeDialog) CreateRequest(method string) (message.Request, error) {
+	this.mutex.Lock()
+	this.localSeq++
+	s := method + " " + this.target + " SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bK" + method + strconv.Itoa(this.localSeq) + "\r\n" +
+		"From: <sip:alice@a.example.com>;tag=alice-tag\r\n" +
+		"To: <sip:bob@b.example.com>;tag=bob-tag\r\n" +
+		"Call-ID: dialog@a.example.com\r\n" +
+		"CSeq: " + strconv.Itoa(this.localSeq) + " " + method + "\r\n" +
+		"Max-Forwards: 70\r\n" +
+		"Content-Length: 0\r\n\r\n"
+	this.mutex.Unlock()
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(s)
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*message.SIPRequest), nil
+}
+
+func (this *fakeDialog) SendRequest(ct ClientTransaction) error {
+	return ct.SendRequest()
+}
+
+// inDialogRequest returns a request sent by bob in the dialog of fakeDialog.
+func inDialogRequest(method string, cseq int, headers string, body string) string {
+	s := method + " sip:alice@192.0.2.1 SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP 192.0.2.2;branch=z9hG4bKbob" + method + strconv.Itoa(cseq) + "\r\n" +
+		"From: <sip:bob@b.example.com>;tag=bob-tag\r\n" +
+		"To: <sip:alice@a.example.com>;tag=alice-tag\r\n" +
+		"Call-ID: dialog@a.example.com\r\n" +
+		"CSeq: " + strconv.Itoa(cseq) + " " + method + "\r\n" +
+		"Max-Forwards: 70\r\n" + headers
+	return s + "Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+}
@@ -0,0 +1,431 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Notifier.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * This interface is implemented by event packages to produce the bodies of
+ * the NOTIFY requests of their subscriptions.
+ */
+type EventBodyProducer interface {
+	/**
+	 * Returns the body describing the current state of the resource of
+	 * subscription and its content type, or an empty body and a nil content
+	 * type for a NOTIFY without body.
+	 */
+	ProduceBody(subscription *ServerSubscription) (body string, contentType header.ContentTypeHeader)
+}
+
+/**
+ * Manages the subscriptions of a notifier as described in RFC 6665. Event
+ * packages are plugged in with RegisterProducer; SUBSCRIBE requests for other
+ * packages are rejected with 489. Once the application accepts a SUBSCRIBE,
+ * the 200 response is followed at once by a NOTIFY describing the current
+ * state; further NOTIFY requests are sent on state changes and the
+ * subscription is terminated with a final NOTIFY when it expires.
+ */
+type Notifier struct {
+	mutex sync.Mutex
+
+	provider       SipProvider
+	producers      map[string]EventBodyProducer
+	defaultExpires int
+	minExpires     int
+	maxExpires     int
+	subscriptions  map[string]*ServerSubscription
+}
+
+/** Creates a notifier that sends its NOTIFY requests through provider.
+ */
+func NewNotifier(provider SipProvider) *Notifier {
+	return &Notifier{
+		provider:       provider,
+		producers:      make(map[string]EventBodyProducer),
+		defaultExpires: SUBSCRIPTION_DEFAULT_EXPIRES,
+		subscriptions:  make(map[string]*ServerSubscription),
+	}
+}
+
+/** Registers the producer of the NOTIFY bodies of an event package.
+ */
+func (this *Notifier) RegisterProducer(eventType string, producer EventBodyProducer) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.producers[strings.ToLower(eventType)] = producer
+}
+
+/** Sets the duration in seconds of subscriptions whose SUBSCRIBE has no
+ * Expires header.
+ */
+func (this *Notifier) SetDefaultExpires(defaultExpires int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.defaultExpires = defaultExpires
+}
+
+/** Sets the shortest duration in seconds accepted for a subscription;
+ * shorter ones are rejected with 423. 0 accepts any duration.
+ */
+func (this *Notifier) SetMinExpires(minExpires int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.minExpires = minExpires
+}
+
+/** Sets the longest duration in seconds granted to a subscription; longer
+ * ones are shortened. 0 grants any duration.
+ */
+func (this *Notifier) SetMaxExpires(maxExpires int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.maxExpires = maxExpires
+}
+
+/** Adds an Allow-Events header listing the registered event packages to msg.
+ */
+func (this *Notifier) AddAllowEvents(msg message.Message) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if len(this.producers) == 0 {
+		return
+	}
+	allowEventsList := header.NewAllowEventsList()
+	for eventType := range this.producers {
+		allowEventsList.PushBack(header.NewAllowEventsFromString(eventType))
+	}
+	msg.AddHeader(allowEventsList)
+}
+
+/** Processes a SUBSCRIBE received on transaction. If the SUBSCRIBE cannot be
+ * accepted the response to send is returned: 489 with Allow-Events for an
+ * unknown event package and 423 with Min-Expires for a too short duration.
+ * Otherwise the subscription is returned. For a new subscription, including
+ * one created in an existing dialog with another event id, the state is empty
+ * and the application decides with Accept, AcceptPending or Reject; a refresh
+ * or an unsubscribe of an existing subscription is answered with Accept. A
+ * new subscription with "Expires: 0" is a fetch: it is terminated right after
+ * its first NOTIFY.
+ */
+func (this *Notifier) ProcessSubscribe(transaction ServerTransaction) (*ServerSubscription, *message.SIPResponse) {
+	subscribe, ok := transaction.GetRequest().(*message.SIPRequest)
+	if !ok {
+		return nil, nil
+	}
+	event, ok := subscribe.GetHeader(core.SIPHeaderNames_EVENT).(header.EventHeader)
+	if !ok || this.getProducer(event.GetEventType()) == nil {
+		response := subscribe.CreateResponse(message.BAD_EVENT)
+		this.AddAllowEvents(response)
+		return nil, response
+	}
+	dialog := transaction.GetDialog()
+	if dialog == nil {
+		return nil, subscribe.CreateResponse(message.SERVER_INTERNAL_ERROR)
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	expires := this.defaultExpires
+	if expiresHeader, ok := subscribe.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader); ok {
+		expires = expiresHeader.GetExpires()
+	}
+	if expires > 0 && expires < this.minExpires {
+		response := subscribe.CreateResponse(message.INTERVAL_TOO_BRIEF)
+		minExpires := header.NewMinExpires()
+		minExpires.SetExpires(this.minExpires)
+		response.SetHeader(minExpires)
+		return nil, response
+	}
+	if this.maxExpires > 0 && expires > this.maxExpires {
+		expires = this.maxExpires
+	}
+
+	key := dialog.GetDialogId() + "|" + strings.ToLower(event.GetEventType()) + "|" + event.GetEventId()
+	subscription := this.subscriptions[key]
+	if subscription == nil {
+		subscription = &ServerSubscription{
+			notifier:  this,
+			key:       key,
+			dialog:    dialog,
+			eventType: event.GetEventType(),
+			eventId:   event.GetEventId(),
+		}
+		this.subscriptions[key] = subscription
+	}
+	subscription.transaction = transaction
+	subscription.request = subscribe
+	subscription.requested = expires
+	return subscription, nil
+}
+
+func (this *Notifier) getProducer(eventType string) EventBodyProducer {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.producers[strings.ToLower(eventType)]
+}
+
+/**
+ * A subscription of a Notifier. Its state is empty until the SUBSCRIBE is
+ * accepted, then "pending" or "active" and finally "terminated".
+ */
+type ServerSubscription struct {
+	notifier *Notifier
+
+	key         string
+	dialog      Dialog
+	eventType   string
+	eventId     string
+	transaction ServerTransaction
+	request     message.Request
+	requested   int
+	state       string
+	expiresAt   time.Time
+	generation  int
+	timer       *time.Timer
+
+	applicationData interface{}
+}
+
+/** Returns the event package of the subscription.
+ */
+func (this *ServerSubscription) GetEventType() string {
+	return this.eventType
+}
+
+/** Returns the event id of the subscription, or an empty string.
+ */
+func (this *ServerSubscription) GetEventId() string {
+	return this.eventId
+}
+
+/** Returns the dialog of the subscription.
+ */
+func (this *ServerSubscription) GetDialog() Dialog {
+	return this.dialog
+}
+
+/** Returns the last SUBSCRIBE of the subscription, e.g. to look at its
+ * Request-URI or Accept header.
+ */
+func (this *ServerSubscription) GetRequest() message.Request {
+	this.notifier.mutex.Lock()
+	defer this.notifier.mutex.Unlock()
+	return this.request
+}
+
+/** Returns the state of the subscription.
+ */
+func (this *ServerSubscription) GetState() string {
+	this.notifier.mutex.Lock()
+	defer this.notifier.mutex.Unlock()
+	return this.state
+}
+
+/** Sets application data, e.g. the state kept by the event package.
+ */
+func (this *ServerSubscription) SetApplicationData(applicationData interface{}) {
+	this.notifier.mutex.Lock()
+	defer this.notifier.mutex.Unlock()
+	this.applicationData = applicationData
+}
+
+/** Returns the application data of the subscription.
+ */
+func (this *ServerSubscription) GetApplicationData() interface{} {
+	this.notifier.mutex.Lock()
+	defer this.notifier.mutex.Unlock()
+	return this.applicationData
+}
+
+/** Accepts the last SUBSCRIBE with 200 and sends the NOTIFY that follows
+ * every accepted SUBSCRIBE. A new subscription becomes active; an unsubscribe
+ * terminates the subscription with reason "timeout".
+ */
+func (this *ServerSubscription) Accept() (SipException error) {
+	return this.accept(header.SubscriptionState_ACTIVE)
+}
+
+/** Accepts a new subscription as pending, e.g. while the subscriber is not
+ * authorized yet. The subscription is made active with Activate.
+ */
+func (this *ServerSubscription) AcceptPending() (SipException error) {
+	return this.accept(header.SubscriptionState_PENDING)
+}
+
+func (this *ServerSubscription) accept(state string) error {
+	this.notifier.mutex.Lock()
+	if this.state == header.SubscriptionState_TERMINATED || this.transaction == nil {
+		this.notifier.mutex.Unlock()
+		return errors.New("SipException: no SUBSCRIBE to accept")
+	}
+	if this.state == "" {
+		this.state = state
+	}
+	transaction := this.transaction
+	this.transaction = nil
+	subscribe := this.request.(*message.SIPRequest)
+	expires := this.requested
+
+	response := subscribe.CreateResponse(message.OK)
+	if response.GetToTag() == "" {
+		response.SetToTag(this.dialog.GetLocalTag())
+	}
+	expiresHeader := header.NewExpires()
+	expiresHeader.SetExpires(expires)
+	response.SetHeader(expiresHeader)
+	this.notifier.mutex.Unlock()
+
+	if err := transaction.SendResponse(response); err != nil {
+		return err
+	}
+	if expires == 0 {
+		return this.Terminate(header.SubscriptionState_TIMEOUT, 0)
+	}
+
+	this.notifier.mutex.Lock()
+	this.startExpiry(expires)
+	this.notifier.mutex.Unlock()
+	return this.Notify()
+}
+
+/** Rejects the SUBSCRIBE with the given final status code. A new
+ * subscription is discarded; an existing one is left unchanged.
+ */
+func (this *ServerSubscription) Reject(statusCode int) (SipException error) {
+	this.notifier.mutex.Lock()
+	if this.transaction == nil {
+		this.notifier.mutex.Unlock()
+		return errors.New("SipException: no SUBSCRIBE to reject")
+	}
+	transaction := this.transaction
+	this.transaction = nil
+	if this.state == "" {
+		this.state = header.SubscriptionState_TERMINATED
+		delete(this.notifier.subscriptions, this.key)
+	}
+	response := this.request.(*message.SIPRequest).CreateResponse(statusCode)
+	this.notifier.mutex.Unlock()
+	return transaction.SendResponse(response)
+}
+
+/** Makes a pending subscription active and notifies the subscriber.
+ */
+func (this *ServerSubscription) Activate() (SipException error) {
+	this.notifier.mutex.Lock()
+	if this.state != header.SubscriptionState_PENDING {
+		this.notifier.mutex.Unlock()
+		return errors.New("SipException: the subscription is not pending")
+	}
+	this.state = header.SubscriptionState_ACTIVE
+	this.notifier.mutex.Unlock()
+	return this.Notify()
+}
+
+/** Sends a NOTIFY describing the current state of the resource, e.g. after
+ * it changed.
+ */
+func (this *ServerSubscription) Notify() (SipException error) {
+	this.notifier.mutex.Lock()
+	state := this.state
+	remaining := int((time.Until(this.expiresAt) + time.Second/2) / time.Second)
+	this.notifier.mutex.Unlock()
+
+	if state != header.SubscriptionState_ACTIVE && state != header.SubscriptionState_PENDING {
+		return errors.New("SipException: the subscription is not accepted")
+	}
+	subscriptionState := header.NewSubscriptionState()
+	subscriptionState.SetState(state)
+	if remaining < 1 {
+		remaining = 1
+	}
+	subscriptionState.SetExpires(remaining)
+	return this.sendNotify(subscriptionState)
+}
+
+/** Terminates the subscription with a final NOTIFY giving reason, one of the
+ * header.SubscriptionState_ reasons, and retryAfter seconds if positive.
+ */
+func (this *ServerSubscription) Terminate(reason string, retryAfter int) (SipException error) {
+	this.notifier.mutex.Lock()
+	if this.state == header.SubscriptionState_TERMINATED {
+		this.notifier.mutex.Unlock()
+		return nil
+	}
+	this.state = header.SubscriptionState_TERMINATED
+	this.stopExpiry()
+	delete(this.notifier.subscriptions, this.key)
+	this.notifier.mutex.Unlock()
+
+	subscriptionState := header.NewSubscriptionState()
+	subscriptionState.SetState(header.SubscriptionState_TERMINATED)
+	if reason != "" {
+		subscriptionState.SetReasonCode(reason)
+	}
+	if retryAfter > 0 {
+		subscriptionState.SetRetryAfter(retryAfter)
+	}
+	return this.sendNotify(subscriptionState)
+}
+
+func (this *ServerSubscription) sendNotify(subscriptionState header.SubscriptionStateHeader) error {
+	notify, err := this.dialog.CreateRequest(message.NOTIFY)
+	if err != nil {
+		return err
+	}
+	event := header.NewEvent()
+	event.SetEventType(this.eventType)
+	if this.eventId != "" {
+		event.SetEventId(this.eventId)
+	}
+	notify.SetHeader(event)
+	notify.SetHeader(subscriptionState)
+
+	// the producer may call back into the subscription
+	if producer := this.notifier.getProducer(this.eventType); producer != nil {
+		if body, contentType := producer.ProduceBody(this); contentType != nil {
+			notify.SetContent(body, contentType)
+		}
+	}
+
+	ct, err := this.notifier.provider.GetNewClientTransaction(notify)
+	if err != nil {
+		return err
+	}
+	return this.dialog.SendRequest(ct)
+}
+
+func (this *ServerSubscription) startExpiry(expires int) {
+	this.stopExpiry()
+	this.expiresAt = time.Now().Add(time.Duration(expires) * time.Second)
+	generation := this.generation
+	this.timer = time.AfterFunc(time.Duration(expires)*time.Second, func() {
+		this.notifier.mutex.Lock()
+		current := generation == this.generation
+		this.notifier.mutex.Unlock()
+		if current {
+			this.Terminate(header.SubscriptionState_TIMEOUT, 0)
+		}
+	})
+}
+
+func (this *ServerSubscription) stopExpiry() {
+	this.generation++
+	if this.timer != nil {
+		this.timer.Stop()
+		this.timer = nil
+	}
+}
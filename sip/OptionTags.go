@@ -0,0 +1,90 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : OptionTags.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strings"
+)
+
+/**
+ * Option tags used in the Supported, Require, Proxy-Require and Unsupported
+ * headers.
+ */
+const (
+	/** Reliable provisional responses (RFC 3262). */
+	OPTION_100REL = "100rel"
+	/** Session timers (RFC 4028). */
+	OPTION_TIMER = "timer"
+	/** Suppression of the implicit REFER subscription (RFC 4488). */
+	OPTION_NOREFERSUB = "norefersub"
+	/** The Replaces header (RFC 3891). */
+	OPTION_REPLACES = "replaces"
+	/** The Join header (RFC 3911). */
+	OPTION_JOIN = "join"
+	/** The Path header (RFC 3327). */
+	OPTION_PATH = "path"
+	/** Client-initiated connections (RFC 5626). */
+	OPTION_OUTBOUND = "outbound"
+	/** Globally Routable User Agent URIs (RFC 5627). */
+	OPTION_GRUU = "gruu"
+)
+
+/** Returns true if one of the headers named headerName of msg carries the
+ * option tag.
+ */
+func hasOptionTag(msg message.Message, headerName, tag string) bool {
+	headers := msg.GetHeaders(headerName)
+	for e := headers.Front(); e != nil; e = e.Next() {
+		if h, ok := e.Value.(header.OptionTag); ok {
+			if strings.EqualFold(strings.TrimSpace(h.GetOptionTag()), tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/** Returns true if msg lists the option tag in a Supported or Require header.
+ * An option that is required is implicitly supported.
+ */
+func IsOptionSupported(msg message.Message, tag string) bool {
+	return hasOptionTag(msg, core.SIPHeaderNames_SUPPORTED, tag) || IsOptionRequired(msg, tag)
+}
+
+/** Returns true if msg lists the option tag in a Require header.
+ */
+func IsOptionRequired(msg message.Message, tag string) bool {
+	return hasOptionTag(msg, core.SIPHeaderNames_REQUIRE, tag)
+}
+
+/** Adds the option tag to the Supported header of msg unless it is already
+ * listed.
+ */
+func AddSupported(msg message.Message, tag string) {
+	if hasOptionTag(msg, core.SIPHeaderNames_SUPPORTED, tag) {
+		return
+	}
+	supportedList := header.NewSupportedList()
+	supportedList.PushBack(header.NewSupportedFromString(tag))
+	msg.AddHeader(supportedList)
+}
+
+/** Adds the option tag to the Require header of msg unless it is already
+ * listed.
+ */
+func AddRequire(msg message.Message, tag string) {
+	if IsOptionRequired(msg, tag) {
+		return
+	}
+	requireList := header.NewRequireList()
+	requireList.PushBack(header.NewRequireFromString(tag))
+	msg.AddHeader(requireList)
+}
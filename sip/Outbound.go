@@ -0,0 +1,667 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Outbound.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"gosips/stun"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * Timers of SIP Outbound (RFC 5626 sections 4.4 and 4.5), in seconds.
+ */
+const (
+	/** The time a pong is waited for before the flow is considered failed. */
+	OUTBOUND_PONG_TIMEOUT = 10
+	/** The keep-alive interval of connection-oriented flows without Flow-Timer. */
+	OUTBOUND_CONNECTION_KEEPALIVE = 120
+	/** The keep-alive interval of UDP flows without Flow-Timer. */
+	OUTBOUND_DATAGRAM_KEEPALIVE = 29
+	/** The base retry time when all the flows have failed. */
+	OUTBOUND_BASE_TIME_ALL_FAILED = 30
+	/** The base retry time when some flows are still registered. */
+	OUTBOUND_BASE_TIME_SOME_FAILED = 90
+	/** The longest time between two attempts to recover a flow. */
+	OUTBOUND_MAX_TIME = 1800
+	/** The registration duration if the REGISTER has no Expires. */
+	OUTBOUND_DEFAULT_EXPIRES = 3600
+)
+
+/** The keep-alive ping of connection-oriented flows, and its pong.
+ */
+const (
+	OUTBOUND_PING = "\r\n\r\n"
+	OUTBOUND_PONG = "\r\n"
+)
+
+/**
+ * A flow (RFC 5626): a transport-layer association between the User Agent
+ * and an edge proxy, such as a TCP connection or a UDP 5-tuple. It is
+ * implemented by the transport layer, which also passes the keep-alive
+ * responses it receives on the flow to OutboundClient.ProcessKeepAlive and
+ * reports broken connections with OutboundClient.ProcessFlowFailure.
+ */
+type Flow interface {
+	/**
+	 * Returns the transport of the flow: UDP, TCP or TLS.
+	 */
+	GetTransport() string
+
+	/**
+	 * Returns the address of the edge proxy at the far end of the flow,
+	 * placed as Route in the REGISTER requests sent over the flow.
+	 */
+	GetOutboundProxy() address.Address
+
+	/**
+	 * Sends raw data, a keep-alive, over the flow.
+	 */
+	Send(data []byte) error
+}
+
+/**
+ * This interface is implemented by applications registering with an
+ * OutboundClient.
+ */
+type OutboundListener interface {
+	/**
+	 * Called to open a new flow replacing the failed flow of a
+	 * registration, possibly to another edge proxy.
+	 */
+	CreateFlow(regId int) (Flow, error)
+
+	/**
+	 * Called when a registration becomes registered or unregistered.
+	 */
+	ProcessRegistrationState(registration *OutboundRegistration)
+}
+
+/**
+ * The User Agent half of SIP Outbound (RFC 5626). The User Agent instance
+ * registers the same contact over several flows, each with its own reg-id,
+ * so that requests reach it as long as one flow works. When the registrar
+ * supports outbound, the flows are kept alive with CRLF keep-alives on
+ * connection-oriented transports and STUN Binding requests on UDP. A flow
+ * that fails, because a keep-alive is not answered, the NAT binding changed
+ * or the registration failed, is replaced with a new one from the
+ * OutboundListener and registered again after the back-off of RFC 5626
+ * section 4.5.
+ * <p>
+ * The REGISTER requests also support GRUU (RFC 5627): the GRUUs assigned
+ * by the registrar to the instance are available once it is registered, to
+ * be placed with SetGruuContact in the Contact of dialog-forming requests.
+ */
+type OutboundClient struct {
+	mutex sync.Mutex
+
+	provider      SipProvider
+	listener      OutboundListener
+	instanceId    string
+	registrations []*OutboundRegistration
+	transactions  map[string]*OutboundRegistration
+	notifications []*OutboundRegistration
+	publicGruu    address.URI
+	temporaryGruu address.URI
+	unit          time.Duration // of the timers, shorter than a second in tests
+}
+
+/** Creates a client for the User Agent instance identified by instanceId, a
+ * URN such as urn:uuid:00000000-0000-1000-8000-000A95A0E128, sending its
+ * REGISTER requests through provider.
+ */
+func NewOutboundClient(provider SipProvider, instanceId string) *OutboundClient {
+	return &OutboundClient{
+		provider:     provider,
+		instanceId:   instanceId,
+		transactions: make(map[string]*OutboundRegistration),
+		unit:         time.Second,
+	}
+}
+
+/** Sets the listener providing new flows and informed about the
+ * registrations.
+ */
+func (this *OutboundClient) SetOutboundListener(listener OutboundListener) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.listener = listener
+}
+
+/** Returns the registrations, in reg-id order.
+ */
+func (this *OutboundClient) GetRegistrations() []*OutboundRegistration {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return append([]*OutboundRegistration(nil), this.registrations...)
+}
+
+/** Returns the public GRUU assigned to the instance by the registrar, or nil
+ * if it assigned none.
+ */
+func (this *OutboundClient) GetPublicGruu() address.URI {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.publicGruu
+}
+
+/** Returns the temporary GRUU assigned to the instance by the last
+ * registration, or nil if the registrar assigned none.
+ */
+func (this *OutboundClient) GetTemporaryGruu() address.URI {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.temporaryGruu
+}
+
+/** Registers the Contact of a REGISTER created by the application over each
+ * flow, the first one with reg-id 1. The +sip.instance and reg-id
+ * parameters, the Route to the edge proxy of the flow and the outbound, path
+ * and gruu option tags are added to each copy of the request.
+ */
+func (this *OutboundClient) Register(register message.Request, flows ...Flow) (SipException error) {
+	request, ok := register.(*message.SIPRequest)
+	if !ok {
+		return errors.New("SipException: not a SIP request")
+	}
+	if _, ok = request.GetHeader(core.SIPHeaderNames_CONTACT).(*header.Contact); !ok {
+		return errors.New("SipException: REGISTER without Contact")
+	}
+	if request.GetCallId() == nil {
+		return errors.New("SipException: REGISTER without Call-ID")
+	}
+	expires := OUTBOUND_DEFAULT_EXPIRES
+	if expiresHeader, ok := request.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader); ok {
+		expires = expiresHeader.GetExpires()
+	}
+
+	this.mutex.Lock()
+	defer this.unlock()
+	if len(this.registrations) > 0 {
+		return errors.New("SipException: already registered")
+	}
+	for i, flow := range flows {
+		registration := &OutboundRegistration{
+			client:  this,
+			regId:   i + 1,
+			flow:    flow,
+			request: request,
+			expires: expires,
+		}
+		if i > 0 {
+			registration.callId = NewToken(8) + "@" + contactHost(request)
+		}
+		this.registrations = append(this.registrations, registration)
+		if err := registration.sendRegister(expires); err != nil {
+			registration.fail()
+		}
+	}
+	return nil
+}
+
+/** Removes the registrations of all the flows with REGISTER requests with
+ * "Expires: 0" and stops their keep-alives.
+ */
+func (this *OutboundClient) Unregister() (SipException error) {
+	this.mutex.Lock()
+	defer this.unlock()
+	for _, registration := range this.registrations {
+		if registration.removed {
+			continue
+		}
+		registration.removed = true
+		registration.stopTimers()
+		if registration.registered {
+			if err := registration.sendRegister(0); err != nil && SipException == nil {
+				SipException = err
+			}
+		}
+	}
+	return SipException
+}
+
+/** Processes a response to a REGISTER. A 2xx starts the keep-alives of the
+ * flow if the registrar requires outbound and schedules the refresh. A 423
+ * sends the request again with the Min-Expires of the response. A 408 or
+ * 5xx is a flow failure; other errors end the registration.
+ */
+func (this *OutboundClient) ProcessResponse(response message.Response) {
+	statusCode := response.GetStatusCode()
+	if statusCode < message.OK {
+		return
+	}
+
+	this.mutex.Lock()
+	defer this.unlock()
+
+	key := transactionKey(response)
+	registration := this.transactions[key]
+	delete(this.transactions, key)
+	if registration == nil || registration.removed {
+		if registration != nil && statusCode < message.MULTIPLE_CHOICES {
+			registration.setRegistered(false)
+		}
+		return
+	}
+
+	switch {
+	case statusCode < message.MULTIPLE_CHOICES:
+		registration.failures = 0
+		if gruu := GetPublicGruu(response, this.instanceId); gruu != nil {
+			this.publicGruu = gruu
+		}
+		if gruu := GetTemporaryGruu(response, this.instanceId); gruu != nil {
+			this.temporaryGruu = gruu
+		}
+		registration.outbound = IsOptionRequired(response, OPTION_OUTBOUND)
+		registration.startRefresh(registration.getGrantedExpires(response))
+		if registration.outbound {
+			interval := OUTBOUND_CONNECTION_KEEPALIVE
+			if strings.EqualFold(registration.flow.GetTransport(), UDP) {
+				interval = OUTBOUND_DATAGRAM_KEEPALIVE
+			}
+			if flowTimer, ok := response.GetHeader(core.SIPHeaderNames_FLOW_TIMER).(header.FlowTimerHeader); ok {
+				interval = flowTimer.GetSeconds()
+			}
+			registration.keepAliveInterval = interval
+			registration.startKeepAlive()
+		}
+		registration.setRegistered(true)
+	case statusCode == message.INTERVAL_TOO_BRIEF:
+		if minExpires, ok := response.GetHeader(core.SIPHeaderNames_MIN_EXPIRES).(header.MinExpiresHeader); ok {
+			registration.expires = minExpires.GetExpires()
+			if err := registration.sendRegister(registration.expires); err != nil {
+				registration.fail()
+			}
+		}
+	case statusCode == message.REQUEST_TIMEOUT || statusCode >= message.SERVER_INTERNAL_ERROR && statusCode < message.BUSY_EVERYWHERE:
+		registration.fail()
+	default:
+		registration.stopTimers()
+		registration.setRegistered(false)
+	}
+}
+
+/** Processes a REGISTER whose transaction timed out: a flow failure.
+ */
+func (this *OutboundClient) ProcessTimeout(request message.Request) {
+	this.mutex.Lock()
+	defer this.unlock()
+	key := transactionKey(request)
+	registration := this.transactions[key]
+	delete(this.transactions, key)
+	if registration != nil && !registration.removed {
+		registration.fail()
+	}
+}
+
+/** Processes keep-alive data received on flow: a CRLF pong or a STUN
+ * Binding response. A change of the reflexive address returned by STUN
+ * means the NAT binding changed, which is a flow failure.
+ */
+func (this *OutboundClient) ProcessKeepAlive(flow Flow, data []byte) {
+	this.mutex.Lock()
+	defer this.unlock()
+	registration := this.findRegistration(flow)
+	if registration == nil || registration.pongTimer == nil {
+		return
+	}
+
+	if stun.IsMessage(data) {
+		response, err := stun.Parse(data)
+		if err != nil || registration.stunRequest == nil ||
+			response.TransactionId != registration.stunRequest.TransactionId || !response.IsSuccessResponse() {
+			return
+		}
+		if mapped := response.GetMappedAddress(); mapped != nil {
+			if registration.mappedAddress != "" && registration.mappedAddress != mapped.String() {
+				registration.fail()
+				return
+			}
+			registration.mappedAddress = mapped.String()
+		}
+	} else if string(data) != OUTBOUND_PONG {
+		return
+	}
+	registration.startKeepAlive()
+}
+
+/** Processes the failure of a flow reported by the transport layer, e.g. a
+ * closed connection.
+ */
+func (this *OutboundClient) ProcessFlowFailure(flow Flow) {
+	this.mutex.Lock()
+	defer this.unlock()
+	if registration := this.findRegistration(flow); registration != nil && !registration.removed {
+		registration.fail()
+	}
+}
+
+func (this *OutboundClient) findRegistration(flow Flow) *OutboundRegistration {
+	for _, registration := range this.registrations {
+		if registration.flow == flow {
+			return registration
+		}
+	}
+	return nil
+}
+
+/** Returns true if a registration other than registration is registered.
+ */
+func (this *OutboundClient) isOtherRegistered(registration *OutboundRegistration) bool {
+	for _, r := range this.registrations {
+		if r != registration && r.registered {
+			return true
+		}
+	}
+	return false
+}
+
+/** Unlocks the client and informs the listener of the registrations whose
+ * state changed meanwhile.
+ */
+func (this *OutboundClient) unlock() {
+	notifications := this.notifications
+	this.notifications = nil
+	listener := this.listener
+	this.mutex.Unlock()
+	if listener != nil {
+		for _, registration := range notifications {
+			listener.ProcessRegistrationState(registration)
+		}
+	}
+}
+
+/**
+ * The registration of an OutboundClient over one flow.
+ */
+type OutboundRegistration struct {
+	client *OutboundClient
+
+	regId             int
+	flow              Flow
+	request           *message.SIPRequest
+	callId            string
+	expires           int
+	registered        bool
+	outbound          bool
+	removed           bool
+	keepAliveInterval int
+	failures          int
+	stunRequest       *stun.Message
+	mappedAddress     string
+	generation        int
+	refreshTimer      *time.Timer
+	keepAliveTimer    *time.Timer
+	pongTimer         *time.Timer
+	retryTimer        *time.Timer
+}
+
+/** Returns the reg-id of the registration.
+ */
+func (this *OutboundRegistration) GetRegId() int {
+	return this.regId
+}
+
+/** Returns the current flow of the registration.
+ */
+func (this *OutboundRegistration) GetFlow() Flow {
+	this.client.mutex.Lock()
+	defer this.client.mutex.Unlock()
+	return this.flow
+}
+
+/** Returns true while the contact is registered over the flow.
+ */
+func (this *OutboundRegistration) IsRegistered() bool {
+	this.client.mutex.Lock()
+	defer this.client.mutex.Unlock()
+	return this.registered
+}
+
+/** Returns true if the registrar supports outbound for the registration,
+ * in which case its flow is kept alive.
+ */
+func (this *OutboundRegistration) IsOutbound() bool {
+	this.client.mutex.Lock()
+	defer this.client.mutex.Unlock()
+	return this.outbound
+}
+
+func (this *OutboundRegistration) sendRegister(expires int) error {
+	// the REGISTER is a copy of the previous one with a new CSeq and branch
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(this.request.String())
+	if err != nil {
+		return err
+	}
+	register := msg.(*message.SIPRequest)
+	register.GetCSeq().SetSequenceNumber(this.request.GetCSeqNumber() + 1)
+	if via := register.GetTopmostVia(); via != nil {
+		via.SetBranch(header.SIPConstants_BRANCH_MAGIC_COOKIE + NewToken(8))
+	}
+	if this.callId != "" {
+		register.SetCallIdFromString(this.callId)
+	}
+	if contact, ok := register.GetHeader(core.SIPHeaderNames_CONTACT).(*header.Contact); ok {
+		contact.RemoveParameter(header.ParameterNames_EXPIRES)
+		contact.SetInstanceId(this.client.instanceId)
+		contact.SetRegId(this.regId)
+	}
+	expiresHeader := header.NewExpires()
+	expiresHeader.SetExpires(expires)
+	register.SetHeader(expiresHeader)
+	AddSupported(register, OPTION_OUTBOUND)
+	AddSupported(register, OPTION_PATH)
+	AddSupported(register, OPTION_GRUU)
+	register.RemoveHeader(core.SIPHeaderNames_ROUTE)
+	if proxy := this.flow.GetOutboundProxy(); proxy != nil {
+		routeList := header.NewRouteList()
+		routeList.PushBack(header.NewRouteFromAddress(proxy))
+		register.SetHeader(routeList)
+	}
+	this.request = register
+
+	ct, err := this.client.provider.GetNewClientTransaction(register)
+	if err != nil {
+		return err
+	}
+	if err = ct.SendRequest(); err != nil {
+		return err
+	}
+	this.client.transactions[transactionKey(register)] = this
+	return nil
+}
+
+/** Returns the duration granted by the registrar: the expires parameter of
+ * the Contact of this instance and reg-id, or else the Expires header.
+ */
+func (this *OutboundRegistration) getGrantedExpires(response message.Response) int {
+	for e := response.GetHeaders(core.SIPHeaderNames_CONTACT).Front(); e != nil; e = e.Next() {
+		if contact, ok := e.Value.(*header.Contact); ok && contact.GetRegId() == this.regId &&
+			strings.EqualFold(contact.GetInstanceId(), this.client.instanceId) &&
+			contact.HasParameter(header.ParameterNames_EXPIRES) {
+			return contact.GetExpires()
+		}
+	}
+	if expiresHeader, ok := response.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader); ok {
+		return expiresHeader.GetExpires()
+	}
+	return this.expires
+}
+
+func (this *OutboundRegistration) setRegistered(registered bool) {
+	if this.registered != registered {
+		this.registered = registered
+		this.client.notifications = append(this.client.notifications, this)
+	}
+}
+
+/** Handles the failure of the flow: the registration is lost and a new flow
+ * is registered after a random wait that doubles with each consecutive
+ * failure.
+ */
+func (this *OutboundRegistration) fail() {
+	this.stopTimers()
+	this.setRegistered(false)
+	this.mappedAddress = ""
+
+	baseTime := OUTBOUND_BASE_TIME_ALL_FAILED
+	if this.client.isOtherRegistered(this) {
+		baseTime = OUTBOUND_BASE_TIME_SOME_FAILED
+	}
+	delay := time.Duration(outboundRetryTime(baseTime, this.failures)) * this.client.unit / 2
+	delay += time.Duration(rand.Int63n(int64(delay) + 1))
+	this.failures++
+
+	generation := this.generation
+	this.retryTimer = time.AfterFunc(delay, func() {
+		this.client.mutex.Lock()
+		listener := this.client.listener
+		if generation != this.generation || this.removed || listener == nil {
+			this.client.mutex.Unlock()
+			return
+		}
+		this.client.mutex.Unlock()
+
+		flow, err := listener.CreateFlow(this.regId)
+
+		this.client.mutex.Lock()
+		defer this.client.unlock()
+		if generation != this.generation || this.removed {
+			return
+		}
+		this.retryTimer = nil
+		if err == nil {
+			this.flow = flow
+			err = this.sendRegister(this.expires)
+		}
+		if err != nil {
+			this.fail()
+		}
+	})
+}
+
+/** Returns the upper bound in seconds of the wait before a flow is
+ * registered again after consecutive failures: baseTime doubled with each
+ * failure, up to OUTBOUND_MAX_TIME. The wait is chosen at random between
+ * half of it and all of it.
+ */
+func outboundRetryTime(baseTime int, failures int) int {
+	if failures < 16 && baseTime<<uint(failures) < OUTBOUND_MAX_TIME {
+		return baseTime << uint(failures)
+	}
+	return OUTBOUND_MAX_TIME
+}
+
+/** Schedules the refresh no later than 32 seconds before the registration
+ * expires, and at half its duration for short registrations.
+ */
+func (this *OutboundRegistration) startRefresh(expires int) {
+	if this.refreshTimer != nil {
+		this.refreshTimer.Stop()
+	}
+	unit := this.client.unit
+	interval := time.Duration(expires) * unit
+	guard := interval / 2
+	if guard > 32*unit {
+		guard = 32 * unit
+	}
+	generation := this.generation
+	this.refreshTimer = time.AfterFunc(interval-guard, func() {
+		this.client.mutex.Lock()
+		defer this.client.unlock()
+		if generation == this.generation && !this.removed {
+			this.refreshTimer = nil
+			if err := this.sendRegister(this.expires); err != nil {
+				this.fail()
+			}
+		}
+	})
+}
+
+/** Schedules the next keep-alive at a random time between 80 and 100
+ * percent of the keep-alive interval.
+ */
+func (this *OutboundRegistration) startKeepAlive() {
+	this.stopKeepAlive()
+	interval := time.Duration(this.keepAliveInterval) * this.client.unit
+	delay := interval*4/5 + time.Duration(rand.Int63n(int64(interval/5)+1))
+	generation := this.generation
+	this.keepAliveTimer = time.AfterFunc(delay, func() {
+		this.client.mutex.Lock()
+		defer this.client.unlock()
+		if generation == this.generation && !this.removed {
+			this.keepAliveTimer = nil
+			this.sendKeepAlive()
+		}
+	})
+}
+
+func (this *OutboundRegistration) sendKeepAlive() {
+	var ping []byte
+	if strings.EqualFold(this.flow.GetTransport(), UDP) {
+		this.stunRequest = stun.NewBindingRequest()
+		ping = this.stunRequest.Encode()
+	} else {
+		ping = []byte(OUTBOUND_PING)
+	}
+	if err := this.flow.Send(ping); err != nil {
+		this.fail()
+		return
+	}
+	generation := this.generation
+	this.pongTimer = time.AfterFunc(OUTBOUND_PONG_TIMEOUT*this.client.unit, func() {
+		this.client.mutex.Lock()
+		defer this.client.unlock()
+		if generation == this.generation && !this.removed && this.pongTimer != nil {
+			this.pongTimer = nil
+			this.fail()
+		}
+	})
+}
+
+func (this *OutboundRegistration) stopKeepAlive() {
+	for _, timer := range []**time.Timer{&this.keepAliveTimer, &this.pongTimer} {
+		if *timer != nil {
+			(*timer).Stop()
+			*timer = nil
+		}
+	}
+	this.stunRequest = nil
+}
+
+func (this *OutboundRegistration) stopTimers() {
+	this.generation++
+	this.stopKeepAlive()
+	for _, timer := range []**time.Timer{&this.refreshTimer, &this.retryTimer} {
+		if *timer != nil {
+			(*timer).Stop()
+			*timer = nil
+		}
+	}
+}
+
+/** Returns the host of the Contact of request, used in the Call-ID of the
+ * other flows.
+ */
+func contactHost(request *message.SIPRequest) string {
+	if contact, ok := request.GetHeader(core.SIPHeaderNames_CONTACT).(*header.Contact); ok {
+		if uri, ok := contact.GetAddress().GetURI().(*address.SipURIImpl); ok {
+			return uri.GetHost()
+		}
+	}
+	return "localhost"
+}
@@ -0,0 +1,263 @@
+package sip
+
+import (
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"gosips/stun"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const instanceId = "urn:uuid:00000000-0000-1000-8000-000A95A0E128"
+
+// fakeFlow records the keep-alives sent over it.
+type fakeFlow struct {
+	mutex     sync.Mutex
+	transport string
+	proxy     string
+	sent      [][]byte
+}
+
+func (this *fakeFlow) GetTransport() string {
+	return this.transport
+}
+
+func (this *fakeFlow) GetOutboundProxy() address.Address {
+	proxy, _ := parser.NewAddressParser("<sip:" + this.proxy + ";lr>").Address()
+	return proxy
+}
+
+func (this *fakeFlow) Send(data []byte) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.sent = append(this.sent, data)
+	return nil
+}
+
+func (this *fakeFlow) getSent() [][]byte {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return append([][]byte(nil), this.sent...)
+}
+
+type fakeOutboundListener struct {
+	mutex  sync.Mutex
+	flows  int
+	states []bool
+}
+
+func (this *fakeOutboundListener) CreateFlow(regId int) (Flow, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.flows++
+	return &fakeFlow{transport: TCP, proxy: "edge2.example.com"}, nil
+}
+
+func (this *fakeOutboundListener) ProcessRegistrationState(registration *OutboundRegistration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.states = append(this.states, registration.IsRegistered())
+}
+
+func (this *fakeOutboundListener) getFlows() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.flows
+}
+
+func (this *fakeOutboundListener) getStates() []bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return append([]bool(nil), this.states...)
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(3 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func outboundRegister(t *testing.T) *message.SIPRequest {
+	return parseRequest(t, "REGISTER sip:example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/TCP 192.0.2.2;branch=z9hG4bKregister\r\n"+
+		"From: <sip:bob@example.com>;tag=bob-tag\r\n"+
+		"To: <sip:bob@example.com>\r\n"+
+		"Call-ID: register@192.0.2.2\r\n"+
+		"CSeq: 1 REGISTER\r\n"+
+		"Contact: <sip:bob@192.0.2.2;transport=tcp;ob>\r\n"+
+		"Max-Forwards: 70\r\n"+
+		"Content-Length: 0\r\n\r\n")
+}
+
+// registerResponse returns the 200 (OK) of a registrar supporting outbound
+// to register.
+func registerResponse(t *testing.T, register *message.SIPRequest, flowTimer string) *message.SIPResponse {
+	response := register.CreateResponse(message.OK).String()
+	return parseResponse(t, strings.Replace(response, "Content-Length:",
+		"Require: outbound\r\nFlow-Timer: "+flowTimer+"\r\nExpires: 3600\r\nContent-Length:", 1))
+}
+
+func TestOutboundRetryTime(t *testing.T) {
+	tests := []struct {
+		baseTime, failures, retryTime int
+	}{
+		{OUTBOUND_BASE_TIME_ALL_FAILED, 0, 30},
+		{OUTBOUND_BASE_TIME_ALL_FAILED, 1, 60},
+		{OUTBOUND_BASE_TIME_ALL_FAILED, 5, 960},
+		{OUTBOUND_BASE_TIME_ALL_FAILED, 6, OUTBOUND_MAX_TIME},
+		{OUTBOUND_BASE_TIME_SOME_FAILED, 0, 90},
+		{OUTBOUND_BASE_TIME_SOME_FAILED, 4, 1440},
+		{OUTBOUND_BASE_TIME_SOME_FAILED, 64, OUTBOUND_MAX_TIME},
+	}
+	for _, test := range tests {
+		if retryTime := outboundRetryTime(test.baseTime, test.failures); retryTime != test.retryTime {
+			t.Errorf("%d after %d failures: expected %d, got %d", test.baseTime, test.failures, test.retryTime, retryTime)
+		}
+	}
+}
+
+func TestOutboundRegistration(t *testing.T) {
+	provider := &fakeProvider{}
+	client := NewOutboundClient(provider, instanceId)
+	client.unit = 10 * time.Millisecond
+	listener := &fakeOutboundListener{}
+	client.SetOutboundListener(listener)
+
+	tcp := &fakeFlow{transport: TCP, proxy: "edge1.example.com"}
+	udp := &fakeFlow{transport: UDP, proxy: "edge1.example.com"}
+	if err := client.Register(outboundRegister(t), tcp, udp); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Unregister()
+	sent := provider.getSent()
+	if len(sent) != 2 {
+		t.Fatal("expected a REGISTER per flow, got", len(sent))
+	}
+	for i, request := range sent {
+		register := reparse(t, request).(*message.SIPRequest)
+		contact := register.GetHeader(core.SIPHeaderNames_CONTACT).(*header.Contact)
+		route, ok := register.GetHeader(core.SIPHeaderNames_ROUTE).(header.RouteHeader)
+		if contact.GetRegId() != i+1 || contact.GetInstanceId() != instanceId || !ok ||
+			route.GetAddress().GetURI().String() != "sip:edge1.example.com;lr" ||
+			!IsOptionSupported(register, OPTION_OUTBOUND) || !IsOptionSupported(register, OPTION_PATH) {
+			t.Errorf("bad REGISTER over flow %d: %s", i+1, register)
+		}
+	}
+	if sent[0].GetHeader(core.SIPHeaderNames_CALL_ID).(header.CallIdHeader).GetCallId() ==
+		sent[1].GetHeader(core.SIPHeaderNames_CALL_ID).(header.CallIdHeader).GetCallId() {
+		t.Error("flows registered with the same Call-ID")
+	}
+	start := time.Now()
+	client.ProcessResponse(registerResponse(t, reparse(t, sent[0]).(*message.SIPRequest), "20"))
+	client.ProcessResponse(registerResponse(t, reparse(t, sent[1]).(*message.SIPRequest), "20"))
+	registrations := client.GetRegistrations()
+	for _, registration := range registrations {
+		if !registration.IsRegistered() || !registration.IsOutbound() {
+			t.Fatal("flow not registered with outbound", registration.GetRegId())
+		}
+	}
+	if states := listener.getStates(); len(states) != 2 || !states[0] || !states[1] {
+		t.Error("registrations not reported", states)
+	}
+
+	// the TCP flow is kept alive with CRLF, within the Flow-Timer
+	waitFor(t, "CRLF keep-alive", func() bool { return len(tcp.getSent()) > 0 })
+	if elapsed := time.Since(start); elapsed < 16*client.unit || elapsed > 25*client.unit {
+		t.Error("keep-alive sent after", elapsed)
+	}
+	if ping := string(tcp.getSent()[0]); ping != OUTBOUND_PING {
+		t.Errorf("bad keep-alive %q", ping)
+	}
+	client.ProcessKeepAlive(tcp, []byte(OUTBOUND_PONG))
+
+	// the UDP flow with STUN: a new reflexive address is a flow failure
+	waitFor(t, "STUN keep-alive", func() bool { return len(udp.getSent()) > 0 })
+	request, err := stun.Parse(udp.getSent()[0])
+	if err != nil || !request.IsRequest() {
+		t.Fatal("bad STUN keep-alive", err)
+	}
+	client.ProcessKeepAlive(udp, stun.NewBindingResponse(request, &net.UDPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 5062}).Encode())
+	if !registrations[1].IsRegistered() {
+		t.Fatal("UDP flow failed")
+	}
+	waitFor(t, "second STUN keep-alive", func() bool { return len(udp.getSent()) > 1 })
+	request, _ = stun.Parse(udp.getSent()[1])
+	client.ProcessKeepAlive(udp, stun.NewBindingResponse(request, &net.UDPAddr{IP: net.IPv4(203, 0, 113, 8), Port: 5062}).Encode())
+	if registrations[1].IsRegistered() {
+		t.Error("NAT binding change not a flow failure")
+	}
+
+	// the TCP flow does not answer: after the back-off a new flow is
+	// registered
+	waitFor(t, "second CRLF keep-alive", func() bool { return len(tcp.getSent()) > 1 })
+	failed := time.Now()
+	waitFor(t, "pong timeout", func() bool { return !registrations[0].IsRegistered() })
+	if elapsed := time.Since(failed); elapsed < OUTBOUND_PONG_TIMEOUT*client.unit/2 {
+		t.Error("flow failed after", elapsed)
+	}
+	waitFor(t, "new flows", func() bool { return listener.getFlows() == 2 })
+	if elapsed := time.Since(failed); elapsed < OUTBOUND_BASE_TIME_ALL_FAILED*client.unit/2 {
+		t.Error("flow replaced after", elapsed)
+	}
+	waitFor(t, "REGISTER over the new flows", func() bool { return len(provider.getSent()) == 4 })
+	for _, request := range provider.getSent()[2:] {
+		route := request.GetHeader(core.SIPHeaderNames_ROUTE).(header.RouteHeader)
+		if route.GetAddress().GetURI().String() != "sip:edge2.example.com;lr" {
+			t.Error("REGISTER not sent over the new flow", request)
+		}
+	}
+	if flow := registrations[0].GetFlow(); flow == Flow(tcp) {
+		t.Error("failed flow kept")
+	}
+}
+
+func TestOutboundFlowFailure(t *testing.T) {
+	provider := &fakeProvider{}
+	client := NewOutboundClient(provider, instanceId)
+	client.unit = 10 * time.Millisecond
+	listener := &fakeOutboundListener{}
+	client.SetOutboundListener(listener)
+	flow := &fakeFlow{transport: TCP, proxy: "edge1.example.com"}
+	if err := client.Register(outboundRegister(t), flow); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Unregister()
+
+	// a 503 is a flow failure, retried after 15 to 30 units
+	failed := time.Now()
+	client.ProcessResponse(provider.lastSent(t).CreateResponse(message.SERVICE_UNAVAILABLE))
+	waitFor(t, "new flow", func() bool { return len(provider.getSent()) == 2 })
+	if elapsed := time.Since(failed); elapsed < 15*client.unit || elapsed > 60*client.unit {
+		t.Error("first retry after", elapsed)
+	}
+
+	// the connection of the new flow closes: the wait doubles
+	registration := client.GetRegistrations()[0]
+	failed = time.Now()
+	client.ProcessFlowFailure(registration.GetFlow())
+	waitFor(t, "second new flow", func() bool { return len(provider.getSent()) == 3 })
+	if elapsed := time.Since(failed); elapsed < 30*client.unit {
+		t.Error("second retry after", elapsed)
+	}
+
+	client.ProcessResponse(registerResponse(t, provider.lastSent(t), "120"))
+	if !registration.IsRegistered() {
+		t.Fatal("new flow not registered")
+	}
+	if err := client.Unregister(); err != nil {
+		t.Fatal(err)
+	}
+	if unregister := provider.lastSent(t); unregister.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader).GetExpires() != 0 {
+		t.Error("bad unregistration", unregister)
+	}
+}
@@ -0,0 +1,553 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Publication.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"crypto/rand"
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * The duration in seconds of a publication whose PUBLISH has no Expires
+ * header, unless the event package defines another one.
+ */
+const PUBLICATION_DEFAULT_EXPIRES = 3600
+
+/**
+ * The event state of one publication, as reported to an EventStateListener.
+ */
+type EventState struct {
+	eventType     string
+	resource      address.URI
+	publicationId string
+	contentType   header.ContentTypeHeader
+	content       string
+}
+
+/** Returns the event package of the publication.
+ */
+func (this *EventState) GetEventType() string {
+	return this.eventType
+}
+
+/** Returns the resource the state is published for, the Request-URI of the
+ * PUBLISH.
+ */
+func (this *EventState) GetResource() address.URI {
+	return this.resource
+}
+
+/** Returns an identifier of the publication that, unlike its entity tag,
+ * stays the same for the lifetime of the publication.
+ */
+func (this *EventState) GetPublicationId() string {
+	return this.publicationId
+}
+
+/** Returns the content type of the published state.
+ */
+func (this *EventState) GetContentType() header.ContentTypeHeader {
+	return this.contentType
+}
+
+/** Returns the published state, or an empty string once the publication is
+ * removed or expired.
+ */
+func (this *EventState) GetContent() string {
+	return this.content
+}
+
+/** Returns true if the publication was removed or expired.
+ */
+func (this *EventState) IsRemoved() bool {
+	return this.content == ""
+}
+
+/**
+ * This interface is implemented by event packages, e.g. a presence agent, to
+ * compose the state published for a resource.
+ */
+type EventStateListener interface {
+	/**
+	 * Called when a publication is created, modified, removed or expires.
+	 * Refreshes that do not change the state are not reported.
+	 */
+	ProcessEventState(state *EventState)
+}
+
+/**
+ * An Event State Compositor as described in RFC 3903: it accepts PUBLISH
+ * requests for the event packages registered with RegisterEventPackage and
+ * keeps each publication under an entity tag, which is replaced by a new one
+ * on every successful PUBLISH. A PUBLISH with a SIP-If-Match header refreshes
+ * (no body), modifies (with a body) or removes ("Expires: 0") the publication
+ * with that entity tag, or is rejected with 412 if there is none, e.g.
+ * because the publication expired.
+ */
+type EventStateCompositor struct {
+	mutex sync.Mutex
+
+	listeners      map[string]EventStateListener
+	defaultExpires int
+	minExpires     int
+	maxExpires     int
+	publications   map[string]*serverPublication
+	nextId         int
+}
+
+type serverPublication struct {
+	id          string
+	eventType   string
+	resource    address.URI
+	eTag        string
+	contentType header.ContentTypeHeader
+	content     string
+	timer       *time.Timer
+}
+
+/** Creates an Event State Compositor.
+ */
+func NewEventStateCompositor() *EventStateCompositor {
+	return &EventStateCompositor{
+		listeners:      make(map[string]EventStateListener),
+		defaultExpires: PUBLICATION_DEFAULT_EXPIRES,
+		publications:   make(map[string]*serverPublication),
+	}
+}
+
+/** Accepts publications for an event package, reporting them to listener.
+ */
+func (this *EventStateCompositor) RegisterEventPackage(eventType string, listener EventStateListener) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.listeners[strings.ToLower(eventType)] = listener
+}
+
+/** Sets the duration in seconds of publications whose PUBLISH has no Expires
+ * header.
+ */
+func (this *EventStateCompositor) SetDefaultExpires(defaultExpires int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.defaultExpires = defaultExpires
+}
+
+/** Sets the shortest duration in seconds accepted for a publication; shorter
+ * ones are rejected with 423. 0 accepts any duration.
+ */
+func (this *EventStateCompositor) SetMinExpires(minExpires int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.minExpires = minExpires
+}
+
+/** Sets the longest duration in seconds granted to a publication; longer ones
+ * are shortened. 0 grants any duration.
+ */
+func (this *EventStateCompositor) SetMaxExpires(maxExpires int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.maxExpires = maxExpires
+}
+
+/** Processes a PUBLISH and returns the response to send: 200 with SIP-ETag
+ * and Expires if it was accepted, 489 for an unregistered event package, 423
+ * with Min-Expires for a too short duration, 412 for an unknown entity tag
+ * and 400 for an initial PUBLISH without state.
+ */
+func (this *EventStateCompositor) ProcessPublish(publish *message.SIPRequest) *message.SIPResponse {
+	event, ok := publish.GetHeader(core.SIPHeaderNames_EVENT).(header.EventHeader)
+	if !ok {
+		return publish.CreateResponse(message.BAD_EVENT)
+	}
+
+	this.mutex.Lock()
+	listener, ok := this.listeners[strings.ToLower(event.GetEventType())]
+	if !ok {
+		this.mutex.Unlock()
+		return publish.CreateResponse(message.BAD_EVENT)
+	}
+	expires := this.defaultExpires
+	if expiresHeader, ok := publish.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader); ok {
+		expires = expiresHeader.GetExpires()
+	}
+	if expires > 0 && expires < this.minExpires {
+		this.mutex.Unlock()
+		response := publish.CreateResponse(message.INTERVAL_TOO_BRIEF)
+		minExpires := header.NewMinExpires()
+		minExpires.SetExpires(this.minExpires)
+		response.SetHeader(minExpires)
+		return response
+	}
+	if this.maxExpires > 0 && expires > this.maxExpires {
+		expires = this.maxExpires
+	}
+
+	var publication *serverPublication
+	var state *EventState
+	if ifMatch, ok := publish.GetHeader(core.SIPHeaderNames_SIP_IF_MATCH).(header.SIPIfMatchHeader); ok {
+		publication = this.publications[ifMatch.GetETag()]
+		if publication == nil || !strings.EqualFold(publication.eventType, event.GetEventType()) ||
+			publication.resource.String() != publish.GetRequestURI().String() {
+			this.mutex.Unlock()
+			return publish.CreateResponse(message.CONDITIONAL_REQUEST_FAILED)
+		}
+		delete(this.publications, publication.eTag)
+		publication.timer.Stop()
+		if expires == 0 {
+			publication.content = ""
+			state = publication.getState()
+		} else if body := publish.GetContent(); body != "" {
+			publication.content = body
+			publication.contentType, _ = publish.GetHeader(core.SIPHeaderNames_CONTENT_TYPE).(header.ContentTypeHeader)
+			state = publication.getState()
+		}
+	} else {
+		body := publish.GetContent()
+		if body == "" || expires == 0 {
+			this.mutex.Unlock()
+			return publish.CreateResponse(message.BAD_REQUEST)
+		}
+		this.nextId++
+		publication = &serverPublication{
+			id:        event.GetEventType() + "-" + NewToken(4) + "-" + strconv.Itoa(this.nextId),
+			eventType: event.GetEventType(),
+			resource:  publish.GetRequestURI(),
+			content:   body,
+		}
+		publication.contentType, _ = publish.GetHeader(core.SIPHeaderNames_CONTENT_TYPE).(header.ContentTypeHeader)
+		state = publication.getState()
+	}
+
+	response := publish.CreateResponse(message.OK)
+	expiresHeader := header.NewExpires()
+	expiresHeader.SetExpires(expires)
+	response.SetHeader(expiresHeader)
+	if expires > 0 {
+		publication.eTag = NewToken(8)
+		this.publications[publication.eTag] = publication
+		this.startExpiry(publication, listener, expires)
+		eTag := header.NewSIPETag()
+		eTag.SetETag(publication.eTag)
+		response.SetHeader(eTag)
+	}
+	this.mutex.Unlock()
+
+	if state != nil {
+		listener.ProcessEventState(state)
+	}
+	return response
+}
+
+func (this *EventStateCompositor) startExpiry(publication *serverPublication, listener EventStateListener, expires int) {
+	eTag := publication.eTag
+	publication.timer = time.AfterFunc(time.Duration(expires)*time.Second, func() {
+		this.mutex.Lock()
+		if this.publications[eTag] != publication {
+			this.mutex.Unlock()
+			return
+		}
+		delete(this.publications, eTag)
+		publication.content = ""
+		state := publication.getState()
+		this.mutex.Unlock()
+		listener.ProcessEventState(state)
+	})
+}
+
+func (this *serverPublication) getState() *EventState {
+	return &EventState{
+		eventType:     this.eventType,
+		resource:      this.resource,
+		publicationId: this.id,
+		contentType:   this.contentType,
+		content:       this.content,
+	}
+}
+
+/**
+ * Publishes event state on behalf of an Event Publication Agent as described
+ * in RFC 3903. Publications are refreshed automatically before they expire;
+ * a publication the compositor lost (412) is published again from scratch.
+ */
+type Publisher struct {
+	mutex sync.Mutex
+
+	provider     SipProvider
+	transactions map[string]*ClientPublication
+}
+
+/** Creates a publisher that sends its PUBLISH requests through provider.
+ */
+func NewPublisher(provider SipProvider) *Publisher {
+	return &Publisher{
+		provider:     provider,
+		transactions: make(map[string]*ClientPublication),
+	}
+}
+
+/** Sends an initial PUBLISH created by the application, which must carry an
+ * Event header and the event state. An Expires header of
+ * PUBLICATION_DEFAULT_EXPIRES seconds is added if there is none.
+ */
+func (this *Publisher) Publish(publish message.Request) (publication *ClientPublication, SipException error) {
+	request, ok := publish.(*message.SIPRequest)
+	if !ok {
+		return nil, errors.New("SipException: unsupported request type")
+	}
+	if _, ok := request.GetHeader(core.SIPHeaderNames_EVENT).(header.EventHeader); !ok {
+		return nil, errors.New("SipException: PUBLISH without Event")
+	}
+	if request.GetContent() == "" {
+		return nil, errors.New("SipException: initial PUBLISH without state")
+	}
+	expires, ok := request.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader)
+	if !ok {
+		expires = header.NewExpires()
+		expires.SetExpires(PUBLICATION_DEFAULT_EXPIRES)
+		request.SetHeader(expires)
+	}
+
+	publication = &ClientPublication{
+		publisher: this,
+		request:   request,
+		expires:   expires.GetExpires(),
+	}
+	publication.contentType, _ = request.GetHeader(core.SIPHeaderNames_CONTENT_TYPE).(header.ContentTypeHeader)
+	publication.content = request.GetContent()
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if err := publication.send(request); err != nil {
+		return nil, err
+	}
+	return publication, nil
+}
+
+/** Processes a response to a PUBLISH. A 2xx stores the new entity tag and
+ * schedules the refresh. A 412 publishes the state again without entity tag
+ * and a 423 sends the request again with the Min-Expires of the response.
+ * Other error responses end the publication.
+ */
+func (this *Publisher) ProcessResponse(response message.Response) {
+	statusCode := response.GetStatusCode()
+	if statusCode < message.OK {
+		return
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	key := transactionKey(response)
+	publication := this.transactions[key]
+	delete(this.transactions, key)
+	if publication == nil || publication.removed && statusCode >= message.MULTIPLE_CHOICES {
+		return
+	}
+
+	switch {
+	case statusCode < message.MULTIPLE_CHOICES:
+		if publication.removed {
+			publication.eTag = ""
+			return
+		}
+		if eTag, ok := response.GetHeader(core.SIPHeaderNames_SIP_ETAG).(header.SIPETagHeader); ok {
+			publication.eTag = eTag.GetETag()
+		}
+		expires := publication.expires
+		if expiresHeader, ok := response.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader); ok && expiresHeader.GetExpires() > 0 {
+			expires = expiresHeader.GetExpires()
+		}
+		publication.startRefresh(expires)
+	case statusCode == message.CONDITIONAL_REQUEST_FAILED:
+		publication.eTag = ""
+		publication.publish(publication.content)
+	case statusCode == message.INTERVAL_TOO_BRIEF:
+		if minExpires, ok := response.GetHeader(core.SIPHeaderNames_MIN_EXPIRES).(header.MinExpiresHeader); ok {
+			publication.expires = minExpires.GetExpires()
+			publication.publish(publication.content)
+		}
+	default:
+		publication.stopRefresh()
+		publication.eTag = ""
+	}
+}
+
+/**
+ * A publication of a Publisher.
+ */
+type ClientPublication struct {
+	publisher *Publisher
+
+	request     *message.SIPRequest
+	contentType header.ContentTypeHeader
+	content     string
+	expires     int
+	eTag        string
+	removed     bool
+	generation  int
+	timer       *time.Timer
+}
+
+/** Returns the current entity tag of the publication, or an empty string if
+ * it is not published.
+ */
+func (this *ClientPublication) GetETag() string {
+	this.publisher.mutex.Lock()
+	defer this.publisher.mutex.Unlock()
+	return this.eTag
+}
+
+/** Returns true while the compositor holds the publication.
+ */
+func (this *ClientPublication) IsPublished() bool {
+	return this.GetETag() != ""
+}
+
+/** Replaces the published state.
+ */
+func (this *ClientPublication) Modify(contentType header.ContentTypeHeader, content string) (SipException error) {
+	this.publisher.mutex.Lock()
+	defer this.publisher.mutex.Unlock()
+	if this.removed {
+		return errors.New("SipException: the publication is removed")
+	}
+	if content == "" {
+		return errors.New("SipException: empty event state")
+	}
+	this.contentType = contentType
+	this.content = content
+	return this.publish(content)
+}
+
+/** Refreshes the publication at once.
+ */
+func (this *ClientPublication) Refresh() (SipException error) {
+	this.publisher.mutex.Lock()
+	defer this.publisher.mutex.Unlock()
+	if this.removed {
+		return errors.New("SipException: the publication is removed")
+	}
+	return this.publish("")
+}
+
+/** Removes the publication with a PUBLISH with "Expires: 0".
+ */
+func (this *ClientPublication) Remove() (SipException error) {
+	this.publisher.mutex.Lock()
+	defer this.publisher.mutex.Unlock()
+	if this.removed {
+		return nil
+	}
+	this.removed = true
+	this.stopRefresh()
+	if this.eTag == "" {
+		return nil
+	}
+	return this.sendPublish("", 0)
+}
+
+/** Sends a PUBLISH carrying content: a refresh if content is empty, a
+ * modification otherwise, or an initial publication if there is no entity
+ * tag yet.
+ */
+func (this *ClientPublication) publish(content string) error {
+	if this.eTag == "" {
+		content = this.content
+	}
+	return this.sendPublish(content, this.expires)
+}
+
+func (this *ClientPublication) sendPublish(content string, expires int) error {
+	// there is no dialog, so the PUBLISH is a copy of the previous one with
+	// a new CSeq and branch
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(this.request.String())
+	if err != nil {
+		return err
+	}
+	publish := msg.(*message.SIPRequest)
+	publish.GetCSeq().SetSequenceNumber(this.request.GetCSeqNumber() + 1)
+	if via := publish.GetTopmostVia(); via != nil {
+		via.SetBranch(header.SIPConstants_BRANCH_MAGIC_COOKIE + NewToken(8))
+	}
+	publish.RemoveHeader(core.SIPHeaderNames_SIP_IF_MATCH)
+	if this.eTag != "" {
+		ifMatch := header.NewSIPIfMatch()
+		ifMatch.SetETag(this.eTag)
+		publish.SetHeader(ifMatch)
+	}
+	expiresHeader := header.NewExpires()
+	expiresHeader.SetExpires(expires)
+	publish.SetHeader(expiresHeader)
+	publish.RemoveContent()
+	publish.RemoveHeader(core.SIPHeaderNames_CONTENT_TYPE)
+	if content != "" {
+		publish.SetContent(content, this.contentType)
+	}
+	this.request = publish
+	return this.send(publish)
+}
+
+func (this *ClientPublication) send(publish *message.SIPRequest) error {
+	ct, err := this.publisher.provider.GetNewClientTransaction(publish)
+	if err != nil {
+		return err
+	}
+	if err = ct.SendRequest(); err != nil {
+		return err
+	}
+	this.publisher.transactions[transactionKey(publish)] = this
+	return nil
+}
+
+/** Schedules the refresh no later than 32 seconds before the publication
+ * expires, and at half its duration for short publications.
+ */
+func (this *ClientPublication) startRefresh(expires int) {
+	this.stopRefresh()
+	interval := time.Duration(expires) * time.Second
+	guard := interval / 2
+	if guard > 32*time.Second {
+		guard = 32 * time.Second
+	}
+	generation := this.generation
+	this.timer = time.AfterFunc(interval-guard, func() {
+		this.publisher.mutex.Lock()
+		defer this.publisher.mutex.Unlock()
+		if generation == this.generation && !this.removed {
+			this.timer = nil
+			this.publish("")
+		}
+	})
+}
+
+func (this *ClientPublication) stopRefresh() {
+	this.generation++
+	if this.timer != nil {
+		this.timer.Stop()
+		this.timer = nil
+	}
+}
+
+/** Returns a random hexadecimal token of n bytes, e.g. for entity tags,
+ * branch identifiers and message ids.
+ */
+func NewToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return message.ToHexString(b)
+}
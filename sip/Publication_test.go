@@ -0,0 +1,210 @@
+package sip
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const pidf = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+	"<presence xmlns=\"urn:ietf:params:xml:ns:pidf\" entity=\"sip:alice@a.example.com\">" +
+	"<tuple id=\"phone\"><status><basic>open</basic></status></tuple></presence>"
+
+type eventStateRecorder struct {
+	states []*EventState
+}
+
+func (this *eventStateRecorder) ProcessEventState(state *EventState) {
+	this.states = append(this.states, state)
+}
+
+func (this *eventStateRecorder) last(t *testing.T) *EventState {
+	if len(this.states) == 0 {
+		t.Fatal("no event state reported")
+	}
+	return this.states[len(this.states)-1]
+}
+
+func publishRequest(t *testing.T, headers string, body string) *message.SIPRequest {
+	return parseRequest(t, "PUBLISH sip:alice@a.example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bKpublish\r\n"+
+		"From: <sip:alice@a.example.com>;tag=alice-tag\r\n"+
+		"To: <sip:alice@a.example.com>\r\n"+
+		"Call-ID: publish@a.example.com\r\n"+
+		"CSeq: 1 PUBLISH\r\n"+
+		"Event: presence\r\n"+
+		"Max-Forwards: 70\r\n"+headers+
+		"Content-Type: application/pidf+xml\r\n"+
+		"Content-Length: "+strconv.Itoa(len(body))+"\r\n\r\n"+body)
+}
+
+func getETag(response message.Response) string {
+	if eTag, ok := response.GetHeader(core.SIPHeaderNames_SIP_ETAG).(header.SIPETagHeader); ok {
+		return eTag.GetETag()
+	}
+	return ""
+}
+
+func getIfMatch(request message.Request) string {
+	if ifMatch, ok := request.GetHeader(core.SIPHeaderNames_SIP_IF_MATCH).(header.SIPIfMatchHeader); ok {
+		return ifMatch.GetETag()
+	}
+	return ""
+}
+
+func TestEventStateCompositor(t *testing.T) {
+	compositor := NewEventStateCompositor()
+	recorder := &eventStateRecorder{}
+	compositor.RegisterEventPackage("presence", recorder)
+	compositor.SetMinExpires(60)
+	compositor.SetMaxExpires(600)
+
+	if response := compositor.ProcessPublish(publishRequest(t, "Expires: 30\r\n", pidf)); response.GetStatusCode() != message.INTERVAL_TOO_BRIEF ||
+		response.GetHeader(core.SIPHeaderNames_MIN_EXPIRES).(header.MinExpiresHeader).GetExpires() != 60 {
+		t.Fatal("short publication not rejected with 423 and Min-Expires", response)
+	}
+	response := compositor.ProcessPublish(publishRequest(t, "Expires: 7200\r\n", pidf))
+	eTag := getETag(response)
+	if response.GetStatusCode() != message.OK || eTag == "" ||
+		response.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader).GetExpires() != 600 {
+		t.Fatal("bad response to the initial PUBLISH", response)
+	}
+	state := recorder.last(t)
+	if state.GetContent() != pidf || state.GetResource().String() != "sip:alice@a.example.com" || state.GetEventType() != "presence" {
+		t.Error("bad event state", state)
+	}
+	publicationId := state.GetPublicationId()
+
+	// a refresh gets a new entity tag without reporting the state again
+	refresh := publishRequest(t, "SIP-If-Match: "+eTag+"\r\n", "")
+	response = compositor.ProcessPublish(refresh)
+	if response.GetStatusCode() != message.OK || getETag(response) == "" || getETag(response) == eTag {
+		t.Fatal("bad response to the refresh", response)
+	}
+	if len(recorder.states) != 1 {
+		t.Error("refresh reported")
+	}
+	// the old entity tag is gone
+	if response := compositor.ProcessPublish(refresh); response.GetStatusCode() != message.CONDITIONAL_REQUEST_FAILED {
+		t.Error("replaced entity tag accepted with", response.GetStatusCode())
+	}
+	eTag = getETag(response)
+
+	modified := strings.Replace(pidf, "open", "closed", 1)
+	response = compositor.ProcessPublish(publishRequest(t, "SIP-If-Match: "+eTag+"\r\n", modified))
+	if response.GetStatusCode() != message.OK {
+		t.Fatal("modification rejected with", response.GetStatusCode())
+	}
+	if state := recorder.last(t); len(recorder.states) != 2 || state.GetContent() != modified ||
+		state.GetPublicationId() != publicationId {
+		t.Error("modification not reported", state)
+	}
+	eTag = getETag(response)
+
+	response = compositor.ProcessPublish(publishRequest(t, "SIP-If-Match: "+eTag+"\r\nExpires: 0\r\n", ""))
+	if response.GetStatusCode() != message.OK || getETag(response) != "" {
+		t.Fatal("bad response to the removal", response)
+	}
+	if state := recorder.last(t); len(recorder.states) != 3 || !state.IsRemoved() {
+		t.Error("removal not reported", state)
+	}
+
+	// an unknown event package and an initial PUBLISH without state
+	other := publishRequest(t, "", pidf)
+	other.GetHeader(core.SIPHeaderNames_EVENT).(header.EventHeader).SetEventType("dialog")
+	if response := compositor.ProcessPublish(other); response.GetStatusCode() != message.BAD_EVENT {
+		t.Error("unknown event package accepted with", response.GetStatusCode())
+	}
+	if response := compositor.ProcessPublish(publishRequest(t, "", "")); response.GetStatusCode() != message.BAD_REQUEST {
+		t.Error("initial PUBLISH without state accepted with", response.GetStatusCode())
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	provider := &fakeProvider{}
+	publisher := NewPublisher(provider)
+	compositor := NewEventStateCompositor()
+	recorder := &eventStateRecorder{}
+	compositor.RegisterEventPackage("presence", recorder)
+	compositor.SetMinExpires(7200)
+
+	// the compositor answers the last PUBLISH sent
+	exchange := func() (*message.SIPRequest, *message.SIPResponse) {
+		publish := provider.lastSent(t)
+		response := reparse(t, compositor.ProcessPublish(publish)).(*message.SIPResponse)
+		publisher.ProcessResponse(response)
+		return publish, response
+	}
+
+	publication, err := publisher.Publish(publishRequest(t, "", pidf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the default duration is too brief: sent again with the Min-Expires
+	if _, response := exchange(); response.GetStatusCode() != message.INTERVAL_TOO_BRIEF {
+		t.Fatal("expected 423, got", response.GetStatusCode())
+	}
+	publish, response := exchange()
+	if publish.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader).GetExpires() != 7200 ||
+		getIfMatch(publish) != "" || publish.GetContent() != pidf {
+		t.Fatal("bad PUBLISH after 423", publish)
+	}
+	if response.GetStatusCode() != message.OK || publication.GetETag() != getETag(response) {
+		t.Fatal("publication not stored", response)
+	}
+	defer publication.Remove()
+
+	eTag := publication.GetETag()
+	if err := publication.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	publish, response = exchange()
+	if getIfMatch(publish) != eTag || publish.GetContent() != "" || publish.GetCSeq().GetSequenceNumber() != 3 {
+		t.Error("bad refresh", publish)
+	}
+	if publication.GetETag() != getETag(response) || publication.GetETag() == eTag {
+		t.Error("new entity tag not stored")
+	}
+
+	modified := strings.Replace(pidf, "open", "closed", 1)
+	if err := publication.Modify(header.NewContentTypeFromString("application", "pidf+xml"), modified); err != nil {
+		t.Fatal(err)
+	}
+	publish, _ = exchange()
+	if getIfMatch(publish) == "" || publish.GetContent() != modified || recorder.last(t).GetContent() != modified {
+		t.Error("bad modification", publish)
+	}
+
+	// the compositor lost the publication: 412 and published again from
+	// scratch with the modified state
+	compositor = NewEventStateCompositor()
+	compositor.RegisterEventPackage("presence", recorder)
+	publication.Refresh()
+	if _, response := exchange(); response.GetStatusCode() != message.CONDITIONAL_REQUEST_FAILED {
+		t.Fatal("expected 412, got", response.GetStatusCode())
+	}
+	publish, response = exchange()
+	if getIfMatch(publish) != "" || publish.GetContent() != modified || response.GetStatusCode() != message.OK {
+		t.Fatal("publication not sent again after 412", publish)
+	}
+	if !publication.IsPublished() || recorder.last(t).GetContent() != modified {
+		t.Error("publication not restored")
+	}
+
+	if err := publication.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	publish, _ = exchange()
+	if getIfMatch(publish) == "" || publish.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader).GetExpires() != 0 {
+		t.Error("bad removal", publish)
+	}
+	if publication.IsPublished() || !recorder.last(t).IsRemoved() {
+		t.Error("publication not removed")
+	}
+	if err := publication.Refresh(); err == nil {
+		t.Error("removed publication refreshed")
+	}
+}
@@ -0,0 +1,285 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Redirect.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/** The number of redirections a Redirector follows by default.
+ */
+const DEFAULT_MAX_REDIRECTIONS = 5
+
+/**
+ * Follows the redirections of requests as a User Agent Client (RFC 3261
+ * section 8.1.3.4). When a request is answered with 300, 301 or 302, the
+ * Contacts of the response are added to the targets of the request and tried
+ * one after the other, highest q-value first, each with a copy of the
+ * original request carrying the Contact as Request-URI. A target already
+ * tried, the original Request-URI included, is never tried again so that
+ * redirection loops end, and the number of redirections followed is limited.
+ * <p>
+ * The application sends its requests with SendRequest and passes the
+ * responses and timeouts of their client transactions to ProcessResponse and
+ * ProcessTimeout, which return the final response of the request once no
+ * target is left: the first 2xx or 6xx, or else the best error received.
+ */
+type Redirector struct {
+	mutex sync.Mutex
+
+	provider        SipProvider
+	maxRedirections int
+	transactions    map[string]*redirection
+}
+
+/**
+ * The targets of a request being redirected.
+ */
+type redirection struct {
+	request      *message.SIPRequest
+	cseq         int
+	visited      map[string]bool
+	targets      []*header.Contact
+	redirections int
+	bestResponse message.Response
+}
+
+/** Creates a redirector sending its requests with provider and following at
+ * most DEFAULT_MAX_REDIRECTIONS redirections per request.
+ */
+func NewRedirector(provider SipProvider) *Redirector {
+	return &Redirector{
+		provider:        provider,
+		maxRedirections: DEFAULT_MAX_REDIRECTIONS,
+		transactions:    make(map[string]*redirection),
+	}
+}
+
+/** Sets the number of redirections followed per request; 0 disables the
+ * recursion.
+ */
+func (this *Redirector) SetMaxRedirections(maxRedirections int) (InvalidArgumentException error) {
+	if maxRedirections < 0 {
+		return errors.New("InvalidArgumentException: bad max redirections")
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.maxRedirections = maxRedirections
+	return nil
+}
+
+/** Returns the number of redirections followed per request.
+ */
+func (this *Redirector) GetMaxRedirections() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.maxRedirections
+}
+
+/** Sends request in a new client transaction and follows its redirections.
+ * ACK and CANCEL cannot be redirected.
+ */
+func (this *Redirector) SendRequest(request *message.SIPRequest) (SipException error) {
+	if method := request.GetMethod(); method == message.ACK || method == message.CANCEL {
+		return errors.New("SipException: " + method + " cannot be redirected")
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	r := &redirection{
+		request: request,
+		cseq:    request.GetCSeqNumber(),
+		visited: make(map[string]bool),
+	}
+	r.visited[targetKey(request.GetRequestURI())] = true
+	ct, err := this.provider.GetNewClientTransaction(request)
+	if err != nil {
+		return err
+	}
+	if err = ct.SendRequest(); err != nil {
+		return err
+	}
+	this.transactions[transactionKey(request)] = r
+	return nil
+}
+
+/** Processes a response to a request sent by the redirector. The final
+ * response of the request is returned, or nil if the response is
+ * provisional, is not for a request of the redirector, or if another target
+ * is being tried.
+ */
+func (this *Redirector) ProcessResponse(response message.Response) message.Response {
+	statusCode := response.GetStatusCode()
+	if statusCode < message.OK {
+		return nil
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	key := transactionKey(response)
+	r := this.transactions[key]
+	if r == nil {
+		return nil
+	}
+	delete(this.transactions, key)
+
+	switch {
+	case statusCode < message.MULTIPLE_CHOICES || statusCode >= message.BUSY_EVERYWHERE:
+		// a success or a global failure ends the search
+		return response
+	case statusCode <= message.MOVED_TEMPORARILY:
+		if r.redirections < this.maxRedirections {
+			r.redirections++
+			r.addTargets(response)
+		}
+	}
+	r.keepBestResponse(response)
+	return this.tryNextTarget(r)
+}
+
+/** Processes a request of the redirector whose client transaction timed
+ * out, as if it had been answered with 408. The final response of the
+ * request is returned, or nil if another target is being tried.
+ */
+func (this *Redirector) ProcessTimeout(request message.Request) message.Response {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	key := transactionKey(request)
+	r := this.transactions[key]
+	if r == nil {
+		return nil
+	}
+	delete(this.transactions, key)
+	r.keepBestResponse(r.request.CreateResponse(message.REQUEST_TIMEOUT))
+	return this.tryNextTarget(r)
+}
+
+/** Sends the request to the next target that can be reached and returns
+ * nil, or returns the best response received if no target is left.
+ */
+func (this *Redirector) tryNextTarget(r *redirection) message.Response {
+	for len(r.targets) > 0 {
+		target := r.targets[0]
+		r.targets = r.targets[1:]
+		if err := this.sendToTarget(r, target); err == nil {
+			return nil
+		}
+	}
+	return r.bestResponse
+}
+
+func (this *Redirector) sendToTarget(r *redirection, target *header.Contact) error {
+	// the request is a copy of the original one with a new Request-URI,
+	// CSeq and branch
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(r.request.String())
+	if err != nil {
+		return err
+	}
+	request := msg.(*message.SIPRequest)
+	uri, err := parser.NewURLParser(target.GetAddress().GetURI().String()).UriReference()
+	if err != nil {
+		return err
+	}
+	if sipURI, ok := uri.(*address.SipURIImpl); ok {
+		// the headers of the URI are not part of the Request-URI
+		sipURI.RemoveHeaders()
+	}
+	request.SetRequestURI(uri)
+	r.cseq++
+	request.GetCSeq().SetSequenceNumber(r.cseq)
+	if via := request.GetTopmostVia(); via != nil {
+		via.SetBranch(header.SIPConstants_BRANCH_MAGIC_COOKIE + NewToken(8))
+	}
+
+	ct, err := this.provider.GetNewClientTransaction(request)
+	if err != nil {
+		return err
+	}
+	if err = ct.SendRequest(); err != nil {
+		return err
+	}
+	this.transactions[transactionKey(request)] = r
+	return nil
+}
+
+/** Adds the Contacts of a redirection not tried yet to the targets, and
+ * sorts the targets by decreasing q-value, keeping the order of the
+ * redirections for equal q-values.
+ */
+func (this *redirection) addTargets(response message.Response) {
+	for e := response.GetHeaders(core.SIPHeaderNames_CONTACT).Front(); e != nil; e = e.Next() {
+		contact, ok := e.Value.(*header.Contact)
+		if !ok || contact.GetWildCardFlag() || contact.GetAddress() == nil {
+			continue
+		}
+		key := targetKey(contact.GetAddress().GetURI())
+		if this.visited[key] {
+			continue
+		}
+		this.visited[key] = true
+		this.targets = append(this.targets, contact)
+	}
+	sort.SliceStable(this.targets, func(i, j int) bool {
+		return qValue(this.targets[i]) > qValue(this.targets[j])
+	})
+}
+
+/** Keeps the best final response received for the request (RFC 3261
+ * section 16.7): the lowest class, a 3xx without target only when nothing
+ * else was received.
+ */
+func (this *redirection) keepBestResponse(response message.Response) {
+	if this.bestResponse == nil || responseClass(response) < responseClass(this.bestResponse) {
+		this.bestResponse = response
+	}
+}
+
+func responseClass(response message.Response) int {
+	class := response.GetStatusCode() / 100
+	if class == 3 {
+		// a redirection that could not be followed is the last resort
+		return 7
+	}
+	return class
+}
+
+func qValue(contact *header.Contact) float32 {
+	if !contact.HasQValue() {
+		return 1
+	}
+	return contact.GetQValue()
+}
+
+/** Returns the key identifying a target: the scheme, user, host, port and
+ * transport of a SIP URI, compared without case except for the user, or
+ * else the URI itself.
+ */
+func targetKey(uri address.URI) string {
+	sipURI, ok := uri.(*address.SipURIImpl)
+	if !ok {
+		return uri.String()
+	}
+	port := sipURI.GetPort()
+	if port <= 0 {
+		port = header.SIPConstants_DEFAULT_PORT
+	}
+	return strings.ToLower(sipURI.GetScheme()) + ":" + sipURI.GetUser() + "@" +
+		strings.ToLower(sipURI.GetHost()) + ":" + strconv.Itoa(port) + ";" +
+		strings.ToLower(sipURI.GetParameter(core.SIPTransportNames_TRANSPORT))
+}
@@ -0,0 +1,116 @@
+package sip
+
+import (
+	"gosips/sip/message"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func redirectedInvite(t *testing.T) *message.SIPRequest {
+	return parseRequest(t, "INVITE sip:bob@example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bKalice\r\n"+
+		"From: <sip:alice@a.example.com>;tag=alice-tag\r\n"+
+		"To: <sip:bob@example.com>\r\n"+
+		"Call-ID: redirect@192.0.2.1\r\n"+
+		"CSeq: 1 INVITE\r\n"+
+		"Max-Forwards: 70\r\n"+
+		"Content-Length: 0\r\n\r\n")
+}
+
+// redirectResponse answers request with statusCode and contacts.
+func redirectResponse(t *testing.T, request message.Request, statusCode int, contacts string) message.Response {
+	response := reparse(t, request).(*message.SIPRequest).CreateResponse(statusCode).String()
+	if contacts != "" {
+		response = strings.Replace(response, "Content-Length:", "Contact: "+contacts+"\r\nContent-Length:", 1)
+	}
+	return parseResponse(t, response)
+}
+
+func TestRedirector(t *testing.T) {
+	provider := &fakeProvider{}
+	redirector := NewRedirector(provider)
+	if err := redirector.SendRequest(parseRequest(t, strings.Replace(redirectedInvite(t).String(), "INVITE", "ACK", -1))); err == nil {
+		t.Error("ACK redirected")
+	}
+	if err := redirector.SendRequest(redirectedInvite(t)); err != nil {
+		t.Fatal(err)
+	}
+	if response := redirector.ProcessResponse(redirectResponse(t, provider.lastSent(t), message.RINGING, "")); response != nil {
+		t.Error("provisional response final", response)
+	}
+
+	// the targets are tried by q-value, except those already tried: the
+	// original Request-URI, and the phone redirected to twice
+	if response := redirector.ProcessResponse(redirectResponse(t, provider.lastSent(t), message.MOVED_TEMPORARILY,
+		"<sip:bob@phone.example.com>;q=0.5, <sip:bob@pc.example.com?Subject=lunch>;q=0.9, <sip:bob@vm.example.com>;q=0.1")); response != nil {
+		t.Fatal("redirection not followed", response)
+	}
+	if response := redirector.ProcessResponse(redirectResponse(t, provider.lastSent(t), message.MOVED_TEMPORARILY,
+		"<sip:bob@PHONE.example.com:5060>, <sip:bob@EXAMPLE.com>, <sip:bob@new.example.com>;q=0.7")); response != nil {
+		t.Fatal("redirection not followed", response)
+	}
+	if response := redirector.ProcessResponse(redirectResponse(t, provider.lastSent(t), message.BUSY_HERE, "")); response != nil {
+		t.Fatal("search ended by a 486", response)
+	}
+	if response := redirector.ProcessTimeout(provider.lastSent(t)); response != nil {
+		t.Fatal("search ended by a timeout", response)
+	}
+	response := redirector.ProcessResponse(redirectResponse(t, provider.lastSent(t), message.NOT_FOUND, ""))
+	if response == nil || response.GetStatusCode() != message.BUSY_HERE {
+		t.Fatal("best response not returned", response)
+	}
+
+	var targets []string
+	for i, request := range provider.getSent() {
+		request := request.(*message.SIPRequest)
+		targets = append(targets, request.GetRequestURI().String())
+		if request.GetCSeqNumber() != i+1 {
+			t.Error("bad CSeq", request.GetCSeq())
+		}
+		if i > 0 && request.GetTopmostVia().GetBranch() == "z9hG4bKalice" {
+			t.Error("branch reused", request)
+		}
+	}
+	if strings.Join(targets, ", ") != "sip:bob@example.com, sip:bob@pc.example.com, sip:bob@new.example.com, "+
+		"sip:bob@phone.example.com, sip:bob@vm.example.com" {
+		t.Error("bad targets", targets)
+	}
+}
+
+func TestRedirectorLimits(t *testing.T) {
+	provider := &fakeProvider{}
+	redirector := NewRedirector(provider)
+	if err := redirector.SetMaxRedirections(-1); err == nil {
+		t.Error("negative max redirections accepted")
+	}
+
+	// the redirections followed are limited
+	redirector.SetMaxRedirections(2)
+	redirector.SendRequest(redirectedInvite(t))
+	for i := 1; ; i++ {
+		response := redirector.ProcessResponse(redirectResponse(t, provider.lastSent(t), message.MOVED_TEMPORARILY,
+			"<sip:bob@"+strconv.Itoa(i)+".example.com>"))
+		if response != nil {
+			if i != 3 || response.GetStatusCode() != message.MOVED_TEMPORARILY {
+				t.Error("redirections not limited", i, response)
+			}
+			break
+		}
+	}
+
+	// a 6xx ends the search with targets left
+	redirector.SendRequest(redirectedInvite(t))
+	redirector.ProcessResponse(redirectResponse(t, provider.lastSent(t), message.MOVED_TEMPORARILY,
+		"<sip:bob@phone.example.com>, <sip:bob@pc.example.com>"))
+	sent := len(provider.getSent())
+	response := redirector.ProcessResponse(redirectResponse(t, provider.lastSent(t), message.DECLINE, ""))
+	if response == nil || response.GetStatusCode() != message.DECLINE || len(provider.getSent()) != sent {
+		t.Error("search not ended by a 6xx", response)
+	}
+
+	// a response to another request is not for the redirector
+	if response := redirector.ProcessResponse(redirectResponse(t, provider.lastSent(t), message.OK, "")); response != nil {
+		t.Error("response of an ended search returned", response)
+	}
+}
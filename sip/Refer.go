@@ -0,0 +1,392 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Refer.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * The event package of the implicit subscription created by REFER.
+ */
+const REFER_EVENT = "refer"
+
+/**
+ * The duration in seconds of the implicit subscription created by REFER
+ * when the REFER has no Expires header.
+ */
+const REFER_DEFAULT_EXPIRES = 60
+
+/** Returns a message/sipfrag body holding the status line of a response.
+ */
+func CreateSipfrag(statusCode int, reasonPhrase string) string {
+	return "SIP/2.0 " + strconv.Itoa(statusCode) + " " + reasonPhrase + "\r\n"
+}
+
+/** Returns the status code and reason phrase of the status line at the start
+ * of a message/sipfrag body.
+ */
+func ParseSipfrag(body string) (statusCode int, reasonPhrase string, ParseException error) {
+	line := body
+	if i := strings.IndexAny(line, "\r\n"); i != -1 {
+		line = line[:i]
+	}
+	fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+	if len(fields) < 2 || !strings.HasPrefix(strings.ToUpper(fields[0]), "SIP/") {
+		return 0, "", errors.New("ParseException: bad sipfrag status line " + line)
+	}
+	if statusCode, ParseException = strconv.Atoi(fields[1]); ParseException != nil || statusCode < 100 || statusCode > 699 {
+		return 0, "", errors.New("ParseException: bad sipfrag status code " + fields[1])
+	}
+	if len(fields) == 3 {
+		reasonPhrase = fields[2]
+	}
+	return statusCode, reasonPhrase, nil
+}
+
+/**
+ * Handles a REFER received in a dialog on behalf of the transfer target as
+ * described in RFC 3515. Accepting the REFER answers it with 202 and creates
+ * the implicit "refer" subscription, over which NOTIFY requests carrying
+ * message/sipfrag bodies report the progress of the request triggered by the
+ * REFER, usually an INVITE to the Refer-To URI. A REFER carrying
+ * "Refer-Sub: false" (RFC 4488) is accepted without subscription.
+ */
+type ReferServer struct {
+	mutex sync.Mutex
+
+	provider    SipProvider
+	transaction ServerTransaction
+	dialog      Dialog
+	referTo     header.ReferToHeader
+	subscribed  bool
+	eventId     string
+	expires     int
+	timer       *time.Timer
+	lastStatus  string
+	terminated  bool
+}
+
+/** Creates a REFER handler for the REFER of transaction. An error is returned
+ * if the REFER does not carry exactly one Refer-To header; it should then be
+ * rejected with 400.
+ */
+func NewReferServer(provider SipProvider, transaction ServerTransaction) (*ReferServer, error) {
+	refer := transaction.GetRequest()
+	if refer.GetHeaders(core.SIPHeaderNames_REFER_TO).Len() != 1 {
+		return nil, errors.New("SipException: REFER without a single Refer-To")
+	}
+	referTo, ok := refer.GetHeader(core.SIPHeaderNames_REFER_TO).(header.ReferToHeader)
+	if !ok {
+		return nil, errors.New("SipException: bad Refer-To")
+	}
+
+	this := &ReferServer{
+		provider:    provider,
+		transaction: transaction,
+		dialog:      transaction.GetDialog(),
+		referTo:     referTo,
+		subscribed:  true,
+		expires:     REFER_DEFAULT_EXPIRES,
+	}
+	if referSub, ok := refer.GetHeader(core.SIPHeaderNames_REFER_SUB).(header.ReferSubHeader); ok {
+		this.subscribed = referSub.IsReferSub()
+	}
+	if expires, ok := refer.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader); ok && expires.GetExpires() > 0 {
+		this.expires = expires.GetExpires()
+	}
+	if cseq, ok := refer.GetHeader(core.SIPHeaderNames_CSEQ).(header.CSeqHeader); ok {
+		this.eventId = strconv.Itoa(cseq.GetSequenceNumber())
+	}
+	return this, nil
+}
+
+/** Returns the Refer-To header of the REFER.
+ */
+func (this *ReferServer) GetReferTo() header.ReferToHeader {
+	return this.referTo
+}
+
+/** Returns true if the REFER created an implicit subscription.
+ */
+func (this *ReferServer) IsSubscribed() bool {
+	return this.subscribed
+}
+
+/** Accepts the REFER with 202 and, unless the subscription was suppressed,
+ * sends the initial NOTIFY reporting "100 Trying".
+ */
+func (this *ReferServer) Accept() (SipException error) {
+	refer, ok := this.transaction.GetRequest().(*message.SIPRequest)
+	if !ok {
+		return errors.New("SipException: unsupported request type")
+	}
+	response := refer.CreateResponse(message.ACCEPTED)
+	if response.GetToTag() == "" && this.dialog != nil {
+		response.SetToTag(this.dialog.GetLocalTag())
+	}
+	if !this.subscribed {
+		response.SetHeader(header.NewReferSub(false))
+	}
+	if err := this.transaction.SendResponse(response); err != nil {
+		return err
+	}
+	if !this.subscribed {
+		return nil
+	}
+	return this.NotifyStatus(message.TRYING, response.GetReasonPhraseFromInt(message.TRYING))
+}
+
+/** Rejects the REFER with the given final status code.
+ */
+func (this *ReferServer) Reject(statusCode int) (SipException error) {
+	refer, ok := this.transaction.GetRequest().(*message.SIPRequest)
+	if !ok {
+		return errors.New("SipException: unsupported request type")
+	}
+	this.mutex.Lock()
+	this.terminated = true
+	this.mutex.Unlock()
+	return this.transaction.SendResponse(refer.CreateResponse(statusCode))
+}
+
+/** Reports a response to the request triggered by the REFER. A final
+ * response terminates the subscription.
+ */
+func (this *ReferServer) NotifyProgress(response message.Response) (SipException error) {
+	return this.NotifyStatus(response.GetStatusCode(), response.GetReasonPhrase())
+}
+
+/** Reports the status of the request triggered by the REFER. A final status
+ * terminates the subscription.
+ */
+func (this *ReferServer) NotifyStatus(statusCode int, reasonPhrase string) (SipException error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if !this.subscribed {
+		return nil
+	}
+	if this.terminated {
+		return errors.New("SipException: the refer subscription is terminated")
+	}
+	this.lastStatus = CreateSipfrag(statusCode, reasonPhrase)
+	if statusCode >= message.OK {
+		return this.notify(header.SubscriptionState_NO_RESOURCE)
+	}
+	if this.timer == nil {
+		this.timer = time.AfterFunc(time.Duration(this.expires)*time.Second, this.expire)
+	}
+	return this.notify("")
+}
+
+/** Terminates the subscription with reason timeout when it expires before
+ * the triggered request completed.
+ */
+func (this *ReferServer) expire() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if !this.terminated {
+		this.notify(header.SubscriptionState_TIMEOUT)
+	}
+}
+
+/** Sends a NOTIFY with the last status. A non-empty reason terminates the
+ * subscription.
+ */
+func (this *ReferServer) notify(reason string) error {
+	notify, err := this.dialog.CreateRequest(message.NOTIFY)
+	if err != nil {
+		return err
+	}
+
+	event := header.NewEvent()
+	event.SetEventType(REFER_EVENT)
+	if this.eventId != "" {
+		event.SetEventId(this.eventId)
+	}
+	notify.SetHeader(event)
+
+	subscriptionState := header.NewSubscriptionState()
+	if reason == "" {
+		subscriptionState.SetState(header.SubscriptionState_ACTIVE)
+		subscriptionState.SetExpires(this.expires)
+	} else {
+		subscriptionState.SetState(header.SubscriptionState_TERMINATED)
+		subscriptionState.SetReasonCode(reason)
+		this.terminated = true
+		if this.timer != nil {
+			this.timer.Stop()
+		}
+	}
+	notify.SetHeader(subscriptionState)
+
+	contentType := header.NewContentTypeFromString("message", "sipfrag")
+	contentType.SetParameter("version", "2.0")
+	notify.SetContent(this.lastStatus, contentType)
+
+	ct, err := this.provider.GetNewClientTransaction(notify)
+	if err != nil {
+		return err
+	}
+	return this.dialog.SendRequest(ct)
+}
+
+/**
+ * Sends a REFER in a dialog on behalf of the transferor and follows the
+ * progress of the transfer through the NOTIFY requests of the implicit
+ * subscription. With SetReferSub(false) the REFER asks for no subscription
+ * (RFC 4488); the transfer is then complete as far as the client is concerned
+ * once the REFER is accepted without subscription.
+ */
+type ReferClient struct {
+	mutex sync.Mutex
+
+	provider   SipProvider
+	dialog     Dialog
+	referSub   bool
+	eventId    string
+	subscribed bool
+	statusCode int
+	terminated bool
+}
+
+/** Creates a REFER client for dialog that sends its REFER through provider.
+ */
+func NewReferClient(provider SipProvider, dialog Dialog) *ReferClient {
+	return &ReferClient{
+		provider: provider,
+		dialog:   dialog,
+		referSub: true,
+	}
+}
+
+/** Sets whether the REFER asks for the implicit subscription.
+ */
+func (this *ReferClient) SetReferSub(referSub bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.referSub = referSub
+}
+
+/** Sends a REFER asking the remote party to contact referTo.
+ */
+func (this *ReferClient) SendRefer(referTo address.Address) (SipException error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	refer, err := this.dialog.CreateRequest(message.REFER)
+	if err != nil {
+		return err
+	}
+	referToHeader := header.NewReferTo()
+	referToHeader.SetAddress(referTo)
+	refer.SetHeader(referToHeader)
+	if !this.referSub {
+		refer.SetHeader(header.NewReferSub(false))
+		AddSupported(refer, OPTION_NOREFERSUB)
+	}
+	if cseq, ok := refer.GetHeader(core.SIPHeaderNames_CSEQ).(header.CSeqHeader); ok {
+		this.eventId = strconv.Itoa(cseq.GetSequenceNumber())
+	}
+
+	ct, err := this.provider.GetNewClientTransaction(refer)
+	if err != nil {
+		return err
+	}
+	this.subscribed = false
+	this.statusCode = 0
+	this.terminated = false
+	return this.dialog.SendRequest(ct)
+}
+
+/** Processes the response to the REFER. A 2xx creates the subscription unless
+ * it carries "Refer-Sub: false"; an error response ends the transfer.
+ */
+func (this *ReferClient) ProcessResponse(response message.Response) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	statusCode := response.GetStatusCode()
+	if statusCode < message.OK {
+		return
+	}
+	if statusCode >= message.MULTIPLE_CHOICES {
+		this.statusCode = statusCode
+		this.terminated = true
+		return
+	}
+	if referSub, ok := response.GetHeader(core.SIPHeaderNames_REFER_SUB).(header.ReferSubHeader); ok && !referSub.IsReferSub() {
+		this.terminated = true
+		return
+	}
+	this.subscribed = true
+}
+
+/** Processes a NOTIFY of the refer subscription and returns the response to
+ * send: 200 if the NOTIFY was accepted, 489 if it is not for the refer event
+ * and 481 if it does not match the subscription.
+ */
+func (this *ReferClient) ProcessNotify(notify *message.SIPRequest) *message.SIPResponse {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	event, ok := notify.GetHeader(core.SIPHeaderNames_EVENT).(header.EventHeader)
+	if !ok || !strings.EqualFold(event.GetEventType(), REFER_EVENT) {
+		return notify.CreateResponse(message.BAD_EVENT)
+	}
+	if id := event.GetEventId(); id != "" && id != this.eventId || this.terminated && !this.subscribed {
+		return notify.CreateResponse(message.CALL_OR_TRANSACTION_DOES_NOT_EXIST)
+	}
+	// the NOTIFY may overtake the 202
+	this.subscribed = true
+
+	if statusCode, _, err := ParseSipfrag(notify.GetContent()); err == nil {
+		this.statusCode = statusCode
+	}
+	if subscriptionState, ok := notify.GetHeader(core.SIPHeaderNames_SUBSCRIPTION_STATE).(header.SubscriptionStateHeader); ok {
+		if strings.EqualFold(subscriptionState.GetState(), header.SubscriptionState_TERMINATED) {
+			this.terminated = true
+		}
+	}
+	return notify.CreateResponse(message.OK)
+}
+
+/** Returns the last status code reported for the triggered request, or 0 if
+ * none was reported yet.
+ */
+func (this *ReferClient) GetStatusCode() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.statusCode
+}
+
+/** Returns true if the triggered request succeeded.
+ */
+func (this *ReferClient) IsSuccessful() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.statusCode >= message.OK && this.statusCode < message.MULTIPLE_CHOICES
+}
+
+/** Returns true once the subscription is terminated, or the REFER was
+ * rejected or accepted without subscription.
+ */
+func (this *ReferClient) IsTerminated() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.terminated
+}
@@ -0,0 +1,174 @@
+package sip
+
+import (
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strconv"
+	"testing"
+)
+
+func parseAddress(t *testing.T, s string) address.Address {
+	addr, err := parser.NewAddressParser(s).Address()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func getSubscriptionState(t *testing.T, msg message.Message) header.SubscriptionStateHeader {
+	subscriptionState, ok := msg.GetHeader(core.SIPHeaderNames_SUBSCRIPTION_STATE).(header.SubscriptionStateHeader)
+	if !ok {
+		t.Fatal("no Subscription-State in", msg)
+	}
+	return subscriptionState
+}
+
+func TestSipfrag(t *testing.T) {
+	if body := CreateSipfrag(180, "Ringing"); body != "SIP/2.0 180 Ringing\r\n" {
+		t.Error("bad sipfrag", body)
+	}
+	statusCode, reasonPhrase, err := ParseSipfrag("SIP/2.0 603 Declined\r\nContent-Length: 0\r\n")
+	if err != nil || statusCode != 603 || reasonPhrase != "Declined" {
+		t.Error("bad sipfrag status line", statusCode, reasonPhrase, err)
+	}
+	for _, body := range []string{"", "INVITE sip:bob@b.example.com SIP/2.0\r\n", "SIP/2.0 99 Early\r\n", "SIP/2.0 OK\r\n"} {
+		if _, _, err := ParseSipfrag(body); err == nil {
+			t.Errorf("%q parsed", body)
+		}
+	}
+}
+
+func TestRefer(t *testing.T) {
+	transferor := newFakeDialog(t)
+	client := NewReferClient(transferor.provider, transferor)
+	if err := client.SendRefer(parseAddress(t, "<sip:carol@c.example.com>")); err != nil {
+		t.Fatal(err)
+	}
+	refer := transferor.provider.lastSent(t)
+	referTo, ok := refer.GetHeader(core.SIPHeaderNames_REFER_TO).(header.ReferToHeader)
+	if refer.GetMethod() != message.REFER || !ok || referTo.GetAddress().GetURI().String() != "sip:carol@c.example.com" {
+		t.Fatal("bad REFER", refer)
+	}
+	eventId := strconv.Itoa(refer.GetCSeq().GetSequenceNumber())
+
+	transferee := newFakeDialog(t)
+	transaction := newFakeServerTransaction(refer, transferee)
+	server, err := NewReferServer(transferee.provider, transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !server.IsSubscribed() || server.GetReferTo().GetAddress().GetURI().String() != "sip:carol@c.example.com" {
+		t.Fatal("bad REFER received")
+	}
+	if err := server.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	responses := transaction.getResponses()
+	if len(responses) != 1 || responses[0].GetStatusCode() != message.ACCEPTED {
+		t.Fatal("REFER not accepted with 202")
+	}
+	client.ProcessResponse(reparse(t, responses[0]).(*message.SIPResponse))
+
+	// the implicit subscription starts with a NOTIFY of 100 Trying
+	notify := transferee.provider.lastSent(t)
+	event, ok := notify.GetHeader(core.SIPHeaderNames_EVENT).(header.EventHeader)
+	if notify.GetMethod() != message.NOTIFY || !ok || event.GetEventType() != REFER_EVENT || event.GetEventId() != eventId {
+		t.Fatal("bad NOTIFY", notify)
+	}
+	if subscriptionState := getSubscriptionState(t, notify); subscriptionState.GetState() != header.SubscriptionState_ACTIVE ||
+		subscriptionState.GetExpires() != REFER_DEFAULT_EXPIRES {
+		t.Error("bad Subscription-State", subscriptionState)
+	}
+	if contentType := notify.GetContentTypeHeader(); contentType.GetContentType() != "message" ||
+		contentType.GetContentSubType() != "sipfrag" || notify.GetContent() != CreateSipfrag(100, "Trying") {
+		t.Error("bad sipfrag", notify)
+	}
+	if response := client.ProcessNotify(notify); response.GetStatusCode() != message.OK {
+		t.Fatal("NOTIFY rejected with", response.GetStatusCode())
+	}
+	if client.GetStatusCode() != message.TRYING || client.IsTerminated() {
+		t.Error("bad transfer state", client.GetStatusCode(), client.IsTerminated())
+	}
+
+	if err := server.NotifyStatus(message.RINGING, "Ringing"); err != nil {
+		t.Fatal(err)
+	}
+	client.ProcessNotify(transferee.provider.lastSent(t))
+	if client.GetStatusCode() != message.RINGING || client.IsSuccessful() {
+		t.Error("ringing not reported", client.GetStatusCode())
+	}
+
+	// a final response ends the subscription
+	if err := server.NotifyStatus(message.OK, "OK"); err != nil {
+		t.Fatal(err)
+	}
+	notify = transferee.provider.lastSent(t)
+	if subscriptionState := getSubscriptionState(t, notify); subscriptionState.GetState() != header.SubscriptionState_TERMINATED ||
+		subscriptionState.GetReasonCode() != header.SubscriptionState_NO_RESOURCE {
+		t.Error("subscription not terminated", subscriptionState)
+	}
+	client.ProcessNotify(notify)
+	if !client.IsSuccessful() || !client.IsTerminated() {
+		t.Error("transfer not completed", client.GetStatusCode(), client.IsTerminated())
+	}
+	if err := server.NotifyStatus(message.OK, "OK"); err == nil {
+		t.Error("NOTIFY sent in a terminated subscription")
+	}
+
+	// NOTIFYs of another event or subscription
+	other := parseRequest(t, inDialogRequest(message.NOTIFY, 5, "Event: presence\r\nSubscription-State: active\r\n", ""))
+	if response := client.ProcessNotify(other); response.GetStatusCode() != message.BAD_EVENT {
+		t.Error("NOTIFY of another event accepted with", response.GetStatusCode())
+	}
+	other = parseRequest(t, inDialogRequest(message.NOTIFY, 5, "Event: refer;id=999\r\nSubscription-State: active\r\n", ""))
+	if response := client.ProcessNotify(other); response.GetStatusCode() != message.CALL_OR_TRANSACTION_DOES_NOT_EXIST {
+		t.Error("NOTIFY of another REFER accepted with", response.GetStatusCode())
+	}
+}
+
+func TestReferWithoutSubscription(t *testing.T) {
+	transferor := newFakeDialog(t)
+	client := NewReferClient(transferor.provider, transferor)
+	client.SetReferSub(false)
+	if err := client.SendRefer(parseAddress(t, "<sip:carol@c.example.com>")); err != nil {
+		t.Fatal(err)
+	}
+	refer := transferor.provider.lastSent(t)
+	if !IsOptionSupported(refer, OPTION_NOREFERSUB) {
+		t.Error("REFER without Supported: norefersub", refer)
+	}
+
+	transferee := newFakeDialog(t)
+	transaction := newFakeServerTransaction(refer, transferee)
+	server, err := NewReferServer(transferee.provider, transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.IsSubscribed() {
+		t.Fatal("Refer-Sub: false ignored")
+	}
+	if err := server.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	if sent := transferee.provider.getSent(); len(sent) != 0 {
+		t.Error("NOTIFY sent without subscription", sent)
+	}
+	response := reparse(t, transaction.getResponses()[0]).(*message.SIPResponse)
+	referSub, ok := response.GetHeader(core.SIPHeaderNames_REFER_SUB).(header.ReferSubHeader)
+	if response.GetStatusCode() != message.ACCEPTED || !ok || referSub.IsReferSub() {
+		t.Fatal("202 without Refer-Sub: false", response)
+	}
+	client.ProcessResponse(response)
+	if !client.IsTerminated() {
+		t.Error("transfer without subscription not complete")
+	}
+
+	// a REFER without Refer-To is rejected
+	refer = parseRequest(t, inDialogRequest(message.REFER, 6, "", ""))
+	if _, err := NewReferServer(transferee.provider, newFakeServerTransaction(refer, transferee)); err == nil {
+		t.Error("REFER without Refer-To accepted")
+	}
+}
@@ -0,0 +1,305 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : ReliableProvisional.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/**
+ * Sends reliable provisional responses to an INVITE on behalf of a User Agent
+ * Server as described in RFC 3262 section 3. Every reliable provisional
+ * response carries "Require: 100rel" and an RSeq header and is retransmitted
+ * by the server transaction until a matching PRACK arrives. Only one reliable
+ * provisional response is outstanding at a time; later ones are queued and
+ * sent in order once the previous one was acknowledged.
+ */
+type ReliableProvisionalServer struct {
+	mutex sync.Mutex
+
+	transaction ServerTransaction
+	rseq        int
+	pending     message.Response
+	queue       []message.Response
+	timer       *time.Timer
+	t1          time.Duration
+	interval    time.Duration
+	elapsed     time.Duration
+	terminated  bool
+}
+
+/** Creates a reliable provisional response sender for the INVITE of the
+ * given server transaction.
+ */
+func NewReliableProvisionalServer(transaction ServerTransaction) *ReliableProvisionalServer {
+	return &ReliableProvisionalServer{
+		transaction: transaction,
+		rseq:        rand.Intn(1<<30) + 1,
+		t1:          TIMER_T1,
+	}
+}
+
+/** Sends response reliably. The response must be a provisional response
+ * other than 100 that carries a To tag, and the INVITE must have indicated
+ * support for 100rel. The Require and RSeq headers are added here.
+ */
+func (this *ReliableProvisionalServer) SendReliableProvisional(response message.Response) (SipException error) {
+	statusCode := response.GetStatusCode()
+	if statusCode <= message.TRYING || statusCode >= message.OK {
+		return errors.New("SipException: only 101-199 responses can be sent reliably")
+	}
+	if to, ok := response.GetHeader(core.SIPHeaderNames_TO).(header.ToHeader); !ok || to.GetTag() == "" {
+		return errors.New("SipException: reliable provisional response without To tag")
+	}
+	if !IsOptionSupported(this.transaction.GetRequest(), OPTION_100REL) {
+		return errors.New("SipException: the request does not support 100rel")
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.terminated {
+		return errors.New("SipException: the INVITE transaction has been answered")
+	}
+	AddRequire(response, OPTION_100REL)
+	rseq := header.NewRSeq()
+	rseq.SetSequenceNumber(this.rseq)
+	response.SetHeader(rseq)
+	this.rseq++
+
+	if this.pending != nil {
+		this.queue = append(this.queue, response)
+		return nil
+	}
+	return this.send(response)
+}
+
+func (this *ReliableProvisionalServer) send(response message.Response) error {
+	this.pending = response
+	this.interval = this.t1
+	this.elapsed = 0
+	if err := this.transaction.SendResponse(response); err != nil {
+		this.pending = nil
+		return err
+	}
+	this.timer = time.AfterFunc(this.interval, this.retransmit)
+	return nil
+}
+
+/** Retransmits the pending response with an interval that starts at T1 and
+ * doubles each time. After 64*T1 the INVITE is rejected with a 500.
+ */
+func (this *ReliableProvisionalServer) retransmit() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.pending == nil || this.terminated {
+		return
+	}
+	this.elapsed += this.interval
+	if this.elapsed >= 64*this.t1 {
+		this.terminate()
+		if invite, ok := this.transaction.GetRequest().(*message.SIPRequest); ok {
+			this.transaction.SendResponse(invite.CreateResponse(message.SERVER_INTERNAL_ERROR))
+		}
+		return
+	}
+	this.transaction.SendResponse(this.pending)
+	this.interval *= 2
+	this.timer = time.AfterFunc(this.interval, this.retransmit)
+}
+
+/** Processes a PRACK received in the dialog. The returned response is a 200
+ * if the RAck header matches the unacknowledged reliable provisional response
+ * and a 481 otherwise; it is to be sent on the server transaction of the
+ * PRACK. A matching PRACK stops the retransmissions and releases the next
+ * queued response.
+ */
+func (this *ReliableProvisionalServer) ProcessPrack(prack *message.SIPRequest) *message.SIPResponse {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	rack, ok := prack.GetHeader(core.SIPHeaderNames_RACK).(header.RAckHeader)
+	if !ok {
+		return prack.CreateResponse(message.BAD_REQUEST)
+	}
+	if this.pending == nil || !this.matches(rack) {
+		return prack.CreateResponse(message.CALL_OR_TRANSACTION_DOES_NOT_EXIST)
+	}
+
+	this.timer.Stop()
+	this.pending = nil
+	for len(this.queue) > 0 && !this.terminated {
+		next := this.queue[0]
+		this.queue = this.queue[1:]
+		if this.send(next) == nil {
+			break
+		}
+	}
+	return prack.CreateResponse(message.OK)
+}
+
+func (this *ReliableProvisionalServer) matches(rack header.RAckHeader) bool {
+	rseq, ok := this.pending.GetHeader(core.SIPHeaderNames_RSEQ).(header.RSeqHeader)
+	if !ok || rseq.GetSequenceNumber() != rack.GetRSeqNumber() {
+		return false
+	}
+	cseq, ok := this.pending.GetHeader(core.SIPHeaderNames_CSEQ).(header.CSeqHeader)
+	return ok && cseq.GetSequenceNumber() == rack.GetCSeqNumber() && cseq.GetMethod() == rack.GetMethod()
+}
+
+/** Returns true while a reliable provisional response waits for its PRACK.
+ */
+func (this *ReliableProvisionalServer) HasPendingResponse() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.pending != nil
+}
+
+/** Stops the retransmissions and drops the queued responses. This is to be
+ * called when a final response is sent to the INVITE.
+ */
+func (this *ReliableProvisionalServer) Terminate() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.terminate()
+}
+
+func (this *ReliableProvisionalServer) terminate() {
+	if this.timer != nil {
+		this.timer.Stop()
+	}
+	this.terminated = true
+	this.pending = nil
+	this.queue = nil
+}
+
+/**
+ * Acknowledges reliable provisional responses on behalf of a User Agent
+ * Client as described in RFC 3262 section 4. A PRACK is sent automatically
+ * for every reliable provisional response received in order; retransmissions
+ * and responses whose RSeq is not one higher than the last one received in
+ * the same early dialog are discarded. A client handles the responses of one
+ * INVITE at a time.
+ */
+type ReliableProvisionalClient struct {
+	mutex sync.Mutex
+
+	provider SipProvider
+	require  bool
+	lastRSeq map[string]int
+}
+
+/** Creates a client that sends its PRACKs through provider.
+ */
+func NewReliableProvisionalClient(provider SipProvider) *ReliableProvisionalClient {
+	return &ReliableProvisionalClient{
+		provider: provider,
+		lastRSeq: make(map[string]int),
+	}
+}
+
+/** Sets whether INVITEs require reliable provisional responses rather than
+ * merely support them.
+ */
+func (this *ReliableProvisionalClient) SetRequire(require bool) {
+	this.require = require
+}
+
+/** Adds "Supported: 100rel" (or "Require: 100rel") to an INVITE before it is
+ * sent and resets the state kept for the previous INVITE.
+ */
+func (this *ReliableProvisionalClient) PrepareRequest(invite message.Request) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.require {
+		AddRequire(invite, OPTION_100REL)
+	} else {
+		AddSupported(invite, OPTION_100REL)
+	}
+	this.lastRSeq = make(map[string]int)
+}
+
+/** Processes a response to the INVITE received in dialog. It returns true if
+ * the response is to be processed further by the application: unreliable
+ * and final responses, and reliable provisional responses received in order,
+ * which are acknowledged with a PRACK before returning.
+ */
+func (this *ReliableProvisionalClient) ProcessResponse(response message.Response, dialog Dialog) (bool, error) {
+	statusCode := response.GetStatusCode()
+	if statusCode >= message.OK {
+		this.mutex.Lock()
+		this.lastRSeq = make(map[string]int)
+		this.mutex.Unlock()
+		return true, nil
+	}
+	rseq, ok := response.GetHeader(core.SIPHeaderNames_RSEQ).(header.RSeqHeader)
+	if statusCode == message.TRYING || !ok || !IsOptionRequired(response, OPTION_100REL) {
+		return true, nil
+	}
+
+	var tag string
+	if to, ok := response.GetHeader(core.SIPHeaderNames_TO).(header.ToHeader); ok {
+		tag = to.GetTag()
+	}
+	this.mutex.Lock()
+	last, seen := this.lastRSeq[tag]
+	if seen && rseq.GetSequenceNumber() != last+1 {
+		this.mutex.Unlock()
+		return false, nil
+	}
+	this.lastRSeq[tag] = rseq.GetSequenceNumber()
+	this.mutex.Unlock()
+
+	prack, err := this.CreatePrack(dialog, response)
+	if err != nil {
+		return true, err
+	}
+	ct, err := this.provider.GetNewClientTransaction(prack)
+	if err != nil {
+		return true, err
+	}
+	return true, dialog.SendRequest(ct)
+}
+
+/** Creates a PRACK in dialog acknowledging the reliable provisional response.
+ */
+func (this *ReliableProvisionalClient) CreatePrack(dialog Dialog, response message.Response) (message.Request, error) {
+	rseq, ok := response.GetHeader(core.SIPHeaderNames_RSEQ).(header.RSeqHeader)
+	if !ok {
+		return nil, errors.New("SipException: response without RSeq")
+	}
+	cseq, ok := response.GetHeader(core.SIPHeaderNames_CSEQ).(header.CSeqHeader)
+	if !ok {
+		return nil, errors.New("SipException: response without CSeq")
+	}
+
+	prack, err := dialog.CreateRequest(message.PRACK)
+	if err != nil {
+		return nil, err
+	}
+	rack := header.NewRAck()
+	if err = rack.SetRSeqNumber(rseq.GetSequenceNumber()); err != nil {
+		return nil, err
+	}
+	if err = rack.SetCSeqNumber(cseq.GetSequenceNumber()); err != nil {
+		return nil, err
+	}
+	rack.SetMethod(cseq.GetMethod())
+	if err = prack.SetHeader(rack); err != nil {
+		return nil, err
+	}
+	return prack, nil
+}
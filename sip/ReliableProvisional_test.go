@@ -0,0 +1,171 @@
+package sip
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const reliableInvite = "INVITE sip:alice@192.0.2.1 SIP/2.0\r\n" +
+	"Via: SIP/2.0/UDP 192.0.2.2;branch=z9hG4bKinvite\r\n" +
+	"From: <sip:bob@b.example.com>;tag=bob-tag\r\n" +
+	"To: <sip:alice@a.example.com>\r\n" +
+	"Call-ID: dialog@a.example.com\r\n" +
+	"CSeq: 1 INVITE\r\n" +
+	"Max-Forwards: 70\r\n" +
+	"Supported: 100rel\r\n" +
+	"Content-Length: 0\r\n\r\n"
+
+func newProvisional(t *testing.T, invite *message.SIPRequest, statusCode int) *message.SIPResponse {
+	response := invite.CreateResponse(statusCode)
+	response.SetToTag("alice-tag")
+	return response
+}
+
+func getRSeq(t *testing.T, response message.Response) int {
+	rseq, ok := response.GetHeader(core.SIPHeaderNames_RSEQ).(header.RSeqHeader)
+	if !ok {
+		t.Fatal("no RSeq in", response.GetStatusCode())
+	}
+	return rseq.GetSequenceNumber()
+}
+
+func TestReliableProvisionalServer(t *testing.T) {
+	invite := parseRequest(t, reliableInvite)
+	transaction := newFakeServerTransaction(invite, nil)
+	server := NewReliableProvisionalServer(transaction)
+	defer server.Terminate()
+
+	if err := server.SendReliableProvisional(invite.CreateResponse(message.RINGING)); err == nil {
+		t.Error("provisional response without To tag sent reliably")
+	}
+	if err := server.SendReliableProvisional(newProvisional(t, invite, message.TRYING)); err == nil {
+		t.Error("100 sent reliably")
+	}
+
+	if err := server.SendReliableProvisional(newProvisional(t, invite, message.RINGING)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.SendReliableProvisional(newProvisional(t, invite, message.SESSION_PROGRESS)); err != nil {
+		t.Fatal(err)
+	}
+	responses := transaction.getResponses()
+	if len(responses) != 1 || responses[0].GetStatusCode() != message.RINGING {
+		t.Fatal("183 sent before the 180 was acknowledged")
+	}
+	ringing := reparse(t, responses[0]).(*message.SIPResponse)
+	if !IsOptionRequired(ringing, OPTION_100REL) {
+		t.Error("no Require: 100rel in", ringing)
+	}
+	rseq := getRSeq(t, ringing)
+
+	prack := parseRequest(t, inDialogRequest(message.PRACK, 2, "", ""))
+	if server.ProcessPrack(prack).GetStatusCode() != message.BAD_REQUEST {
+		t.Error("PRACK without RAck accepted")
+	}
+	prack = parseRequest(t, inDialogRequest(message.PRACK, 2, "RAck: "+strconv.Itoa(rseq+1)+" 1 INVITE\r\n", ""))
+	if server.ProcessPrack(prack).GetStatusCode() != message.CALL_OR_TRANSACTION_DOES_NOT_EXIST {
+		t.Error("PRACK of the wrong RSeq accepted")
+	}
+	prack = parseRequest(t, inDialogRequest(message.PRACK, 2, "RAck: "+strconv.Itoa(rseq)+" 1 INVITE\r\n", ""))
+	if server.ProcessPrack(prack).GetStatusCode() != message.OK {
+		t.Fatal("PRACK of the 180 rejected")
+	}
+	responses = transaction.getResponses()
+	if len(responses) != 2 || responses[1].GetStatusCode() != message.SESSION_PROGRESS {
+		t.Fatal("queued 183 not sent after the PRACK")
+	}
+	if getRSeq(t, responses[1]) != rseq+1 {
+		t.Error("RSeq not incremented", getRSeq(t, responses[1]), rseq)
+	}
+	if server.ProcessPrack(prack).GetStatusCode() != message.CALL_OR_TRANSACTION_DOES_NOT_EXIST {
+		t.Error("PRACK of the 180 accepted twice")
+	}
+}
+
+func TestReliableProvisionalRetransmission(t *testing.T) {
+	invite := parseRequest(t, reliableInvite)
+	transaction := newFakeServerTransaction(invite, nil)
+	server := NewReliableProvisionalServer(transaction)
+	server.t1 = 10 * time.Millisecond
+
+	start := time.Now()
+	if err := server.SendReliableProvisional(newProvisional(t, invite, message.RINGING)); err != nil {
+		t.Fatal(err)
+	}
+	// retransmitted at T1, 3*T1, 7*T1 ... 63*T1, rejected with a 500 at 127*T1
+	deadline := time.Now().Add(5 * time.Second)
+	for server.HasPendingResponse() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if server.HasPendingResponse() {
+		t.Fatal("retransmissions not stopped")
+	}
+	if elapsed := time.Since(start); elapsed < 64*server.t1 {
+		t.Error("retransmissions stopped after", elapsed)
+	}
+	responses := transaction.getResponses()
+	if len(responses) != 8 {
+		t.Fatal("bad number of responses", len(responses))
+	}
+	for _, response := range responses[:7] {
+		if response.GetStatusCode() != message.RINGING {
+			t.Error("bad retransmission", response.GetStatusCode())
+		}
+	}
+	if responses[7].GetStatusCode() != message.SERVER_INTERNAL_ERROR {
+		t.Error("INVITE not rejected with a 500", responses[7].GetStatusCode())
+	}
+	if err := server.SendReliableProvisional(newProvisional(t, invite, message.RINGING)); err == nil {
+		t.Error("provisional response sent after the final response")
+	}
+}
+
+func TestReliableProvisionalClient(t *testing.T) {
+	invite := parseRequest(t, reliableInvite)
+	dialog := newFakeDialog(t)
+	client := NewReliableProvisionalClient(dialog.provider)
+
+	reliable := func(statusCode int, rseq int) *message.SIPResponse {
+		response := newProvisional(t, invite, statusCode)
+		AddRequire(response, OPTION_100REL)
+		rseqHeader := header.NewRSeq()
+		rseqHeader.SetSequenceNumber(rseq)
+		response.SetHeader(rseqHeader)
+		return reparse(t, response).(*message.SIPResponse)
+	}
+
+	if ok, err := client.ProcessResponse(newProvisional(t, invite, message.RINGING), dialog); !ok || err != nil {
+		t.Fatal("unreliable provisional response discarded", err)
+	}
+	if len(dialog.provider.getSent()) != 0 {
+		t.Fatal("unreliable provisional response acknowledged")
+	}
+
+	ringing := reliable(message.RINGING, 7)
+	if ok, err := client.ProcessResponse(ringing, dialog); !ok || err != nil {
+		t.Fatal("reliable provisional response discarded", err)
+	}
+	prack := dialog.provider.lastSent(t)
+	rack, ok := prack.GetHeader(core.SIPHeaderNames_RACK).(header.RAckHeader)
+	if prack.GetMethod() != message.PRACK || !ok ||
+		rack.GetRSeqNumber() != 7 || rack.GetCSeqNumber() != 1 || rack.GetMethod() != message.INVITE {
+		t.Fatal("bad PRACK", prack)
+	}
+
+	if ok, _ := client.ProcessResponse(ringing, dialog); ok {
+		t.Error("retransmission accepted")
+	}
+	if ok, _ := client.ProcessResponse(reliable(message.SESSION_PROGRESS, 9), dialog); ok {
+		t.Error("response out of order accepted")
+	}
+	if ok, _ := client.ProcessResponse(reliable(message.SESSION_PROGRESS, 8), dialog); !ok {
+		t.Error("next response discarded")
+	}
+	if len(dialog.provider.getSent()) != 2 {
+		t.Error("bad number of PRACKs", len(dialog.provider.getSent()))
+	}
+}
@@ -0,0 +1,240 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Replaces.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+/**
+ * Keeps the dialogs of a User Agent so that the target of a Replaces or Join
+ * header can be found. Dialogs are matched on their current tags, so a dialog
+ * can be added before its remote tag is known.
+ */
+type DialogTable struct {
+	mutex   sync.Mutex
+	dialogs []Dialog
+}
+
+/** Creates an empty dialog table.
+ */
+func NewDialogTable() *DialogTable {
+	return &DialogTable{}
+}
+
+/** Adds dialog to the table.
+ */
+func (this *DialogTable) Add(dialog Dialog) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for _, d := range this.dialogs {
+		if d == dialog {
+			return
+		}
+	}
+	this.dialogs = append(this.dialogs, dialog)
+}
+
+/** Removes dialog from the table, e.g. once it is terminated.
+ */
+func (this *DialogTable) Remove(dialog Dialog) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for i, d := range this.dialogs {
+		if d == dialog {
+			this.dialogs = append(this.dialogs[:i], this.dialogs[i+1:]...)
+			return
+		}
+	}
+}
+
+/** Returns the dialog with the given Call-ID, local tag and remote tag, or
+ * nil if there is none.
+ */
+func (this *DialogTable) Find(callId, localTag, remoteTag string) Dialog {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for _, d := range this.dialogs {
+		if d.GetCallId() == nil || d.GetCallId().GetCallId() != callId {
+			continue
+		}
+		if d.GetLocalTag() == localTag && d.GetRemoteTag() == remoteTag {
+			return d
+		}
+	}
+	return nil
+}
+
+/** Returns a Replaces header identifying dialog as seen by the other side of
+ * the dialog, as placed in the Refer-To of an attended transfer.
+ */
+func CreateReplaces(dialog Dialog, earlyOnly bool) (header.ReplacesHeader, error) {
+	replaces := header.NewReplaces()
+	if err := replaces.SetCallId(dialog.GetCallId().GetCallId()); err != nil {
+		return nil, err
+	}
+	replaces.SetToTag(dialog.GetRemoteTag())
+	replaces.SetFromTag(dialog.GetLocalTag())
+	replaces.SetEarlyOnly(earlyOnly)
+	return replaces, nil
+}
+
+/** Embeds the Replaces header in the URI of a Refer-To address, e.g.
+ * <code>sip:bob@example.com?Replaces=12345%40host%3Bto-tag%3D1%3Bfrom-tag%3D2</code>.
+ */
+func SetReplacesInReferTo(referTo address.Address, replaces header.ReplacesHeader) (SipException error) {
+	uri, ok := referTo.GetURI().(*address.SipURIImpl)
+	if !ok {
+		return errors.New("SipException: Replaces can only be embedded in a SIP URI")
+	}
+	uri.SetHeader(core.SIPHeaderNames_REPLACES, escapeURIHeader(replaces.EncodeBody()))
+	return nil
+}
+
+/** Escapes a header value embedded in a SIP URI. Unlike with url.QueryEscape
+ * a space becomes %20: in a SIP URI a "+" stands for itself (RFC 3261 section
+ * 19.1.1), so the value is unescaped with url.PathUnescape.
+ */
+func escapeURIHeader(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
+
+/** Returns the Replaces header embedded in the URI of a Refer-To header, or
+ * nil if there is none. The transfer target places it in the INVITE sent to
+ * the Refer-To URI.
+ */
+func GetReplacesFromReferTo(referTo header.ReferToHeader) (header.ReplacesHeader, error) {
+	uri, ok := referTo.GetAddress().GetURI().(*address.SipURIImpl)
+	if !ok {
+		return nil, nil
+	}
+	value := uri.GetHeader(core.SIPHeaderNames_REPLACES)
+	if value == "" {
+		return nil, nil
+	}
+	value, err := url.PathUnescape(value)
+	if err != nil {
+		return nil, errors.New("ParseException: bad Replaces escaping in Refer-To")
+	}
+	h, err := parser.NewReplacesParser(core.SIPHeaderNames_REPLACES + ": " + value + "\n").Parse()
+	if err != nil {
+		return nil, err
+	}
+	return h.(header.ReplacesHeader), nil
+}
+
+/** Looks up the dialog identified by the Replaces header of an INVITE, as
+ * described in RFC 3891 section 3. If the INVITE cannot replace a dialog, the
+ * response to send is returned: 400 for more than one Replaces header, 481
+ * if no dialog matches or the match is an early dialog this side did not
+ * initiate, 486 if early-only is set but the dialog is confirmed and 603 if
+ * the dialog is already terminated. The returned dialog is nil if the INVITE
+ * has no Replaces header.
+ * <p>
+ * Once the new dialog is established, the replaced dialog is ended with
+ * TerminateReplaced.
+ */
+func ProcessReplaces(invite *message.SIPRequest, table *DialogTable) (Dialog, *message.SIPResponse) {
+	headers := invite.GetHeaders(core.SIPHeaderNames_REPLACES)
+	if headers.Len() == 0 {
+		return nil, nil
+	}
+	if headers.Len() > 1 || invite.GetHeader(core.SIPHeaderNames_JOIN) != nil {
+		return nil, invite.CreateResponse(message.BAD_REQUEST)
+	}
+	replaces, ok := headers.Front().Value.(header.ReplacesHeader)
+	if !ok {
+		return nil, invite.CreateResponse(message.BAD_REQUEST)
+	}
+
+	dialog, response := findTargetDialog(invite, table, replaces.GetCallId(), replaces.GetToTag(), replaces.GetFromTag())
+	if response != nil {
+		return nil, response
+	}
+	if replaces.IsEarlyOnly() && dialog.GetState() == DIALOGSTATE_CONFIRMED {
+		return nil, invite.CreateResponse(message.BUSY_HERE)
+	}
+	return dialog, nil
+}
+
+/** Looks up the dialog identified by the Join header of an INVITE, as
+ * described in RFC 3911 section 4. If the INVITE cannot join a dialog, the
+ * response to send is returned: 400 for more than one Join header, 481 if no
+ * dialog matches or the match is an early dialog this side did not initiate
+ * and 603 if the dialog is already terminated. The returned dialog is nil if
+ * the INVITE has no Join header. The joined dialog is left unchanged.
+ */
+func ProcessJoin(invite *message.SIPRequest, table *DialogTable) (Dialog, *message.SIPResponse) {
+	headers := invite.GetHeaders(core.SIPHeaderNames_JOIN)
+	if headers.Len() == 0 {
+		return nil, nil
+	}
+	if headers.Len() > 1 || invite.GetHeader(core.SIPHeaderNames_REPLACES) != nil {
+		return nil, invite.CreateResponse(message.BAD_REQUEST)
+	}
+	join, ok := headers.Front().Value.(header.JoinHeader)
+	if !ok {
+		return nil, invite.CreateResponse(message.BAD_REQUEST)
+	}
+	return findTargetDialog(invite, table, join.GetCallId(), join.GetToTag(), join.GetFromTag())
+}
+
+/** The to-tag of Replaces and Join is the local tag of the target dialog and
+ * the from-tag its remote tag.
+ */
+func findTargetDialog(invite *message.SIPRequest, table *DialogTable, callId, toTag, fromTag string) (Dialog, *message.SIPResponse) {
+	dialog := table.Find(callId, toTag, fromTag)
+	if dialog == nil {
+		return nil, invite.CreateResponse(message.CALL_OR_TRANSACTION_DOES_NOT_EXIST)
+	}
+	switch state := dialog.GetState(); {
+	case state == DIALOGSTATE_TERMINATED || state == DIALOGSTATE_COMPLETED:
+		return nil, invite.CreateResponse(message.DECLINE)
+	case state != DIALOGSTATE_CONFIRMED && dialog.IsServer():
+		return nil, invite.CreateResponse(message.CALL_OR_TRANSACTION_DOES_NOT_EXIST)
+	}
+	return dialog, nil
+}
+
+/** Ends a dialog replaced by a new one: a confirmed dialog with a BYE and an
+ * early dialog this side initiated with a CANCEL of its INVITE.
+ */
+func TerminateReplaced(provider SipProvider, dialog Dialog) (SipException error) {
+	var request message.Request
+	if dialog.GetState() == DIALOGSTATE_CONFIRMED {
+		if request, SipException = dialog.CreateRequest(message.BYE); SipException != nil {
+			return SipException
+		}
+		ct, err := provider.GetNewClientTransaction(request)
+		if err != nil {
+			return err
+		}
+		return dialog.SendRequest(ct)
+	}
+
+	inviteTransaction, ok := dialog.GetFirstTransaction().(ClientTransaction)
+	if !ok {
+		return errors.New("SipException: the dialog was not initiated by this side")
+	}
+	if request, SipException = inviteTransaction.CreateCancel(); SipException != nil {
+		return SipException
+	}
+	ct, err := provider.GetNewClientTransaction(request)
+	if err != nil {
+		return err
+	}
+	return ct.SendRequest()
+}
@@ -0,0 +1,120 @@
+package sip
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strings"
+	"testing"
+)
+
+func TestReplacesInReferTo(t *testing.T) {
+	replaces := header.NewReplaces()
+	replaces.SetCallId("a+b@a.example.com")
+	replaces.SetToTag("to")
+	replaces.SetFromTag("from")
+	replaces.SetEarlyOnly(true)
+
+	referTo := parseAddress(t, "<sip:bob@b.example.com>")
+	if err := SetReplacesInReferTo(referTo, replaces); err != nil {
+		t.Fatal(err)
+	}
+	s := referTo.String()
+	if strings.Contains(s, "+") || !strings.Contains(s, "a%2Bb%40a.example.com%3Bto-tag%3Dto") {
+		t.Error("bad escaping", s)
+	}
+	if escaped := escapeURIHeader("a b+c"); escaped != "a%20b%2Bc" {
+		t.Error("bad escaping", escaped)
+	}
+
+	h := header.NewReferTo()
+	h.SetAddress(parseAddress(t, s))
+	decoded, err := GetReplacesFromReferTo(h)
+	if err != nil || decoded == nil {
+		t.Fatal("no Replaces in", s, err)
+	}
+	if decoded.GetCallId() != "a+b@a.example.com" || decoded.GetToTag() != "to" ||
+		decoded.GetFromTag() != "from" || !decoded.IsEarlyOnly() {
+		t.Error("Replaces changed", decoded.EncodeBody())
+	}
+
+	// a "+" sent unescaped stands for itself
+	h.SetAddress(parseAddress(t, "<sip:bob@b.example.com?Replaces=a+b%40a.example.com%3Bto-tag%3D1%3Bfrom-tag%3D2>"))
+	if decoded, err := GetReplacesFromReferTo(h); err != nil || decoded.GetCallId() != "a+b@a.example.com" {
+		t.Error("bad Call-ID", decoded, err)
+	}
+}
+
+func TestProcessReplaces(t *testing.T) {
+	dialog := newFakeDialog(t)
+	table := NewDialogTable()
+	table.Add(dialog)
+
+	invite := func(headers string) *message.SIPRequest {
+		return parseRequest(t, "INVITE sip:alice@192.0.2.1 SIP/2.0\r\n"+
+			"Via: SIP/2.0/UDP 198.51.100.3;branch=z9hG4bKcarol\r\n"+
+			"From: <sip:carol@c.example.com>;tag=carol-tag\r\n"+
+			"To: <sip:alice@a.example.com>\r\n"+
+			"Call-ID: carol@c.example.com\r\n"+
+			"CSeq: 1 INVITE\r\n"+
+			"Max-Forwards: 70\r\n"+headers+
+			"Content-Length: 0\r\n\r\n")
+	}
+	const target = "dialog@a.example.com;to-tag=alice-tag;from-tag=bob-tag"
+
+	tests := []struct {
+		state      *DialogState
+		server     bool
+		headers    string
+		statusCode int
+	}{
+		{DIALOGSTATE_CONFIRMED, false, "", 0},
+		{DIALOGSTATE_CONFIRMED, true, "Replaces: " + target + "\r\n", 0},
+		// the tags of the dialog seen from the other side do not match
+		{DIALOGSTATE_CONFIRMED, false, "Replaces: dialog@a.example.com;to-tag=bob-tag;from-tag=alice-tag\r\n",
+			message.CALL_OR_TRANSACTION_DOES_NOT_EXIST},
+		{DIALOGSTATE_CONFIRMED, false, "Replaces: other@a.example.com;to-tag=alice-tag;from-tag=bob-tag\r\n",
+			message.CALL_OR_TRANSACTION_DOES_NOT_EXIST},
+		{DIALOGSTATE_TERMINATED, false, "Replaces: " + target + "\r\n", message.DECLINE},
+		{DIALOGSTATE_COMPLETED, false, "Replaces: " + target + "\r\n", message.DECLINE},
+		// an early dialog can only be replaced by its initiator's peer
+		{DIALOGSTATE_EARLY, false, "Replaces: " + target + "\r\n", 0},
+		{DIALOGSTATE_EARLY, true, "Replaces: " + target + "\r\n", message.CALL_OR_TRANSACTION_DOES_NOT_EXIST},
+		{DIALOGSTATE_EARLY, false, "Replaces: " + target + ";early-only\r\n", 0},
+		{DIALOGSTATE_CONFIRMED, false, "Replaces: " + target + ";early-only\r\n", message.BUSY_HERE},
+		{DIALOGSTATE_CONFIRMED, false, "Replaces: " + target + "\r\nJoin: " + target + "\r\n", message.BAD_REQUEST},
+	}
+	for _, test := range tests {
+		dialog.state, dialog.server = test.state, test.server
+		replaced, response := ProcessReplaces(invite(test.headers), table)
+		switch {
+		case test.statusCode != 0 && (response == nil || response.GetStatusCode() != test.statusCode):
+			t.Errorf("%v %v %q: expected %d, got %v", test.state, test.server, test.headers, test.statusCode, response)
+		case test.statusCode == 0 && response != nil:
+			t.Errorf("%v %v %q: rejected with %d", test.state, test.server, test.headers, response.GetStatusCode())
+		case test.statusCode == 0 && test.headers != "" && replaced != dialog:
+			t.Errorf("%v %v %q: dialog not found", test.state, test.server, test.headers)
+		case test.headers == "" && replaced != nil:
+			t.Error("dialog replaced without Replaces")
+		}
+	}
+
+	dialog.state, dialog.server = DIALOGSTATE_CONFIRMED, false
+	if joined, response := ProcessJoin(invite("Join: "+target+"\r\n"), table); joined != dialog || response != nil {
+		t.Error("dialog not joined", response)
+	}
+	table.Remove(dialog)
+	if _, response := ProcessJoin(invite("Join: "+target+"\r\n"), table); response == nil ||
+		response.GetStatusCode() != message.CALL_OR_TRANSACTION_DOES_NOT_EXIST {
+		t.Error("removed dialog joined")
+	}
+
+	// a confirmed dialog is replaced with a BYE
+	if err := TerminateReplaced(dialog.provider, dialog); err != nil {
+		t.Fatal(err)
+	}
+	if bye := dialog.provider.lastSent(t); bye.GetMethod() != message.BYE ||
+		bye.GetHeader(core.SIPHeaderNames_CALL_ID).(header.CallIdHeader).GetCallId() != "dialog@a.example.com" {
+		t.Error("replaced dialog not terminated", bye)
+	}
+}
@@ -0,0 +1,370 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : SessionTimer.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"sync"
+	"time"
+)
+
+/**
+ * The smallest session interval allowed by RFC 4028, in seconds.
+ */
+const SESSION_TIMER_MIN_SE = 90
+
+/**
+ * The default session interval recommended by RFC 4028, in seconds.
+ */
+const SESSION_TIMER_DEFAULT_INTERVAL = 1800
+
+/**
+ * This interface is implemented by applications that use a SessionTimer and
+ * want to take part in refreshes or learn about expired sessions.
+ */
+type SessionTimerListener interface {
+	/**
+	 * Called before a session refresh request is sent, so that the
+	 * application can add a session description to a re-INVITE.
+	 */
+	ProcessSessionRefresh(request message.Request)
+
+	/**
+	 * Called after the session expired and a BYE was sent in dialog.
+	 */
+	ProcessSessionExpired(dialog Dialog)
+}
+
+/**
+ * Keeps a session alive as described in RFC 4028. The session interval and
+ * the refresher ("uac" or "uas") are negotiated in the Session-Expires header
+ * of an INVITE or UPDATE and its 2xx response. The refresher sends a re-INVITE
+ * or UPDATE at half the session interval; if no refresh succeeds before the
+ * session expires, a BYE is sent. Requests asking for an interval below the
+ * Min-SE of this side are rejected with 422.
+ * <p>
+ * A session timer belongs to a single dialog. Each successful INVITE or
+ * UPDATE transaction in the dialog restarts it, whichever side sent it.
+ */
+type SessionTimer struct {
+	mutex sync.Mutex
+
+	provider      SipProvider
+	listener      SessionTimerListener
+	refreshMethod string
+	interval      int
+	minSE         int
+	unit          time.Duration // of the intervals, shorter than a second in tests
+
+	dialog       Dialog
+	refresher    bool
+	generation   int
+	refreshTimer *time.Timer
+	expiryTimer  *time.Timer
+}
+
+/** Creates a session timer that asks for the given session interval in
+ * seconds and sends refreshes and BYEs through provider. Refreshes are sent
+ * as re-INVITEs unless SetRefreshMethod is used.
+ */
+func NewSessionTimer(provider SipProvider, interval int) *SessionTimer {
+	this := &SessionTimer{
+		provider:      provider,
+		refreshMethod: message.INVITE,
+		minSE:         SESSION_TIMER_MIN_SE,
+		unit:          time.Second,
+	}
+	this.SetInterval(interval)
+	return this
+}
+
+/** Sets the listener informed about refreshes and expiry.
+ */
+func (this *SessionTimer) SetSessionTimerListener(listener SessionTimerListener) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.listener = listener
+}
+
+/** Sets the method used for refreshes, message.INVITE or message.UPDATE.
+ */
+func (this *SessionTimer) SetRefreshMethod(method string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.refreshMethod = method
+}
+
+/** Sets the session interval asked for in requests. It is raised to the
+ * minimum session interval if smaller.
+ */
+func (this *SessionTimer) SetInterval(interval int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.interval = interval
+	if this.interval < this.minSE {
+		this.interval = this.minSE
+	}
+}
+
+/** Returns the session interval in seconds. Once a session is running this is
+ * the negotiated value.
+ */
+func (this *SessionTimer) GetInterval() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.interval
+}
+
+/** Sets the smallest session interval this side accepts. Values below 90
+ * seconds are raised to 90.
+ */
+func (this *SessionTimer) SetMinSE(minSE int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.minSE = minSE
+	if this.minSE < SESSION_TIMER_MIN_SE {
+		this.minSE = SESSION_TIMER_MIN_SE
+	}
+	if this.interval < this.minSE {
+		this.interval = this.minSE
+	}
+}
+
+/** Returns the smallest session interval this side accepts.
+ */
+func (this *SessionTimer) GetMinSE() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.minSE
+}
+
+/** Returns true if this side is responsible for refreshing the session.
+ */
+func (this *SessionTimer) IsRefresher() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.refresher
+}
+
+/** Adds the Supported, Session-Expires and Min-SE headers to an INVITE or
+ * UPDATE before it is sent. If this side already refreshes the session the
+ * request keeps that role with "refresher=uac".
+ */
+func (this *SessionTimer) PrepareRequest(request message.Request) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.prepareRequest(request)
+}
+
+func (this *SessionTimer) prepareRequest(request message.Request) {
+	AddSupported(request, OPTION_TIMER)
+
+	sessionExpires := header.NewSessionExpires()
+	sessionExpires.SetExpires(this.interval)
+	if this.dialog != nil && this.refresher {
+		sessionExpires.SetRefresher(header.SessionExpires_UAC)
+	}
+	request.SetHeader(sessionExpires)
+
+	minSE := header.NewMinSE()
+	minSE.SetExpires(this.minSE)
+	request.SetHeader(minSE)
+}
+
+/** Processes a response to an INVITE or UPDATE sent in dialog. A 422 raises
+ * the session interval to the Min-SE of the response so that the request can
+ * be retried. A 2xx starts or restarts the session timer with the interval
+ * and refresher chosen by the UAS; a 2xx without Session-Expires means the
+ * session does not expire.
+ */
+func (this *SessionTimer) ProcessResponse(response message.Response, dialog Dialog) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	statusCode := response.GetStatusCode()
+	if statusCode == message.SESSION_INTERVAL_TOO_SMALL {
+		if minSE, ok := response.GetHeader(core.SIPHeaderNames_MIN_SE).(header.MinSEHeader); ok {
+			if minSE.GetExpires() > this.minSE {
+				this.minSE = minSE.GetExpires()
+			}
+			if this.interval < this.minSE {
+				this.interval = this.minSE
+			}
+		}
+		return
+	}
+	if statusCode < message.OK || statusCode >= message.MULTIPLE_CHOICES {
+		return
+	}
+
+	sessionExpires, ok := response.GetHeader(core.SIPHeaderNames_SESSION_EXPIRES).(header.SessionExpiresHeader)
+	if !ok {
+		this.stop()
+		return
+	}
+	this.interval = sessionExpires.GetExpires()
+	// RFC 4028 section 7.2: if the UAS did not choose, the UAC refreshes.
+	this.start(dialog, sessionExpires.GetRefresher() != header.SessionExpires_UAS)
+}
+
+/** Checks the session interval of a received INVITE or UPDATE. If it is
+ * below the minimum session interval of this side, the 422 response to send
+ * is returned, carrying Min-SE; otherwise nil is returned.
+ */
+func (this *SessionTimer) ProcessRequest(request *message.SIPRequest) *message.SIPResponse {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	sessionExpires, ok := request.GetHeader(core.SIPHeaderNames_SESSION_EXPIRES).(header.SessionExpiresHeader)
+	if !ok || sessionExpires.GetExpires() >= this.minSE {
+		return nil
+	}
+	response := request.CreateResponse(message.SESSION_INTERVAL_TOO_SMALL)
+	minSE := header.NewMinSE()
+	minSE.SetExpires(this.minSE)
+	response.SetHeader(minSE)
+	return response
+}
+
+/** Adds Session-Expires (and Require: timer when the UAC refreshes) to the
+ * 2xx response to an INVITE or UPDATE received in dialog and starts or
+ * restarts the session timer. The session interval is the smaller of the
+ * requested one and our own, but never below the Min-SE of the request. The
+ * refresher asked for by the UAC is kept; otherwise the UAC refreshes if it
+ * supports session timers and this side does if not.
+ */
+func (this *SessionTimer) PrepareResponse(request message.Request, response message.Response, dialog Dialog) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	interval := this.interval
+	refresher := ""
+	if sessionExpires, ok := request.GetHeader(core.SIPHeaderNames_SESSION_EXPIRES).(header.SessionExpiresHeader); ok {
+		if sessionExpires.GetExpires() < interval {
+			interval = sessionExpires.GetExpires()
+		}
+		refresher = sessionExpires.GetRefresher()
+	}
+	if minSE, ok := request.GetHeader(core.SIPHeaderNames_MIN_SE).(header.MinSEHeader); ok {
+		if interval < minSE.GetExpires() {
+			interval = minSE.GetExpires()
+		}
+	}
+
+	supported := IsOptionSupported(request, OPTION_TIMER)
+	if refresher == "" || !supported {
+		if supported {
+			refresher = header.SessionExpires_UAC
+		} else {
+			refresher = header.SessionExpires_UAS
+		}
+	}
+
+	sessionExpires := header.NewSessionExpires()
+	sessionExpires.SetExpires(interval)
+	sessionExpires.SetRefresher(refresher)
+	response.SetHeader(sessionExpires)
+	if refresher == header.SessionExpires_UAC {
+		AddRequire(response, OPTION_TIMER)
+	}
+
+	this.interval = interval
+	this.start(dialog, refresher == header.SessionExpires_UAS)
+}
+
+/** Stops the session timer, e.g. when the dialog is terminated.
+ */
+func (this *SessionTimer) Stop() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.stop()
+}
+
+func (this *SessionTimer) stop() {
+	this.generation++
+	if this.refreshTimer != nil {
+		this.refreshTimer.Stop()
+		this.refreshTimer = nil
+	}
+	if this.expiryTimer != nil {
+		this.expiryTimer.Stop()
+		this.expiryTimer = nil
+	}
+}
+
+func (this *SessionTimer) start(dialog Dialog, refresher bool) {
+	this.stop()
+	this.dialog = dialog
+	this.refresher = refresher
+
+	generation := this.generation
+	interval := time.Duration(this.interval) * this.unit
+	if refresher {
+		this.refreshTimer = time.AfterFunc(interval/2, func() { this.refresh(generation) })
+	}
+	// RFC 4028 section 10: the BYE is sent slightly before the session
+	// expires, so that the other side does not tear it down first.
+	guard := interval / 3
+	if guard > 32*this.unit {
+		guard = 32 * this.unit
+	}
+	this.expiryTimer = time.AfterFunc(interval-guard, func() { this.expire(generation) })
+}
+
+/** Sends a session refresh at half the session interval.
+ */
+func (this *SessionTimer) refresh(generation int) {
+	this.mutex.Lock()
+	dialog := this.dialog
+	listener := this.listener
+	if generation != this.generation {
+		this.mutex.Unlock()
+		return
+	}
+	this.refreshTimer = nil
+	request, err := dialog.CreateRequest(this.refreshMethod)
+	if err != nil {
+		this.mutex.Unlock()
+		return
+	}
+	this.prepareRequest(request)
+	this.mutex.Unlock()
+
+	if listener != nil {
+		listener.ProcessSessionRefresh(request)
+	}
+	if ct, err := this.provider.GetNewClientTransaction(request); err == nil {
+		dialog.SendRequest(ct)
+	}
+}
+
+/** Ends the session with a BYE because no refresh arrived in time.
+ */
+func (this *SessionTimer) expire(generation int) {
+	this.mutex.Lock()
+	dialog := this.dialog
+	listener := this.listener
+	if generation != this.generation {
+		this.mutex.Unlock()
+		return
+	}
+	this.stop()
+	this.dialog = nil
+	this.mutex.Unlock()
+
+	if bye, err := dialog.CreateRequest(message.BYE); err == nil {
+		if ct, err := this.provider.GetNewClientTransaction(bye); err == nil {
+			dialog.SendRequest(ct)
+		}
+	}
+	if listener != nil {
+		listener.ProcessSessionExpired(dialog)
+	}
+}
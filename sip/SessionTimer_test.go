@@ -0,0 +1,193 @@
+package sip
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sessionTimerEvents struct {
+	mutex     sync.Mutex
+	refreshes []message.Request
+	expired   []Dialog
+}
+
+func (this *sessionTimerEvents) ProcessSessionRefresh(request message.Request) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.refreshes = append(this.refreshes, request)
+}
+
+func (this *sessionTimerEvents) ProcessSessionExpired(dialog Dialog) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.expired = append(this.expired, dialog)
+}
+
+func (this *sessionTimerEvents) count() (refreshes, expired int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return len(this.refreshes), len(this.expired)
+}
+
+func getSessionExpires(t *testing.T, msg message.Message) header.SessionExpiresHeader {
+	sessionExpires, ok := msg.GetHeader(core.SIPHeaderNames_SESSION_EXPIRES).(header.SessionExpiresHeader)
+	if !ok {
+		t.Fatal("no Session-Expires in", msg)
+	}
+	return sessionExpires
+}
+
+func TestSessionTimerRefresher(t *testing.T) {
+	dialog := newFakeDialog(t)
+
+	tests := []struct {
+		headers   string
+		interval  int
+		refresher string
+	}{
+		// the UAC supports session timers and did not choose: it refreshes
+		{"Supported: timer\r\nSession-Expires: 600\r\n", 600, header.SessionExpires_UAC},
+		{"Supported: timer\r\nSession-Expires: 600;refresher=uas\r\n", 600, header.SessionExpires_UAS},
+		// our interval is smaller than the requested one
+		{"Supported: timer\r\nSession-Expires: 3600;refresher=uac\r\n", 1800, header.SessionExpires_UAC},
+		// the UAC does not support session timers: we refresh
+		{"Session-Expires: 600;refresher=uac\r\n", 600, header.SessionExpires_UAS},
+		{"", 1800, header.SessionExpires_UAS},
+		// never below the Min-SE of the request
+		{"Supported: timer\r\nSession-Expires: 300\r\nMin-SE: 400\r\n", 400, header.SessionExpires_UAC},
+	}
+	for _, test := range tests {
+		timer := NewSessionTimer(dialog.provider, 1800)
+		request := parseRequest(t, inDialogRequest(message.INVITE, 2, test.headers, ""))
+		if response := timer.ProcessRequest(request); response != nil {
+			t.Errorf("%q rejected with %d", test.headers, response.GetStatusCode())
+			continue
+		}
+		response := request.CreateResponse(message.OK)
+		timer.PrepareResponse(request, response, dialog)
+		response = reparse(t, response).(*message.SIPResponse)
+		sessionExpires := getSessionExpires(t, response)
+		if sessionExpires.GetExpires() != test.interval || sessionExpires.GetRefresher() != test.refresher {
+			t.Errorf("%q answered with Session-Expires %d;refresher=%s", test.headers,
+				sessionExpires.GetExpires(), sessionExpires.GetRefresher())
+		}
+		if IsOptionRequired(response, OPTION_TIMER) != (test.refresher == header.SessionExpires_UAC) {
+			t.Errorf("%q answered with bad Require", test.headers)
+		}
+		if timer.IsRefresher() != (test.refresher == header.SessionExpires_UAS) {
+			t.Errorf("%q: bad refresher role", test.headers)
+		}
+		timer.Stop()
+	}
+
+	// as UAC: the UAS that does not choose leaves the refresh to us
+	timer := NewSessionTimer(dialog.provider, 1800)
+	defer timer.Stop()
+	timer.ProcessResponse(parseResponse(t, "SIP/2.0 200 OK\r\n"+
+		"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bK1\r\n"+
+		"From: <sip:alice@a.example.com>;tag=alice-tag\r\n"+
+		"To: <sip:bob@b.example.com>;tag=bob-tag\r\n"+
+		"Call-ID: dialog@a.example.com\r\n"+
+		"CSeq: 11 INVITE\r\n"+
+		"Session-Expires: 900\r\n"+
+		"Content-Length: 0\r\n\r\n"), dialog)
+	if !timer.IsRefresher() || timer.GetInterval() != 900 {
+		t.Error("bad session after the 2xx", timer.IsRefresher(), timer.GetInterval())
+	}
+	request, _ := dialog.CreateRequest(message.INVITE)
+	timer.PrepareRequest(request)
+	if sessionExpires := getSessionExpires(t, reparse(t, request)); sessionExpires.GetRefresher() != header.SessionExpires_UAC ||
+		sessionExpires.GetExpires() != 900 {
+		t.Error("refresh does not keep the refresher role", request)
+	}
+}
+
+func TestSessionTimerMinSE(t *testing.T) {
+	dialog := newFakeDialog(t)
+	timer := NewSessionTimer(dialog.provider, 60)
+	if timer.GetInterval() != SESSION_TIMER_MIN_SE {
+		t.Error("interval below 90 seconds", timer.GetInterval())
+	}
+	timer.SetMinSE(300)
+
+	request := parseRequest(t, inDialogRequest(message.INVITE, 2, "Session-Expires: 200\r\n", ""))
+	response := timer.ProcessRequest(request)
+	if response == nil || response.GetStatusCode() != message.SESSION_INTERVAL_TOO_SMALL {
+		t.Fatal("interval below Min-SE accepted")
+	}
+	response = reparse(t, response).(*message.SIPResponse)
+	minSE, ok := response.GetHeader(core.SIPHeaderNames_MIN_SE).(header.MinSEHeader)
+	if !ok || minSE.GetExpires() != 300 {
+		t.Fatal("422 without Min-SE: 300", response)
+	}
+
+	// the UAC retries with the Min-SE of the 422
+	caller := NewSessionTimer(dialog.provider, 120)
+	caller.ProcessResponse(response, dialog)
+	if caller.GetInterval() != 300 || caller.GetMinSE() != 300 {
+		t.Fatal("422 not applied", caller.GetInterval(), caller.GetMinSE())
+	}
+	retry, _ := dialog.CreateRequest(message.INVITE)
+	caller.PrepareRequest(retry)
+	retry = reparse(t, retry).(*message.SIPRequest)
+	if getSessionExpires(t, retry).GetExpires() != 300 {
+		t.Error("retry with a bad Session-Expires", retry)
+	}
+	if timer.ProcessRequest(retry.(*message.SIPRequest)) != nil {
+		t.Error("retry rejected")
+	}
+}
+
+func TestSessionTimerExpiry(t *testing.T) {
+	for _, refresher := range []bool{true, false} {
+		dialog := newFakeDialog(t)
+		events := &sessionTimerEvents{}
+		timer := NewSessionTimer(dialog.provider, SESSION_TIMER_MIN_SE)
+		timer.SetSessionTimerListener(events)
+		timer.unit = time.Millisecond
+
+		// refresh at 45 ms, BYE at 60 ms as no 2xx restarts the timer
+		request := parseRequest(t, inDialogRequest(message.INVITE, 2, "Supported: timer\r\n", ""))
+		if refresher {
+			request = parseRequest(t, inDialogRequest(message.INVITE, 2, "Session-Expires: 90;refresher=uas\r\n", ""))
+		}
+		timer.PrepareResponse(request, request.CreateResponse(message.OK), dialog)
+		if timer.IsRefresher() != refresher {
+			t.Fatal("bad refresher role", refresher)
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for _, expired := events.count(); expired == 0 && time.Now().Before(deadline); _, expired = events.count() {
+			time.Sleep(5 * time.Millisecond)
+		}
+		refreshes, expired := events.count()
+		sent := dialog.provider.getSent()
+		if refresher {
+			if refreshes != 1 || len(sent) != 2 || sent[0].GetMethod() != message.INVITE ||
+				getSessionExpires(t, reparse(t, sent[0])).GetRefresher() != header.SessionExpires_UAC {
+				t.Error("no session refresh", refreshes, sent)
+			}
+		} else if refreshes != 0 || len(sent) != 1 {
+			t.Error("session refreshed by the other side", refreshes, sent)
+		}
+		if expired != 1 || sent[len(sent)-1].GetMethod() != message.BYE {
+			t.Error("session not ended with a BYE", refresher, expired, sent)
+		}
+	}
+
+	// a stopped session timer neither refreshes nor expires
+	dialog := newFakeDialog(t)
+	timer := NewSessionTimer(dialog.provider, SESSION_TIMER_MIN_SE)
+	timer.unit = time.Millisecond
+	request := parseRequest(t, inDialogRequest(message.INVITE, 2, "Supported: timer\r\n", ""))
+	timer.PrepareResponse(request, request.CreateResponse(message.OK), dialog)
+	timer.Stop()
+	time.Sleep(100 * time.Millisecond)
+	if sent := dialog.provider.getSent(); len(sent) != 0 {
+		t.Error("stopped session timer fired", sent)
+	}
+}
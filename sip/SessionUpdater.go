@@ -0,0 +1,317 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : SessionUpdater.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/**
+ * Modifies the session of an early or confirmed dialog with UPDATE requests
+ * as described in RFC 3311, tracking the state of the SDP offer/answer
+ * exchange in the dialog:
+ * <ul>
+ * <li> An UPDATE carrying an offer received while our own offer is unanswered
+ * is glare and is rejected with 491; our offer is then retried after a random
+ * delay as described in RFC 3261 section 14.1.
+ * <li> An UPDATE carrying an offer received while an offer we received is
+ * unanswered is rejected with 500 and a Retry-After of up to 10 seconds.
+ * </ul>
+ * UPDATE is a target refresh request: the Contact of an UPDATE and of its 2xx
+ * response replaces the remote target, which is used as the Request-URI of
+ * the UPDATEs sent afterwards.
+ * <p>
+ * Offers and answers exchanged in the INVITE, reliable provisional responses
+ * or PRACK are reported with OfferSent, OfferReceived, AnswerSent and
+ * AnswerReceived so that UPDATE offers do not overlap with them.
+ */
+type SessionUpdater struct {
+	mutex sync.Mutex
+
+	provider     SipProvider
+	dialog       Dialog
+	contact      header.ContactHeader
+	remoteTarget address.Address
+
+	localOffer  bool
+	remoteOffer bool
+	// the CSeq of the UPDATE carrying our offer, 0 if none is outstanding
+	offerCSeq  int
+	retryOffer string
+	retryTimer *time.Timer
+}
+
+/** Creates a session updater for dialog that sends its UPDATEs through
+ * provider.
+ */
+func NewSessionUpdater(provider SipProvider, dialog Dialog) *SessionUpdater {
+	return &SessionUpdater{
+		provider:     provider,
+		dialog:       dialog,
+		remoteTarget: dialog.GetRemoteTarget(),
+	}
+}
+
+/** Sets the Contact placed in UPDATE requests and their 2xx responses.
+ */
+func (this *SessionUpdater) SetLocalContact(contact header.ContactHeader) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.contact = contact
+}
+
+/** Returns the remote target of the dialog, as last refreshed.
+ */
+func (this *SessionUpdater) GetRemoteTarget() address.Address {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.remoteTarget
+}
+
+/** Reports an offer sent outside an UPDATE, e.g. in the INVITE.
+ */
+func (this *SessionUpdater) OfferSent() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.localOffer = true
+}
+
+/** Reports the answer to our offer received outside an UPDATE.
+ */
+func (this *SessionUpdater) AnswerReceived() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.localOffer = false
+}
+
+/** Reports an offer received outside an UPDATE, e.g. in the INVITE.
+ */
+func (this *SessionUpdater) OfferReceived() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.remoteOffer = true
+}
+
+/** Reports the answer to a received offer sent outside an UPDATE.
+ */
+func (this *SessionUpdater) AnswerSent() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.remoteOffer = false
+}
+
+/** Returns true while an offer exchange is in progress in either direction.
+ */
+func (this *SessionUpdater) IsOfferPending() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.localOffer || this.remoteOffer
+}
+
+/** Sends an UPDATE in the dialog carrying the SDP offer, or no body if offer
+ * is empty. Only one offer can be outstanding in the dialog at a time.
+ */
+func (this *SessionUpdater) SendUpdate(offer string) (SipException error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.sendUpdate(offer)
+}
+
+func (this *SessionUpdater) sendUpdate(offer string) error {
+	if state := this.dialog.GetState(); state == DIALOGSTATE_TERMINATED || state == DIALOGSTATE_COMPLETED {
+		return errors.New("SipException: the dialog is terminated")
+	}
+	if offer != "" && (this.localOffer || this.remoteOffer) {
+		return errors.New("SipException: an offer is pending in the dialog")
+	}
+	// a new offer replaces the one waiting to be sent again after a 491
+	if offer != "" && this.retryTimer != nil {
+		this.retryTimer.Stop()
+		this.retryTimer = nil
+	}
+
+	update, err := this.dialog.CreateRequest(message.UPDATE)
+	if err != nil {
+		return err
+	}
+	if this.remoteTarget != nil {
+		update.SetRequestURI(this.remoteTarget.GetURI())
+	}
+	if this.contact != nil {
+		update.SetHeader(this.contact)
+	}
+	if offer != "" {
+		update.SetContent(offer, header.NewContentTypeFromString("application", "sdp"))
+	}
+
+	ct, err := this.provider.GetNewClientTransaction(update)
+	if err != nil {
+		return err
+	}
+	if err = this.dialog.SendRequest(ct); err != nil {
+		return err
+	}
+	if offer != "" {
+		this.localOffer = true
+		if cseq, ok := update.GetHeader(core.SIPHeaderNames_CSEQ).(header.CSeqHeader); ok {
+			this.offerCSeq = cseq.GetSequenceNumber()
+		}
+		this.retryOffer = offer
+	}
+	return nil
+}
+
+/** Processes the response to an UPDATE we sent. A 2xx refreshes the remote
+ * target. If the UPDATE carried our offer, a 2xx answers it and a 491 means
+ * glare: the offer is sent again after a random delay, 2.1 to 4 seconds if we
+ * created the dialog and 0 to 2 seconds if not. Any other final response
+ * rejects the offer. The responses to an UPDATE without offer leave the
+ * offer exchange in progress unchanged.
+ */
+func (this *SessionUpdater) ProcessResponse(response message.Response) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	statusCode := response.GetStatusCode()
+	if statusCode < message.OK {
+		return
+	}
+	if statusCode < message.MULTIPLE_CHOICES {
+		this.refreshTarget(response)
+	}
+	cseq, ok := response.GetHeader(core.SIPHeaderNames_CSEQ).(header.CSeqHeader)
+	if !ok || this.offerCSeq == 0 || cseq.GetSequenceNumber() != this.offerCSeq {
+		return
+	}
+	this.localOffer = false
+	this.offerCSeq = 0
+	if statusCode < message.MULTIPLE_CHOICES {
+		this.retryOffer = ""
+		return
+	}
+	if statusCode != message.REQUEST_PENDING || this.retryOffer == "" {
+		this.retryOffer = ""
+		return
+	}
+
+	offer := this.retryOffer
+	this.retryTimer = time.AfterFunc(glareRetryDelay(!this.dialog.IsServer()), func() {
+		this.mutex.Lock()
+		defer this.mutex.Unlock()
+		if this.retryTimer != nil && this.retryOffer == offer {
+			this.retryTimer = nil
+			this.sendUpdate(offer)
+		}
+	})
+}
+
+/** Processes an UPDATE received in the dialog. If the UPDATE cannot be
+ * accepted the response to send is returned: 491 on glare and 500 with
+ * Retry-After if an offer we received is still unanswered. Otherwise the
+ * remote target is refreshed, nil is returned and the application answers
+ * the UPDATE with AcceptUpdate or RejectUpdate.
+ */
+func (this *SessionUpdater) ProcessUpdate(update *message.SIPRequest) *message.SIPResponse {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if state := this.dialog.GetState(); state == DIALOGSTATE_TERMINATED || state == DIALOGSTATE_COMPLETED {
+		return update.CreateResponse(message.CALL_OR_TRANSACTION_DOES_NOT_EXIST)
+	}
+	if hasOffer(update) {
+		if this.localOffer {
+			return update.CreateResponse(message.REQUEST_PENDING)
+		}
+		if this.remoteOffer {
+			response := update.CreateResponse(message.SERVER_INTERNAL_ERROR)
+			retryAfter := header.NewRetryAfter()
+			retryAfter.SetRetryAfter(rand.Intn(10) + 1)
+			response.SetHeader(retryAfter)
+			return response
+		}
+		this.remoteOffer = true
+	}
+	this.refreshTarget(update)
+	return nil
+}
+
+/** Creates the 2xx response to an UPDATE accepted by ProcessUpdate, carrying
+ * the SDP answer if the UPDATE carried an offer.
+ */
+func (this *SessionUpdater) AcceptUpdate(update *message.SIPRequest, answer string) *message.SIPResponse {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	response := update.CreateResponse(message.OK)
+	if this.contact != nil {
+		response.SetHeader(this.contact)
+	}
+	if hasOffer(update) {
+		this.remoteOffer = false
+		if answer != "" {
+			response.SetContent(answer, header.NewContentTypeFromString("application", "sdp"))
+		}
+	}
+	return response
+}
+
+/** Creates a final error response, e.g. 488, to an UPDATE accepted by
+ * ProcessUpdate. The session is left unchanged.
+ */
+func (this *SessionUpdater) RejectUpdate(update *message.SIPRequest, statusCode int) *message.SIPResponse {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if hasOffer(update) {
+		this.remoteOffer = false
+	}
+	return update.CreateResponse(statusCode)
+}
+
+/** Stops a pending retry of our offer.
+ */
+func (this *SessionUpdater) Stop() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.retryTimer != nil {
+		this.retryTimer.Stop()
+		this.retryTimer = nil
+	}
+}
+
+func (this *SessionUpdater) refreshTarget(msg message.Message) {
+	if contact, ok := msg.GetHeader(core.SIPHeaderNames_CONTACT).(header.ContactHeader); ok {
+		if addr := contact.GetAddress(); addr != nil {
+			this.remoteTarget = addr
+		}
+	}
+}
+
+/** Returns the random delay after which an offer rejected with 491 is sent
+ * again (RFC 3261 section 14.1): 2.1 to 4 seconds for the owner of the
+ * Call-ID, the side that created the dialog, and 0 to 2 seconds otherwise.
+ */
+func glareRetryDelay(owner bool) time.Duration {
+	if owner {
+		return 2100*time.Millisecond + time.Duration(rand.Intn(191))*10*time.Millisecond
+	}
+	return time.Duration(rand.Intn(201)) * 10 * time.Millisecond
+}
+
+/** Returns true if msg carries a session description.
+ */
+func hasOffer(msg message.Message) bool {
+	return msg.GetContent() != ""
+}
@@ -0,0 +1,168 @@
+package sip
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"testing"
+	"time"
+)
+
+const sdpOffer = "v=0\r\no=bob 1 1 IN IP4 192.0.2.2\r\ns=-\r\nc=IN IP4 192.0.2.2\r\nt=0 0\r\nm=audio 4000 RTP/AVP 0\r\n"
+
+func TestGlareRetryDelay(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if delay := glareRetryDelay(true); delay < 2100*time.Millisecond || delay > 4*time.Second {
+			t.Fatal("bad delay for the owner of the Call-ID", delay)
+		}
+		if delay := glareRetryDelay(false); delay < 0 || delay > 2*time.Second {
+			t.Fatal("bad delay", delay)
+		}
+	}
+}
+
+func TestSessionUpdaterGlare(t *testing.T) {
+	dialog := newFakeDialog(t)
+	dialog.server = true
+	updater := NewSessionUpdater(dialog.provider, dialog)
+	defer updater.Stop()
+
+	if err := updater.SendUpdate(sdpOffer); err != nil {
+		t.Fatal(err)
+	}
+	update := dialog.provider.lastSent(t)
+	if update.GetMethod() != message.UPDATE || update.GetContent() == "" || !updater.IsOfferPending() {
+		t.Fatal("bad UPDATE", update)
+	}
+	if err := updater.SendUpdate(sdpOffer); err == nil {
+		t.Error("second offer sent while the first one is pending")
+	}
+
+	// both sides sent an offer: 491 to the UPDATE received and to ours
+	received := parseRequest(t, inDialogRequest(message.UPDATE, 2, "Content-Type: application/sdp\r\n", sdpOffer))
+	if response := updater.ProcessUpdate(received); response == nil || response.GetStatusCode() != message.REQUEST_PENDING {
+		t.Fatal("glare not detected")
+	}
+	start := time.Now()
+	updater.ProcessResponse(update.CreateResponse(message.REQUEST_PENDING))
+	if updater.IsOfferPending() {
+		t.Error("offer still pending after the 491")
+	}
+
+	// we did not create the dialog: the offer is sent again within 2 seconds
+	deadline := time.Now().Add(3 * time.Second)
+	for len(dialog.provider.getSent()) == 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed > 2100*time.Millisecond {
+		t.Error("offer sent again after", elapsed)
+	}
+	retry := dialog.provider.lastSent(t)
+	if len(dialog.provider.getSent()) != 2 || retry.GetMethod() != message.UPDATE || retry.GetContent() != update.GetContent() {
+		t.Fatal("offer not sent again", dialog.provider.getSent())
+	}
+	if !updater.IsOfferPending() {
+		t.Error("retried offer not pending")
+	}
+	updater.ProcessResponse(retry.CreateResponse(message.OK))
+	if updater.IsOfferPending() {
+		t.Error("offer pending after the 2xx")
+	}
+}
+
+func TestSessionUpdaterPendingOffer(t *testing.T) {
+	dialog := newFakeDialog(t)
+	updater := NewSessionUpdater(dialog.provider, dialog)
+	updater.OfferReceived()
+
+	// an offer received in the INVITE is not answered yet
+	update := parseRequest(t, inDialogRequest(message.UPDATE, 2, "Content-Type: application/sdp\r\n", sdpOffer))
+	response := updater.ProcessUpdate(update)
+	if response == nil || response.GetStatusCode() != message.SERVER_INTERNAL_ERROR {
+		t.Fatal("overlapping offer accepted")
+	}
+	response = reparse(t, response).(*message.SIPResponse)
+	retryAfter, ok := response.GetHeader(core.SIPHeaderNames_RETRY_AFTER).(header.RetryAfterHeader)
+	if !ok || retryAfter.GetRetryAfter() < 0 || retryAfter.GetRetryAfter() > 10 {
+		t.Error("500 without a Retry-After of up to 10 seconds", response)
+	}
+
+	updater.AnswerSent()
+	if response := updater.ProcessUpdate(update); response != nil {
+		t.Fatal("UPDATE rejected with", response.GetStatusCode())
+	}
+	if response := updater.AcceptUpdate(update, sdpOffer); response.GetContent() == "" || updater.IsOfferPending() {
+		t.Error("UPDATE not answered", response)
+	}
+
+	dialog.state = DIALOGSTATE_TERMINATED
+	if response := updater.ProcessUpdate(update); response == nil ||
+		response.GetStatusCode() != message.CALL_OR_TRANSACTION_DOES_NOT_EXIST {
+		t.Error("UPDATE accepted in a terminated dialog")
+	}
+}
+
+func TestSessionUpdaterTargetRefresh(t *testing.T) {
+	dialog := newFakeDialog(t)
+	updater := NewSessionUpdater(dialog.provider, dialog)
+	if updater.GetRemoteTarget().GetURI().String() != "sip:bob@192.0.2.2" {
+		t.Fatal("bad initial remote target", updater.GetRemoteTarget())
+	}
+
+	// the Contact of a received UPDATE is the new remote target
+	update := parseRequest(t, inDialogRequest(message.UPDATE, 2, "Contact: <sip:bob@198.51.100.2:5070>\r\n", ""))
+	if response := updater.ProcessUpdate(update); response != nil {
+		t.Fatal("UPDATE rejected with", response.GetStatusCode())
+	}
+	if err := updater.SendUpdate(""); err != nil {
+		t.Fatal(err)
+	}
+	sent := dialog.provider.lastSent(t)
+	if sent.GetRequestURI().String() != "sip:bob@198.51.100.2:5070" {
+		t.Error("UPDATE not sent to the refreshed target", sent.GetRequestURI())
+	}
+
+	// and so is the Contact of the 2xx to our UPDATE
+	response := sent.CreateResponse(message.OK)
+	response.SetHeader(parseRequest(t, inDialogRequest(message.UPDATE, 3, "Contact: <sip:bob@203.0.113.2>\r\n", "")).
+		GetHeader(core.SIPHeaderNames_CONTACT))
+	updater.ProcessResponse(reparse(t, response).(*message.SIPResponse))
+	if updater.GetRemoteTarget().GetURI().String() != "sip:bob@203.0.113.2" {
+		t.Error("remote target not refreshed by the 2xx", updater.GetRemoteTarget())
+	}
+}
+
+func TestSessionUpdaterUpdateWithoutOffer(t *testing.T) {
+	dialog := newFakeDialog(t)
+	updater := NewSessionUpdater(dialog.provider, dialog)
+	defer updater.Stop()
+
+	// an UPDATE without offer sent while the offer of the INVITE is pending
+	// leaves it pending
+	updater.OfferSent()
+	if err := updater.SendUpdate(""); err != nil {
+		t.Fatal(err)
+	}
+	update := dialog.provider.lastSent(t)
+	updater.ProcessResponse(update.CreateResponse(message.OK))
+	if !updater.IsOfferPending() {
+		t.Error("offer of the INVITE answered by the 2xx to an UPDATE without offer")
+	}
+	updater.AnswerReceived()
+
+	// nor does it drop an offer waiting to be sent again after a 491
+	updater.SendUpdate(sdpOffer)
+	offer := dialog.provider.lastSent(t)
+	updater.ProcessResponse(offer.CreateResponse(message.REQUEST_PENDING))
+	updater.SendUpdate("")
+	refresh := dialog.provider.lastSent(t)
+	updater.ProcessResponse(refresh.CreateResponse(message.OK))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(dialog.provider.getSent()) == 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if retry := dialog.provider.lastSent(t); len(dialog.provider.getSent()) != 4 || retry.GetContent() != offer.GetContent() ||
+		!updater.IsOfferPending() {
+		t.Error("offer not sent again after an UPDATE without offer", dialog.provider.getSent())
+	}
+}
@@ -0,0 +1,526 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Subscriber.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * The duration in seconds of a subscription whose SUBSCRIBE has no Expires
+ * header, unless the event package defines another one.
+ */
+const SUBSCRIPTION_DEFAULT_EXPIRES = 3600
+
+/**
+ * This interface is implemented by event packages to receive the state
+ * carried in the NOTIFY requests of their subscriptions.
+ */
+type EventBodyConsumer interface {
+	/**
+	 * Called with the body of each NOTIFY received for subscription.
+	 */
+	ConsumeBody(subscription *ClientSubscription, contentType header.ContentTypeHeader, body string)
+}
+
+/**
+ * This interface is implemented by applications that want to learn about
+ * terminated subscriptions.
+ */
+type SubscriberListener interface {
+	/**
+	 * Called once subscription is terminated, by a NOTIFY or by an error
+	 * response to its SUBSCRIBE.
+	 */
+	ProcessSubscriptionTerminated(subscription *ClientSubscription)
+}
+
+/**
+ * Manages the subscriptions of a subscriber as described in RFC 6665. Event
+ * packages are plugged in with RegisterConsumer; NOTIFY requests for other
+ * packages are rejected with 489. A dialog can carry several subscriptions,
+ * told apart by the event package and the "id" parameter of the Event header.
+ * Subscriptions are refreshed before they expire until they are terminated by
+ * the notifier or with Unsubscribe.
+ */
+type Subscriber struct {
+	mutex sync.Mutex
+
+	provider      SipProvider
+	listener      SubscriberListener
+	consumers     map[string]EventBodyConsumer
+	subscriptions map[string]*ClientSubscription
+	transactions  map[string]*ClientSubscription
+	unit          time.Duration // of the durations, shorter than a second in tests
+}
+
+/** Creates a subscriber that sends its SUBSCRIBE requests through provider.
+ */
+func NewSubscriber(provider SipProvider) *Subscriber {
+	return &Subscriber{
+		provider:      provider,
+		consumers:     make(map[string]EventBodyConsumer),
+		subscriptions: make(map[string]*ClientSubscription),
+		transactions:  make(map[string]*ClientSubscription),
+		unit:          time.Second,
+	}
+}
+
+/** Sets the listener informed about terminated subscriptions.
+ */
+func (this *Subscriber) SetSubscriberListener(listener SubscriberListener) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.listener = listener
+}
+
+/** Registers the consumer of the NOTIFY bodies of an event package.
+ */
+func (this *Subscriber) RegisterConsumer(eventType string, consumer EventBodyConsumer) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.consumers[strings.ToLower(eventType)] = consumer
+}
+
+/** Sends an initial SUBSCRIBE created by the application, which must carry
+ * an Event header. An Expires header of SUBSCRIPTION_DEFAULT_EXPIRES seconds
+ * is added if there is none.
+ */
+func (this *Subscriber) Subscribe(subscribe message.Request) (subscription *ClientSubscription, SipException error) {
+	event, ok := subscribe.GetHeader(core.SIPHeaderNames_EVENT).(header.EventHeader)
+	if !ok {
+		return nil, errors.New("SipException: SUBSCRIBE without Event")
+	}
+	callId, ok := subscribe.GetHeader(core.SIPHeaderNames_CALL_ID).(header.CallIdHeader)
+	if !ok {
+		return nil, errors.New("SipException: SUBSCRIBE without Call-ID")
+	}
+	expires, ok := subscribe.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader)
+	if !ok {
+		expires = header.NewExpires()
+		expires.SetExpires(SUBSCRIPTION_DEFAULT_EXPIRES)
+		subscribe.SetHeader(expires)
+	}
+
+	subscription = &ClientSubscription{
+		subscriber: this,
+		callId:     callId.GetCallId(),
+		eventType:  event.GetEventType(),
+		eventId:    event.GetEventId(),
+		requested:  expires.GetExpires(),
+		retryAfter: -1,
+	}
+	ct, err := this.provider.GetNewClientTransaction(subscribe)
+	if err != nil {
+		return nil, err
+	}
+	subscription.dialog = ct.GetDialog()
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if _, ok := this.subscriptions[subscription.key()]; ok {
+		return nil, errors.New("SipException: the subscription already exists")
+	}
+	if err = ct.SendRequest(); err != nil {
+		return nil, err
+	}
+	this.subscriptions[subscription.key()] = subscription
+	this.transactions[transactionKey(subscribe)] = subscription
+	return subscription, nil
+}
+
+/** Creates an additional subscription in an existing dialog, told apart from
+ * the others of the dialog by eventId.
+ */
+func (this *Subscriber) SubscribeInDialog(dialog Dialog, eventType, eventId string, expires int) (subscription *ClientSubscription, SipException error) {
+	subscription = &ClientSubscription{
+		subscriber: this,
+		dialog:     dialog,
+		callId:     dialog.GetCallId().GetCallId(),
+		eventType:  eventType,
+		eventId:    eventId,
+		requested:  expires,
+		retryAfter: -1,
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if _, ok := this.subscriptions[subscription.key()]; ok {
+		return nil, errors.New("SipException: the subscription already exists")
+	}
+	if err := subscription.sendSubscribe(expires); err != nil {
+		return nil, err
+	}
+	this.subscriptions[subscription.key()] = subscription
+	return subscription, nil
+}
+
+/** Processes a response to a SUBSCRIBE received in dialog. A 2xx starts the
+ * refresh timer with the duration granted by the notifier. A 423 raises the
+ * duration to the Min-Expires of the response; a refresh is then sent again
+ * at once, while an initial SUBSCRIBE terminates the subscription and is to
+ * be sent again by the application with GetExpires seconds. Other error
+ * responses terminate a subscription that has not been notified yet, and any
+ * subscription when the response is 481.
+ */
+func (this *Subscriber) ProcessResponse(response message.Response, dialog Dialog) {
+	statusCode := response.GetStatusCode()
+	if statusCode < message.OK {
+		return
+	}
+
+	this.mutex.Lock()
+	key := transactionKey(response)
+	subscription := this.transactions[key]
+	delete(this.transactions, key)
+	if subscription == nil || subscription.state == header.SubscriptionState_TERMINATED {
+		this.mutex.Unlock()
+		return
+	}
+	if subscription.dialog == nil {
+		subscription.dialog = dialog
+	}
+
+	terminated := false
+	switch {
+	case statusCode < message.MULTIPLE_CHOICES:
+		if expires, ok := response.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader); ok {
+			subscription.expires = expires.GetExpires()
+		} else {
+			subscription.expires = subscription.requested
+		}
+		if subscription.expires > 0 {
+			subscription.startRefresh(subscription.expires)
+		}
+	case statusCode == message.INTERVAL_TOO_BRIEF:
+		if minExpires, ok := response.GetHeader(core.SIPHeaderNames_MIN_EXPIRES).(header.MinExpiresHeader); ok {
+			subscription.requested = minExpires.GetExpires()
+		}
+		if subscription.state == "" || subscription.sendSubscribe(subscription.requested) != nil {
+			terminated = subscription.terminate("", -1)
+		}
+	case subscription.state == "" || statusCode == message.CALL_OR_TRANSACTION_DOES_NOT_EXIST:
+		terminated = subscription.terminate("", -1)
+	}
+	listener := this.listener
+	this.mutex.Unlock()
+
+	if terminated && listener != nil {
+		listener.ProcessSubscriptionTerminated(subscription)
+	}
+}
+
+/** Processes a NOTIFY received in dialog and returns the response to send:
+ * 200 if the NOTIFY was accepted, 489 if its event package is not registered,
+ * 481 if it matches no subscription and 400 if it has no Subscription-State.
+ * The body of an accepted NOTIFY is passed to the consumer of the package.
+ */
+func (this *Subscriber) ProcessNotify(notify *message.SIPRequest, dialog Dialog) *message.SIPResponse {
+	event, ok := notify.GetHeader(core.SIPHeaderNames_EVENT).(header.EventHeader)
+	if !ok {
+		return notify.CreateResponse(message.BAD_EVENT)
+	}
+	subscriptionState, ok := notify.GetHeader(core.SIPHeaderNames_SUBSCRIPTION_STATE).(header.SubscriptionStateHeader)
+	if !ok {
+		return notify.CreateResponse(message.BAD_REQUEST)
+	}
+
+	this.mutex.Lock()
+	consumer, ok := this.consumers[strings.ToLower(event.GetEventType())]
+	if !ok {
+		this.mutex.Unlock()
+		return notify.CreateResponse(message.BAD_EVENT)
+	}
+	key := subscriptionKey(notify.GetCallId().GetCallId(), event.GetEventType(), event.GetEventId())
+	subscription := this.subscriptions[key]
+	if subscription == nil {
+		this.mutex.Unlock()
+		return notify.CreateResponse(message.CALL_OR_TRANSACTION_DOES_NOT_EXIST)
+	}
+	if subscription.dialog == nil {
+		subscription.dialog = dialog
+	}
+
+	terminated := false
+	state := strings.ToLower(subscriptionState.GetState())
+	if state == header.SubscriptionState_TERMINATED {
+		terminated = subscription.terminate(strings.ToLower(subscriptionState.GetReasonCode()), subscriptionState.GetRetryAfter())
+	} else {
+		subscription.state = state
+		// the notifier may shorten the subscription
+		if expires := subscriptionState.GetExpires(); expires > 0 && (subscription.expires <= 0 || expires < subscription.expires) {
+			subscription.expires = expires
+			subscription.startRefresh(expires)
+		}
+	}
+	listener := this.listener
+	this.mutex.Unlock()
+
+	if body := notify.GetContent(); body != "" && consumer != nil {
+		contentType, _ := notify.GetHeader(core.SIPHeaderNames_CONTENT_TYPE).(header.ContentTypeHeader)
+		consumer.ConsumeBody(subscription, contentType, body)
+	}
+	if terminated && listener != nil {
+		listener.ProcessSubscriptionTerminated(subscription)
+	}
+	return notify.CreateResponse(message.OK)
+}
+
+/** Subscriptions are matched on the Call-ID, the event package and the event
+ * id.
+ */
+func subscriptionKey(callId, eventType, eventId string) string {
+	return callId + "|" + strings.ToLower(eventType) + "|" + eventId
+}
+
+/** Responses are matched to the SUBSCRIBE they answer on the Call-ID and the
+ * CSeq number.
+ */
+func transactionKey(msg message.Message) string {
+	var key string
+	if callId, ok := msg.GetHeader(core.SIPHeaderNames_CALL_ID).(header.CallIdHeader); ok {
+		key = callId.GetCallId()
+	}
+	if cseq, ok := msg.GetHeader(core.SIPHeaderNames_CSEQ).(header.CSeqHeader); ok {
+		key += "|" + strconv.Itoa(cseq.GetSequenceNumber())
+	}
+	return key
+}
+
+/**
+ * A subscription of a Subscriber. Its state is empty until the first NOTIFY
+ * arrives, then "pending" or "active" and finally "terminated".
+ */
+type ClientSubscription struct {
+	subscriber *Subscriber
+
+	dialog     Dialog
+	callId     string
+	eventType  string
+	eventId    string
+	requested  int
+	expires    int
+	state      string
+	reasonCode string
+	retryAfter int
+	generation int
+	timer      *time.Timer
+
+	applicationData interface{}
+}
+
+/** Returns the event package of the subscription.
+ */
+func (this *ClientSubscription) GetEventType() string {
+	return this.eventType
+}
+
+/** Returns the event id of the subscription, or an empty string.
+ */
+func (this *ClientSubscription) GetEventId() string {
+	return this.eventId
+}
+
+/** Returns the dialog of the subscription, or nil until it is established.
+ */
+func (this *ClientSubscription) GetDialog() Dialog {
+	this.subscriber.mutex.Lock()
+	defer this.subscriber.mutex.Unlock()
+	return this.dialog
+}
+
+/** Returns the state of the subscription.
+ */
+func (this *ClientSubscription) GetState() string {
+	this.subscriber.mutex.Lock()
+	defer this.subscriber.mutex.Unlock()
+	return this.state
+}
+
+/** Returns the duration of the subscription in seconds: the one granted by
+ * the notifier once known, the one asked for otherwise.
+ */
+func (this *ClientSubscription) GetExpires() int {
+	this.subscriber.mutex.Lock()
+	defer this.subscriber.mutex.Unlock()
+	if this.expires > 0 {
+		return this.expires
+	}
+	return this.requested
+}
+
+/** Returns the reason given by the notifier for terminating the
+ * subscription, or an empty string.
+ */
+func (this *ClientSubscription) GetReasonCode() string {
+	this.subscriber.mutex.Lock()
+	defer this.subscriber.mutex.Unlock()
+	return this.reasonCode
+}
+
+/** Returns true once the subscription is terminated.
+ */
+func (this *ClientSubscription) IsTerminated() bool {
+	return this.GetState() == header.SubscriptionState_TERMINATED
+}
+
+/** Tells whether a new subscription may be created after this one was
+ * terminated, and after how many seconds, following the reason given by the
+ * notifier as described in RFC 6665 section 4.1.3: at once after
+ * "deactivated" and "timeout", after the retry-after interval (or at the
+ * application's discretion) after "probation" and "giveup", and never after
+ * "rejected", "noresource" and "invariant".
+ */
+func (this *ClientSubscription) ShouldResubscribe() (resubscribe bool, delay int) {
+	this.subscriber.mutex.Lock()
+	defer this.subscriber.mutex.Unlock()
+	if this.state != header.SubscriptionState_TERMINATED {
+		return false, 0
+	}
+	switch this.reasonCode {
+	case header.SubscriptionState_DEACTIVATED, header.SubscriptionState_TIMEOUT:
+		return true, 0
+	case header.SubscriptionState_PROBATION, header.SubscriptionState_GIVE_UP:
+		if this.retryAfter > 0 {
+			return true, this.retryAfter
+		}
+		return true, 0
+	case header.SubscriptionState_REJECTED, header.SubscriptionState_NO_RESOURCE, header.SubscriptionState_INVARIANT:
+		return false, 0
+	}
+	// unknown reasons are treated as no reason
+	if this.retryAfter > 0 {
+		return true, this.retryAfter
+	}
+	return false, 0
+}
+
+/** Sets application data, e.g. the state kept by the event package.
+ */
+func (this *ClientSubscription) SetApplicationData(applicationData interface{}) {
+	this.subscriber.mutex.Lock()
+	defer this.subscriber.mutex.Unlock()
+	this.applicationData = applicationData
+}
+
+/** Returns the application data of the subscription.
+ */
+func (this *ClientSubscription) GetApplicationData() interface{} {
+	this.subscriber.mutex.Lock()
+	defer this.subscriber.mutex.Unlock()
+	return this.applicationData
+}
+
+/** Refreshes the subscription at once.
+ */
+func (this *ClientSubscription) Refresh() (SipException error) {
+	this.subscriber.mutex.Lock()
+	defer this.subscriber.mutex.Unlock()
+	if this.state == header.SubscriptionState_TERMINATED {
+		return errors.New("SipException: the subscription is terminated")
+	}
+	return this.sendSubscribe(this.requested)
+}
+
+/** Ends the subscription with a SUBSCRIBE with "Expires: 0". The
+ * subscription is terminated once the final NOTIFY arrives.
+ */
+func (this *ClientSubscription) Unsubscribe() (SipException error) {
+	this.subscriber.mutex.Lock()
+	defer this.subscriber.mutex.Unlock()
+	if this.state == header.SubscriptionState_TERMINATED {
+		return nil
+	}
+	this.stopRefresh()
+	return this.sendSubscribe(0)
+}
+
+func (this *ClientSubscription) key() string {
+	return subscriptionKey(this.callId, this.eventType, this.eventId)
+}
+
+func (this *ClientSubscription) sendSubscribe(expires int) error {
+	if this.dialog == nil {
+		return errors.New("SipException: the subscription has no dialog yet")
+	}
+	subscribe, err := this.dialog.CreateRequest(message.SUBSCRIBE)
+	if err != nil {
+		return err
+	}
+	event := header.NewEvent()
+	event.SetEventType(this.eventType)
+	if this.eventId != "" {
+		event.SetEventId(this.eventId)
+	}
+	subscribe.SetHeader(event)
+	expiresHeader := header.NewExpires()
+	expiresHeader.SetExpires(expires)
+	subscribe.SetHeader(expiresHeader)
+
+	ct, err := this.subscriber.provider.GetNewClientTransaction(subscribe)
+	if err != nil {
+		return err
+	}
+	if err = this.dialog.SendRequest(ct); err != nil {
+		return err
+	}
+	this.subscriber.transactions[transactionKey(subscribe)] = this
+	return nil
+}
+
+/** Schedules the refresh no later than 32 seconds before the subscription
+ * expires, and at half its duration for short subscriptions.
+ */
+func (this *ClientSubscription) startRefresh(expires int) {
+	this.stopRefresh()
+	unit := this.subscriber.unit
+	interval := time.Duration(expires) * unit
+	guard := interval / 2
+	if guard > 32*unit {
+		guard = 32 * unit
+	}
+	generation := this.generation
+	this.timer = time.AfterFunc(interval-guard, func() {
+		this.subscriber.mutex.Lock()
+		defer this.subscriber.mutex.Unlock()
+		if generation == this.generation && this.state != header.SubscriptionState_TERMINATED {
+			this.timer = nil
+			this.sendSubscribe(this.requested)
+		}
+	})
+}
+
+func (this *ClientSubscription) stopRefresh() {
+	this.generation++
+	if this.timer != nil {
+		this.timer.Stop()
+		this.timer = nil
+	}
+}
+
+/** Terminates the subscription and returns true unless it was already
+ * terminated.
+ */
+func (this *ClientSubscription) terminate(reasonCode string, retryAfter int) bool {
+	if this.state == header.SubscriptionState_TERMINATED {
+		return false
+	}
+	this.stopRefresh()
+	this.state = header.SubscriptionState_TERMINATED
+	this.reasonCode = reasonCode
+	this.retryAfter = retryAfter
+	delete(this.subscriber.subscriptions, this.key())
+	return true
+}
@@ -0,0 +1,368 @@
+package sip
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"sync"
+	"testing"
+	"time"
+)
+
+type presenceProducer struct{}
+
+func (this presenceProducer) ProduceBody(subscription *ServerSubscription) (string, header.ContentTypeHeader) {
+	return "<presence/>", header.NewContentTypeFromString("application", "pidf+xml")
+}
+
+type presenceConsumer struct {
+	mutex      sync.Mutex
+	bodies     []string
+	terminated []*ClientSubscription
+}
+
+func (this *presenceConsumer) ConsumeBody(subscription *ClientSubscription, contentType header.ContentTypeHeader, body string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.bodies = append(this.bodies, body)
+}
+
+func (this *presenceConsumer) ProcessSubscriptionTerminated(subscription *ClientSubscription) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.terminated = append(this.terminated, subscription)
+}
+
+func (this *presenceConsumer) count() (bodies, terminated int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return len(this.bodies), len(this.terminated)
+}
+
+// subscribeRequest returns an initial SUBSCRIBE of bob in the Call-ID of
+// fakeDialog, so that the NOTIFYs sent in a fakeDialog match it.
+func subscribeRequest(t *testing.T, headers string) *message.SIPRequest {
+	return parseRequest(t, "SUBSCRIBE sip:alice@a.example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP 192.0.2.2;branch=z9hG4bKsubscribe\r\n"+
+		"From: <sip:bob@b.example.com>;tag=bob-tag\r\n"+
+		"To: <sip:alice@a.example.com>\r\n"+
+		"Call-ID: dialog@a.example.com\r\n"+
+		"CSeq: 1 SUBSCRIBE\r\n"+
+		"Max-Forwards: 70\r\n"+
+		"Contact: <sip:bob@192.0.2.2>\r\n"+headers+
+		"Content-Length: 0\r\n\r\n")
+}
+
+func getExpires(t *testing.T, msg message.Message) int {
+	expires, ok := msg.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader)
+	if !ok {
+		t.Fatal("no Expires in", msg)
+	}
+	return expires.GetExpires()
+}
+
+type subscriptionTest struct {
+	t                *testing.T
+	notifierDialog   *fakeDialog
+	subscriberDialog *fakeDialog
+	notifier         *Notifier
+	subscriber       *Subscriber
+	consumer         *presenceConsumer
+}
+
+func newSubscriptionTest(t *testing.T) *subscriptionTest {
+	this := &subscriptionTest{
+		t:                t,
+		notifierDialog:   newFakeDialog(t),
+		subscriberDialog: newFakeDialog(t),
+		consumer:         &presenceConsumer{},
+	}
+	this.notifier = NewNotifier(this.notifierDialog.provider)
+	this.notifier.RegisterProducer("presence", presenceProducer{})
+	this.subscriber = NewSubscriber(this.subscriberDialog.provider)
+	this.subscriber.RegisterConsumer("presence", this.consumer)
+	this.subscriber.SetSubscriberListener(this.consumer)
+	return this
+}
+
+// subscribe passes the last SUBSCRIBE sent to the notifier.
+func (this *subscriptionTest) subscribe() (*fakeServerTransaction, *ServerSubscription, *message.SIPResponse) {
+	transaction := newFakeServerTransaction(this.subscriberDialog.provider.lastSent(this.t), this.notifierDialog)
+	subscription, response := this.notifier.ProcessSubscribe(transaction)
+	return transaction, subscription, response
+}
+
+// respond passes the last response of transaction to the subscriber.
+func (this *subscriptionTest) respond(transaction *fakeServerTransaction) *message.SIPResponse {
+	responses := transaction.getResponses()
+	if len(responses) == 0 {
+		this.t.Fatal("SUBSCRIBE not answered")
+	}
+	response := reparse(this.t, responses[len(responses)-1]).(*message.SIPResponse)
+	this.subscriber.ProcessResponse(response, this.subscriberDialog)
+	return response
+}
+
+// notify passes the last NOTIFY sent to the subscriber.
+func (this *subscriptionTest) notify() (*message.SIPRequest, int) {
+	notify := this.notifierDialog.provider.lastSent(this.t)
+	return notify, this.subscriber.ProcessNotify(notify, this.subscriberDialog).GetStatusCode()
+}
+
+func TestSubscription(t *testing.T) {
+	test := newSubscriptionTest(t)
+	test.notifier.SetMinExpires(60)
+	test.notifier.SetMaxExpires(600)
+
+	// a too short subscription: 423, then sent again by the application
+	subscription, err := test.subscriber.Subscribe(subscribeRequest(t, "Event: presence\r\nExpires: 30\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, response := test.subscribe()
+	if response == nil || response.GetStatusCode() != message.INTERVAL_TOO_BRIEF {
+		t.Fatal("short subscription accepted")
+	}
+	response = reparse(t, response).(*message.SIPResponse)
+	if minExpires, ok := response.GetHeader(core.SIPHeaderNames_MIN_EXPIRES).(header.MinExpiresHeader); !ok || minExpires.GetExpires() != 60 {
+		t.Fatal("423 without Min-Expires: 60", response)
+	}
+	test.subscriber.ProcessResponse(response, test.subscriberDialog)
+	if !subscription.IsTerminated() || subscription.GetExpires() != 60 {
+		t.Fatal("423 to the initial SUBSCRIBE not applied", subscription.GetState(), subscription.GetExpires())
+	}
+	if _, terminated := test.consumer.count(); terminated != 1 {
+		t.Error("listener not informed", terminated)
+	}
+
+	subscription, err = test.subscriber.Subscribe(subscribeRequest(t, "Event: presence\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction, serverSubscription, response := test.subscribe()
+	if response != nil {
+		t.Fatal("SUBSCRIBE rejected with", response.GetStatusCode())
+	}
+	if serverSubscription.GetState() != "" || serverSubscription.GetEventType() != "presence" {
+		t.Fatal("bad new subscription", serverSubscription.GetState())
+	}
+	if err := serverSubscription.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	if response := test.respond(transaction); response.GetStatusCode() != message.OK || getExpires(t, response) != 600 {
+		t.Fatal("subscription not shortened to 600 seconds", response)
+	}
+	notify, statusCode := test.notify()
+	if statusCode != message.OK {
+		t.Fatal("NOTIFY rejected with", statusCode)
+	}
+	if subscriptionState := getSubscriptionState(t, notify); subscriptionState.GetState() != header.SubscriptionState_ACTIVE ||
+		subscriptionState.GetExpires() < 599 || subscriptionState.GetExpires() > 600 {
+		t.Error("bad Subscription-State", subscriptionState)
+	}
+	if subscription.GetState() != header.SubscriptionState_ACTIVE || subscription.GetExpires() != 600 {
+		t.Error("bad subscription", subscription.GetState(), subscription.GetExpires())
+	}
+	if bodies, _ := test.consumer.count(); bodies != 1 {
+		t.Error("NOTIFY body not consumed")
+	}
+
+	// a refresh is accepted in the same subscription
+	if err := subscription.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	transaction, refreshed, response := test.subscribe()
+	if response != nil || refreshed != serverSubscription {
+		t.Fatal("refresh not matched to the subscription", response)
+	}
+	if err := refreshed.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	test.respond(transaction)
+	if _, statusCode := test.notify(); statusCode != message.OK || serverSubscription.GetState() != header.SubscriptionState_ACTIVE {
+		t.Fatal("refresh not notified", statusCode)
+	}
+
+	// a 423 to a refresh is retried at once with Min-Expires
+	test.notifier.SetMinExpires(7200)
+	test.notifier.SetMaxExpires(0)
+	subscription.Refresh()
+	_, _, response = test.subscribe()
+	if response == nil || response.GetStatusCode() != message.INTERVAL_TOO_BRIEF {
+		t.Fatal("short refresh accepted")
+	}
+	sent := len(test.subscriberDialog.provider.getSent())
+	test.subscriber.ProcessResponse(reparse(t, response).(*message.SIPResponse), test.subscriberDialog)
+	if len(test.subscriberDialog.provider.getSent()) != sent+1 || subscription.IsTerminated() {
+		t.Fatal("refresh not retried")
+	}
+	transaction, _, response = test.subscribe()
+	if response != nil || getExpires(t, transaction.GetRequest()) != 7200 {
+		t.Fatal("refresh not retried with Min-Expires", response, transaction.GetRequest())
+	}
+
+	// unsubscribe: 200 and a final NOTIFY
+	serverSubscription.Reject(message.SERVER_INTERNAL_ERROR)
+	if err := subscription.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	transaction, unsubscribed, _ := test.subscribe()
+	if unsubscribed != serverSubscription || getExpires(t, transaction.GetRequest()) != 0 {
+		t.Fatal("bad unsubscribe")
+	}
+	unsubscribed.Accept()
+	test.respond(transaction)
+	notify, statusCode = test.notify()
+	if subscriptionState := getSubscriptionState(t, notify); statusCode != message.OK ||
+		subscriptionState.GetState() != header.SubscriptionState_TERMINATED ||
+		subscriptionState.GetReasonCode() != header.SubscriptionState_TIMEOUT {
+		t.Error("bad final NOTIFY", statusCode, subscriptionState)
+	}
+	if !subscription.IsTerminated() || serverSubscription.GetState() != header.SubscriptionState_TERMINATED {
+		t.Error("subscription not terminated")
+	}
+	if resubscribe, delay := subscription.ShouldResubscribe(); !resubscribe || delay != 0 {
+		t.Error("no new subscription after a timeout", resubscribe, delay)
+	}
+
+	// the subscription is gone
+	if _, statusCode := test.notify(); statusCode != message.CALL_OR_TRANSACTION_DOES_NOT_EXIST {
+		t.Error("NOTIFY of a terminated subscription accepted with", statusCode)
+	}
+}
+
+func TestSubscriptionNotify(t *testing.T) {
+	test := newSubscriptionTest(t)
+	if _, err := test.subscriber.Subscribe(subscribeRequest(t, "Event: presence;id=1\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := test.subscriber.Subscribe(subscribeRequest(t, "Event: presence;id=1\r\n")); err == nil {
+		t.Error("subscription created twice")
+	}
+
+	cases := []struct {
+		headers    string
+		statusCode int
+	}{
+		{"Event: presence;id=1\r\nSubscription-State: active;expires=60\r\n", message.OK},
+		{"Event: presence;id=2\r\nSubscription-State: active;expires=60\r\n", message.CALL_OR_TRANSACTION_DOES_NOT_EXIST},
+		{"Event: dialog;id=1\r\nSubscription-State: active;expires=60\r\n", message.BAD_EVENT},
+		{"Subscription-State: active;expires=60\r\n", message.BAD_EVENT},
+		{"Event: presence;id=1\r\n", message.BAD_REQUEST},
+	}
+	for _, c := range cases {
+		notify := parseRequest(t, inDialogRequest(message.NOTIFY, 3, c.headers, ""))
+		if response := test.subscriber.ProcessNotify(notify, test.subscriberDialog); response.GetStatusCode() != c.statusCode {
+			t.Errorf("%q: expected %d, got %d", c.headers, c.statusCode, response.GetStatusCode())
+		}
+	}
+
+	// the notifier no longer knows the dialog
+	subscription, _ := test.subscriber.Subscribe(subscribeRequest(t, "Event: presence;id=3\r\n"))
+	notify := parseRequest(t, inDialogRequest(message.NOTIFY, 4, "Event: presence;id=3\r\nSubscription-State: active;expires=60\r\n", ""))
+	test.subscriber.ProcessNotify(notify, test.subscriberDialog)
+	subscription.Refresh()
+	refresh := test.subscriberDialog.provider.lastSent(t)
+	test.subscriber.ProcessResponse(refresh.CreateResponse(message.CALL_OR_TRANSACTION_DOES_NOT_EXIST), test.subscriberDialog)
+	if !subscription.IsTerminated() {
+		t.Error("subscription not terminated by a 481")
+	}
+
+	// SUBSCRIBEs of an unknown package
+	transaction := newFakeServerTransaction(subscribeRequest(t, "Event: dialog\r\n"), test.notifierDialog)
+	if _, response := test.notifier.ProcessSubscribe(transaction); response == nil ||
+		response.GetStatusCode() != message.BAD_EVENT || response.GetHeader(core.SIPHeaderNames_ALLOW_EVENTS) == nil {
+		t.Error("unknown package not rejected with 489 and Allow-Events")
+	}
+}
+
+func TestSubscriptionTerminated(t *testing.T) {
+	tests := []struct {
+		subscriptionState string
+		resubscribe       bool
+		delay             int
+	}{
+		{"terminated;reason=deactivated", true, 0},
+		{"terminated;reason=timeout;retry-after=30", true, 0},
+		{"terminated;reason=probation;retry-after=30", true, 30},
+		{"terminated;reason=giveup;retry-after=120", true, 120},
+		{"terminated;reason=probation", true, 0},
+		{"terminated;reason=rejected;retry-after=30", false, 0},
+		{"terminated;reason=noresource", false, 0},
+		{"terminated;reason=invariant", false, 0},
+		{"terminated;retry-after=15", true, 15},
+		{"terminated", false, 0},
+	}
+	for _, test := range tests {
+		st := newSubscriptionTest(t)
+		subscription, err := st.subscriber.Subscribe(subscribeRequest(t, "Event: presence\r\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		notify := parseRequest(t, inDialogRequest(message.NOTIFY, 3, "Event: presence\r\nSubscription-State: "+test.subscriptionState+"\r\n", ""))
+		if response := st.subscriber.ProcessNotify(notify, st.subscriberDialog); response.GetStatusCode() != message.OK {
+			t.Fatalf("%s: NOTIFY rejected with %d", test.subscriptionState, response.GetStatusCode())
+		}
+		resubscribe, delay := subscription.ShouldResubscribe()
+		if !subscription.IsTerminated() || resubscribe != test.resubscribe || delay != test.delay {
+			t.Errorf("%s: resubscribe %v after %d", test.subscriptionState, resubscribe, delay)
+		}
+	}
+}
+
+func TestSubscriptionFetch(t *testing.T) {
+	test := newSubscriptionTest(t)
+	subscription, err := test.subscriber.Subscribe(subscribeRequest(t, "Event: presence\r\nExpires: 0\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction, serverSubscription, response := test.subscribe()
+	if response != nil {
+		t.Fatal("fetch rejected with", response.GetStatusCode())
+	}
+	if err := serverSubscription.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	if response := test.respond(transaction); getExpires(t, response) != 0 {
+		t.Error("bad 200 to a fetch", response)
+	}
+
+	// a single NOTIFY carrying the state terminates the subscription
+	if sent := test.notifierDialog.provider.getSent(); len(sent) != 1 {
+		t.Fatal("bad number of NOTIFYs", len(sent))
+	}
+	notify, statusCode := test.notify()
+	if subscriptionState := getSubscriptionState(t, notify); statusCode != message.OK ||
+		subscriptionState.GetState() != header.SubscriptionState_TERMINATED || notify.GetContent() != "<presence/>" {
+		t.Error("bad NOTIFY of a fetch", notify)
+	}
+	if bodies, terminated := test.consumer.count(); bodies != 1 || terminated != 1 || !subscription.IsTerminated() {
+		t.Error("fetch not completed", bodies, terminated)
+	}
+	if serverSubscription.GetState() != header.SubscriptionState_TERMINATED {
+		t.Error("fetch not terminated by the notifier")
+	}
+}
+
+func TestSubscriptionRefreshTimer(t *testing.T) {
+	test := newSubscriptionTest(t)
+	test.subscriber.unit = time.Millisecond
+	if _, err := test.subscriber.Subscribe(subscribeRequest(t, "Event: presence\r\nExpires: 40\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	transaction, serverSubscription, _ := test.subscribe()
+	serverSubscription.Accept()
+	test.respond(transaction)
+
+	// refreshed at half the duration of a short subscription
+	deadline := time.Now().Add(time.Second)
+	for len(test.subscriberDialog.provider.getSent()) == 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	refresh := test.subscriberDialog.provider.lastSent(t)
+	if refresh.GetMethod() != message.SUBSCRIBE || refresh.GetCSeq().GetSequenceNumber() == 1 || getExpires(t, refresh) != 40 {
+		t.Fatal("subscription not refreshed", refresh)
+	}
+	serverSubscription.Terminate(header.SubscriptionState_NO_RESOURCE, 0)
+	test.notify()
+}
@@ -0,0 +1,24 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Timers.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package sip
+
+import (
+	"time"
+)
+
+/**
+ * The timer values of RFC 3261 section 17.1.1.1 and appendix A.
+ */
+const (
+	/** Round-trip time estimate. */
+	TIMER_T1 = 500 * time.Millisecond
+	/** Maximum retransmit interval for non-INVITE requests and INVITE responses. */
+	TIMER_T2 = 4 * time.Second
+	/** Maximum duration a message will remain in the network. */
+	TIMER_T4 = 5 * time.Second
+)
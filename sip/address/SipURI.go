@@ -371,4 +371,29 @@ type SipURI interface {
 	 * pre-existing route set.
 	 */
 	SetLrParam()
+
+	/**
+	 * Returns whether the <code>gr</code> parameter is set, marking the URI
+	 * as a Globally Routable User Agent URI (RFC 5627).
+	 *
+	 * @return true if the "gr" parameter is set, false otherwise.
+	 */
+	HasGrParam() bool
+
+	/**
+	 * Returns the value of the <code>gr</code> parameter: the instance id of
+	 * a public GRUU, or an empty string for a temporary GRUU.
+	 *
+	 * @return the value of the <code>gr</code> parameter
+	 */
+	GetGrParam() string
+
+	/**
+	 * Sets the <code>gr</code> parameter of this SipURI to the instance id of
+	 * a public GRUU, or without value for a temporary GRUU if grParam is
+	 * empty.
+	 *
+	 * @param grParam - new value of the <code>gr</code> parameter
+	 */
+	SetGrParam(grParam string)
 }
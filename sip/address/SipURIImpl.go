@@ -953,3 +953,35 @@ func (this *SipURIImpl) GetUserParam() string {
 func (this *SipURIImpl) HasLrParam() bool {
 	return this.uriParms.GetNameValue("lr") != nil
 }
+
+/** Returns whether the <code>gr</code> parameter is Set, marking the URI as
+ * a GRUU (RFC 5627).
+ *
+ * @return true if the "gr" parameter is Set, false otherwise.
+ */
+func (this *SipURIImpl) HasGrParam() bool {
+	return this.uriParms.GetNameValue("gr") != nil
+}
+
+/** Returns the value of the <code>gr</code> parameter: the instance id of a
+ * public GRUU, or an empty string for a temporary GRUU.
+ *
+ * @return the value of the <code>gr</code> parameter
+ */
+func (this *SipURIImpl) GetGrParam() string {
+	return this.GetParameter("gr")
+}
+
+/** Sets the <code>gr</code> parameter to the instance id of a public GRUU,
+ * or without value for a temporary GRUU if grParam is empty.
+ *
+ * @param grParam - new value of the <code>gr</code> parameter
+ */
+func (this *SipURIImpl) SetGrParam(grParam string) {
+	this.uriParms.Delete("gr")
+	if grParam == "" {
+		this.uriParms.AddNameValue(core.NewNameValue("gr", nil))
+	} else {
+		this.uriParms.AddNameValue(core.NewNameValue("gr", grParam))
+	}
+}
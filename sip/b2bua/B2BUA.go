@@ -0,0 +1,650 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : B2BUA.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package b2bua
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * This interface is implemented by the applications of a B2BUA.
+ */
+type B2BUAListener interface {
+	/**
+	 * Called for each INVITE creating a new call. The application connects
+	 * the call to one or more targets, at once or later, or rejects it.
+	 */
+	ProcessCall(call *Call)
+
+	/**
+	 * Called for each request received in a dialog of a call, BYE included,
+	 * before it is forwarded to the other side. Returning a response
+	 * consumes the request: the response is sent and the request is not
+	 * forwarded.
+	 */
+	ProcessDialogRequest(leg *Leg, request *message.SIPRequest) *message.SIPResponse
+
+	/**
+	 * Called once a call is terminated.
+	 */
+	CallTerminated(call *Call)
+}
+
+/**
+ * The hook rewriting the session descriptions (RFC 4566) passed from one leg
+ * of a call to another, e.g. to anchor the media on a relay. A session
+ * description that cannot be rewritten makes the request carrying it fail
+ * with 488 (Not Acceptable Here). The hook is called without the lock of the
+ * B2BUA, so that it can use the methods of the call and its legs.
+ */
+type SessionDescriptionRewriter interface {
+	RewriteSessionDescription(from *Leg, to *Leg, sdp string) (string, error)
+
+	/**
+	 * Called once a call is terminated, before the listener, to release
+	 * what was allocated for its session descriptions.
+	 */
+	CallTerminated(call *Call)
+}
+
+/**
+ * A back-to-back user agent (RFC 3261 section 6, RFC 7092). Each INVITE it
+ * receives creates a Call whose inbound leg is the server dialog of the
+ * INVITE; the application connects the call to one or more targets, each
+ * reached through an outbound leg: a client dialog of its own with a new
+ * Call-ID, From tag, Via, CSeq and Contact, so that nothing of one side
+ * leaks to the other but the headers the application chooses to keep. The
+ * first outbound leg to answer is connected to the inbound leg and the other
+ * ones are cancelled; if none answers, the best of their final responses
+ * is returned to the caller.
+ * <p>
+ * Requests received in the dialog of a leg are answered by the listener or
+ * forwarded in the dialog of the other leg and their responses sent back,
+ * with the session descriptions they carry passed through the
+ * SessionDescriptionRewriter. A 2xx or ACK whose session description cannot
+ * be rewritten is acknowledged on its leg and the call ended with a BYE on
+ * both legs, as their sessions would no longer match. A BYE received on one
+ * leg is answered and sent on the other one, and a CANCEL of the inbound
+ * INVITE cancels the outbound legs.
+ * <p>
+ * The application passes to ProcessRequest the requests received with their
+ * server transaction, and to ProcessResponse and ProcessTimeout the
+ * responses and timeouts of the client transactions of the B2BUA.
+ */
+type B2BUA struct {
+	mutex sync.Mutex
+
+	provider     sip.SipProvider
+	host         string
+	port         int
+	listener     B2BUAListener
+	rewriter     SessionDescriptionRewriter
+	legs         map[string]*Leg
+	transactions map[string]*clientRequest
+	terminated   []*Call
+}
+
+/**
+ * A request sent by the B2BUA in a client transaction.
+ */
+type clientRequest struct {
+	leg         *Leg
+	request     *message.SIPRequest
+	transaction sip.ClientTransaction
+	// the transaction of the request forwarded, nil for the INVITE of an
+	// outbound leg and for the requests generated by the B2BUA
+	server sip.ServerTransaction
+	origin *Leg
+}
+
+/**
+ * The body of a message passed from one leg to another, once rewritten by
+ * the SessionDescriptionRewriter.
+ */
+type body struct {
+	content     string
+	contentType header.ContentTypeHeader
+	err         error
+}
+
+/** Creates a B2BUA sending its requests with provider and reachable at host
+ * and port, which it places in the Via and Contact of its messages.
+ */
+func NewB2BUA(provider sip.SipProvider, host string, port int) *B2BUA {
+	return &B2BUA{
+		provider:     provider,
+		host:         host,
+		port:         port,
+		legs:         make(map[string]*Leg),
+		transactions: make(map[string]*clientRequest),
+	}
+}
+
+/** Sets the listener of the B2BUA. Without listener new calls are rejected
+ * with 480 and the requests received in dialogs are all forwarded.
+ */
+func (this *B2BUA) SetB2BUAListener(listener B2BUAListener) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.listener = listener
+}
+
+/** Sets the hook rewriting the session descriptions passed between legs;
+ * without it they are passed unchanged.
+ */
+func (this *B2BUA) SetSessionDescriptionRewriter(rewriter SessionDescriptionRewriter) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.rewriter = rewriter
+}
+
+/** Processes a request received with its server transaction; transaction
+ * is nil for an ACK. An INVITE outside of a dialog creates a call, the other
+ * requests are matched to a leg by their Call-ID and To tag. A request
+ * matching no leg is answered with 481.
+ */
+func (this *B2BUA) ProcessRequest(request message.Request, transaction sip.ServerTransaction) (SipException error) {
+	req, ok := request.(*message.SIPRequest)
+	if !ok {
+		return errors.New("SipException: unsupported request type")
+	}
+	switch {
+	case req.GetMethod() == message.CANCEL:
+		return this.processCancel(req, transaction)
+	case req.GetMethod() == message.ACK:
+		this.processAck(req)
+		return nil
+	case req.GetToTag() == "" && req.GetMethod() == message.INVITE:
+		return this.processInvite(req, transaction)
+	}
+
+	this.mutex.Lock()
+	leg := this.legs[legKey(req.GetCallIdentifier(), req.GetToTag())]
+	listener := this.listener
+	this.mutex.Unlock()
+	if leg == nil {
+		return transaction.SendResponse(req.CreateResponse(message.CALL_OR_TRANSACTION_DOES_NOT_EXIST))
+	}
+	if listener != nil {
+		if response := listener.ProcessDialogRequest(leg, req); response != nil {
+			return transaction.SendResponse(response)
+		}
+	}
+
+	if req.GetMethod() == message.BYE {
+		this.mutex.Lock()
+		defer this.unlock()
+		if err := transaction.SendResponse(req.CreateResponse(message.OK)); err != nil {
+			return err
+		}
+		leg.call.terminate(leg)
+		return nil
+	}
+
+	this.mutex.Lock()
+	peer := leg.getPeer()
+	rewriter := this.rewriter
+	this.mutex.Unlock()
+	var b *body
+	if peer != nil {
+		b = rewriteBody(rewriter, leg, peer, req)
+	}
+
+	this.mutex.Lock()
+	defer this.unlock()
+	return this.forwardRequest(leg, peer, req, b, transaction)
+}
+
+func (this *B2BUA) processInvite(invite *message.SIPRequest, transaction sip.ServerTransaction) error {
+	if maxForwards := invite.GetMaxForwards(); maxForwards != nil && maxForwards.GetMaxForwards() == 0 {
+		return transaction.SendResponse(invite.CreateResponse(message.TOO_MANY_HOPS))
+	}
+
+	this.mutex.Lock()
+	call := &Call{b2bua: this}
+	leg := &Leg{
+		call:        call,
+		inbound:     true,
+		request:     invite,
+		transaction: transaction,
+		callId:      invite.GetCallIdentifier(),
+		remoteTag:   invite.GetFromTag(),
+	}
+	if dialog := transaction.GetDialog(); dialog != nil {
+		leg.dialog = dialog
+		leg.localTag = dialog.GetLocalTag()
+	}
+	if leg.localTag == "" {
+		leg.localTag = sip.NewToken(8)
+	}
+	call.inbound = leg
+	this.legs[leg.key()] = leg
+	listener := this.listener
+	this.mutex.Unlock()
+
+	if listener == nil {
+		return call.Reject(message.TEMPORARILY_UNAVAILABLE)
+	}
+	listener.ProcessCall(call)
+	return nil
+}
+
+/** Processes a CANCEL of the INVITE of an inbound leg: the CANCEL is
+ * answered with 200, the INVITE with 487 and the outbound legs are
+ * cancelled.
+ */
+func (this *B2BUA) processCancel(cancel *message.SIPRequest, transaction sip.ServerTransaction) error {
+	this.mutex.Lock()
+	defer this.unlock()
+
+	var leg *Leg
+	for _, l := range this.legs {
+		if l.inbound && l.callId == cancel.GetCallIdentifier() && l.remoteTag == cancel.GetFromTag() &&
+			l.request.GetCSeqNumber() == cancel.GetCSeqNumber() {
+			leg = l
+		}
+	}
+	if leg == nil {
+		return transaction.SendResponse(cancel.CreateResponse(message.CALL_OR_TRANSACTION_DOES_NOT_EXIST))
+	}
+	if err := transaction.SendResponse(cancel.CreateResponse(message.OK)); err != nil {
+		return err
+	}
+	if leg.call.connected == nil && !leg.call.terminated {
+		leg.call.reject(message.REQUEST_TERMINATED, "")
+	}
+	return nil
+}
+
+/** Processes an ACK of a 2xx forwarded to a leg: the ACK of the 2xx it
+ * answered is sent on the other leg, with the session description of the
+ * ACK if any.
+ */
+func (this *B2BUA) processAck(ack *message.SIPRequest) {
+	this.mutex.Lock()
+	leg := this.legs[legKey(ack.GetCallIdentifier(), ack.GetToTag())]
+	if leg == nil || leg.ackPeer == nil {
+		this.mutex.Unlock()
+		return
+	}
+	peer, cseq := leg.ackPeer, leg.ackCSeq
+	leg.ackPeer = nil
+	rewriter := this.rewriter
+	this.mutex.Unlock()
+	b := rewriteBody(rewriter, leg, peer, ack)
+
+	this.mutex.Lock()
+	defer this.unlock()
+	if b == nil {
+		this.sendAck(peer, cseq, "", nil)
+	} else if b.err == nil {
+		this.sendAck(peer, cseq, b.content, b.contentType)
+	} else {
+		// the answer of the ACK cannot be passed on
+		this.sendAck(peer, cseq, "", nil)
+		leg.call.hangUp()
+	}
+}
+
+/** Processes a response to a request sent by the B2BUA.
+ */
+func (this *B2BUA) ProcessResponse(response message.Response) {
+	this.mutex.Lock()
+	key := transactionKey(response)
+	request := this.transactions[key]
+	if request == nil {
+		this.mutex.Unlock()
+		return
+	}
+	if response.GetStatusCode() >= message.OK {
+		delete(this.transactions, key)
+	}
+	// the leg the body of the response is passed to, if any
+	var to *Leg
+	switch call := request.leg.call; {
+	case request.server != nil:
+		to = request.origin
+	case request.leg.request == request.request && !request.leg.cancelled && call.connected == nil && !call.terminated:
+		to = call.inbound
+	}
+	rewriter := this.rewriter
+	this.mutex.Unlock()
+	var b *body
+	if to != nil {
+		b = rewriteBody(rewriter, request.leg, to, response)
+	}
+
+	this.mutex.Lock()
+	defer this.unlock()
+	this.processResponse(request, response, b)
+}
+
+/** Processes a request of the B2BUA whose client transaction timed out, as
+ * if it had been answered with 408.
+ */
+func (this *B2BUA) ProcessTimeout(request message.Request) {
+	this.mutex.Lock()
+	defer this.unlock()
+
+	key := transactionKey(request)
+	r := this.transactions[key]
+	if r == nil {
+		return
+	}
+	delete(this.transactions, key)
+	this.processResponse(r, r.request.CreateResponse(message.REQUEST_TIMEOUT), nil)
+}
+
+/** Processes a response to request whose body, if it is passed to another
+ * leg, was rewritten as b.
+ */
+func (this *B2BUA) processResponse(request *clientRequest, response message.Response, b *body) {
+	switch {
+	case request.server != nil:
+		this.forwardResponse(request, response, b)
+	case request.leg.request == request.request:
+		request.leg.call.processInviteResponse(request.leg, response, b)
+	}
+}
+
+/** Forwards a request received in the dialog of leg, with its body
+ * rewritten as b, in the dialog of peer, the other leg of the call. The
+ * request is answered with 481 if the other leg has no dialog yet or
+ * changed, and with 488 if its session description cannot be rewritten.
+ */
+func (this *B2BUA) forwardRequest(leg *Leg, peer *Leg, req *message.SIPRequest, b *body, transaction sip.ServerTransaction) error {
+	if peer == nil || peer != leg.getPeer() || peer.getDialog() == nil || peer.terminated {
+		return transaction.SendResponse(req.CreateResponse(message.CALL_OR_TRANSACTION_DOES_NOT_EXIST))
+	}
+	if b != nil && b.err != nil {
+		return transaction.SendResponse(req.CreateResponse(message.NOT_ACCEPTABLE_HERE))
+	}
+	forward, err := peer.dialog.CreateRequest(req.GetMethod())
+	if err != nil {
+		return transaction.SendResponse(req.CreateResponse(message.SERVER_INTERNAL_ERROR))
+	}
+	if b != nil {
+		forward.SetContent(b.content, b.contentType)
+	}
+	for _, name := range forwardedHeaders {
+		if h := req.GetHeader(name); h != nil {
+			forward.SetHeader(h)
+		}
+	}
+	if isTargetRefresh(req.GetMethod()) {
+		forward.SetHeader(this.createContact())
+	}
+	if err = this.sendRequest(peer, forward.(*message.SIPRequest), transaction, leg); err != nil {
+		return transaction.SendResponse(req.CreateResponse(message.SERVER_INTERNAL_ERROR))
+	}
+	return nil
+}
+
+/** Sends back on the origin leg the response to a request forwarded by
+ * forwardRequest, with its body rewritten as b. For a 2xx to an INVITE, the
+ * ACK of the origin leg is forwarded to the other leg once it is received.
+ */
+func (this *B2BUA) forwardResponse(request *clientRequest, response message.Response, b *body) {
+	statusCode := response.GetStatusCode()
+	if statusCode == message.TRYING {
+		return
+	}
+	req := request.server.GetRequest().(*message.SIPRequest)
+	r := req.CreateResponse(statusCode)
+	r.SetReasonPhrase(response.GetReasonPhrase())
+	if b != nil {
+		if b.err == nil {
+			r.SetContent(b.content, b.contentType)
+		} else if statusCode >= message.OK {
+			r = req.CreateResponse(message.SERVER_INTERNAL_ERROR)
+		}
+	}
+	if isTargetRefresh(req.GetMethod()) && r.GetStatusCode() < message.MULTIPLE_CHOICES {
+		r.SetHeader(this.createContact())
+	}
+	if req.GetMethod() == message.INVITE && statusCode >= message.OK && statusCode < message.MULTIPLE_CHOICES {
+		if r.GetStatusCode() < message.MULTIPLE_CHOICES {
+			request.origin.ackPeer = request.leg
+			request.origin.ackCSeq = request.request.GetCSeqNumber()
+		} else {
+			// the new session of the leg cannot be passed on: the call
+			// cannot go on with different sessions on its legs
+			this.sendAck(request.leg, request.request.GetCSeqNumber(), "", nil)
+			request.server.SendResponse(r)
+			request.leg.call.hangUp()
+			return
+		}
+	}
+	request.server.SendResponse(r)
+}
+
+/** Sends a request in a new client transaction in the dialog of leg, or out
+ * of dialog for the INVITE of an outbound leg, and records it so that its
+ * response can be matched.
+ */
+func (this *B2BUA) sendRequest(leg *Leg, request *message.SIPRequest, server sip.ServerTransaction, origin *Leg) error {
+	ct, err := this.provider.GetNewClientTransaction(request)
+	if err != nil {
+		return err
+	}
+	if leg.getDialog() != nil && request.GetToTag() != "" {
+		err = leg.dialog.SendRequest(ct)
+	} else {
+		err = ct.SendRequest()
+	}
+	if err != nil {
+		return err
+	}
+	this.transactions[transactionKey(request)] = &clientRequest{
+		leg:         leg,
+		request:     request,
+		transaction: ct,
+		server:      server,
+		origin:      origin,
+	}
+	return nil
+}
+
+/** Sends the ACK of the 2xx to the INVITE with the given CSeq number in the
+ * dialog of leg.
+ */
+func (this *B2BUA) sendAck(leg *Leg, cseq int, content string, contentType header.ContentTypeHeader) {
+	if leg.getDialog() == nil {
+		return
+	}
+	ack, err := leg.dialog.CreateRequest(message.ACK)
+	if err != nil {
+		return
+	}
+	if cseqHeader, ok := ack.GetHeader(core.SIPHeaderNames_CSEQ).(header.CSeqHeader); ok {
+		cseqHeader.SetSequenceNumber(cseq)
+	}
+	if content != "" {
+		ack.SetContent(content, contentType)
+	}
+	leg.dialog.SendAck(ack)
+}
+
+/** Sends a BYE in the dialog of leg.
+ */
+func (this *B2BUA) sendBye(leg *Leg) {
+	if leg.getDialog() == nil {
+		return
+	}
+	bye, err := leg.dialog.CreateRequest(message.BYE)
+	if err != nil {
+		return
+	}
+	this.sendRequest(leg, bye.(*message.SIPRequest), nil, nil)
+}
+
+/** Returns the rewriter of the session descriptions.
+ */
+func (this *B2BUA) getRewriter() SessionDescriptionRewriter {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.rewriter
+}
+
+/** Returns the body of msg to pass from one leg to the other, rewritten by
+ * rewriter if it is a session description, or nil if msg has no body. It is
+ * called without the lock of the B2BUA.
+ */
+func rewriteBody(rewriter SessionDescriptionRewriter, from, to *Leg, msg message.Message) *body {
+	content := msg.GetContent()
+	if content == "" {
+		return nil
+	}
+	contentType, _ := msg.GetHeader(core.SIPHeaderNames_CONTENT_TYPE).(header.ContentTypeHeader)
+	if contentType == nil {
+		contentType = header.NewContentTypeFromString("application", "sdp")
+	}
+	if rewriter != nil && strings.EqualFold(contentType.GetContentType(), "application") &&
+		strings.EqualFold(contentType.GetContentSubType(), "sdp") {
+		var err error
+		if content, err = rewriter.RewriteSessionDescription(from, to, content); err != nil {
+			return &body{err: err}
+		}
+	}
+	return &body{content: content, contentType: contentType}
+}
+
+/** Returns a Via of the B2BUA with a new branch.
+ */
+func (this *B2BUA) createVia() (*header.ViaList, error) {
+	transport := sip.UDP
+	if listeningPoint := this.provider.GetListeningPoint(); listeningPoint != nil {
+		transport = listeningPoint.GetTransport()
+	}
+	via := "Via: SIP/2.0/" + strings.ToUpper(transport) + " " + this.getHostPort() +
+		";branch=" + header.SIPConstants_BRANCH_MAGIC_COOKIE + sip.NewToken(8) + "\n"
+	viaList, err := parser.NewViaParser(via).Parse()
+	if err != nil {
+		return nil, err
+	}
+	return viaList.(*header.ViaList), nil
+}
+
+/** Returns a Contact with the URI of the B2BUA.
+ */
+func (this *B2BUA) createContact() *header.Contact {
+	uri := "sip:" + this.getHostPort()
+	if listeningPoint := this.provider.GetListeningPoint(); listeningPoint != nil &&
+		!strings.EqualFold(listeningPoint.GetTransport(), sip.UDP) {
+		uri += ";transport=" + strings.ToLower(listeningPoint.GetTransport())
+	}
+	contact := header.NewContact()
+	if contactAddress, err := parser.NewAddressParser("<" + uri + ">").Address(); err == nil {
+		contact.SetAddress(contactAddress)
+	}
+	return contact
+}
+
+func (this *B2BUA) getHostPort() string {
+	if this.port > 0 {
+		return this.host + ":" + strconv.Itoa(this.port)
+	}
+	return this.host
+}
+
+/** Unlocks the B2BUA and informs the rewriter and the listener of the calls
+ * terminated meanwhile.
+ */
+func (this *B2BUA) unlock() {
+	terminated := this.terminated
+	this.terminated = nil
+	listener := this.listener
+	rewriter := this.rewriter
+	this.mutex.Unlock()
+	for _, call := range terminated {
+		if rewriter != nil {
+			rewriter.CallTerminated(call)
+		}
+		if listener != nil {
+			listener.CallTerminated(call)
+		}
+	}
+}
+
+/** The headers copied from a request received on one leg to the request
+ * forwarded on the other one, and from the INVITE of a call to its outbound
+ * INVITEs, besides its body.
+ */
+var forwardedHeaders = []string{
+	core.SIPHeaderNames_EVENT,
+	core.SIPHeaderNames_SUBSCRIPTION_STATE,
+	core.SIPHeaderNames_REFER_TO,
+	core.SIPHeaderNames_REASON,
+	core.SIPHeaderNames_CONTENT_DISPOSITION,
+	core.SIPHeaderNames_SESSION_EXPIRES,
+	core.SIPHeaderNames_MIN_SE,
+}
+
+/** Returns true if a request of method refreshes the remote target of its
+ * dialog, so that the request and its 2xx carry a Contact.
+ */
+func isTargetRefresh(method string) bool {
+	return method == message.INVITE || method == message.UPDATE ||
+		method == message.SUBSCRIBE || method == message.NOTIFY || method == message.REFER
+}
+
+/** Returns the key of the client transaction of msg: its Call-ID, CSeq
+ * number and method, the latter telling a CANCEL from its INVITE.
+ */
+func transactionKey(msg message.Message) string {
+	var key string
+	if callId, ok := msg.GetHeader(core.SIPHeaderNames_CALL_ID).(header.CallIdHeader); ok {
+		key = callId.GetCallId()
+	}
+	if cseq, ok := msg.GetHeader(core.SIPHeaderNames_CSEQ).(header.CSeqHeader); ok {
+		key += "|" + strconv.Itoa(cseq.GetSequenceNumber()) + "|" + cseq.GetMethod()
+	}
+	return key
+}
+
+func legKey(callId, localTag string) string {
+	return callId + "|" + localTag
+}
+
+/** Returns a copy of uri usable as Request-URI.
+ */
+func copyURI(uri address.URI) (address.URI, error) {
+	copied, err := parser.NewURLParser(uri.String()).UriReference()
+	if err != nil {
+		return nil, err
+	}
+	if sipURI, ok := copied.(*address.SipURIImpl); ok {
+		sipURI.RemoveHeaders()
+	}
+	return copied, nil
+}
+
+/** Returns a copy of the display name and URI of addr, without the headers
+ * of the URI.
+ */
+func copyAddress(addr address.Address) (address.Address, error) {
+	uri, err := copyURI(addr.GetURI())
+	if err != nil {
+		return nil, err
+	}
+	copied := address.NewAddressImpl()
+	copied.SetURI(uri)
+	if displayName := addr.GetDisplayName(); displayName != "" {
+		copied.SetDisplayName(displayName)
+	}
+	return copied, nil
+}
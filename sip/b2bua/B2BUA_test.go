@@ -0,0 +1,642 @@
+package b2bua
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeDialog creates the requests of a dialog of the B2BUA and records its
+// ACKs.
+type fakeDialog struct {
+	sip.Dialog
+
+	provider  *fakeProvider
+	callId    string
+	localTag  string
+	remoteTag string
+	localURI  string
+	remoteURI string
+	target    string
+	localSeq  int
+	acks      []*message.SIPRequest
+}
+
+func (this *fakeDialog) GetLocalTag() string {
+	return this.localTag
+}
+
+func (this *fakeDialog) CreateRequest(method string) (message.Request, error) {
+	if method != message.ACK {
+		this.localSeq++
+	}
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(method + " " + this.target + " SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP b2b.example.com:5060;branch=z9hG4bK" + strconv.Itoa(len(this.provider.sent)) + "\r\n" +
+		"From: <" + this.localURI + ">;tag=" + this.localTag + "\r\n" +
+		"To: <" + this.remoteURI + ">;tag=" + this.remoteTag + "\r\n" +
+		"Call-ID: " + this.callId + "\r\n" +
+		"CSeq: " + strconv.Itoa(this.localSeq) + " " + method + "\r\n" +
+		"Max-Forwards: 70\r\n" +
+		"Content-Length: 0\r\n\r\n")
+	if err != nil {
+		return nil, err
+	}
+	return msg.(message.Request), nil
+}
+
+func (this *fakeDialog) SendRequest(ct sip.ClientTransaction) error {
+	return ct.SendRequest()
+}
+
+func (this *fakeDialog) SendAck(ack message.Request) error {
+	this.acks = append(this.acks, ack.(*message.SIPRequest))
+	return nil
+}
+
+type fakeClientTransaction struct {
+	sip.ClientTransaction
+
+	provider *fakeProvider
+	request  *message.SIPRequest
+	dialog   *fakeDialog
+}
+
+func (this *fakeClientTransaction) SendRequest() error {
+	this.provider.sent = append(this.provider.sent, this.request)
+	return nil
+}
+
+func (this *fakeClientTransaction) GetDialog() sip.Dialog {
+	if this.dialog == nil {
+		return nil
+	}
+	return this.dialog
+}
+
+func (this *fakeClientTransaction) CreateCancel() (message.Request, error) {
+	// CreateCancelRequest changes the headers it shares with the request
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(this.request.String())
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*message.SIPRequest).CreateCancelRequest(), nil
+}
+
+type fakeServerTransaction struct {
+	sip.ServerTransaction
+
+	request   *message.SIPRequest
+	dialog    *fakeDialog
+	responses []*message.SIPResponse
+}
+
+func (this *fakeServerTransaction) GetRequest() message.Request {
+	return this.request
+}
+
+func (this *fakeServerTransaction) SendResponse(response message.Response) error {
+	this.responses = append(this.responses, response.(*message.SIPResponse))
+	return nil
+}
+
+func (this *fakeServerTransaction) GetDialog() sip.Dialog {
+	if this.dialog == nil {
+		return nil
+	}
+	return this.dialog
+}
+
+func (this *fakeServerTransaction) lastResponse(t *testing.T) *message.SIPResponse {
+	if len(this.responses) == 0 {
+		t.Fatal("no response sent to", this.request.GetMethod())
+	}
+	return this.responses[len(this.responses)-1]
+}
+
+type fakeProvider struct {
+	sip.SipProvider
+
+	callIds      int
+	sent         []*message.SIPRequest
+	transactions []*fakeClientTransaction
+}
+
+func (this *fakeProvider) GetListeningPoint() sip.ListeningPoint {
+	return nil
+}
+
+func (this *fakeProvider) GetNewCallId() header.CallIdHeader {
+	this.callIds++
+	callId, _ := header.NewCallID("b2b" + strconv.Itoa(this.callIds) + "@b2b.example.com")
+	return callId
+}
+
+func (this *fakeProvider) GetNewClientTransaction(request message.Request) (sip.ClientTransaction, error) {
+	ct := &fakeClientTransaction{provider: this, request: request.(*message.SIPRequest)}
+	this.transactions = append(this.transactions, ct)
+	return ct, nil
+}
+
+func (this *fakeProvider) lastSent(t *testing.T) *message.SIPRequest {
+	if len(this.sent) == 0 {
+		t.Fatal("no request sent")
+	}
+	return this.sent[len(this.sent)-1]
+}
+
+// getTransaction returns the client transaction of request.
+func (this *fakeProvider) getTransaction(request *message.SIPRequest) *fakeClientTransaction {
+	for _, ct := range this.transactions {
+		if ct.request == request {
+			return ct
+		}
+	}
+	return nil
+}
+
+// fakeListener connects the calls to its targets.
+type fakeListener struct {
+	targets    []string
+	consume    string
+	terminated []*Call
+}
+
+func (this *fakeListener) ProcessCall(call *Call) {
+	for _, target := range this.targets {
+		uri, _ := parser.NewURLParser(target).UriReference()
+		call.Connect(uri)
+	}
+}
+
+func (this *fakeListener) ProcessDialogRequest(leg *Leg, request *message.SIPRequest) *message.SIPResponse {
+	if request.GetMethod() == this.consume {
+		return request.CreateResponse(message.OK)
+	}
+	return nil
+}
+
+func (this *fakeListener) CallTerminated(call *Call) {
+	this.terminated = append(this.terminated, call)
+}
+
+// fakeRewriter replaces the address of alice with the one of the relay and
+// fails on the session descriptions containing reject. It uses the methods
+// of the legs, which take the lock of the B2BUA.
+type fakeRewriter struct {
+	reject     string
+	terminated []*Call
+}
+
+func (this *fakeRewriter) RewriteSessionDescription(from *Leg, to *Leg, sdp string) (string, error) {
+	if from.IsTerminated() || from.GetCall().IsTerminated() {
+		return "", errors.New("terminated call")
+	}
+	if this.reject != "" && strings.Contains(sdp, this.reject) {
+		return "", errors.New("rejected session description")
+	}
+	return strings.Replace(sdp, "192.0.2.1", "203.0.113.1", -1), nil
+}
+
+func (this *fakeRewriter) CallTerminated(call *Call) {
+	this.terminated = append(this.terminated, call)
+}
+
+func sdpOffer(host string, port int) string {
+	return "v=0\r\n" +
+		"o=- 1 1 IN IP4 " + host + "\r\n" +
+		"s=-\r\n" +
+		"c=IN IP4 " + host + "\r\n" +
+		"t=0 0\r\n" +
+		"m=audio " + strconv.Itoa(port) + " RTP/AVP 0\r\n"
+}
+
+func parseRequest(t *testing.T, s string) *message.SIPRequest {
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(s)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, s)
+	}
+	return msg.(*message.SIPRequest)
+}
+
+// aliceRequest returns a request of alice to the B2BUA, in the dialog with
+// toTag if not empty.
+func aliceRequest(t *testing.T, method string, cseq int, toTag string, sdp string) *message.SIPRequest {
+	to := "<sip:bob@b2b.example.com>"
+	if toTag != "" {
+		to += ";tag=" + toTag
+	}
+	content := "Content-Length: 0\r\n\r\n"
+	if sdp != "" {
+		content = "Content-Type: application/sdp\r\nContent-Length: " + strconv.Itoa(len(sdp)) + "\r\n\r\n" + sdp
+	}
+	return parseRequest(t, method+" sip:bob@b2b.example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bKalice"+strconv.Itoa(cseq)+method+"\r\n"+
+		"Record-Route: <sip:p.a.example.com;lr>\r\n"+
+		"From: \"Alice\" <sip:alice@a.example.com>;tag=alice-tag\r\n"+
+		"To: "+to+"\r\n"+
+		"Call-ID: alice@192.0.2.1\r\n"+
+		"CSeq: "+strconv.Itoa(cseq)+" "+method+"\r\n"+
+		"Contact: <sip:alice@192.0.2.1>\r\n"+
+		"Max-Forwards: 70\r\n"+content)
+}
+
+// bobRequest returns a request of bob in the dialog of the outbound INVITE
+// invite.
+func bobRequest(t *testing.T, invite *message.SIPRequest, method string, cseq int, sdp string) *message.SIPRequest {
+	content := "Content-Length: 0\r\n\r\n"
+	if sdp != "" {
+		content = "Content-Type: application/sdp\r\nContent-Length: " + strconv.Itoa(len(sdp)) + "\r\n\r\n" + sdp
+	}
+	return parseRequest(t, method+" sip:b2b.example.com:5060 SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP 192.0.2.2;branch=z9hG4bKbob"+strconv.Itoa(cseq)+method+"\r\n"+
+		"From: <sip:bob@192.0.2.2>;tag=bob-tag\r\n"+
+		"To: <sip:alice@a.example.com>;tag="+invite.GetFromTag()+"\r\n"+
+		"Call-ID: "+invite.GetCallIdentifier()+"\r\n"+
+		"CSeq: "+strconv.Itoa(cseq)+" "+method+"\r\n"+
+		"Contact: <sip:bob@192.0.2.2>\r\n"+
+		"Max-Forwards: 70\r\n"+content)
+}
+
+// respond returns the response of the remote party to request.
+func respond(t *testing.T, request *message.SIPRequest, statusCode int, toTag string, sdp string) message.Response {
+	response := request.CreateResponse(statusCode)
+	if toTag != "" && request.GetToTag() == "" {
+		response.SetToTag(toTag)
+	}
+	if sdp != "" {
+		response.SetContent(sdp, header.NewContentTypeFromString("application", "sdp"))
+	}
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(response.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg.(message.Response)
+}
+
+// connectedCall is a call of alice to bob through the B2BUA, answered and
+// acknowledged.
+type connectedCall struct {
+	provider *fakeProvider
+	b2bua    *B2BUA
+	listener *fakeListener
+	inbound  *fakeServerTransaction
+	invite   *message.SIPRequest
+	// the dialogs of the B2BUA with alice and bob
+	alice *fakeDialog
+	bob   *fakeDialog
+}
+
+func newConnectedCall(t *testing.T, rewriter SessionDescriptionRewriter) *connectedCall {
+	this := &connectedCall{provider: &fakeProvider{}, listener: &fakeListener{targets: []string{"sip:bob@192.0.2.2"}}}
+	this.b2bua = NewB2BUA(this.provider, "b2b.example.com", 5060)
+	this.b2bua.SetB2BUAListener(this.listener)
+	if rewriter != nil {
+		this.b2bua.SetSessionDescriptionRewriter(rewriter)
+	}
+	invite := aliceRequest(t, message.INVITE, 1, "", sdpOffer("192.0.2.1", 4000))
+	this.inbound = &fakeServerTransaction{request: invite}
+	if err := this.b2bua.ProcessRequest(invite, this.inbound); err != nil {
+		t.Fatal(err)
+	}
+	this.invite = this.provider.lastSent(t)
+	this.bob = &fakeDialog{provider: this.provider, callId: this.invite.GetCallIdentifier(),
+		localTag: this.invite.GetFromTag(), remoteTag: "bob-tag", localURI: "sip:alice@a.example.com",
+		remoteURI: "sip:bob@192.0.2.2", target: "sip:bob@192.0.2.2", localSeq: 1}
+	this.provider.getTransaction(this.invite).dialog = this.bob
+	this.b2bua.ProcessResponse(respond(t, this.invite, message.OK, "bob-tag", sdpOffer("192.0.2.2", 5000)))
+	ok := this.inbound.lastResponse(t)
+	if ok.GetStatusCode() != message.OK {
+		t.Fatal("call not answered", ok)
+	}
+	this.alice = &fakeDialog{provider: this.provider, callId: "alice@192.0.2.1", localTag: ok.GetToTag(),
+		remoteTag: "alice-tag", localURI: "sip:bob@b2b.example.com", remoteURI: "sip:alice@a.example.com",
+		target: "sip:alice@192.0.2.1", localSeq: 100}
+	this.inbound.dialog = this.alice
+	this.b2bua.ProcessRequest(aliceRequest(t, message.ACK, 1, ok.GetToTag(), ""), nil)
+	if len(this.bob.acks) != 1 || this.bob.acks[0].GetCSeqNumber() != 1 {
+		t.Fatal("ACK not sent to bob", this.bob.acks)
+	}
+	return this
+}
+
+func TestB2BUAConnect(t *testing.T) {
+	provider := &fakeProvider{}
+	b2bua := NewB2BUA(provider, "b2b.example.com", 5060)
+	listener := &fakeListener{targets: []string{"sip:bob@192.0.2.2", "sip:bob@192.0.2.3"}}
+	b2bua.SetB2BUAListener(listener)
+	b2bua.SetSessionDescriptionRewriter(&fakeRewriter{})
+	invite := aliceRequest(t, message.INVITE, 1, "", sdpOffer("192.0.2.1", 4000))
+	inbound := &fakeServerTransaction{request: invite}
+	if err := b2bua.ProcessRequest(invite, inbound); err != nil {
+		t.Fatal(err)
+	}
+
+	// the outbound legs share nothing with the inbound one
+	if len(provider.sent) != 2 {
+		t.Fatal("expected an INVITE per target, got", len(provider.sent))
+	}
+	invite1, invite2 := provider.sent[0], provider.sent[1]
+	if s := invite1.String(); strings.Contains(s, "alice@192.0.2.1") || strings.Contains(s, "alice-tag") ||
+		strings.Contains(s, "z9hG4bKalice") || strings.Contains(s, "Record-Route") ||
+		!strings.Contains(s, "Contact: <sip:b2b.example.com:5060>") || !strings.Contains(s, "Max-Forwards: 69") ||
+		!strings.Contains(s, "c=IN IP4 203.0.113.1") || invite1.GetCSeqNumber() != 1 ||
+		invite1.GetRequestURI().String() != "sip:bob@192.0.2.2" {
+		t.Error("bad outbound INVITE", s)
+	}
+	if invite1.GetCallIdentifier() == invite2.GetCallIdentifier() || invite1.GetFromTag() == invite2.GetFromTag() {
+		t.Error("outbound legs share their dialog identifiers")
+	}
+
+	// a ringing leg is forwarded, with the tag of the B2BUA
+	b2bua.ProcessResponse(respond(t, invite1, message.RINGING, "bob-tag", ""))
+	ringing := inbound.lastResponse(t)
+	if ringing.GetStatusCode() != message.RINGING || ringing.GetToTag() == "" || ringing.GetToTag() == "bob-tag" {
+		t.Fatal("bad 180", ringing)
+	}
+	b2bua.ProcessResponse(respond(t, invite2, message.SESSION_PROGRESS, "bob2-tag", ""))
+
+	// the first 2xx connects the call and cancels the other leg
+	provider.getTransaction(invite1).dialog = &fakeDialog{provider: provider, callId: invite1.GetCallIdentifier(),
+		localTag: invite1.GetFromTag(), remoteTag: "bob-tag", target: "sip:bob@192.0.2.2", localSeq: 1}
+	b2bua.ProcessResponse(respond(t, invite1, message.OK, "bob-tag", sdpOffer("192.0.2.2", 5000)))
+	ok := inbound.lastResponse(t)
+	if ok.GetStatusCode() != message.OK || ok.GetToTag() != ringing.GetToTag() || !strings.Contains(ok.GetContent(), "5000") ||
+		!strings.Contains(ok.String(), "Contact: <sip:b2b.example.com:5060>") {
+		t.Fatal("bad 200", ok)
+	}
+	if cancel := provider.lastSent(t); cancel.GetMethod() != message.CANCEL || cancel.GetCallIdentifier() != invite2.GetCallIdentifier() {
+		t.Error("other leg not cancelled", cancel)
+	}
+	if len(b2bua.legs) != 3 || len(listener.terminated) != 0 {
+		t.Error("legs of the call forgotten")
+	}
+	b2bua.ProcessResponse(respond(t, invite2, message.REQUEST_TERMINATED, "bob2-tag", ""))
+	if len(inbound.responses) != 3 {
+		t.Error("response of the cancelled leg forwarded", inbound.lastResponse(t))
+	}
+}
+
+func TestB2BUAConnectHeaders(t *testing.T) {
+	provider := &fakeProvider{}
+	b2bua := NewB2BUA(provider, "b2b.example.com", 5060)
+	b2bua.SetB2BUAListener(&fakeListener{targets: []string{"sip:bob@192.0.2.2"}})
+	invite := aliceRequest(t, message.INVITE, 1, "", sdpOffer("192.0.2.1", 4000))
+	invite = parseRequest(t, strings.Replace(invite.String(), "Max-Forwards: 70\r\n", "Max-Forwards: 70\r\n"+
+		"Authorization: Digest username=\"alice\", realm=\"b2b.example.com\", nonce=\"n\", uri=\"sip:bob@b2b.example.com\", response=\"r\"\r\n"+
+		"Proxy-Authorization: Digest username=\"alice\", realm=\"a.example.com\", nonce=\"n\", uri=\"sip:bob@b2b.example.com\", response=\"r\"\r\n"+
+		"Replaces: other@192.0.2.1;to-tag=bob-tag;from-tag=alice-tag\r\n"+
+		"Require: 100rel\r\n"+
+		"Supported: 100rel, timer\r\n"+
+		"Session-Expires: 1800\r\n", 1))
+	if err := b2bua.ProcessRequest(invite, &fakeServerTransaction{request: invite}); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the addresses, the body and the forwarded headers are copied
+	sent := parseRequest(t, provider.lastSent(t).String())
+	for _, name := range []string{core.SIPHeaderNames_AUTHORIZATION, core.SIPHeaderNames_PROXY_AUTHORIZATION,
+		core.SIPHeaderNames_REPLACES, core.SIPHeaderNames_REQUIRE, core.SIPHeaderNames_SUPPORTED} {
+		if sent.GetHeader(name) != nil {
+			t.Error(name, "copied", sent)
+		}
+	}
+	if s := sent.String(); !strings.Contains(s, "From: \"Alice\" <sip:alice@a.example.com>;tag=") ||
+		!strings.Contains(s, "To: <sip:bob@b2b.example.com>") || !strings.Contains(s, "Session-Expires: 1800") ||
+		!strings.Contains(s, "m=audio 4000 ") || sent.GetFromTag() == "alice-tag" {
+		t.Error("bad outbound INVITE", s)
+	}
+}
+
+func TestB2BUAFailure(t *testing.T) {
+	provider := &fakeProvider{}
+	b2bua := NewB2BUA(provider, "b2b.example.com", 0)
+	listener := &fakeListener{targets: []string{"sip:bob@192.0.2.2", "sip:bob@192.0.2.3"}}
+	b2bua.SetB2BUAListener(listener)
+
+	// the best final response is returned once all legs failed
+	invite := aliceRequest(t, message.INVITE, 1, "", "")
+	inbound := &fakeServerTransaction{request: invite}
+	b2bua.ProcessRequest(invite, inbound)
+	b2bua.ProcessResponse(respond(t, provider.sent[0], message.BUSY_HERE, "bob-tag", ""))
+	if len(inbound.responses) != 0 {
+		t.Fatal("call rejected with a leg left", inbound.lastResponse(t))
+	}
+	b2bua.ProcessTimeout(provider.sent[1])
+	if response := inbound.lastResponse(t); response.GetStatusCode() != message.BUSY_HERE || len(listener.terminated) != 1 {
+		t.Fatal("bad final response", response)
+	}
+
+	// a 6xx ends the search
+	provider.sent = nil
+	invite = aliceRequest(t, message.INVITE, 2, "", "")
+	inbound = &fakeServerTransaction{request: invite}
+	b2bua.ProcessRequest(invite, inbound)
+	b2bua.ProcessResponse(respond(t, provider.sent[1], message.SESSION_PROGRESS, "bob2-tag", ""))
+	b2bua.ProcessResponse(respond(t, provider.sent[0], message.DECLINE, "bob-tag", ""))
+	if response := inbound.lastResponse(t); response.GetStatusCode() != message.DECLINE {
+		t.Error("6xx not returned", response)
+	}
+	if cancel := provider.lastSent(t); cancel.GetMethod() != message.CANCEL || cancel.GetCallIdentifier() != provider.sent[1].GetCallIdentifier() {
+		t.Error("pending leg not cancelled", cancel)
+	}
+
+	// without listener calls are rejected with 480
+	b2bua.SetB2BUAListener(nil)
+	invite = aliceRequest(t, message.INVITE, 3, "", "")
+	inbound = &fakeServerTransaction{request: invite}
+	b2bua.ProcessRequest(invite, inbound)
+	if response := inbound.lastResponse(t); response.GetStatusCode() != message.TEMPORARILY_UNAVAILABLE {
+		t.Error("call without listener answered with", response.GetStatusCode())
+	}
+}
+
+func TestB2BUACancel(t *testing.T) {
+	provider := &fakeProvider{}
+	b2bua := NewB2BUA(provider, "b2b.example.com", 5060)
+	listener := &fakeListener{targets: []string{"sip:bob@192.0.2.2"}}
+	b2bua.SetB2BUAListener(listener)
+	invite := aliceRequest(t, message.INVITE, 1, "", "")
+	inbound := &fakeServerTransaction{request: invite}
+	b2bua.ProcessRequest(invite, inbound)
+	outbound := provider.lastSent(t)
+
+	// the CANCEL of the outbound INVITE waits for a provisional response
+	cancel := invite.CreateCancelRequest()
+	cancelTransaction := &fakeServerTransaction{request: cancel}
+	b2bua.ProcessRequest(cancel, cancelTransaction)
+	if cancelTransaction.lastResponse(t).GetStatusCode() != message.OK ||
+		inbound.lastResponse(t).GetStatusCode() != message.REQUEST_TERMINATED || len(listener.terminated) != 1 {
+		t.Fatal("CANCEL not answered")
+	}
+	if len(provider.sent) != 1 {
+		t.Fatal("CANCEL sent before a provisional response", provider.lastSent(t))
+	}
+	b2bua.ProcessResponse(respond(t, outbound, message.RINGING, "bob-tag", ""))
+	if cancel := provider.lastSent(t); cancel.GetMethod() != message.CANCEL || cancel.GetCallIdentifier() != outbound.GetCallIdentifier() ||
+		len(inbound.responses) != 1 {
+		t.Fatal("outbound INVITE not cancelled", cancel)
+	}
+
+	// a 2xx crossing the CANCEL is acknowledged and ended with a BYE
+	bob := &fakeDialog{provider: provider, callId: outbound.GetCallIdentifier(), localTag: outbound.GetFromTag(),
+		remoteTag: "bob-tag", target: "sip:bob@192.0.2.2", localSeq: 1}
+	provider.getTransaction(outbound).dialog = bob
+	b2bua.ProcessResponse(respond(t, outbound, message.OK, "bob-tag", ""))
+	if len(bob.acks) != 1 || provider.lastSent(t).GetMethod() != message.BYE || len(inbound.responses) != 1 {
+		t.Error("late 2xx not ended")
+	}
+
+	// a CANCEL of nothing
+	cancelTransaction = &fakeServerTransaction{request: cancel}
+	b2bua.ProcessRequest(cancel, cancelTransaction)
+	if cancelTransaction.lastResponse(t).GetStatusCode() != message.CALL_OR_TRANSACTION_DOES_NOT_EXIST {
+		t.Error("CANCEL of a terminated call answered with", cancelTransaction.lastResponse(t).GetStatusCode())
+	}
+}
+
+func TestB2BUADialogRequests(t *testing.T) {
+	call := newConnectedCall(t, &fakeRewriter{})
+	aliceTag := call.alice.localTag
+
+	// an INFO of alice is forwarded to bob and its response back
+	info := aliceRequest(t, message.INFO, 2, aliceTag, "")
+	transaction := &fakeServerTransaction{request: info}
+	call.b2bua.ProcessRequest(info, transaction)
+	forward := call.provider.lastSent(t)
+	if forward.GetMethod() != message.INFO || forward.GetCallIdentifier() != call.invite.GetCallIdentifier() {
+		t.Fatal("INFO not forwarded", forward)
+	}
+	call.b2bua.ProcessResponse(respond(t, forward, message.OK, "", ""))
+	if response := transaction.lastResponse(t); response.GetStatusCode() != message.OK || response.GetCallIdentifier() != "alice@192.0.2.1" {
+		t.Error("bad INFO response", response)
+	}
+
+	// unless the listener answers it
+	call.listener.consume = message.INFO
+	sent := len(call.provider.sent)
+	transaction = &fakeServerTransaction{request: info}
+	call.b2bua.ProcessRequest(info, transaction)
+	if len(call.provider.sent) != sent || transaction.lastResponse(t).GetStatusCode() != message.OK {
+		t.Error("INFO consumed by the listener forwarded")
+	}
+
+	// a re-INVITE of bob is forwarded to alice with the contact of the
+	// B2BUA, and the ACK of its 2xx back to bob
+	reInvite := bobRequest(t, call.invite, message.INVITE, 2, sdpOffer("192.0.2.2", 5002))
+	transaction = &fakeServerTransaction{request: reInvite}
+	call.b2bua.ProcessRequest(reInvite, transaction)
+	forward = call.provider.lastSent(t)
+	if forward.GetMethod() != message.INVITE || forward.GetCallIdentifier() != "alice@192.0.2.1" ||
+		!strings.Contains(forward.GetContent(), "5002") || !strings.Contains(forward.String(), "Contact: <sip:b2b.example.com:5060>") {
+		t.Fatal("re-INVITE not forwarded", forward)
+	}
+	call.b2bua.ProcessResponse(respond(t, forward, message.OK, "", sdpOffer("192.0.2.1", 4002)))
+	if response := transaction.lastResponse(t); response.GetStatusCode() != message.OK ||
+		!strings.Contains(response.GetContent(), "c=IN IP4 203.0.113.1") {
+		t.Fatal("bad re-INVITE response", response)
+	}
+	if len(call.alice.acks) != 0 {
+		t.Fatal("ACK sent before the ACK of bob")
+	}
+	call.b2bua.ProcessRequest(bobRequest(t, call.invite, message.ACK, 2, ""), nil)
+	if len(call.alice.acks) != 1 || call.alice.acks[0].GetCSeqNumber() != forward.GetCSeqNumber() {
+		t.Error("ACK of the re-INVITE not forwarded", call.alice.acks)
+	}
+
+	// a request of an unknown dialog
+	unknown := parseRequest(t, strings.Replace(info.String(), "Call-ID: alice@192.0.2.1", "Call-ID: unknown@192.0.2.1", 1))
+	transaction = &fakeServerTransaction{request: unknown}
+	call.b2bua.ProcessRequest(unknown, transaction)
+	if transaction.lastResponse(t).GetStatusCode() != message.CALL_OR_TRANSACTION_DOES_NOT_EXIST {
+		t.Error("request of an unknown dialog answered with", transaction.lastResponse(t).GetStatusCode())
+	}
+}
+
+func TestB2BUABye(t *testing.T) {
+	// a BYE of alice is answered and sent to bob
+	call := newConnectedCall(t, nil)
+	bye := aliceRequest(t, message.BYE, 2, call.alice.localTag, "")
+	transaction := &fakeServerTransaction{request: bye}
+	call.b2bua.ProcessRequest(bye, transaction)
+	if forward := call.provider.lastSent(t); transaction.lastResponse(t).GetStatusCode() != message.OK ||
+		forward.GetMethod() != message.BYE || forward.GetCallIdentifier() != call.invite.GetCallIdentifier() ||
+		len(call.listener.terminated) != 1 {
+		t.Fatal("BYE not forwarded to bob")
+	}
+	transaction = &fakeServerTransaction{request: bye}
+	call.b2bua.ProcessRequest(bye, transaction)
+	if transaction.lastResponse(t).GetStatusCode() != message.CALL_OR_TRANSACTION_DOES_NOT_EXIST {
+		t.Error("BYE of a terminated call answered with", transaction.lastResponse(t).GetStatusCode())
+	}
+
+	// a BYE of bob is sent to alice
+	call = newConnectedCall(t, nil)
+	bye = bobRequest(t, call.invite, message.BYE, 2, "")
+	transaction = &fakeServerTransaction{request: bye}
+	call.b2bua.ProcessRequest(bye, transaction)
+	if forward := call.provider.lastSent(t); forward.GetMethod() != message.BYE || forward.GetCallIdentifier() != "alice@192.0.2.1" ||
+		len(call.listener.terminated) != 1 {
+		t.Fatal("BYE not forwarded to alice")
+	}
+
+	// Terminate sends a BYE on both legs
+	call = newConnectedCall(t, nil)
+	sent := len(call.provider.sent)
+	inbound := call.b2bua.legs[legKey("alice@192.0.2.1", call.alice.localTag)]
+	inbound.GetCall().Terminate()
+	if byes := call.provider.sent[sent:]; len(byes) != 2 || byes[0].GetMethod() != message.BYE || byes[1].GetMethod() != message.BYE ||
+		!inbound.GetCall().IsTerminated() || len(call.listener.terminated) != 1 {
+		t.Error("call not terminated", byes)
+	}
+}
+
+func TestB2BUARewriteFailure(t *testing.T) {
+	// an offer that cannot be rewritten is rejected with 488
+	rewriter := &fakeRewriter{reject: "m=video"}
+	call := newConnectedCall(t, rewriter)
+	reInvite := bobRequest(t, call.invite, message.INVITE, 2, sdpOffer("192.0.2.2", 5002)+"m=video 5004 RTP/AVP 31\r\n")
+	transaction := &fakeServerTransaction{request: reInvite}
+	sent := len(call.provider.sent)
+	call.b2bua.ProcessRequest(reInvite, transaction)
+	if transaction.lastResponse(t).GetStatusCode() != message.NOT_ACCEPTABLE_HERE || len(call.provider.sent) != sent {
+		t.Fatal("offer not rejected")
+	}
+
+	// an answer that cannot be rewritten ends the call, as alice already
+	// has the new session
+	reInvite = bobRequest(t, call.invite, message.INVITE, 3, sdpOffer("192.0.2.2", 5002))
+	transaction = &fakeServerTransaction{request: reInvite}
+	call.b2bua.ProcessRequest(reInvite, transaction)
+	forward := call.provider.lastSent(t)
+	sent = len(call.provider.sent)
+	call.b2bua.ProcessResponse(respond(t, forward, message.OK, "", sdpOffer("192.0.2.1", 4002)+"m=video 4004 RTP/AVP 31\r\n"))
+	if transaction.lastResponse(t).GetStatusCode() != message.SERVER_INTERNAL_ERROR {
+		t.Error("answer passed on", transaction.lastResponse(t))
+	}
+	if len(call.alice.acks) != 1 || call.alice.acks[0].GetCSeqNumber() != forward.GetCSeqNumber() {
+		t.Error("2xx of alice not acknowledged", call.alice.acks)
+	}
+	byes := call.provider.sent[sent:]
+	if len(byes) != 2 || byes[0].GetMethod() != message.BYE || byes[1].GetMethod() != message.BYE ||
+		len(call.listener.terminated) != 1 || len(rewriter.terminated) != 1 {
+		t.Error("call not ended on both legs", byes)
+	}
+
+	// so does an answer in an ACK
+	call = newConnectedCall(t, &fakeRewriter{reject: "m=video"})
+	reInvite = bobRequest(t, call.invite, message.INVITE, 2, "")
+	transaction = &fakeServerTransaction{request: reInvite}
+	call.b2bua.ProcessRequest(reInvite, transaction)
+	call.b2bua.ProcessResponse(respond(t, call.provider.lastSent(t), message.OK, "", sdpOffer("192.0.2.1", 4002)))
+	sent = len(call.provider.sent)
+	call.b2bua.ProcessRequest(bobRequest(t, call.invite, message.ACK, 2, sdpOffer("192.0.2.2", 5002)+"m=video 5004 RTP/AVP 31\r\n"), nil)
+	if len(call.alice.acks) != 1 || len(call.provider.sent) != sent+2 || len(call.listener.terminated) != 1 {
+		t.Error("call not ended on an answer in an ACK")
+	}
+}
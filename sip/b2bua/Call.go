@@ -0,0 +1,542 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Call.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package b2bua
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+)
+
+/**
+ * A call of a B2BUA: the inbound leg created by an INVITE and the outbound
+ * legs the application connected it to.
+ */
+type Call struct {
+	b2bua *B2BUA
+
+	inbound         *Leg
+	outbound        []*Leg
+	connected       *Leg
+	bestResponse    message.Response
+	terminated      bool
+	applicationData interface{}
+}
+
+/** Connects the call to target: a new INVITE is sent to target in a new
+ * outbound leg, with the display names and URIs of the From and To of the
+ * INVITE of the inbound leg, its body and its forwarded headers, and the
+ * Call-ID, From tag, CSeq, Via and Contact of the B2BUA. A call can be
+ * connected to several targets until one of them answers.
+ */
+func (this *Call) Connect(target address.URI) (leg *Leg, SipException error) {
+	b2bua := this.b2bua
+	if this.IsTerminated() || this.GetConnectedLeg() != nil {
+		return nil, errors.New("SipException: the call is already answered or terminated")
+	}
+	caller := this.inbound.request
+	uri, err := copyURI(target)
+	if err != nil {
+		return nil, err
+	}
+	fromAddress, err := copyAddress(caller.GetFrom().GetAddress())
+	if err != nil {
+		return nil, err
+	}
+	toAddress, err := copyAddress(caller.GetTo().GetAddress())
+	if err != nil {
+		return nil, err
+	}
+	viaList, err := b2bua.createVia()
+	if err != nil {
+		return nil, err
+	}
+
+	invite := message.NewSIPRequest()
+	invite.SetMethod(message.INVITE)
+	invite.SetRequestURI(uri)
+	invite.SetVia(viaList)
+	from := header.NewFrom()
+	from.SetAddress(fromAddress)
+	from.SetTag(sip.NewToken(8))
+	invite.SetFrom(from)
+	to := header.NewTo()
+	to.SetAddress(toAddress)
+	invite.SetTo(to)
+	callId := b2bua.provider.GetNewCallId()
+	invite.SetCallId(callId)
+	invite.SetCSeq(header.NewCSeq(1, message.INVITE))
+	maxForwards := header.NewMaxForwards()
+	maxForwards.SetMaxForwards(70)
+	if callerMaxForwards := caller.GetMaxForwards(); callerMaxForwards != nil {
+		maxForwards.SetMaxForwards(callerMaxForwards.GetMaxForwards() - 1)
+	}
+	invite.SetMaxForwards(maxForwards)
+	invite.SetHeader(b2bua.createContact())
+	for _, name := range forwardedHeaders {
+		if h := caller.GetHeader(name); h != nil {
+			invite.SetHeader(h)
+		}
+	}
+
+	leg = &Leg{
+		call:     this,
+		request:  invite,
+		callId:   callId.GetCallId(),
+		localTag: invite.GetFromTag(),
+	}
+	if b := rewriteBody(b2bua.getRewriter(), this.inbound, leg, caller); b != nil {
+		if b.err != nil {
+			return nil, b.err
+		}
+		invite.SetContent(b.content, b.contentType)
+	}
+
+	b2bua.mutex.Lock()
+	defer b2bua.unlock()
+	if this.terminated || this.connected != nil {
+		return nil, errors.New("SipException: the call is already answered or terminated")
+	}
+	if err = b2bua.sendRequest(leg, invite, nil, nil); err != nil {
+		return nil, err
+	}
+	leg.transaction = b2bua.transactions[transactionKey(invite)].transaction
+	this.outbound = append(this.outbound, leg)
+	b2bua.legs[leg.key()] = leg
+	return leg, nil
+}
+
+/** Rejects the call with the given final status code, cancelling its
+ * outbound legs. Only a call not answered yet can be rejected.
+ */
+func (this *Call) Reject(statusCode int) (SipException error) {
+	this.b2bua.mutex.Lock()
+	defer this.b2bua.unlock()
+
+	if this.terminated || this.connected != nil {
+		return errors.New("SipException: the call is already answered or terminated")
+	}
+	if statusCode < message.MULTIPLE_CHOICES {
+		return errors.New("SipException: not an error status code")
+	}
+	return this.reject(statusCode, "")
+}
+
+/** Terminates the call: a BYE is sent on both legs of an answered call,
+ * while a call not answered yet is rejected with 480 and its outbound legs
+ * cancelled.
+ */
+func (this *Call) Terminate() (SipException error) {
+	this.b2bua.mutex.Lock()
+	defer this.b2bua.unlock()
+
+	if this.terminated {
+		return nil
+	}
+	return this.hangUp()
+}
+
+/** Returns the inbound leg of the call.
+ */
+func (this *Call) GetInboundLeg() *Leg {
+	return this.inbound
+}
+
+/** Returns the outbound legs of the call, in the order they were created.
+ */
+func (this *Call) GetOutboundLegs() []*Leg {
+	this.b2bua.mutex.Lock()
+	defer this.b2bua.mutex.Unlock()
+	return append([]*Leg(nil), this.outbound...)
+}
+
+/** Returns the outbound leg which answered the call, or nil if the call was
+ * not answered.
+ */
+func (this *Call) GetConnectedLeg() *Leg {
+	this.b2bua.mutex.Lock()
+	defer this.b2bua.mutex.Unlock()
+	return this.connected
+}
+
+/** Returns true if the call is terminated.
+ */
+func (this *Call) IsTerminated() bool {
+	this.b2bua.mutex.Lock()
+	defer this.b2bua.mutex.Unlock()
+	return this.terminated
+}
+
+/** Sets application data attached to the call.
+ */
+func (this *Call) SetApplicationData(applicationData interface{}) {
+	this.b2bua.mutex.Lock()
+	defer this.b2bua.mutex.Unlock()
+	this.applicationData = applicationData
+}
+
+/** Returns the application data attached to the call.
+ */
+func (this *Call) GetApplicationData() interface{} {
+	this.b2bua.mutex.Lock()
+	defer this.b2bua.mutex.Unlock()
+	return this.applicationData
+}
+
+/** Processes a response to the INVITE of an outbound leg, with its body
+ * rewritten as b for the caller. Provisional responses are forwarded to the
+ * caller, the first 2xx connects the call and cancels the other outbound
+ * legs, and once all of them failed the best final response is returned to
+ * the caller.
+ */
+func (this *Call) processInviteResponse(leg *Leg, response message.Response, b *body) {
+	statusCode := response.GetStatusCode()
+	if tag := getToTag(response); tag != "" && statusCode < message.MULTIPLE_CHOICES {
+		leg.remoteTag = tag
+		leg.getDialog()
+	}
+
+	if statusCode < message.OK {
+		leg.provisional = true
+		switch {
+		case leg.cancelled:
+			this.cancel(leg)
+		case statusCode != message.TRYING && this.connected == nil && !this.terminated:
+			if r, err := this.createInboundResponse(leg, response, b); err == nil {
+				this.sendInboundResponse(r)
+			}
+		}
+		return
+	}
+
+	if statusCode < message.MULTIPLE_CHOICES {
+		if leg.cancelled || this.connected != nil || this.terminated {
+			// the leg answered too late
+			this.dropLeg(leg)
+			return
+		}
+		r, err := this.createInboundResponse(leg, response, b)
+		if err != nil {
+			this.dropLeg(leg)
+			response = leg.request.CreateResponse(message.NOT_ACCEPTABLE_HERE)
+		} else {
+			this.connected = leg
+			this.inbound.ackPeer = leg
+			this.inbound.ackCSeq = leg.request.GetCSeqNumber()
+			for _, other := range this.outbound {
+				if other != leg {
+					this.cancel(other)
+				}
+			}
+			this.sendInboundResponse(r)
+			return
+		}
+	}
+
+	leg.terminated = true
+	delete(this.b2bua.legs, leg.key())
+	if this.connected != nil || this.terminated {
+		return
+	}
+	if this.bestResponse == nil || responseClass(response) < responseClass(this.bestResponse) {
+		this.bestResponse = response
+	}
+	if statusCode >= message.BUSY_EVERYWHERE {
+		// a global failure ends the search
+		this.reject(statusCode, response.GetReasonPhrase())
+		return
+	}
+	for _, other := range this.outbound {
+		if !other.terminated {
+			return
+		}
+	}
+	this.reject(this.bestResponse.GetStatusCode(), this.bestResponse.GetReasonPhrase())
+}
+
+/** Creates the response to the INVITE of the inbound leg forwarding the
+ * response received on leg, with its body rewritten as b.
+ */
+func (this *Call) createInboundResponse(leg *Leg, response message.Response, b *body) (*message.SIPResponse, error) {
+	r := this.inbound.request.CreateResponse(response.GetStatusCode())
+	r.SetReasonPhrase(response.GetReasonPhrase())
+	r.SetToTag(this.inbound.localTag)
+	if response.GetStatusCode() < message.MULTIPLE_CHOICES {
+		r.SetHeader(this.b2bua.createContact())
+	}
+	if b != nil {
+		if b.err != nil {
+			return nil, b.err
+		}
+		r.SetContent(b.content, b.contentType)
+	}
+	return r, nil
+}
+
+func (this *Call) sendInboundResponse(response *message.SIPResponse) {
+	if transaction, ok := this.inbound.transaction.(sip.ServerTransaction); ok {
+		transaction.SendResponse(response)
+	}
+}
+
+/** Answers the INVITE of the inbound leg with a final error response, with
+ * the standard reason phrase if reasonPhrase is empty, cancels the outbound
+ * legs and terminates the call.
+ */
+func (this *Call) reject(statusCode int, reasonPhrase string) error {
+	r := this.inbound.request.CreateResponse(statusCode)
+	if reasonPhrase != "" {
+		r.SetReasonPhrase(reasonPhrase)
+	}
+	r.SetToTag(this.inbound.localTag)
+	for _, leg := range this.outbound {
+		this.cancel(leg)
+	}
+	this.finish()
+	if transaction, ok := this.inbound.transaction.(sip.ServerTransaction); ok {
+		return transaction.SendResponse(r)
+	}
+	return nil
+}
+
+/** Cancels the INVITE of an outbound leg not answered yet. The CANCEL is
+ * sent once a provisional response was received (RFC 3261 section 9.1).
+ */
+func (this *Call) cancel(leg *Leg) {
+	if leg.terminated || leg == this.connected || leg.cancelSent {
+		return
+	}
+	leg.cancelled = true
+	if !leg.provisional {
+		return
+	}
+	transaction, ok := leg.transaction.(sip.ClientTransaction)
+	if !ok {
+		return
+	}
+	cancel, err := transaction.CreateCancel()
+	if err != nil {
+		return
+	}
+	ct, err := this.b2bua.provider.GetNewClientTransaction(cancel)
+	if err != nil {
+		return
+	}
+	if ct.SendRequest() == nil {
+		leg.cancelSent = true
+	}
+}
+
+/** Acknowledges and ends with a BYE the 2xx of an outbound leg which is not
+ * connected to the call.
+ */
+func (this *Call) dropLeg(leg *Leg) {
+	this.b2bua.sendAck(leg, leg.request.GetCSeqNumber(), "", nil)
+	this.b2bua.sendBye(leg)
+	leg.terminated = true
+	delete(this.b2bua.legs, leg.key())
+}
+
+/** Processes a BYE received on leg: the BYE is sent on the other leg of an
+ * answered call. A BYE in an early dialog ends the leg as if it had failed.
+ */
+func (this *Call) terminate(leg *Leg) {
+	if this.terminated {
+		return
+	}
+	if this.connected == nil {
+		if leg.inbound {
+			this.reject(message.REQUEST_TERMINATED, "")
+		} else {
+			this.cancel(leg)
+			this.processInviteResponse(leg, leg.request.CreateResponse(message.REQUEST_TERMINATED), nil)
+		}
+		return
+	}
+	if leg == this.inbound {
+		this.b2bua.sendBye(this.connected)
+	} else {
+		this.b2bua.sendBye(this.inbound)
+	}
+	this.finish()
+}
+
+/** Ends the call with a BYE on both legs once it is answered, or else
+ * rejects it with 480.
+ */
+func (this *Call) hangUp() error {
+	if this.terminated {
+		return nil
+	}
+	if this.connected == nil {
+		return this.reject(message.TEMPORARILY_UNAVAILABLE, "")
+	}
+	this.b2bua.sendBye(this.inbound)
+	this.b2bua.sendBye(this.connected)
+	this.finish()
+	return nil
+}
+
+/** Marks the call terminated, forgets its legs and queues the notification
+ * of the listener.
+ */
+func (this *Call) finish() {
+	if this.terminated {
+		return
+	}
+	this.terminated = true
+	this.inbound.terminated = true
+	delete(this.b2bua.legs, this.inbound.key())
+	for _, leg := range this.outbound {
+		if leg.cancelled && !leg.cancelSent && !leg.terminated {
+			// the leg is kept until its CANCEL can be sent
+			continue
+		}
+		leg.terminated = true
+		delete(this.b2bua.legs, leg.key())
+	}
+	this.b2bua.terminated = append(this.b2bua.terminated, this)
+}
+
+/** Returns the rank of the class of a final response for the choice of the
+ * response returned to the caller (RFC 3261 section 16.7): 6xx first, then
+ * the lowest class.
+ */
+func responseClass(response message.Response) int {
+	class := response.GetStatusCode() / 100
+	if class == 6 {
+		return 0
+	}
+	return class
+}
+
+/**
+ * A leg of a call: the server dialog of the inbound INVITE or the client
+ * dialog of an outbound one.
+ */
+type Leg struct {
+	call *Call
+
+	inbound     bool
+	request     *message.SIPRequest
+	transaction sip.Transaction
+	dialog      sip.Dialog
+	callId      string
+	localTag    string
+	remoteTag   string
+	provisional bool
+	cancelled   bool
+	cancelSent  bool
+	terminated  bool
+	// the leg and CSeq number of the 2xx waiting for the ACK of this leg
+	ackPeer *Leg
+	ackCSeq int
+}
+
+/** Returns the call of the leg.
+ */
+func (this *Leg) GetCall() *Call {
+	return this.call
+}
+
+/** Returns true for the inbound leg of a call.
+ */
+func (this *Leg) IsInbound() bool {
+	return this.inbound
+}
+
+/** Returns the INVITE which created the leg, received for the inbound leg
+ * and sent for an outbound leg.
+ */
+func (this *Leg) GetRequest() *message.SIPRequest {
+	return this.request
+}
+
+/** Returns the dialog of the leg, or nil if it is not established yet.
+ */
+func (this *Leg) GetDialog() sip.Dialog {
+	this.call.b2bua.mutex.Lock()
+	defer this.call.b2bua.mutex.Unlock()
+	return this.getDialog()
+}
+
+/** Returns the dialog of the leg, taken from the transaction of its INVITE
+ * once the stack created it.
+ */
+func (this *Leg) getDialog() sip.Dialog {
+	if this.dialog == nil && this.transaction != nil {
+		this.dialog = this.transaction.GetDialog()
+	}
+	return this.dialog
+}
+
+/** Returns the Call-ID of the dialog of the leg.
+ */
+func (this *Leg) GetCallId() string {
+	return this.callId
+}
+
+/** Returns the tag of the B2BUA in the dialog of the leg.
+ */
+func (this *Leg) GetLocalTag() string {
+	return this.localTag
+}
+
+/** Returns the tag of the remote party in the dialog of the leg, empty
+ * until it is known.
+ */
+func (this *Leg) GetRemoteTag() string {
+	this.call.b2bua.mutex.Lock()
+	defer this.call.b2bua.mutex.Unlock()
+	return this.remoteTag
+}
+
+/** Returns true if the leg is terminated.
+ */
+func (this *Leg) IsTerminated() bool {
+	this.call.b2bua.mutex.Lock()
+	defer this.call.b2bua.mutex.Unlock()
+	return this.terminated
+}
+
+/** Returns the To tag of msg, or an empty string if it has none.
+ */
+func getToTag(msg message.Message) string {
+	if to, ok := msg.GetHeader(core.SIPHeaderNames_TO).(header.ToHeader); ok {
+		return to.GetTag()
+	}
+	return ""
+}
+
+func (this *Leg) key() string {
+	return legKey(this.callId, this.localTag)
+}
+
+/** Returns the leg requests received on this one are forwarded to: the
+ * connected leg for the inbound leg, or its only outbound leg while the call
+ * is not answered, and the inbound leg for an outbound leg.
+ */
+func (this *Leg) getPeer() *Leg {
+	call := this.call
+	if !this.inbound {
+		if call.connected == nil || call.connected == this {
+			return call.inbound
+		}
+		return nil
+	}
+	if call.connected != nil {
+		return call.connected
+	}
+	if len(call.outbound) == 1 {
+		return call.outbound[0]
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : MediaRelay.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package b2bua
+
+import (
+	"errors"
+	"gosips/rtp"
+	"gosips/sdp"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * Anchors the media of the calls of a B2BUA on an rtp.Relay, to let them
+ * traverse NATs. Set as SessionDescriptionRewriter of the B2BUA, it relays
+ * each media stream of a call through a stream of the relay, whose endpoint
+ * 0 faces the caller and endpoint 1 the callee: the connection address and
+ * ports of each media description passed from one leg to the other are
+ * those of the party sending it for the endpoint facing that party, and are
+ * replaced with the address and ports of the endpoint facing the other
+ * party. The relay then latches onto the addresses the parties actually
+ * send from.
+ * <p>
+ * The streams of a call are closed once it is terminated, and a call whose
+ * media streams all timed out on the relay is terminated. A stream does not
+ * time out while a party holds it with the unspecified address or the
+ * inactive direction.
+ */
+type MediaRelay struct {
+	mutex sync.Mutex
+
+	relay   *rtp.Relay
+	address string
+	calls   map[*Call][]*rtp.RelayStream
+}
+
+/** Creates a media relay allocating its streams on relay and announcing
+ * address, the address at which the parties reach relay, in the session
+ * descriptions. The media relay becomes the RelayListener of relay.
+ */
+func NewMediaRelay(relay *rtp.Relay, address string) *MediaRelay {
+	this := &MediaRelay{
+		relay:   relay,
+		address: address,
+		calls:   make(map[*Call][]*rtp.RelayStream),
+	}
+	relay.SetRelayListener(this)
+	return this
+}
+
+/** Rewrites a session description passed from one leg of a call to the
+ * other so that the media of the call flows through the relay. A media
+ * description with port 0, which rejects or disables its stream, is left
+ * unchanged, and so is the unspecified connection address of a stream put
+ * on hold. A stream that timed out while the other streams of the call went
+ * on is replaced with a new one.
+ */
+func (this *MediaRelay) RewriteSessionDescription(from *Leg, to *Leg, content string) (string, error) {
+	sd, err := sdp.ParseSessionDescription(content)
+	if err != nil {
+		return "", err
+	}
+	fromSide, toSide := 1, 0
+	if from.IsInbound() {
+		fromSide, toSide = 0, 1
+	}
+	// the connection addresses are resolved before locking
+	mds := sd.GetMediaDescriptions()
+	remoteRTP := make([]*rtp.IPAddress, len(mds))
+	remoteRTCP := make([]*rtp.IPAddress, len(mds))
+	onHold := make([]bool, len(mds))
+	for i, md := range mds {
+		if md.GetPort() == 0 {
+			continue
+		}
+		if remoteRTP[i], remoteRTCP[i], err = getRemoteAddresses(sd, md); err != nil {
+			return "", err
+		}
+		onHold[i] = isOnHold(sd, md)
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	call := from.GetCall()
+	if call.IsTerminated() {
+		return "", errors.New("SipException: call terminated")
+	}
+	streams := this.calls[call]
+	for i, md := range mds {
+		if md.GetPort() == 0 {
+			continue
+		}
+		for len(streams) <= i {
+			streams = append(streams, nil)
+		}
+		if streams[i] == nil || streams[i].IsClosed() {
+			stream, err := this.relay.CreateStream()
+			if err != nil {
+				return "", errors.New("SipException: no media relay port available")
+			}
+			streams[i] = stream
+			this.calls[call] = streams
+		}
+		streams[i].GetEndpoint(fromSide).SetRemoteAddress(remoteRTP[i], remoteRTCP[i])
+		streams[i].GetEndpoint(fromSide).SetOnHold(onHold[i])
+
+		endpoint := streams[i].GetEndpoint(toSide)
+		md.SetPort(int(endpoint.GetRTPPort()))
+		if remoteRTP[i] != nil {
+			md.SetConnection(sdp.NewConnection(this.address))
+		}
+		if md.HasAttribute(sdp.RTCP_ATTRIBUTE) {
+			md.SetAttribute(sdp.NewAttribute(sdp.RTCP_ATTRIBUTE, strconv.Itoa(int(endpoint.GetRTCPPort()))))
+		}
+	}
+	// the media descriptions on hold may use the session connection
+	if connection := sd.GetConnection(); connection != nil && !isUnspecified(connection) {
+		sd.SetConnection(sdp.NewConnection(this.address))
+	}
+	return sd.String(), nil
+}
+
+/** Returns the streams of the relay carrying the media of call, in the
+ * order of the media descriptions; the stream of a media description never
+ * relayed, having always had port 0, is nil.
+ */
+func (this *MediaRelay) GetStreams(call *Call) []*rtp.RelayStream {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return append([]*rtp.RelayStream(nil), this.calls[call]...)
+}
+
+/** Closes the streams of a call once it is terminated. The B2BUA calls it
+ * for each call terminated.
+ */
+func (this *MediaRelay) CallTerminated(call *Call) {
+	this.mutex.Lock()
+	streams := this.calls[call]
+	delete(this.calls, call)
+	this.mutex.Unlock()
+	for _, stream := range streams {
+		if stream != nil {
+			stream.Close()
+		}
+	}
+}
+
+/** Terminates the call of a stream that timed out once its other streams
+ * timed out as well, and closes its streams at once, as the call may
+ * already be terminated.
+ */
+func (this *MediaRelay) StreamTimedOut(stream *rtp.RelayStream) {
+	this.mutex.Lock()
+	var call *Call
+	for c, streams := range this.calls {
+		for _, s := range streams {
+			if s == stream {
+				call = c
+			}
+		}
+	}
+	idle := true
+	for _, s := range this.calls[call] {
+		if s != nil && !s.IsClosed() {
+			idle = false
+		}
+	}
+	this.mutex.Unlock()
+	if call != nil && idle {
+		this.CallTerminated(call)
+		call.Terminate()
+	}
+}
+
+/** Returns the RTP and RTCP addresses announced by a media description: its
+ * connection address with its port and either the port of its rtcp
+ * attribute (RFC 3605) or the next one, or no RTCP address if RTCP is
+ * multiplexed (RFC 5761). An unspecified address, as used to put a stream on
+ * hold, gives no address at all.
+ */
+func getRemoteAddresses(sd *sdp.SessionDescription, md *sdp.MediaDescription) (remoteRTP, remoteRTCP *rtp.IPAddress, SipException error) {
+	connection := sd.GetMediaConnection(md)
+	if connection == nil {
+		return nil, nil, errors.New("SipException: no connection address for media " + md.GetMedia())
+	}
+	host := getConnectionHost(connection)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		addr, err := net.ResolveIPAddr("ip", host)
+		if err != nil {
+			return nil, nil, errors.New("SipException: unknown connection address " + host)
+		}
+		ip = addr.IP
+	}
+	if ip.IsUnspecified() {
+		return nil, nil, nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	remoteRTP = rtp.NewIPAddress(ip, uint16(md.GetPort()))
+	if md.HasAttribute(sdp.RTCP_MUX_ATTRIBUTE) {
+		return remoteRTP, nil, nil
+	}
+	rtcpPort := md.GetPort() + 1
+	if a := md.GetAttribute(sdp.RTCP_ATTRIBUTE); a != nil {
+		if fields := strings.Fields(a.GetValue()); len(fields) > 0 {
+			if port, err := strconv.Atoi(fields[0]); err == nil && port > 0 && port <= 65535 {
+				rtcpPort = port
+			}
+		}
+	}
+	return remoteRTP, rtp.NewIPAddress(ip, uint16(rtcpPort)), nil
+}
+
+/** Returns true if a media description puts its stream on hold with the
+ * unspecified connection address or the inactive direction, its own or else
+ * that of the session.
+ */
+func isOnHold(sd *sdp.SessionDescription, md *sdp.MediaDescription) bool {
+	if connection := sd.GetMediaConnection(md); connection != nil && isUnspecified(connection) {
+		return true
+	}
+	for _, direction := range []string{"sendrecv", "sendonly", "recvonly", "inactive"} {
+		if md.HasAttribute(direction) {
+			return direction == "inactive"
+		}
+	}
+	return sd.HasAttribute("inactive")
+}
+
+/** Returns true if connection has the unspecified address 0.0.0.0 or ::,
+ * which puts the streams using it on hold (RFC 2543).
+ */
+func isUnspecified(connection *sdp.Connection) bool {
+	ip := net.ParseIP(getConnectionHost(connection))
+	return ip != nil && ip.IsUnspecified()
+}
+
+/** Returns the address of connection without the TTL and number of
+ * addresses of a multicast address.
+ */
+func getConnectionHost(connection *sdp.Connection) string {
+	host := connection.GetAddress()
+	if i := strings.Index(host, "/"); i != -1 {
+		host = host[:i]
+	}
+	return host
+}
@@ -0,0 +1,146 @@
+package b2bua
+
+import (
+	"gosips/rtp"
+	"gosips/sip/message"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMediaRelay(t *testing.T) {
+	relay := rtp.NewRelay(net.IPv4(127, 0, 0, 1), 42000, 42100)
+	defer relay.Close()
+	mediaRelay := NewMediaRelay(relay, "203.0.113.1")
+
+	// the offer of alice and the answer of bob are anchored on the relay
+	call := newConnectedCall(t, mediaRelay)
+	inbound := call.b2bua.legs[legKey("alice@192.0.2.1", call.alice.localTag)]
+	streams := mediaRelay.GetStreams(inbound.GetCall())
+	if len(streams) != 1 {
+		t.Fatal("bad streams", streams)
+	}
+	alice, bob := streams[0].GetEndpoint(0), streams[0].GetEndpoint(1)
+	if offer := call.invite.String(); !strings.Contains(offer, "c=IN IP4 203.0.113.1") ||
+		!strings.Contains(offer, "m=audio "+strconv.Itoa(int(bob.GetRTPPort()))+" ") {
+		t.Error("offer not anchored", offer)
+	}
+	if answer := call.inbound.lastResponse(t).String(); !strings.Contains(answer, "c=IN IP4 203.0.113.1") ||
+		!strings.Contains(answer, "m=audio "+strconv.Itoa(int(alice.GetRTPPort()))+" ") {
+		t.Error("answer not anchored", answer)
+	}
+	if alice.GetRemoteAddress().GetPort() != 4000 || alice.GetRemoteRTCPAddress().GetPort() != 4001 ||
+		bob.GetRemoteAddress().GetPort() != 5000 {
+		t.Error("bad remote addresses", alice.GetRemoteAddress(), bob.GetRemoteAddress())
+	}
+
+	// bob puts the call on hold with the unspecified address, which alice
+	// gets as is
+	reInvite := bobRequest(t, call.invite, message.INVITE, 2, sdpOffer("0.0.0.0", 5002))
+	transaction := &fakeServerTransaction{request: reInvite}
+	call.b2bua.ProcessRequest(reInvite, transaction)
+	forward := call.provider.lastSent(t)
+	if offer := forward.String(); !strings.Contains(offer, "c=IN IP4 0.0.0.0") || strings.Contains(offer, "203.0.113.1") {
+		t.Error("hold not passed on", offer)
+	}
+	if bob.GetRemoteAddress() != nil {
+		t.Error("address of bob on hold", bob.GetRemoteAddress())
+	}
+	call.b2bua.ProcessResponse(respond(t, forward, message.OK, "", sdpOffer("192.0.2.1", 4000)))
+	call.b2bua.ProcessRequest(bobRequest(t, call.invite, message.ACK, 2, ""), nil)
+	if len(mediaRelay.GetStreams(inbound.GetCall())) != 1 {
+		t.Error("stream not reused")
+	}
+
+	// the streams are closed with the call
+	inbound.GetCall().Terminate()
+	if !streams[0].IsClosed() || len(relay.GetStreams()) != 0 || len(mediaRelay.GetStreams(inbound.GetCall())) != 0 {
+		t.Error("streams of a terminated call not closed")
+	}
+}
+
+// terminations passes the calls terminated to a channel, for the calls
+// terminated by the relay.
+type terminations struct {
+	*fakeListener
+	calls chan *Call
+}
+
+func (this terminations) CallTerminated(call *Call) {
+	this.calls <- call
+}
+
+func TestMediaRelayTimeout(t *testing.T) {
+	relay := rtp.NewRelay(net.IPv4(127, 0, 0, 1), 42200, 42300)
+	defer relay.Close()
+	relay.SetTimeout(100 * time.Millisecond)
+	mediaRelay := NewMediaRelay(relay, "203.0.113.1")
+
+	// a call without media is ended once its stream times out
+	call := newConnectedCall(t, mediaRelay)
+	listener := terminations{call.listener, make(chan *Call, 1)}
+	call.b2bua.SetB2BUAListener(listener)
+	select {
+	case terminated := <-listener.calls:
+		if !terminated.IsTerminated() || len(relay.GetStreams()) != 0 || len(mediaRelay.GetStreams(terminated)) != 0 {
+			t.Error("call not released")
+		}
+		if sent := call.provider.sent; len(sent) != 3 || sent[1].GetMethod() != message.BYE || sent[2].GetMethod() != message.BYE {
+			t.Error("call not ended on both legs", call.provider.sent)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("call without media not terminated")
+	}
+}
+
+func TestMediaRelayHold(t *testing.T) {
+	relay := rtp.NewRelay(net.IPv4(127, 0, 0, 1), 42400, 42500)
+	defer relay.Close()
+	relay.SetTimeout(150 * time.Millisecond)
+	mediaRelay := NewMediaRelay(relay, "203.0.113.1")
+
+	call := newConnectedCall(t, mediaRelay)
+	listener := terminations{call.listener, make(chan *Call, 1)}
+	call.b2bua.SetB2BUAListener(listener)
+	inbound := call.b2bua.legs[legKey("alice@192.0.2.1", call.alice.localTag)]
+	reInvite := func(cseq int, offer, answer string) {
+		request := bobRequest(t, call.invite, message.INVITE, cseq, offer)
+		call.b2bua.ProcessRequest(request, &fakeServerTransaction{request: request})
+		call.b2bua.ProcessResponse(respond(t, call.provider.lastSent(t), message.OK, "", answer))
+		call.b2bua.ProcessRequest(bobRequest(t, call.invite, message.ACK, cseq, ""), nil)
+	}
+	notTerminated := func(context string) {
+		select {
+		case <-listener.calls:
+			t.Fatal("call terminated", context)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	// a call held with the unspecified address outlasts the timeout
+	reInvite(2, sdpOffer("0.0.0.0", 5002), sdpOffer("192.0.2.1", 4000)+"a=recvonly\r\n")
+	notTerminated("on hold")
+
+	// and so does a call held with the inactive direction, whose idle video
+	// stream times out alone
+	reInvite(3, sdpOffer("192.0.2.2", 5000)+"a=inactive\r\nm=video 5004 RTP/AVP 96\r\n",
+		sdpOffer("192.0.2.1", 4000)+"a=inactive\r\nm=video 4002 RTP/AVP 96\r\n")
+	notTerminated("with an inactive stream")
+	if streams := mediaRelay.GetStreams(inbound.GetCall()); len(streams) != 2 || streams[0].IsClosed() || !streams[1].IsClosed() {
+		t.Error("bad streams on hold", streams)
+	}
+
+	// once resumed, the call is ended when its streams are all idle
+	reInvite(4, sdpOffer("192.0.2.2", 5000)+"m=video 5004 RTP/AVP 96\r\n",
+		sdpOffer("192.0.2.1", 4000)+"m=video 4002 RTP/AVP 96\r\n")
+	select {
+	case terminated := <-listener.calls:
+		if !terminated.IsTerminated() || len(mediaRelay.GetStreams(terminated)) != 0 {
+			t.Error("call not released")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("resumed call without media not terminated")
+	}
+}
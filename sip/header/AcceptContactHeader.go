@@ -0,0 +1,44 @@
+package header
+
+/**
+ * The Accept-Contact header field (RFC 3841) carries a caller preference: a
+ * feature predicate describing the User Agents the caller would like the
+ * request to reach, e.g. those supporting video. Its parameters are the
+ * feature tags of RFC 3840 and:
+ * <ul>
+ * <li> require - contacts not matching the predicate are discarded.
+ * <li> explicit - only contacts explicitly indicating the features match.
+ * </ul>
+ * For Example:<br>
+ * <code>Accept-Contact: *;video;methods="INVITE";require;explicit</code>
+ */
+type AcceptContactHeader interface {
+	ParametersHeader
+
+	/**
+	 * Sets a feature tag of the predicate, e.g. "video" or "+sip.actor". An
+	 * empty value makes it a boolean feature set to true; other values are
+	 * quoted tag value lists such as "INVITE,BYE", "!msg-taker" or "#>=2".
+	 */
+	SetFeatureTag(name, value string)
+
+	/**
+	 * Sets or removes the require parameter.
+	 */
+	SetRequire(require bool)
+
+	/**
+	 * Returns true if the require parameter is present.
+	 */
+	IsRequire() bool
+
+	/**
+	 * Sets or removes the explicit parameter.
+	 */
+	SetExplicit(explicit bool)
+
+	/**
+	 * Returns true if the explicit parameter is present.
+	 */
+	IsExplicit() bool
+}
@@ -0,0 +1,86 @@
+package header
+
+import (
+	"bytes"
+	"gosips/core"
+)
+
+/**
+* AcceptContact SIP Header (RFC 3841).
+ */
+type AcceptContact struct {
+	Parameters
+}
+
+/** Default constructor.
+ */
+func NewAcceptContact() *AcceptContact {
+	this := &AcceptContact{}
+	this.Parameters.super(core.SIPHeaderNames_ACCEPT_CONTACT)
+	return this
+}
+
+func (this *AcceptContact) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/** Encode the body of this header (the stuff that follows headerName).
+ * A.K.A headerValue.
+ */
+func (this *AcceptContact) EncodeBody() string {
+	return encodeFeaturePredicate(&this.Parameters)
+}
+
+func (this *AcceptContact) SetFeatureTag(name, value string) {
+	setFeatureTag(&this.Parameters, name, value)
+}
+
+func (this *AcceptContact) SetRequire(require bool) {
+	setFlag(&this.Parameters, ParameterNames_REQUIRE, require)
+}
+
+func (this *AcceptContact) IsRequire() bool {
+	return this.HasParameter(ParameterNames_REQUIRE)
+}
+
+func (this *AcceptContact) SetExplicit(explicit bool) {
+	setFlag(&this.Parameters, ParameterNames_EXPLICIT, explicit)
+}
+
+func (this *AcceptContact) IsExplicit() bool {
+	return this.HasParameter(ParameterNames_EXPLICIT)
+}
+
+/** Encodes a feature predicate of the Accept-Contact and Reject-Contact
+ * headers: a wildcard followed by the parameters.
+ */
+func encodeFeaturePredicate(parameters *Parameters) string {
+	var encoding bytes.Buffer
+	encoding.WriteString(core.SIPSeparatorNames_STAR)
+	if parameters.parameters != nil && parameters.parameters.Len() > 0 {
+		encoding.WriteString(core.SIPSeparatorNames_SEMICOLON)
+		encoding.WriteString(parameters.parameters.String())
+	}
+	return encoding.String()
+}
+
+/** Sets a feature tag: a flag for an empty value, a quoted value otherwise.
+ */
+func setFeatureTag(parameters *Parameters, name, value string) {
+	if value == "" {
+		setFlag(parameters, name, true)
+	} else {
+		parameters.RemoveParameter(name)
+		parameters.SetParameter(name, "\""+value+"\"")
+	}
+}
+
+/** Adds a parameter without value, encoded without "=", or removes it.
+ */
+func setFlag(parameters *Parameters, name string, set bool) {
+	parameters.RemoveParameter(name)
+	if set {
+		parameters.SetParameterFromNameValue(core.NewNameValue(name, nil))
+	}
+}
@@ -0,0 +1,18 @@
+package header
+
+import "gosips/core"
+
+/**
+* AcceptContact List of SIP headers (a collection of feature predicates)
+ */
+type AcceptContactList struct {
+	SIPHeaderList
+}
+
+/** Default constructor
+ */
+func NewAcceptContactList() *AcceptContactList {
+	this := &AcceptContactList{}
+	this.SIPHeaderList.super(core.SIPHeaderNames_ACCEPT_CONTACT)
+	return this
+}
@@ -109,4 +109,61 @@ type ContactHeader interface {
 	 */
 
 	GetExpires() int
+
+	/**
+	 * Sets the <code>+sip.instance</code> parameter (RFC 5626), the URN
+	 * identifying the User Agent instance across reboots.
+	 *
+	 * @param instanceId - the URN, without angle brackets.
+	 * @throws ParseException if the instance id is empty.
+	 */
+	SetInstanceId(instanceId string) (ParseException error)
+
+	/**
+	 * Returns the URN of the <code>+sip.instance</code> parameter, or an
+	 * empty string if there is none.
+	 */
+	GetInstanceId() string
+
+	/**
+	 * Sets the <code>reg-id</code> parameter (RFC 5626), distinguishing the
+	 * flows a User Agent instance registers over.
+	 *
+	 * @throws InvalidArgumentException if regId is less than 1.
+	 */
+	SetRegId(regId int) (InvalidArgumentException error)
+
+	/**
+	 * Returns the value of the <code>reg-id</code> parameter, 0 if there is
+	 * none.
+	 */
+	GetRegId() int
+
+	/**
+	 * Sets the <code>pub-gruu</code> parameter (RFC 5627), the public GRUU
+	 * the registrar assigned to the User Agent instance.
+	 *
+	 * @param pubGruu - the GRUU, without quotes.
+	 */
+	SetPubGruu(pubGruu string)
+
+	/**
+	 * Returns the public GRUU of the <code>pub-gruu</code> parameter, or an
+	 * empty string if there is none.
+	 */
+	GetPubGruu() string
+
+	/**
+	 * Sets the <code>temp-gruu</code> parameter (RFC 5627), a temporary GRUU
+	 * the registrar assigned to the User Agent instance.
+	 *
+	 * @param tempGruu - the GRUU, without quotes.
+	 */
+	SetTempGruu(tempGruu string)
+
+	/**
+	 * Returns the temporary GRUU of the <code>temp-gruu</code> parameter, or
+	 * an empty string if there is none.
+	 */
+	GetTempGruu() string
 }
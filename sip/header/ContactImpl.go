@@ -6,6 +6,7 @@ import (
 	"gosips/core"
 	"gosips/sip/address"
 	"strconv"
+	"strings"
 )
 
 /**
@@ -197,3 +198,69 @@ func (this *Contact) SetQValue(q float32) (InvalidArgumentException error) {
 	this.SetParameter(ParameterNames_Q, strconv.FormatFloat(float64(q), 'f', -1, 32))
 	return nil
 }
+
+/** Set the +sip.instance parameter (RFC 5626), the URN identifying the
+ * User Agent instance, e.g. urn:uuid:00000000-0000-1000-8000-000A95A0E128.
+ */
+func (this *Contact) SetInstanceId(instanceId string) (ParseException error) {
+	if instanceId == "" {
+		return errors.New("ParseException: empty instance id")
+	}
+	this.SetParameter(ParameterNames_SIP_INSTANCE, "\"<"+instanceId+">\"")
+	return nil
+}
+
+/** get the +sip.instance parameter without its quotes and angle brackets.
+ * Return an empty string if the parameter has not been set.
+ */
+func (this *Contact) GetInstanceId() string {
+	instanceId := strings.Trim(this.GetParameter(ParameterNames_SIP_INSTANCE), "\"")
+	return strings.TrimSuffix(strings.TrimPrefix(instanceId, "<"), ">")
+}
+
+/** Set the reg-id parameter (RFC 5626), identifying the flow of the
+ * registration.
+ */
+func (this *Contact) SetRegId(regId int) (InvalidArgumentException error) {
+	if regId < 1 {
+		return errors.New("InvalidArgumentException: bad reg-id")
+	}
+	this.SetParameter(ParameterNames_REG_ID, strconv.Itoa(regId))
+	return nil
+}
+
+/** get the reg-id parameter. Return 0 if the parameter has not been set.
+ */
+func (this *Contact) GetRegId() int {
+	regId, err := strconv.Atoi(this.GetParameter(ParameterNames_REG_ID))
+	if err != nil || regId < 1 {
+		return 0
+	}
+	return regId
+}
+
+/** Set the pub-gruu parameter (RFC 5627), the public GRUU of the instance.
+ */
+func (this *Contact) SetPubGruu(pubGruu string) {
+	this.SetParameter(ParameterNames_PUB_GRUU, "\""+pubGruu+"\"")
+}
+
+/** get the pub-gruu parameter without its quotes. Return an empty string if
+ * the parameter has not been set.
+ */
+func (this *Contact) GetPubGruu() string {
+	return strings.Trim(this.GetParameter(ParameterNames_PUB_GRUU), "\"")
+}
+
+/** Set the temp-gruu parameter (RFC 5627), a temporary GRUU of the instance.
+ */
+func (this *Contact) SetTempGruu(tempGruu string) {
+	this.SetParameter(ParameterNames_TEMP_GRUU, "\""+tempGruu+"\"")
+}
+
+/** get the temp-gruu parameter without its quotes. Return an empty string if
+ * the parameter has not been set.
+ */
+func (this *Contact) GetTempGruu() string {
+	return strings.Trim(this.GetParameter(ParameterNames_TEMP_GRUU), "\"")
+}
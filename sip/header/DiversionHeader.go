@@ -0,0 +1,78 @@
+package header
+
+/**
+ * The Diversion header field (RFC 5806) is the legacy way of recording the
+ * diversions of a call, still used by many gateways and voicemail systems.
+ * Each entry holds the address the call was diverted from; the most recent
+ * diversion is the topmost entry. Its parameters are:
+ * <ul>
+ * <li> reason - why the call was diverted, e.g. user-busy or no-answer.
+ * <li> counter - how many diversions the entry stands for, 1 by default.
+ * <li> limit - the number of diversions allowed.
+ * <li> privacy - full, name, uri or off.
+ * <li> screen - yes if the diverting address was verified.
+ * </ul>
+ * For Example:<br>
+ * <code>Diversion: &lt;sip:+15555551002@example.com&gt;;reason=user-busy;counter=1</code>
+ *
+ * @see HistoryInfoHeader
+ */
+type DiversionHeader interface {
+	AddressHeader
+	ParametersHeader
+
+	/**
+	 * Sets the reason of the diversion, e.g. Diversion_USER_BUSY.
+	 */
+	SetReason(reason string) (ParseException error)
+
+	/**
+	 * Gets the reason of the diversion, "" if it has none.
+	 */
+	GetReason() string
+
+	/**
+	 * Sets the number of diversions the entry stands for.
+	 *
+	 * @throws InvalidArgumentException if counter is less than 1.
+	 */
+	SetCounter(counter int) (InvalidArgumentException error)
+
+	/**
+	 * Gets the number of diversions the entry stands for, 1 if it has no
+	 * counter.
+	 */
+	GetCounter() int
+
+	/**
+	 * Sets the number of diversions allowed.
+	 *
+	 * @throws InvalidArgumentException if limit is negative.
+	 */
+	SetLimit(limit int) (InvalidArgumentException error)
+
+	/**
+	 * Gets the number of diversions allowed, -1 if it has no limit.
+	 */
+	GetLimit() int
+
+	/**
+	 * Sets the privacy of the diverting address: full, name, uri or off.
+	 */
+	SetPrivacy(privacy string) (ParseException error)
+
+	/**
+	 * Gets the privacy of the diverting address, "" if it has none.
+	 */
+	GetPrivacy() string
+
+	/**
+	 * Sets whether the diverting address was verified.
+	 */
+	SetScreen(screen bool)
+
+	/**
+	 * Returns true if the diverting address was verified.
+	 */
+	IsScreened() bool
+}
@@ -0,0 +1,140 @@
+package header
+
+import (
+	"bytes"
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"strconv"
+	"strings"
+)
+
+/** The diversion reasons of RFC 5806.
+ */
+const (
+	Diversion_UNKNOWN        = "unknown"
+	Diversion_USER_BUSY      = "user-busy"
+	Diversion_NO_ANSWER      = "no-answer"
+	Diversion_UNAVAILABLE    = "unavailable"
+	Diversion_UNCONDITIONAL  = "unconditional"
+	Diversion_TIME_OF_DAY    = "time-of-day"
+	Diversion_DO_NOT_DISTURB = "do-not-disturb"
+	Diversion_DEFLECTION     = "deflection"
+	Diversion_FOLLOW_ME      = "follow-me"
+	Diversion_OUT_OF_SERVICE = "out-of-service"
+	Diversion_AWAY           = "away"
+)
+
+/** The Diversion header records the diversions of a call (RFC 5806).
+ */
+type Diversion struct {
+	AddressParameters
+}
+
+/** default constructor
+ */
+func NewDiversion() *Diversion {
+	this := &Diversion{}
+	this.AddressParameters.super(core.SIPHeaderNames_DIVERSION)
+	return this
+}
+
+/**  constructor
+ * @param addr address to set
+ */
+func NewDiversionFromAddress(addr address.Address) *Diversion {
+	this := &Diversion{}
+	this.AddressParameters.super(core.SIPHeaderNames_DIVERSION)
+	this.addr = addr
+	return this
+}
+
+func (this *Diversion) SetReason(reason string) (ParseException error) {
+	if reason == "" {
+		return errors.New("ParseException: empty Diversion reason")
+	}
+	return this.SetParameter(ParameterNames_REASON, reason)
+}
+
+func (this *Diversion) GetReason() string {
+	return this.GetParameter(ParameterNames_REASON)
+}
+
+func (this *Diversion) SetCounter(counter int) (InvalidArgumentException error) {
+	if counter < 1 {
+		return errors.New("InvalidArgumentException: bad Diversion counter")
+	}
+	return this.SetParameter(ParameterNames_COUNTER, strconv.Itoa(counter))
+}
+
+func (this *Diversion) GetCounter() int {
+	counter, err := strconv.Atoi(this.GetParameter(ParameterNames_COUNTER))
+	if err != nil || counter < 1 {
+		return 1
+	}
+	return counter
+}
+
+func (this *Diversion) SetLimit(limit int) (InvalidArgumentException error) {
+	if limit < 0 {
+		return errors.New("InvalidArgumentException: bad Diversion limit")
+	}
+	return this.SetParameter(ParameterNames_LIMIT, strconv.Itoa(limit))
+}
+
+func (this *Diversion) GetLimit() int {
+	limit, err := strconv.Atoi(this.GetParameter(ParameterNames_LIMIT))
+	if err != nil || limit < 0 {
+		return -1
+	}
+	return limit
+}
+
+func (this *Diversion) SetPrivacy(privacy string) (ParseException error) {
+	if privacy == "" {
+		return errors.New("ParseException: empty Diversion privacy")
+	}
+	return this.SetParameter(ParameterNames_PRIVACY, privacy)
+}
+
+func (this *Diversion) GetPrivacy() string {
+	return this.GetParameter(ParameterNames_PRIVACY)
+}
+
+func (this *Diversion) SetScreen(screen bool) {
+	if screen {
+		this.SetParameter(ParameterNames_SCREEN, "yes")
+	} else {
+		this.SetParameter(ParameterNames_SCREEN, "no")
+	}
+}
+
+func (this *Diversion) IsScreened() bool {
+	return strings.EqualFold(this.GetParameter(ParameterNames_SCREEN), "yes")
+}
+
+func (this *Diversion) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/** Encode into canonical form.
+ *@return String containing the canonicaly encoded header.
+ */
+func (this *Diversion) EncodeBody() string {
+	var encoding bytes.Buffer
+	addr, _ := this.addr.(*address.AddressImpl)
+	if addr.GetAddressType() == address.ADDRESS_SPEC {
+		encoding.WriteString(core.SIPSeparatorNames_LESS_THAN)
+	}
+	encoding.WriteString(this.addr.String())
+	if addr.GetAddressType() == address.ADDRESS_SPEC {
+		encoding.WriteString(core.SIPSeparatorNames_GREATER_THAN)
+	}
+
+	if this.parameters != nil && this.parameters.Len() > 0 {
+		encoding.WriteString(core.SIPSeparatorNames_SEMICOLON)
+		encoding.WriteString(this.parameters.String())
+	}
+	return encoding.String()
+}
@@ -0,0 +1,27 @@
+package header
+
+/**
+ * The Flow-Timer header field (RFC 5626) is returned by a registrar
+ * supporting outbound in the 2xx response to a REGISTER. It gives the number
+ * of seconds within which the server expects a keep-alive on the flow the
+ * registration was received over; the User Agent sends its keep-alives at an
+ * interval between 80 and 100 percent of it.
+ * <p>
+ * For Example:<br>
+ * <code>Flow-Timer: 120</code>
+ */
+type FlowTimerHeader interface {
+	Header
+
+	/**
+	 * Sets the keep-alive interval in seconds.
+	 *
+	 * @throws InvalidArgumentException if seconds is not positive.
+	 */
+	SetSeconds(seconds int) (InvalidArgumentException error)
+
+	/**
+	 * Gets the keep-alive interval in seconds.
+	 */
+	GetSeconds() int
+}
@@ -0,0 +1,49 @@
+package header
+
+import (
+	"errors"
+	"gosips/core"
+	"strconv"
+)
+
+/**
+* FlowTimer SIP Header.
+ */
+type FlowTimer struct {
+	SIPHeader
+
+	seconds int
+}
+
+/** default constructor
+ */
+func NewFlowTimer() *FlowTimer {
+	this := &FlowTimer{}
+	this.SIPHeader.super(core.SIPHeaderNames_FLOW_TIMER)
+	return this
+}
+
+func (this *FlowTimer) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/**
+ * Return canonical form.
+ * @return String
+ */
+func (this *FlowTimer) EncodeBody() string {
+	return strconv.Itoa(this.seconds)
+}
+
+func (this *FlowTimer) GetSeconds() int {
+	return this.seconds
+}
+
+func (this *FlowTimer) SetSeconds(seconds int) (InvalidArgumentException error) {
+	if seconds <= 0 {
+		return errors.New("InvalidArgumentException: bad Flow-Timer")
+	}
+	this.seconds = seconds
+	return nil
+}
@@ -0,0 +1,64 @@
+package header
+
+/**
+ * The History-Info header field (RFC 7044) records the targets a request was
+ * sent to as it was retargeted. Each entry holds a targeted-to URI and an
+ * index giving its position in the tree of retargetings, e.g. 1, 1.1 and
+ * 1.1.1 for a request retargeted twice. The rc, mp or np parameter holds the
+ * index of the entry the target was derived from:
+ * <ul>
+ * <li> rc - the Request-URI changed, the target user did not, e.g. a contact
+ * found by a location service.
+ * <li> mp - the target user changed, e.g. the request was forwarded.
+ * <li> np - the Request-URI did not change.
+ * </ul>
+ * The cause of a retargeting is the Reason header embedded in the URI of the
+ * entry the request was retargeted from.
+ * <p>
+ * For Example:<br>
+ * <code>History-Info: &lt;sip:bob@example.com?Reason=SIP%3Bcause%3D302&gt;;index=1,
+ * &lt;sip:office@example.com&gt;;index=1.1;mp=1</code>
+ *
+ * @see DiversionHeader
+ */
+type HistoryInfoHeader interface {
+	AddressHeader
+	ParametersHeader
+
+	/**
+	 * Sets the index of the entry.
+	 *
+	 * @param index - the index, dot separated numbers such as 1.1.2.
+	 * @throws ParseException if the index is not well formed.
+	 */
+	SetIndex(index string) (ParseException error)
+
+	/**
+	 * Gets the index of the entry, "" if it has none.
+	 */
+	GetIndex() string
+
+	/**
+	 * Sets the rc, mp or np parameter to the index of the entry the target
+	 * was derived from, removing the others.
+	 *
+	 * @param tag - ParameterNames_RC, ParameterNames_MP or ParameterNames_NP.
+	 * @param index - the index of the parent entry.
+	 * @throws ParseException if the tag or index is not valid.
+	 */
+	SetTargetTag(tag, index string) (ParseException error)
+
+	/**
+	 * Gets the rc, mp or np parameter of the entry and its value, or "" if it
+	 * has none.
+	 */
+	GetTargetTag() (tag, index string)
+
+	/**
+	 * Embeds reason in the URI of the entry as the cause of the retargeting
+	 * of the request from it.
+	 *
+	 * @throws SipException if the URI is not a SIP URI.
+	 */
+	SetReason(reason ReasonHeader) (SipException error)
+}
@@ -0,0 +1,123 @@
+package header
+
+import (
+	"bytes"
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"net/url"
+	"strings"
+)
+
+/** The History-Info header records the retargetings of a request (RFC 7044).
+ */
+type HistoryInfo struct {
+	AddressParameters
+}
+
+/** default constructor
+ */
+func NewHistoryInfo() *HistoryInfo {
+	this := &HistoryInfo{}
+	this.AddressParameters.super(core.SIPHeaderNames_HISTORY_INFO)
+	return this
+}
+
+/**  constructor
+ * @param addr address to set
+ */
+func NewHistoryInfoFromAddress(addr address.Address) *HistoryInfo {
+	this := &HistoryInfo{}
+	this.AddressParameters.super(core.SIPHeaderNames_HISTORY_INFO)
+	this.addr = addr
+	return this
+}
+
+func (this *HistoryInfo) SetIndex(index string) (ParseException error) {
+	if !IsHistoryIndex(index) {
+		return errors.New("ParseException: bad History-Info index " + index)
+	}
+	return this.SetParameter(ParameterNames_INDEX, index)
+}
+
+func (this *HistoryInfo) GetIndex() string {
+	return this.GetParameter(ParameterNames_INDEX)
+}
+
+func (this *HistoryInfo) SetTargetTag(tag, index string) (ParseException error) {
+	if tag != ParameterNames_RC && tag != ParameterNames_MP && tag != ParameterNames_NP {
+		return errors.New("ParseException: bad History-Info tag " + tag)
+	}
+	if !IsHistoryIndex(index) {
+		return errors.New("ParseException: bad History-Info index " + index)
+	}
+	this.RemoveParameter(ParameterNames_RC)
+	this.RemoveParameter(ParameterNames_MP)
+	this.RemoveParameter(ParameterNames_NP)
+	return this.SetParameter(tag, index)
+}
+
+func (this *HistoryInfo) GetTargetTag() (tag, index string) {
+	for _, tag = range []string{ParameterNames_RC, ParameterNames_MP, ParameterNames_NP} {
+		if this.HasParameter(tag) {
+			return tag, this.GetParameter(tag)
+		}
+	}
+	return "", ""
+}
+
+func (this *HistoryInfo) SetReason(reason ReasonHeader) (SipException error) {
+	uri, ok := this.addr.GetURI().(*address.SipURIImpl)
+	if !ok {
+		return errors.New("SipException: Reason can only be embedded in a SIP URI")
+	}
+	// a "+" stands for itself in a SIP URI, so spaces are escaped as %20
+	uri.SetHeader(core.SIPHeaderNames_REASON, strings.Replace(url.QueryEscape(reason.EncodeBody()), "+", "%20", -1))
+	return nil
+}
+
+func (this *HistoryInfo) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/** Encode into canonical form.
+ *@return String containing the canonicaly encoded header.
+ */
+func (this *HistoryInfo) EncodeBody() string {
+	var encoding bytes.Buffer
+	addr, _ := this.addr.(*address.AddressImpl)
+	if addr.GetAddressType() == address.ADDRESS_SPEC {
+		encoding.WriteString(core.SIPSeparatorNames_LESS_THAN)
+	}
+	encoding.WriteString(this.addr.String())
+	if addr.GetAddressType() == address.ADDRESS_SPEC {
+		encoding.WriteString(core.SIPSeparatorNames_GREATER_THAN)
+	}
+
+	if this.parameters != nil && this.parameters.Len() > 0 {
+		encoding.WriteString(core.SIPSeparatorNames_SEMICOLON)
+		encoding.WriteString(this.parameters.String())
+	}
+	return encoding.String()
+}
+
+/** Returns true if index is a well formed History-Info index: numbers
+ * separated by dots, e.g. 1.2.1.
+ */
+func IsHistoryIndex(index string) bool {
+	if index == "" {
+		return false
+	}
+	for _, n := range strings.Split(index, ".") {
+		if n == "" {
+			return false
+		}
+		for i := 0; i < len(n); i++ {
+			if n[i] < '0' || n[i] > '9' {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,18 @@
+package header
+
+import "gosips/core"
+
+/**
+* HistoryInfo List of SIP headers (a collection of Addresses)
+ */
+type HistoryInfoList struct {
+	SIPHeaderList
+}
+
+/** Default constructor
+ */
+func NewHistoryInfoList() *HistoryInfoList {
+	this := &HistoryInfoList{}
+	this.SIPHeaderList.super(core.SIPHeaderNames_HISTORY_INFO)
+	return this
+}
@@ -0,0 +1,64 @@
+package header
+
+/**
+ * The Identity header field (RFC 8224) carries a signature over the identity
+ * of the originator of a request and the target of the request, a PASSporT
+ * (RFC 8225) in the form of a JSON Web Token. Its parameters are:
+ * <ul>
+ * <li> info - the URI of the certificate of the signer.
+ * <li> alg - the signature algorithm, ES256.
+ * <li> ppt - the PASSporT extension, e.g. shaken (RFC 8588).
+ * </ul>
+ * For Example:<br>
+ * <code>Identity: eyJhbGciOiJFUzI1NiIsInBwdCI6InNoYWtlbiIsInR5cCI6InBhc3Nwb3J0In0.
+ * eyJhdHRlc3QiOiJBIn0.c2lnbmF0dXJl;info=&lt;https://cert.example.org/passport.cer&gt;;
+ * alg=ES256;ppt=shaken</code>
+ */
+type IdentityHeader interface {
+	ParametersHeader
+
+	/**
+	 * Sets the signed identity digest, the encoded PASSporT.
+	 *
+	 * @throws ParseException if the digest is empty.
+	 */
+	SetSignedIdentityDigest(digest string) (ParseException error)
+
+	/**
+	 * Gets the signed identity digest.
+	 */
+	GetSignedIdentityDigest() string
+
+	/**
+	 * Sets the URI of the certificate of the signer.
+	 *
+	 * @throws ParseException if the URI is empty.
+	 */
+	SetInfo(info string) (ParseException error)
+
+	/**
+	 * Gets the URI of the certificate of the signer, without the angle
+	 * brackets, or "" if there is none.
+	 */
+	GetInfo() string
+
+	/**
+	 * Sets the signature algorithm.
+	 */
+	SetAlgorithm(alg string) (ParseException error)
+
+	/**
+	 * Gets the signature algorithm, ES256 if there is none.
+	 */
+	GetAlgorithm() string
+
+	/**
+	 * Sets the PASSporT extension, e.g. Identity_PPT_SHAKEN.
+	 */
+	SetPassportType(ppt string) (ParseException error)
+
+	/**
+	 * Gets the PASSporT extension, or "" if there is none.
+	 */
+	GetPassportType() string
+}
@@ -0,0 +1,99 @@
+package header
+
+import (
+	"bytes"
+	"errors"
+	"gosips/core"
+	"strings"
+)
+
+/** The algorithm and extension of the Identity header.
+ */
+const (
+	Identity_ALG_ES256  = "ES256"
+	Identity_PPT_SHAKEN = "shaken"
+)
+
+/**
+ * The Identity header carries a signed identity (RFC 8224).
+ */
+type Identity struct {
+	Parameters
+
+	signedIdentityDigest string
+}
+
+/** Creates a new instance of Identity */
+func NewIdentity() *Identity {
+	this := &Identity{}
+	this.Parameters.super(core.SIPHeaderNames_IDENTITY)
+	return this
+}
+
+func (this *Identity) SetSignedIdentityDigest(digest string) (ParseException error) {
+	if digest == "" {
+		return errors.New("ParseException: empty signed identity digest")
+	}
+	this.signedIdentityDigest = digest
+	return nil
+}
+
+func (this *Identity) GetSignedIdentityDigest() string {
+	return this.signedIdentityDigest
+}
+
+func (this *Identity) SetInfo(info string) (ParseException error) {
+	if info == "" {
+		return errors.New("ParseException: empty Identity info")
+	}
+	return this.SetParameter(ParameterNames_INFO, "<"+info+">")
+}
+
+func (this *Identity) GetInfo() string {
+	info := this.GetParameter(ParameterNames_INFO)
+	return strings.TrimSuffix(strings.TrimPrefix(info, "<"), ">")
+}
+
+func (this *Identity) SetAlgorithm(alg string) (ParseException error) {
+	if alg == "" {
+		return errors.New("ParseException: empty Identity alg")
+	}
+	return this.SetParameter(ParameterNames_ALG, alg)
+}
+
+func (this *Identity) GetAlgorithm() string {
+	if alg := this.GetParameter(ParameterNames_ALG); alg != "" {
+		return alg
+	}
+	return Identity_ALG_ES256
+}
+
+func (this *Identity) SetPassportType(ppt string) (ParseException error) {
+	if ppt == "" {
+		return errors.New("ParseException: empty Identity ppt")
+	}
+	return this.SetParameter(ParameterNames_PPT, ppt)
+}
+
+func (this *Identity) GetPassportType() string {
+	return this.GetParameter(ParameterNames_PPT)
+}
+
+func (this *Identity) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/** Encode the body of this header (the stuff that follows headerName).
+ * A.K.A headerValue.
+ */
+func (this *Identity) EncodeBody() string {
+	var encoding bytes.Buffer
+	encoding.WriteString(this.signedIdentityDigest)
+
+	if this.parameters != nil && this.parameters.Len() > 0 {
+		encoding.WriteString(core.SIPSeparatorNames_SEMICOLON)
+		encoding.WriteString(this.parameters.String())
+	}
+	return encoding.String()
+}
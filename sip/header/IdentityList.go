@@ -0,0 +1,18 @@
+package header
+
+import "gosips/core"
+
+/**
+* Identity List of SIP headers (a collection of signed identities)
+ */
+type IdentityList struct {
+	SIPHeaderList
+}
+
+/** Default constructor
+ */
+func NewIdentityList() *IdentityList {
+	this := &IdentityList{}
+	this.SIPHeaderList.super(core.SIPHeaderNames_IDENTITY)
+	return this
+}
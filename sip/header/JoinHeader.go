@@ -0,0 +1,49 @@
+package header
+
+/**
+ * This interface represents the Join header, as defined by
+ * <a href = "http://www.ietf.org/rfc/rfc3911.txt">RFC3911</a>, this header is
+ * not part of RFC3261.
+ * <p>
+ * The Join header is used to logically join an existing SIP dialog with a
+ * new SIP dialog, e.g. for call pickup, barge-in or conferencing. It
+ * identifies the dialog to be joined by its Call-ID and the tags of the
+ * dialog as seen by the recipient: the to-tag is its local tag and the
+ * from-tag its remote tag.
+ * <p>
+ * For Example:<br>
+ * <code>Join: 98732@sip.example.com;from-tag=r33th4x0r;to-tag=ff87ff</code>
+ */
+type JoinHeader interface {
+	ParametersHeader
+
+	/**
+	 * Sets the Call-ID of the dialog to be joined.
+	 */
+	SetCallId(callId string) (ParseException error)
+
+	/**
+	 * Gets the Call-ID of the dialog to be joined.
+	 */
+	GetCallId() string
+
+	/**
+	 * Sets the to-tag parameter.
+	 */
+	SetToTag(toTag string)
+
+	/**
+	 * Gets the to-tag parameter.
+	 */
+	GetToTag() string
+
+	/**
+	 * Sets the from-tag parameter.
+	 */
+	SetFromTag(fromTag string)
+
+	/**
+	 * Gets the from-tag parameter.
+	 */
+	GetFromTag() string
+}
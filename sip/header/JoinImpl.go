@@ -0,0 +1,91 @@
+package header
+
+import (
+	"bytes"
+	"errors"
+	"gosips/core"
+)
+
+/**
+* Join SIP Header (RFC 3911).
+ */
+type Join struct {
+	Parameters
+
+	/** Call-ID of the dialog to be joined
+	 */
+	callId string
+}
+
+/** default constructor
+ */
+func NewJoin() *Join {
+	this := &Join{}
+	this.Parameters.super(core.SIPHeaderNames_JOIN)
+	return this
+}
+
+func (this *Join) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/**
+ * Return canonical form.
+ * @return String
+ */
+func (this *Join) EncodeBody() string {
+	var encoding bytes.Buffer
+	encoding.WriteString(this.callId)
+	if this.parameters != nil && this.parameters.Len() > 0 {
+		encoding.WriteString(core.SIPSeparatorNames_SEMICOLON)
+		encoding.WriteString(this.parameters.String())
+	}
+	return encoding.String()
+}
+
+/**
+ * Sets the Call-ID of the dialog to be joined.
+ */
+func (this *Join) SetCallId(callId string) (ParseException error) {
+	if callId == "" {
+		return errors.New("ParseException: empty Call-ID")
+	}
+	this.callId = callId
+	return nil
+}
+
+/**
+ * Gets the Call-ID of the dialog to be joined.
+ */
+func (this *Join) GetCallId() string {
+	return this.callId
+}
+
+/**
+ * Sets the to-tag parameter.
+ */
+func (this *Join) SetToTag(toTag string) {
+	this.Parameters.SetParameter(ParameterNames_TO_TAG, toTag)
+}
+
+/**
+ * Gets the to-tag parameter.
+ */
+func (this *Join) GetToTag() string {
+	return this.Parameters.GetParameter(ParameterNames_TO_TAG)
+}
+
+/**
+ * Sets the from-tag parameter.
+ */
+func (this *Join) SetFromTag(fromTag string) {
+	this.Parameters.SetParameter(ParameterNames_FROM_TAG, fromTag)
+}
+
+/**
+ * Gets the from-tag parameter.
+ */
+func (this *Join) GetFromTag() string {
+	return this.Parameters.GetParameter(ParameterNames_FROM_TAG)
+}
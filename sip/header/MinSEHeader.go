@@ -0,0 +1,33 @@
+package header
+
+/**
+ * This interface represents the Min-SE header, as defined by
+ * <a href = "http://www.ietf.org/rfc/rfc4028.txt">RFC4028</a>, this header is
+ * not part of RFC3261.
+ * <p>
+ * The Min-SE header indicates the minimum value for the session interval, in
+ * units of delta-seconds. When used in an INVITE or UPDATE request, it
+ * indicates the smallest value of the session interval that can be used for
+ * that session. When present in a request or response, its value MUST NOT be
+ * less than 90 seconds. It is also carried in 422 (Session Interval Too Small)
+ * responses.
+ * <p>
+ * For Example:<br>
+ * <code>Min-SE: 90</code>
+ */
+type MinSEHeader interface {
+	ParametersHeader
+
+	/**
+	 * Sets the minimum session interval in seconds.
+	 *
+	 * @param expires - the new minimum session interval of this MinSEHeader
+	 * @throws InvalidArgumentException if supplied value is less than zero.
+	 */
+	SetExpires(expires int) (InvalidArgumentException error)
+
+	/**
+	 * Gets the minimum session interval in seconds.
+	 */
+	GetExpires() int
+}
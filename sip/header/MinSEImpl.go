@@ -0,0 +1,67 @@
+package header
+
+import (
+	"bytes"
+	"errors"
+	"gosips/core"
+	"strconv"
+)
+
+/**
+* Min-SE SIP Header (RFC 4028).
+ */
+type MinSE struct {
+	Parameters
+
+	/** minimum session interval
+	 */
+	expires int
+}
+
+/** default constructor
+ */
+func NewMinSE() *MinSE {
+	this := &MinSE{}
+	this.Parameters.super(core.SIPHeaderNames_MIN_SE)
+	return this
+}
+
+func (this *MinSE) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/**
+ * Return canonical form.
+ * @return String
+ */
+func (this *MinSE) EncodeBody() string {
+	var encoding bytes.Buffer
+	encoding.WriteString(strconv.Itoa(this.expires))
+	if this.parameters != nil && this.parameters.Len() > 0 {
+		encoding.WriteString(core.SIPSeparatorNames_SEMICOLON)
+		encoding.WriteString(this.parameters.String())
+	}
+	return encoding.String()
+}
+
+/**
+ * Gets the minimum session interval in seconds.
+ */
+func (this *MinSE) GetExpires() int {
+	return this.expires
+}
+
+/**
+ * Sets the minimum session interval in seconds.
+ *
+ * @param expires - the new minimum session interval of this MinSEHeader
+ * @throws InvalidArgumentException if supplied value is less than zero.
+ */
+func (this *MinSE) SetExpires(expires int) (InvalidArgumentException error) {
+	if expires < 0 {
+		return errors.New("InvalidArgumentException: bad argument")
+	}
+	this.expires = expires
+	return nil
+}
@@ -0,0 +1,21 @@
+package header
+
+/**
+ * The P-Asserted-Identity header field (RFC 3325) carries the identity of
+ * the user sending a request, as asserted by a proxy of a trust domain that
+ * authenticated the user. It is only meaningful inside the trust domain and
+ * is removed, by a privacy service, from requests leaving it toward entities
+ * that are not trusted.
+ * <p>
+ * A request carries at most one SIP or SIPS URI and one tel URI.
+ * <p>
+ * For Example:<br>
+ * <code>P-Asserted-Identity: "Cullen Jennings" &lt;sip:fluffy@cisco.com&gt;,
+ * &lt;tel:+14085264000&gt;</code>
+ *
+ * @see PPreferredIdentityHeader
+ * @see PrivacyHeader
+ */
+type PAssertedIdentityHeader interface {
+	AddressHeader
+}
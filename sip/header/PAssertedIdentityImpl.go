@@ -0,0 +1,52 @@
+package header
+
+import (
+	"bytes"
+	"gosips/core"
+	"gosips/sip/address"
+)
+
+/** P-Asserted-Identity SIP Header (RFC 3325).
+ */
+type PAssertedIdentity struct {
+	AddressParameters
+}
+
+/** default constructor
+ */
+func NewPAssertedIdentity() *PAssertedIdentity {
+	this := &PAssertedIdentity{}
+	this.AddressParameters.super(core.SIPHeaderNames_P_ASSERTED_IDENTITY)
+	return this
+}
+
+/**  constructor
+ * @param addr address to set
+ */
+func NewPAssertedIdentityFromAddress(addr address.Address) *PAssertedIdentity {
+	this := &PAssertedIdentity{}
+	this.AddressParameters.super(core.SIPHeaderNames_P_ASSERTED_IDENTITY)
+	this.addr = addr
+	return this
+}
+
+func (this *PAssertedIdentity) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/** Encode into canonical form.
+ *@return String containing the canonicaly encoded header.
+ */
+func (this *PAssertedIdentity) EncodeBody() string {
+	var encoding bytes.Buffer
+	addr, _ := this.addr.(*address.AddressImpl)
+	if addr.GetAddressType() == address.ADDRESS_SPEC {
+		encoding.WriteString(core.SIPSeparatorNames_LESS_THAN)
+	}
+	encoding.WriteString(this.addr.String())
+	if addr.GetAddressType() == address.ADDRESS_SPEC {
+		encoding.WriteString(core.SIPSeparatorNames_GREATER_THAN)
+	}
+	return encoding.String()
+}
@@ -0,0 +1,18 @@
+package header
+
+import "gosips/core"
+
+/**
+* PAssertedIdentity List of SIP headers (a collection of Addresses)
+ */
+type PAssertedIdentityList struct {
+	SIPHeaderList
+}
+
+/** Default constructor
+ */
+func NewPAssertedIdentityList() *PAssertedIdentityList {
+	this := &PAssertedIdentityList{}
+	this.SIPHeaderList.super(core.SIPHeaderNames_P_ASSERTED_IDENTITY)
+	return this
+}
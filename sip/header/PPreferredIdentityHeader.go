@@ -0,0 +1,15 @@
+package header
+
+/**
+ * The P-Preferred-Identity header field (RFC 3325) is sent by a User Agent
+ * to a proxy of its trust domain to tell which of its identities the proxy
+ * should assert. The proxy replaces it with a P-Asserted-Identity.
+ * <p>
+ * For Example:<br>
+ * <code>P-Preferred-Identity: "Cullen Jennings" &lt;sip:fluffy@cisco.com&gt;</code>
+ *
+ * @see PAssertedIdentityHeader
+ */
+type PPreferredIdentityHeader interface {
+	AddressHeader
+}
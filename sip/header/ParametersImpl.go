@@ -57,6 +57,29 @@ const ParameterNames_TRANSPORT = "transport"
 const ParameterNames_TEXT = "text"
 const ParameterNames_CAUSE = "cause"
 const ParameterNames_ID = "id"
+const ParameterNames_REFRESHER = "refresher"
+const ParameterNames_TO_TAG = "to-tag"
+const ParameterNames_FROM_TAG = "from-tag"
+const ParameterNames_EARLY_ONLY = "early-only"
+const ParameterNames_INDEX = "index"
+const ParameterNames_RC = "rc"
+const ParameterNames_MP = "mp"
+const ParameterNames_NP = "np"
+const ParameterNames_REASON = "reason"
+const ParameterNames_COUNTER = "counter"
+const ParameterNames_LIMIT = "limit"
+const ParameterNames_PRIVACY = "privacy"
+const ParameterNames_SCREEN = "screen"
+const ParameterNames_ALG = "alg"
+const ParameterNames_PPT = "ppt"
+const ParameterNames_SIP_INSTANCE = "+sip.instance"
+const ParameterNames_REG_ID = "reg-id"
+const ParameterNames_OB = "ob"
+const ParameterNames_PUB_GRUU = "pub-gruu"
+const ParameterNames_TEMP_GRUU = "temp-gruu"
+const ParameterNames_GR = "gr"
+const ParameterNames_REQUIRE = "require"
+const ParameterNames_EXPLICIT = "explicit"
 
 const SIPConstants_DEFAULT_ENCODING = "UTF-8"
 const SIPConstants_DEFAULT_PORT = 5060
@@ -0,0 +1,22 @@
+package header
+
+/**
+ * The Path header field (RFC 3327) is inserted in a REGISTER by proxies on
+ * the path from the User Agent to the registrar, e.g. edge proxies, that
+ * want to stay on the path of requests sent to the registered contact. The
+ * registrar stores the Path vector with the binding and echoes it in the
+ * 200 (OK) response; proxies forwarding a request to the contact turn it into
+ * a pre-loaded route set.
+ * <p>
+ * Each proxy adds its URI, with the lr parameter, at the top of the list.
+ * <p>
+ * For Example:<br>
+ * <code>Path: &lt;sip:P2.EXAMPLEHOME.COM;lr&gt;,&lt;sip:P1.EXAMPLEVISITED.COM;lr&gt;</code>
+ *
+ * @see RouteHeader
+ * @see ServiceRouteHeader
+ */
+type PathHeader interface {
+	AddressHeader
+	ParametersHeader
+}
@@ -0,0 +1,57 @@
+package header
+
+import (
+	"bytes"
+	"gosips/core"
+	"gosips/sip/address"
+)
+
+/** The Path header records the proxies on the path of a REGISTER (RFC 3327).
+ */
+type Path struct {
+	AddressParameters
+}
+
+/** default constructor
+ */
+func NewPath() *Path {
+	this := &Path{}
+	this.AddressParameters.super(core.SIPHeaderNames_PATH)
+	return this
+}
+
+/**  constructor
+ * @param addr address to set
+ */
+func NewPathFromAddress(addr address.Address) *Path {
+	this := &Path{}
+	this.AddressParameters.super(core.SIPHeaderNames_PATH)
+	this.addr = addr
+	return this
+}
+
+func (this *Path) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/** Encode into canonical form.
+ *@return String containing the canonicaly encoded header.
+ */
+func (this *Path) EncodeBody() string {
+	var encoding bytes.Buffer
+	addr, _ := this.addr.(*address.AddressImpl)
+	if addr.GetAddressType() == address.ADDRESS_SPEC {
+		encoding.WriteString(core.SIPSeparatorNames_LESS_THAN)
+	}
+	encoding.WriteString(this.addr.String())
+	if addr.GetAddressType() == address.ADDRESS_SPEC {
+		encoding.WriteString(core.SIPSeparatorNames_GREATER_THAN)
+	}
+
+	if this.parameters != nil && this.parameters.Len() > 0 {
+		encoding.WriteString(core.SIPSeparatorNames_SEMICOLON)
+		encoding.WriteString(this.parameters.String())
+	}
+	return encoding.String()
+}
@@ -0,0 +1,40 @@
+package header
+
+/**
+ * The Privacy header field (RFC 3323) lists the privacy a User Agent asks
+ * for, with values such as:
+ * <ul>
+ * <li> header - hide the headers that identify the user, e.g. From,
+ * Contact, Call-Info and Via.
+ * <li> session - hide the media addresses of the session.
+ * <li> user - hide identity information added by the user.
+ * <li> none - no privacy is wanted.
+ * <li> critical - the request must fail if the privacy cannot be provided.
+ * <li> id - hide the asserted identity of the user (RFC 3325).
+ * </ul>
+ * For Example:<br>
+ * <code>Privacy: id;critical</code>
+ */
+type PrivacyHeader interface {
+	Header
+
+	/**
+	 * Adds a privacy value.
+	 *
+	 * @param value - the privacy value, a token.
+	 * @throws ParseException if the value is empty.
+	 */
+	AddPrivacy(value string) (ParseException error)
+
+	/**
+	 * Gets the privacy values in order.
+	 *
+	 * @return the privacy values.
+	 */
+	GetPrivacy() []string
+
+	/**
+	 * Returns true if the privacy value is listed, compared without case.
+	 */
+	HasPrivacy(value string) bool
+}
@@ -0,0 +1,81 @@
+package header
+
+import (
+	"errors"
+	"gosips/core"
+	"strings"
+)
+
+/**
+ * Privacy values (RFC 3323 section 4.2 and RFC 3325 section 9.3).
+ */
+const (
+	Privacy_HEADER   = "header"
+	Privacy_SESSION  = "session"
+	Privacy_USER     = "user"
+	Privacy_NONE     = "none"
+	Privacy_CRITICAL = "critical"
+	Privacy_ID       = "id"
+)
+
+/**
+* Privacy SIP Header (RFC 3323).
+ */
+type Privacy struct {
+	SIPHeader
+
+	/** privacy values
+	 */
+	values []string
+}
+
+/** Default Constructor.
+ */
+func NewPrivacy() *Privacy {
+	this := &Privacy{}
+	this.SIPHeader.super(core.SIPHeaderNames_PRIVACY)
+	return this
+}
+
+func (this *Privacy) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/**
+ * Generate the canonical form.
+ * @return String.
+ */
+func (this *Privacy) EncodeBody() string {
+	return strings.Join(this.values, core.SIPSeparatorNames_SEMICOLON)
+}
+
+/**
+ * Adds a privacy value.
+ */
+func (this *Privacy) AddPrivacy(value string) (ParseException error) {
+	if value == "" {
+		return errors.New("NullPointerException: the privacy value is null")
+	}
+	this.values = append(this.values, value)
+	return nil
+}
+
+/**
+ * Gets the privacy values.
+ */
+func (this *Privacy) GetPrivacy() []string {
+	return this.values
+}
+
+/**
+ * Returns true if the privacy value is listed.
+ */
+func (this *Privacy) HasPrivacy(value string) bool {
+	for _, v := range this.values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package header
+
+/**
+ * This interface represents the Refer-Sub header, as defined by
+ * <a href = "http://www.ietf.org/rfc/rfc4488.txt">RFC4488</a>, this header is
+ * not part of RFC3261.
+ * <p>
+ * A REFER request carrying "Refer-Sub: false" asks the recipient not to
+ * create the implicit subscription of RFC 3515. A recipient honouring the
+ * request places "Refer-Sub: false" in its 2xx response and sends no NOTIFY.
+ * <p>
+ * For Example:<br>
+ * <code>Refer-Sub: false</code>
+ */
+type ReferSubHeader interface {
+	ParametersHeader
+
+	/**
+	 * Sets whether the implicit subscription is created.
+	 */
+	SetReferSub(value bool)
+
+	/**
+	 * Returns true if the implicit subscription is created.
+	 */
+	IsReferSub() bool
+}
@@ -0,0 +1,59 @@
+package header
+
+import (
+	"bytes"
+	"gosips/core"
+	"strconv"
+)
+
+/**
+* Refer-Sub SIP Header (RFC 4488).
+ */
+type ReferSub struct {
+	Parameters
+
+	/** whether the implicit subscription is created
+	 */
+	value bool
+}
+
+/** Creates a Refer-Sub header with the given value.
+ */
+func NewReferSub(value bool) *ReferSub {
+	this := &ReferSub{value: value}
+	this.Parameters.super(core.SIPHeaderNames_REFER_SUB)
+	return this
+}
+
+func (this *ReferSub) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/**
+ * Return canonical form.
+ * @return String
+ */
+func (this *ReferSub) EncodeBody() string {
+	var encoding bytes.Buffer
+	encoding.WriteString(strconv.FormatBool(this.value))
+	if this.parameters != nil && this.parameters.Len() > 0 {
+		encoding.WriteString(core.SIPSeparatorNames_SEMICOLON)
+		encoding.WriteString(this.parameters.String())
+	}
+	return encoding.String()
+}
+
+/**
+ * Sets whether the implicit subscription is created.
+ */
+func (this *ReferSub) SetReferSub(value bool) {
+	this.value = value
+}
+
+/**
+ * Returns true if the implicit subscription is created.
+ */
+func (this *ReferSub) IsReferSub() bool {
+	return this.value
+}
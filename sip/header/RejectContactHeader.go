@@ -0,0 +1,21 @@
+package header
+
+/**
+ * The Reject-Contact header field (RFC 3841) carries a caller preference: a
+ * feature predicate describing the User Agents the request must not reach.
+ * A contact is discarded if it indicates all the feature tags of the
+ * predicate and matches it.
+ * <p>
+ * For Example:<br>
+ * <code>Reject-Contact: *;actor="msg-taker";video</code>
+ */
+type RejectContactHeader interface {
+	ParametersHeader
+
+	/**
+	 * Sets a feature tag of the predicate, e.g. "video" or "+sip.actor". An
+	 * empty value makes it a boolean feature set to true; other values are
+	 * quoted tag value lists such as "msg-taker".
+	 */
+	SetFeatureTag(name, value string)
+}
@@ -0,0 +1,36 @@
+package header
+
+import (
+	"gosips/core"
+)
+
+/**
+* RejectContact SIP Header (RFC 3841).
+ */
+type RejectContact struct {
+	Parameters
+}
+
+/** Default constructor.
+ */
+func NewRejectContact() *RejectContact {
+	this := &RejectContact{}
+	this.Parameters.super(core.SIPHeaderNames_REJECT_CONTACT)
+	return this
+}
+
+func (this *RejectContact) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/** Encode the body of this header (the stuff that follows headerName).
+ * A.K.A headerValue.
+ */
+func (this *RejectContact) EncodeBody() string {
+	return encodeFeaturePredicate(&this.Parameters)
+}
+
+func (this *RejectContact) SetFeatureTag(name, value string) {
+	setFeatureTag(&this.Parameters, name, value)
+}
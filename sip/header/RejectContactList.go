@@ -0,0 +1,18 @@
+package header
+
+import "gosips/core"
+
+/**
+* RejectContact List of SIP headers (a collection of feature predicates)
+ */
+type RejectContactList struct {
+	SIPHeaderList
+}
+
+/** Default constructor
+ */
+func NewRejectContactList() *RejectContactList {
+	this := &RejectContactList{}
+	this.SIPHeaderList.super(core.SIPHeaderNames_REJECT_CONTACT)
+	return this
+}
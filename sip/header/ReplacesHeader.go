@@ -0,0 +1,60 @@
+package header
+
+/**
+ * This interface represents the Replaces header, as defined by
+ * <a href = "http://www.ietf.org/rfc/rfc3891.txt">RFC3891</a>, this header is
+ * not part of RFC3261.
+ * <p>
+ * The Replaces header is used to logically replace an existing SIP dialog
+ * with a new SIP dialog, e.g. in attended transfer or call pickup. It
+ * identifies the dialog to be replaced by its Call-ID and the tags of the
+ * dialog as seen by the recipient: the to-tag is its local tag and the
+ * from-tag its remote tag. The early-only flag asks for the dialog to be
+ * replaced only while it is early.
+ * <p>
+ * For Example:<br>
+ * <code>Replaces: 98732@sip.example.com;from-tag=r33th4x0r;to-tag=ff87ff</code>
+ */
+type ReplacesHeader interface {
+	ParametersHeader
+
+	/**
+	 * Sets the Call-ID of the dialog to be replaced.
+	 */
+	SetCallId(callId string) (ParseException error)
+
+	/**
+	 * Gets the Call-ID of the dialog to be replaced.
+	 */
+	GetCallId() string
+
+	/**
+	 * Sets the to-tag parameter.
+	 */
+	SetToTag(toTag string)
+
+	/**
+	 * Gets the to-tag parameter.
+	 */
+	GetToTag() string
+
+	/**
+	 * Sets the from-tag parameter.
+	 */
+	SetFromTag(fromTag string)
+
+	/**
+	 * Gets the from-tag parameter.
+	 */
+	GetFromTag() string
+
+	/**
+	 * Sets or removes the early-only flag.
+	 */
+	SetEarlyOnly(earlyOnly bool)
+
+	/**
+	 * Returns true if the early-only flag is present.
+	 */
+	IsEarlyOnly() bool
+}
@@ -0,0 +1,108 @@
+package header
+
+import (
+	"bytes"
+	"errors"
+	"gosips/core"
+)
+
+/**
+* Replaces SIP Header (RFC 3891).
+ */
+type Replaces struct {
+	Parameters
+
+	/** Call-ID of the dialog to be replaced
+	 */
+	callId string
+}
+
+/** default constructor
+ */
+func NewReplaces() *Replaces {
+	this := &Replaces{}
+	this.Parameters.super(core.SIPHeaderNames_REPLACES)
+	return this
+}
+
+func (this *Replaces) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/**
+ * Return canonical form.
+ * @return String
+ */
+func (this *Replaces) EncodeBody() string {
+	var encoding bytes.Buffer
+	encoding.WriteString(this.callId)
+	if this.parameters != nil && this.parameters.Len() > 0 {
+		encoding.WriteString(core.SIPSeparatorNames_SEMICOLON)
+		encoding.WriteString(this.parameters.String())
+	}
+	return encoding.String()
+}
+
+/**
+ * Sets the Call-ID of the dialog to be replaced.
+ */
+func (this *Replaces) SetCallId(callId string) (ParseException error) {
+	if callId == "" {
+		return errors.New("ParseException: empty Call-ID")
+	}
+	this.callId = callId
+	return nil
+}
+
+/**
+ * Gets the Call-ID of the dialog to be replaced.
+ */
+func (this *Replaces) GetCallId() string {
+	return this.callId
+}
+
+/**
+ * Sets the to-tag parameter.
+ */
+func (this *Replaces) SetToTag(toTag string) {
+	this.Parameters.SetParameter(ParameterNames_TO_TAG, toTag)
+}
+
+/**
+ * Gets the to-tag parameter.
+ */
+func (this *Replaces) GetToTag() string {
+	return this.Parameters.GetParameter(ParameterNames_TO_TAG)
+}
+
+/**
+ * Sets the from-tag parameter.
+ */
+func (this *Replaces) SetFromTag(fromTag string) {
+	this.Parameters.SetParameter(ParameterNames_FROM_TAG, fromTag)
+}
+
+/**
+ * Gets the from-tag parameter.
+ */
+func (this *Replaces) GetFromTag() string {
+	return this.Parameters.GetParameter(ParameterNames_FROM_TAG)
+}
+
+/**
+ * Sets or removes the early-only flag.
+ */
+func (this *Replaces) SetEarlyOnly(earlyOnly bool) {
+	this.Parameters.RemoveParameter(ParameterNames_EARLY_ONLY)
+	if earlyOnly {
+		this.Parameters.SetParameterFromNameValue(core.NewNameValue(ParameterNames_EARLY_ONLY, nil))
+	}
+}
+
+/**
+ * Returns true if the early-only flag is present.
+ */
+func (this *Replaces) IsEarlyOnly() bool {
+	return this.parameters.GetNameValue(ParameterNames_EARLY_ONLY) != nil
+}
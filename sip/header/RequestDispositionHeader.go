@@ -0,0 +1,33 @@
+package header
+
+/**
+ * The Request-Disposition header field (RFC 3841) carries the caller
+ * preferences about how proxies process the request: whether they proxy or
+ * redirect it, fork it, in parallel or sequentially, cancel the other
+ * branches when one is answered, recurse on redirections and queue it when
+ * the called party is busy. Each directive overrides the local policy of
+ * the proxy for its feature.
+ * <p>
+ * For Example:<br>
+ * <code>Request-Disposition: proxy, recurse, parallel</code>
+ */
+type RequestDispositionHeader interface {
+	Header
+
+	/**
+	 * Adds a directive, e.g. RequestDisposition_SEQUENTIAL.
+	 *
+	 * @throws ParseException if the directive is empty.
+	 */
+	AddDirective(directive string) (ParseException error)
+
+	/**
+	 * Gets the directives in order.
+	 */
+	GetDirectives() []string
+
+	/**
+	 * Returns true if the directive is listed.
+	 */
+	HasDirective(directive string) bool
+}
@@ -0,0 +1,87 @@
+package header
+
+import (
+	"errors"
+	"gosips/core"
+	"strings"
+)
+
+/**
+ * The directives of the Request-Disposition header (RFC 3841 section 9.1).
+ */
+const (
+	RequestDisposition_PROXY      = "proxy"
+	RequestDisposition_REDIRECT   = "redirect"
+	RequestDisposition_CANCEL     = "cancel"
+	RequestDisposition_NO_CANCEL  = "no-cancel"
+	RequestDisposition_FORK       = "fork"
+	RequestDisposition_NO_FORK    = "no-fork"
+	RequestDisposition_RECURSE    = "recurse"
+	RequestDisposition_NO_RECURSE = "no-recurse"
+	RequestDisposition_PARALLEL   = "parallel"
+	RequestDisposition_SEQUENTIAL = "sequential"
+	RequestDisposition_QUEUE      = "queue"
+	RequestDisposition_NO_QUEUE   = "no-queue"
+)
+
+/**
+* RequestDisposition SIP Header (RFC 3841).
+ */
+type RequestDisposition struct {
+	SIPHeader
+
+	/** directives
+	 */
+	directives []string
+}
+
+/** Default Constructor.
+ */
+func NewRequestDisposition() *RequestDisposition {
+	this := &RequestDisposition{}
+	this.SIPHeader.super(core.SIPHeaderNames_REQUEST_DISPOSITION)
+	return this
+}
+
+func (this *RequestDisposition) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/**
+ * Generate the canonical form.
+ * @return String.
+ */
+func (this *RequestDisposition) EncodeBody() string {
+	return strings.Join(this.directives, core.SIPSeparatorNames_COMMA+core.SIPSeparatorNames_SP)
+}
+
+/**
+ * Adds a directive.
+ */
+func (this *RequestDisposition) AddDirective(directive string) (ParseException error) {
+	if directive == "" {
+		return errors.New("ParseException: empty Request-Disposition directive")
+	}
+	this.directives = append(this.directives, directive)
+	return nil
+}
+
+/**
+ * Gets the directives.
+ */
+func (this *RequestDisposition) GetDirectives() []string {
+	return this.directives
+}
+
+/**
+ * Returns true if the directive is listed.
+ */
+func (this *RequestDisposition) HasDirective(directive string) bool {
+	for _, d := range this.directives {
+		if strings.EqualFold(d, directive) {
+			return true
+		}
+	}
+	return false
+}
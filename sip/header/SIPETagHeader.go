@@ -0,0 +1,31 @@
+package header
+
+/**
+ * This interface represents the SIP-ETag header, as defined by
+ * <a href = "http://www.ietf.org/rfc/rfc3903.txt">RFC3903</a>, this header is
+ * not part of RFC3261.
+ * <p>
+ * The SIP-ETag header of the 2xx response to a PUBLISH request carries the
+ * entity tag the Event State Compositor assigned to the publication.
+ * <p>
+ * For Example:<br>
+ * <code>SIP-ETag: dx200xyz</code>
+ */
+type SIPETagHeader interface {
+	Header
+
+	/**
+	 * Sets the entity tag.
+	 *
+	 * @param eTag - the entity tag, a token.
+	 * @throws ParseException if the entity tag is empty.
+	 */
+	SetETag(eTag string) (ParseException error)
+
+	/**
+	 * Gets the entity tag.
+	 *
+	 * @return the entity tag.
+	 */
+	GetETag() string
+}
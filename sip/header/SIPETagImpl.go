@@ -0,0 +1,56 @@
+package header
+
+import (
+	"errors"
+	"gosips/core"
+)
+
+/**
+* SIP-ETag SIP Header (RFC 3903).
+ */
+type SIPETag struct {
+	SIPHeader
+
+	/** entity tag
+	 */
+	eTag string
+}
+
+/** Default Constructor.
+ */
+func NewSIPETag() *SIPETag {
+	this := &SIPETag{}
+	this.SIPHeader.super(core.SIPHeaderNames_SIP_ETAG)
+	return this
+}
+
+func (this *SIPETag) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/**
+ * Generate the canonical form.
+ * @return String.
+ */
+func (this *SIPETag) EncodeBody() string {
+	return this.eTag
+}
+
+/**
+ * Sets the entity tag.
+ */
+func (this *SIPETag) SetETag(eTag string) (ParseException error) {
+	if eTag == "" {
+		return errors.New("NullPointerException: the eTag parameter is null")
+	}
+	this.eTag = eTag
+	return nil
+}
+
+/**
+ * Gets the entity tag.
+ */
+func (this *SIPETag) GetETag() string {
+	return this.eTag
+}
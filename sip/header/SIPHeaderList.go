@@ -107,7 +107,8 @@ func (this *SIPHeaderList) String() string {
 	if this.headerName == core.SIPHeaderNames_WWW_AUTHENTICATE ||
 		this.headerName == core.SIPHeaderNames_PROXY_AUTHENTICATE ||
 		this.headerName == core.SIPHeaderNames_AUTHORIZATION ||
-		this.headerName == core.SIPHeaderNames_PROXY_AUTHORIZATION { //|| //TODO by LY
+		this.headerName == core.SIPHeaderNames_PROXY_AUTHORIZATION ||
+		this.headerName == core.SIPHeaderNames_IDENTITY { //|| //TODO by LY
 		//this instanceof ExtensionHeaderList ) {
 		for e := this.Front(); e != nil; e = e.Next() {
 			if sh, ok := e.Value.(Header); ok {
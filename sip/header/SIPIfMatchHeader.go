@@ -0,0 +1,31 @@
+package header
+
+/**
+ * This interface represents the SIP-If-Match header, as defined by
+ * <a href = "http://www.ietf.org/rfc/rfc3903.txt">RFC3903</a>, this header is
+ * not part of RFC3261.
+ * <p>
+ * The SIP-If-Match header of a PUBLISH request carries the entity tag of the
+ * publication the request refreshes, modifies or removes.
+ * <p>
+ * For Example:<br>
+ * <code>SIP-If-Match: dx200xyz</code>
+ */
+type SIPIfMatchHeader interface {
+	Header
+
+	/**
+	 * Sets the entity tag.
+	 *
+	 * @param eTag - the entity tag, a token.
+	 * @throws ParseException if the entity tag is empty.
+	 */
+	SetETag(eTag string) (ParseException error)
+
+	/**
+	 * Gets the entity tag.
+	 *
+	 * @return the entity tag.
+	 */
+	GetETag() string
+}
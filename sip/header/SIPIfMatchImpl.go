@@ -0,0 +1,56 @@
+package header
+
+import (
+	"errors"
+	"gosips/core"
+)
+
+/**
+* SIP-If-Match SIP Header (RFC 3903).
+ */
+type SIPIfMatch struct {
+	SIPHeader
+
+	/** entity tag
+	 */
+	eTag string
+}
+
+/** Default Constructor.
+ */
+func NewSIPIfMatch() *SIPIfMatch {
+	this := &SIPIfMatch{}
+	this.SIPHeader.super(core.SIPHeaderNames_SIP_IF_MATCH)
+	return this
+}
+
+func (this *SIPIfMatch) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/**
+ * Generate the canonical form.
+ * @return String.
+ */
+func (this *SIPIfMatch) EncodeBody() string {
+	return this.eTag
+}
+
+/**
+ * Sets the entity tag.
+ */
+func (this *SIPIfMatch) SetETag(eTag string) (ParseException error) {
+	if eTag == "" {
+		return errors.New("NullPointerException: the eTag parameter is null")
+	}
+	this.eTag = eTag
+	return nil
+}
+
+/**
+ * Gets the entity tag.
+ */
+func (this *SIPIfMatch) GetETag() string {
+	return this.eTag
+}
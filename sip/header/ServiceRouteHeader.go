@@ -0,0 +1,18 @@
+package header
+
+/**
+ * The Service-Route header field (RFC 3608) is returned by a registrar in the
+ * 200 (OK) response to a REGISTER. It holds the route set, e.g. the home
+ * service proxy, the registered User Agent pre-loads as Route on the requests
+ * it originates while the registration lasts.
+ * <p>
+ * For Example:<br>
+ * <code>Service-Route: &lt;sip:orig@scscf.example.com;lr&gt;</code>
+ *
+ * @see RouteHeader
+ * @see PathHeader
+ */
+type ServiceRouteHeader interface {
+	AddressHeader
+	ParametersHeader
+}
@@ -0,0 +1,18 @@
+package header
+
+import "gosips/core"
+
+/**
+* ServiceRoute List of SIP headers (a collection of Addresses)
+ */
+type ServiceRouteList struct {
+	SIPHeaderList
+}
+
+/** Default constructor
+ */
+func NewServiceRouteList() *ServiceRouteList {
+	this := &ServiceRouteList{}
+	this.SIPHeaderList.super(core.SIPHeaderNames_SERVICE_ROUTE)
+	return this
+}
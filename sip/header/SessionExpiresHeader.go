@@ -0,0 +1,42 @@
+package header
+
+/**
+ * This interface represents the Session-Expires header, as defined by
+ * <a href = "http://www.ietf.org/rfc/rfc4028.txt">RFC4028</a>, this header is
+ * not part of RFC3261.
+ * <p>
+ * The Session-Expires header conveys the session interval for a SIP session.
+ * It is placed only in INVITE or UPDATE requests, as well as in any 2xx
+ * response to an INVITE or UPDATE. The refresher parameter indicates who is
+ * doing the refreshing: "uac" or "uas". The compact form of the header is "x".
+ * <p>
+ * For Example:<br>
+ * <code>Session-Expires: 4000;refresher=uac</code>
+ */
+type SessionExpiresHeader interface {
+	ParametersHeader
+
+	/**
+	 * Sets the session interval in seconds.
+	 *
+	 * @param expires - the new session interval of this SessionExpiresHeader
+	 * @throws InvalidArgumentException if supplied value is less than zero.
+	 */
+	SetExpires(expires int) (InvalidArgumentException error)
+
+	/**
+	 * Gets the session interval in seconds.
+	 */
+	GetExpires() int
+
+	/**
+	 * Sets the refresher parameter, "uac" or "uas". An empty string removes
+	 * the parameter.
+	 */
+	SetRefresher(refresher string)
+
+	/**
+	 * Gets the refresher parameter, or "" if it is absent.
+	 */
+	GetRefresher() string
+}
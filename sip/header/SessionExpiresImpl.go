@@ -0,0 +1,89 @@
+package header
+
+import (
+	"bytes"
+	"errors"
+	"gosips/core"
+	"strconv"
+)
+
+const SessionExpires_UAC = "uac"
+const SessionExpires_UAS = "uas"
+
+/**
+* Session-Expires SIP Header (RFC 4028).
+ */
+type SessionExpires struct {
+	Parameters
+
+	/** session interval
+	 */
+	expires int
+}
+
+/** default constructor
+ */
+func NewSessionExpires() *SessionExpires {
+	this := &SessionExpires{}
+	this.Parameters.super(core.SIPHeaderNames_SESSION_EXPIRES)
+	return this
+}
+
+func (this *SessionExpires) String() string {
+	return this.headerName + core.SIPSeparatorNames_COLON +
+		core.SIPSeparatorNames_SP + this.EncodeBody() + core.SIPSeparatorNames_NEWLINE
+}
+
+/**
+ * Return canonical form.
+ * @return String
+ */
+func (this *SessionExpires) EncodeBody() string {
+	var encoding bytes.Buffer
+	encoding.WriteString(strconv.Itoa(this.expires))
+	if this.parameters != nil && this.parameters.Len() > 0 {
+		encoding.WriteString(core.SIPSeparatorNames_SEMICOLON)
+		encoding.WriteString(this.parameters.String())
+	}
+	return encoding.String()
+}
+
+/**
+ * Gets the session interval in seconds.
+ */
+func (this *SessionExpires) GetExpires() int {
+	return this.expires
+}
+
+/**
+ * Sets the session interval in seconds.
+ *
+ * @param expires - the new session interval of this SessionExpiresHeader
+ * @throws InvalidArgumentException if supplied value is less than zero.
+ */
+func (this *SessionExpires) SetExpires(expires int) (InvalidArgumentException error) {
+	if expires < 0 {
+		return errors.New("InvalidArgumentException: bad argument")
+	}
+	this.expires = expires
+	return nil
+}
+
+/**
+ * Sets the refresher parameter, "uac" or "uas". An empty string removes
+ * the parameter.
+ */
+func (this *SessionExpires) SetRefresher(refresher string) {
+	if refresher == "" {
+		this.Parameters.RemoveParameter(ParameterNames_REFRESHER)
+	} else {
+		this.Parameters.SetParameter(ParameterNames_REFRESHER, refresher)
+	}
+}
+
+/**
+ * Gets the refresher parameter, or "" if it is absent.
+ */
+func (this *SessionExpires) GetRefresher() string {
+	return this.Parameters.GetParameter(ParameterNames_REFRESHER)
+}
@@ -31,6 +31,22 @@ package header
 * </ul>
 */
 
+/** The subscription states.
+ */
+const SubscriptionState_ACTIVE = "active"
+const SubscriptionState_PENDING = "pending"
+const SubscriptionState_TERMINATED = "terminated"
+
+/** The reason codes of a terminated subscription.
+ */
+const SubscriptionState_DEACTIVATED = "deactivated"
+const SubscriptionState_PROBATION = "probation"
+const SubscriptionState_REJECTED = "rejected"
+const SubscriptionState_TIMEOUT = "timeout"
+const SubscriptionState_GIVE_UP = "giveup"
+const SubscriptionState_NO_RESOURCE = "noresource"
+const SubscriptionState_INVARIANT = "invariant"
+
 type SubscriptionStateHeader interface {
 	ParametersHeader
 
@@ -0,0 +1,194 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Passport.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package identity
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+/** The type and extension of a PASSporT.
+ */
+const (
+	PASSPORT_TYPE = "passport"
+	PPT_SHAKEN    = "shaken"
+)
+
+/** The attestation levels of the shaken extension (RFC 8588): full, partial
+ * and gateway attestation.
+ */
+const (
+	ATTEST_FULL    = "A"
+	ATTEST_PARTIAL = "B"
+	ATTEST_GATEWAY = "C"
+)
+
+/** The JOSE header of a PASSporT. The fields are in lexicographic order so
+ * that it is encoded in the canonical form of RFC 8225 section 9.
+ */
+type PassportHeader struct {
+	Alg string `json:"alg"`
+	Ppt string `json:"ppt,omitempty"`
+	Typ string `json:"typ"`
+	X5u string `json:"x5u"`
+}
+
+/** The originating identity of a PASSporT: a telephone number or a URI.
+ */
+type PassportOrig struct {
+	Tn  string `json:"tn,omitempty"`
+	Uri string `json:"uri,omitempty"`
+}
+
+/** The destination identities of a PASSporT.
+ */
+type PassportDest struct {
+	Tn  []string `json:"tn,omitempty"`
+	Uri []string `json:"uri,omitempty"`
+}
+
+/** The claims of a PASSporT, with the shaken extension claims attest and
+ * origid.
+ */
+type PassportClaims struct {
+	Attest string        `json:"attest,omitempty"`
+	Dest   *PassportDest `json:"dest"`
+	Iat    int64         `json:"iat"`
+	Orig   *PassportOrig `json:"orig"`
+	Origid string        `json:"origid,omitempty"`
+}
+
+/**
+ * A Personal Assertion Token (RFC 8225): a JSON Web Token signed with ES256
+ * asserting the originating identity of a call.
+ */
+type Passport struct {
+	Header PassportHeader
+	Claims PassportClaims
+
+	token string
+}
+
+/** Creates an unsigned PASSporT with the shaken extension whose certificate
+ * is found at x5u.
+ */
+func NewShakenPassport(x5u string, claims PassportClaims) *Passport {
+	return &Passport{
+		Header: PassportHeader{
+			Alg: "ES256",
+			Ppt: PPT_SHAKEN,
+			Typ: PASSPORT_TYPE,
+			X5u: x5u,
+		},
+		Claims: claims,
+	}
+}
+
+/** Returns the encoded PASSporT, header.payload.signature, once it is signed
+ * or parsed.
+ */
+func (this *Passport) GetToken() string {
+	return this.token
+}
+
+/** Signs the PASSporT with key, a P-256 private key, and returns its
+ * encoding.
+ */
+func (this *Passport) Sign(key *ecdsa.PrivateKey) (token string, err error) {
+	signingInput, err := this.signingInput()
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	this.token = signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return this.token, nil
+}
+
+func (this *Passport) signingInput() (string, error) {
+	header, err := json.Marshal(&this.Header)
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(&this.Claims)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(claims), nil
+}
+
+/** Parses an encoded PASSporT without checking its signature.
+ */
+func ParsePassport(token string) (*Passport, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("ParseException: a PASSporT has three parts")
+	}
+	this := &Passport{token: token}
+	if err := decodePart(parts[0], &this.Header); err != nil {
+		return nil, err
+	}
+	if err := decodePart(parts[1], &this.Claims); err != nil {
+		return nil, err
+	}
+	if this.Header.Typ != PASSPORT_TYPE {
+		return nil, errors.New("ParseException: bad PASSporT typ " + this.Header.Typ)
+	}
+	if this.Claims.Orig == nil || this.Claims.Dest == nil || this.Claims.Iat == 0 {
+		return nil, errors.New("ParseException: missing PASSporT claim")
+	}
+	return this, nil
+}
+
+func decodePart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("ParseException: bad PASSporT encoding")
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return errors.New("ParseException: bad PASSporT JSON")
+	}
+	return nil
+}
+
+/** Checks the ES256 signature of a parsed PASSporT with the public key of the
+ * certificate of the signer.
+ */
+func (this *Passport) Verify(key *ecdsa.PublicKey) (err error) {
+	if this.Header.Alg != "ES256" {
+		return errors.New("SipException: unsupported PASSporT alg " + this.Header.Alg)
+	}
+	i := strings.LastIndex(this.token, ".")
+	if i < 0 {
+		return errors.New("SipException: the PASSporT is not signed")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(this.token[i+1:])
+	if err != nil || len(signature) != 64 {
+		return errors.New("SipException: bad PASSporT signature")
+	}
+	digest := sha256.Sum256([]byte(this.token[:i]))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return errors.New("SipException: PASSporT signature mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package identity
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestPassportSignAndVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passport := NewShakenPassport("https://cert.example.org/passport.pem", PassportClaims{
+		Attest: ATTEST_FULL,
+		Dest:   &PassportDest{Tn: []string{"12155551213"}},
+		Iat:    1443208345,
+		Orig:   &PassportOrig{Tn: "12155551212"},
+		Origid: "123e4567-e89b-12d3-a456-426655440000",
+	})
+	token, err := passport.Sign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(token, ".")
+	header, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if string(header) != `{"alg":"ES256","ppt":"shaken","typ":"passport","x5u":"https://cert.example.org/passport.pem"}` {
+		t.Errorf("bad header %s", header)
+	}
+	if string(claims) != `{"attest":"A","dest":{"tn":["12155551213"]},"iat":1443208345,"orig":{"tn":"12155551212"},"origid":"123e4567-e89b-12d3-a456-426655440000"}` {
+		t.Errorf("bad claims %s", claims)
+	}
+
+	parsed, err := ParsePassport(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Claims.Attest != ATTEST_FULL || parsed.Claims.Orig.Tn != "12155551212" {
+		t.Errorf("bad passport %+v", parsed.Claims)
+	}
+	if err = parsed.Verify(&key.PublicKey); err != nil {
+		t.Error(err)
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err = parsed.Verify(&other.PublicKey); err == nil {
+		t.Error("verified with the wrong key")
+	}
+	tampered, _ := ParsePassport(parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(
+		`{"attest":"A","dest":{"tn":["12155551213"]},"iat":1443208345,"orig":{"tn":"19005551212"}}`)) + "." + parts[2])
+	if err = tampered.Verify(&key.PublicKey); err == nil {
+		t.Error("verified a tampered passport")
+	}
+}
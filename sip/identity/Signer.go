@@ -0,0 +1,181 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Signer.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package identity
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strings"
+	"time"
+)
+
+/**
+ * An authentication service (RFC 8224 section 6.1) signing the requests of
+ * its users with the shaken extension (RFC 8588). The originating identity is
+ * taken from the P-Asserted-Identity of the request, or its From if it has
+ * none, and the destination from its To.
+ */
+type Signer struct {
+	key *ecdsa.PrivateKey
+	x5u string
+}
+
+/** Creates a signer with a P-256 private key whose certificate is published
+ * at x5u, the URI placed in the info parameter of the Identity header.
+ */
+func NewSigner(key *ecdsa.PrivateKey, x5u string) *Signer {
+	return &Signer{key: key, x5u: x5u}
+}
+
+/** Signs request with the attestation level attest, e.g. ATTEST_FULL, and
+ * adds the Identity header to it. A random origid is generated if origid is
+ * empty.
+ */
+func (this *Signer) Sign(request *message.SIPRequest, attest, origid string) (identity header.IdentityHeader, SipException error) {
+	if attest != ATTEST_FULL && attest != ATTEST_PARTIAL && attest != ATTEST_GATEWAY {
+		return nil, errors.New("SipException: bad attestation " + attest)
+	}
+	orig := GetOriginatingIdentity(request)
+	dest := GetDestinationIdentity(request)
+	if orig == nil || dest == nil {
+		return nil, errors.New("SipException: the request has no identity to sign")
+	}
+	if origid == "" {
+		origid = newUUID()
+	}
+	passport := NewShakenPassport(this.x5u, PassportClaims{
+		Attest: attest,
+		Dest:   dest,
+		Iat:    time.Now().Unix(),
+		Orig:   orig,
+		Origid: origid,
+	})
+	token, err := passport.Sign(this.key)
+	if err != nil {
+		return nil, err
+	}
+
+	identityHeader := header.NewIdentity()
+	identityHeader.SetSignedIdentityDigest(token)
+	identityHeader.SetInfo(this.x5u)
+	identityHeader.SetAlgorithm(header.Identity_ALG_ES256)
+	identityHeader.SetPassportType(header.Identity_PPT_SHAKEN)
+
+	identityList := header.NewIdentityList()
+	for e := request.GetHeaders(core.SIPHeaderNames_IDENTITY).Front(); e != nil; e = e.Next() {
+		identityList.PushBack(e.Value.(header.Header))
+	}
+	identityList.PushBack(identityHeader)
+	request.SetHeader(identityList)
+	return identityHeader, nil
+}
+
+/** Returns the orig claim for a request: the first P-Asserted-Identity, a
+ * telephone number if there is one, or else the From.
+ */
+func GetOriginatingIdentity(request message.Message) *PassportOrig {
+	var uris []address.URI
+	for e := request.GetHeaders(core.SIPHeaderNames_P_ASSERTED_IDENTITY).Front(); e != nil; e = e.Next() {
+		if pai, ok := e.Value.(header.PAssertedIdentityHeader); ok {
+			uris = append(uris, pai.GetAddress().GetURI())
+		}
+	}
+	for _, uri := range uris {
+		if tn := GetTelephoneNumber(uri); tn != "" {
+			return &PassportOrig{Tn: tn}
+		}
+	}
+	if len(uris) > 0 {
+		return &PassportOrig{Uri: canonicalURI(uris[0])}
+	}
+	if from, ok := request.GetHeader(core.SIPHeaderNames_FROM).(header.FromHeader); ok && from.GetAddress() != nil {
+		uri := from.GetAddress().GetURI()
+		if tn := GetTelephoneNumber(uri); tn != "" {
+			return &PassportOrig{Tn: tn}
+		}
+		return &PassportOrig{Uri: canonicalURI(uri)}
+	}
+	return nil
+}
+
+/** Returns the dest claim for a request, taken from its To.
+ */
+func GetDestinationIdentity(request message.Message) *PassportDest {
+	to, ok := request.GetHeader(core.SIPHeaderNames_TO).(header.ToHeader)
+	if !ok || to.GetAddress() == nil {
+		return nil
+	}
+	uri := to.GetAddress().GetURI()
+	if tn := GetTelephoneNumber(uri); tn != "" {
+		return &PassportDest{Tn: []string{tn}}
+	}
+	return &PassportDest{Uri: []string{canonicalURI(uri)}}
+}
+
+/** Returns the canonical telephone number of a tel URI or of the user part of
+ * a SIP URI (RFC 8224 section 8.3): its digits without the leading + and
+ * visual separators. "" is returned if uri is not a telephone number.
+ */
+func GetTelephoneNumber(uri address.URI) string {
+	var number string
+	if sipURI, ok := uri.(*address.SipURIImpl); ok {
+		number = sipURI.GetUser()
+		if i := strings.Index(number, ";"); i >= 0 {
+			number = number[:i]
+		}
+	} else if s := uri.String(); strings.HasPrefix(strings.ToLower(s), "tel:") {
+		number = s[len("tel:"):]
+		if i := strings.Index(number, ";"); i >= 0 {
+			number = number[:i]
+		}
+	} else {
+		return ""
+	}
+
+	var digits strings.Builder
+	for i := 0; i < len(number); i++ {
+		switch c := number[i]; {
+		case c >= '0' && c <= '9', c == '*', c == '#':
+			digits.WriteByte(c)
+		case c == '+' && i == 0, c == '-', c == '.', c == '(', c == ')':
+		default:
+			return ""
+		}
+	}
+	return digits.String()
+}
+
+/** Returns uri without its parameters and headers.
+ */
+func canonicalURI(uri address.URI) string {
+	if sipURI, ok := uri.(*address.SipURIImpl); ok {
+		s := sipURI.GetScheme() + ":"
+		if sipURI.GetUser() != "" {
+			s += sipURI.GetUser() + "@"
+		}
+		return s + strings.ToLower(sipURI.GetHost())
+	}
+	return uri.String()
+}
+
+/** Returns a random version 4 UUID.
+ */
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	s := hex.EncodeToString(b)
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
@@ -0,0 +1,305 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Verifier.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package identity
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * The longest time in seconds between the signing of a request and its
+ * verification (RFC 8224 section 6.2.2).
+ */
+const IDENTITY_MAX_AGE = 60
+
+/**
+ * Fetches the certificate chain published at the info URI of an Identity
+ * header, the certificate of the signer first. The verifier of a carrier
+ * uses an HTTPCertificateFetcher; tests and closed networks may use a local
+ * one instead.
+ */
+type CertificateFetcher interface {
+	FetchCertificates(url string) ([]*x509.Certificate, error)
+}
+
+/**
+ * The largest certificate chain in bytes read by an HTTPCertificateFetcher.
+ */
+const MAX_CERTIFICATES_SIZE = 64 * 1024
+
+/**
+ * The number of certificate chains an HTTPCertificateFetcher keeps.
+ */
+const MAX_CACHED_CHAINS = 256
+
+/**
+ * A CertificateFetcher getting PEM encoded certificates over HTTPS from the
+ * certificate repository of the signer. Fetched chains are kept for an hour,
+ * at most MAX_CACHED_CHAINS of them: the oldest one is dropped first.
+ */
+type HTTPCertificateFetcher struct {
+	mutex sync.Mutex
+
+	client *http.Client
+	cache  map[string]*cachedChain
+}
+
+type cachedChain struct {
+	chain     []*x509.Certificate
+	fetchedAt time.Time
+}
+
+/** Creates a fetcher with a 5 seconds timeout.
+ */
+func NewHTTPCertificateFetcher() *HTTPCertificateFetcher {
+	return &HTTPCertificateFetcher{
+		client: &http.Client{Timeout: 5 * time.Second},
+		cache:  make(map[string]*cachedChain),
+	}
+}
+
+func (this *HTTPCertificateFetcher) FetchCertificates(url string) ([]*x509.Certificate, error) {
+	if !strings.HasPrefix(strings.ToLower(url), "https:") {
+		return nil, errors.New("SipException: the certificate URI is not https")
+	}
+	this.mutex.Lock()
+	cached := this.cache[url]
+	this.mutex.Unlock()
+	if cached != nil && !cached.isExpired() {
+		return cached.chain, nil
+	}
+
+	response, err := this.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("SipException: certificate fetch failed with " + response.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, MAX_CERTIFICATES_SIZE+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MAX_CERTIFICATES_SIZE {
+		return nil, errors.New("SipException: certificate chain too large")
+	}
+	chain, err := ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+
+	this.mutex.Lock()
+	this.store(url, chain)
+	this.mutex.Unlock()
+	return chain, nil
+}
+
+/** Adds a chain to the cache, dropping the expired chains, and the oldest
+ * one if the cache is still full.
+ */
+func (this *HTTPCertificateFetcher) store(url string, chain []*x509.Certificate) {
+	var oldest string
+	for u, cached := range this.cache {
+		if cached.isExpired() {
+			delete(this.cache, u)
+		} else if oldest == "" || cached.fetchedAt.Before(this.cache[oldest].fetchedAt) {
+			oldest = u
+		}
+	}
+	if _, ok := this.cache[url]; !ok && len(this.cache) >= MAX_CACHED_CHAINS {
+		delete(this.cache, oldest)
+	}
+	this.cache[url] = &cachedChain{chain: chain, fetchedAt: time.Now()}
+}
+
+func (this *cachedChain) isExpired() bool {
+	return time.Since(this.fetchedAt) >= time.Hour
+}
+
+/** Parses PEM encoded certificates, or a single DER encoded one.
+ */
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, certificate)
+	}
+	if len(chain) == 0 {
+		certificate, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil, errors.New("ParseException: no certificate found")
+		}
+		chain = append(chain, certificate)
+	}
+	return chain, nil
+}
+
+/**
+ * A verification service (RFC 8224 section 6.2) checking the Identity
+ * headers of incoming requests. A request is accepted if one of its Identity
+ * headers holds a fresh PASSporT, signed with a certificate from the
+ * trusted roots, whose orig and dest match the request.
+ */
+type Verifier struct {
+	mutex sync.Mutex
+
+	fetcher CertificateFetcher
+	roots   *x509.CertPool
+	maxAge  int
+}
+
+/** Creates a verifier getting the certificates of signers with fetcher.
+ * No certificate is trusted until roots are set with SetRoots.
+ */
+func NewVerifier(fetcher CertificateFetcher) *Verifier {
+	return &Verifier{fetcher: fetcher, maxAge: IDENTITY_MAX_AGE}
+}
+
+/** Sets the certificate authorities trusted to issue the certificates of
+ * signers, e.g. those of the STI policy administrator.
+ */
+func (this *Verifier) SetRoots(roots *x509.CertPool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.roots = roots
+}
+
+/** Sets the longest time in seconds between the signing of a request and its
+ * verification.
+ */
+func (this *Verifier) SetMaxAge(maxAge int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.maxAge = maxAge
+}
+
+/** Verifies the Identity headers of request. It returns the PASSporT of the
+ * first valid one, e.g. to read its attestation, or the response to reject
+ * the request with: 428 if it has no Identity header, 436 if a certificate
+ * cannot be fetched, 437 if it is not trusted, 403 (Stale Date) if the
+ * PASSporT is too old and 438 if it does not match the request.
+ */
+func (this *Verifier) Verify(request *message.SIPRequest) (passport *Passport, response *message.SIPResponse) {
+	statusCode := message.USE_IDENTITY_HEADER
+	for e := request.GetHeaders(core.SIPHeaderNames_IDENTITY).Front(); e != nil; e = e.Next() {
+		identity, ok := e.Value.(header.IdentityHeader)
+		if !ok {
+			continue
+		}
+		if passport, statusCode = this.verifyIdentity(request, identity); passport != nil {
+			return passport, nil
+		}
+	}
+	response = request.CreateResponse(statusCode)
+	if statusCode == message.FORBIDDEN {
+		response.SetReasonPhrase("Stale Date")
+	}
+	return nil, response
+}
+
+func (this *Verifier) verifyIdentity(request *message.SIPRequest, identity header.IdentityHeader) (*Passport, int) {
+	this.mutex.Lock()
+	roots, maxAge := this.roots, this.maxAge
+	this.mutex.Unlock()
+
+	if identity.GetAlgorithm() != header.Identity_ALG_ES256 {
+		return nil, message.INVALID_IDENTITY_HEADER
+	}
+	info := identity.GetInfo()
+	if info == "" {
+		return nil, message.BAD_IDENTITY_INFO
+	}
+	chain, err := this.fetcher.FetchCertificates(info)
+	if err != nil || len(chain) == 0 {
+		return nil, message.BAD_IDENTITY_INFO
+	}
+	key, ok := chain[0].PublicKey.(*ecdsa.PublicKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return nil, message.UNSUPPORTED_CREDENTIAL
+	}
+	if roots == nil {
+		return nil, message.UNSUPPORTED_CREDENTIAL
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range chain[1:] {
+		intermediates.AddCert(certificate)
+	}
+	if _, err = chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, message.UNSUPPORTED_CREDENTIAL
+	}
+
+	passport, err := ParsePassport(identity.GetSignedIdentityDigest())
+	if err != nil || passport.Header.X5u != info || passport.Header.Ppt != identity.GetPassportType() {
+		return nil, message.INVALID_IDENTITY_HEADER
+	}
+	if err = passport.Verify(key); err != nil {
+		return nil, message.INVALID_IDENTITY_HEADER
+	}
+	if age := time.Now().Unix() - passport.Claims.Iat; age > int64(maxAge) || age < -int64(maxAge) {
+		return nil, message.FORBIDDEN
+	}
+	if !matchesOrig(passport.Claims.Orig, GetOriginatingIdentity(request)) ||
+		!matchesDest(passport.Claims.Dest, GetDestinationIdentity(request)) {
+		return nil, message.INVALID_IDENTITY_HEADER
+	}
+	return passport, 0
+}
+
+func matchesOrig(claim, orig *PassportOrig) bool {
+	return orig != nil && claim.Tn == orig.Tn && claim.Uri == orig.Uri
+}
+
+func matchesDest(claim, dest *PassportDest) bool {
+	if dest == nil {
+		return false
+	}
+	for _, tn := range dest.Tn {
+		if !hasString(claim.Tn, tn) {
+			return false
+		}
+	}
+	for _, uri := range dest.Uri {
+		if !hasString(claim.Uri, uri) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
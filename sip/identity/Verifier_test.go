@@ -0,0 +1,217 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testCertURL = "https://cert.example.org/passport.pem"
+
+type localFetcher map[string][]*x509.Certificate
+
+func (this localFetcher) FetchCertificates(url string) ([]*x509.Certificate, error) {
+	if chain, ok := this[url]; ok {
+		return chain, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func newTestCertificate(t *testing.T) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "SHAKEN test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, certificate
+}
+
+func newTestInvite(t *testing.T) *message.SIPRequest {
+	s := "INVITE sip:+12155551213@example.com;user=phone SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP pc33.atlanta.example.com;branch=z9hG4bKnashds8\r\n" +
+		"Max-Forwards: 70\r\n" +
+		"To: <sip:+1-215-555-1213@example.com;user=phone>\r\n" +
+		"From: \"Alice\" <sip:+12155551212@example.com;user=phone>;tag=1928301774\r\n" +
+		"Call-ID: a84b4c76e66710\r\n" +
+		"CSeq: 314159 INVITE\r\n" +
+		"Content-Length: 0\r\n\r\n"
+	m, err := parser.NewStringMsgParser().ParseSIPMessage(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.(*message.SIPRequest)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	key, certificate := newTestCertificate(t)
+	request := newTestInvite(t)
+	identity, err := NewSigner(key, testCertURL).Sign(request, ATTEST_FULL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.GetInfo() != testCertURL || identity.GetPassportType() != header.Identity_PPT_SHAKEN {
+		t.Errorf("bad Identity %s", identity.String())
+	}
+
+	m, err := parser.NewStringMsgParser().ParseSIPMessage(request.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := m.(*message.SIPRequest)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+	verifier := NewVerifier(localFetcher{testCertURL: {certificate}})
+	verifier.SetRoots(roots)
+	passport, response := verifier.Verify(received)
+	if response != nil {
+		t.Fatalf("rejected with %d", response.GetStatusCode())
+	}
+	if passport.Claims.Attest != ATTEST_FULL || passport.Claims.Orig.Tn != "12155551212" ||
+		passport.Claims.Dest.Tn[0] != "12155551213" || passport.Claims.Origid == "" {
+		t.Errorf("bad passport %+v", passport.Claims)
+	}
+
+	received.GetFrom().GetAddress().SetURI(received.GetTo().GetAddress().GetURI())
+	if _, response = verifier.Verify(received); response == nil || response.GetStatusCode() != message.INVALID_IDENTITY_HEADER {
+		t.Error("verified a request with another originator")
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	key, certificate := newTestCertificate(t)
+	_, other := newTestCertificate(t)
+
+	request := newTestInvite(t)
+	verifier := NewVerifier(localFetcher{testCertURL: {certificate}})
+	if _, response := verifier.Verify(request); response.GetStatusCode() != message.USE_IDENTITY_HEADER {
+		t.Errorf("got %d without Identity", response.GetStatusCode())
+	}
+
+	NewSigner(key, "https://unknown.example.org/cert.pem").Sign(request, ATTEST_PARTIAL, "")
+	if _, response := verifier.Verify(request); response.GetStatusCode() != message.BAD_IDENTITY_INFO {
+		t.Errorf("got %d for an unknown certificate", response.GetStatusCode())
+	}
+
+	request = newTestInvite(t)
+	NewSigner(key, testCertURL).Sign(request, ATTEST_GATEWAY, "")
+	if _, response := verifier.Verify(request); response.GetStatusCode() != message.UNSUPPORTED_CREDENTIAL {
+		t.Errorf("got %d without trusted roots", response.GetStatusCode())
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(other)
+	verifier.SetRoots(roots)
+	if _, response := verifier.Verify(request); response.GetStatusCode() != message.UNSUPPORTED_CREDENTIAL {
+		t.Errorf("got %d for an untrusted certificate", response.GetStatusCode())
+	}
+
+	verifier = NewVerifier(localFetcher{testCertURL: {other}})
+	verifier.SetRoots(roots)
+	if _, response := verifier.Verify(request); response.GetStatusCode() != message.INVALID_IDENTITY_HEADER {
+		t.Errorf("got %d for a bad signature", response.GetStatusCode())
+	}
+
+	request = newTestInvite(t)
+	passport := NewShakenPassport(testCertURL, PassportClaims{
+		Attest: ATTEST_FULL,
+		Dest:   GetDestinationIdentity(request),
+		Iat:    time.Now().Add(-5 * time.Minute).Unix(),
+		Orig:   GetOriginatingIdentity(request),
+	})
+	token, _ := passport.Sign(key)
+	identity := header.NewIdentity()
+	identity.SetSignedIdentityDigest(token)
+	identity.SetInfo(testCertURL)
+	identity.SetPassportType(header.Identity_PPT_SHAKEN)
+	request.SetHeader(identity)
+	verifier = NewVerifier(localFetcher{testCertURL: {certificate}})
+	roots = x509.NewCertPool()
+	roots.AddCert(certificate)
+	verifier.SetRoots(roots)
+	if _, response := verifier.Verify(request); response.GetStatusCode() != message.FORBIDDEN ||
+		response.GetReasonPhrase() != "Stale Date" {
+		t.Errorf("got %d for a stale PASSporT", response.GetStatusCode())
+	}
+	if request.GetHeader(core.SIPHeaderNames_IDENTITY) == nil {
+		t.Error("Identity not set")
+	}
+}
+
+func TestHTTPCertificateFetcher(t *testing.T) {
+	_, certificate := newTestCertificate(t)
+	body := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/large.pem" {
+			w.Write(bytes.Repeat(body, MAX_CERTIFICATES_SIZE/len(body)+1))
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+	fetcher := NewHTTPCertificateFetcher()
+	fetcher.client = server.Client()
+
+	if _, err := fetcher.FetchCertificates("http://cert.example.org/passport.pem"); err == nil {
+		t.Error("certificate fetched over http")
+	}
+	chain, err := fetcher.FetchCertificates(server.URL + "/passport.pem")
+	if err != nil || len(chain) != 1 || !chain[0].Equal(certificate) {
+		t.Fatal("bad chain", chain, err)
+	}
+	if _, err := fetcher.FetchCertificates(server.URL + "/passport.pem"); err != nil || requests != 1 {
+		t.Error("cached chain fetched again", err)
+	}
+	if _, err := fetcher.FetchCertificates(server.URL + "/large.pem"); err == nil {
+		t.Error("too large chain accepted")
+	}
+
+	// expired chains and then the oldest one are dropped
+	fetcher.cache[server.URL+"/passport.pem"].fetchedAt = time.Now().Add(-2 * time.Hour)
+	for i := 0; i < MAX_CACHED_CHAINS+1; i++ {
+		if _, err := fetcher.FetchCertificates(server.URL + "/" + strconv.Itoa(i) + ".pem"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(fetcher.cache) != MAX_CACHED_CHAINS {
+		t.Error("cache not bounded", len(fetcher.cache))
+	}
+	if _, ok := fetcher.cache[server.URL+"/passport.pem"]; ok {
+		t.Error("expired chain kept")
+	}
+	if _, ok := fetcher.cache[server.URL+"/0.pem"]; ok {
+		t.Error("oldest chain kept")
+	}
+}
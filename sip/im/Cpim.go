@@ -0,0 +1,209 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Cpim.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package im
+
+import (
+	"errors"
+	"strings"
+)
+
+/**
+ * Names of the CPIM message headers (RFC 3862 section 3.3). Unlike MIME
+ * header names they are case-sensitive.
+ */
+const (
+	CPIM_FROM     = "From"
+	CPIM_TO       = "To"
+	CPIM_CC       = "cc"
+	CPIM_DATETIME = "DateTime"
+	CPIM_SUBJECT  = "Subject"
+	CPIM_NS       = "NS"
+	CPIM_REQUIRE  = "Require"
+)
+
+/**
+ * A header of a CPIM message or of its content.
+ */
+type CpimHeader struct {
+	Name  string
+	Value string
+}
+
+/**
+ * A message in the Common Presence and Instant Messaging format of RFC 3862,
+ * the "message/cpim" body of a MESSAGE request. It consists of the message
+ * headers, e.g. From, To and DateTime, the MIME headers of the content, e.g.
+ * Content-Type, and the content itself.
+ */
+type CpimMessage struct {
+	Headers        []*CpimHeader
+	ContentHeaders []*CpimHeader
+	Content        string
+}
+
+/** Creates a CPIM message from from to to carrying content of contentType,
+ * e.g. "text/plain;charset=UTF-8". from and to are name-addr values, e.g.
+ * "Alice &lt;im:alice@example.com&gt;".
+ */
+func NewCpimMessage(from, to, contentType, content string) *CpimMessage {
+	this := &CpimMessage{Content: content}
+	this.AddHeader(CPIM_FROM, from)
+	this.AddHeader(CPIM_TO, to)
+	this.SetContentHeader("Content-Type", contentType)
+	return this
+}
+
+/** Returns the value of the first message header called name, or an empty
+ * string if there is none.
+ */
+func (this *CpimMessage) GetHeader(name string) string {
+	for _, h := range this.Headers {
+		if h.Name == name {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+/** Returns the values of all message headers called name.
+ */
+func (this *CpimMessage) GetHeaders(name string) []string {
+	var values []string
+	for _, h := range this.Headers {
+		if h.Name == name {
+			values = append(values, h.Value)
+		}
+	}
+	return values
+}
+
+/** Appends a message header.
+ */
+func (this *CpimMessage) AddHeader(name, value string) {
+	this.Headers = append(this.Headers, &CpimHeader{Name: name, Value: value})
+}
+
+/** Replaces the message headers called name by a single one.
+ */
+func (this *CpimMessage) SetHeader(name, value string) {
+	for _, h := range this.Headers {
+		if h.Name == name {
+			h.Value = value
+			this.removeHeaders(name, h)
+			return
+		}
+	}
+	this.AddHeader(name, value)
+}
+
+func (this *CpimMessage) removeHeaders(name string, keep *CpimHeader) {
+	headers := this.Headers[:0]
+	for _, h := range this.Headers {
+		if h.Name != name || h == keep {
+			headers = append(headers, h)
+		}
+	}
+	this.Headers = headers
+}
+
+/** Returns the value of the content header called name, compared without
+ * case, or an empty string if there is none.
+ */
+func (this *CpimMessage) GetContentHeader(name string) string {
+	for _, h := range this.ContentHeaders {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+/** Sets a content header, replacing the one with the same name.
+ */
+func (this *CpimMessage) SetContentHeader(name, value string) {
+	for _, h := range this.ContentHeaders {
+		if strings.EqualFold(h.Name, name) {
+			h.Value = value
+			return
+		}
+	}
+	this.ContentHeaders = append(this.ContentHeaders, &CpimHeader{Name: name, Value: value})
+}
+
+/** Returns the media type of the content without parameters, in lower case,
+ * e.g. "text/plain".
+ */
+func (this *CpimMessage) GetContentType() string {
+	contentType := this.GetContentHeader("Content-Type")
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+/** Encodes the message, with CRLF line ends.
+ */
+func (this *CpimMessage) Encode() string {
+	var s string
+	for _, h := range this.Headers {
+		s += h.Name + ": " + h.Value + "\r\n"
+	}
+	s += "\r\n"
+	for _, h := range this.ContentHeaders {
+		s += h.Name + ": " + h.Value + "\r\n"
+	}
+	return s + "\r\n" + this.Content
+}
+
+/** Parses a CPIM message. Lines may end with CRLF or LF.
+ */
+func ParseCpim(body string) (*CpimMessage, error) {
+	this := &CpimMessage{}
+	rest := body
+	var err error
+	if this.Headers, rest, err = parseCpimHeaders(rest); err != nil {
+		return nil, err
+	}
+	if this.ContentHeaders, rest, err = parseCpimHeaders(rest); err != nil {
+		return nil, err
+	}
+	if this.GetHeader(CPIM_FROM) == "" || this.GetHeader(CPIM_TO) == "" {
+		return nil, errors.New("ParseException: CPIM message without From or To")
+	}
+	this.Content = rest
+	return this, nil
+}
+
+/** Parses a block of headers up to and including the empty line ending it.
+ */
+func parseCpimHeaders(s string) (headers []*CpimHeader, rest string, ParseException error) {
+	for {
+		i := strings.Index(s, "\n")
+		if i < 0 {
+			return nil, "", errors.New("ParseException: truncated CPIM message")
+		}
+		line := strings.TrimSuffix(s[:i], "\r")
+		s = s[i+1:]
+		if line == "" {
+			return headers, s, nil
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(headers) > 0 {
+			// folded content header
+			headers[len(headers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			return nil, "", errors.New("ParseException: bad CPIM header: " + line)
+		}
+		headers = append(headers, &CpimHeader{
+			Name:  strings.TrimSpace(line[:colon]),
+			Value: strings.TrimSpace(line[colon+1:]),
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package im
+
+import (
+	"testing"
+)
+
+func TestParseCpim(t *testing.T) {
+	body := "From: MR SANDERS <im:piglet@100akerwood.com>\r\n" +
+		"To: Depressed Donkey <im:eeyore@100akerwood.com>\r\n" +
+		"DateTime: 2000-12-13T13:40:00-08:00\r\n" +
+		"Subject: the weather will be fine today\r\n" +
+		"NS: MyFeatures <mid:MessageFeatures@id.foo.com>\r\n" +
+		"MyFeatures.VitalMessageOption: Confirmation-requested\r\n" +
+		"\r\n" +
+		"Content-type: text/xml; charset=utf-8\r\n" +
+		"Content-ID: <1234567890@foo.com>\r\n" +
+		"\r\n" +
+		"<body>\r\nHere is the text of my message.\r\n</body>\r\n"
+
+	cpim, err := ParseCpim(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpim.GetHeader(CPIM_FROM) != "MR SANDERS <im:piglet@100akerwood.com>" ||
+		cpim.GetHeader(CPIM_SUBJECT) != "the weather will be fine today" ||
+		cpim.GetHeader("MyFeatures.VitalMessageOption") != "Confirmation-requested" {
+		t.Errorf("bad headers %+v", cpim.Headers)
+	}
+	if cpim.GetContentType() != "text/xml" || cpim.GetContentHeader("Content-ID") != "<1234567890@foo.com>" {
+		t.Errorf("bad content headers %+v", cpim.ContentHeaders)
+	}
+	if cpim.Content != "<body>\r\nHere is the text of my message.\r\n</body>\r\n" {
+		t.Errorf("bad content %q", cpim.Content)
+	}
+	if cpim.Encode() != body {
+		t.Errorf("bad round trip %q", cpim.Encode())
+	}
+
+	if _, err := ParseCpim("From: <im:a@b.com>\r\n\r\nContent-Type: text/plain\r\n\r\nhi"); err == nil {
+		t.Error("CPIM message without To accepted")
+	}
+	if _, err := ParseCpim("From: <im:a@b.com>\r\nTo: <im:c@d.com>\r\n"); err == nil {
+		t.Error("truncated CPIM message accepted")
+	}
+}
@@ -0,0 +1,232 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Imdn.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package im
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+)
+
+/**
+ * Namespaces of Instant Message Disposition Notifications (RFC 5438).
+ */
+const (
+	/** The namespace of the IMDN headers of a CPIM message. */
+	IMDN_HEADER_NAMESPACE = "urn:ietf:params:imdn"
+	/** The namespace of IMDN documents. */
+	IMDN_NAMESPACE = "urn:ietf:params:xml:ns:imdn"
+)
+
+/**
+ * Names of the IMDN headers of a CPIM message, without namespace prefix.
+ */
+const (
+	IMDN_MESSAGE_ID               = "Message-ID"
+	IMDN_DISPOSITION_NOTIFICATION = "Disposition-Notification"
+)
+
+/**
+ * Values of the Disposition-Notification header, the notifications a sender
+ * asks for.
+ */
+const (
+	DISPOSITION_POSITIVE_DELIVERY = "positive-delivery"
+	DISPOSITION_NEGATIVE_DELIVERY = "negative-delivery"
+	DISPOSITION_DISPLAY           = "display"
+)
+
+/**
+ * Values of the status of a notification.
+ */
+const (
+	IMDN_DELIVERED = "delivered"
+	IMDN_DISPLAYED = "displayed"
+	IMDN_FAILED    = "failed"
+	IMDN_FORBIDDEN = "forbidden"
+	IMDN_ERROR     = "error"
+)
+
+/**
+ * The content type of IMDN documents and the disposition type of the
+ * content carrying them.
+ */
+const (
+	IMDN_CONTENT_TYPE        = "message/imdn+xml"
+	DISPOSITION_NOTIFICATION = "notification"
+)
+
+/** The namespace prefix declared for the IMDN headers by SetImdnHeader.
+ */
+const imdnPrefix = "imdn"
+
+/**
+ * An IMDN document reporting the delivery or display of the message with
+ * MessageId. Elements without namespace in their tag are in the namespace of
+ * their parent.
+ */
+type Imdn struct {
+	XMLName              xml.Name          `xml:"urn:ietf:params:xml:ns:imdn imdn"`
+	MessageId            string            `xml:"message-id"`
+	DateTime             string            `xml:"datetime"`
+	RecipientURI         string            `xml:"recipient-uri,omitempty"`
+	OriginalRecipientURI string            `xml:"original-recipient-uri,omitempty"`
+	Subject              string            `xml:"subject,omitempty"`
+	DeliveryNotification *ImdnNotification `xml:"delivery-notification"`
+	DisplayNotification  *ImdnNotification `xml:"display-notification"`
+}
+
+/**
+ * A delivery or display notification of an IMDN document.
+ */
+type ImdnNotification struct {
+	Status *ImdnStatus `xml:"status"`
+}
+
+/**
+ * The status of a notification, an empty element such as
+ * &lt;delivered/&gt;.
+ */
+type ImdnStatus struct {
+	Values []*ImdnValue `xml:",any"`
+}
+
+/**
+ * An element of the status of a notification.
+ */
+type ImdnValue struct {
+	XMLName xml.Name
+}
+
+/** Creates a delivery notification of the message with messageId sent at
+ * dateTime. status is IMDN_DELIVERED, IMDN_FAILED, IMDN_FORBIDDEN or
+ * IMDN_ERROR.
+ */
+func NewDeliveryNotification(messageId, dateTime, status string) *Imdn {
+	return &Imdn{
+		MessageId:            messageId,
+		DateTime:             dateTime,
+		DeliveryNotification: newImdnNotification(status),
+	}
+}
+
+/** Creates a display notification of the message with messageId sent at
+ * dateTime. status is IMDN_DISPLAYED, IMDN_FORBIDDEN or IMDN_ERROR.
+ */
+func NewDisplayNotification(messageId, dateTime, status string) *Imdn {
+	return &Imdn{
+		MessageId:           messageId,
+		DateTime:            dateTime,
+		DisplayNotification: newImdnNotification(status),
+	}
+}
+
+func newImdnNotification(status string) *ImdnNotification {
+	return &ImdnNotification{
+		Status: &ImdnStatus{
+			Values: []*ImdnValue{{XMLName: xml.Name{Space: IMDN_NAMESPACE, Local: status}}},
+		},
+	}
+}
+
+/** Returns true for a display notification and false for a delivery
+ * notification.
+ */
+func (this *Imdn) IsDisplayNotification() bool {
+	return this.DisplayNotification != nil
+}
+
+/** Returns the status of the notification, e.g. IMDN_DELIVERED, or an empty
+ * string if there is none.
+ */
+func (this *Imdn) GetStatus() string {
+	notification := this.DeliveryNotification
+	if notification == nil {
+		notification = this.DisplayNotification
+	}
+	if notification == nil || notification.Status == nil || len(notification.Status.Values) == 0 {
+		return ""
+	}
+	return notification.Status.Values[0].XMLName.Local
+}
+
+/** Encodes the document, including the XML declaration.
+ */
+func (this *Imdn) Encode() (string, error) {
+	b, err := xml.Marshal(this)
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b), nil
+}
+
+/** Parses an IMDN document.
+ */
+func ParseImdn(body string) (*Imdn, error) {
+	imdn := &Imdn{}
+	if err := xml.Unmarshal([]byte(body), imdn); err != nil {
+		return nil, errors.New("ParseException: bad IMDN document: " + err.Error())
+	}
+	if imdn.MessageId == "" || imdn.DeliveryNotification == nil && imdn.DisplayNotification == nil {
+		return nil, errors.New("ParseException: IMDN document without message-id or notification")
+	}
+	return imdn, nil
+}
+
+/** Returns the value of the IMDN header called name of a CPIM message, e.g.
+ * IMDN_MESSAGE_ID, under whatever prefix the NS header of the message
+ * declares for the IMDN namespace.
+ */
+func GetImdnHeader(cpim *CpimMessage, name string) string {
+	prefix := getNamespacePrefix(cpim, IMDN_HEADER_NAMESPACE)
+	if prefix == "" {
+		return ""
+	}
+	return cpim.GetHeader(prefix + "." + name)
+}
+
+/** Sets the IMDN header called name of a CPIM message, declaring the IMDN
+ * namespace first if needed.
+ */
+func SetImdnHeader(cpim *CpimMessage, name, value string) {
+	prefix := getNamespacePrefix(cpim, IMDN_HEADER_NAMESPACE)
+	if prefix == "" {
+		prefix = imdnPrefix
+		cpim.AddHeader(CPIM_NS, prefix+" <"+IMDN_HEADER_NAMESPACE+">")
+	}
+	cpim.SetHeader(prefix+"."+name, value)
+}
+
+/** Returns the dispositions requested by the Disposition-Notification header
+ * of a CPIM message.
+ */
+func GetDispositionNotification(cpim *CpimMessage) []string {
+	var dispositions []string
+	for _, d := range strings.Split(GetImdnHeader(cpim, IMDN_DISPOSITION_NOTIFICATION), ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			dispositions = append(dispositions, d)
+		}
+	}
+	return dispositions
+}
+
+/** Returns the prefix the NS headers of cpim declare for namespace, e.g.
+ * "imdn" for "NS: imdn &lt;urn:ietf:params:imdn&gt;".
+ */
+func getNamespacePrefix(cpim *CpimMessage, namespace string) string {
+	for _, ns := range cpim.GetHeaders(CPIM_NS) {
+		i := strings.Index(ns, "<")
+		if i < 0 || !strings.HasSuffix(ns, ">") {
+			continue
+		}
+		if ns[i+1:len(ns)-1] == namespace {
+			return strings.TrimSpace(ns[:i])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package im
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseImdn(t *testing.T) {
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<imdn xmlns="urn:ietf:params:xml:ns:imdn">
+  <message-id>34jk324j</message-id>
+  <datetime>2008-04-04T12:16:49-05:00</datetime>
+  <recipient-uri>im:bob@example.com</recipient-uri>
+  <original-recipient-uri>im:bob@example.com</original-recipient-uri>
+  <delivery-notification>
+    <status>
+      <delivered/>
+    </status>
+  </delivery-notification>
+</imdn>`
+
+	imdn, err := ParseImdn(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imdn.MessageId != "34jk324j" || imdn.RecipientURI != "im:bob@example.com" ||
+		imdn.IsDisplayNotification() || imdn.GetStatus() != IMDN_DELIVERED {
+		t.Errorf("bad imdn %+v", imdn)
+	}
+
+	encoded, err := NewDisplayNotification("34jk324j", "2008-04-04T12:16:49-05:00", IMDN_DISPLAYED).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(encoded, `xmlns=""`) {
+		t.Errorf("bad namespace %s", encoded)
+	}
+	decoded, err := ParseImdn(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.IsDisplayNotification() || decoded.GetStatus() != IMDN_DISPLAYED || decoded.MessageId != "34jk324j" {
+		t.Errorf("bad round trip %s", encoded)
+	}
+}
+
+func TestImdnHeaders(t *testing.T) {
+	cpim, err := ParseCpim("From: <im:alice@example.com>\r\n" +
+		"To: <im:bob@example.com>\r\n" +
+		"NS: x <urn:ietf:params:imdn>\r\n" +
+		"x.Message-ID: 34jk324j\r\n" +
+		"x.Disposition-Notification: positive-delivery, Display\r\n" +
+		"\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if GetImdnHeader(cpim, IMDN_MESSAGE_ID) != "34jk324j" {
+		t.Errorf("bad message id %+v", cpim.Headers)
+	}
+	dispositions := GetDispositionNotification(cpim)
+	if len(dispositions) != 2 || dispositions[0] != DISPOSITION_POSITIVE_DELIVERY || dispositions[1] != DISPOSITION_DISPLAY {
+		t.Errorf("bad dispositions %v", dispositions)
+	}
+
+	cpim = NewCpimMessage("<im:alice@example.com>", "<im:bob@example.com>", "text/plain", "hi")
+	SetImdnHeader(cpim, IMDN_MESSAGE_ID, "1")
+	SetImdnHeader(cpim, IMDN_MESSAGE_ID, "2")
+	if len(cpim.GetHeaders(CPIM_NS)) != 1 || cpim.GetHeader("imdn.Message-ID") != "2" || len(cpim.GetHeaders("imdn.Message-ID")) != 1 {
+		t.Errorf("bad headers %+v", cpim.Encode())
+	}
+}
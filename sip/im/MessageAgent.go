@@ -0,0 +1,358 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : MessageAgent.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package im
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * The largest MESSAGE request sent or accepted over a transport without
+ * congestion control, i.e. UDP, when the path MTU is unknown (RFC 3428
+ * section 8).
+ */
+const MAX_UDP_MESSAGE_SIZE = 1300
+
+/**
+ * Content types of instant messages.
+ */
+const (
+	TEXT_PLAIN_CONTENT_TYPE = "text/plain"
+	CPIM_CONTENT_TYPE       = "message/cpim"
+)
+
+/**
+ * This interface is implemented by applications that send and receive
+ * instant messages with a MessageAgent.
+ */
+type MessageListener interface {
+	/**
+	 * Called for every instant message received.
+	 */
+	ProcessMessage(msg *InstantMessage)
+
+	/**
+	 * Called for every disposition notification received. request is the
+	 * MESSAGE that carried it.
+	 */
+	ProcessNotification(notification *Imdn, request *message.SIPRequest)
+
+	/**
+	 * Returns a new MESSAGE request without body addressed to to, used by the
+	 * agent to send disposition notifications on its own.
+	 */
+	CreateMessage(to address.Address) (message.Request, error)
+}
+
+/**
+ * A received instant message: the content of a MESSAGE, either directly as a
+ * "text/plain" body or wrapped in a "message/cpim" body.
+ */
+type InstantMessage struct {
+	request *message.SIPRequest
+	cpim    *CpimMessage
+}
+
+/** Returns the MESSAGE request that carried the message.
+ */
+func (this *InstantMessage) GetRequest() *message.SIPRequest {
+	return this.request
+}
+
+/** Returns the CPIM wrapper of the message, or nil if there is none.
+ */
+func (this *InstantMessage) GetCpim() *CpimMessage {
+	return this.cpim
+}
+
+/** Returns the media type of the content without parameters, e.g.
+ * "text/plain".
+ */
+func (this *InstantMessage) GetContentType() string {
+	if this.cpim != nil {
+		return this.cpim.GetContentType()
+	}
+	return getContentType(this.request)
+}
+
+/** Returns the content of the message.
+ */
+func (this *InstantMessage) GetContent() string {
+	if this.cpim != nil {
+		return this.cpim.Content
+	}
+	return this.request.GetContent()
+}
+
+/** Returns the IMDN message id of the message, or an empty string if it has
+ * none.
+ */
+func (this *InstantMessage) GetMessageId() string {
+	if this.cpim == nil {
+		return ""
+	}
+	return GetImdnHeader(this.cpim, IMDN_MESSAGE_ID)
+}
+
+/** Returns true if the sender asked for the given disposition notification,
+ * e.g. DISPOSITION_DISPLAY.
+ */
+func (this *InstantMessage) WantsNotification(disposition string) bool {
+	if this.cpim == nil || this.GetMessageId() == "" {
+		return false
+	}
+	for _, d := range GetDispositionNotification(this.cpim) {
+		if d == disposition {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Sends and receives page-mode instant messages (RFC 3428). Messages asking
+ * for disposition notifications are wrapped in CPIM (RFC 3862) and carry the
+ * IMDN headers of RFC 5438; the agent answers positive-delivery requests
+ * itself once a message has been handed to the listener, while display
+ * notifications are sent by the application with SendNotification.
+ * <p>
+ * Requests over transports without congestion control are limited to
+ * MAX_UDP_MESSAGE_SIZE bytes: larger ones are not sent, and are rejected with
+ * 513 when received.
+ */
+type MessageAgent struct {
+	mutex sync.Mutex
+
+	provider sip.SipProvider
+	listener MessageListener
+}
+
+/** Creates a message agent sending its requests through provider.
+ */
+func NewMessageAgent(provider sip.SipProvider) *MessageAgent {
+	return &MessageAgent{provider: provider}
+}
+
+/** Sets the listener of the agent.
+ */
+func (this *MessageAgent) SetMessageListener(listener MessageListener) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.listener = listener
+}
+
+/** Sends content as the body of a MESSAGE created by the application. Without
+ * dispositions the content is sent as is; otherwise it is wrapped in CPIM
+ * with a new message id, which is returned, and a Disposition-Notification
+ * header asking for dispositions.
+ */
+func (this *MessageAgent) Send(request message.Request, contentType header.ContentTypeHeader, content string, dispositions ...string) (messageId string, SipException error) {
+	if contentType == nil {
+		return "", errors.New("SipException: content without type")
+	}
+	if len(dispositions) == 0 {
+		request.SetContent(content, contentType)
+		return "", this.send(request)
+	}
+
+	from, ok := request.GetHeader(core.SIPHeaderNames_FROM).(header.FromHeader)
+	to, ok2 := request.GetHeader(core.SIPHeaderNames_TO).(header.ToHeader)
+	if !ok || !ok2 {
+		return "", errors.New("SipException: MESSAGE without From or To")
+	}
+	cpim := NewCpimMessage(nameAddr(from.GetAddress()), nameAddr(to.GetAddress()), contentType.EncodeBody(), content)
+	cpim.AddHeader(CPIM_DATETIME, time.Now().Format(time.RFC3339))
+	messageId = sip.NewToken(8)
+	SetImdnHeader(cpim, IMDN_MESSAGE_ID, messageId)
+	SetImdnHeader(cpim, IMDN_DISPOSITION_NOTIFICATION, strings.Join(dispositions, ", "))
+	request.SetContent(cpim.Encode(), newContentType(CPIM_CONTENT_TYPE))
+	return messageId, this.send(request)
+}
+
+/** Sends a disposition notification with status, e.g. IMDN_DISPLAYED, for
+ * msg to its sender, if the sender asked for it.
+ */
+func (this *MessageAgent) SendNotification(msg *InstantMessage, status string) (SipException error) {
+	disposition := DISPOSITION_NEGATIVE_DELIVERY
+	switch status {
+	case IMDN_DELIVERED:
+		disposition = DISPOSITION_POSITIVE_DELIVERY
+	case IMDN_DISPLAYED:
+		disposition = DISPOSITION_DISPLAY
+	}
+	if !msg.WantsNotification(disposition) {
+		return errors.New("SipException: the sender did not ask for " + disposition + " notifications")
+	}
+
+	this.mutex.Lock()
+	listener := this.listener
+	this.mutex.Unlock()
+	if listener == nil {
+		return errors.New("SipException: no message listener")
+	}
+
+	dateTime := msg.cpim.GetHeader(CPIM_DATETIME)
+	var imdn *Imdn
+	if disposition == DISPOSITION_DISPLAY {
+		imdn = NewDisplayNotification(msg.GetMessageId(), dateTime, status)
+	} else {
+		imdn = NewDeliveryNotification(msg.GetMessageId(), dateTime, status)
+	}
+	imdn.RecipientURI = stripNameAddr(msg.cpim.GetHeader(CPIM_TO))
+	body, err := imdn.Encode()
+	if err != nil {
+		return err
+	}
+
+	cpim := NewCpimMessage(msg.cpim.GetHeader(CPIM_TO), msg.cpim.GetHeader(CPIM_FROM), IMDN_CONTENT_TYPE, body)
+	cpim.AddHeader(CPIM_DATETIME, time.Now().Format(time.RFC3339))
+	SetImdnHeader(cpim, IMDN_MESSAGE_ID, sip.NewToken(8))
+	cpim.SetContentHeader("Content-Disposition", DISPOSITION_NOTIFICATION)
+
+	request, err := listener.CreateMessage(msg.request.GetFrom().GetAddress())
+	if err != nil {
+		return err
+	}
+	request.SetContent(cpim.Encode(), newContentType(CPIM_CONTENT_TYPE))
+	return this.send(request)
+}
+
+/** Processes a received MESSAGE and returns the response to send: 200 once
+ * the message or notification has been handed to the listener, 513 for a
+ * too large request over a transport without congestion control, 415 with
+ * Accept for an unsupported content type and 400 for a bad body.
+ */
+func (this *MessageAgent) ProcessMessage(request *message.SIPRequest) *message.SIPResponse {
+	if !IsCongestionControlled(request) && len(request.EncodeAsBytes()) > MAX_UDP_MESSAGE_SIZE {
+		return request.CreateResponse(message.MESSAGE_TOO_LARGE)
+	}
+
+	this.mutex.Lock()
+	listener := this.listener
+	this.mutex.Unlock()
+
+	msg := &InstantMessage{request: request}
+	switch getContentType(request) {
+	case TEXT_PLAIN_CONTENT_TYPE:
+	case CPIM_CONTENT_TYPE:
+		cpim, err := ParseCpim(request.GetContent())
+		if err != nil {
+			return request.CreateResponse(message.BAD_REQUEST)
+		}
+		msg.cpim = cpim
+		if isNotification(request, cpim) {
+			imdn, err := ParseImdn(cpim.Content)
+			if err != nil {
+				return request.CreateResponse(message.BAD_REQUEST)
+			}
+			if listener != nil {
+				listener.ProcessNotification(imdn, request)
+			}
+			return request.CreateResponse(message.OK)
+		}
+	default:
+		response := request.CreateResponse(message.UNSUPPORTED_MEDIA_TYPE)
+		acceptList := header.NewAcceptList()
+		for _, contentType := range []string{TEXT_PLAIN_CONTENT_TYPE, CPIM_CONTENT_TYPE} {
+			accept := header.NewAccept()
+			types := strings.Split(contentType, "/")
+			accept.SetContentType(types[0])
+			accept.SetContentSubType(types[1])
+			acceptList.PushBack(accept)
+		}
+		response.SetHeader(acceptList)
+		return response
+	}
+
+	if listener != nil {
+		listener.ProcessMessage(msg)
+		if msg.WantsNotification(DISPOSITION_POSITIVE_DELIVERY) {
+			this.SendNotification(msg, IMDN_DELIVERED)
+		}
+	}
+	return request.CreateResponse(message.OK)
+}
+
+/** Returns true if the topmost Via of msg names a transport with congestion
+ * control, e.g. TCP or TLS.
+ */
+func IsCongestionControlled(msg message.Message) bool {
+	via, ok := msg.GetHeader(core.SIPHeaderNames_VIA).(*header.Via)
+	if !ok {
+		return false
+	}
+	switch strings.ToUpper(via.GetTransport()) {
+	case sip.TCP, sip.TLS, sip.SCTP:
+		return true
+	}
+	return false
+}
+
+func (this *MessageAgent) send(request message.Request) error {
+	if !IsCongestionControlled(request) {
+		if r, ok := request.(*message.SIPRequest); ok && len(r.EncodeAsBytes()) > MAX_UDP_MESSAGE_SIZE {
+			return errors.New("SipException: MESSAGE too large for a transport without congestion control")
+		}
+	}
+	ct, err := this.provider.GetNewClientTransaction(request)
+	if err != nil {
+		return err
+	}
+	return ct.SendRequest()
+}
+
+/** A notification is marked with "Content-Disposition: notification", either
+ * in the CPIM content headers or in the MESSAGE itself.
+ */
+func isNotification(request *message.SIPRequest, cpim *CpimMessage) bool {
+	if strings.EqualFold(strings.TrimSpace(strings.Split(cpim.GetContentHeader("Content-Disposition"), ";")[0]), DISPOSITION_NOTIFICATION) {
+		return true
+	}
+	disposition, ok := request.GetHeader(core.SIPHeaderNames_CONTENT_DISPOSITION).(header.ContentDispositionHeader)
+	return ok && strings.EqualFold(disposition.GetDispositionType(), DISPOSITION_NOTIFICATION)
+}
+
+func getContentType(msg message.Message) string {
+	contentType, ok := msg.GetHeader(core.SIPHeaderNames_CONTENT_TYPE).(header.ContentTypeHeader)
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(contentType.GetContentType() + "/" + contentType.GetContentSubType())
+}
+
+func newContentType(contentType string) header.ContentTypeHeader {
+	types := strings.Split(contentType, "/")
+	return header.NewContentTypeFromString(types[0], types[1])
+}
+
+func nameAddr(a address.Address) string {
+	if a.GetDisplayName() != "" {
+		return "\"" + a.GetDisplayName() + "\" <" + a.GetURI().String() + ">"
+	}
+	return "<" + a.GetURI().String() + ">"
+}
+
+/** Returns the URI of a CPIM name-addr, e.g. "im:bob@example.com" for
+ * "Bob &lt;im:bob@example.com&gt;".
+ */
+func stripNameAddr(s string) string {
+	if i := strings.Index(s, "<"); i >= 0 {
+		if j := strings.Index(s[i:], ">"); j >= 0 {
+			return s[i+1 : i+j]
+		}
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,143 @@
+package im
+
+import (
+	"gosips/sip"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const messageRequest = "MESSAGE sip:bob@b.example.com SIP/2.0\r\n" +
+	"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bKmessage\r\n" +
+	"From: \"Alice\" <sip:alice@a.example.com>;tag=alice-tag\r\n" +
+	"To: <sip:bob@b.example.com>\r\n" +
+	"Call-ID: message@a.example.com\r\n" +
+	"CSeq: 1 MESSAGE\r\n" +
+	"Max-Forwards: 70\r\n" +
+	"Content-Length: 0\r\n\r\n"
+
+func parseRequest(t *testing.T, s string) *message.SIPRequest {
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg.(*message.SIPRequest)
+}
+
+func withBody(contentType, body string) string {
+	return strings.Replace(messageRequest, "Content-Length: 0\r\n\r\n",
+		"Content-Type: "+contentType+"\r\nContent-Length: "+strconv.Itoa(len(body))+"\r\n\r\n"+body, 1)
+}
+
+type fakeClientTransaction struct {
+	sip.ClientTransaction
+}
+
+func (this *fakeClientTransaction) SendRequest() error {
+	return nil
+}
+
+// fakeProvider records the MESSAGEs sent as received by the peer.
+type fakeProvider struct {
+	sip.SipProvider
+	t    *testing.T
+	sent []*message.SIPRequest
+}
+
+func (this *fakeProvider) GetNewClientTransaction(request message.Request) (sip.ClientTransaction, error) {
+	this.sent = append(this.sent, parseRequest(this.t, request.String()))
+	return &fakeClientTransaction{}, nil
+}
+
+type messageRecorder struct {
+	t             *testing.T
+	messages      []*InstantMessage
+	notifications []*Imdn
+}
+
+func (this *messageRecorder) ProcessMessage(msg *InstantMessage) {
+	this.messages = append(this.messages, msg)
+}
+
+func (this *messageRecorder) ProcessNotification(imdn *Imdn, request *message.SIPRequest) {
+	this.notifications = append(this.notifications, imdn)
+}
+
+func (this *messageRecorder) CreateMessage(to address.Address) (message.Request, error) {
+	return parseRequest(this.t, strings.Replace(messageRequest, "sip:bob@b.example.com SIP", to.GetURI().String()+" SIP", 1)), nil
+}
+
+func TestMessageAgent(t *testing.T) {
+	alice, bob := &fakeProvider{t: t}, &fakeProvider{t: t}
+	aliceAgent, bobAgent := NewMessageAgent(alice), NewMessageAgent(bob)
+	aliceRecorder, bobRecorder := &messageRecorder{t: t}, &messageRecorder{t: t}
+	aliceAgent.SetMessageListener(aliceRecorder)
+	bobAgent.SetMessageListener(bobRecorder)
+	textPlain := header.NewContentTypeFromString("text", "plain")
+
+	if _, err := aliceAgent.Send(parseRequest(t, messageRequest), nil, "Hello", DISPOSITION_DISPLAY); err == nil {
+		t.Error("content without type sent")
+	}
+	messageId, err := aliceAgent.Send(parseRequest(t, messageRequest), textPlain, "Hello",
+		DISPOSITION_POSITIVE_DELIVERY, DISPOSITION_DISPLAY)
+	if err != nil || messageId == "" {
+		t.Fatal("message not sent", err)
+	}
+	if response := bobAgent.ProcessMessage(alice.sent[0]); response.GetStatusCode() != message.OK {
+		t.Fatal("MESSAGE rejected with", response.GetStatusCode())
+	}
+	if len(bobRecorder.messages) != 1 {
+		t.Fatal("message not delivered")
+	}
+	msg := bobRecorder.messages[0]
+	if msg.GetContent() != "Hello" || msg.GetContentType() != "text/plain" || msg.GetMessageId() != messageId {
+		t.Error("bad message", msg.GetContent(), msg.GetContentType(), msg.GetMessageId())
+	}
+
+	// the delivery notification is sent at once, the display one on request
+	if len(bob.sent) != 1 {
+		t.Fatal("no delivery notification sent")
+	}
+	if err := bobAgent.SendNotification(msg, IMDN_DISPLAYED); err != nil {
+		t.Fatal(err)
+	}
+	for _, notification := range bob.sent {
+		if response := aliceAgent.ProcessMessage(notification); response.GetStatusCode() != message.OK {
+			t.Fatal("notification rejected with", response.GetStatusCode())
+		}
+	}
+	if notifications := aliceRecorder.notifications; len(notifications) != 2 ||
+		notifications[0].GetStatus() != IMDN_DELIVERED || notifications[1].GetStatus() != IMDN_DISPLAYED ||
+		notifications[0].MessageId != messageId || len(aliceRecorder.messages) != 0 {
+		t.Error("bad notifications", notifications)
+	}
+
+	// a plain message asks for no notification
+	bobAgent.ProcessMessage(parseRequest(t, withBody("text/plain", "hi")))
+	if len(bobRecorder.messages) != 2 || bobRecorder.messages[1].WantsNotification(DISPOSITION_DISPLAY) {
+		t.Fatal("bad plain message")
+	}
+	if err := bobAgent.SendNotification(bobRecorder.messages[1], IMDN_DISPLAYED); err == nil {
+		t.Error("notification sent without request")
+	}
+
+	if response := bobAgent.ProcessMessage(parseRequest(t, withBody("text/html", "hi"))); response.GetStatusCode() != message.UNSUPPORTED_MEDIA_TYPE {
+		t.Error("unsupported content accepted with", response.GetStatusCode())
+	}
+
+	// large messages need congestion control
+	large := strings.Repeat("x", MAX_UDP_MESSAGE_SIZE)
+	if _, err := aliceAgent.Send(parseRequest(t, messageRequest), textPlain, large); err == nil {
+		t.Error("large message sent over UDP")
+	}
+	if _, err := aliceAgent.Send(parseRequest(t, strings.Replace(messageRequest, "UDP", "TCP", 1)), textPlain, large); err != nil {
+		t.Error("large message not sent over TCP", err)
+	}
+	if response := bobAgent.ProcessMessage(parseRequest(t, withBody("text/plain", large))); response.GetStatusCode() != message.MESSAGE_TOO_LARGE {
+		t.Error("large message accepted over UDP with", response.GetStatusCode())
+	}
+}
@@ -440,4 +440,19 @@ const PRACK = "PRACK"
  */
 const UPDATE = "UPDATE"
 
+/**
+ * PUBLISH is an extension method (RFC 3903) that publishes event state to
+ * an Event State Compositor, which composes the state published by all the
+ * sources of a resource and distributes it to the subscribers of the event
+ * package. The Request-URI identifies the resource and the Event header the
+ * event package. An initial PUBLISH carries the event state in its body; the
+ * 2xx response gives an entity tag for the publication in a SIP-ETag header.
+ * The publication is then refreshed, modified or removed (with "Expires: 0")
+ * by PUBLISH requests giving the entity tag in a SIP-If-Match header; a
+ * refresh carries no body. An unknown entity tag is rejected with 412.
+ *
+ *
+ */
+const PUBLISH = "PUBLISH"
+
 //}
@@ -132,13 +132,21 @@ package message
  * <LI>PROXY_AUTHENTICATION_REQUIRED - 407</LI>
  * <LI>REQUEST_TIMEOUT - 408</LI>
  * <LI>GONE - 410</LI>
+ * <LI>CONDITIONAL_REQUEST_FAILED - 412</LI>
  * <LI>REQUEST_ENTITY_TOO_LARGE - 413
  * <LI>REQUEST_URI_TOO_LONG - 414
  * <LI>UNSUPPORTED_MEDIA_TYPE - 415</LI>
  * <LI>UNSUPPORTED_URI_SCHEME - 416
  * <LI>BAD_EXTENSION - 420</LI>
  * <LI>EXTENSION_REQUIRED - 421
+ * <LI>SESSION_INTERVAL_TOO_SMALL - 422</LI>
  * <LI>INTERVAL_TOO_BRIEF - 423
+ * <LI>USE_IDENTITY_HEADER - 428</LI>
+ * <LI>FLOW_FAILED - 430</LI>
+ * <LI>BAD_IDENTITY_INFO - 436</LI>
+ * <LI>UNSUPPORTED_CREDENTIAL - 437</LI>
+ * <LI>INVALID_IDENTITY_HEADER - 438</LI>
+ * <LI>FIRST_HOP_LACKS_OUTBOUND_SUPPORT - 439</LI>
  * <LI>TEMPORARILY_UNAVAILABLE - 480</LI>
  * <LI>CALL_OR_TRANSACTION_DOES_NOT_EXIST - 481</LI>
  * <LI>LOOP_DETECTED - 482</LI>
@@ -433,6 +441,13 @@ const REQUEST_TIMEOUT = 408
  */
 const GONE = 410
 
+/**
+ * The precondition given in a SIP-If-Match header of a PUBLISH request
+ * (RFC 3903) was not met: the entity tag does not match any publication of
+ * the event state compositor, e.g. because the publication expired.
+ */
+const CONDITIONAL_REQUEST_FAILED = 412
+
 /**
  * The server is refusing to process a request because the request
  * entity-body is larger than the server is willing or able to process. The
@@ -493,6 +508,16 @@ const BAD_EXTENSION = 420
  */
 const EXTENSION_REQUIRED = 421
 
+/**
+ * The server is rejecting the request because the session interval in the
+ * Session-Expires header field is smaller than the minimum it is willing to
+ * accept, which is given in the Min-SE header field of the response. This
+ * response is defined by RFC 4028.
+ *
+ *
+ */
+const SESSION_INTERVAL_TOO_SMALL = 422
+
 /**
  * The server is rejecting the request because the expiration time of the
  * resource refreshed by the request is too short. This response can be
@@ -503,6 +528,58 @@ const EXTENSION_REQUIRED = 421
  */
 const INTERVAL_TOO_BRIEF = 423
 
+/**
+ * The server requires an Identity header field in the request, which is not
+ * present. This response is defined by RFC 8224.
+ *
+ *
+ */
+const USE_IDENTITY_HEADER = 428
+
+/**
+ * The flow identified by the flow token of the request no longer exists,
+ * e.g. its connection was closed. The proxy that sent the request can try
+ * another flow of the same User Agent instance. This response is defined by
+ * RFC 5626.
+ *
+ *
+ */
+const FLOW_FAILED = 430
+
+/**
+ * The certificate referenced by the info parameter of the Identity header
+ * field could not be obtained. This response is defined by RFC 8224.
+ *
+ *
+ */
+const BAD_IDENTITY_INFO = 436
+
+/**
+ * The credential referenced by the Identity header field was obtained but
+ * cannot be validated, e.g. it is not issued by a trusted authority or does
+ * not cover the identity of the originator. This response is defined by
+ * RFC 8224.
+ *
+ *
+ */
+const UNSUPPORTED_CREDENTIAL = 437
+
+/**
+ * The signature of the Identity header field does not match the request.
+ * This response is defined by RFC 8224.
+ *
+ *
+ */
+const INVALID_IDENTITY_HEADER = 438
+
+/**
+ * The registrar supports outbound but the first edge proxy on the path of
+ * the REGISTER does not. This response is defined by RFC 5626.
+ *
+ *
+ */
+const FIRST_HOP_LACKS_OUTBOUND_SUPPORT = 439
+
 /**
  * The callee's end system was contacted successfully but the callee is
  * currently unavailable (for example, is not logged in, logged in but in a
@@ -30,6 +30,9 @@ type SIPRequest struct {
 	requestLine *header.RequestLine
 }
 
+// a SIPRequest is a Request
+var _ Request = (*SIPRequest)(nil)
+
 /** Constructor.
  */
 func NewSIPRequest() *SIPRequest {
@@ -215,7 +218,7 @@ func (this *SIPRequest) SetRequestURI(uri address.URI) {
 // *@param method is the method to Set.
 // *@throws IllegalArgumentException if the method is nil
 // */
-func (this *SIPRequest) SetMethod(method string) {
+func (this *SIPRequest) SetMethod(method string) (ParseException error) {
 	//if method == nil
 	//  throw new IllegalArgumentException("nil method");
 	if this.requestLine == nil {
@@ -227,6 +230,7 @@ func (this *SIPRequest) SetMethod(method string) {
 		this.cSeqHeader.SetMethod(method)
 		//}catch(ParseException e){}
 	}
+	return nil
 }
 
 // /** Get the method from the request line.
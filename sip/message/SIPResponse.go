@@ -20,6 +20,9 @@ type SIPResponse struct {
 	statusLine *header.StatusLine
 }
 
+// a SIPResponse is a Response
+var _ Response = (*SIPResponse)(nil)
+
 /** Constructor.
  */
 func NewSIPResponse() *SIPResponse {
@@ -97,6 +100,9 @@ func (this *SIPResponse) GetReasonPhraseFromInt(rc int) string {
 	case GONE:
 		retval = "Gone"
 
+	case CONDITIONAL_REQUEST_FAILED:
+		retval = "Conditional Request Failed"
+
 	case TEMPORARILY_UNAVAILABLE:
 		retval = "Temporarily Unavailable"
 
@@ -121,6 +127,27 @@ func (this *SIPResponse) GetReasonPhraseFromInt(rc int) string {
 	case INTERVAL_TOO_BRIEF:
 		retval = "Interval too brief"
 
+	case SESSION_INTERVAL_TOO_SMALL:
+		retval = "Session interval too small"
+
+	case USE_IDENTITY_HEADER:
+		retval = "Use Identity Header"
+
+	case BAD_IDENTITY_INFO:
+		retval = "Bad Identity Info"
+
+	case UNSUPPORTED_CREDENTIAL:
+		retval = "Unsupported Credential"
+
+	case INVALID_IDENTITY_HEADER:
+		retval = "Invalid Identity Header"
+
+	case FLOW_FAILED:
+		retval = "Flow Failed"
+
+	case FIRST_HOP_LACKS_OUTBOUND_SUPPORT:
+		retval = "First Hop Lacks Outbound Support"
+
 	case CALL_OR_TRANSACTION_DOES_NOT_EXIST:
 		retval = "Call leg/Transaction does not exist"
 
@@ -199,13 +226,14 @@ func (this *SIPResponse) GetReasonPhraseFromInt(rc int) string {
 //     *@param statusCode is the status code to Set.
 //     *@throws IlegalArgumentException if invalid status code.
 //     */
-func (this *SIPResponse) SetStatusCode(statusCode int) { //throws ParseException {
+func (this *SIPResponse) SetStatusCode(statusCode int) (ParseException error) {
 	// if (statusCode < 100 || statusCode > 800)
 	//     throw new ParseException("bad status code",0);
 	if this.statusLine == nil {
 		this.statusLine = header.NewStatusLine()
 	}
 	this.statusLine.SetStatusCode(statusCode)
+	return nil
 }
 
 //    /**
@@ -227,13 +255,14 @@ func (this *SIPResponse) GetStatusCode() int {
 //     *@param reasonPhrase the reason phrase.
 //     *@throws IllegalArgumentException if nil string
 //     */
-func (this *SIPResponse) SetReasonPhrase(reasonPhrase string) {
+func (this *SIPResponse) SetReasonPhrase(reasonPhrase string) (ParseException error) {
 	//if this.reasonPhrase == nil)
 	//    throw new IllegalArgumentException("Bad reason phrase");
 	if this.statusLine == nil {
 		this.statusLine = header.NewStatusLine()
 	}
 	this.statusLine.SetReasonPhrase(reasonPhrase)
+	return nil
 }
 
 //    /** Get the reason phrase.
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for a list of Accept-Contact headers.
+ */
+type AcceptContactParser struct {
+	HeaderParser
+}
+
+/** Creates a new instance of AcceptContactParser
+ * @param acceptContact the header to parse
+ */
+func NewAcceptContactParser(acceptContact string) *AcceptContactParser {
+	this := &AcceptContactParser{}
+	this.HeaderParser.super(acceptContact)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewAcceptContactParserFromLexer(lexer core.Lexer) *AcceptContactParser {
+	this := &AcceptContactParser{}
+	this.HeaderParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (AcceptContactList object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *AcceptContactParser) Parse() (sh header.Header, ParseException error) {
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_ACCEPT_CONTACT)
+
+	acceptContactList := header.NewAcceptContactList()
+	for {
+		acceptContact := header.NewAcceptContact()
+		if ParseException = this.featurePredicate(acceptContact); ParseException != nil {
+			return nil, ParseException
+		}
+		acceptContactList.PushBack(acceptContact)
+		if ch, _ := lexer.LookAheadK(0); ch == ',' {
+			lexer.Match(',')
+			lexer.SPorHT()
+		} else {
+			break
+		}
+	}
+	if _, ParseException = lexer.Match('\n'); ParseException != nil {
+		return nil, ParseException
+	}
+
+	return acceptContactList, nil
+}
+
+/** The headers made of a feature predicate (RFC 3841).
+ */
+type featurePredicateHeader interface {
+	SetFeatureTag(name, value string)
+	SetParameter(name, value string) (ParseException error)
+}
+
+/** Parses a feature predicate of the Accept-Contact and Reject-Contact
+ * headers: a wildcard followed by feature tags and generic parameters. The
+ * quoted values are feature tag values, the parameters without value are
+ * boolean features or the require and explicit flags.
+ */
+func (this *HeaderParser) featurePredicate(predicate featurePredicateHeader) (ParseException error) {
+	lexer := this.GetLexer()
+	if _, ParseException = lexer.Match('*'); ParseException != nil {
+		return ParseException
+	}
+	lexer.SPorHT()
+	for ch, _ := lexer.LookAheadK(0); ch == ';'; ch, _ = lexer.LookAheadK(0) {
+		lexer.Match(';')
+		lexer.SPorHT()
+		name := lexer.Ttoken()
+		if name == "" {
+			return errors.New("ParseException: missing feature tag name")
+		}
+		lexer.SPorHT()
+		if ch, _ = lexer.LookAheadK(0); ch != '=' {
+			predicate.SetFeatureTag(name, "")
+			continue
+		}
+		lexer.Match('=')
+		lexer.SPorHT()
+		if ch, _ = lexer.LookAheadK(0); ch == '"' {
+			value, err := lexer.QuotedString()
+			if err != nil {
+				return errors.New("ParseException: unterminated feature tag value")
+			}
+			predicate.SetFeatureTag(name, value)
+		} else {
+			predicate.SetParameter(name, lexer.Ttoken())
+		}
+		lexer.SPorHT()
+	}
+	return nil
+}
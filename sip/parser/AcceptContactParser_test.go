@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestAcceptContactParser(t *testing.T) {
+	var tvi = []string{
+		"Accept-Contact: *;audio;require\n",
+		"Accept-Contact: * ; video ; methods=\"INVITE,BYE\" ; explicit , *;+sip.actor=\"msg-taker\"\n",
+		"a: *;mobility=\"mobile\";require;explicit\n",
+	}
+	var tvo = []string{
+		"Accept-Contact: *;audio;require\n",
+		"Accept-Contact: *;video;methods=\"INVITE,BYE\";explicit,*;+sip.actor=\"msg-taker\"\n",
+		"Accept-Contact: *;mobility=\"mobile\";require;explicit\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewAcceptContactParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
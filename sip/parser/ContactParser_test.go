@@ -20,6 +20,9 @@ func TestContactParser(t *testing.T) {
 			"\n",
 		"Contact:*\n",
 		"Contact:BigGuy<sip:utente@127.0.0.1;5000>;Expires=3600\n",
+		"Contact: <sip:callee@192.0.2.1>;pub-gruu=\"sip:callee@example.com;gr=urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6\"" +
+			";temp-gruu=\"sip:tgruu.7hs==jd7vnzga5w7fajsc7-ajd6fabz0f8g5@example.com;gr\"" +
+			";+sip.instance=\"<urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6>\";expires=3600\n",
 	}
 	var tvo = []string{
 		"Contact: <sip:utente@127.0.0.1:5000;transport=udp>;expires=3600\n",
@@ -36,6 +39,9 @@ func TestContactParser(t *testing.T) {
 			"\n",
 		"Contact: <*>\n",
 		"Contact: \"BigGuy\" <sip:utente@127.0.0.1;5000>;Expires=3600\n",
+		"Contact: <sip:callee@192.0.2.1>;pub-gruu=\"sip:callee@example.com;gr=urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6\"" +
+			";temp-gruu=\"sip:tgruu.7hs==jd7vnzga5w7fajsc7-ajd6fabz0f8g5@example.com;gr\"" +
+			";+sip.instance=\"<urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6>\";expires=3600\n",
 	}
 
 	for i := 0; i < len(tvi); i++ {
@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for a list of Diversion headers.
+ */
+type DiversionParser struct {
+	AddressParametersParser
+}
+
+/** Constructor
+ * @param String diversion message to parse to set
+ */
+func NewDiversionParser(diversion string) *DiversionParser {
+	this := &DiversionParser{}
+	this.AddressParametersParser.super(diversion)
+	return this
+}
+
+func NewDiversionParserFromLexer(lexer core.Lexer) *DiversionParser {
+	this := &DiversionParser{}
+	this.AddressParametersParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message and generate the Diversion List Object
+ * @return SIPHeader the Diversion List object
+ * @throws ParseException if errors occur during the parsing
+ */
+func (this *DiversionParser) Parse() (sh header.Header, ParseException error) {
+	diversionList := header.NewDiversionList()
+
+	var ch byte
+	lexer := this.GetLexer()
+	lexer.Match(TokenTypes_DIVERSION)
+	lexer.SPorHT()
+	lexer.Match(':')
+	lexer.SPorHT()
+	for {
+		diversion := header.NewDiversion()
+		this.AddressParametersParser.Parse(diversion)
+		diversionList.PushBack(diversion)
+		lexer.SPorHT()
+		if ch, _ = lexer.LookAheadK(0); ch == ',' {
+			lexer.Match(',')
+			lexer.SPorHT()
+		} else if ch, _ = lexer.LookAheadK(0); ch == '\n' {
+			break
+		} else {
+			return nil, this.CreateParseException("unexpected char")
+		}
+	}
+
+	return diversionList, nil
+}
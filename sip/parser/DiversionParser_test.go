@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestDiversionParser(t *testing.T) {
+	var tvi = []string{
+		"Diversion: <sip:+15555551002@example.com;user=phone>;reason=user-busy;counter=1;privacy=off\n",
+		"Diversion: \"Alice\" <sip:alice@example.com>;reason=no-answer;screen=no,<sip:bob@example.com>;reason=unconditional\n",
+	}
+	var tvo = []string{
+		"Diversion: <sip:+15555551002@example.com;user=phone>;reason=user-busy;counter=1;privacy=off\n",
+		"Diversion: \"Alice\" <sip:alice@example.com>;reason=no-answer;screen=no,<sip:bob@example.com>;reason=unconditional\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewDiversionParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
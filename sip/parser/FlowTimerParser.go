@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for FlowTimer header.
+ */
+type FlowTimerParser struct {
+	HeaderParser
+}
+
+/** protected constructor.
+ *@param text is the text of the header to parse
+ */
+func NewFlowTimerParser(flowTimer string) *FlowTimerParser {
+	this := &FlowTimerParser{}
+	this.HeaderParser.super(flowTimer)
+	return this
+}
+
+/** constructor.
+ *@param lexer is the lexer passed in from the enclosing parser.
+ */
+func NewFlowTimerParserFromLexer(lexer core.Lexer) *FlowTimerParser {
+	this := &FlowTimerParser{}
+	this.HeaderParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (FlowTimer)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *FlowTimerParser) Parse() (sh header.Header, ParseException error) {
+	flowTimer := header.NewFlowTimer()
+
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_FLOW_TIMER)
+
+	var number int
+	if number, ParseException = lexer.Number(); ParseException != nil {
+		return nil, ParseException
+	}
+	if ParseException = flowTimer.SetSeconds(number); ParseException != nil {
+		return nil, ParseException
+	}
+
+	lexer.SPorHT()
+
+	lexer.Match('\n')
+
+	return flowTimer, nil
+}
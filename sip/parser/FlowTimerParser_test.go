@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestFlowTimerParser(t *testing.T) {
+	var tvi = []string{
+		"Flow-Timer: 120\n",
+		"Flow-Timer:  25 \n",
+	}
+	var tvo = []string{
+		"Flow-Timer: 120\n",
+		"Flow-Timer: 25\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewFlowTimerParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for a list of HistoryInfo headers.
+ */
+type HistoryInfoParser struct {
+	AddressParametersParser
+}
+
+/** Constructor
+ * @param String historyInfo message to parse to set
+ */
+func NewHistoryInfoParser(historyInfo string) *HistoryInfoParser {
+	this := &HistoryInfoParser{}
+	this.AddressParametersParser.super(historyInfo)
+	return this
+}
+
+func NewHistoryInfoParserFromLexer(lexer core.Lexer) *HistoryInfoParser {
+	this := &HistoryInfoParser{}
+	this.AddressParametersParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message and generate the HistoryInfo List Object
+ * @return SIPHeader the HistoryInfo List object
+ * @throws ParseException if errors occur during the parsing
+ */
+func (this *HistoryInfoParser) Parse() (sh header.Header, ParseException error) {
+	historyInfoList := header.NewHistoryInfoList()
+
+	var ch byte
+	lexer := this.GetLexer()
+	lexer.Match(TokenTypes_HISTORY_INFO)
+	lexer.SPorHT()
+	lexer.Match(':')
+	lexer.SPorHT()
+	for {
+		historyInfo := header.NewHistoryInfo()
+		this.AddressParametersParser.Parse(historyInfo)
+		historyInfoList.PushBack(historyInfo)
+		lexer.SPorHT()
+		if ch, _ = lexer.LookAheadK(0); ch == ',' {
+			lexer.Match(',')
+			lexer.SPorHT()
+		} else if ch, _ = lexer.LookAheadK(0); ch == '\n' {
+			break
+		} else {
+			return nil, this.CreateParseException("unexpected char")
+		}
+	}
+
+	return historyInfoList, nil
+}
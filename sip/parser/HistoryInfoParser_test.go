@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestHistoryInfoParser(t *testing.T) {
+	var tvi = []string{
+		"History-Info: <sip:bob@example.com?Reason=SIP%3Bcause%3D302>;index=1,<sip:office@example.com>;index=1.1;mp=1\n",
+		"History-Info: <sip:bob@192.0.2.4>;index=1.1.1;rc=1.1\n",
+	}
+	var tvo = []string{
+		"History-Info: <sip:bob@example.com?Reason=SIP%3Bcause%3D302>;index=1,<sip:office@example.com>;index=1.1;mp=1\n",
+		"History-Info: <sip:bob@192.0.2.4>;index=1.1.1;rc=1.1\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewHistoryInfoParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for Identity header.
+ */
+type IdentityParser struct {
+	HeaderParser
+}
+
+/** Creates a new instance of IdentityParser
+ * @param identity the header to parse
+ */
+func NewIdentityParser(identity string) *IdentityParser {
+	this := &IdentityParser{}
+	this.HeaderParser.super(identity)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewIdentityParserFromLexer(lexer core.Lexer) *IdentityParser {
+	this := &IdentityParser{}
+	this.HeaderParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (IdentityList object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *IdentityParser) Parse() (sh header.Header, ParseException error) {
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_IDENTITY)
+
+	identityList := header.NewIdentityList()
+	for {
+		identity := header.NewIdentity()
+		if ParseException = identity.SetSignedIdentityDigest(this.digest()); ParseException != nil {
+			return nil, ParseException
+		}
+		lexer.SPorHT()
+		for ch, _ := lexer.LookAheadK(0); ch == ';'; ch, _ = lexer.LookAheadK(0) {
+			lexer.Match(';')
+			lexer.SPorHT()
+			name := lexer.Ttoken()
+			if name == "" {
+				return nil, errors.New("ParseException: missing Identity parameter name")
+			}
+			lexer.SPorHT()
+			value := ""
+			if ch, _ = lexer.LookAheadK(0); ch == '=' {
+				lexer.Match('=')
+				lexer.SPorHT()
+				if ch, _ = lexer.LookAheadK(0); ch == '<' {
+					lexer.ConsumeK(1)
+					uri, err := lexer.GetString('>')
+					if err != nil {
+						return nil, errors.New("ParseException: unterminated Identity info")
+					}
+					value = "<" + uri + ">"
+				} else {
+					value = lexer.Ttoken()
+				}
+				lexer.SPorHT()
+			}
+			identity.SetParameter(name, value)
+		}
+		identityList.PushBack(identity)
+		if ch, _ := lexer.LookAheadK(0); ch == ',' {
+			lexer.Match(',')
+			lexer.SPorHT()
+		} else {
+			break
+		}
+	}
+	if _, ParseException = lexer.Match('\n'); ParseException != nil {
+		return nil, ParseException
+	}
+
+	return identityList, nil
+}
+
+/** Reads the signed identity digest: a compact JWS, or the quoted base64
+ * signature of the RFC 4474 Identity header.
+ */
+func (this *IdentityParser) digest() string {
+	lexer := this.GetLexer()
+	if ch, _ := lexer.LookAheadK(0); ch == '"' {
+		lexer.ConsumeK(1)
+		s, _ := lexer.GetString('"')
+		return "\"" + s + "\""
+	}
+	var digest bytes.Buffer
+	for {
+		ch, err := lexer.LookAheadK(0)
+		if err != nil || ch == ';' || ch == ',' || ch == ' ' || ch == '\t' || ch == '\n' {
+			break
+		}
+		lexer.ConsumeK(1)
+		digest.WriteByte(ch)
+	}
+	return digest.String()
+}
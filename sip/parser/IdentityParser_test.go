@@ -0,0 +1,25 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestIdentityParser(t *testing.T) {
+	var tvi = []string{
+		"Identity: eyJhbGciOiJFUzI1NiJ9.eyJhdHRlc3QiOiJBIn0.c2ln-_9;info=<https://cert.example.org/passport.cer>;alg=ES256;ppt=shaken\n",
+		"Identity: eyJhbGciOiJFUzI1NiJ9..c2lnbmF0dXJl ; info = <https://biloxi.example.org/biloxi.cer> ; alg=ES256\n",
+		"Identity: r5mwreLuyDRYBi/0TiPwEsY3rEVsk/G2WxhgTV1PF7hHuLIK0YWVKZhKv9Mj8UeXqkMVbnVq37CD+813gvYjcBUaZngQmXc9WNZSDNGCzA+fWl9MEUHWIZo1CeJebdY/XlgKeTa0Olvq0rt70Q5jiSfbqMJmQFteeivUhkMWYUA=\n",
+		"Identity: \"CyI4+nAkHrH3ntmaxgr01TMxTmtjP7MASwliNRdupRI1vpkXRvZXx1ja9k0nB2sN\"\n",
+	}
+	var tvo = []string{
+		"Identity: eyJhbGciOiJFUzI1NiJ9.eyJhdHRlc3QiOiJBIn0.c2ln-_9;info=<https://cert.example.org/passport.cer>;alg=ES256;ppt=shaken\n",
+		"Identity: eyJhbGciOiJFUzI1NiJ9..c2lnbmF0dXJl;info=<https://biloxi.example.org/biloxi.cer>;alg=ES256\n",
+		"Identity: r5mwreLuyDRYBi/0TiPwEsY3rEVsk/G2WxhgTV1PF7hHuLIK0YWVKZhKv9Mj8UeXqkMVbnVq37CD+813gvYjcBUaZngQmXc9WNZSDNGCzA+fWl9MEUHWIZo1CeJebdY/XlgKeTa0Olvq0rt70Q5jiSfbqMJmQFteeivUhkMWYUA=\n",
+		"Identity: \"CyI4+nAkHrH3ntmaxgr01TMxTmtjP7MASwliNRdupRI1vpkXRvZXx1ja9k0nB2sN\"\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewIdentityParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+	"strings"
+)
+
+/** SIPParser for Join header.
+ */
+type JoinParser struct {
+	ParametersParser
+}
+
+/** Creates a new instance of JoinParser
+ * @param join the header to parse
+ */
+func NewJoinParser(join string) *JoinParser {
+	this := &JoinParser{}
+	this.ParametersParser.super(join)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewJoinParserFromLexer(lexer core.Lexer) *JoinParser {
+	this := &JoinParser{}
+	this.ParametersParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (Join object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *JoinParser) Parse() (sh header.Header, ParseException error) {
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_JOIN)
+
+	join := header.NewJoin()
+	callId := strings.TrimSpace(lexer.ByteStringNoSemicolon())
+	if strings.ContainsAny(callId, " \t") {
+		return nil, errors.New("ParseException: bad Call-ID " + callId)
+	}
+	if ParseException = join.SetCallId(callId); ParseException != nil {
+		return nil, ParseException
+	}
+
+	if ParseException = this.ParametersParser.Parse(join); ParseException != nil {
+		return nil, ParseException
+	}
+
+	lexer.SPorHT()
+	lexer.Match('\n')
+
+	return join, nil
+}
@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestJoinParser(t *testing.T) {
+	var tvi = []string{
+		"Join: 12adf2f34456gs5;to-tag=12345;from-tag=54321\n",
+		"Join: 87134@171.161.34.23 ;to-tag=24796 ;from-tag=0\n",
+	}
+	var tvo = []string{
+		"Join: 12adf2f34456gs5;to-tag=12345;from-tag=54321\n",
+		"Join: 87134@171.161.34.23;to-tag=24796;from-tag=0\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewJoinParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for Min-SE header.
+ */
+type MinSEParser struct {
+	ParametersParser
+}
+
+/** Creates a new instance of MinSEParser
+ * @param minSE the header to parse
+ */
+func NewMinSEParser(minSE string) *MinSEParser {
+	this := &MinSEParser{}
+	this.ParametersParser.super(minSE)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewMinSEParserFromLexer(lexer core.Lexer) *MinSEParser {
+	this := &MinSEParser{}
+	this.ParametersParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (MinSE object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *MinSEParser) Parse() (sh header.Header, ParseException error) {
+	minSE := header.NewMinSE()
+
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_MIN_SE)
+
+	var number int
+	if number, ParseException = lexer.Number(); ParseException != nil {
+		return nil, ParseException
+	}
+	minSE.SetExpires(number)
+
+	if ParseException = this.ParametersParser.Parse(minSE); ParseException != nil {
+		return nil, ParseException
+	}
+
+	lexer.SPorHT()
+	lexer.Match('\n')
+
+	return minSE, nil
+}
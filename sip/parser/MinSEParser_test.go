@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestMinSEParser(t *testing.T) {
+	var tvi = []string{
+		"Min-SE: 90\n",
+		"Min-SE: 3600 ;foo=bar\n",
+	}
+	var tvo = []string{
+		"Min-SE: 90\n",
+		"Min-SE: 3600;foo=bar\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewMinSEParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
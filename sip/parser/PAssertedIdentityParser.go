@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for a list of PAssertedIdentity headers.
+ */
+type PAssertedIdentityParser struct {
+	AddressParametersParser
+}
+
+/** Constructor
+ * @param String pAssertedIdentity message to parse to set
+ */
+func NewPAssertedIdentityParser(pAssertedIdentity string) *PAssertedIdentityParser {
+	this := &PAssertedIdentityParser{}
+	this.AddressParametersParser.super(pAssertedIdentity)
+	return this
+}
+
+func NewPAssertedIdentityParserFromLexer(lexer core.Lexer) *PAssertedIdentityParser {
+	this := &PAssertedIdentityParser{}
+	this.AddressParametersParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message and generate the PAssertedIdentity List Object
+ * @return SIPHeader the PAssertedIdentity List object
+ * @throws ParseException if errors occur during the parsing
+ */
+func (this *PAssertedIdentityParser) Parse() (sh header.Header, ParseException error) {
+	pAssertedIdentityList := header.NewPAssertedIdentityList()
+
+	var ch byte
+	lexer := this.GetLexer()
+	lexer.Match(TokenTypes_P_ASSERTED_IDENTITY)
+	lexer.SPorHT()
+	lexer.Match(':')
+	lexer.SPorHT()
+	for {
+		pAssertedIdentity := header.NewPAssertedIdentity()
+		this.AddressParametersParser.Parse(pAssertedIdentity)
+		pAssertedIdentityList.PushBack(pAssertedIdentity)
+		lexer.SPorHT()
+		if ch, _ = lexer.LookAheadK(0); ch == ',' {
+			lexer.Match(',')
+			lexer.SPorHT()
+		} else if ch, _ = lexer.LookAheadK(0); ch == '\n' {
+			break
+		} else {
+			return nil, this.CreateParseException("unexpected char")
+		}
+	}
+
+	return pAssertedIdentityList, nil
+}
@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestPAssertedIdentityParser(t *testing.T) {
+	var tvi = []string{
+		"P-Asserted-Identity: \"Cullen Jennings\" <sip:fluffy@cisco.com>\n",
+		"P-Asserted-Identity: <sip:fluffy@cisco.com>,<tel:+14085264000>\n",
+		"P-Asserted-Identity: tel:+14085264000\n",
+	}
+	var tvo = []string{
+		"P-Asserted-Identity: \"Cullen Jennings\" <sip:fluffy@cisco.com>\n",
+		"P-Asserted-Identity: <sip:fluffy@cisco.com>,<tel:+14085264000>\n",
+		"P-Asserted-Identity: <tel:+14085264000>\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewPAssertedIdentityParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
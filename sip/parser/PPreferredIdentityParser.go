@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for a list of PPreferredIdentity headers.
+ */
+type PPreferredIdentityParser struct {
+	AddressParametersParser
+}
+
+/** Constructor
+ * @param String pPreferredIdentity message to parse to set
+ */
+func NewPPreferredIdentityParser(pPreferredIdentity string) *PPreferredIdentityParser {
+	this := &PPreferredIdentityParser{}
+	this.AddressParametersParser.super(pPreferredIdentity)
+	return this
+}
+
+func NewPPreferredIdentityParserFromLexer(lexer core.Lexer) *PPreferredIdentityParser {
+	this := &PPreferredIdentityParser{}
+	this.AddressParametersParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message and generate the PPreferredIdentity List Object
+ * @return SIPHeader the PPreferredIdentity List object
+ * @throws ParseException if errors occur during the parsing
+ */
+func (this *PPreferredIdentityParser) Parse() (sh header.Header, ParseException error) {
+	pPreferredIdentityList := header.NewPPreferredIdentityList()
+
+	var ch byte
+	lexer := this.GetLexer()
+	lexer.Match(TokenTypes_P_PREFERRED_IDENTITY)
+	lexer.SPorHT()
+	lexer.Match(':')
+	lexer.SPorHT()
+	for {
+		pPreferredIdentity := header.NewPPreferredIdentity()
+		this.AddressParametersParser.Parse(pPreferredIdentity)
+		pPreferredIdentityList.PushBack(pPreferredIdentity)
+		lexer.SPorHT()
+		if ch, _ = lexer.LookAheadK(0); ch == ',' {
+			lexer.Match(',')
+			lexer.SPorHT()
+		} else if ch, _ = lexer.LookAheadK(0); ch == '\n' {
+			break
+		} else {
+			return nil, this.CreateParseException("unexpected char")
+		}
+	}
+
+	return pPreferredIdentityList, nil
+}
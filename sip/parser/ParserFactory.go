@@ -147,6 +147,52 @@ func CreateParser(line string) (parser Parser, ParseException error) {
 		parser = NewAcceptParser(line)
 	case strings.ToLower(core.SIPHeaderNames_REFER_TO):
 		parser = NewReferToParser(line)
+	case strings.ToLower(core.SIPHeaderNames_SESSION_EXPIRES):
+		parser = NewSessionExpiresParser(line)
+	case "x":
+		parser = NewSessionExpiresParser(line)
+	case strings.ToLower(core.SIPHeaderNames_MIN_SE):
+		parser = NewMinSEParser(line)
+	case strings.ToLower(core.SIPHeaderNames_REFER_SUB):
+		parser = NewReferSubParser(line)
+	case strings.ToLower(core.SIPHeaderNames_REPLACES):
+		parser = NewReplacesParser(line)
+	case strings.ToLower(core.SIPHeaderNames_JOIN):
+		parser = NewJoinParser(line)
+	case strings.ToLower(core.SIPHeaderNames_SIP_ETAG):
+		parser = NewSIPETagParser(line)
+	case strings.ToLower(core.SIPHeaderNames_SIP_IF_MATCH):
+		parser = NewSIPIfMatchParser(line)
+	case strings.ToLower(core.SIPHeaderNames_PATH):
+		parser = NewPathParser(line)
+	case strings.ToLower(core.SIPHeaderNames_SERVICE_ROUTE):
+		parser = NewServiceRouteParser(line)
+	case strings.ToLower(core.SIPHeaderNames_P_ASSERTED_IDENTITY):
+		parser = NewPAssertedIdentityParser(line)
+	case strings.ToLower(core.SIPHeaderNames_P_PREFERRED_IDENTITY):
+		parser = NewPPreferredIdentityParser(line)
+	case strings.ToLower(core.SIPHeaderNames_PRIVACY):
+		parser = NewPrivacyParser(line)
+	case strings.ToLower(core.SIPHeaderNames_HISTORY_INFO):
+		parser = NewHistoryInfoParser(line)
+	case strings.ToLower(core.SIPHeaderNames_DIVERSION):
+		parser = NewDiversionParser(line)
+	case strings.ToLower(core.SIPHeaderNames_IDENTITY):
+		parser = NewIdentityParser(line)
+	case strings.ToLower(core.SIPHeaderNames_FLOW_TIMER):
+		parser = NewFlowTimerParser(line)
+	case strings.ToLower(core.SIPHeaderNames_ACCEPT_CONTACT):
+		parser = NewAcceptContactParser(line)
+	case "a":
+		parser = NewAcceptContactParser(line)
+	case strings.ToLower(core.SIPHeaderNames_REJECT_CONTACT):
+		parser = NewRejectContactParser(line)
+	case "j":
+		parser = NewRejectContactParser(line)
+	case strings.ToLower(core.SIPHeaderNames_REQUEST_DISPOSITION):
+		parser = NewRequestDispositionParser(line)
+	case "d":
+		parser = NewRequestDispositionParser(line)
 	default:
 		// Just generate a generic SIPHeader. We define
 		// parsers only for the above.
@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestPathParser(t *testing.T) {
+	var tvi = []string{
+		"Path: <sip:P2.EXAMPLEHOME.COM;lr>,<sip:P1.EXAMPLEVISITED.COM;lr>\n",
+		"Path: <sip:proxy.example.com;lr;ob>\n",
+		"Path: \"Edge\" <sip:edge@10.0.0.1:5070;transport=tcp;lr>\n",
+	}
+	var tvo = []string{
+		"Path: <sip:P2.EXAMPLEHOME.COM;lr>,<sip:P1.EXAMPLEVISITED.COM;lr>\n",
+		"Path: <sip:proxy.example.com;lr;ob>\n",
+		"Path: \"Edge\" <sip:edge@10.0.0.1:5070;transport=tcp;lr>\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewPathParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
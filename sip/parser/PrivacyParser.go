@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for Privacy header.
+ */
+type PrivacyParser struct {
+	HeaderParser
+}
+
+/** Creates a new instance of PrivacyParser
+ * @param privacy the header to parse
+ */
+func NewPrivacyParser(privacy string) *PrivacyParser {
+	this := &PrivacyParser{}
+	this.HeaderParser.super(privacy)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewPrivacyParserFromLexer(lexer core.Lexer) *PrivacyParser {
+	this := &PrivacyParser{}
+	this.HeaderParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (Privacy object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *PrivacyParser) Parse() (sh header.Header, ParseException error) {
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_PRIVACY)
+
+	privacy := header.NewPrivacy()
+	for {
+		token := lexer.Ttoken()
+		if token == "" {
+			return nil, errors.New("ParseException: missing privacy value")
+		}
+		privacy.AddPrivacy(token)
+		lexer.SPorHT()
+		if ch, _ := lexer.LookAheadK(0); ch == ';' {
+			lexer.Match(';')
+			lexer.SPorHT()
+		} else {
+			break
+		}
+	}
+	lexer.Match('\n')
+
+	return privacy, nil
+}
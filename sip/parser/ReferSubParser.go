@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+	"strings"
+)
+
+/** SIPParser for Refer-Sub header.
+ */
+type ReferSubParser struct {
+	ParametersParser
+}
+
+/** Creates a new instance of ReferSubParser
+ * @param referSub the header to parse
+ */
+func NewReferSubParser(referSub string) *ReferSubParser {
+	this := &ReferSubParser{}
+	this.ParametersParser.super(referSub)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewReferSubParserFromLexer(lexer core.Lexer) *ReferSubParser {
+	this := &ReferSubParser{}
+	this.ParametersParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (ReferSub object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *ReferSubParser) Parse() (sh header.Header, ParseException error) {
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_REFER_SUB)
+
+	lexer.Match(TokenTypes_ID)
+	token := lexer.GetNextToken()
+	var referSub *header.ReferSub
+	switch strings.ToLower(token.GetTokenValue()) {
+	case "true":
+		referSub = header.NewReferSub(true)
+	case "false":
+		referSub = header.NewReferSub(false)
+	default:
+		return nil, errors.New("ParseException: bad Refer-Sub value " + token.GetTokenValue())
+	}
+
+	if ParseException = this.ParametersParser.Parse(referSub); ParseException != nil {
+		return nil, ParseException
+	}
+
+	lexer.SPorHT()
+	lexer.Match('\n')
+
+	return referSub, nil
+}
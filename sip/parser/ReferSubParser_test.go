@@ -0,0 +1,27 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestReferSubParser(t *testing.T) {
+	var tvi = []string{
+		"Refer-Sub: false\n",
+		"Refer-Sub: TRUE\n",
+		"Refer-Sub: false ;foo=bar\n",
+	}
+	var tvo = []string{
+		"Refer-Sub: false\n",
+		"Refer-Sub: true\n",
+		"Refer-Sub: false;foo=bar\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewReferSubParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+
+	if _, err := NewReferSubParser("Refer-Sub: maybe\n").Parse(); err == nil {
+		t.Error("bad Refer-Sub value accepted")
+	}
+}
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for a list of Reject-Contact headers.
+ */
+type RejectContactParser struct {
+	HeaderParser
+}
+
+/** Creates a new instance of RejectContactParser
+ * @param rejectContact the header to parse
+ */
+func NewRejectContactParser(rejectContact string) *RejectContactParser {
+	this := &RejectContactParser{}
+	this.HeaderParser.super(rejectContact)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewRejectContactParserFromLexer(lexer core.Lexer) *RejectContactParser {
+	this := &RejectContactParser{}
+	this.HeaderParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (RejectContactList object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *RejectContactParser) Parse() (sh header.Header, ParseException error) {
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_REJECT_CONTACT)
+
+	rejectContactList := header.NewRejectContactList()
+	for {
+		rejectContact := header.NewRejectContact()
+		if ParseException = this.featurePredicate(rejectContact); ParseException != nil {
+			return nil, ParseException
+		}
+		rejectContactList.PushBack(rejectContact)
+		if ch, _ := lexer.LookAheadK(0); ch == ',' {
+			lexer.Match(',')
+			lexer.SPorHT()
+		} else {
+			break
+		}
+	}
+	if _, ParseException = lexer.Match('\n'); ParseException != nil {
+		return nil, ParseException
+	}
+
+	return rejectContactList, nil
+}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+	"strings"
+)
+
+/** SIPParser for Replaces header.
+ */
+type ReplacesParser struct {
+	ParametersParser
+}
+
+/** Creates a new instance of ReplacesParser
+ * @param replaces the header to parse
+ */
+func NewReplacesParser(replaces string) *ReplacesParser {
+	this := &ReplacesParser{}
+	this.ParametersParser.super(replaces)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewReplacesParserFromLexer(lexer core.Lexer) *ReplacesParser {
+	this := &ReplacesParser{}
+	this.ParametersParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (Replaces object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *ReplacesParser) Parse() (sh header.Header, ParseException error) {
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_REPLACES)
+
+	replaces := header.NewReplaces()
+	callId := strings.TrimSpace(lexer.ByteStringNoSemicolon())
+	if strings.ContainsAny(callId, " \t") {
+		return nil, errors.New("ParseException: bad Call-ID " + callId)
+	}
+	if ParseException = replaces.SetCallId(callId); ParseException != nil {
+		return nil, ParseException
+	}
+
+	if ParseException = this.ParametersParser.Parse(replaces); ParseException != nil {
+		return nil, ParseException
+	}
+	// early-only is a flag: keep it without a value.
+	if replaces.GetNameValue(header.ParameterNames_EARLY_ONLY) != nil {
+		replaces.SetEarlyOnly(true)
+	}
+
+	lexer.SPorHT()
+	lexer.Match('\n')
+
+	return replaces, nil
+}
@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestReplacesParser(t *testing.T) {
+	var tvi = []string{
+		"Replaces: 425928@bobster.example.org;to-tag=7743;from-tag=6472\n",
+		"Replaces: 98732@sip.example.com ;from-tag=r33th4x0r ;to-tag=ff87ff ;early-only\n",
+	}
+	var tvo = []string{
+		"Replaces: 425928@bobster.example.org;to-tag=7743;from-tag=6472\n",
+		"Replaces: 98732@sip.example.com;from-tag=r33th4x0r;to-tag=ff87ff;early-only\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewReplacesParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for Request-Disposition header.
+ */
+type RequestDispositionParser struct {
+	HeaderParser
+}
+
+/** Creates a new instance of RequestDispositionParser
+ * @param requestDisposition the header to parse
+ */
+func NewRequestDispositionParser(requestDisposition string) *RequestDispositionParser {
+	this := &RequestDispositionParser{}
+	this.HeaderParser.super(requestDisposition)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewRequestDispositionParserFromLexer(lexer core.Lexer) *RequestDispositionParser {
+	this := &RequestDispositionParser{}
+	this.HeaderParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (RequestDisposition object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *RequestDispositionParser) Parse() (sh header.Header, ParseException error) {
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_REQUEST_DISPOSITION)
+
+	requestDisposition := header.NewRequestDisposition()
+	for {
+		token := lexer.Ttoken()
+		if token == "" {
+			return nil, errors.New("ParseException: missing Request-Disposition directive")
+		}
+		requestDisposition.AddDirective(token)
+		lexer.SPorHT()
+		if ch, _ := lexer.LookAheadK(0); ch == ',' {
+			lexer.Match(',')
+			lexer.SPorHT()
+		} else {
+			break
+		}
+	}
+	if _, ParseException = lexer.Match('\n'); ParseException != nil {
+		return nil, ParseException
+	}
+
+	return requestDisposition, nil
+}
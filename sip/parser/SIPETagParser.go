@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for SIP-ETag header.
+ */
+type SIPETagParser struct {
+	HeaderParser
+}
+
+/** Creates a new instance of SIPETagParser
+ * @param eTag the header to parse
+ */
+func NewSIPETagParser(eTag string) *SIPETagParser {
+	this := &SIPETagParser{}
+	this.HeaderParser.super(eTag)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewSIPETagParserFromLexer(lexer core.Lexer) *SIPETagParser {
+	this := &SIPETagParser{}
+	this.HeaderParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (SIPETag object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *SIPETagParser) Parse() (sh header.Header, ParseException error) {
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_SIP_ETAG)
+
+	eTag := header.NewSIPETag()
+	token := lexer.Ttoken()
+	if token == "" {
+		return nil, errors.New("ParseException: missing entity tag")
+	}
+	eTag.SetETag(token)
+
+	lexer.SPorHT()
+	lexer.Match('\n')
+
+	return eTag, nil
+}
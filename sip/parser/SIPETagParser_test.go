@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestSIPETagParser(t *testing.T) {
+	var tvi = []string{
+		"SIP-ETag: dx200xyz\n",
+		"SIP-ETag:   kwj449x.1-a!\n",
+	}
+	var tvo = []string{
+		"SIP-ETag: dx200xyz\n",
+		"SIP-ETag: kwj449x.1-a!\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewSIPETagParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for SIP-If-Match header.
+ */
+type SIPIfMatchParser struct {
+	HeaderParser
+}
+
+/** Creates a new instance of SIPIfMatchParser
+ * @param eTag the header to parse
+ */
+func NewSIPIfMatchParser(eTag string) *SIPIfMatchParser {
+	this := &SIPIfMatchParser{}
+	this.HeaderParser.super(eTag)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewSIPIfMatchParserFromLexer(lexer core.Lexer) *SIPIfMatchParser {
+	this := &SIPIfMatchParser{}
+	this.HeaderParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (SIPIfMatch object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *SIPIfMatchParser) Parse() (sh header.Header, ParseException error) {
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_SIP_IF_MATCH)
+
+	eTag := header.NewSIPIfMatch()
+	token := lexer.Ttoken()
+	if token == "" {
+		return nil, errors.New("ParseException: missing entity tag")
+	}
+	eTag.SetETag(token)
+
+	lexer.SPorHT()
+	lexer.Match('\n')
+
+	return eTag, nil
+}
@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestSIPIfMatchParser(t *testing.T) {
+	var tvi = []string{
+		"SIP-If-Match: dx200xyz\n",
+		"SIP-If-Match: 37sha8~ \n",
+	}
+	var tvo = []string{
+		"SIP-If-Match: dx200xyz\n",
+		"SIP-If-Match: 37sha8~\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewSIPIfMatchParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
@@ -76,6 +76,25 @@ const TokenTypes_EVENT = TokenTypes_START + 63
 const TokenTypes_AUTHENTICATION_INFO = TokenTypes_START + 64
 const TokenTypes_ALLOW_EVENTS = TokenTypes_START + 65
 const TokenTypes_REFER_TO = TokenTypes_START + 66
+const TokenTypes_SESSION_EXPIRES = TokenTypes_START + 67
+const TokenTypes_MIN_SE = TokenTypes_START + 68
+const TokenTypes_REFER_SUB = TokenTypes_START + 69
+const TokenTypes_REPLACES = TokenTypes_START + 70
+const TokenTypes_JOIN = TokenTypes_START + 71
+const TokenTypes_SIP_ETAG = TokenTypes_START + 72
+const TokenTypes_SIP_IF_MATCH = TokenTypes_START + 73
+const TokenTypes_PATH = TokenTypes_START + 74
+const TokenTypes_SERVICE_ROUTE = TokenTypes_START + 75
+const TokenTypes_P_ASSERTED_IDENTITY = TokenTypes_START + 76
+const TokenTypes_P_PREFERRED_IDENTITY = TokenTypes_START + 77
+const TokenTypes_PRIVACY = TokenTypes_START + 78
+const TokenTypes_HISTORY_INFO = TokenTypes_START + 79
+const TokenTypes_DIVERSION = TokenTypes_START + 80
+const TokenTypes_IDENTITY = TokenTypes_START + 81
+const TokenTypes_FLOW_TIMER = TokenTypes_START + 82
+const TokenTypes_ACCEPT_CONTACT = TokenTypes_START + 83
+const TokenTypes_REJECT_CONTACT = TokenTypes_START + 84
+const TokenTypes_REQUEST_DISPOSITION = TokenTypes_START + 85
 const TokenTypes_ALPHA = core.CORELEXER_ALPHA
 const TokenTypes_DIGIT = core.CORELEXER_DIGIT
 const TokenTypes_ID = core.CORELEXER_ID
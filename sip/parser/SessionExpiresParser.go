@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+)
+
+/** SIPParser for Session-Expires header.
+ */
+type SessionExpiresParser struct {
+	ParametersParser
+}
+
+/** Creates a new instance of SessionExpiresParser
+ * @param sessionExpires the header to parse
+ */
+func NewSessionExpiresParser(sessionExpires string) *SessionExpiresParser {
+	this := &SessionExpiresParser{}
+	this.ParametersParser.super(sessionExpires)
+	return this
+}
+
+/** Constructor
+ * @param lexer the lexer to use to parse the header
+ */
+func NewSessionExpiresParserFromLexer(lexer core.Lexer) *SessionExpiresParser {
+	this := &SessionExpiresParser{}
+	this.ParametersParser.superFromLexer(lexer)
+	return this
+}
+
+/** parse the String message
+ * @return Header (SessionExpires object)
+ * @throws SIPParseException if the message does not respect the spec.
+ */
+func (this *SessionExpiresParser) Parse() (sh header.Header, ParseException error) {
+	sessionExpires := header.NewSessionExpires()
+
+	lexer := this.GetLexer()
+	this.HeaderName(TokenTypes_SESSION_EXPIRES)
+
+	var number int
+	if number, ParseException = lexer.Number(); ParseException != nil {
+		return nil, ParseException
+	}
+	sessionExpires.SetExpires(number)
+
+	if ParseException = this.ParametersParser.Parse(sessionExpires); ParseException != nil {
+		return nil, ParseException
+	}
+
+	lexer.SPorHT()
+	lexer.Match('\n')
+
+	return sessionExpires, nil
+}
@@ -0,0 +1,33 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestSessionExpiresParser(t *testing.T) {
+	var tvi = []string{
+		"Session-Expires: 4000;refresher=uac\n",
+		"Session-Expires: 1800 ; refresher=uas\n",
+		"x: 90\n",
+		"Session-Expires: 3600;refresher=uac;foo=bar\n",
+	}
+	var tvo = []string{
+		"Session-Expires: 4000;refresher=uac\n",
+		"Session-Expires: 1800;refresher=uas\n",
+		"Session-Expires: 90\n",
+		"Session-Expires: 3600;refresher=uac;foo=bar\n",
+	}
+
+	for i := 0; i < len(tvi); i++ {
+		shp := NewSessionExpiresParser(tvi[i])
+		testHeaderParser(t, shp, tvo[i])
+	}
+}
+
+func TestSessionExpiresCompactForm(t *testing.T) {
+	shp, err := CreateParser("x: 1800;refresher=uas\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testHeaderParser(t, shp, "Session-Expires: 1800;refresher=uas\n")
+}
@@ -0,0 +1,186 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Pidf.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package presence
+
+import (
+	"encoding/xml"
+	"errors"
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strings"
+)
+
+/**
+ * XML namespaces of the presence documents.
+ */
+const (
+	/** PIDF (RFC 3863). */
+	PIDF_NAMESPACE = "urn:ietf:params:xml:ns:pidf"
+	/** The PIDF data model of persons, services and devices (RFC 4479). */
+	DATA_MODEL_NAMESPACE = "urn:ietf:params:xml:ns:pidf:data-model"
+	/** Rich presence extensions (RFC 4480). */
+	RPID_NAMESPACE = "urn:ietf:params:xml:ns:pidf:rpid"
+)
+
+/**
+ * The event package of presence (RFC 3856).
+ */
+const PRESENCE_EVENT = "presence"
+
+/**
+ * Values of the basic status of a tuple.
+ */
+const (
+	BASIC_OPEN   = "open"
+	BASIC_CLOSED = "closed"
+)
+
+/**
+ * A PIDF presence document describing a presentity, the entity. Elements
+ * without namespace in their tag are in the namespace of their parent.
+ */
+type Presence struct {
+	XMLName xml.Name  `xml:"urn:ietf:params:xml:ns:pidf presence"`
+	Entity  string    `xml:"entity,attr"`
+	Tuples  []*Tuple  `xml:"tuple"`
+	Persons []*Person `xml:"urn:ietf:params:xml:ns:pidf:data-model person"`
+	Notes   []*Note   `xml:"note"`
+}
+
+/**
+ * A presence tuple, usually one per device or service of the presentity.
+ */
+type Tuple struct {
+	Id        string   `xml:"id,attr"`
+	Status    Status   `xml:"status"`
+	Contact   *Contact `xml:"contact,omitempty"`
+	Notes     []*Note  `xml:"note"`
+	Timestamp string   `xml:"timestamp,omitempty"`
+}
+
+/**
+ * The status of a tuple.
+ */
+type Status struct {
+	Basic string `xml:"basic,omitempty"`
+}
+
+/**
+ * The contact address of a tuple with its optional priority, from 0 to 1.
+ */
+type Contact struct {
+	Priority string `xml:"priority,attr,omitempty"`
+	URI      string `xml:",chardata"`
+}
+
+/**
+ * A human readable comment in an optional language.
+ */
+type Note struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+/**
+ * The person element of the data model, carrying the RPID activities and
+ * mood of the presentity.
+ */
+type Person struct {
+	Id         string      `xml:"id,attr"`
+	Activities *RpidValues `xml:"urn:ietf:params:xml:ns:pidf:rpid activities,omitempty"`
+	Mood       *RpidValues `xml:"urn:ietf:params:xml:ns:pidf:rpid mood,omitempty"`
+	Notes      []*Note     `xml:"note"`
+	Timestamp  string      `xml:"timestamp,omitempty"`
+}
+
+/** Creates an empty presence document for entity.
+ */
+func NewPresence(entity string) *Presence {
+	return &Presence{Entity: entity}
+}
+
+/** Creates a tuple with the given id and basic status.
+ */
+func NewTuple(id, basic string) *Tuple {
+	return &Tuple{Id: id, Status: Status{Basic: basic}}
+}
+
+/** Returns the tuple with the given id, or nil.
+ */
+func (this *Presence) GetTuple(id string) *Tuple {
+	for _, tuple := range this.Tuples {
+		if tuple.Id == id {
+			return tuple
+		}
+	}
+	return nil
+}
+
+/** Returns the person with the given id, or nil.
+ */
+func (this *Presence) GetPerson(id string) *Person {
+	for _, person := range this.Persons {
+		if person.Id == id {
+			return person
+		}
+	}
+	return nil
+}
+
+/** Returns the document encoded as XML.
+ */
+func (this *Presence) Encode() (string, error) {
+	b, err := xml.Marshal(this)
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b), nil
+}
+
+/** Parses a PIDF document.
+ */
+func ParsePresence(body string) (*Presence, error) {
+	presence := &Presence{}
+	if err := xml.Unmarshal([]byte(body), presence); err != nil {
+		return nil, errors.New("ParseException: bad PIDF document: " + err.Error())
+	}
+	return presence, nil
+}
+
+/** Returns the "application/pidf+xml" content type.
+ */
+func NewPidfContentType() header.ContentTypeHeader {
+	return header.NewContentTypeFromString("application", "pidf+xml")
+}
+
+/** Sets the document as the body of msg.
+ */
+func SetPresenceContent(msg message.Message, presence *Presence) error {
+	body, err := presence.Encode()
+	if err != nil {
+		return err
+	}
+	msg.SetContent(body, NewPidfContentType())
+	return nil
+}
+
+/** Returns the PIDF document carried by msg.
+ */
+func GetPresenceContent(msg message.Message) (*Presence, error) {
+	if !hasContentType(msg, "application", "pidf+xml") {
+		return nil, errors.New("ParseException: the message does not carry a PIDF document")
+	}
+	return ParsePresence(msg.GetContent())
+}
+
+func hasContentType(msg message.Message, contentType, contentSubType string) bool {
+	h, ok := msg.GetHeader(core.SIPHeaderNames_CONTENT_TYPE).(header.ContentTypeHeader)
+	return ok && strings.EqualFold(h.GetContentType(), contentType) &&
+		strings.EqualFold(h.GetContentSubType(), contentSubType)
+}
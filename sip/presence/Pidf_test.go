@@ -0,0 +1,82 @@
+package presence
+
+import (
+	"testing"
+)
+
+func TestParsePresence(t *testing.T) {
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<presence xmlns="urn:ietf:params:xml:ns:pidf"
+    xmlns:dm="urn:ietf:params:xml:ns:pidf:data-model"
+    xmlns:rpid="urn:ietf:params:xml:ns:pidf:rpid"
+    entity="pres:someone@example.com">
+  <tuple id="bs35r9">
+    <status><basic>open</basic></status>
+    <contact priority="0.8">im:someone@mobilecarrier.net</contact>
+    <note xml:lang="en">Don't Disturb Please!</note>
+    <timestamp>2005-10-27T16:49:29Z</timestamp>
+  </tuple>
+  <dm:person id="p1">
+    <rpid:activities>
+      <rpid:on-the-phone/>
+      <rpid:meeting/>
+    </rpid:activities>
+    <rpid:mood><rpid:other>stuck in traffic</rpid:other></rpid:mood>
+    <dm:note>Busy</dm:note>
+  </dm:person>
+</presence>`
+
+	presence, err := ParsePresence(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tuple := presence.GetTuple("bs35r9")
+	if presence.Entity != "pres:someone@example.com" || tuple == nil {
+		t.Fatalf("bad presence %+v", presence)
+	}
+	if tuple.Status.Basic != BASIC_OPEN || tuple.Contact.Priority != "0.8" ||
+		tuple.Contact.URI != "im:someone@mobilecarrier.net" ||
+		len(tuple.Notes) != 1 || tuple.Notes[0].Lang != "en" {
+		t.Errorf("bad tuple %+v", tuple)
+	}
+	person := presence.GetPerson("p1")
+	if person == nil || !person.Activities.Has(ACTIVITY_ON_THE_PHONE) || !person.Activities.Has(ACTIVITY_MEETING) {
+		t.Fatalf("bad person %+v", person)
+	}
+	if person.Mood.Values[0].XMLName.Local != "other" || person.Mood.Values[0].Text != "stuck in traffic" {
+		t.Errorf("bad mood %+v", person.Mood.Values[0])
+	}
+	if len(person.Notes) != 1 || person.Notes[0].Text != "Busy" {
+		t.Errorf("bad notes %+v", person.Notes)
+	}
+}
+
+func TestEncodePresence(t *testing.T) {
+	presence := NewPresence("sip:alice@example.com")
+	tuple := NewTuple("t1", BASIC_CLOSED)
+	tuple.Contact = &Contact{URI: "sip:alice@192.0.2.1"}
+	presence.Tuples = append(presence.Tuples, tuple)
+	presence.Persons = append(presence.Persons, &Person{
+		Id:         "p1",
+		Activities: NewRpidValues(ACTIVITY_AWAY, ACTIVITY_MEAL),
+		Mood:       NewRpidValues(MOOD_HAPPY),
+	})
+
+	body, err := presence.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ParsePresence(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Entity != presence.Entity || decoded.GetTuple("t1").Status.Basic != BASIC_CLOSED ||
+		decoded.GetTuple("t1").Contact.URI != "sip:alice@192.0.2.1" {
+		t.Errorf("bad tuple %+v", decoded.GetTuple("t1"))
+	}
+	person := decoded.GetPerson("p1")
+	if person == nil || len(person.Activities.GetNames()) != 2 || !person.Activities.Has(ACTIVITY_MEAL) ||
+		!person.Mood.Has(MOOD_HAPPY) || person.Mood.Values[0].XMLName.Space != RPID_NAMESPACE {
+		t.Errorf("bad person %+v", person)
+	}
+}
@@ -0,0 +1,419 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : PresenceAgent.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package presence
+
+import (
+	"gosips/core"
+	"gosips/sip"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strings"
+	"sync"
+)
+
+/**
+ * This interface is implemented by applications that decide which watchers
+ * may see the presence of a presentity.
+ */
+type WatcherAuthorizer interface {
+	/**
+	 * Returns true if watcher may subscribe to the presence of presentity.
+	 * Watchers that are not authorized are kept pending until
+	 * AuthorizeWatcher is called.
+	 */
+	IsAuthorized(presentity, watcher string) bool
+
+	/**
+	 * Returns true if subscriber, other than presentity itself, may
+	 * subscribe to the watcher information of presentity. Subscribers that
+	 * are not authorized are rejected with 403.
+	 */
+	IsWatcherInfoAuthorized(presentity, subscriber string) bool
+}
+
+/**
+ * A presence agent as described in RFC 3856: it keeps the presence state
+ * published by the sources of each presentity, e.g. its devices, composes it
+ * into a single PIDF document and notifies it to the watchers of the
+ * presentity. The watchers of a presentity are in turn reported to the
+ * subscribers of the "presence.winfo" package of that presentity (RFC 3857).
+ * <p>
+ * The agent plugs into a sip.Notifier as the producer of both packages;
+ * SUBSCRIBE requests are handed to ProcessSubscribe. Presence state published
+ * with PUBLISH is taken from a sip.EventStateCompositor the agent is
+ * registered with for the "presence" package.
+ */
+type PresenceAgent struct {
+	mutex sync.Mutex
+
+	notifier     *sip.Notifier
+	authorizer   WatcherAuthorizer
+	presentities map[string]*presentity
+}
+
+type presentity struct {
+	uri      string
+	sources  []string
+	states   map[string]*Presence
+	watchers []*sip.ServerSubscription
+	winfo    []*sip.ServerSubscription
+	versions map[*sip.ServerSubscription]int
+}
+
+/** Creates a presence agent serving the subscriptions of notifier.
+ */
+func NewPresenceAgent(notifier *sip.Notifier) *PresenceAgent {
+	this := &PresenceAgent{
+		notifier:     notifier,
+		presentities: make(map[string]*presentity),
+	}
+	notifier.RegisterProducer(PRESENCE_EVENT, this)
+	notifier.RegisterProducer(PRESENCE_WINFO_EVENT, this)
+	return this
+}
+
+/** Sets the authorizer of watchers. Without one every watcher is authorized
+ * and only the presentity itself sees its watcher information.
+ */
+func (this *PresenceAgent) SetWatcherAuthorizer(authorizer WatcherAuthorizer) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.authorizer = authorizer
+}
+
+/** Processes a SUBSCRIBE to the presence or watcher information of a
+ * presentity, the Request-URI. The response to send is returned if the
+ * SUBSCRIBE is rejected; otherwise it is answered here and followed by a
+ * NOTIFY, and nil is returned. The watcher information of a presentity is
+ * only given to the presentity itself and to the subscribers authorized by
+ * the WatcherAuthorizer; a SUBSCRIBE of anyone else is rejected here with
+ * 403.
+ */
+func (this *PresenceAgent) ProcessSubscribe(transaction sip.ServerTransaction) *message.SIPResponse {
+	subscription, response := this.notifier.ProcessSubscribe(transaction)
+	if response != nil || subscription == nil {
+		return response
+	}
+	if subscription.GetState() != "" {
+		subscription.Accept()
+		return nil
+	}
+
+	subscribe := subscription.GetRequest()
+	uri := GetPresentityURI(subscribe.GetRequestURI())
+	this.mutex.Lock()
+	if subscription.GetEventType() == PRESENCE_WINFO_EVENT && !this.isWatcherInfoAuthorized(uri, getWatcherURI(subscribe)) {
+		this.mutex.Unlock()
+		subscription.Reject(message.FORBIDDEN)
+		return nil
+	}
+	p := this.getPresentity(uri)
+	authorized := true
+	if subscription.GetEventType() == PRESENCE_WINFO_EVENT {
+		p.winfo = append(p.winfo, subscription)
+	} else {
+		p.watchers = append(p.watchers, subscription)
+		if this.authorizer != nil {
+			authorized = this.authorizer.IsAuthorized(uri, getWatcherURI(subscribe))
+		}
+	}
+	this.mutex.Unlock()
+
+	if authorized {
+		subscription.Accept()
+	} else {
+		subscription.AcceptPending()
+	}
+	if subscription.GetEventType() == PRESENCE_EVENT {
+		this.notifyWatcherInfo(uri)
+	}
+	return nil
+}
+
+/** Activates or rejects the pending subscriptions of watcher to the presence
+ * of presentity.
+ */
+func (this *PresenceAgent) AuthorizeWatcher(presentity, watcher string, authorized bool) {
+	this.mutex.Lock()
+	var pending []*sip.ServerSubscription
+	if p, ok := this.presentities[presentity]; ok {
+		for _, subscription := range p.watchers {
+			if subscription.GetState() == header.SubscriptionState_PENDING &&
+				getWatcherURI(subscription.GetRequest()) == watcher {
+				pending = append(pending, subscription)
+			}
+		}
+	}
+	this.mutex.Unlock()
+
+	for _, subscription := range pending {
+		if authorized {
+			subscription.Activate()
+		} else {
+			subscription.Terminate(header.SubscriptionState_REJECTED, 0)
+		}
+	}
+	// rejected watchers are reported once their subscription is removed
+	if authorized && len(pending) > 0 {
+		this.notifyWatcherInfo(presentity)
+	}
+}
+
+/** Stores the presence state published by a source of presentity, replacing
+ * the previous state of that source, and notifies the watchers.
+ */
+func (this *PresenceAgent) Publish(presentity, source string, state *Presence) {
+	this.mutex.Lock()
+	p := this.getPresentity(presentity)
+	if _, ok := p.states[source]; !ok {
+		p.sources = append(p.sources, source)
+	}
+	p.states[source] = state
+	this.mutex.Unlock()
+
+	this.notifyWatchers(presentity)
+}
+
+/** Removes the presence state published by a source of presentity and
+ * notifies the watchers.
+ */
+func (this *PresenceAgent) Unpublish(presentity, source string) {
+	this.mutex.Lock()
+	p, ok := this.presentities[presentity]
+	if !ok || p.states[source] == nil {
+		this.mutex.Unlock()
+		return
+	}
+	delete(p.states, source)
+	for i, s := range p.sources {
+		if s == source {
+			p.sources = append(p.sources[:i], p.sources[i+1:]...)
+			break
+		}
+	}
+	this.mutex.Unlock()
+
+	this.notifyWatchers(presentity)
+}
+
+/** Stores or removes the presence state of a publication of a
+ * sip.EventStateCompositor; each publication is a source of the presentity.
+ * Publications whose body is not a valid PIDF document are ignored.
+ */
+func (this *PresenceAgent) ProcessEventState(state *sip.EventState) {
+	uri := GetPresentityURI(state.GetResource())
+	if state.IsRemoved() {
+		this.Unpublish(uri, state.GetPublicationId())
+		return
+	}
+	presence, err := ParsePresence(state.GetContent())
+	if err != nil {
+		return
+	}
+	this.Publish(uri, state.GetPublicationId(), presence)
+}
+
+/** Returns the presence of presentity composed from the state of all its
+ * sources. Tuples and persons are merged by id; for equal ids the state of
+ * the source that started publishing last wins.
+ */
+func (this *PresenceAgent) GetPresence(presentity string) *Presence {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.compose(presentity)
+}
+
+/** Produces the NOTIFY bodies of the presence and presence.winfo packages
+ * for the notifier.
+ */
+func (this *PresenceAgent) ProduceBody(subscription *sip.ServerSubscription) (body string, contentType header.ContentTypeHeader) {
+	uri := GetPresentityURI(subscription.GetRequest().GetRequestURI())
+	state := subscription.GetState()
+	if state == header.SubscriptionState_TERMINATED {
+		defer this.remove(uri, subscription)
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if subscription.GetEventType() == PRESENCE_WINFO_EVENT {
+		p := this.getPresentity(uri)
+		p.versions[subscription]++
+		watcherInfo := NewWatcherInfo(p.versions[subscription]-1, WATCHERINFO_FULL)
+		watcherList := watcherInfo.GetWatcherList(uri, PRESENCE_EVENT)
+		for _, watcher := range p.watchers {
+			watcherList.Watchers = append(watcherList.Watchers, newWatcher(watcher))
+		}
+		if body, err := watcherInfo.Encode(); err == nil {
+			return body, NewWatcherInfoContentType()
+		}
+		return "", nil
+	}
+
+	// RFC 3856 section 6.6.2: no state is given to pending watchers.
+	if state == header.SubscriptionState_PENDING ||
+		state == header.SubscriptionState_TERMINATED && !this.isAuthorized(uri, subscription) {
+		return "", nil
+	}
+	if body, err := this.compose(uri).Encode(); err == nil {
+		return body, NewPidfContentType()
+	}
+	return "", nil
+}
+
+/** Returns the canonical form of a presentity or watcher URI: the scheme,
+ * user and host of a SIP URI, and the URI itself otherwise.
+ */
+func GetPresentityURI(uri address.URI) string {
+	if sipURI, ok := uri.(*address.SipURIImpl); ok {
+		s := sipURI.GetScheme() + ":"
+		if sipURI.GetUser() != "" {
+			s += sipURI.GetUser() + "@"
+		}
+		return s + strings.ToLower(sipURI.GetHost())
+	}
+	return uri.String()
+}
+
+func getWatcherURI(subscribe message.Request) string {
+	if from, ok := subscribe.GetHeader(core.SIPHeaderNames_FROM).(header.FromHeader); ok {
+		return GetPresentityURI(from.GetAddress().GetURI())
+	}
+	return ""
+}
+
+func newWatcher(subscription *sip.ServerSubscription) *Watcher {
+	watcher := &Watcher{
+		Id:     subscription.GetDialog().GetDialogId(),
+		Status: subscription.GetState(),
+		Event:  WATCHER_EVENT_SUBSCRIBE,
+		URI:    getWatcherURI(subscription.GetRequest()),
+	}
+	if watcher.Status == "" {
+		watcher.Status = WATCHER_PENDING
+	}
+	if from, ok := subscription.GetRequest().GetHeader(core.SIPHeaderNames_FROM).(header.FromHeader); ok {
+		watcher.DisplayName = from.GetAddress().GetDisplayName()
+	}
+	return watcher
+}
+
+func (this *PresenceAgent) getPresentity(uri string) *presentity {
+	p, ok := this.presentities[uri]
+	if !ok {
+		p = &presentity{
+			uri:      uri,
+			states:   make(map[string]*Presence),
+			versions: make(map[*sip.ServerSubscription]int),
+		}
+		this.presentities[uri] = p
+	}
+	return p
+}
+
+func (this *PresenceAgent) compose(uri string) *Presence {
+	composed := NewPresence(uri)
+	p, ok := this.presentities[uri]
+	if !ok {
+		return composed
+	}
+	for _, source := range p.sources {
+		state := p.states[source]
+		for _, tuple := range state.Tuples {
+			copied := *tuple
+			if old := composed.GetTuple(tuple.Id); old != nil {
+				*old = copied
+			} else {
+				composed.Tuples = append(composed.Tuples, &copied)
+			}
+		}
+		for _, person := range state.Persons {
+			copied := *person
+			if old := composed.GetPerson(person.Id); old != nil {
+				*old = copied
+			} else {
+				composed.Persons = append(composed.Persons, &copied)
+			}
+		}
+		composed.Notes = append(composed.Notes, state.Notes...)
+	}
+	return composed
+}
+
+func (this *PresenceAgent) isAuthorized(uri string, subscription *sip.ServerSubscription) bool {
+	return this.authorizer == nil || this.authorizer.IsAuthorized(uri, getWatcherURI(subscription.GetRequest()))
+}
+
+/** RFC 3857 section 5.2: the watcher information of a presentity is private
+ * to the presentity.
+ */
+func (this *PresenceAgent) isWatcherInfoAuthorized(uri, subscriber string) bool {
+	return subscriber == uri || this.authorizer != nil && this.authorizer.IsWatcherInfoAuthorized(uri, subscriber)
+}
+
+/** Forgets a terminated subscription and reports a terminated watcher to the
+ * watcher information subscribers.
+ */
+func (this *PresenceAgent) remove(uri string, subscription *sip.ServerSubscription) {
+	this.mutex.Lock()
+	p, ok := this.presentities[uri]
+	if !ok {
+		this.mutex.Unlock()
+		return
+	}
+	delete(p.versions, subscription)
+	p.winfo = removeSubscription(p.winfo, subscription)
+	watchers := len(p.watchers)
+	p.watchers = removeSubscription(p.watchers, subscription)
+	removed := len(p.watchers) != watchers
+	this.mutex.Unlock()
+
+	if removed {
+		this.notifyWatcherInfo(uri)
+	}
+}
+
+func removeSubscription(subscriptions []*sip.ServerSubscription, subscription *sip.ServerSubscription) []*sip.ServerSubscription {
+	for i, s := range subscriptions {
+		if s == subscription {
+			return append(subscriptions[:i], subscriptions[i+1:]...)
+		}
+	}
+	return subscriptions
+}
+
+func (this *PresenceAgent) notifyWatchers(uri string) {
+	this.mutex.Lock()
+	var watchers []*sip.ServerSubscription
+	if p, ok := this.presentities[uri]; ok {
+		watchers = append(watchers, p.watchers...)
+	}
+	this.mutex.Unlock()
+
+	for _, watcher := range watchers {
+		if watcher.GetState() == header.SubscriptionState_ACTIVE {
+			watcher.Notify()
+		}
+	}
+}
+
+func (this *PresenceAgent) notifyWatcherInfo(uri string) {
+	this.mutex.Lock()
+	var winfo []*sip.ServerSubscription
+	if p, ok := this.presentities[uri]; ok {
+		winfo = append(winfo, p.winfo...)
+	}
+	this.mutex.Unlock()
+
+	for _, subscription := range winfo {
+		if subscription.GetState() == header.SubscriptionState_ACTIVE {
+			subscription.Notify()
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package presence
+
+import (
+	"gosips/core"
+	"gosips/sip"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type fakeClientTransaction struct {
+	sip.ClientTransaction
+	request message.Request
+}
+
+func (this *fakeClientTransaction) GetRequest() message.Request {
+	return this.request
+}
+
+type fakeProvider struct {
+	sip.SipProvider
+}
+
+func (this *fakeProvider) GetNewClientTransaction(request message.Request) (sip.ClientTransaction, error) {
+	return &fakeClientTransaction{request: request}, nil
+}
+
+// fakeDialog is the dialog of the subscription of a watcher; it records the
+// NOTIFYs sent.
+type fakeDialog struct {
+	sip.Dialog
+	t       *testing.T
+	watcher string
+	cseq    int
+	sent    []*message.SIPRequest
+}
+
+func (this *fakeDialog) GetLocalTag() string {
+	return "agent-tag"
+}
+
+func (this *fakeDialog) GetDialogId() string {
+	return this.watcher + ":agent-tag"
+}
+
+func (this *fakeDialog) CreateRequest(method string) (message.Request, error) {
+	this.cseq++
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(method + " sip:watcher@192.0.2.2 SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bK" + strconv.Itoa(this.cseq) + "\r\n" +
+		"From: <sip:bob@b.example.com>;tag=agent-tag\r\n" +
+		"To: <" + this.watcher + ">;tag=watcher-tag\r\n" +
+		"Call-ID: " + this.watcher + "\r\n" +
+		"CSeq: " + strconv.Itoa(this.cseq) + " " + method + "\r\n" +
+		"Max-Forwards: 70\r\n" +
+		"Content-Length: 0\r\n\r\n")
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*message.SIPRequest), nil
+}
+
+func (this *fakeDialog) SendRequest(ct sip.ClientTransaction) error {
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(ct.GetRequest().String())
+	if err != nil {
+		this.t.Fatal(err)
+	}
+	this.sent = append(this.sent, msg.(*message.SIPRequest))
+	return nil
+}
+
+func (this *fakeDialog) last() *message.SIPRequest {
+	if len(this.sent) == 0 {
+		this.t.Fatal("no NOTIFY sent to", this.watcher)
+	}
+	return this.sent[len(this.sent)-1]
+}
+
+type fakeServerTransaction struct {
+	sip.ServerTransaction
+	request   message.Request
+	dialog    sip.Dialog
+	responses []message.Response
+}
+
+func (this *fakeServerTransaction) GetRequest() message.Request {
+	return this.request
+}
+
+func (this *fakeServerTransaction) GetDialog() sip.Dialog {
+	return this.dialog
+}
+
+func (this *fakeServerTransaction) SendResponse(response message.Response) error {
+	this.responses = append(this.responses, response)
+	return nil
+}
+
+type watcherAuthorizer map[string]bool
+
+func (this watcherAuthorizer) IsAuthorized(presentity, watcher string) bool {
+	return this[watcher]
+}
+
+func (this watcherAuthorizer) IsWatcherInfoAuthorized(presentity, subscriber string) bool {
+	return this["winfo:"+subscriber]
+}
+
+// subscribe hands a SUBSCRIBE of watcher to the presence of bob to agent.
+func subscribe(t *testing.T, agent *PresenceAgent, watcher, eventType string) (*fakeDialog, *fakeServerTransaction) {
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage("SUBSCRIBE sip:bob@b.example.com SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP 192.0.2.2;branch=z9hG4bKsubscribe\r\n" +
+		"From: <" + watcher + ">;tag=watcher-tag\r\n" +
+		"To: <sip:bob@b.example.com>\r\n" +
+		"Call-ID: " + watcher + "\r\n" +
+		"CSeq: 1 SUBSCRIBE\r\n" +
+		"Event: " + eventType + "\r\n" +
+		"Expires: 600\r\n" +
+		"Max-Forwards: 70\r\n" +
+		"Content-Length: 0\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialog := &fakeDialog{t: t, watcher: watcher}
+	transaction := &fakeServerTransaction{request: msg.(*message.SIPRequest), dialog: dialog}
+	if response := agent.ProcessSubscribe(transaction); response != nil {
+		transaction.responses = append(transaction.responses, response)
+	}
+	return dialog, transaction
+}
+
+func getState(t *testing.T, notify *message.SIPRequest) string {
+	subscriptionState, ok := notify.GetHeader(core.SIPHeaderNames_SUBSCRIPTION_STATE).(header.SubscriptionStateHeader)
+	if !ok {
+		t.Fatal("no Subscription-State in", notify)
+	}
+	return subscriptionState.GetState()
+}
+
+func TestPresenceAgent(t *testing.T) {
+	agent := NewPresenceAgent(sip.NewNotifier(&fakeProvider{}))
+	agent.SetWatcherAuthorizer(watcherAuthorizer{"sip:alice@a.example.com": true})
+	presence := NewPresence("sip:bob@b.example.com")
+	presence.Tuples = append(presence.Tuples, NewTuple("phone", BASIC_OPEN))
+	agent.Publish("sip:bob@b.example.com", "phone", presence)
+
+	// bob follows his own watchers
+	bob, _ := subscribe(t, agent, "sip:bob@b.example.com", PRESENCE_WINFO_EVENT)
+	if notify := bob.last(); getState(t, notify) != header.SubscriptionState_ACTIVE || notify.GetContent() == "" {
+		t.Fatal("bad watcher information NOTIFY", notify)
+	}
+
+	alice, transaction := subscribe(t, agent, "sip:alice@a.example.com", PRESENCE_EVENT)
+	if transaction.responses[0].GetStatusCode() != message.OK {
+		t.Fatal("SUBSCRIBE rejected with", transaction.responses[0].GetStatusCode())
+	}
+	notify := alice.last()
+	if getState(t, notify) != header.SubscriptionState_ACTIVE || !strings.Contains(notify.GetContent(), "phone") {
+		t.Fatal("bad presence NOTIFY", notify)
+	}
+	if !strings.Contains(bob.last().GetContent(), "sip:alice@a.example.com") {
+		t.Error("new watcher not reported", bob.last().GetContent())
+	}
+
+	// eve waits for bob to decide and sees nothing meanwhile
+	eve, _ := subscribe(t, agent, "sip:eve@e.example.com", PRESENCE_EVENT)
+	if notify := eve.last(); getState(t, notify) != header.SubscriptionState_PENDING || notify.GetContent() != "" {
+		t.Fatal("state given to a pending watcher", notify)
+	}
+	watcherInfo, err := ParseWatcherInfo(bob.last().GetContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watchers := watcherInfo.GetWatcherList("sip:bob@b.example.com", PRESENCE_EVENT).Watchers; len(watchers) != 2 ||
+		watchers[1].URI != "sip:eve@e.example.com" || watchers[1].Status != WATCHER_PENDING {
+		t.Errorf("pending watcher not reported %+v", watchers)
+	}
+
+	presence = NewPresence("sip:bob@b.example.com")
+	presence.Tuples = append(presence.Tuples, NewTuple("pc", BASIC_CLOSED))
+	agent.Publish("sip:bob@b.example.com", "pc", presence)
+	if notify := alice.last(); !strings.Contains(notify.GetContent(), "phone") || !strings.Contains(notify.GetContent(), "pc") {
+		t.Error("composed presence not notified", notify.GetContent())
+	}
+	if len(eve.sent) != 1 {
+		t.Error("pending watcher notified")
+	}
+
+	agent.AuthorizeWatcher("sip:bob@b.example.com", "sip:eve@e.example.com", false)
+	if notify := eve.last(); getState(t, notify) != header.SubscriptionState_TERMINATED || notify.GetContent() != "" {
+		t.Error("rejected watcher not terminated", notify)
+	}
+}
+
+func TestPresenceAgentWatcherInfoAuthorization(t *testing.T) {
+	agent := NewPresenceAgent(sip.NewNotifier(&fakeProvider{}))
+
+	// without authorizer only bob sees his watchers
+	eve, transaction := subscribe(t, agent, "sip:eve@e.example.com", PRESENCE_WINFO_EVENT)
+	if len(transaction.responses) != 1 || transaction.responses[0].GetStatusCode() != message.FORBIDDEN {
+		t.Fatal("foreign watcher information subscription not rejected with 403")
+	}
+	if len(eve.sent) != 0 {
+		t.Fatal("watcher information notified to a foreign subscriber")
+	}
+
+	// a foreign subscriber authorized by the application
+	agent.SetWatcherAuthorizer(watcherAuthorizer{"winfo:sip:carol@c.example.com": true})
+	carol, transaction := subscribe(t, agent, "sip:carol@c.example.com", PRESENCE_WINFO_EVENT)
+	if transaction.responses[0].GetStatusCode() != message.OK || len(carol.sent) != 1 {
+		t.Fatal("authorized watcher information subscription rejected")
+	}
+	eve, transaction = subscribe(t, agent, "sip:eve@e.example.com", PRESENCE_WINFO_EVENT)
+	if transaction.responses[0].GetStatusCode() != message.FORBIDDEN || len(eve.sent) != 0 {
+		t.Fatal("foreign watcher information subscription accepted")
+	}
+
+	// eve's presence subscription is reported to carol
+	eve, _ = subscribe(t, agent, "sip:eve@e.example.com", PRESENCE_EVENT)
+	if len(carol.sent) != 2 || len(eve.sent) != 1 || getState(t, eve.last()) != header.SubscriptionState_PENDING {
+		t.Error("bad watcher information NOTIFYs", len(carol.sent), len(eve.sent))
+	}
+}
@@ -0,0 +1,113 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Rpid.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package presence
+
+import (
+	"encoding/xml"
+)
+
+/**
+ * Some of the activities defined by RPID (RFC 4480).
+ */
+const (
+	ACTIVITY_APPOINTMENT  = "appointment"
+	ACTIVITY_AWAY         = "away"
+	ACTIVITY_BUSY         = "busy"
+	ACTIVITY_HOLIDAY      = "holiday"
+	ACTIVITY_MEAL         = "meal"
+	ACTIVITY_MEETING      = "meeting"
+	ACTIVITY_ON_THE_PHONE = "on-the-phone"
+	ACTIVITY_SLEEPING     = "sleeping"
+	ACTIVITY_TRAVEL       = "travel"
+	ACTIVITY_VACATION     = "vacation"
+	ACTIVITY_WORKING      = "working"
+	ACTIVITY_UNKNOWN      = "unknown"
+)
+
+/**
+ * Some of the moods defined by RPID (RFC 4480).
+ */
+const (
+	MOOD_AFRAID   = "afraid"
+	MOOD_ANGRY    = "angry"
+	MOOD_BORED    = "bored"
+	MOOD_HAPPY    = "happy"
+	MOOD_SAD      = "sad"
+	MOOD_SLEEPY   = "sleepy"
+	MOOD_STRESSED = "stressed"
+	MOOD_UNKNOWN  = "unknown"
+)
+
+/**
+ * The content of the RPID activities and mood elements: a list of empty
+ * elements naming the values, e.g. <code>&lt;rpid:away/&gt;</code>, plus the
+ * free text of an <code>&lt;rpid:other&gt;</code> element and notes.
+ */
+type RpidValues struct {
+	Notes  []*Note      `xml:"note"`
+	Values []*RpidValue `xml:",any"`
+}
+
+/**
+ * A single activity or mood.
+ */
+type RpidValue struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+}
+
+/** Creates the values with the given names.
+ */
+func NewRpidValues(names ...string) *RpidValues {
+	this := &RpidValues{}
+	for _, name := range names {
+		this.Add(name)
+	}
+	return this
+}
+
+/** Adds a value unless it is already present.
+ */
+func (this *RpidValues) Add(name string) {
+	if !this.Has(name) {
+		this.Values = append(this.Values, &RpidValue{XMLName: xml.Name{Space: RPID_NAMESPACE, Local: name}})
+	}
+}
+
+/** Sets the free text of the "other" value.
+ */
+func (this *RpidValues) SetOther(text string) {
+	for _, value := range this.Values {
+		if value.XMLName.Local == "other" {
+			value.Text = text
+			return
+		}
+	}
+	this.Values = append(this.Values, &RpidValue{XMLName: xml.Name{Space: RPID_NAMESPACE, Local: "other"}, Text: text})
+}
+
+/** Returns true if the value is present.
+ */
+func (this *RpidValues) Has(name string) bool {
+	for _, value := range this.Values {
+		if value.XMLName.Local == name {
+			return true
+		}
+	}
+	return false
+}
+
+/** Returns the names of the values.
+ */
+func (this *RpidValues) GetNames() []string {
+	names := make([]string, 0, len(this.Values))
+	for _, value := range this.Values {
+		names = append(names, value.XMLName.Local)
+	}
+	return names
+}
@@ -0,0 +1,158 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : WatcherInfo.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package presence
+
+import (
+	"encoding/xml"
+	"errors"
+	"gosips/sip/header"
+	"gosips/sip/message"
+)
+
+/**
+ * The XML namespace of watcher information documents (RFC 3858).
+ */
+const WATCHERINFO_NAMESPACE = "urn:ietf:params:xml:ns:watcherinfo"
+
+/**
+ * The event package of watcher information for presence (RFC 3857).
+ */
+const PRESENCE_WINFO_EVENT = "presence.winfo"
+
+/**
+ * Values of the state attribute of a watcher information document.
+ */
+const (
+	WATCHERINFO_FULL    = "full"
+	WATCHERINFO_PARTIAL = "partial"
+)
+
+/**
+ * Values of the status attribute of a watcher.
+ */
+const (
+	WATCHER_PENDING    = "pending"
+	WATCHER_ACTIVE     = "active"
+	WATCHER_WAITING    = "waiting"
+	WATCHER_TERMINATED = "terminated"
+)
+
+/**
+ * Values of the event attribute of a watcher, the event that led to its
+ * status.
+ */
+const (
+	WATCHER_EVENT_SUBSCRIBE   = "subscribe"
+	WATCHER_EVENT_APPROVED    = "approved"
+	WATCHER_EVENT_DEACTIVATED = "deactivated"
+	WATCHER_EVENT_PROBATION   = "probation"
+	WATCHER_EVENT_REJECTED    = "rejected"
+	WATCHER_EVENT_TIMEOUT     = "timeout"
+	WATCHER_EVENT_GIVE_UP     = "giveup"
+	WATCHER_EVENT_NO_RESOURCE = "noresource"
+)
+
+/**
+ * A watcher information document listing the watchers of resources.
+ * Version is incremented by the notifier for each document sent in a
+ * subscription.
+ */
+type WatcherInfo struct {
+	XMLName      xml.Name       `xml:"urn:ietf:params:xml:ns:watcherinfo watcherinfo"`
+	Version      int            `xml:"version,attr"`
+	State        string         `xml:"state,attr"`
+	WatcherLists []*WatcherList `xml:"watcher-list"`
+}
+
+/**
+ * The watchers of a resource for an event package.
+ */
+type WatcherList struct {
+	Resource string     `xml:"resource,attr"`
+	Package  string     `xml:"package,attr"`
+	Watchers []*Watcher `xml:"watcher"`
+}
+
+/**
+ * A watcher, identified by the URI of the subscriber.
+ */
+type Watcher struct {
+	Id                 string `xml:"id,attr"`
+	Status             string `xml:"status,attr"`
+	Event              string `xml:"event,attr"`
+	DisplayName        string `xml:"display-name,attr,omitempty"`
+	Expiration         int    `xml:"expiration,attr,omitempty"`
+	DurationSubscribed int    `xml:"duration-subscribed,attr,omitempty"`
+	URI                string `xml:",chardata"`
+}
+
+/** Creates an empty watcher information document.
+ */
+func NewWatcherInfo(version int, state string) *WatcherInfo {
+	return &WatcherInfo{Version: version, State: state}
+}
+
+/** Returns the watcher list of resource for the event package, adding it if
+ * it is not present.
+ */
+func (this *WatcherInfo) GetWatcherList(resource, eventPackage string) *WatcherList {
+	for _, watcherList := range this.WatcherLists {
+		if watcherList.Resource == resource && watcherList.Package == eventPackage {
+			return watcherList
+		}
+	}
+	watcherList := &WatcherList{Resource: resource, Package: eventPackage}
+	this.WatcherLists = append(this.WatcherLists, watcherList)
+	return watcherList
+}
+
+/** Returns the document encoded as XML.
+ */
+func (this *WatcherInfo) Encode() (string, error) {
+	b, err := xml.Marshal(this)
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b), nil
+}
+
+/** Parses a watcher information document.
+ */
+func ParseWatcherInfo(body string) (*WatcherInfo, error) {
+	watcherInfo := &WatcherInfo{}
+	if err := xml.Unmarshal([]byte(body), watcherInfo); err != nil {
+		return nil, errors.New("ParseException: bad watcherinfo document: " + err.Error())
+	}
+	return watcherInfo, nil
+}
+
+/** Returns the "application/watcherinfo+xml" content type.
+ */
+func NewWatcherInfoContentType() header.ContentTypeHeader {
+	return header.NewContentTypeFromString("application", "watcherinfo+xml")
+}
+
+/** Sets the document as the body of msg.
+ */
+func SetWatcherInfoContent(msg message.Message, watcherInfo *WatcherInfo) error {
+	body, err := watcherInfo.Encode()
+	if err != nil {
+		return err
+	}
+	msg.SetContent(body, NewWatcherInfoContentType())
+	return nil
+}
+
+/** Returns the watcher information document carried by msg.
+ */
+func GetWatcherInfoContent(msg message.Message) (*WatcherInfo, error) {
+	if !hasContentType(msg, "application", "watcherinfo+xml") {
+		return nil, errors.New("ParseException: the message does not carry a watcherinfo document")
+	}
+	return ParseWatcherInfo(msg.GetContent())
+}
@@ -0,0 +1,47 @@
+package presence
+
+import (
+	"testing"
+)
+
+func TestWatcherInfo(t *testing.T) {
+	body := `<?xml version="1.0"?>
+<watcherinfo xmlns="urn:ietf:params:xml:ns:watcherinfo"
+             version="0" state="full">
+  <watcher-list resource="sip:professor@example.net" package="presence">
+    <watcher status="active"
+             id="8ajksjda7s"
+             duration-subscribed="509"
+             event="approved">sip:userA@example.net</watcher>
+    <watcher status="pending"
+             id="hh8juja87s997-ass7"
+             display-name="Mr. Subscriber"
+             event="subscribe">sip:userB@example.org</watcher>
+  </watcher-list>
+</watcherinfo>`
+
+	watcherInfo, err := ParseWatcherInfo(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watcherInfo.Version != 0 || watcherInfo.State != WATCHERINFO_FULL || len(watcherInfo.WatcherLists) != 1 {
+		t.Fatalf("bad watcherinfo %+v", watcherInfo)
+	}
+	watchers := watcherInfo.GetWatcherList("sip:professor@example.net", PRESENCE_EVENT).Watchers
+	if len(watchers) != 2 || watchers[0].DurationSubscribed != 509 || watchers[0].URI != "sip:userA@example.net" ||
+		watchers[1].Status != WATCHER_PENDING || watchers[1].DisplayName != "Mr. Subscriber" {
+		t.Fatalf("bad watchers %+v", watchers)
+	}
+
+	encoded, err := watcherInfo.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ParseWatcherInfo(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.WatcherLists[0].Watchers) != 2 || decoded.WatcherLists[0].Watchers[1].Event != WATCHER_EVENT_SUBSCRIBE {
+		t.Errorf("bad round trip %s", encoded)
+	}
+}
@@ -0,0 +1,404 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : CallerPreferences.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package proxy
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/** The feature tags of the sip tree (RFC 3840 section 10), encoded in the
+ * Contact header without their "+sip." prefix.
+ */
+var baseFeatureTags = map[string]bool{
+	"audio":       true,
+	"application": true,
+	"data":        true,
+	"control":     true,
+	"video":       true,
+	"text":        true,
+	"automata":    true,
+	"class":       true,
+	"duplex":      true,
+	"mobility":    true,
+	"description": true,
+	"events":      true,
+	"priority":    true,
+	"methods":     true,
+	"schemes":     true,
+	"extensions":  true,
+	"isfocus":     true,
+	"actor":       true,
+	"language":    true,
+	"type":        true,
+}
+
+/**
+ * The feature set of a contact (RFC 3840): the values of each of its
+ * feature tags. Tag names are lower case and without their leading "+";
+ * the value of a boolean feature present without value is TRUE.
+ */
+type FeatureSet map[string][]string
+
+/** Returns true if a Contact, Accept-Contact or Reject-Contact parameter is
+ * a feature tag: a tag of the sip tree or a name starting with "+", except
+ * the +sip.instance of outbound and GRUU.
+ */
+func IsFeatureTag(name string) bool {
+	name = strings.ToLower(name)
+	if name == header.ParameterNames_SIP_INSTANCE {
+		return false
+	}
+	return baseFeatureTags[name] || strings.HasPrefix(name, "+")
+}
+
+/** Returns the feature set described by the feature tags of parameters,
+ * e.g. those of a Contact.
+ */
+func GetFeatureSet(parameters *core.NameValueList) FeatureSet {
+	features := make(FeatureSet)
+	if parameters == nil {
+		return features
+	}
+	for e := parameters.Front(); e != nil; e = e.Next() {
+		nv := e.Value.(*core.NameValue)
+		if !IsFeatureTag(nv.GetName()) {
+			continue
+		}
+		var value string
+		if v, ok := nv.GetValue().(string); ok {
+			value = strings.Trim(v, "\"")
+		}
+		name := strings.TrimPrefix(strings.ToLower(nv.GetName()), "+")
+		if value == "" {
+			features[name] = []string{"TRUE"}
+			continue
+		}
+		for _, v := range strings.Split(value, ",") {
+			features[name] = append(features[name], strings.TrimSpace(v))
+		}
+	}
+	return features
+}
+
+/** Matches the feature predicate of an Accept-Contact or Reject-Contact
+ * against features (RFC 3841 section 7.2.4). The feature tags of the
+ * predicate absent from features are ignored, so matched tells whether
+ * features match the other tags; present and total are the number of
+ * feature tags of the predicate found in features and in the predicate.
+ */
+func MatchFeatures(predicate FeatureSet, features FeatureSet) (matched bool, present, total int) {
+	matched = true
+	for name, values := range predicate {
+		total++
+		featureValues, ok := features[name]
+		if !ok {
+			continue
+		}
+		present++
+		if !matchTagValues(values, featureValues) {
+			matched = false
+		}
+	}
+	return matched, present, total
+}
+
+/** Returns true if one of the values of a predicate tag, a disjunction,
+ * matches the values of the tag in a feature set.
+ */
+func matchTagValues(values, featureValues []string) bool {
+	for _, value := range values {
+		if strings.HasPrefix(value, "!") {
+			if !matchTagValue(value[1:], featureValues) {
+				return true
+			}
+		} else if matchTagValue(value, featureValues) {
+			return true
+		}
+	}
+	return false
+}
+
+/** Returns true if a tag value of a predicate - a token or boolean compared
+ * case-insensitively, a quoted string in angle brackets compared exactly, or
+ * a numeric comparison "#=n", "#>=n", "#<=n" or range "#a:b" - matches one
+ * of the values of a feature set.
+ */
+func matchTagValue(value string, featureValues []string) bool {
+	for _, featureValue := range featureValues {
+		switch {
+		case strings.HasPrefix(value, "#"):
+			if n, err := strconv.ParseFloat(strings.TrimPrefix(featureValue, "#"), 64); err == nil &&
+				strings.HasPrefix(featureValue, "#") && matchNumeric(value[1:], n) {
+				return true
+			}
+		case strings.HasPrefix(value, "<"):
+			if value == featureValue {
+				return true
+			}
+		default:
+			if strings.EqualFold(value, featureValue) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchNumeric(comparison string, n float64) bool {
+	parse := func(s string) (float64, bool) {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return f, err == nil
+	}
+	switch {
+	case strings.HasPrefix(comparison, ">="):
+		bound, ok := parse(comparison[2:])
+		return ok && n >= bound
+	case strings.HasPrefix(comparison, "<="):
+		bound, ok := parse(comparison[2:])
+		return ok && n <= bound
+	case strings.HasPrefix(comparison, "="):
+		bound, ok := parse(comparison[1:])
+		return ok && n == bound
+	case strings.Contains(comparison, ":"):
+		bounds := strings.SplitN(comparison, ":", 2)
+		low, ok1 := parse(bounds[0])
+		high, ok2 := parse(bounds[1])
+		return ok1 && ok2 && n >= low && n <= high
+	}
+	return false
+}
+
+/** Applies the caller preferences of request to bindings (RFC 3841 section
+ * 7.2) and returns the remaining bindings ranked for the target set.
+ * <p>
+ * A binding whose contact lists the methods it accepts is discarded if the
+ * method of the request is not among them, and so for the events of a
+ * SUBSCRIBE. A binding is discarded by a Reject-Contact predicate whose
+ * feature tags it all indicates and matches. For each Accept-Contact
+ * predicate, a binding scores the fraction of the feature tags of the
+ * predicate it indicates if it matches the others, 0 if it does not match
+ * or, for an explicit predicate, does not indicate them all. A binding that
+ * does not match a predicate with require, or does not indicate all the
+ * feature tags of a predicate with require and explicit, is discarded. The
+ * bindings are ranked by q-value, then by their average score (section
+ * 7.2.5).
+ */
+func ApplyCallerPreferences(request message.Request, bindings []*Binding) []*Binding {
+	var accepts []header.AcceptContactHeader
+	for e := request.GetHeaders(core.SIPHeaderNames_ACCEPT_CONTACT).Front(); e != nil; e = e.Next() {
+		if acceptContact, ok := e.Value.(header.AcceptContactHeader); ok {
+			accepts = append(accepts, acceptContact)
+		}
+	}
+	var rejects []FeatureSet
+	for e := request.GetHeaders(core.SIPHeaderNames_REJECT_CONTACT).Front(); e != nil; e = e.Next() {
+		if rejectContact, ok := e.Value.(header.RejectContactHeader); ok {
+			rejects = append(rejects, GetFeatureSet(rejectContact.GetParameters()))
+		}
+	}
+
+	implicit := FeatureSet{"methods": {request.GetMethod()}}
+	if request.GetMethod() == message.SUBSCRIBE {
+		if event, ok := request.GetHeader(core.SIPHeaderNames_EVENT).(header.EventHeader); ok {
+			implicit["events"] = []string{event.GetEventType()}
+		}
+	}
+
+	type candidate struct {
+		binding *Binding
+		score   float64
+	}
+	var candidates []candidate
+	for _, b := range bindings {
+		features := GetFeatureSet(b.contact.GetParameters())
+		if matched, _, _ := MatchFeatures(implicit, features); !matched {
+			continue
+		}
+
+		rejected := false
+		for _, reject := range rejects {
+			if matched, present, total := MatchFeatures(reject, features); matched && present == total && total > 0 {
+				rejected = true
+				break
+			}
+		}
+		if rejected {
+			continue
+		}
+
+		score := 0.0
+		for _, accept := range accepts {
+			matched, present, total := MatchFeatures(GetFeatureSet(accept.GetParameters()), features)
+			if accept.IsRequire() && (!matched || accept.IsExplicit() && present < total) {
+				rejected = true
+				break
+			}
+			switch {
+			case !matched, accept.IsExplicit() && present < total:
+			case total == 0:
+				score += 1
+			default:
+				score += float64(present) / float64(total)
+			}
+		}
+		if rejected {
+			continue
+		}
+		if len(accepts) > 0 {
+			score /= float64(len(accepts))
+		}
+		candidates = append(candidates, candidate{b, score})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if qi, qj := candidates[i].binding.GetQValue(), candidates[j].binding.GetQValue(); qi != qj {
+			return qi > qj
+		}
+		return candidates[i].score > candidates[j].score
+	})
+	ranked := make([]*Binding, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = c.binding
+	}
+	return ranked
+}
+
+/**
+ * The target set of a request forwarded by a proxy to the bindings of its
+ * Request-URI, ranked by the caller preferences, with the directives of its
+ * Request-Disposition (RFC 3841 section 9.1). Directives absent from the
+ * request take the defaults of the proxy: proxy, cancel, fork, recurse,
+ * parallel and no-queue.
+ */
+type TargetSet struct {
+	bindings []*Binding
+	redirect bool
+	cancel   bool
+	fork     bool
+	recurse  bool
+	parallel bool
+	queue    bool
+}
+
+/** Computes the target set of request from the bindings of its Request-URI,
+ * e.g. those returned by LocationService.Lookup. An empty target set is
+ * answered with 480 (Temporarily Unavailable).
+ */
+func NewTargetSet(request message.Request, bindings []*Binding) *TargetSet {
+	this := &TargetSet{
+		bindings: ApplyCallerPreferences(request, bindings),
+		cancel:   true,
+		fork:     true,
+		recurse:  true,
+		parallel: true,
+	}
+	if disposition, ok := request.GetHeader(core.SIPHeaderNames_REQUEST_DISPOSITION).(header.RequestDispositionHeader); ok {
+		for _, directive := range disposition.GetDirectives() {
+			switch strings.ToLower(directive) {
+			case header.RequestDisposition_PROXY:
+				this.redirect = false
+			case header.RequestDisposition_REDIRECT:
+				this.redirect = true
+			case header.RequestDisposition_CANCEL:
+				this.cancel = true
+			case header.RequestDisposition_NO_CANCEL:
+				this.cancel = false
+			case header.RequestDisposition_FORK:
+				this.fork = true
+			case header.RequestDisposition_NO_FORK:
+				this.fork = false
+			case header.RequestDisposition_RECURSE:
+				this.recurse = true
+			case header.RequestDisposition_NO_RECURSE:
+				this.recurse = false
+			case header.RequestDisposition_PARALLEL:
+				this.parallel = true
+			case header.RequestDisposition_SEQUENTIAL:
+				this.parallel = false
+			case header.RequestDisposition_QUEUE:
+				this.queue = true
+			case header.RequestDisposition_NO_QUEUE:
+				this.queue = false
+			}
+		}
+	}
+	if !this.fork && len(this.bindings) > 1 {
+		this.bindings = this.bindings[:1]
+	}
+	return this
+}
+
+/** Returns the bindings of the target set, best first.
+ */
+func (this *TargetSet) GetBindings() []*Binding {
+	return this.bindings
+}
+
+/** Returns the bindings to try in turn: a single group with all the
+ * bindings when forking in parallel, a group per binding otherwise. The
+ * next group is tried when all the branches of the previous one failed.
+ */
+func (this *TargetSet) GetBranches() [][]*Binding {
+	if len(this.bindings) == 0 {
+		return nil
+	}
+	if this.parallel {
+		return [][]*Binding{this.bindings}
+	}
+	branches := make([][]*Binding, len(this.bindings))
+	for i, b := range this.bindings {
+		branches[i] = []*Binding{b}
+	}
+	return branches
+}
+
+/** Returns true if the caller asked for the target set to be returned in a
+ * redirect response instead of being proxied to.
+ */
+func (this *TargetSet) IsRedirect() bool {
+	return this.redirect
+}
+
+/** Returns true if the pending branches are to be cancelled when one of
+ * them is answered with a 2xx.
+ */
+func (this *TargetSet) IsCancel() bool {
+	return this.cancel
+}
+
+/** Returns true if the request may be forked to several bindings.
+ */
+func (this *TargetSet) IsFork() bool {
+	return this.fork
+}
+
+/** Returns true if the contacts of 3xx responses to the branches are to be
+ * added to the target set.
+ */
+func (this *TargetSet) IsRecurse() bool {
+	return this.recurse
+}
+
+/** Returns true if the branches are tried in parallel, false if one after
+ * the other.
+ */
+func (this *TargetSet) IsParallel() bool {
+	return this.parallel
+}
+
+/** Returns true if the caller wishes the request to be queued when the
+ * called party is busy, instead of receiving a 486 (Busy Here).
+ */
+func (this *TargetSet) IsQueue() bool {
+	return this.queue
+}
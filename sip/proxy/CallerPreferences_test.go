@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strings"
+	"testing"
+)
+
+func TestMatchFeatures(t *testing.T) {
+	features := FeatureSet{
+		"audio":          {"TRUE"},
+		"methods":        {"INVITE", "BYE"},
+		"language":       {"<en>", "<fr>"},
+		"priority":       {"#10"},
+		"rfc-3841.color": {"red"},
+	}
+	tests := []struct {
+		predicate      FeatureSet
+		matched        bool
+		present, total int
+	}{
+		{FeatureSet{}, true, 0, 0},
+		{FeatureSet{"audio": {"TRUE"}}, true, 1, 1},
+		{FeatureSet{"audio": {"false"}}, false, 1, 1},
+		{FeatureSet{"video": {"TRUE"}}, true, 0, 1},
+		{FeatureSet{"audio": {"TRUE"}, "video": {"TRUE"}}, true, 1, 2},
+		{FeatureSet{"methods": {"invite"}}, true, 1, 1},
+		{FeatureSet{"methods": {"MESSAGE", "BYE"}}, true, 1, 1},
+		{FeatureSet{"methods": {"!MESSAGE"}}, true, 1, 1},
+		{FeatureSet{"methods": {"!INVITE"}}, false, 1, 1},
+		{FeatureSet{"language": {"<fr>"}}, true, 1, 1},
+		{FeatureSet{"language": {"<FR>"}}, false, 1, 1},
+		{FeatureSet{"priority": {"#>=5"}}, true, 1, 1},
+		{FeatureSet{"priority": {"#<=5"}}, false, 1, 1},
+		{FeatureSet{"priority": {"#=10"}}, true, 1, 1},
+		{FeatureSet{"priority": {"#1:9"}}, false, 1, 1},
+		{FeatureSet{"priority": {"#10:20"}}, true, 1, 1},
+		{FeatureSet{"priority": {"10"}}, false, 1, 1},
+		{FeatureSet{"rfc-3841.color": {"RED"}, "audio": {"FALSE"}}, false, 2, 2},
+	}
+	for i, test := range tests {
+		matched, present, total := MatchFeatures(test.predicate, features)
+		if matched != test.matched || present != test.present || total != test.total {
+			t.Errorf("%d %v: got %v %d/%d", i, test.predicate, matched, present, total)
+		}
+	}
+}
+
+func TestGetFeatureSet(t *testing.T) {
+	request := register(t, 1, "Contact: <sip:bob@192.0.2.1>;audio;methods=\"INVITE,BYE\";+rfc-3841.color=red;"+
+		"+sip.instance=\"<urn:uuid:1>\";expires=60\r\n")
+	features := GetFeatureSet(request.GetHeader(core.SIPHeaderNames_CONTACT).(header.ContactHeader).GetParameters())
+	if len(features) != 3 || strings.Join(features["audio"], ",") != "TRUE" ||
+		strings.Join(features["methods"], ",") != "INVITE,BYE" || strings.Join(features["rfc-3841.color"], ",") != "red" {
+		t.Error("bad feature set", features)
+	}
+}
+
+// bobBindings registers the contacts of bob: a phone, a video phone, a
+// voicemail and a PC that only accepts MESSAGE and SUBSCRIBE.
+func bobBindings(t *testing.T) *LocationService {
+	location := NewLocationService()
+	response := NewRegistrar(location).ProcessRegister(register(t, 1,
+		"Contact: <sip:bob@phone.example.com>;audio;q=0.9\r\n"+
+			"Contact: <sip:bob@video.example.com>;audio;video;q=0.5\r\n"+
+			"Contact: <sip:bob@vm.example.com>;audio;actor=\"msg-taker\";automata;q=0.1\r\n"+
+			"Contact: <sip:bob@pc.example.com>;methods=\"MESSAGE,SUBSCRIBE\";events=\"presence\";q=1.0\r\n"))
+	if response.GetStatusCode() != message.OK {
+		t.Fatal("REGISTER rejected with", response.GetStatusCode())
+	}
+	return location
+}
+
+func preferencesRequest(t *testing.T, method string, headers string) *message.SIPRequest {
+	return parseRequest(t, method+" sip:bob@example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP 198.51.100.3;branch=z9hG4bKcarol\r\n"+
+		"From: <sip:carol@c.example.com>;tag=carol-tag\r\n"+
+		"To: <sip:bob@example.com>\r\n"+
+		"Call-ID: carol@c.example.com\r\n"+
+		"CSeq: 1 "+method+"\r\n"+
+		"Max-Forwards: 70\r\n"+headers+
+		"Content-Length: 0\r\n\r\n")
+}
+
+func getHosts(bindings []*Binding) string {
+	var hosts []string
+	for _, b := range bindings {
+		hosts = append(hosts, strings.TrimSuffix(b.GetContactURI().(*address.SipURIImpl).GetHost(), ".example.com"))
+	}
+	return strings.Join(hosts, " ")
+}
+
+func TestApplyCallerPreferences(t *testing.T) {
+	location := bobBindings(t)
+	tests := []struct {
+		method  string
+		headers string
+		hosts   string
+	}{
+		// the PC does not accept INVITE, the others by q-value
+		{message.INVITE, "", "phone video vm"},
+		{message.MESSAGE, "", "pc phone video vm"},
+		{message.SUBSCRIBE, "Event: presence\r\n", "pc phone video vm"},
+		{message.SUBSCRIBE, "Event: dialog\r\n", "phone video vm"},
+		// the video phone indicates video, the others are neutral: the
+		// q-value ranks before the score
+		{message.INVITE, "Accept-Contact: *;video\r\n", "phone video vm"},
+		// only the contacts not indicating video are discarded with explicit
+		// and require
+		{message.INVITE, "Accept-Contact: *;video;require\r\n", "phone video vm"},
+		{message.INVITE, "Accept-Contact: *;video;explicit\r\n", "phone video vm"},
+		{message.INVITE, "Accept-Contact: *;video;require;explicit\r\n", "video"},
+		{message.INVITE, "Accept-Contact: *;video=FALSE;require\r\n", "phone vm"},
+		// a human: the voicemail is an automaton
+		{message.INVITE, "Accept-Contact: *;automata=FALSE;require\r\n", "phone video"},
+		{message.INVITE, "Reject-Contact: *;actor=\"msg-taker\"\r\n", "phone video"},
+		// a Reject-Contact only discards the contacts indicating all its tags
+		{message.INVITE, "Reject-Contact: *;actor=\"msg-taker\";video\r\n", "phone video vm"},
+		// the voicemail scores best but has the lowest q-value
+		{message.INVITE, "Accept-Contact: *;audio;automata\r\nAccept-Contact: *;actor=\"msg-taker\"\r\n", "phone video vm"},
+		{message.INVITE, "Accept-Contact: *;audio;video;require;explicit\r\n", "video"},
+	}
+	for _, test := range tests {
+		request := preferencesRequest(t, test.method, test.headers)
+		hosts := getHosts(ApplyCallerPreferences(request, location.Lookup(request.GetRequestURI())))
+		if hosts != test.hosts {
+			t.Errorf("%s %q: expected %s, got %s", test.method, test.headers, test.hosts, hosts)
+		}
+	}
+
+	// the average score of the predicates ranks the bindings of equal q-value
+	location = NewLocationService()
+	NewRegistrar(location).ProcessRegister(register(t, 1,
+		"Contact: <sip:bob@phone.example.com>;audio;q=0.5\r\n"+
+			"Contact: <sip:bob@video.example.com>;audio;video;q=0.5\r\n"+
+			"Contact: <sip:bob@vm.example.com>;audio;actor=\"msg-taker\";automata;q=0.5\r\n"))
+	request := preferencesRequest(t, message.INVITE, "Accept-Contact: *;audio;automata\r\nAccept-Contact: *;video\r\n")
+	if hosts := getHosts(ApplyCallerPreferences(request, location.Lookup(request.GetRequestURI()))); hosts != "video vm phone" {
+		t.Error("bad ranking of equal q-values", hosts)
+	}
+}
+
+func TestTargetSet(t *testing.T) {
+	location := bobBindings(t)
+	targets := func(headers string) *TargetSet {
+		request := preferencesRequest(t, message.INVITE, headers)
+		return NewTargetSet(request, location.Lookup(request.GetRequestURI()))
+	}
+
+	targetSet := targets("")
+	if getHosts(targetSet.GetBindings()) != "phone video vm" || targetSet.IsRedirect() || !targetSet.IsCancel() ||
+		!targetSet.IsFork() || !targetSet.IsRecurse() || !targetSet.IsParallel() || targetSet.IsQueue() {
+		t.Error("bad defaults", getHosts(targetSet.GetBindings()))
+	}
+	if branches := targetSet.GetBranches(); len(branches) != 1 || getHosts(branches[0]) != "phone video vm" {
+		t.Error("bad parallel branches", branches)
+	}
+
+	targetSet = targets("Request-Disposition: redirect, no-cancel, no-recurse, sequential, queue\r\n")
+	if !targetSet.IsRedirect() || targetSet.IsCancel() || targetSet.IsRecurse() || targetSet.IsParallel() || !targetSet.IsQueue() {
+		t.Error("directives ignored")
+	}
+	var hosts []string
+	for _, branch := range targetSet.GetBranches() {
+		hosts = append(hosts, getHosts(branch))
+	}
+	if strings.Join(hosts, ",") != "phone,video,vm" {
+		t.Error("bad sequential branches", hosts)
+	}
+
+	// no-fork keeps the best binding only
+	targetSet = targets("Accept-Contact: *;video\r\nRequest-Disposition: no-fork\r\n")
+	if getHosts(targetSet.GetBindings()) != "phone" || len(targetSet.GetBranches()) != 1 {
+		t.Error("request forked", getHosts(targetSet.GetBindings()))
+	}
+
+	if targetSet := targets("Accept-Contact: *;text;require;explicit\r\n"); targetSet.GetBranches() != nil {
+		t.Error("empty target set with branches")
+	}
+}
@@ -0,0 +1,288 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : EdgeProxy.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"gosips/core"
+	"gosips/sip"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"gosips/stun"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/** The length of the truncated HMAC-SHA1 of flow tokens, 80 bits.
+ */
+const flowTokenMACLength = 10
+
+/**
+ * Identifies a flow between a User Agent and the edge proxy by its
+ * transport and its local and remote transport addresses, e.g. "TCP",
+ * "192.0.2.1:5060" and "203.0.113.7:49152".
+ */
+type FlowId struct {
+	Transport string
+	Local     string
+	Remote    string
+}
+
+func (this FlowId) String() string {
+	return this.Transport + " " + this.Local + " " + this.Remote
+}
+
+/**
+ * The edge proxy half of SIP Outbound (RFC 5626 section 5). The edge proxy
+ * is the first hop of the User Agents behind NATs: it records the flow a
+ * REGISTER or dialog-forming request arrived on in a flow token placed in
+ * the user part of its Path or Record-Route URI, so that requests routed
+ * back through it are sent over the same flow. Flow tokens are signed with
+ * an HMAC so that they cannot be forged. The edge proxy also answers the
+ * CRLF and STUN keep-alives of the User Agents.
+ * <p>
+ * The transport layer reports the flows it opens and closes with AddFlow
+ * and RemoveFlow.
+ */
+type EdgeProxy struct {
+	mutex sync.Mutex
+
+	host  string
+	port  int
+	key   []byte
+	flows map[FlowId]bool
+}
+
+/** Creates an edge proxy reachable at host and port, signing its flow
+ * tokens with key, a random secret of at least 20 bytes.
+ */
+func NewEdgeProxy(host string, port int, key []byte) *EdgeProxy {
+	return &EdgeProxy{
+		host:  host,
+		port:  port,
+		key:   key,
+		flows: make(map[FlowId]bool),
+	}
+}
+
+/** Records a flow opened by the transport layer.
+ */
+func (this *EdgeProxy) AddFlow(flow FlowId) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.flows[flow] = true
+}
+
+/** Forgets a flow closed by the transport layer; requests for it are then
+ * rejected with 430.
+ */
+func (this *EdgeProxy) RemoveFlow(flow FlowId) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	delete(this.flows, flow)
+}
+
+/** Returns true if the flow is open.
+ */
+func (this *EdgeProxy) HasFlow(flow FlowId) bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.flows[flow]
+}
+
+/** Returns the flow token of flow: the flow and its truncated HMAC-SHA1,
+ * base64url encoded (RFC 5626 section 5.2).
+ */
+func (this *EdgeProxy) CreateFlowToken(flow FlowId) string {
+	data := []byte(flow.String())
+	token := append(this.mac(data), data...)
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+/** Returns the flow of a flow token, or an error if the token was not
+ * created by this edge proxy.
+ */
+func (this *EdgeProxy) ParseFlowToken(token string) (flow FlowId, SipException error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) <= flowTokenMACLength {
+		return flow, errors.New("SipException: bad flow token")
+	}
+	if !hmac.Equal(data[:flowTokenMACLength], this.mac(data[flowTokenMACLength:])) {
+		return flow, errors.New("SipException: forged flow token")
+	}
+	fields := strings.Split(string(data[flowTokenMACLength:]), " ")
+	if len(fields) != 3 {
+		return flow, errors.New("SipException: bad flow token")
+	}
+	return FlowId{Transport: fields[0], Local: fields[1], Remote: fields[2]}, nil
+}
+
+func (this *EdgeProxy) mac(data []byte) []byte {
+	h := hmac.New(sha1.New, this.key)
+	h.Write(data)
+	return h.Sum(nil)[:flowTokenMACLength]
+}
+
+/** Returns the URI of the edge proxy with the flow token of flow as user
+ * part and the lr parameter, and the ob parameter if ob is true.
+ */
+func (this *EdgeProxy) createFlowAddress(flow FlowId, ob bool) (address.Address, error) {
+	uri := "sip:" + this.CreateFlowToken(flow) + "@" + this.host
+	if this.port > 0 {
+		uri += ":" + strconv.Itoa(this.port)
+	}
+	if !strings.EqualFold(flow.Transport, sip.UDP) {
+		uri += ";transport=" + strings.ToLower(flow.Transport)
+	}
+	uri += ";lr"
+	if ob {
+		uri += ";" + header.ParameterNames_OB
+	}
+	return parser.NewAddressParser("<" + uri + ">").Address()
+}
+
+/** Processes a REGISTER received over flow before it is forwarded to the
+ * registrar: a Path header with the flow token is pushed on top of the
+ * others, with the ob parameter if the User Agent registers with a reg-id.
+ * A REGISTER with a reg-id whose User Agent does not support Path cannot be
+ * handled and the response to send, 421, is returned.
+ */
+func (this *EdgeProxy) ProcessRegister(register *message.SIPRequest, flow FlowId) *message.SIPResponse {
+	outbound := false
+	for e := register.GetHeaders(core.SIPHeaderNames_CONTACT).Front(); e != nil; e = e.Next() {
+		if contact, ok := e.Value.(header.ContactHeader); ok && contact.GetRegId() > 0 {
+			outbound = true
+		}
+	}
+	if !sip.IsOptionSupported(register, sip.OPTION_PATH) {
+		if outbound {
+			response := register.CreateResponse(message.EXTENSION_REQUIRED)
+			sip.AddRequire(response, sip.OPTION_PATH)
+			return response
+		}
+		return nil
+	}
+
+	this.AddFlow(flow)
+	pathAddress, err := this.createFlowAddress(flow, outbound)
+	if err != nil {
+		return register.CreateResponse(message.SERVER_INTERNAL_ERROR)
+	}
+	pathList := header.NewPathList()
+	pathList.PushBack(header.NewPathFromAddress(pathAddress))
+	for e := register.GetHeaders(core.SIPHeaderNames_PATH).Front(); e != nil; e = e.Next() {
+		pathList.PushBack(e.Value.(header.Header))
+	}
+	register.SetHeader(pathList)
+	return nil
+}
+
+/** Pushes a Record-Route with the flow token of flow on top of the others,
+ * for a dialog-forming request received over flow from a User Agent whose
+ * Contact has the ob parameter, so that the requests of the dialog are sent
+ * back over the flow.
+ */
+func (this *EdgeProxy) RecordRoute(request *message.SIPRequest, flow FlowId) (SipException error) {
+	this.AddFlow(flow)
+	recordRouteAddress, err := this.createFlowAddress(flow, false)
+	if err != nil {
+		return err
+	}
+	recordRouteList := header.NewRecordRouteList()
+	recordRouteList.PushBack(header.NewRecordRouteFromAddress(recordRouteAddress))
+	for e := request.GetHeaders(core.SIPHeaderNames_RECORD_ROUTE).Front(); e != nil; e = e.Next() {
+		recordRouteList.PushBack(e.Value.(header.Header))
+	}
+	request.SetHeader(recordRouteList)
+	return nil
+}
+
+/** Processes a request whose topmost Route is the edge proxy. If the Route
+ * carries a flow token it is removed and the flow the request must be sent
+ * over is returned. If the token is not valid the response to send, 403, is
+ * returned, and 430 if the flow no longer exists. Both results are nil if
+ * the topmost Route is not a flow token of the edge proxy.
+ */
+func (this *EdgeProxy) ProcessRoute(request *message.SIPRequest) (flow *FlowId, response *message.SIPResponse) {
+	routes := request.GetHeaders(core.SIPHeaderNames_ROUTE)
+	if routes.Front() == nil {
+		return nil, nil
+	}
+	route, ok := routes.Front().Value.(header.RouteHeader)
+	if !ok {
+		return nil, nil
+	}
+	uri, ok := route.GetAddress().GetURI().(*address.SipURIImpl)
+	if !ok || uri.GetUser() == "" || !this.isLocal(uri) {
+		return nil, nil
+	}
+
+	routeList := header.NewRouteList()
+	for e := routes.Front().Next(); e != nil; e = e.Next() {
+		routeList.PushBack(e.Value.(header.Header))
+	}
+	if routeList.Len() > 0 {
+		request.SetHeader(routeList)
+	} else {
+		request.RemoveHeader(core.SIPHeaderNames_ROUTE)
+	}
+
+	id, err := this.ParseFlowToken(uri.GetUser())
+	if err != nil {
+		return nil, request.CreateResponse(message.FORBIDDEN)
+	}
+	if !this.HasFlow(id) {
+		return nil, request.CreateResponse(message.FLOW_FAILED)
+	}
+	return &id, nil
+}
+
+func (this *EdgeProxy) isLocal(uri *address.SipURIImpl) bool {
+	return isLocalURI(uri, this.host, this.port)
+}
+
+/** Returns true if uri designates host and port, a port of 0 or less
+ * meaning the default port.
+ */
+func isLocalURI(uri *address.SipURIImpl, host string, port int) bool {
+	if !strings.EqualFold(uri.GetHost(), host) {
+		return false
+	}
+	uriPort := uri.GetPort()
+	return uriPort == port || uriPort <= 0 && (port <= 0 || port == header.SIPConstants_DEFAULT_PORT)
+}
+
+/** Processes keep-alive data received over flow and returns the response to
+ * send back over it: a CRLF pong to a double CRLF ping, or a STUN Binding
+ * response reflecting the remote address of the flow. nil is returned for
+ * other data.
+ */
+func (this *EdgeProxy) ProcessKeepAlive(flow FlowId, data []byte) []byte {
+	if string(data) == sip.OUTBOUND_PING {
+		return []byte(sip.OUTBOUND_PONG)
+	}
+	if !stun.IsMessage(data) {
+		return nil
+	}
+	request, err := stun.Parse(data)
+	if err != nil || request.Type != stun.BINDING_REQUEST {
+		return nil
+	}
+	source, err := net.ResolveUDPAddr("udp", flow.Remote)
+	if err != nil {
+		return nil
+	}
+	return stun.NewBindingResponse(request, source).Encode()
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"gosips/core"
+	"gosips/sip"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/stun"
+	"strings"
+	"testing"
+)
+
+var edgeFlow = FlowId{Transport: "TCP", Local: "198.51.100.1:5060", Remote: "203.0.113.7:49152"}
+
+func newTestEdgeProxy() *EdgeProxy {
+	return NewEdgeProxy("edge.example.com", 0, []byte("0123456789abcdefghij"))
+}
+
+func TestFlowToken(t *testing.T) {
+	edge := newTestEdgeProxy()
+	token := edge.CreateFlowToken(edgeFlow)
+	if flow, err := edge.ParseFlowToken(token); err != nil || flow != edgeFlow {
+		t.Fatal("bad flow", flow, err)
+	}
+	if strings.ContainsAny(token, "+/=") {
+		t.Error("flow token not base64url", token)
+	}
+
+	forged := []byte(token)
+	forged[len(forged)-1] ^= 1
+	if _, err := edge.ParseFlowToken(string(forged)); err == nil {
+		t.Error("forged flow token accepted")
+	}
+	other := NewEdgeProxy("edge.example.com", 0, []byte("another key of twenty"))
+	if _, err := other.ParseFlowToken(token); err == nil {
+		t.Error("flow token of another edge proxy accepted")
+	}
+	for _, bad := range []string{"", "!!", "c2hvcnQ"} {
+		if _, err := edge.ParseFlowToken(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestEdgeProxyRegister(t *testing.T) {
+	edge := newTestEdgeProxy()
+	outbound := "Supported: outbound, path\r\nContact: <sip:bob@192.0.2.1;transport=tcp>;reg-id=1;+sip.instance=\"<urn:uuid:00000000-0000-1000-8000-000A95A0E128>\"\r\n"
+
+	// the Path with the flow token, with ob for outbound
+	request := register(t, 1, outbound)
+	if response := edge.ProcessRegister(request, edgeFlow); response != nil {
+		t.Fatal("REGISTER rejected with", response.GetStatusCode())
+	}
+	var path []*address.SipURIImpl
+	for e := request.GetHeaders(core.SIPHeaderNames_PATH).Front(); e != nil; e = e.Next() {
+		path = append(path, e.Value.(header.PathHeader).GetAddress().GetURI().(*address.SipURIImpl))
+	}
+	if len(path) != 1 || path[0].GetHost() != "edge.example.com" ||
+		!path[0].GetUriParms().HasNameValue(header.ParameterNames_OB) || path[0].GetTransportParam() != "tcp" {
+		t.Fatal("bad Path", path)
+	}
+	if flow, err := edge.ParseFlowToken(path[0].GetUser()); err != nil || flow != edgeFlow || !edge.HasFlow(edgeFlow) {
+		t.Error("bad flow token in Path", flow, err)
+	}
+
+	// the registrar accepts the flow of the edge proxy
+	registrar := NewRegistrar(NewLocationService())
+	if response := registrar.ProcessRegister(parseRequest(t, request.String())); response.GetStatusCode() != message.OK ||
+		!sip.IsOptionRequired(response, sip.OPTION_OUTBOUND) {
+		t.Error("outbound registration rejected", response)
+	}
+	// but not the flow of a first hop without outbound
+	response := registrar.ProcessRegister(register(t, 2, "Path: <sip:p1.example.net;lr>\r\n"+outbound))
+	if response.GetStatusCode() != message.FIRST_HOP_LACKS_OUTBOUND_SUPPORT {
+		t.Error("outbound registration through a first hop without outbound accepted with", response.GetStatusCode())
+	}
+
+	// no ob without reg-id, and 421 for outbound without path
+	request = register(t, 3, "Supported: path\r\nContact: <sip:bob@192.0.2.1>\r\n")
+	edge.ProcessRegister(request, edgeFlow)
+	if uri := request.GetHeader(core.SIPHeaderNames_PATH).(header.PathHeader).GetAddress().GetURI().(*address.SipURIImpl); uri.GetUriParms().HasNameValue(header.ParameterNames_OB) {
+		t.Error("ob without outbound", uri)
+	}
+	request = register(t, 4, strings.Replace(outbound, "outbound, path", "outbound", 1))
+	if response := edge.ProcessRegister(request, edgeFlow); response == nil ||
+		response.GetStatusCode() != message.EXTENSION_REQUIRED || !sip.IsOptionRequired(response, sip.OPTION_PATH) {
+		t.Error("outbound without path accepted", response)
+	}
+}
+
+func TestEdgeProxyRoute(t *testing.T) {
+	edge := newTestEdgeProxy()
+	invite := func(route string) *message.SIPRequest {
+		return parseRequest(t, "INVITE sip:bob@192.0.2.1 SIP/2.0\r\n"+
+			"Via: SIP/2.0/UDP 198.51.100.3;branch=z9hG4bKcarol\r\n"+
+			"Route: "+route+"\r\n"+
+			"From: <sip:carol@c.example.com>;tag=carol-tag\r\n"+
+			"To: <sip:bob@example.com>\r\n"+
+			"Call-ID: carol@c.example.com\r\n"+
+			"CSeq: 1 INVITE\r\n"+
+			"Max-Forwards: 70\r\n"+
+			"Content-Length: 0\r\n\r\n")
+	}
+	edge.AddFlow(edgeFlow)
+	route := "<sip:" + edge.CreateFlowToken(edgeFlow) + "@edge.example.com;transport=tcp;lr>"
+
+	request := invite(route)
+	flow, response := edge.ProcessRoute(request)
+	if response != nil || flow == nil || *flow != edgeFlow {
+		t.Fatal("request not routed over the flow", flow, response)
+	}
+	if request.GetHeader(core.SIPHeaderNames_ROUTE) != nil {
+		t.Error("flow token Route not removed")
+	}
+
+	// a Route of another proxy is left alone
+	request = invite("<sip:proxy.example.com;lr>")
+	if flow, response := edge.ProcessRoute(request); flow != nil || response != nil || request.GetHeader(core.SIPHeaderNames_ROUTE) == nil {
+		t.Error("foreign Route processed")
+	}
+
+	forged := strings.Replace(route, "<sip:", "<sip:x", 1)
+	if _, response := edge.ProcessRoute(invite(forged)); response == nil || response.GetStatusCode() != message.FORBIDDEN {
+		t.Error("forged flow token not rejected with 403", response)
+	}
+	edge.RemoveFlow(edgeFlow)
+	if _, response := edge.ProcessRoute(invite(route)); response == nil || response.GetStatusCode() != message.FLOW_FAILED {
+		t.Error("closed flow not rejected with 430", response)
+	}
+
+	// Record-Route with the flow token of a dialog-forming request
+	request = invite("<sip:proxy.example.com;lr>")
+	if err := edge.RecordRoute(request, edgeFlow); err != nil {
+		t.Fatal(err)
+	}
+	recordRoute := request.GetHeader(core.SIPHeaderNames_RECORD_ROUTE).(header.RecordRouteHeader)
+	if flow, err := edge.ParseFlowToken(recordRoute.GetAddress().GetURI().(*address.SipURIImpl).GetUser()); err != nil ||
+		flow != edgeFlow || !edge.HasFlow(edgeFlow) {
+		t.Error("bad Record-Route", recordRoute, err)
+	}
+}
+
+func TestEdgeProxyKeepAlive(t *testing.T) {
+	edge := newTestEdgeProxy()
+	if pong := edge.ProcessKeepAlive(edgeFlow, []byte(sip.OUTBOUND_PING)); string(pong) != sip.OUTBOUND_PONG {
+		t.Errorf("bad pong %q", pong)
+	}
+	udp := FlowId{Transport: "UDP", Local: "198.51.100.1:5060", Remote: "203.0.113.7:5062"}
+	request := stun.NewBindingRequest()
+	response, err := stun.Parse(edge.ProcessKeepAlive(udp, request.Encode()))
+	if err != nil || !response.IsSuccessResponse() || response.TransactionId != request.TransactionId ||
+		response.GetMappedAddress().String() != "203.0.113.7:5062" {
+		t.Error("bad STUN response", response, err)
+	}
+	if data := edge.ProcessKeepAlive(udp, []byte("\r\n")); data != nil {
+		t.Errorf("answered %q", data)
+	}
+}
@@ -0,0 +1,244 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : History.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package proxy
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+/** Returns the History-Info entries of a request in order.
+ */
+func GetHistoryInfo(request message.Message) []*header.HistoryInfo {
+	var entries []*header.HistoryInfo
+	for e := request.GetHeaders(core.SIPHeaderNames_HISTORY_INFO).Front(); e != nil; e = e.Next() {
+		if entry, ok := e.Value.(*header.HistoryInfo); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+/** Returns the Reason header embedded in the URI of a History-Info entry, or
+ * nil if there is none: the cause of the retargeting of the request from the
+ * entry.
+ */
+func GetHistoryReason(entry header.HistoryInfoHeader) (header.ReasonHeader, error) {
+	uri, ok := entry.GetAddress().GetURI().(*address.SipURIImpl)
+	if !ok {
+		return nil, nil
+	}
+	value := uri.GetHeader(core.SIPHeaderNames_REASON)
+	if value == "" {
+		return nil, nil
+	}
+	value, err := url.PathUnescape(value)
+	if err != nil {
+		return nil, errors.New("ParseException: bad Reason escaping in History-Info")
+	}
+	h, err := parser.NewReasonParser(core.SIPHeaderNames_REASON + ": " + value + "\n").Parse()
+	if err != nil {
+		return nil, err
+	}
+	if reasonList, ok := h.(*header.ReasonList); ok {
+		h = reasonList.Front().Value.(header.Header)
+	}
+	return h.(header.ReasonHeader), nil
+}
+
+/** Returns a Reason header for a SIP response status code, e.g.
+ * <code>SIP;cause=302;text="Moved Temporarily"</code>.
+ */
+func NewStatusReason(statusCode int) header.ReasonHeader {
+	reason := header.NewReason()
+	reason.SetProtocol("SIP")
+	reason.SetCause(statusCode)
+	if text := message.NewSIPResponse().GetReasonPhraseFromInt(statusCode); text != "" {
+		reason.SetQuotedParameter(header.ParameterNames_TEXT, text)
+	}
+	return reason
+}
+
+/** Retargets request to target, recording it in the History-Info header as
+ * described in RFC 7044 section 10.3. If the request has no entry for its
+ * Request-URI yet, one with index 1 is added first. The new entry is a child
+ * of the entry of the Request-URI, and tag, header.ParameterNames_RC or
+ * header.ParameterNames_MP, refers to it; an empty tag adds no reference. A
+ * statusCode other than 0, e.g. the 302 or 486 that caused the retargeting,
+ * is embedded as Reason in the entry of the Request-URI.
+ */
+func RetargetWithHistory(request *message.SIPRequest, target address.URI, tag string, statusCode int) (SipException error) {
+	entries := GetHistoryInfo(request)
+	parent := findHistoryEntry(entries, request.GetRequestURI())
+	if parent == nil {
+		uriAddress, err := newURIAddress(request.GetRequestURI())
+		if err != nil {
+			return err
+		}
+		parent = header.NewHistoryInfoFromAddress(uriAddress)
+		index := "1"
+		if len(entries) > 0 {
+			index = nextHistoryIndex(entries, "")
+		}
+		parent.SetIndex(index)
+		entries = append(entries, parent)
+	}
+	parentIndex := parent.GetIndex()
+	if parentIndex == "" {
+		return errors.New("SipException: History-Info entry without index")
+	}
+	if statusCode != 0 {
+		if err := parent.SetReason(NewStatusReason(statusCode)); err != nil {
+			return err
+		}
+	}
+
+	targetAddress, err := newURIAddress(target)
+	if err != nil {
+		return err
+	}
+	entry := header.NewHistoryInfoFromAddress(targetAddress)
+	if err = entry.SetIndex(nextHistoryIndex(entries, parentIndex)); err != nil {
+		return err
+	}
+	if tag != "" {
+		if err = entry.SetTargetTag(tag, parentIndex); err != nil {
+			return err
+		}
+	}
+	entries = append(entries, entry)
+
+	historyList := header.NewHistoryInfoList()
+	for _, e := range entries {
+		historyList.PushBack(e)
+	}
+	request.SetHeader(historyList)
+	request.SetRequestURI(target)
+	return nil
+}
+
+/** Returns the last entry whose URI, without its headers, is uri.
+ */
+func findHistoryEntry(entries []*header.HistoryInfo, uri address.URI) *header.HistoryInfo {
+	target := stripURIHeaders(uri.String())
+	for i := len(entries) - 1; i >= 0; i-- {
+		if stripURIHeaders(entries[i].GetAddress().GetURI().String()) == target {
+			return entries[i]
+		}
+	}
+	return nil
+}
+
+func stripURIHeaders(uri string) string {
+	if i := strings.Index(uri, "?"); i >= 0 {
+		return uri[:i]
+	}
+	return uri
+}
+
+/** Returns the index of the next child of parent, or of the next top level
+ * entry if parent is empty.
+ */
+func nextHistoryIndex(entries []*header.HistoryInfo, parent string) string {
+	prefix := parent
+	if prefix != "" {
+		prefix += "."
+	}
+	last := 0
+	for _, e := range entries {
+		index := e.GetIndex()
+		if !strings.HasPrefix(index, prefix) {
+			continue
+		}
+		child := strings.SplitN(index[len(prefix):], ".", 2)[0]
+		if n, err := strconv.Atoi(child); err == nil && n > last {
+			last = n
+		}
+	}
+	return prefix + strconv.Itoa(last+1)
+}
+
+/** Returns a new address for a copy of uri.
+ */
+func newURIAddress(uri address.URI) (address.Address, error) {
+	return parser.NewAddressParser("<" + uri.String() + ">").Address()
+}
+
+/** Returns the number of diversions recorded in the Diversion headers of
+ * request.
+ */
+func GetDiversionCount(request message.Message) int {
+	count := 0
+	for e := request.GetHeaders(core.SIPHeaderNames_DIVERSION).Front(); e != nil; e = e.Next() {
+		if diversion, ok := e.Value.(header.DiversionHeader); ok {
+			count += diversion.GetCounter()
+		}
+	}
+	return count
+}
+
+/** Returns the Diversion reason for the status code that caused a call to be
+ * diverted, header.Diversion_UNCONDITIONAL for 0.
+ */
+func GetDiversionReason(statusCode int) string {
+	switch statusCode {
+	case 0, message.MOVED_TEMPORARILY, message.MOVED_PERMANENTLY:
+		return header.Diversion_UNCONDITIONAL
+	case message.BUSY_HERE, message.BUSY_EVERYWHERE:
+		return header.Diversion_USER_BUSY
+	case message.REQUEST_TIMEOUT, message.TEMPORARILY_UNAVAILABLE:
+		return header.Diversion_NO_ANSWER
+	case message.SERVICE_UNAVAILABLE:
+		return header.Diversion_UNAVAILABLE
+	case message.DECLINE:
+		return header.Diversion_DEFLECTION
+	}
+	return header.Diversion_UNKNOWN
+}
+
+/** Records the diversion of request away from its Request-URI in a Diversion
+ * header placed on top of the others (RFC 5806), the reason being derived
+ * from statusCode. An error is returned if a limit of the existing
+ * diversions is reached, in which case the call must not be diverted.
+ * <p>
+ * It is called before the Request-URI is changed, e.g. before
+ * RetargetWithHistory.
+ */
+func AddDiversion(request *message.SIPRequest, statusCode int) (SipException error) {
+	count := GetDiversionCount(request)
+	for e := request.GetHeaders(core.SIPHeaderNames_DIVERSION).Front(); e != nil; e = e.Next() {
+		if diversion, ok := e.Value.(header.DiversionHeader); ok {
+			if limit := diversion.GetLimit(); limit >= 0 && count >= limit {
+				return errors.New("SipException: diversion limit reached")
+			}
+		}
+	}
+
+	uriAddress, err := newURIAddress(request.GetRequestURI())
+	if err != nil {
+		return err
+	}
+	diversion := header.NewDiversionFromAddress(uriAddress)
+	diversion.SetReason(GetDiversionReason(statusCode))
+	diversion.SetCounter(1)
+
+	diversionList := header.NewDiversionList()
+	diversionList.PushBack(diversion)
+	for e := request.GetHeaders(core.SIPHeaderNames_DIVERSION).Front(); e != nil; e = e.Next() {
+		diversionList.PushBack(e.Value.(header.Header))
+	}
+	request.SetHeader(diversionList)
+	return nil
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strings"
+	"testing"
+)
+
+func historyRequest(t *testing.T, headers string) *message.SIPRequest {
+	return parseRequest(t, "INVITE sip:bob@example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bKalice\r\n"+
+		"From: <sip:alice@a.example.com>;tag=alice-tag\r\n"+
+		"To: <sip:bob@example.com>\r\n"+
+		"Call-ID: history@192.0.2.1\r\n"+
+		"CSeq: 1 INVITE\r\n"+
+		"Max-Forwards: 70\r\n"+headers+
+		"Content-Length: 0\r\n\r\n")
+}
+
+func parseURI(t *testing.T, s string) address.URI {
+	return parseAddress(t, "<"+s+">").GetURI()
+}
+
+func TestRetargetWithHistory(t *testing.T) {
+	request := historyRequest(t, "")
+	// bob is busy at his office and forwards to his voicemail
+	if err := RetargetWithHistory(request, parseURI(t, "sip:office@example.com"), header.ParameterNames_MP, message.MOVED_TEMPORARILY); err != nil {
+		t.Fatal(err)
+	}
+	if err := RetargetWithHistory(request, parseURI(t, "sip:office@192.0.2.7:5070"), header.ParameterNames_RC, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := RetargetWithHistory(request, parseURI(t, "sip:vm@example.com"), header.ParameterNames_MP, message.BUSY_HERE); err != nil {
+		t.Fatal(err)
+	}
+	if request.GetRequestURI().String() != "sip:vm@example.com" {
+		t.Error("request not retargeted", request.GetRequestURI())
+	}
+	if s := request.String(); strings.Contains(s, "+") || !strings.Contains(s, "Moved%20Temporarily") {
+		t.Error("bad Reason escaping", s)
+	}
+
+	entries := GetHistoryInfo(parseRequest(t, request.String()))
+	tests := []struct {
+		uri   string
+		index string
+		tag   string
+		cause int
+	}{
+		{"sip:bob@example.com", "1", "", message.MOVED_TEMPORARILY},
+		{"sip:office@example.com", "1.1", "mp=1", 0},
+		{"sip:office@192.0.2.7:5070", "1.1.1", "rc=1.1", message.BUSY_HERE},
+		{"sip:vm@example.com", "1.1.1.1", "mp=1.1.1", 0},
+	}
+	if len(entries) != len(tests) {
+		t.Fatal("bad History-Info", entries)
+	}
+	for i, test := range tests {
+		entry := entries[i]
+		if uri := stripURIHeaders(entry.GetAddress().GetURI().String()); uri != test.uri || entry.GetIndex() != test.index {
+			t.Errorf("entry %d: %s;index=%s", i, uri, entry.GetIndex())
+		}
+		if tag, index := entry.GetTargetTag(); test.tag != "" && tag+"="+index != test.tag || test.tag == "" && tag != "" {
+			t.Errorf("entry %d: bad tag %s=%s", i, tag, index)
+		}
+		reason, err := GetHistoryReason(entry)
+		if err != nil {
+			t.Errorf("entry %d: %v", i, err)
+		} else if test.cause == 0 && reason != nil || test.cause != 0 && (reason == nil || reason.GetCause() != test.cause) {
+			t.Errorf("entry %d: bad Reason %v", i, reason)
+		}
+	}
+	if reason, _ := GetHistoryReason(entries[0]); reason.GetProtocol() != "SIP" || !strings.Contains(reason.GetText(), "Moved Temporarily") {
+		t.Error("bad Reason text", reason.GetText())
+	}
+
+	// a "+" received in the Reason stands for itself
+	entry := GetHistoryInfo(historyRequest(t, "History-Info: <sip:bob@example.com?Reason=SIP%3Bcause%3D480%3Btext%3D%22a+b%22>;index=1\r\n"))[0]
+	if reason, err := GetHistoryReason(entry); err != nil || reason.GetText() != "\"a+b\"" {
+		t.Error("bad Reason text", reason, err)
+	}
+}
+
+func TestAddDiversion(t *testing.T) {
+	request := historyRequest(t, "")
+	if err := AddDiversion(request, message.MOVED_TEMPORARILY); err != nil {
+		t.Fatal(err)
+	}
+	request.SetRequestURI(parseURI(t, "sip:office@example.com"))
+	if err := AddDiversion(request, message.BUSY_HERE); err != nil {
+		t.Fatal(err)
+	}
+
+	request = parseRequest(t, request.String())
+	var diversions []string
+	for e := request.GetHeaders(core.SIPHeaderNames_DIVERSION).Front(); e != nil; e = e.Next() {
+		diversion := e.Value.(header.DiversionHeader)
+		diversions = append(diversions, diversion.GetAddress().GetURI().String()+" "+diversion.GetReason())
+	}
+	// the last diversion comes first
+	if strings.Join(diversions, ", ") != "sip:office@example.com user-busy, sip:bob@example.com unconditional" {
+		t.Error("bad Diversion", diversions)
+	}
+	if count := GetDiversionCount(request); count != 2 {
+		t.Error("bad diversion count", count)
+	}
+
+	request.GetHeader(core.SIPHeaderNames_DIVERSION).(header.DiversionHeader).SetLimit(2)
+	if err := AddDiversion(request, 0); err == nil {
+		t.Error("diversion limit ignored")
+	}
+
+	for statusCode, reason := range map[int]string{
+		0:                               header.Diversion_UNCONDITIONAL,
+		message.BUSY_EVERYWHERE:         header.Diversion_USER_BUSY,
+		message.REQUEST_TIMEOUT:         header.Diversion_NO_ANSWER,
+		message.TEMPORARILY_UNAVAILABLE: header.Diversion_NO_ANSWER,
+		message.SERVICE_UNAVAILABLE:     header.Diversion_UNAVAILABLE,
+		message.DECLINE:                 header.Diversion_DEFLECTION,
+		message.FORBIDDEN:               header.Diversion_UNKNOWN,
+	} {
+		if r := GetDiversionReason(statusCode); r != reason {
+			t.Errorf("%d: expected %s, got %s", statusCode, reason, r)
+		}
+	}
+}
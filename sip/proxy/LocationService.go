@@ -0,0 +1,403 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : LocationService.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package proxy
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/parser"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * A binding of an address-of-record to a contact address, as created by a
+ * REGISTER (RFC 3261 section 10).
+ */
+type Binding struct {
+	addressOfRecord string
+	contact         *header.Contact
+	callId          string
+	cseq            int
+	expiresAt       time.Time
+	path            []*header.Path
+	instanceId      string
+	regId           int
+}
+
+/** Returns the canonical address-of-record of the binding.
+ */
+func (this *Binding) GetAddressOfRecord() string {
+	return this.addressOfRecord
+}
+
+/** Returns the Contact of the binding, with its parameters except expires.
+ */
+func (this *Binding) GetContact() header.ContactHeader {
+	return this.contact
+}
+
+/** Returns the contact address requests for the address-of-record are sent
+ * to.
+ */
+func (this *Binding) GetContactURI() address.URI {
+	return this.contact.GetAddress().GetURI()
+}
+
+/** Returns the Call-ID of the REGISTER that last updated the binding.
+ */
+func (this *Binding) GetCallId() string {
+	return this.callId
+}
+
+/** Returns the CSeq number of the REGISTER that last updated the binding.
+ */
+func (this *Binding) GetCSeq() int {
+	return this.cseq
+}
+
+/** Returns the q-value of the contact, 1 if it has none.
+ */
+func (this *Binding) GetQValue() float32 {
+	if !this.contact.HasQValue() {
+		return 1
+	}
+	return this.contact.GetQValue()
+}
+
+/** Returns the number of seconds left before the binding expires.
+ */
+func (this *Binding) GetExpires() int {
+	left := time.Until(this.expiresAt)
+	if left <= 0 {
+		return 0
+	}
+	return int((left + time.Second/2) / time.Second)
+}
+
+/** Returns the Path vector of the REGISTER (RFC 3327), topmost first: the
+ * route set of requests sent to the contact.
+ */
+func (this *Binding) GetPath() []*header.Path {
+	return this.path
+}
+
+/** Returns the instance id of the User Agent that registered the binding
+ * (RFC 5626), or an empty string.
+ */
+func (this *Binding) GetInstanceId() string {
+	return this.instanceId
+}
+
+/** Returns the reg-id of the flow the binding was registered over, 0 if it
+ * was not registered with outbound.
+ */
+func (this *Binding) GetRegId() int {
+	return this.regId
+}
+
+/** Returns the public GRUU of the instance that registered the binding (RFC
+ * 5627): its address-of-record with the instance id as gr parameter, or nil
+ * if the binding has no instance id.
+ */
+func (this *Binding) GetPublicGruu() address.URI {
+	if this.instanceId == "" {
+		return nil
+	}
+	uri, err := parser.NewURLParser(this.addressOfRecord).UriReference()
+	if err != nil {
+		return nil
+	}
+	sipURI, ok := uri.(*address.SipURIImpl)
+	if !ok {
+		return nil
+	}
+	sipURI.SetGrParam(this.instanceId)
+	return sipURI
+}
+
+/** Returns what identifies the binding among those of its address-of-record:
+ * the instance id and reg-id of an outbound registration, the contact URI
+ * otherwise.
+ */
+func (this *Binding) key() string {
+	if this.instanceId != "" && this.regId > 0 {
+		return this.instanceId + "|" + strconv.Itoa(this.regId)
+	}
+	return this.GetContactURI().String()
+}
+
+/** The prefix of the user part of temporary GRUUs.
+ */
+const tempGruuPrefix = "tgruu."
+
+/**
+ * The location service of a registrar: the bindings of each
+ * address-of-record. Expired bindings are dropped as they are looked up.
+ * <p>
+ * The location service also resolves the GRUUs of the instances registered
+ * with it (RFC 5627). Temporary GRUUs carry their address-of-record,
+ * instance id and the Call-ID of the registration encrypted with a key of
+ * the location service, so that they need no storage and do not reveal the
+ * address-of-record. They remain valid as long as the instance stays
+ * registered with the same Call-ID.
+ */
+type LocationService struct {
+	mutex    sync.Mutex
+	bindings map[string][]*Binding
+	gruuKey  cipher.AEAD
+}
+
+/** Creates an empty location service.
+ */
+func NewLocationService() *LocationService {
+	key := make([]byte, 16)
+	rand.Read(key)
+	block, _ := aes.NewCipher(key)
+	gruuKey, _ := cipher.NewGCM(block)
+	return &LocationService{bindings: make(map[string][]*Binding), gruuKey: gruuKey}
+}
+
+/** Returns the current bindings of the address-of-record uri, highest q-value
+ * first. For a GRUU, only the bindings of its instance are returned, none if
+ * the GRUU is not valid.
+ */
+func (this *LocationService) Lookup(uri address.URI) []*Binding {
+	if !IsGruu(uri) {
+		return this.GetBindings(GetAddressOfRecord(uri))
+	}
+	addressOfRecord, instanceId, callId := this.resolveGruu(uri.(*address.SipURIImpl))
+	var bindings []*Binding
+	for _, b := range this.GetBindings(addressOfRecord) {
+		if instanceId != "" && strings.EqualFold(b.instanceId, instanceId) {
+			bindings = append(bindings, b)
+		}
+	}
+	if callId != "" {
+		for _, b := range bindings {
+			if b.callId == callId {
+				return bindings
+			}
+		}
+		return nil
+	}
+	return bindings
+}
+
+/** Creates a new temporary GRUU for the instance that registered binding,
+ * or returns nil if it has no instance id.
+ */
+func (this *LocationService) CreateTemporaryGruu(binding *Binding) address.URI {
+	uri, ok := binding.GetPublicGruu().(*address.SipURIImpl)
+	if !ok {
+		return nil
+	}
+	nonce := make([]byte, this.gruuKey.NonceSize())
+	rand.Read(nonce)
+	plaintext := []byte(binding.addressOfRecord + " " + binding.instanceId + " " + binding.callId)
+	token := this.gruuKey.Seal(nonce, nonce, plaintext, nil)
+	uri.SetUser(tempGruuPrefix + base64.RawURLEncoding.EncodeToString(token))
+	uri.SetGrParam("")
+	return uri
+}
+
+/** Returns the address-of-record and instance id of a GRUU, and for a
+ * temporary GRUU the Call-ID of the registration it was created for. Empty
+ * strings are returned for a temporary GRUU that was not created by the
+ * location service.
+ */
+func (this *LocationService) resolveGruu(uri *address.SipURIImpl) (addressOfRecord, instanceId, callId string) {
+	if uri.GetGrParam() != "" {
+		return GetAddressOfRecord(uri), uri.GetGrParam(), ""
+	}
+	if !strings.HasPrefix(uri.GetUser(), tempGruuPrefix) {
+		return "", "", ""
+	}
+	token, err := base64.RawURLEncoding.DecodeString(uri.GetUser()[len(tempGruuPrefix):])
+	nonceSize := this.gruuKey.NonceSize()
+	if err != nil || len(token) < nonceSize {
+		return "", "", ""
+	}
+	plaintext, err := this.gruuKey.Open(nil, token[:nonceSize], token[nonceSize:], nil)
+	if err != nil {
+		return "", "", ""
+	}
+	fields := strings.SplitN(string(plaintext), " ", 3)
+	if len(fields) != 3 {
+		return "", "", ""
+	}
+	return fields[0], fields[1], fields[2]
+}
+
+/** Returns the current bindings of a canonical address-of-record, highest
+ * q-value first.
+ */
+func (this *LocationService) GetBindings(addressOfRecord string) []*Binding {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	bindings := append([]*Binding(nil), this.getBindings(addressOfRecord)...)
+	sort.SliceStable(bindings, func(i, j int) bool {
+		return bindings[i].GetQValue() > bindings[j].GetQValue()
+	})
+	return bindings
+}
+
+/** Adds or replaces the binding to the same contact URI, or with the same
+ * instance id and reg-id.
+ */
+func (this *LocationService) Put(binding *Binding) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.put(binding)
+}
+
+/** Removes a binding.
+ */
+func (this *LocationService) Remove(binding *Binding) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	bindings := this.getBindings(binding.addressOfRecord)
+	for i, b := range bindings {
+		if b == binding {
+			this.setBindings(binding.addressOfRecord, append(bindings[:i], bindings[i+1:]...))
+			return
+		}
+	}
+}
+
+/** Applies a REGISTER of addressOfRecord with callId and cseq: bindings
+ * replace those with the same key, the bindings with a key in removed are
+ * removed, and all bindings if removeAll is true. The REGISTER is applied
+ * under one lock, entirely or not at all: nothing changes and false is
+ * returned if one of the bindings it affects was last updated by the same or
+ * a later REGISTER of callId.
+ */
+func (this *LocationService) update(addressOfRecord string, callId string, cseq int, bindings []*Binding, removed []string, removeAll bool) bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	affected := make(map[string]bool)
+	for _, b := range bindings {
+		affected[b.key()] = true
+	}
+	for _, key := range removed {
+		affected[key] = true
+	}
+	current := this.getBindings(addressOfRecord)
+	for _, b := range current {
+		if (removeAll || affected[b.key()]) && b.callId == callId && cseq <= b.cseq {
+			return false
+		}
+	}
+
+	if removeAll {
+		this.setBindings(addressOfRecord, nil)
+		return true
+	}
+	kept := current[:0]
+	for _, b := range current {
+		if !contains(removed, b.key()) {
+			kept = append(kept, b)
+		}
+	}
+	this.setBindings(addressOfRecord, kept)
+	for _, b := range bindings {
+		this.put(b)
+	}
+	return true
+}
+
+func (this *LocationService) put(binding *Binding) {
+	bindings := this.getBindings(binding.addressOfRecord)
+	for i, b := range bindings {
+		if sameContact(b, binding) {
+			bindings[i] = binding
+			return
+		}
+	}
+	this.bindings[binding.addressOfRecord] = append(bindings, binding)
+}
+
+func (this *LocationService) getBindings(addressOfRecord string) []*Binding {
+	bindings := this.bindings[addressOfRecord]
+	now := time.Now()
+	current := bindings[:0]
+	for _, b := range bindings {
+		if b.expiresAt.After(now) {
+			current = append(current, b)
+		}
+	}
+	this.setBindings(addressOfRecord, current)
+	return current
+}
+
+func (this *LocationService) setBindings(addressOfRecord string, bindings []*Binding) {
+	if len(bindings) == 0 {
+		delete(this.bindings, addressOfRecord)
+	} else {
+		this.bindings[addressOfRecord] = bindings
+	}
+}
+
+func sameContact(a, b *Binding) bool {
+	return a.key() == b.key()
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+/** Returns true if uri is a GRUU (RFC 5627): a SIP URI with the gr parameter.
+ */
+func IsGruu(uri address.URI) bool {
+	sipURI, ok := uri.(*address.SipURIImpl)
+	return ok && sipURI.HasGrParam()
+}
+
+/** Returns the canonical form of an address-of-record: the scheme, user and
+ * lower case host of a SIP URI, and the URI itself otherwise.
+ */
+func GetAddressOfRecord(uri address.URI) string {
+	if sipURI, ok := uri.(*address.SipURIImpl); ok {
+		s := sipURI.GetScheme() + ":"
+		if sipURI.GetUser() != "" {
+			s += sipURI.GetUser() + "@"
+		}
+		return s + strings.ToLower(sipURI.GetHost())
+	}
+	return uri.String()
+}
+
+/** Returns a copy of contact without its expires and GRUU parameters.
+ */
+func copyContact(contact header.ContactHeader) *header.Contact {
+	c := header.NewContact()
+	c.SetAddress(contact.GetAddress())
+	if parameters := contact.GetParameters(); parameters != nil {
+		for e := parameters.Front(); e != nil; e = e.Next() {
+			if nv := e.Value.(*core.NameValue); !strings.EqualFold(nv.GetName(), header.ParameterNames_EXPIRES) &&
+				!strings.EqualFold(nv.GetName(), header.ParameterNames_PUB_GRUU) &&
+				!strings.EqualFold(nv.GetName(), header.ParameterNames_TEMP_GRUU) {
+				c.GetParameters().AddNameValue(nv.Clone().(*core.NameValue))
+			}
+		}
+	}
+	return c
+}
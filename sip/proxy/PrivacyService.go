@@ -0,0 +1,218 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : PrivacyService.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package proxy
+
+import (
+	"errors"
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strings"
+	"sync"
+)
+
+/**
+ * The anonymous From address of RFC 3323 section 4.1.1.1.
+ */
+const ANONYMOUS_FROM = "\"Anonymous\" <sip:anonymous@anonymous.invalid>"
+
+/**
+ * Headers that may identify the user and are removed by header privacy,
+ * besides From, Contact and Via.
+ */
+var privacyHeaders = []string{
+	core.SIPHeaderNames_CALL_INFO,
+	core.SIPHeaderNames_REPLY_TO,
+	core.SIPHeaderNames_IN_REPLY_TO,
+	core.SIPHeaderNames_ORGANIZATION,
+	core.SIPHeaderNames_SUBJECT,
+	core.SIPHeaderNames_USER_AGENT,
+}
+
+/**
+ * What a PrivacyService changed in a request, needed to restore the
+ * responses.
+ */
+type PrivacyContext struct {
+	vias    header.SIPHeaderLister
+	contact header.ContactHeader
+}
+
+/** Returns the Contact of the request before it was replaced, or nil if it
+ * was not.
+ */
+func (this *PrivacyContext) GetOriginalContact() header.ContactHeader {
+	return this.contact
+}
+
+/** Puts the Via headers removed from the request back into a response to it.
+ * The proxy calls it once it has removed its own Via from the response.
+ */
+func (this *PrivacyContext) RestoreVias(response *message.SIPResponse) {
+	if this.vias != nil {
+		response.SetHeader(this.vias)
+	}
+}
+
+/**
+ * A privacy service at the boundary of a trust domain (RFC 3323 section 5
+ * and RFC 3325). Before a request leaves the proxy it applies the privacy
+ * the user asked for in the Privacy header:
+ * <ul>
+ * <li> header and user - From is anonymized, Contact is replaced by the
+ * contact of the service and the headers that identify the user, e.g.
+ * Call-Info, are removed. With header, the Via headers are removed as well and
+ * kept to be restored in the responses.
+ * <li> id - P-Asserted-Identity is removed when the request leaves the
+ * trust domain; trusted hosts still receive it (RFC 3325 section 9.3).
+ * </ul>
+ * P-Asserted-Identity and P-Preferred-Identity never leave the trust domain,
+ * whatever the privacy, and a P-Asserted-Identity received from outside it
+ * is dropped. Toward a trusted host the request is left unchanged.
+ */
+type PrivacyService struct {
+	mutex sync.Mutex
+
+	trustedHosts map[string]bool
+	contact      address.Address
+}
+
+/** Creates a privacy service trusting no host.
+ */
+func NewPrivacyService() *PrivacyService {
+	return &PrivacyService{trustedHosts: make(map[string]bool)}
+}
+
+/** Adds a host, e.g. a proxy or gateway, to the trust domain.
+ */
+func (this *PrivacyService) AddTrustedHost(host string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.trustedHosts[strings.ToLower(host)] = true
+}
+
+/** Returns true if host is in the trust domain.
+ */
+func (this *PrivacyService) IsTrusted(host string) bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.trustedHosts[strings.ToLower(host)]
+}
+
+/** Sets the address the Contact of a request asking for header privacy is
+ * replaced with; the service must then stay on the path of the dialog, e.g.
+ * with Record-Route, to map requests back to the original contact. Without
+ * one the Contact is left unchanged.
+ */
+func (this *PrivacyService) SetContact(contact address.Address) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.contact = contact
+}
+
+/** Processes a request received from host: the identity asserted by a host
+ * outside the trust domain is not trusted and removed.
+ */
+func (this *PrivacyService) ProcessIncoming(request *message.SIPRequest, host string) {
+	if !this.IsTrusted(host) {
+		request.RemoveHeader(core.SIPHeaderNames_P_ASSERTED_IDENTITY)
+	}
+}
+
+/** Asserts identity for the user that sent request, replacing its
+ * P-Preferred-Identity, once the proxy has authenticated the user.
+ */
+func AssertIdentity(request *message.SIPRequest, identities ...address.Address) {
+	request.RemoveHeader(core.SIPHeaderNames_P_PREFERRED_IDENTITY)
+	identityList := header.NewPAssertedIdentityList()
+	for _, identity := range identities {
+		identityList.PushBack(header.NewPAssertedIdentityFromAddress(identity))
+	}
+	request.SetHeader(identityList)
+}
+
+/** Applies the privacy asked for by request before it is forwarded to
+ * nextHop. An error is returned if the request asks for critical privacy the
+ * service cannot provide, in which case it must be rejected with 500.
+ */
+func (this *PrivacyService) ApplyPrivacy(request *message.SIPRequest, nextHop string) (context *PrivacyContext, SipException error) {
+	var values []string
+	if privacy, ok := request.GetHeader(core.SIPHeaderNames_PRIVACY).(header.PrivacyHeader); ok {
+		values = privacy.GetPrivacy()
+	}
+	trusted := this.IsTrusted(nextHop)
+	this.mutex.Lock()
+	contact := this.contact
+	this.mutex.Unlock()
+
+	headerPrivacy, userPrivacy, critical := false, false, false
+	for _, v := range values {
+		switch strings.ToLower(v) {
+		case header.Privacy_HEADER:
+			headerPrivacy = true
+		case header.Privacy_USER:
+			userPrivacy = true
+		case header.Privacy_CRITICAL:
+			critical = true
+		case header.Privacy_ID, header.Privacy_NONE:
+			// the asserted identity is removed below for untrusted hosts
+		default:
+			if hasValue(values, header.Privacy_CRITICAL) {
+				return nil, errors.New("SipException: privacy " + v + " is not available")
+			}
+		}
+	}
+	if critical && headerPrivacy && contact == nil {
+		return nil, errors.New("SipException: the Contact cannot be anonymized")
+	}
+
+	context = &PrivacyContext{}
+	if trusted {
+		return context, nil
+	}
+	request.RemoveHeader(core.SIPHeaderNames_P_ASSERTED_IDENTITY)
+	request.RemoveHeader(core.SIPHeaderNames_P_PREFERRED_IDENTITY)
+	if !headerPrivacy && !userPrivacy {
+		return context, nil
+	}
+
+	anonymous, err := parser.NewAddressParser(ANONYMOUS_FROM).Address()
+	if err != nil {
+		return nil, err
+	}
+	if from, ok := request.GetFrom().(*header.From); ok {
+		from.SetAddress(anonymous)
+	}
+	for _, name := range privacyHeaders {
+		request.RemoveHeader(name)
+	}
+	if contactHeader, ok := request.GetHeader(core.SIPHeaderNames_CONTACT).(header.ContactHeader); ok && contact != nil {
+		context.contact = contactHeader
+		anonymousContact := header.NewContact()
+		anonymousContact.SetAddress(contact)
+		request.SetHeader(anonymousContact)
+	}
+	if headerPrivacy {
+		if vias, ok := request.GetHeaderList(core.SIPHeaderNames_VIA).(header.SIPHeaderLister); ok {
+			context.vias = vias
+		}
+		request.RemoveHeader(core.SIPHeaderNames_VIA)
+	}
+	return context, nil
+}
+
+func hasValue(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
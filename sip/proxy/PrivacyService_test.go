@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"testing"
+)
+
+// privacyRequest returns an INVITE of alice received by the privacy service
+// from the proxy of her domain.
+func privacyRequest(t *testing.T, privacy string) *message.SIPRequest {
+	return parseRequest(t, "INVITE sip:bob@b.example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP proxy.a.example.com;branch=z9hG4bKproxy\r\n"+
+		"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bKalice\r\n"+
+		"From: \"Alice\" <sip:alice@a.example.com>;tag=alice-tag\r\n"+
+		"To: <sip:bob@b.example.com>\r\n"+
+		"Call-ID: privacy@192.0.2.1\r\n"+
+		"CSeq: 1 INVITE\r\n"+
+		"Contact: <sip:alice@192.0.2.1>\r\n"+
+		"Call-Info: <http://a.example.com/alice.png>;purpose=icon\r\n"+
+		"P-Asserted-Identity: <sip:alice@a.example.com>\r\n"+
+		"Privacy: "+privacy+"\r\n"+
+		"Max-Forwards: 70\r\n"+
+		"Content-Length: 0\r\n\r\n")
+}
+
+func countVias(msg message.Message) int {
+	n := 0
+	for e := msg.GetHeaders(core.SIPHeaderNames_VIA).Front(); e != nil; e = e.Next() {
+		n++
+	}
+	return n
+}
+
+func TestApplyPrivacy(t *testing.T) {
+	service := NewPrivacyService()
+	service.AddTrustedHost("GW.a.example.com")
+	service.SetContact(parseAddress(t, "<sip:anonymous@privacy.a.example.com>"))
+
+	tests := []struct {
+		privacy string
+		nextHop string
+		// what is left of the request
+		identity bool
+		from     bool
+		contact  bool
+		callInfo bool
+		vias     int
+		restored bool
+	}{
+		{"none", "gw.a.example.com", true, true, true, true, 2, false},
+		{"none", "b.example.com", false, true, true, true, 2, false},
+		{"id", "gw.a.example.com", true, true, true, true, 2, false},
+		{"id", "b.example.com", false, true, true, true, 2, false},
+		{"header", "gw.a.example.com", true, true, true, true, 2, false},
+		{"header", "b.example.com", false, false, false, false, 0, true},
+		{"user", "gw.a.example.com", true, true, true, true, 2, false},
+		{"user", "b.example.com", false, false, false, false, 2, false},
+		{"header;user;id", "b.example.com", false, false, false, false, 0, true},
+	}
+	for _, test := range tests {
+		request := privacyRequest(t, test.privacy)
+		context, err := service.ApplyPrivacy(request, test.nextHop)
+		if err != nil {
+			t.Errorf("%s to %s: %v", test.privacy, test.nextHop, err)
+			continue
+		}
+		_, identity := request.GetHeader(core.SIPHeaderNames_P_ASSERTED_IDENTITY).(header.PAssertedIdentityHeader)
+		from := request.GetFrom().GetAddress().GetURI().String() == "sip:alice@a.example.com"
+		contact := request.GetHeader(core.SIPHeaderNames_CONTACT).(header.ContactHeader).GetAddress().GetURI().String() == "sip:alice@192.0.2.1"
+		callInfo := request.GetHeader(core.SIPHeaderNames_CALL_INFO) != nil
+		if identity != test.identity || from != test.from || contact != test.contact || callInfo != test.callInfo ||
+			countVias(request) != test.vias {
+			t.Errorf("%s to %s: P-Asserted-Identity %v, From %v, Contact %v, Call-Info %v, %d Via",
+				test.privacy, test.nextHop, identity, from, contact, callInfo, countVias(request))
+		}
+		if !test.from && request.GetFrom().GetAddress().GetURI().String() != "sip:anonymous@anonymous.invalid" {
+			t.Errorf("%s to %s: bad From %s", test.privacy, test.nextHop, request.GetFrom())
+		}
+		if (context.GetOriginalContact() != nil) == test.contact {
+			t.Errorf("%s to %s: bad original Contact %v", test.privacy, test.nextHop, context.GetOriginalContact())
+		}
+
+		// the response to the forwarded request, once the proxy removed
+		// its own Via
+		response := privacyRequest(t, test.privacy).CreateResponse(message.OK)
+		if test.restored {
+			response.RemoveHeader(core.SIPHeaderNames_VIA)
+		}
+		context.RestoreVias(response)
+		if countVias(response) != 2 || response.GetTopmostVia().GetBranch() != "z9hG4bKproxy" {
+			t.Errorf("%s to %s: Via not restored %s", test.privacy, test.nextHop, response)
+		}
+	}
+}
+
+func TestApplyCriticalPrivacy(t *testing.T) {
+	service := NewPrivacyService()
+	if _, err := service.ApplyPrivacy(privacyRequest(t, "header;critical"), "b.example.com"); err == nil {
+		t.Error("Contact not anonymized without error")
+	}
+	if _, err := service.ApplyPrivacy(privacyRequest(t, "session;critical"), "b.example.com"); err == nil {
+		t.Error("unavailable critical privacy without error")
+	}
+	if _, err := service.ApplyPrivacy(privacyRequest(t, "session"), "b.example.com"); err != nil {
+		t.Error(err)
+	}
+	service.SetContact(parseAddress(t, "<sip:anonymous@privacy.a.example.com>"))
+	if _, err := service.ApplyPrivacy(privacyRequest(t, "header;critical"), "b.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessIncoming(t *testing.T) {
+	service := NewPrivacyService()
+	service.AddTrustedHost("gw.a.example.com")
+	request := privacyRequest(t, "none")
+	service.ProcessIncoming(request, "gw.a.example.com")
+	if request.GetHeader(core.SIPHeaderNames_P_ASSERTED_IDENTITY) == nil {
+		t.Error("identity asserted by a trusted host removed")
+	}
+	service.ProcessIncoming(request, "b.example.com")
+	if request.GetHeader(core.SIPHeaderNames_P_ASSERTED_IDENTITY) != nil {
+		t.Error("identity asserted by an untrusted host kept")
+	}
+}
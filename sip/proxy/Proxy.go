@@ -0,0 +1,47 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Proxy.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package proxy
+
+import (
+	"gosips/core"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+)
+
+/** Retargets a request forwarded by a proxy to a registered contact: the
+ * Request-URI becomes the contact URI and the Path vector of the binding is
+ * pushed on top of the Route headers of the request (RFC 3327 section 5.4),
+ * so that the request reaches the contact through the proxies that were on
+ * the path of its REGISTER.
+ */
+func RetargetToBinding(request *message.SIPRequest, binding *Binding) {
+	request.SetRequestURI(binding.GetContactURI())
+	routes := make([]address.Address, 0, len(binding.path))
+	for _, p := range binding.path {
+		routes = append(routes, p.GetAddress())
+	}
+	PrependRoutes(request, routes)
+}
+
+/** Pushes routes, in order, on top of the Route headers of request, e.g. the
+ * Path vector of a binding or the service route of a registration.
+ */
+func PrependRoutes(request *message.SIPRequest, routes []address.Address) {
+	if len(routes) == 0 {
+		return
+	}
+	routeList := header.NewRouteList()
+	for _, route := range routes {
+		routeList.PushBack(header.NewRouteFromAddress(route))
+	}
+	for e := request.GetHeaders(core.SIPHeaderNames_ROUTE).Front(); e != nil; e = e.Next() {
+		routeList.PushBack(e.Value.(header.Header))
+	}
+	request.SetHeader(routeList)
+}
@@ -0,0 +1,128 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Redirect.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package proxy
+
+import (
+	"errors"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"sync"
+)
+
+/**
+ * A redirect server as described in RFC 3261 section 8.3: instead of
+ * forwarding requests, it answers them with a 3xx listing the contacts
+ * registered for their Request-URI in a LocationService. The contacts are
+ * ranked by the caller preferences of the request and carry the q-value and
+ * remaining duration of their binding, so that the caller tries them in
+ * order. Requests for an address-of-record without bindings are answered
+ * with 480 (Temporarily Unavailable).
+ * <p>
+ * Contacts reachable only through the Path of their registration (RFC 3327)
+ * cannot be redirected to: the caller is redirected to the public GRUU of
+ * the instance instead, which is routed through the Path by the proxy of the
+ * domain, and bindings without GRUU are left out.
+ */
+type RedirectServer struct {
+	mutex sync.Mutex
+
+	location   *LocationService
+	statusCode int
+}
+
+/** Creates a redirect server answering with the bindings of location and
+ * 302 (Moved Temporarily).
+ */
+func NewRedirectServer(location *LocationService) *RedirectServer {
+	return &RedirectServer{
+		location:   location,
+		statusCode: message.MOVED_TEMPORARILY,
+	}
+}
+
+/** Sets the status code of the redirections: 300 (Multiple Choices) to let
+ * the caller choose among the contacts, 301 (Moved Permanently) if the
+ * caller may replace the Request-URI for good, or 302 (Moved Temporarily).
+ */
+func (this *RedirectServer) SetStatusCode(statusCode int) (InvalidArgumentException error) {
+	if statusCode != message.MULTIPLE_CHOICES && statusCode != message.MOVED_PERMANENTLY &&
+		statusCode != message.MOVED_TEMPORARILY {
+		return errors.New("InvalidArgumentException: not a redirection status code")
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.statusCode = statusCode
+	return nil
+}
+
+/** Processes a request and returns the response to send: a redirection to
+ * the bindings of the Request-URI or 480. nil is returned for an ACK, which
+ * has no response.
+ */
+func (this *RedirectServer) ProcessRequest(request *message.SIPRequest) *message.SIPResponse {
+	if request.GetMethod() == message.ACK {
+		return nil
+	}
+	this.mutex.Lock()
+	statusCode := this.statusCode
+	this.mutex.Unlock()
+
+	targetSet := NewTargetSet(request, this.location.Lookup(request.GetRequestURI()))
+	contacts := getRedirectContacts(targetSet.GetBindings())
+	if len(contacts) == 0 {
+		return request.CreateResponse(message.TEMPORARILY_UNAVAILABLE)
+	}
+	return createRedirectResponse(request, statusCode, contacts)
+}
+
+/** Creates a 3xx response to request with a Contact for each binding, in
+ * order, carrying the q-value of the binding and its remaining duration as
+ * expires parameter. A binding registered with a Path is redirected to its
+ * public GRUU, or left out if it has none. A proxy uses it when the
+ * Request-Disposition of a request asks for a redirect.
+ */
+func CreateRedirectResponse(request *message.SIPRequest, statusCode int, bindings []*Binding) *message.SIPResponse {
+	return createRedirectResponse(request, statusCode, getRedirectContacts(bindings))
+}
+
+func createRedirectResponse(request *message.SIPRequest, statusCode int, contacts []*header.Contact) *message.SIPResponse {
+	response := request.CreateResponse(statusCode)
+	if len(contacts) == 0 {
+		return response
+	}
+	contactList := header.NewContactList()
+	for _, contact := range contacts {
+		contactList.PushBack(contact)
+	}
+	response.SetHeader(contactList)
+	return response
+}
+
+/** Returns the Contacts the caller can be redirected to for bindings.
+ */
+func getRedirectContacts(bindings []*Binding) []*header.Contact {
+	var contacts []*header.Contact
+	for _, b := range bindings {
+		contact := copyContact(b.contact)
+		if len(b.GetPath()) > 0 {
+			gruu := b.GetPublicGruu()
+			if gruu == nil {
+				continue
+			}
+			gruuAddress := address.NewAddressImpl()
+			gruuAddress.SetURI(gruu)
+			contact.SetAddress(gruuAddress)
+		}
+		contact.RemoveParameter(header.ParameterNames_REG_ID)
+		contact.SetQValue(b.GetQValue())
+		contact.SetExpires(b.GetExpires())
+		contacts = append(contacts, contact)
+	}
+	return contacts
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strings"
+	"testing"
+)
+
+func TestRedirectServer(t *testing.T) {
+	location := NewLocationService()
+	registrar := NewRegistrar(location)
+	registrar.ProcessRegister(register(t, 1, "Contact: <sip:bob@192.0.2.1>;q=0.5;expires=600, <sip:bob@192.0.2.3>;video;q=0.9\r\n"))
+	server := NewRedirectServer(location)
+	if err := server.SetStatusCode(message.USE_PROXY); err == nil {
+		t.Error("305 accepted as redirection")
+	}
+
+	request := preferencesRequest(t, message.INVITE, "")
+	response := server.ProcessRequest(request)
+	if response.GetStatusCode() != message.MOVED_TEMPORARILY {
+		t.Fatal("bad redirection", response.GetStatusCode())
+	}
+	var contacts []string
+	for e := response.GetHeaders(core.SIPHeaderNames_CONTACT).Front(); e != nil; e = e.Next() {
+		contact := e.Value.(*header.Contact)
+		contacts = append(contacts, contact.GetAddress().GetURI().String())
+		if expires := contact.GetExpires(); expires <= 0 || expires > 3600 {
+			t.Error("bad remaining duration", contact)
+		}
+	}
+	if strings.Join(contacts, ", ") != "sip:bob@192.0.2.3, sip:bob@192.0.2.1" {
+		t.Error("bad contacts", contacts)
+	}
+	if contact := response.GetHeader(core.SIPHeaderNames_CONTACT).(*header.Contact); contact.GetQValue() != 0.9 ||
+		!contact.HasParameter("video") {
+		t.Error("bad contact", contact)
+	}
+
+	server.SetStatusCode(message.MULTIPLE_CHOICES)
+	if response := server.ProcessRequest(preferencesRequest(t, message.INVITE, "Accept-Contact: *;video;require;explicit\r\n")); response.GetStatusCode() != message.MULTIPLE_CHOICES ||
+		len(getContacts(response)) != 1 {
+		t.Error("caller preferences ignored", response)
+	}
+	if response := server.ProcessRequest(preferencesRequest(t, message.ACK, "")); response != nil {
+		t.Error("ACK answered", response)
+	}
+	request = parseRequest(t, strings.Replace(request.String(), "sip:bob@example.com SIP/2.0", "sip:carol@example.com SIP/2.0", 1))
+	if response := server.ProcessRequest(request); response.GetStatusCode() != message.TEMPORARILY_UNAVAILABLE {
+		t.Error("unknown user redirected with", response.GetStatusCode())
+	}
+}
+
+func TestRedirectWithPath(t *testing.T) {
+	location := NewLocationService()
+	registrar := NewRegistrar(location)
+	registrar.ProcessRegister(register(t, 1, "Supported: path, gruu\r\nPath: <sip:p1.example.net;lr>\r\n"+
+		"Contact: <sip:bob@192.0.2.1>;+sip.instance=\"<urn:uuid:00000000-0000-1000-8000-000A95A0E128>\"\r\n"))
+	server := NewRedirectServer(location)
+
+	// the contact behind the Path is replaced by its GRUU
+	response := server.ProcessRequest(preferencesRequest(t, message.INVITE, ""))
+	contact, ok := response.GetHeader(core.SIPHeaderNames_CONTACT).(*header.Contact)
+	if response.GetStatusCode() != message.MOVED_TEMPORARILY || !ok ||
+		contact.GetAddress().GetURI().String() != "sip:bob@example.com;gr=urn:uuid:00000000-0000-1000-8000-000A95A0E128" {
+		t.Fatal("bad redirection to the GRUU", response)
+	}
+
+	// and left out without GRUU
+	registrar.ProcessRegister(register(t, 2, "Supported: path\r\nPath: <sip:p1.example.net;lr>\r\n"+
+		"Contact: <sip:bob@192.0.2.1>;+sip.instance=\"<urn:uuid:00000000-0000-1000-8000-000A95A0E128>\";expires=0, <sip:bob@192.0.2.3>\r\n"))
+	if response := server.ProcessRequest(preferencesRequest(t, message.INVITE, "")); response.GetStatusCode() != message.TEMPORARILY_UNAVAILABLE {
+		t.Error("contact behind a Path redirected to", response)
+	}
+	bindings := location.GetBindings("sip:bob@example.com")
+	if response := CreateRedirectResponse(preferencesRequest(t, message.INVITE, ""), message.MOVED_TEMPORARILY, bindings); len(bindings) != 1 ||
+		response.GetHeader(core.SIPHeaderNames_CONTACT) != nil {
+		t.Error("contact behind a Path redirected to", response)
+	}
+}
@@ -0,0 +1,309 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Registrar.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package proxy
+
+import (
+	"gosips/core"
+	"gosips/sip"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * The duration in seconds of a binding whose REGISTER gives no expiration
+ * interval.
+ */
+const REGISTRATION_DEFAULT_EXPIRES = 3600
+
+/**
+ * A registrar as described in RFC 3261 section 10.3, storing the bindings of
+ * REGISTER requests in a LocationService. The Path vector of a REGISTER (RFC
+ * 3327) is kept with its bindings and echoed in the 200 (OK) response if the
+ * User Agent supports Path; the configured service route is returned in a
+ * Service-Route header (RFC 3608).
+ * <p>
+ * A User Agent supporting outbound (RFC 5626) registers a binding per flow,
+ * identified by the +sip.instance and reg-id parameters of its Contact
+ * instead of the contact URI. The registrar then returns "Require: outbound"
+ * and the configured Flow-Timer, provided the first edge proxy on the path
+ * of the REGISTER supports outbound as well.
+ * <p>
+ * The bindings registered with a +sip.instance by a User Agent supporting
+ * GRUU (RFC 5627) are returned with a public GRUU and a new temporary GRUU
+ * in the pub-gruu and temp-gruu parameters of their Contact. Requests for
+ * these GRUUs are routed by the LocationService to the instance only.
+ * <p>
+ * Authentication of the REGISTER and the check that the address-of-record
+ * belongs to the domain of the registrar are left to the application.
+ */
+type Registrar struct {
+	mutex sync.Mutex
+
+	location       *LocationService
+	defaultExpires int
+	minExpires     int
+	maxExpires     int
+	flowTimer      int
+	serviceRoute   []address.Address
+}
+
+/** Creates a registrar storing its bindings in location.
+ */
+func NewRegistrar(location *LocationService) *Registrar {
+	return &Registrar{
+		location:       location,
+		defaultExpires: REGISTRATION_DEFAULT_EXPIRES,
+	}
+}
+
+/** Returns the location service of the registrar.
+ */
+func (this *Registrar) GetLocationService() *LocationService {
+	return this.location
+}
+
+/** Sets the duration in seconds of bindings whose REGISTER gives no
+ * expiration interval.
+ */
+func (this *Registrar) SetDefaultExpires(defaultExpires int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.defaultExpires = defaultExpires
+}
+
+/** Sets the shortest duration in seconds accepted for a binding; shorter ones
+ * are rejected with 423. 0 accepts any duration.
+ */
+func (this *Registrar) SetMinExpires(minExpires int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.minExpires = minExpires
+}
+
+/** Sets the longest duration in seconds granted to a binding; longer ones are
+ * shortened. 0 grants any duration.
+ */
+func (this *Registrar) SetMaxExpires(maxExpires int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.maxExpires = maxExpires
+}
+
+/** Sets the keep-alive interval in seconds returned in the Flow-Timer header
+ * of successful outbound registrations. 0 returns no Flow-Timer and lets the
+ * User Agents use their default interval.
+ */
+func (this *Registrar) SetFlowTimer(flowTimer int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.flowTimer = flowTimer
+}
+
+/** Sets the route set returned in the Service-Route header of successful
+ * registrations, e.g. the URI of the home service proxy with the lr
+ * parameter. No Service-Route header is returned without one.
+ */
+func (this *Registrar) SetServiceRoute(serviceRoute ...address.Address) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.serviceRoute = serviceRoute
+}
+
+/** Processes a REGISTER and returns the response to send: 200 with the
+ * current bindings of the address-of-record if it was accepted, 423 with
+ * Min-Expires for a too short expiration interval, 400 for a bad wildcard
+ * Contact, 500 for a REGISTER older than the one that last updated a
+ * binding, 439 for an outbound registration through an edge proxy that
+ * does not support outbound and 403 for a Contact that is a GRUU of the
+ * domain. Either all contacts of the REGISTER are applied or none.
+ */
+func (this *Registrar) ProcessRegister(register *message.SIPRequest) *message.SIPResponse {
+	this.mutex.Lock()
+	defaultExpires, minExpires, maxExpires := this.defaultExpires, this.minExpires, this.maxExpires
+	flowTimer := this.flowTimer
+	serviceRoute := this.serviceRoute
+	this.mutex.Unlock()
+
+	if register.GetTo() == nil || register.GetCallId() == nil {
+		return register.CreateResponse(message.BAD_REQUEST)
+	}
+	addressOfRecord := GetAddressOfRecord(register.GetTo().GetAddress().GetURI())
+	callId := register.GetCallId().GetCallId()
+	cseq := register.GetCSeqNumber()
+	expiresHeader, hasExpires := register.GetHeader(core.SIPHeaderNames_EXPIRES).(header.ExpiresHeader)
+
+	var contacts []*header.Contact
+	wildcard := false
+	for e := register.GetHeaders(core.SIPHeaderNames_CONTACT).Front(); e != nil; e = e.Next() {
+		if contact, ok := e.Value.(*header.Contact); ok {
+			contacts = append(contacts, contact)
+			wildcard = wildcard || isWildcard(contact)
+		}
+	}
+
+	if wildcard {
+		if len(contacts) != 1 || !hasExpires || expiresHeader.GetExpires() != 0 {
+			return register.CreateResponse(message.BAD_REQUEST)
+		}
+		if !this.location.update(addressOfRecord, callId, cseq, nil, nil, true) {
+			return register.CreateResponse(message.SERVER_INTERNAL_ERROR)
+		}
+		return this.createResponse(register, addressOfRecord, serviceRoute, false)
+	}
+
+	var path []*header.Path
+	for e := register.GetHeaders(core.SIPHeaderNames_PATH).Front(); e != nil; e = e.Next() {
+		if p, ok := e.Value.(*header.Path); ok {
+			path = append(path, p)
+		}
+	}
+
+	outbound := false
+	if sip.IsOptionSupported(register, sip.OPTION_OUTBOUND) {
+		for _, contact := range contacts {
+			if contact.GetRegId() > 0 && contact.GetInstanceId() != "" {
+				outbound = true
+			}
+		}
+	}
+	if outbound && len(path) > 0 {
+		// the first hop is the bottommost Path
+		uri, ok := path[len(path)-1].GetAddress().GetURI().(*address.SipURIImpl)
+		if !ok || !uri.GetUriParms().HasNameValue(header.ParameterNames_OB) {
+			return register.CreateResponse(message.FIRST_HOP_LACKS_OUTBOUND_SUPPORT)
+		}
+	}
+
+	// a GRUU cannot be bound to another GRUU of the domain
+	if domain, ok := register.GetTo().GetAddress().GetURI().(*address.SipURIImpl); ok {
+		for _, contact := range contacts {
+			if uri, ok := contact.GetAddress().GetURI().(*address.SipURIImpl); ok && uri.HasGrParam() &&
+				strings.EqualFold(uri.GetHost(), domain.GetHost()) {
+				return register.CreateResponse(message.FORBIDDEN)
+			}
+		}
+	}
+
+	var bindings []*Binding
+	var removed []string
+	for _, contact := range contacts {
+		expires := defaultExpires
+		if contact.HasParameter(header.ParameterNames_EXPIRES) {
+			expires = contact.GetExpires()
+		} else if hasExpires {
+			expires = expiresHeader.GetExpires()
+		}
+		if expires > 0 && expires < minExpires {
+			response := register.CreateResponse(message.INTERVAL_TOO_BRIEF)
+			minExpiresHeader := header.NewMinExpires()
+			minExpiresHeader.SetExpires(minExpires)
+			response.SetHeader(minExpiresHeader)
+			return response
+		}
+		if maxExpires > 0 && expires > maxExpires {
+			expires = maxExpires
+		}
+
+		binding := &Binding{
+			addressOfRecord: addressOfRecord,
+			contact:         copyContact(contact),
+			callId:          callId,
+			cseq:            cseq,
+			expiresAt:       time.Now().Add(time.Duration(expires) * time.Second),
+			path:            path,
+			instanceId:      contact.GetInstanceId(),
+		}
+		if outbound && contact.GetRegId() > 0 {
+			binding.regId = contact.GetRegId()
+		}
+		if expires == 0 {
+			removed = append(removed, binding.key())
+		} else {
+			bindings = append(bindings, binding)
+		}
+	}
+	if !this.location.update(addressOfRecord, callId, cseq, bindings, removed, false) {
+		return register.CreateResponse(message.SERVER_INTERNAL_ERROR)
+	}
+
+	gruu := sip.IsOptionSupported(register, sip.OPTION_GRUU)
+	response := this.createResponse(register, addressOfRecord, serviceRoute, gruu)
+	if len(path) > 0 && sip.IsOptionSupported(register, sip.OPTION_PATH) {
+		pathList := header.NewPathList()
+		for _, p := range path {
+			pathList.PushBack(p)
+		}
+		response.SetHeader(pathList)
+	}
+	if outbound {
+		sip.AddRequire(response, sip.OPTION_OUTBOUND)
+		if flowTimer > 0 {
+			flowTimerHeader := header.NewFlowTimer()
+			flowTimerHeader.SetSeconds(flowTimer)
+			response.SetHeader(flowTimerHeader)
+		}
+	}
+	return response
+}
+
+/** The wildcard flag of a parsed Contact is lost when its address is set, so
+ * the address is checked as well.
+ */
+func isWildcard(contact *header.Contact) bool {
+	if addr, ok := contact.GetAddress().(*address.AddressImpl); ok && addr.IsWildcard() {
+		return true
+	}
+	return contact.GetWildCardFlag()
+}
+
+/** Returns a 200 (OK) listing the current bindings of addressOfRecord, with
+ * the GRUUs of their instances if gruu is true.
+ */
+func (this *Registrar) createResponse(register *message.SIPRequest, addressOfRecord string, serviceRoute []address.Address, gruu bool) *message.SIPResponse {
+	response := register.CreateResponse(message.OK)
+	bindings := this.location.GetBindings(addressOfRecord)
+	if len(bindings) > 0 {
+		contactList := header.NewContactList()
+		for _, b := range bindings {
+			contact := copyContact(b.contact)
+			contact.SetExpires(b.GetExpires())
+			if pubGruu := b.GetPublicGruu(); gruu && pubGruu != nil {
+				contact.SetPubGruu(pubGruu.String())
+				contact.SetTempGruu(this.location.CreateTemporaryGruu(b).String())
+			}
+			contactList.PushBack(contact)
+		}
+		response.SetHeader(contactList)
+	}
+	if len(serviceRoute) > 0 {
+		serviceRouteList := header.NewServiceRouteList()
+		for _, route := range serviceRoute {
+			serviceRouteList.PushBack(header.NewServiceRouteFromAddress(route))
+		}
+		response.SetHeader(serviceRouteList)
+	}
+	return response
+}
+
+/** Returns the route set of the Service-Route header of a 200 (OK) to a
+ * REGISTER, which the User Agent pre-loads with PrependRoutes on the requests
+ * it originates.
+ */
+func GetServiceRoute(response message.Response) []address.Address {
+	var routes []address.Address
+	for e := response.GetHeaders(core.SIPHeaderNames_SERVICE_ROUTE).Front(); e != nil; e = e.Next() {
+		if route, ok := e.Value.(header.ServiceRouteHeader); ok {
+			routes = append(routes, route.GetAddress())
+		}
+	}
+	return routes
+}
@@ -0,0 +1,232 @@
+package proxy
+
+import (
+	"gosips/core"
+	"gosips/sip"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func parseRequest(t *testing.T, s string) *message.SIPRequest {
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(s)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, s)
+	}
+	return msg.(*message.SIPRequest)
+}
+
+func parseAddress(t *testing.T, s string) address.Address {
+	addr, err := parser.NewAddressParser(s).Address()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+// register returns a REGISTER of bob, received through the visited proxy
+// p1, with the Call-ID of his phone.
+func register(t *testing.T, cseq int, headers string) *message.SIPRequest {
+	return parseRequest(t, "REGISTER sip:example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP p1.example.net;branch=z9hG4bKp1"+strconv.Itoa(cseq)+"\r\n"+
+		"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bKua"+strconv.Itoa(cseq)+"\r\n"+
+		"To: <sip:bob@Example.com>\r\n"+
+		"From: <sip:bob@example.com>;tag=bob-tag\r\n"+
+		"Call-ID: register@192.0.2.1\r\n"+
+		"CSeq: "+strconv.Itoa(cseq)+" REGISTER\r\n"+
+		"Max-Forwards: 69\r\n"+headers+
+		"Content-Length: 0\r\n\r\n")
+}
+
+func getContacts(response message.Response) map[string]int {
+	contacts := make(map[string]int)
+	for e := response.GetHeaders(core.SIPHeaderNames_CONTACT).Front(); e != nil; e = e.Next() {
+		contact := e.Value.(header.ContactHeader)
+		contacts[contact.GetAddress().GetURI().String()] = contact.GetExpires()
+	}
+	return contacts
+}
+
+func TestRegistrar(t *testing.T) {
+	location := NewLocationService()
+	registrar := NewRegistrar(location)
+	registrar.SetMinExpires(60)
+	registrar.SetMaxExpires(3600)
+	registrar.SetServiceRoute(parseAddress(t, "<sip:orig@scscf.example.com;lr>"))
+
+	if response := registrar.ProcessRegister(register(t, 1, "Contact: <sip:bob@192.0.2.1>\r\nExpires: 30\r\n")); response.GetStatusCode() != message.INTERVAL_TOO_BRIEF ||
+		response.GetHeader(core.SIPHeaderNames_MIN_EXPIRES).(header.MinExpiresHeader).GetExpires() != 60 {
+		t.Fatal("short registration not rejected with 423 and Min-Expires", response)
+	}
+
+	response := registrar.ProcessRegister(register(t, 2, "Path: <sip:p1.example.net;lr>\r\nSupported: path\r\n"+
+		"Contact: <sip:bob@192.0.2.1>;q=0.5, <sip:bob@192.0.2.1:5070>;expires=120\r\nExpires: 7200\r\n"))
+	if response.GetStatusCode() != message.OK {
+		t.Fatal("REGISTER rejected with", response.GetStatusCode())
+	}
+	if contacts := getContacts(response); len(contacts) != 2 || contacts["sip:bob@192.0.2.1"] != 3600 ||
+		contacts["sip:bob@192.0.2.1:5070"] != 120 {
+		t.Error("bad bindings in the response", contacts)
+	}
+	if path, ok := response.GetHeader(core.SIPHeaderNames_PATH).(header.PathHeader); !ok ||
+		path.GetAddress().GetURI().String() != "sip:p1.example.net;lr" {
+		t.Error("Path not echoed", response)
+	}
+	if serviceRoute := GetServiceRoute(response); len(serviceRoute) != 1 ||
+		serviceRoute[0].GetURI().String() != "sip:orig@scscf.example.com;lr" {
+		t.Error("bad Service-Route", serviceRoute)
+	}
+
+	// the address-of-record is canonical and the highest q-value comes first
+	bindings := location.Lookup(parseAddress(t, "<sip:bob@EXAMPLE.com;transport=tcp>").GetURI())
+	if len(bindings) != 2 || bindings[0].GetContactURI().String() != "sip:bob@192.0.2.1:5070" ||
+		bindings[1].GetQValue() != 0.5 || len(bindings[0].GetPath()) != 1 {
+		t.Fatal("bad bindings", bindings)
+	}
+
+	// a REGISTER that is not newer than the last one changes nothing, even
+	// for its other contacts
+	response = registrar.ProcessRegister(register(t, 2, "Contact: <sip:bob@192.0.2.1>;expires=0, <sip:bob@198.51.100.1>\r\n"))
+	if response.GetStatusCode() != message.SERVER_INTERNAL_ERROR {
+		t.Error("REGISTER out of order accepted with", response.GetStatusCode())
+	}
+	if bindings := location.GetBindings("sip:bob@example.com"); len(bindings) != 2 {
+		t.Error("REGISTER out of order applied", bindings)
+	}
+
+	// refresh one binding and remove the other
+	response = registrar.ProcessRegister(register(t, 3, "Contact: <sip:bob@192.0.2.1:5070>;expires=600, <sip:bob@192.0.2.1>;expires=0\r\n"))
+	if contacts := getContacts(response); response.GetStatusCode() != message.OK || len(contacts) != 1 ||
+		contacts["sip:bob@192.0.2.1:5070"] != 600 {
+		t.Error("bad refresh", contacts)
+	}
+	if bindings := location.GetBindings("sip:bob@example.com"); len(bindings) != 1 || bindings[0].GetCSeq() != 3 ||
+		len(bindings[0].GetPath()) != 0 {
+		t.Error("binding not refreshed", bindings)
+	}
+
+	// a query lists the bindings without changing them
+	if contacts := getContacts(registrar.ProcessRegister(register(t, 4, ""))); len(contacts) != 1 {
+		t.Error("bad query", contacts)
+	}
+
+	if response := registrar.ProcessRegister(register(t, 5, "Contact: *\r\n")); response.GetStatusCode() != message.BAD_REQUEST {
+		t.Error("wildcard without Expires: 0 accepted with", response.GetStatusCode())
+	}
+	response = registrar.ProcessRegister(register(t, 5, "Contact: *\r\nExpires: 0\r\n"))
+	if response.GetStatusCode() != message.OK || len(location.GetBindings("sip:bob@example.com")) != 0 {
+		t.Error("bindings not removed by the wildcard", response)
+	}
+}
+
+func TestRegistrarGruu(t *testing.T) {
+	const instance = "urn:uuid:00000000-0000-1000-8000-000A95A0E128"
+	const contact = "Contact: <sip:bob@192.0.2.1>;+sip.instance=\"<" + instance + ">\"\r\n"
+	location := NewLocationService()
+	registrar := NewRegistrar(location)
+
+	// the 200 carries the GRUUs of the instance
+	response := parseResponse(t, registrar.ProcessRegister(register(t, 1, "Supported: gruu\r\n"+contact)).String())
+	pubGruu, tempGruu := sip.GetPublicGruu(response, instance), sip.GetTemporaryGruu(response, instance)
+	if response.GetStatusCode() != message.OK || pubGruu == nil || tempGruu == nil ||
+		pubGruu.String() != "sip:bob@example.com;gr=urn:uuid:00000000-0000-1000-8000-000A95A0E128" {
+		t.Fatal("bad GRUUs", response)
+	}
+	if s := tempGruu.String(); !strings.HasPrefix(s, "sip:tgruu.") || strings.Contains(s, "bob") || !IsGruu(tempGruu) {
+		t.Error("bad temporary GRUU", s)
+	}
+	for _, gruu := range []address.URI{pubGruu, tempGruu} {
+		if bindings := location.Lookup(gruu); len(bindings) != 1 || bindings[0].GetContactURI().String() != "sip:bob@192.0.2.1" {
+			t.Error("GRUU not resolved", gruu, bindings)
+		}
+	}
+
+	// each refresh creates a new temporary GRUU, and the previous ones stay
+	// valid
+	response = registrar.ProcessRegister(register(t, 2, "Supported: gruu\r\n"+contact))
+	refreshed := sip.GetTemporaryGruu(response, instance)
+	if refreshed == nil || refreshed.String() == tempGruu.String() {
+		t.Fatal("temporary GRUU not renewed", refreshed)
+	}
+	for _, gruu := range []address.URI{tempGruu, refreshed} {
+		if len(location.Lookup(gruu)) != 1 {
+			t.Error("temporary GRUU not resolved", gruu)
+		}
+	}
+
+	// a temporary GRUU not created by the location service is not resolved
+	user := tempGruu.(*address.SipURIImpl).GetUser()
+	tampered := []byte(user)
+	if i := len("tgruu.") + 10; tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	for _, forged := range []string{string(tampered), "tgruu.AAAA", "tgruu." + user[len("tgruu."):len(user)-4]} {
+		if bindings := location.Lookup(parseURI(t, "sip:"+forged+"@example.com;gr")); len(bindings) != 0 {
+			t.Error("forged temporary GRUU resolved", forged)
+		}
+	}
+	if bindings := NewLocationService().Lookup(tempGruu); len(bindings) != 0 {
+		t.Error("temporary GRUU of another location service resolved")
+	}
+
+	// a GRUU of the domain cannot be registered as a contact
+	if response := registrar.ProcessRegister(register(t, 3, "Contact: <"+pubGruu.String()+">\r\n")); response.GetStatusCode() != message.FORBIDDEN {
+		t.Error("GRUU registered as contact with", response.GetStatusCode())
+	}
+	if response := registrar.ProcessRegister(register(t, 3, "Contact: <sip:bob@EXAMPLE.com;gr>\r\n")); response.GetStatusCode() != message.FORBIDDEN {
+		t.Error("temporary GRUU registered as contact with", response.GetStatusCode())
+	}
+
+	// no GRUU without the gruu option tag nor for a contact without instance
+	response = registrar.ProcessRegister(register(t, 4, contact))
+	if sip.GetPublicGruu(response, instance) != nil || sip.GetTemporaryGruu(response, instance) != nil {
+		t.Error("GRUU without Supported: gruu", response)
+	}
+	response = registrar.ProcessRegister(register(t, 5, "Supported: gruu\r\nContact: <sip:bob@192.0.2.1>;+sip.instance=\"<"+instance+">\";expires=0, <sip:bob@192.0.2.3>\r\n"))
+	if response.GetStatusCode() != message.OK || strings.Contains(response.String(), "gruu=") {
+		t.Error("GRUU without instance", response)
+	}
+	if bindings := location.Lookup(pubGruu); len(bindings) != 0 {
+		t.Error("GRUU of a removed instance resolved", bindings)
+	}
+}
+
+func TestRetargetToBinding(t *testing.T) {
+	location := NewLocationService()
+	registrar := NewRegistrar(location)
+	registrar.ProcessRegister(register(t, 1, "Path: <sip:p1.example.net;lr>, <sip:p2.example.net;lr>\r\n"+
+		"Contact: <sip:bob@192.0.2.1>\r\n"))
+
+	invite := parseRequest(t, "INVITE sip:bob@example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP 198.51.100.3;branch=z9hG4bKcarol\r\n"+
+		"Route: <sip:term@scscf.example.com;lr>\r\n"+
+		"From: <sip:carol@c.example.com>;tag=carol-tag\r\n"+
+		"To: <sip:bob@example.com>\r\n"+
+		"Call-ID: carol@c.example.com\r\n"+
+		"CSeq: 1 INVITE\r\n"+
+		"Max-Forwards: 70\r\n"+
+		"Content-Length: 0\r\n\r\n")
+	bindings := location.Lookup(invite.GetRequestURI())
+	if len(bindings) != 1 {
+		t.Fatal("bad bindings", bindings)
+	}
+	RetargetToBinding(invite, bindings[0])
+	PrependRoutes(invite, []address.Address{parseAddress(t, "<sip:orig@scscf.example.com;lr>")})
+
+	if invite.GetRequestURI().String() != "sip:bob@192.0.2.1" {
+		t.Error("request not retargeted", invite.GetRequestURI())
+	}
+	var routes []string
+	for e := invite.GetHeaders(core.SIPHeaderNames_ROUTE).Front(); e != nil; e = e.Next() {
+		routes = append(routes, e.Value.(header.RouteHeader).GetAddress().GetURI().String())
+	}
+	if strings.Join(routes, ", ") != "sip:orig@scscf.example.com;lr, sip:p1.example.net;lr, sip:p2.example.net;lr, sip:term@scscf.example.com;lr" {
+		t.Error("bad route set", routes)
+	}
+}
@@ -0,0 +1,500 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : TopologyHiding.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package proxy
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"gosips/core"
+	"gosips/sip"
+	"gosips/sip/address"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/** The prefix of the tokens of topology hiding.
+ */
+const topologyTokenPrefix = "th."
+
+/** The Via parameter carrying the hidden Via headers.
+ */
+const topologyViaParameter = "th"
+
+/** The kinds of tokens, used as additional data of their encryption so that
+ * a token of one kind cannot be passed for another.
+ */
+const (
+	topologyTokenVia            = "via"
+	topologyTokenRoute          = "route"
+	topologyTokenContact        = "contact"
+	topologyTokenCallId         = "call-id"
+	topologyTokenExternalCallId = "external-call-id"
+)
+
+/**
+ * The topology hiding function of a Session Border Controller (RFC 5853
+ * section 3.1.2): the messages leaving the internal network through the SBC
+ * reveal none of its addresses, and the messages entering it get them back.
+ * <p>
+ * The Via headers of a request leaving are replaced with a single Via of
+ * the SBC and its Record-Route headers with a single Record-Route of the
+ * SBC, both carrying the headers they replace in an encrypted token. The
+ * Contact headers are replaced with URIs of the SBC whose user part is the
+ * encrypted contact URI, and the Call-ID with its encryption. The responses
+ * and the requests entering, whose Via, Route and Request-URI carry the
+ * tokens, are restored. The Call-IDs created outside are likewise encrypted
+ * entering and restored leaving, so that no state is needed: the tokens are
+ * encrypted with a key of the SBC and with a nonce derived from their
+ * content, so that the same header always gives the same token. Finally, the
+ * headers of the internal network matched by the configured patterns, e.g.
+ * "X-Internal-.*", are removed from the messages leaving.
+ * <p>
+ * HideRequest and HideResponse are applied to the messages ready to be sent
+ * outside, after the SBC pushed its own Via and Record-Route if any, and
+ * RestoreRequest and RestoreResponse to the messages received from outside
+ * before they are processed. The SBC must record-route the dialog-forming
+ * requests entering the internal network so that the Record-Route headers
+ * of their responses can be hidden.
+ */
+type TopologyHiding struct {
+	mutex sync.Mutex
+
+	host      string
+	port      int
+	transport string
+	key       cipher.AEAD
+	nonceKey  []byte
+	patterns  []*regexp.Regexp
+}
+
+/** Creates the topology hiding of an SBC reachable from outside at host and
+ * port over transport, e.g. sip.UDP, encrypting its tokens with keys derived
+ * from key, a random secret of at least 16 bytes. The SBCs sharing the
+ * dialogs of a cluster share the secret, which is kept across restarts so
+ * that the tokens of the dialogs in progress remain valid.
+ */
+func NewTopologyHiding(host string, port int, transport string, key []byte) *TopologyHiding {
+	block, _ := aes.NewCipher(deriveKey(key, "encryption"))
+	aead, _ := cipher.NewGCM(block)
+	nonceKey := deriveKey(key, "nonce")
+	return &TopologyHiding{
+		host:      host,
+		port:      port,
+		transport: transport,
+		key:       aead,
+		nonceKey:  nonceKey,
+	}
+}
+
+/** Adds a pattern of the names of the headers removed from the messages
+ * leaving: a regular expression matching whole header names, without case.
+ */
+func (this *TopologyHiding) AddHeaderPattern(pattern string) (ParseException error) {
+	re, err := regexp.Compile("(?i)^(?:" + pattern + ")$")
+	if err != nil {
+		return errors.New("ParseException: bad header pattern: " + err.Error())
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.patterns = append(this.patterns, re)
+	return nil
+}
+
+/** Hides the internal network from a request leaving it: its Via and
+ * Record-Route headers are replaced with those of the SBC, its Contact
+ * headers and Call-ID are encrypted and its internal headers removed.
+ */
+func (this *TopologyHiding) HideRequest(request *message.SIPRequest) (SipException error) {
+	this.removeHeaders(request)
+	if err := this.hideVias(request); err != nil {
+		return err
+	}
+	if recordRoutes := request.GetHeaders(core.SIPHeaderNames_RECORD_ROUTE); recordRoutes.Len() > 0 {
+		if err := this.hideRecordRoutes(request, recordRoutes.Len(), false); err != nil {
+			return err
+		}
+	}
+	if err := this.hideContacts(request); err != nil {
+		return err
+	}
+	request.SetCallIdFromString(this.hideCallId(request.GetCallIdentifier()))
+	return nil
+}
+
+/** Hides the internal network from a response leaving it: the Record-Route
+ * headers above the one of the SBC are replaced with a Record-Route of the
+ * SBC, its Contact headers and Call-ID are encrypted and its internal
+ * headers removed.
+ */
+func (this *TopologyHiding) HideResponse(response *message.SIPResponse) (SipException error) {
+	this.removeHeaders(response)
+	// the internal Record-Route headers are those above the SBC
+	n := 0
+	for e := response.GetHeaders(core.SIPHeaderNames_RECORD_ROUTE).Front(); e != nil; e = e.Next() {
+		n++
+		if uri, ok := e.Value.(*header.RecordRoute).GetAddress().GetURI().(*address.SipURIImpl); ok && this.isLocal(uri) {
+			if err := this.hideRecordRoutes(response, n, true); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	if err := this.hideContacts(response); err != nil {
+		return err
+	}
+	response.SetCallIdFromString(this.hideCallId(response.GetCallIdentifier()))
+	return nil
+}
+
+/** Restores a request entering the internal network: its Call-ID, its
+ * Request-URI if it is a hidden contact and the Route headers hidden in its
+ * topmost Route. The Route headers of the SBC itself are removed. An error is
+ * returned for a token that was not created by the SBC; the request should
+ * then be rejected with 403.
+ */
+func (this *TopologyHiding) RestoreRequest(request *message.SIPRequest) (SipException error) {
+	request.SetCallIdFromString(this.restoreCallId(request.GetCallIdentifier()))
+
+	if uri, ok := request.GetRequestURI().(*address.SipURIImpl); ok && this.isToken(uri) {
+		contact, err := this.restoreURI(uri)
+		if err != nil {
+			return err
+		}
+		request.SetRequestURI(contact)
+	}
+
+	routes := request.GetHeaders(core.SIPHeaderNames_ROUTE)
+	if routes.Front() == nil {
+		return nil
+	}
+	route := routes.Front().Value.(*header.Route)
+	uri, ok := route.GetAddress().GetURI().(*address.SipURIImpl)
+	if !ok || !this.isToken(uri) {
+		return nil
+	}
+	hidden, reversed, err := this.openRoutes(uri)
+	if err != nil {
+		return err
+	}
+	if reversed {
+		for i, j := 0, len(hidden)-1; i < j; i, j = i+1, j-1 {
+			hidden[i], hidden[j] = hidden[j], hidden[i]
+		}
+	}
+	routeList := header.NewRouteList()
+	for _, addr := range hidden {
+		// the request is at the SBC, which is not a next hop
+		if u, ok := addr.GetURI().(*address.SipURIImpl); ok && routeList.Len() == 0 && this.isLocal(u) {
+			continue
+		}
+		routeList.PushBack(header.NewRouteFromAddress(addr))
+	}
+	for e := routes.Front().Next(); e != nil; e = e.Next() {
+		routeList.PushBack(e.Value.(header.Header))
+	}
+	if routeList.Len() > 0 {
+		request.SetHeader(routeList)
+	} else {
+		request.RemoveHeader(core.SIPHeaderNames_ROUTE)
+	}
+	return nil
+}
+
+/** Restores a response entering the internal network: its Call-ID, the Via
+ * headers hidden in its topmost Via and the Record-Route and Contact headers
+ * hidden by the SBC.
+ */
+func (this *TopologyHiding) RestoreResponse(response *message.SIPResponse) (SipException error) {
+	response.SetCallIdFromString(this.restoreCallId(response.GetCallIdentifier()))
+
+	if via := response.GetTopmostVia(); via != nil && via.HasParameter(topologyViaParameter) {
+		plaintext, err := this.open(topologyTokenVia, via.GetParameter(topologyViaParameter))
+		if err != nil {
+			return err
+		}
+		viaList := header.NewViaList()
+		for _, body := range strings.Split(string(plaintext), "\n") {
+			vias, err := parser.NewViaParser("Via: " + body + "\n").Parse()
+			if err != nil {
+				return err
+			}
+			for e := vias.(*header.ViaList).Front(); e != nil; e = e.Next() {
+				viaList.PushBack(e.Value.(header.Header))
+			}
+		}
+		response.SetVia(viaList)
+	}
+
+	if recordRoutes := response.GetHeaders(core.SIPHeaderNames_RECORD_ROUTE); recordRoutes.Len() > 0 {
+		recordRouteList := header.NewRecordRouteList()
+		restored := false
+		for e := recordRoutes.Front(); e != nil; e = e.Next() {
+			recordRoute := e.Value.(*header.RecordRoute)
+			uri, ok := recordRoute.GetAddress().GetURI().(*address.SipURIImpl)
+			if !ok || !this.isToken(uri) {
+				recordRouteList.PushBack(recordRoute)
+				continue
+			}
+			hidden, _, err := this.openRoutes(uri)
+			if err != nil {
+				return err
+			}
+			for _, addr := range hidden {
+				recordRouteList.PushBack(header.NewRecordRouteFromAddress(addr))
+			}
+			restored = true
+		}
+		if restored {
+			response.SetHeader(recordRouteList)
+		}
+	}
+
+	for e := response.GetHeaders(core.SIPHeaderNames_CONTACT).Front(); e != nil; e = e.Next() {
+		contact, ok := e.Value.(*header.Contact)
+		if !ok || contact.GetWildCardFlag() || contact.GetAddress() == nil {
+			continue
+		}
+		if uri, ok := contact.GetAddress().GetURI().(*address.SipURIImpl); ok && this.isToken(uri) {
+			restored, err := this.restoreURI(uri)
+			if err != nil {
+				return err
+			}
+			contactAddress, err := parser.NewAddressParser("<" + restored.String() + ">").Address()
+			if err != nil {
+				return err
+			}
+			contact.SetAddress(contactAddress)
+		}
+	}
+	return nil
+}
+
+/** Replaces the Via headers of a request with a Via of the SBC carrying
+ * them. Its branch is derived from the topmost branch so that a CANCEL or
+ * the ACK of an error response gets the branch of its INVITE.
+ */
+func (this *TopologyHiding) hideVias(request *message.SIPRequest) error {
+	vias := request.GetHeaders(core.SIPHeaderNames_VIA)
+	if vias.Front() == nil {
+		return nil
+	}
+	var bodies []string
+	for e := vias.Front(); e != nil; e = e.Next() {
+		bodies = append(bodies, e.Value.(*header.Via).EncodeBody())
+	}
+	token := this.seal(topologyTokenVia, []byte(strings.Join(bodies, "\n")))
+	branch := request.GetTopmostVia().GetBranch()
+	mac := hmac.New(sha256.New, this.nonceKey)
+	mac.Write([]byte(topologyTokenVia + "\x00" + branch))
+	via := "Via: SIP/2.0/" + strings.ToUpper(this.transport) + " " + this.getHostPort() +
+		";branch=" + header.SIPConstants_BRANCH_MAGIC_COOKIE + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12]) +
+		";" + topologyViaParameter + "=" + token + "\n"
+	viaList, err := parser.NewViaParser(via).Parse()
+	if err != nil {
+		return err
+	}
+	request.SetVia(viaList.(*header.ViaList))
+	return nil
+}
+
+/** Replaces the n topmost Record-Route headers of msg with a Record-Route
+ * of the SBC carrying them. reversed tells that the requests of the dialog
+ * will use them in the reverse order, as for those of a response.
+ */
+func (this *TopologyHiding) hideRecordRoutes(msg message.Message, n int, reversed bool) error {
+	recordRoutes := msg.GetHeaders(core.SIPHeaderNames_RECORD_ROUTE)
+	flag := "0"
+	if reversed {
+		flag = "1"
+	}
+	bodies := []string{flag}
+	e := recordRoutes.Front()
+	for i := 0; i < n && e != nil; i, e = i+1, e.Next() {
+		bodies = append(bodies, e.Value.(*header.RecordRoute).EncodeBody())
+	}
+	token := this.seal(topologyTokenRoute, []byte(strings.Join(bodies, "\n")))
+	recordRouteAddress, err := parser.NewAddressParser("<" + this.createURI(token) + ";lr>").Address()
+	if err != nil {
+		return err
+	}
+	recordRouteList := header.NewRecordRouteList()
+	recordRouteList.PushBack(header.NewRecordRouteFromAddress(recordRouteAddress))
+	for ; e != nil; e = e.Next() {
+		recordRouteList.PushBack(e.Value.(header.Header))
+	}
+	return msg.SetHeader(recordRouteList)
+}
+
+/** Returns the addresses hidden in the Route or Record-Route URI of the SBC
+ * uri, in the order of the Record-Route headers they were taken from.
+ */
+func (this *TopologyHiding) openRoutes(uri *address.SipURIImpl) (routes []address.Address, reversed bool, SipException error) {
+	plaintext, err := this.open(topologyTokenRoute, uri.GetUser()[len(topologyTokenPrefix):])
+	if err != nil {
+		return nil, false, err
+	}
+	bodies := strings.Split(string(plaintext), "\n")
+	for _, body := range bodies[1:] {
+		recordRoutes, err := parser.NewRecordRouteParser("Record-Route: " + body + "\n").Parse()
+		if err != nil {
+			return nil, false, err
+		}
+		for e := recordRoutes.(*header.RecordRouteList).Front(); e != nil; e = e.Next() {
+			routes = append(routes, e.Value.(*header.RecordRoute).GetAddress())
+		}
+	}
+	return routes, bodies[0] == "1", nil
+}
+
+/** Replaces the URIs of the Contact headers of msg with URIs of the SBC
+ * carrying them.
+ */
+func (this *TopologyHiding) hideContacts(msg message.Message) error {
+	for e := msg.GetHeaders(core.SIPHeaderNames_CONTACT).Front(); e != nil; e = e.Next() {
+		contact, ok := e.Value.(*header.Contact)
+		if !ok || contact.GetWildCardFlag() || contact.GetAddress() == nil {
+			continue
+		}
+		token := this.seal(topologyTokenContact, []byte(contact.GetAddress().GetURI().String()))
+		contactAddress, err := parser.NewAddressParser("<" + this.createURI(token) + ">").Address()
+		if err != nil {
+			return err
+		}
+		contact.SetAddress(contactAddress)
+	}
+	return nil
+}
+
+/** Returns the URI hidden in the contact URI of the SBC uri.
+ */
+func (this *TopologyHiding) restoreURI(uri *address.SipURIImpl) (address.URI, error) {
+	plaintext, err := this.open(topologyTokenContact, uri.GetUser()[len(topologyTokenPrefix):])
+	if err != nil {
+		return nil, err
+	}
+	return parser.NewURLParser(string(plaintext)).UriReference()
+}
+
+/** Returns the Call-ID of a message leaving: the Call-ID created outside if
+ * callId is its encryption, or else the encryption of callId.
+ */
+func (this *TopologyHiding) hideCallId(callId string) string {
+	if strings.HasPrefix(callId, topologyTokenPrefix) {
+		if plaintext, err := this.open(topologyTokenExternalCallId, callId[len(topologyTokenPrefix):]); err == nil {
+			return string(plaintext)
+		}
+	}
+	return topologyTokenPrefix + this.seal(topologyTokenCallId, []byte(callId))
+}
+
+/** Returns the Call-ID of a message entering: the internal Call-ID if
+ * callId is its encryption, or else the encryption of callId.
+ */
+func (this *TopologyHiding) restoreCallId(callId string) string {
+	if strings.HasPrefix(callId, topologyTokenPrefix) {
+		if plaintext, err := this.open(topologyTokenCallId, callId[len(topologyTokenPrefix):]); err == nil {
+			return string(plaintext)
+		}
+	}
+	return topologyTokenPrefix + this.seal(topologyTokenExternalCallId, []byte(callId))
+}
+
+/** Removes the headers of msg whose names match one of the patterns.
+ */
+func (this *TopologyHiding) removeHeaders(msg message.Message) {
+	this.mutex.Lock()
+	patterns := this.patterns
+	this.mutex.Unlock()
+	if len(patterns) == 0 {
+		return
+	}
+	var names []string
+	for e := msg.GetHeaderNames().Front(); e != nil; e = e.Next() {
+		name := e.Value.(header.Header).GetName()
+		for _, pattern := range patterns {
+			if pattern.MatchString(name) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	for _, name := range names {
+		msg.RemoveHeader(name)
+	}
+}
+
+/** Returns the 32 bytes key of the given usage derived from secret.
+ */
+func deriveKey(secret []byte, usage string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("topology hiding " + usage))
+	return mac.Sum(nil)
+}
+
+/** Encrypts plaintext into a base64url token. The nonce is derived from the
+ * kind and the plaintext, so that the same plaintext gives the same token.
+ */
+func (this *TopologyHiding) seal(kind string, plaintext []byte) string {
+	mac := hmac.New(sha256.New, this.nonceKey)
+	mac.Write([]byte(kind + "\x00"))
+	mac.Write(plaintext)
+	nonce := mac.Sum(nil)[:this.key.NonceSize()]
+	token := this.key.Seal(nonce, nonce, plaintext, []byte(kind))
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+/** Decrypts a token of the given kind created by seal.
+ */
+func (this *TopologyHiding) open(kind, token string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	nonceSize := this.key.NonceSize()
+	if err != nil || len(data) < nonceSize {
+		return nil, errors.New("SipException: bad topology hiding token")
+	}
+	plaintext, err := this.key.Open(nil, data[:nonceSize], data[nonceSize:], []byte(kind))
+	if err != nil {
+		return nil, errors.New("SipException: forged topology hiding token")
+	}
+	return plaintext, nil
+}
+
+/** Returns a URI of the SBC with token as user part.
+ */
+func (this *TopologyHiding) createURI(token string) string {
+	uri := "sip:" + topologyTokenPrefix + token + "@" + this.getHostPort()
+	if !strings.EqualFold(this.transport, sip.UDP) {
+		uri += ";transport=" + strings.ToLower(this.transport)
+	}
+	return uri
+}
+
+/** Returns true if uri is a URI of the SBC carrying a token.
+ */
+func (this *TopologyHiding) isToken(uri *address.SipURIImpl) bool {
+	return strings.HasPrefix(uri.GetUser(), topologyTokenPrefix) && this.isLocal(uri)
+}
+
+func (this *TopologyHiding) isLocal(uri *address.SipURIImpl) bool {
+	return isLocalURI(uri, this.host, this.port)
+}
+
+func (this *TopologyHiding) getHostPort() string {
+	if this.port > 0 {
+		return this.host + ":" + strconv.Itoa(this.port)
+	}
+	return this.host
+}
@@ -0,0 +1,260 @@
+package proxy
+
+import (
+	"gosips/core"
+	"gosips/sip/header"
+	"gosips/sip/message"
+	"gosips/sip/parser"
+	"strings"
+	"testing"
+)
+
+var topologyKey = []byte("0123456789abcdef")
+
+// internalInvite returns an INVITE of alice leaving the internal network
+// through the SBC, after the SBC pushed its Via and Record-Route.
+func internalInvite(t *testing.T, method string) *message.SIPRequest {
+	return parseRequest(t, method+" sip:bob@b.example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP sbc.example.com:5060;branch=z9hG4bKsbc\r\n"+
+		"Via: SIP/2.0/UDP 10.1.1.1:5060;branch=z9hG4bKcore\r\n"+
+		"Via: SIP/2.0/UDP 10.2.2.2:5060;branch=z9hG4bKalice;rport\r\n"+
+		"Record-Route: <sip:sbc.example.com;lr>\r\n"+
+		"Record-Route: <sip:10.1.1.1;lr>\r\n"+
+		"From: <sip:alice@a.example.com>;tag=alice-tag\r\n"+
+		"To: <sip:bob@b.example.com>\r\n"+
+		"Call-ID: internal@10.2.2.2\r\n"+
+		"CSeq: 1 "+method+"\r\n"+
+		"Contact: <sip:alice@10.2.2.2:5060>;expires=60\r\n"+
+		"X-Internal-Route: core7\r\n"+
+		"P-Charging-Vector: icid-value=1\r\n"+
+		"Max-Forwards: 70\r\n"+
+		"Content-Length: 0\r\n\r\n")
+}
+
+func parseResponse(t *testing.T, s string) *message.SIPResponse {
+	msg, err := parser.NewStringMsgParser().ParseSIPMessage(s)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, s)
+	}
+	return msg.(*message.SIPResponse)
+}
+
+func getURIs(msg message.Message, name string) []string {
+	var uris []string
+	for e := msg.GetHeaders(name).Front(); e != nil; e = e.Next() {
+		uris = append(uris, e.Value.(header.AddressHeader).GetAddress().GetURI().String())
+	}
+	return uris
+}
+
+func TestHideRequest(t *testing.T) {
+	topologyHiding := NewTopologyHiding("sbc.example.com", 5060, "UDP", topologyKey)
+	if err := topologyHiding.AddHeaderPattern("X-Internal-.*"); err != nil {
+		t.Fatal(err)
+	}
+	topologyHiding.AddHeaderPattern("p-charging-vector")
+	if err := topologyHiding.AddHeaderPattern("("); err == nil {
+		t.Error("bad pattern accepted")
+	}
+
+	invite := internalInvite(t, message.INVITE)
+	if err := topologyHiding.HideRequest(invite); err != nil {
+		t.Fatal(err)
+	}
+	s := invite.String()
+	for _, internal := range []string{"10.1.1.1", "10.2.2.2", "z9hG4bKalice", "X-Internal", "P-Charging"} {
+		if strings.Contains(s, internal) {
+			t.Errorf("%s leaks\n%s", internal, s)
+		}
+	}
+	if invite.GetHeaders(core.SIPHeaderNames_VIA).Len() != 1 || invite.GetTopmostVia().GetHost() != "sbc.example.com" ||
+		len(getURIs(invite, core.SIPHeaderNames_RECORD_ROUTE)) != 1 || !strings.Contains(s, "expires=60") ||
+		!strings.HasPrefix(invite.GetCallIdentifier(), "th.") {
+		t.Fatal("bad hidden request", s)
+	}
+
+	// the same headers give the same tokens: a CANCEL gets the branch of its
+	// INVITE, and the key is kept across restarts
+	cancel := internalInvite(t, message.CANCEL)
+	NewTopologyHiding("sbc.example.com", 5060, "UDP", topologyKey).HideRequest(cancel)
+	if cancel.GetTopmostVia().GetBranch() != invite.GetTopmostVia().GetBranch() ||
+		cancel.GetCallIdentifier() != invite.GetCallIdentifier() {
+		t.Error("CANCEL not matching its INVITE", cancel)
+	}
+	other := internalInvite(t, message.INVITE)
+	NewTopologyHiding("sbc.example.com", 5060, "UDP", []byte("fedcba9876543210")).HideRequest(other)
+	if other.GetCallIdentifier() == invite.GetCallIdentifier() {
+		t.Error("tokens independent of the key")
+	}
+}
+
+func TestTopologyHidingInternalCall(t *testing.T) {
+	topologyHiding := NewTopologyHiding("sbc.example.com", 5060, "UDP", topologyKey)
+	invite := internalInvite(t, message.INVITE)
+	topologyHiding.HideRequest(invite)
+	hidden := parseRequest(t, invite.String())
+
+	// bob answers through his proxy
+	ok := hidden.CreateResponse(message.OK)
+	ok.SetToTag("bob-tag")
+	s := strings.Replace(ok.String(), "Record-Route: ", "Record-Route: <sip:proxy.b.example.com;lr>\r\nRecord-Route: ", 1)
+	s = strings.Replace(s, "Content-Length:", "Contact: <sip:bob@198.51.100.9>\r\nContent-Length:", 1)
+	response := parseResponse(t, s)
+	if err := topologyHiding.RestoreResponse(response); err != nil {
+		t.Fatal(err)
+	}
+	var vias []string
+	for e := response.GetHeaders(core.SIPHeaderNames_VIA).Front(); e != nil; e = e.Next() {
+		vias = append(vias, e.Value.(*header.Via).GetBranch())
+	}
+	if strings.Join(vias, " ") != "z9hG4bKsbc z9hG4bKcore z9hG4bKalice" {
+		t.Error("Via not restored", vias)
+	}
+	if response.GetCallIdentifier() != "internal@10.2.2.2" {
+		t.Error("Call-ID not restored", response.GetCallIdentifier())
+	}
+	if routes := strings.Join(getURIs(response, core.SIPHeaderNames_RECORD_ROUTE), ", "); routes != "sip:proxy.b.example.com;lr, sip:sbc.example.com;lr, sip:10.1.1.1;lr" {
+		t.Error("Record-Route not restored", routes)
+	}
+	if contacts := getURIs(response, core.SIPHeaderNames_CONTACT); len(contacts) != 1 || contacts[0] != "sip:bob@198.51.100.9" {
+		t.Error("external Contact changed", contacts)
+	}
+
+	// the BYE of bob follows the hidden route set to the hidden contact
+	bye := parseRequest(t, "BYE "+getURIs(hidden, core.SIPHeaderNames_CONTACT)[0]+" SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP 198.51.100.9;branch=z9hG4bKbye\r\n"+
+		"Route: <"+getURIs(hidden, core.SIPHeaderNames_RECORD_ROUTE)[0]+">\r\n"+
+		"From: <sip:bob@b.example.com>;tag=bob-tag\r\n"+
+		"To: <sip:alice@a.example.com>;tag=alice-tag\r\n"+
+		"Call-ID: "+hidden.GetCallIdentifier()+"\r\n"+
+		"CSeq: 2 BYE\r\n"+
+		"Content-Length: 0\r\n\r\n")
+	if err := topologyHiding.RestoreRequest(bye); err != nil {
+		t.Fatal(err)
+	}
+	if bye.GetRequestURI().String() != "sip:alice@10.2.2.2:5060" || bye.GetCallIdentifier() != "internal@10.2.2.2" {
+		t.Error("BYE not restored", bye)
+	}
+	if routes := getURIs(bye, core.SIPHeaderNames_ROUTE); len(routes) != 1 || routes[0] != "sip:10.1.1.1;lr" {
+		t.Error("bad route set", routes)
+	}
+
+	// a request without Route nor token is left alone
+	options := strings.Replace(bye.String(), "Route: <sip:10.1.1.1;lr>\r\n", "", 1)
+	options = strings.Replace(options, "BYE sip:alice@10.2.2.2:5060", "OPTIONS sip:alice@a.example.com", 1)
+	request := parseRequest(t, strings.Replace(options, "2 BYE", "3 OPTIONS", 1))
+	if err := topologyHiding.RestoreRequest(request); err != nil || request.GetRequestURI().String() != "sip:alice@a.example.com" ||
+		request.GetHeaders(core.SIPHeaderNames_ROUTE).Len() != 0 {
+		t.Error("request without token changed", request, err)
+	}
+}
+
+func TestTopologyHidingExternalCall(t *testing.T) {
+	topologyHiding := NewTopologyHiding("sbc.example.com", 0, "TCP", topologyKey)
+	invite := parseRequest(t, "INVITE sip:alice@a.example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/TCP 198.51.100.9;branch=z9hG4bKbob\r\n"+
+		"Record-Route: <sip:proxy.b.example.com;lr>\r\n"+
+		"From: <sip:bob@b.example.com>;tag=bob-tag\r\n"+
+		"To: <sip:alice@a.example.com>\r\n"+
+		"Call-ID: external@198.51.100.9\r\n"+
+		"CSeq: 1 INVITE\r\n"+
+		"Contact: <sip:bob@198.51.100.9;transport=tcp>\r\n"+
+		"Content-Length: 0\r\n\r\n")
+	if err := topologyHiding.RestoreRequest(invite); err != nil {
+		t.Fatal(err)
+	}
+	internal := invite.GetCallIdentifier()
+	if !strings.HasPrefix(internal, "th.") {
+		t.Fatal("external Call-ID not encrypted", internal)
+	}
+
+	// alice answers through the internal proxy and the SBC
+	response := parseResponse(t, "SIP/2.0 200 OK\r\n"+
+		"Via: SIP/2.0/TCP 198.51.100.9;branch=z9hG4bKbob\r\n"+
+		"Record-Route: <sip:10.1.1.1;lr>\r\n"+
+		"Record-Route: <sip:sbc.example.com;transport=tcp;lr>\r\n"+
+		"Record-Route: <sip:proxy.b.example.com;lr>\r\n"+
+		"From: <sip:bob@b.example.com>;tag=bob-tag\r\n"+
+		"To: <sip:alice@a.example.com>;tag=alice-tag\r\n"+
+		"Call-ID: "+internal+"\r\n"+
+		"CSeq: 1 INVITE\r\n"+
+		"Contact: <sip:alice@10.2.2.2>\r\n"+
+		"Content-Length: 0\r\n\r\n")
+	if err := topologyHiding.HideResponse(response); err != nil {
+		t.Fatal(err)
+	}
+	if s := response.String(); strings.Contains(s, "10.") || response.GetCallIdentifier() != "external@198.51.100.9" {
+		t.Fatal("bad hidden response", s)
+	}
+	recordRoutes := getURIs(response, core.SIPHeaderNames_RECORD_ROUTE)
+	if len(recordRoutes) != 2 || recordRoutes[1] != "sip:proxy.b.example.com;lr" ||
+		!strings.HasSuffix(recordRoutes[0], "@sbc.example.com;transport=tcp;lr") {
+		t.Fatal("bad Record-Route", recordRoutes)
+	}
+
+	// the BYE of bob uses the route set in the reverse order
+	bye := parseRequest(t, "BYE "+getURIs(response, core.SIPHeaderNames_CONTACT)[0]+" SIP/2.0\r\n"+
+		"Via: SIP/2.0/TCP proxy.b.example.com;branch=z9hG4bKbye\r\n"+
+		"Route: <"+recordRoutes[0]+">\r\n"+
+		"From: <sip:bob@b.example.com>;tag=bob-tag\r\n"+
+		"To: <sip:alice@a.example.com>;tag=alice-tag\r\n"+
+		"Call-ID: external@198.51.100.9\r\n"+
+		"CSeq: 2 BYE\r\n"+
+		"Content-Length: 0\r\n\r\n")
+	if err := topologyHiding.RestoreRequest(bye); err != nil {
+		t.Fatal(err)
+	}
+	if routes := getURIs(bye, core.SIPHeaderNames_ROUTE); bye.GetCallIdentifier() != internal ||
+		bye.GetRequestURI().String() != "sip:alice@10.2.2.2" || len(routes) != 1 || routes[0] != "sip:10.1.1.1;lr" {
+		t.Error("BYE not restored", bye)
+	}
+}
+
+func TestTopologyHidingForgedTokens(t *testing.T) {
+	topologyHiding := NewTopologyHiding("sbc.example.com", 5060, "UDP", topologyKey)
+	invite := internalInvite(t, message.INVITE)
+	topologyHiding.HideRequest(invite)
+	contact := getURIs(invite, core.SIPHeaderNames_CONTACT)[0]
+	recordRoute := getURIs(invite, core.SIPHeaderNames_RECORD_ROUTE)[0]
+	request := func(requestURI, route string) *message.SIPRequest {
+		return parseRequest(t, "BYE "+requestURI+" SIP/2.0\r\n"+
+			"Via: SIP/2.0/UDP 198.51.100.9;branch=z9hG4bKbye\r\n"+
+			"Route: <"+route+">\r\n"+
+			"From: <sip:bob@b.example.com>;tag=bob-tag\r\n"+
+			"To: <sip:alice@a.example.com>;tag=alice-tag\r\n"+
+			"Call-ID: "+invite.GetCallIdentifier()+"\r\n"+
+			"CSeq: 2 BYE\r\n"+
+			"Content-Length: 0\r\n\r\n")
+	}
+	forge := func(uri string) string {
+		return strings.Replace(uri, "th.", "th.A", 1)
+	}
+
+	if err := topologyHiding.RestoreRequest(request(contact, recordRoute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := topologyHiding.RestoreRequest(request(forge(contact), recordRoute)); err == nil {
+		t.Error("forged contact accepted")
+	}
+	if err := topologyHiding.RestoreRequest(request(contact, forge(recordRoute))); err == nil {
+		t.Error("forged route accepted")
+	}
+	// a contact token is not a route token
+	if err := topologyHiding.RestoreRequest(request(contact, contact+";lr")); err == nil {
+		t.Error("contact token accepted as route")
+	}
+	// nor are the tokens of another key
+	other := NewTopologyHiding("sbc.example.com", 5060, "UDP", []byte("fedcba9876543210"))
+	if err := other.RestoreRequest(request(contact, recordRoute)); err == nil {
+		t.Error("tokens of another key accepted")
+	}
+
+	response := parseResponse(t, strings.Replace(invite.CreateResponse(message.OK).String(), ";th=", ";th=A", 1))
+	if err := topologyHiding.RestoreResponse(response); err == nil {
+		t.Error("forged Via accepted")
+	}
+	// a forged Call-ID is taken for an external one
+	if callId := "th.AAAA"; topologyHiding.restoreCallId(callId) == callId {
+		t.Error("forged Call-ID restored")
+	}
+}
@@ -0,0 +1,227 @@
+/**
+ * ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ * Module Name   : GoSIP Specification
+ * File Name     : Message.go
+ *~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+ */
+
+package stun
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+/** The message types of the Binding method (RFC 5389 section 6).
+ */
+const (
+	BINDING_REQUEST        = 0x0001
+	BINDING_RESPONSE       = 0x0101
+	BINDING_ERROR_RESPONSE = 0x0111
+)
+
+/** The attributes the package understands; the others are kept as they are.
+ */
+const (
+	ATTRIBUTE_MAPPED_ADDRESS     = 0x0001
+	ATTRIBUTE_XOR_MAPPED_ADDRESS = 0x0020
+	ATTRIBUTE_SOFTWARE           = 0x8022
+)
+
+/** The magic cookie of RFC 5389 messages.
+ */
+const MAGIC_COOKIE = 0x2112A442
+
+const headerLength = 20
+
+/**
+ * An attribute of a STUN message.
+ */
+type Attribute struct {
+	Type  uint16
+	Value []byte
+}
+
+/**
+ * A STUN message (RFC 5389), as used for the keep-alives of SIP Outbound
+ * (RFC 5626 section 4.4.2) and the connectivity checks of media relays.
+ */
+type Message struct {
+	Type          uint16
+	TransactionId [12]byte
+	Attributes    []Attribute
+}
+
+/** Creates a Binding request with a random transaction id.
+ */
+func NewBindingRequest() *Message {
+	this := &Message{Type: BINDING_REQUEST}
+	rand.Read(this.TransactionId[:])
+	return this
+}
+
+/** Creates the success response to a Binding request received from source,
+ * reflecting source in an XOR-MAPPED-ADDRESS attribute.
+ */
+func NewBindingResponse(request *Message, source *net.UDPAddr) *Message {
+	this := &Message{Type: BINDING_RESPONSE, TransactionId: request.TransactionId}
+	this.SetMappedAddress(source)
+	return this
+}
+
+/** Returns true if data looks like a STUN message: the two first bits are
+ * zero and the magic cookie is present. It tells STUN from SIP when both are
+ * received on the same socket.
+ */
+func IsMessage(data []byte) bool {
+	return len(data) >= headerLength && data[0]&0xc0 == 0 &&
+		binary.BigEndian.Uint32(data[4:8]) == MAGIC_COOKIE
+}
+
+/** Parses a STUN message.
+ */
+func Parse(data []byte) (*Message, error) {
+	if !IsMessage(data) {
+		return nil, errors.New("ParseException: not a STUN message")
+	}
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if length%4 != 0 || headerLength+length > len(data) {
+		return nil, errors.New("ParseException: bad STUN message length")
+	}
+	this := &Message{Type: binary.BigEndian.Uint16(data[0:2])}
+	copy(this.TransactionId[:], data[8:20])
+	for body := data[headerLength : headerLength+length]; len(body) > 0; {
+		if len(body) < 4 {
+			return nil, errors.New("ParseException: truncated STUN attribute")
+		}
+		attributeType := binary.BigEndian.Uint16(body[0:2])
+		attributeLength := int(binary.BigEndian.Uint16(body[2:4]))
+		padded := (attributeLength + 3) &^ 3
+		if 4+padded > len(body) {
+			return nil, errors.New("ParseException: truncated STUN attribute")
+		}
+		value := append([]byte(nil), body[4:4+attributeLength]...)
+		this.Attributes = append(this.Attributes, Attribute{Type: attributeType, Value: value})
+		body = body[4+padded:]
+	}
+	return this, nil
+}
+
+/** Encodes the message.
+ */
+func (this *Message) Encode() []byte {
+	var body bytes.Buffer
+	for _, attribute := range this.Attributes {
+		binary.Write(&body, binary.BigEndian, attribute.Type)
+		binary.Write(&body, binary.BigEndian, uint16(len(attribute.Value)))
+		body.Write(attribute.Value)
+		for i := len(attribute.Value); i%4 != 0; i++ {
+			body.WriteByte(0)
+		}
+	}
+	data := make([]byte, headerLength, headerLength+body.Len())
+	binary.BigEndian.PutUint16(data[0:2], this.Type)
+	binary.BigEndian.PutUint16(data[2:4], uint16(body.Len()))
+	binary.BigEndian.PutUint32(data[4:8], MAGIC_COOKIE)
+	copy(data[8:20], this.TransactionId[:])
+	return append(data, body.Bytes()...)
+}
+
+/** Returns true if the message is a request.
+ */
+func (this *Message) IsRequest() bool {
+	return this.Type&0x0110 == 0
+}
+
+/** Returns true if the message is a success response.
+ */
+func (this *Message) IsSuccessResponse() bool {
+	return this.Type&0x0110 == 0x0100
+}
+
+/** Returns the first attribute of a type, or nil if there is none.
+ */
+func (this *Message) GetAttribute(attributeType uint16) *Attribute {
+	for i := range this.Attributes {
+		if this.Attributes[i].Type == attributeType {
+			return &this.Attributes[i]
+		}
+	}
+	return nil
+}
+
+/** Returns the reflexive transport address of a Binding response, taken
+ * from its XOR-MAPPED-ADDRESS or else its MAPPED-ADDRESS, or nil if it has
+ * none.
+ */
+func (this *Message) GetMappedAddress() *net.UDPAddr {
+	if attribute := this.GetAttribute(ATTRIBUTE_XOR_MAPPED_ADDRESS); attribute != nil {
+		return this.decodeAddress(attribute.Value, true)
+	}
+	if attribute := this.GetAttribute(ATTRIBUTE_MAPPED_ADDRESS); attribute != nil {
+		return this.decodeAddress(attribute.Value, false)
+	}
+	return nil
+}
+
+/** Sets the XOR-MAPPED-ADDRESS attribute to addr.
+ */
+func (this *Message) SetMappedAddress(addr *net.UDPAddr) {
+	ip := addr.IP.To4()
+	family := byte(0x01)
+	if ip == nil {
+		ip = addr.IP.To16()
+		family = 0x02
+	}
+	value := make([]byte, 4+len(ip))
+	value[1] = family
+	binary.BigEndian.PutUint16(value[2:4], uint16(addr.Port)^(MAGIC_COOKIE>>16))
+	copy(value[4:], ip)
+	this.xorAddress(value[4:])
+
+	for i := range this.Attributes {
+		if this.Attributes[i].Type == ATTRIBUTE_XOR_MAPPED_ADDRESS {
+			this.Attributes[i].Value = value
+			return
+		}
+	}
+	this.Attributes = append(this.Attributes, Attribute{Type: ATTRIBUTE_XOR_MAPPED_ADDRESS, Value: value})
+}
+
+func (this *Message) decodeAddress(value []byte, xor bool) *net.UDPAddr {
+	if len(value) < 8 {
+		return nil
+	}
+	var ip []byte
+	switch value[1] {
+	case 0x01:
+		ip = append([]byte(nil), value[4:8]...)
+	case 0x02:
+		if len(value) < 20 {
+			return nil
+		}
+		ip = append([]byte(nil), value[4:20]...)
+	default:
+		return nil
+	}
+	port := binary.BigEndian.Uint16(value[2:4])
+	if xor {
+		port ^= MAGIC_COOKIE >> 16
+		this.xorAddress(ip)
+	}
+	return &net.UDPAddr{IP: net.IP(ip), Port: int(port)}
+}
+
+/** XORs an address with the magic cookie and, for IPv6, the transaction id.
+ */
+func (this *Message) xorAddress(ip []byte) {
+	var key [16]byte
+	binary.BigEndian.PutUint32(key[0:4], MAGIC_COOKIE)
+	copy(key[4:], this.TransactionId[:])
+	for i := range ip {
+		ip[i] ^= key[i]
+	}
+}
@@ -0,0 +1,67 @@
+package stun
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseResponse(t *testing.T) {
+	// the IPv4 response of RFC 5769 section 2.2
+	data, _ := hex.DecodeString(strings.Join([]string{
+		"0101003c2112a442b7e7a701bc34d686fa87dfae",
+		"8022000b7465737420766563746f7220",
+		"00200008" + "0001a147e112a643",
+		"000800142b91f599fd9e90c38c7489f92af9ba53f06be7d7",
+		"80280004c07d4c96",
+	}, ""))
+	if !IsMessage(data) {
+		t.Fatal("not a STUN message")
+	}
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsSuccessResponse() || len(m.Attributes) != 4 {
+		t.Errorf("bad message %+v", m)
+	}
+	if addr := m.GetMappedAddress(); addr == nil || addr.String() != "192.0.2.1:32853" {
+		t.Errorf("bad mapped address %v", addr)
+	}
+	if software := m.GetAttribute(ATTRIBUTE_SOFTWARE); software == nil || string(software.Value) != "test vector" {
+		t.Errorf("bad software %v", software)
+	}
+	// the vector pads the SOFTWARE attribute with spaces, Encode with zeros
+	if encoded := m.Encode(); len(encoded) != len(data) || !bytes.Equal(encoded[36:], data[36:]) {
+		t.Errorf("bad encoding %x", encoded)
+	}
+}
+
+func TestBinding(t *testing.T) {
+	request := NewBindingRequest()
+	if !request.IsRequest() {
+		t.Error("not a request")
+	}
+	parsed, err := Parse(request.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range []string{"203.0.113.9:5060", "[2001:db8::1]:40000"} {
+		addr, _ := net.ResolveUDPAddr("udp", source)
+		response, err := Parse(NewBindingResponse(parsed, addr).Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.TransactionId != request.TransactionId || !response.IsSuccessResponse() {
+			t.Errorf("bad response %+v", response)
+		}
+		if mapped := response.GetMappedAddress(); mapped == nil || mapped.String() != addr.String() {
+			t.Errorf("bad mapped address %v for %v", mapped, addr)
+		}
+	}
+	if IsMessage([]byte("\r\n\r\n")) || IsMessage([]byte("REGISTER sip:example.com SIP/2.0\r\n")) {
+		t.Error("SIP taken for STUN")
+	}
+}